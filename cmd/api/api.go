@@ -7,8 +7,62 @@ import (
 	"log"
 	// Import the net/http package for HTTP server functionalities
 	"net/http"
+	// Import the time package for the reservation sweep interval
+	"time"
+	// Import the config package for the identity provider settings
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the admin package, containing the admin-only handlers
+	"github.com/FreekAlberti/Ecom/cmd/service/admin"
+	// Import the audit package, containing the store for the audit log
+	"github.com/FreekAlberti/Ecom/cmd/service/audit"
+	// Import the catalog package, containing the bulk product import and export
+	"github.com/FreekAlberti/Ecom/cmd/service/catalog"
+	// Import the currency package, containing the exchange rates and price lists
+	"github.com/FreekAlberti/Ecom/cmd/service/currency"
+	// Import the fulfillment package, containing the warehouse routing engine
+	"github.com/FreekAlberti/Ecom/cmd/service/fulfillment"
+	// Import the giftcard package, containing the gift card and store credit handlers and store
+	"github.com/FreekAlberti/Ecom/cmd/service/giftcard"
+	// Import the history package, containing the order history and reorder handlers
+	"github.com/FreekAlberti/Ecom/cmd/service/history"
+	// Import the inventory package, containing the stock and reservation handlers
+	"github.com/FreekAlberti/Ecom/cmd/service/inventory"
+	// Import the invoice package, containing the invoice and credit note handlers, renderer and store
+	"github.com/FreekAlberti/Ecom/cmd/service/invoice"
+	// Import the magiclink package, containing the passwordless login handlers
+	"github.com/FreekAlberti/Ecom/cmd/service/magiclink"
+	// Import the mailer package, used to send emails
+	"github.com/FreekAlberti/Ecom/cmd/service/mailer"
+	// Import the media package, containing the product image handlers and file storage
+	"github.com/FreekAlberti/Ecom/cmd/service/media"
+	// Import the oidc package, containing the social login handlers
+	"github.com/FreekAlberti/Ecom/cmd/service/oidc"
+	// Import the order package, containing the checkout handler and order store
+	"github.com/FreekAlberti/Ecom/cmd/service/order"
+	// Import the payment package, containing the payment provider and the saved payment methods
+	"github.com/FreekAlberti/Ecom/cmd/service/payment"
+	// Import the product package, containing the catalog handlers and store
+	"github.com/FreekAlberti/Ecom/cmd/service/product"
+	// Import the promotion package, containing the promotion engine, handlers and store
+	"github.com/FreekAlberti/Ecom/cmd/service/promotion"
+	// Import the restock package, containing the low-stock alerts and reorder suggestions
+	"github.com/FreekAlberti/Ecom/cmd/service/restock"
+	// Import the returns package, containing the return and refund handlers and store
+	"github.com/FreekAlberti/Ecom/cmd/service/returns"
+	// Import the search package, containing the product search indexes
+	"github.com/FreekAlberti/Ecom/cmd/service/search"
+	// Import the shipment package, containing the shipment and tracking handlers and store
+	"github.com/FreekAlberti/Ecom/cmd/service/shipment"
+	// Import the shipping package, containing the shipping zones, methods and rate providers
+	"github.com/FreekAlberti/Ecom/cmd/service/shipping"
+	// Import the subscription package, containing the subscription handlers, store and renewal scheduler
+	"github.com/FreekAlberti/Ecom/cmd/service/subscription"
+	// Import the tax package, containing the tax calculator
+	"github.com/FreekAlberti/Ecom/cmd/service/tax"
 	// Import the user package, likely containing handlers and logic for user-related operations
 	"github.com/FreekAlberti/Ecom/cmd/service/user"
+	// Import the webhook package, used to send events to other systems
+	"github.com/FreekAlberti/Ecom/cmd/service/webhook"
 	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers
 	"github.com/gorilla/mux"
 )
@@ -51,6 +105,202 @@ func (s *APIServer) Run() error {
 	// Routes might include endpoints like /login, /register, and others under /api/v1.
 	userHandler.RegisterRoutes(subrouter)
 
+	// Create the audit log store, used to record privileged actions.
+	auditStore := audit.NewStore(s.db)
+
+	// Register the admin-only routes, such as /admin/users, under /api/v1.
+	adminHandler := admin.NewHandler(userStore, auditStore)
+	adminHandler.RegisterRoutes(subrouter)
+
+	// Register the social login routes, such as /oauth/{provider}/login, for the configured identity providers.
+	oidcHandler := oidc.NewHandler(oidc.NewProviders(config.Envs.OIDCProviders), userStore, oidc.NewStore(s.db))
+	oidcHandler.RegisterRoutes(subrouter)
+
+	// Create the mailer configured in the environment, used to send emails such as login links.
+	mail := mailer.New()
+
+	// Register the passwordless login routes, such as /login/magic-link.
+	magicLinkHandler := magiclink.NewHandler(userStore, magiclink.NewStore(s.db), mail)
+	magicLinkHandler.RegisterRoutes(subrouter)
+
+	// Create the inventory store, and expire the reservations of abandoned checkouts in the background.
+	inventoryStore := inventory.NewStore(s.db)
+	inventory.StartReservationSweeper(inventoryStore, time.Minute)
+
+	// Create the fulfillment routing engine with the configured strategy, which chooses the warehouses orders ship from.
+	strategy, err := fulfillment.NewStrategy(config.Envs.FulfillmentStrategy)
+	if err != nil {
+		return err
+	}
+	engine := fulfillment.NewEngine(inventoryStore, strategy)
+
+	// Create the order store, and the allocator giving received stock to backordered and preordered items.
+	orderStore := order.NewStore(s.db)
+	allocator := order.NewAllocator(orderStore, inventoryStore, inventoryStore, engine)
+
+	// Register the inventory routes, such as /inventory/{sku} and /admin/warehouses.
+	inventoryHandler := inventory.NewHandler(inventoryStore, inventoryStore, userStore, allocator)
+	inventoryHandler.RegisterRoutes(subrouter)
+
+	// Register the fulfillment admin routes, such as /admin/fulfillment/plan.
+	fulfillmentHandler := fulfillment.NewHandler(engine, userStore)
+	fulfillmentHandler.RegisterRoutes(subrouter)
+
+	// Alert the ops team by email and webhook when stock runs low, scanning in the background.
+	webhooks := webhook.NewSender(config.Envs.WebhookURLs, config.Envs.WebhookSecret)
+	monitor := restock.NewMonitor(restock.NewStore(s.db), inventoryStore, orderStore, mail, config.Envs.OpsEmail, webhooks)
+	monitor.Start(time.Second * time.Duration(config.Envs.LowStockScanIntervalInSeconds))
+
+	// Register the reorder routes, such as /admin/reorder/suggestions.
+	restockHandler := restock.NewHandler(monitor, userStore)
+	restockHandler.RegisterRoutes(subrouter)
+
+	// Create the configured product search index, and a product store keeping it in sync.
+	index, err := search.NewIndex(config.Envs.SearchBackend, s.db)
+	if err != nil {
+		return err
+	}
+	catalogStore := product.NewStore(s.db, config.Envs.BaseCurrency)
+	productStore := product.NewIndexedStore(catalogStore, index)
+
+	// The in-memory index starts empty, so fill it with the catalog.
+	if config.Envs.SearchBackend == search.BackendMemory {
+		if err := productStore.Reindex(); err != nil {
+			return err
+		}
+	}
+
+	// Build the search suggestions from the catalog and the query log, and keep them up to date in the background.
+	queryLog := search.NewStore(s.db)
+	suggester := search.NewSuggester()
+	queryWindow := int(config.Envs.SuggestQueryWindowDays)
+	if err := search.RebuildSuggester(suggester, productStore, catalogStore, queryLog, queryWindow); err != nil {
+		return err
+	}
+	search.StartSuggesterRefresh(suggester, productStore, catalogStore, queryLog, queryWindow, time.Second*time.Duration(config.Envs.SuggestRefreshIntervalInSeconds))
+
+	// Load the exchange rates of the base currency, which prices in other currencies are converted at
+	// unless a product has a price set in them.
+	rates, err := currency.NewRatesFile(config.Envs.ExchangeRatesFile, config.Envs.BaseCurrency)
+	if err != nil {
+		return err
+	}
+	pricer := currency.NewPricer(rates, catalogStore)
+
+	// Register the currency routes, such as /currencies and /admin/exchange-rates.
+	currencyHandler := currency.NewHandler(rates, pricer, catalogStore, productStore, userStore)
+	currencyHandler.RegisterRoutes(subrouter)
+
+	// Create the promotion engine applying automatic promotions and coupon codes to carts, and register the
+	// promotion routes, such as /admin/promotions.
+	promotionStore := promotion.NewStore(s.db)
+	promotions := promotion.NewEngine(promotionStore, orderStore, pricer)
+	promotionHandler := promotion.NewHandler(promotionStore, pricer, userStore)
+	promotionHandler.RegisterRoutes(subrouter)
+
+	// Register the gift card and store credit routes, such as /admin/gift-cards and /me/store-credit.
+	balanceStore := giftcard.NewStore(s.db)
+	giftCardHandler := giftcard.NewHandler(balanceStore, orderStore, pricer, userStore)
+	giftCardHandler.RegisterRoutes(subrouter)
+
+	// Load the tax rules and the sales tax rates orders are taxed with.
+	taxRules, err := tax.LoadRules(config.Envs.TaxRulesFile)
+	if err != nil {
+		return err
+	}
+	salesTax, err := tax.LoadSalesTaxTable(config.Envs.SalesTaxRatesFile)
+	if err != nil {
+		return err
+	}
+	taxes, err := tax.NewCalculator(taxRules, salesTax)
+	if err != nil {
+		return err
+	}
+
+	// Register the shipping routes, such as /admin/shipping-zones. Methods are priced by their rate tables;
+	// carrier APIs can be added as more rate providers.
+	shippingStore := shipping.NewStore(s.db, config.Envs.BaseCurrency)
+	quoter := shipping.NewQuoter(shippingStore, pricer)
+	shippingHandler := shipping.NewHandler(shippingStore, quoter, pricer, userStore)
+	shippingHandler.RegisterRoutes(subrouter)
+
+	// Register the order routes, such as /cart/price, /cart/shipping-options and /cart/checkout. Carts are priced
+	// from the catalog, and what is left due is charged to a saved payment method through the payment provider.
+	carts := order.NewCartPricer(productStore, catalogStore, pricer, promotions)
+	payments, err := payment.New(config.Envs.PaymentProvider)
+	if err != nil {
+		return err
+	}
+	paymentMethodStore := payment.NewStore(s.db)
+	orderHandler := order.NewHandler(orderStore, inventoryStore, inventoryStore, engine, carts, promotionStore, balanceStore, paymentMethodStore, payments, taxes, quoter, pricer, userStore)
+	orderHandler.RegisterRoutes(subrouter)
+
+	// Register the shipment routes, such as /admin/orders/{id}/shipments and /orders/{id}/shipments. Customers are
+	// emailed when their packages ship and arrive.
+	shipmentStore := shipment.NewStore(s.db)
+	shipmentHandler := shipment.NewHandler(shipmentStore, orderStore, inventoryStore, mail, userStore)
+	shipmentHandler.RegisterRoutes(subrouter)
+
+	// Register the return routes, such as /orders/{id}/returns and /admin/returns. Restocked items go to orders
+	// waiting for them, and refunds are paid back through the payment provider or as store credit.
+	returnStore := returns.NewStore(s.db)
+	returnHandler := returns.NewHandler(returnStore, orderStore, shipmentStore, inventoryStore, allocator, balanceStore, payments, userStore)
+	returnHandler.RegisterRoutes(subrouter)
+
+	// Register the invoice routes, such as /orders/{id}/invoice.pdf and /admin/invoices. Invoices and credit notes
+	// are numbered per fiscal year and never change once issued.
+	invoiceHandler := invoice.NewHandler(invoice.NewStore(s.db), orderStore, returnStore, productStore, userStore)
+	invoiceHandler.RegisterRoutes(subrouter)
+
+	// Register the order history routes, such as /me/orders and /me/orders/{id}/reorder. Reorders are priced
+	// like any other cart.
+	historyHandler := history.NewHandler(orderStore, shipmentStore, returnStore, carts, productStore, catalogStore, inventoryStore, inventoryStore, pricer, userStore)
+	historyHandler.RegisterRoutes(subrouter)
+
+	// Register the payment method routes, such as /me/payment-methods.
+	paymentHandler := payment.NewHandler(paymentMethodStore, userStore)
+	paymentHandler.RegisterRoutes(subrouter)
+
+	// Start the scheduler renewing subscriptions as they fall due, and register the subscription routes, such as
+	// /me/subscriptions and /admin/subscription-plans. Renewals are placed as checkout places orders, and failed
+	// ones are retried on the dunning schedule.
+	subscriptionStore := subscription.NewStore(s.db)
+	scheduler := subscription.NewScheduler(subscriptionStore, paymentMethodStore, orderHandler, payments, mail, userStore, config.Envs.SubscriptionRetryDays)
+	scheduler.Start(time.Second * time.Duration(config.Envs.SubscriptionScanIntervalInSeconds))
+
+	subscriptionHandler := subscription.NewHandler(subscriptionStore, paymentMethodStore, carts, pricer, userStore)
+	subscriptionHandler.RegisterRoutes(subrouter)
+
+	// Register the product routes, such as /products, /products/search and /categories.
+	productHandler := product.NewHandler(productStore, catalogStore, catalogStore, index, suggester, queryLog, pricer, userStore)
+	productHandler.RegisterRoutes(subrouter)
+
+	// Register the bulk catalog routes, such as /admin/catalog/import. Imports go through the indexed store,
+	// so imported products are searchable, and give imported stock to waiting orders.
+	catalogHandler := catalog.NewHandler(
+		catalog.NewImporter(productStore, catalogStore, catalogStore, inventoryStore, allocator, config.Envs.BaseCurrency),
+		catalog.NewExporter(productStore, catalogStore, catalogStore, inventoryStore),
+		userStore,
+	)
+	catalogHandler.RegisterRoutes(subrouter)
+
+	// Register the search routes, such as /search/suggest.
+	searchHandler := search.NewHandler(suggester)
+	searchHandler.RegisterRoutes(subrouter)
+
+	// Create the configured storage for uploaded files. Files stored locally are served by this server.
+	blobs, err := media.NewBlobStore()
+	if err != nil {
+		return err
+	}
+	if local, ok := blobs.(*media.LocalStore); ok {
+		router.PathPrefix(media.LocalPathPrefix).Handler(http.StripPrefix(media.LocalPathPrefix, local.Handler()))
+	}
+
+	// Register the product image routes, such as /products/{id}/images.
+	mediaHandler := media.NewHandler(media.NewStore(s.db), blobs, productStore, userStore, config.Envs.MaxImageUploadBytes)
+	mediaHandler.RegisterRoutes(subrouter)
+
 	// Log that the server is starting, and indicate the address it will be listening on.
 	log.Println("Listening on", s.addr)
 
@@ -0,0 +1,164 @@
+package main
+
+import (
+	// Import the encoding/json package to print import reports
+	"encoding/json"
+	// Import the flag package for the options of the subcommands
+	"flag"
+	// Import the io package for the files read and written
+	"io"
+	// Import the log package for logging errors and other messages
+	"log"
+	// Import the os package for the command line arguments, files and standard streams
+	"os"
+	// Import the path/filepath and strings packages to guess the format of a file from its name
+	"path/filepath"
+	"strings"
+
+	// Import the config and db packages to connect to the database the same way the API server does
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	"github.com/FreekAlberti/Ecom/cmd/db"
+	// Import the services the catalog import and export work with
+	"github.com/FreekAlberti/Ecom/cmd/service/catalog"
+	"github.com/FreekAlberti/Ecom/cmd/service/fulfillment"
+	"github.com/FreekAlberti/Ecom/cmd/service/inventory"
+	"github.com/FreekAlberti/Ecom/cmd/service/order"
+	"github.com/FreekAlberti/Ecom/cmd/service/product"
+	"github.com/FreekAlberti/Ecom/cmd/service/search"
+
+	// Import the MySQL driver for the Go SQL package
+	mysqlCfg "github.com/go-sql-driver/mysql"
+)
+
+const usage = "usage: catalog import [-dry-run] [-format csv|jsonl] FILE\n       catalog export [-format csv|jsonl] [-o FILE]"
+
+// main imports products, variants and stock levels from a CSV or JSON Lines file, or exports them to one.
+// A file named "-" is read from standard input. Import reports are printed as JSON, and the exit status is 1
+// if any row was rejected.
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	// Connect to the database using the same configuration as the API server.
+	db, err := db.NewMySQLStorage(mysqlCfg.Config{
+		User:                 config.Envs.DBUser,
+		Passwd:               config.Envs.DBPassword,
+		Addr:                 config.Envs.DBAddress,
+		DBName:               config.Envs.DBName,
+		Net:                  "tcp",
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Write products through the configured search index, like the API server. An in-memory index lives in
+	// the server, which fills it from the database when it starts.
+	index, err := search.NewIndex(config.Envs.SearchBackend, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	catalogStore := product.NewStore(db, config.Envs.BaseCurrency)
+	productStore := product.NewIndexedStore(catalogStore, index)
+	inventoryStore := inventory.NewStore(db)
+
+	switch os.Args[1] {
+	case "import":
+		strategy, err := fulfillment.NewStrategy(config.Envs.FulfillmentStrategy)
+		if err != nil {
+			log.Fatal(err)
+		}
+		allocator := order.NewAllocator(order.NewStore(db), inventoryStore, inventoryStore, fulfillment.NewEngine(inventoryStore, strategy))
+		importer := catalog.NewImporter(productStore, catalogStore, catalogStore, inventoryStore, allocator, config.Envs.BaseCurrency)
+		runImport(importer, os.Args[2:])
+	case "export":
+		runExport(catalog.NewExporter(productStore, catalogStore, catalogStore, inventoryStore), os.Args[2:])
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// runImport imports the file named in args and prints the report.
+func runImport(importer *catalog.Importer, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "check the file and report what would change, without writing anything")
+	format := flags.String("format", "", "format of the file, csv or jsonl; guessed from its name if empty")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal(usage)
+	}
+
+	name := flags.Arg(0)
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	rows, err := catalog.NewReader(formatOf(*format, name), r)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := importer.Import(rows, catalog.ImportOptions{DryRun: *dryRun})
+	if report != nil {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		e.Encode(report)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runExport writes the catalog to the file named in args, or to standard output.
+func runExport(exporter *catalog.Exporter, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "", "format of the file, csv or jsonl; guessed from its name if empty")
+	output := flags.String("o", "-", "file to write, - for standard output")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		log.Fatal(usage)
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if *output != "-" {
+		var err error
+		if f, err = os.Create(*output); err != nil {
+			log.Fatal(err)
+		}
+		w = f
+	}
+
+	if err := exporter.Export(formatOf(*format, *output), w); err != nil {
+		log.Fatal(err)
+	}
+	if f != nil {
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// formatOf returns the format given on the command line, or else the one of the file name, CSV by default.
+func formatOf(format, name string) string {
+	if format != "" {
+		return format
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return catalog.FormatJSONL
+	}
+	return catalog.FormatCSV
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	// Imports the os package for interacting with the operating system
 	"os"
+	// Imports the strconv package for converting environment variable strings to numbers
+	"strconv"
+	// Imports the strings package for splitting list-valued environment variables
+	"strings"
 
 	// Imports the godotenv package to load environment variables from a .env file
 	"github.com/lpernett/godotenv"
@@ -18,6 +22,86 @@ type Config struct {
 	DBPassword string // The password for the database
 	DBAddress  string // The address of the database, composed of host and port
 	DBName     string // The name of the database
+
+	JWTSecret              string // The secret used to sign JSON Web Tokens
+	JWTExpirationInSeconds int64  // How long an issued JSON Web Token stays valid, in seconds
+
+	ImpersonationExpirationInSeconds int64 // How long a token an admin uses to impersonate a customer stays valid, in seconds
+
+	OIDCProviders []OIDCProvider // The external identity providers users can log in with
+
+	SMTPHost     string // The host of the SMTP server used to send emails. When empty, emails are only logged
+	SMTPPort     string // The port of the SMTP server
+	SMTPUser     string // The username for the SMTP server
+	SMTPPassword string // The password for the SMTP server
+	MailFrom     string // The address emails are sent from
+
+	MagicLinkURL                 string // The page the emailed login link points to; the token is appended to it
+	MagicLinkExpirationInSeconds int64  // How long an emailed login link stays valid, in seconds
+	MagicLinkMaxPerWindow        int64  // How many login links can be requested for an email per rate limit window
+	MagicLinkWindowInSeconds     int64  // The length of the rate limit window, in seconds
+
+	FulfillmentStrategy string // The strategy used to choose the warehouses an order ships from
+
+	OpsEmail      string   // The address low-stock alerts are emailed to. When empty, no alerts are emailed
+	WebhookURLs   []string // The endpoints events such as low-stock alerts are sent to
+	WebhookSecret string   // The secret outgoing webhook requests are signed with
+
+	LowStockScanIntervalInSeconds int64 // How often stock levels are checked against the reorder thresholds, in seconds
+	ReorderLeadTimeDays           int64 // The default number of days it takes for a reorder to arrive
+	ReorderCoverDays              int64 // The default number of days of sales a reorder should cover
+	ReorderSalesWindowDays        int64 // The number of days of sales the reorder suggestions are based on
+
+	CheckoutReservationInSeconds int64 // How long stock is held for a checkout awaiting payment, in seconds
+	BackorderHoldInSeconds       int64 // How long stock allocated to a backorder or preorder is held, in seconds
+
+	SearchBackend string // The product search backend: "memory" for the in-process index, or "mysql" for MySQL full-text search
+
+	SuggestRefreshIntervalInSeconds int64 // How often search suggestions are rebuilt from the catalog and the query log, in seconds
+	SuggestQueryWindowDays          int64 // The number of days of searches popular query suggestions are based on
+
+	PopularityWindowDays int64 // The number of days of sales product listings sorted by popularity are based on
+
+	BlobBackend         string // Where uploaded files are stored: "local" for a directory served by the API, or "s3" for S3-compatible storage
+	MediaDir            string // The directory uploaded files are stored in by the local backend
+	MediaPublicURL      string // The public URL uploaded files are served at. When empty, the API server's /media path or the bucket URL
+	S3Endpoint          string // The URL of the S3-compatible storage service
+	S3Region            string // The region of the bucket
+	S3Bucket            string // The bucket uploaded files are stored in
+	S3AccessKey         string // The access key ID for the storage service
+	S3SecretKey         string // The secret access key for the storage service
+	MaxImageUploadBytes int64  // The largest image that can be uploaded, in bytes
+
+	BaseCurrency      string // The ISO 4217 code of the currency product prices are stored in, e.g. "EUR"
+	ExchangeRatesFile string // The JSON file the exchange rates of the base currency are kept in
+
+	TaxRulesFile      string // The JSON file with the VAT rates per country and tax class. When missing, nothing is taxed
+	SalesTaxRatesFile string // The CSV file with the US sales tax rates per state and ZIP code
+
+	PaymentProvider string // The provider charges and refunds are made through. "log" only logs them and is meant for development; there is no default
+
+	ReturnWindowDays int64  // The number of days after delivery customers can ask to return items
+	RefundShipping   string // When returns pay shipping back: "never", "at_fault" when the shop is at fault, or "always"
+
+	CompanyName          string   // The legal name of the shop, printed on invoices
+	CompanyAddress       []string // The lines of the address of the shop, printed on invoices
+	CompanyVATID         string   // The VAT ID of the shop, printed on invoices
+	CompanyRegistration  string   // The registration number of the shop at the chamber of commerce, printed on invoices
+	CompanyEmail         string   // The email address customers can ask about invoices at
+	FiscalYearStartMonth int64    // The month the fiscal year starts in, 1 for January; invoices are numbered per fiscal year
+
+	SubscriptionScanIntervalInSeconds int64   // How often subscriptions are checked for renewals that are due, in seconds
+	SubscriptionRetryDays             []int64 // The days between the retries of a failed renewal; the subscription is cancelled after the last
+}
+
+// OIDCProvider struct holds the settings of an external OpenID Connect identity provider
+type OIDCProvider struct {
+	Name         string   // Short name of the provider, used in URLs, e.g. "google"
+	IssuerURL    string   // The issuer URL, used to discover the provider's endpoints
+	ClientID     string   // The client ID registered with the provider
+	ClientSecret string   // The client secret registered with the provider
+	RedirectURL  string   // The callback URL registered with the provider
+	Scopes       []string // The scopes to request, which must include "openid"
 }
 
 // Envs is a global variable that stores the initialized configuration settings
@@ -35,7 +119,107 @@ func initConfig() Config {
 		DBPassword: getEnv("DB_PASSWORD", "mypassword"),
 		DBAddress:  fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
 		DBName:     getEnv("DB_NAME", "ecom"),
+
+		JWTSecret:              getEnv("JWT_SECRET", "not-so-secret-now-is-it?"),
+		JWTExpirationInSeconds: getEnvAsInt("JWT_EXP", 3600*24*7),
+
+		ImpersonationExpirationInSeconds: getEnvAsInt("IMPERSONATION_EXP", 60*15),
+
+		OIDCProviders: getOIDCProviders(),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUser:     getEnv("SMTP_USER", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@localhost"),
+
+		MagicLinkURL:                 getEnv("MAGIC_LINK_URL", "http://localhost:8080/login/magic-link?token="),
+		MagicLinkExpirationInSeconds: getEnvAsInt("MAGIC_LINK_EXP", 60*10),
+		MagicLinkMaxPerWindow:        getEnvAsInt("MAGIC_LINK_MAX_PER_WINDOW", 3),
+		MagicLinkWindowInSeconds:     getEnvAsInt("MAGIC_LINK_WINDOW", 60*15),
+
+		FulfillmentStrategy: getEnv("FULFILLMENT_STRATEGY", "consolidate"),
+
+		OpsEmail:      getEnv("OPS_EMAIL", ""),
+		WebhookURLs:   strings.Fields(strings.ReplaceAll(getEnv("WEBHOOK_URLS", ""), ",", " ")),
+		WebhookSecret: getEnv("WEBHOOK_SECRET", "not-so-secret-now-is-it?"),
+
+		LowStockScanIntervalInSeconds: getEnvAsInt("LOW_STOCK_SCAN_INTERVAL", 60*5),
+		ReorderLeadTimeDays:           getEnvAsInt("REORDER_LEAD_TIME_DAYS", 7),
+		ReorderCoverDays:              getEnvAsInt("REORDER_COVER_DAYS", 14),
+		ReorderSalesWindowDays:        getEnvAsInt("REORDER_SALES_WINDOW_DAYS", 30),
+
+		CheckoutReservationInSeconds: getEnvAsInt("CHECKOUT_RESERVATION_EXP", 60*15),
+		BackorderHoldInSeconds:       getEnvAsInt("BACKORDER_HOLD_EXP", 3600*24*30),
+
+		SearchBackend: getEnv("SEARCH_BACKEND", "memory"),
+
+		SuggestRefreshIntervalInSeconds: getEnvAsInt("SUGGEST_REFRESH_INTERVAL", 60*5),
+		SuggestQueryWindowDays:          getEnvAsInt("SUGGEST_QUERY_WINDOW_DAYS", 30),
+
+		PopularityWindowDays: getEnvAsInt("POPULARITY_WINDOW_DAYS", 30),
+
+		BlobBackend:         getEnv("BLOB_BACKEND", "local"),
+		MediaDir:            getEnv("MEDIA_DIR", "media"),
+		MediaPublicURL:      getEnv("MEDIA_PUBLIC_URL", ""),
+		S3Endpoint:          getEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Region:            getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:            getEnv("S3_BUCKET", ""),
+		S3AccessKey:         getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:         getEnv("S3_SECRET_KEY", ""),
+		MaxImageUploadBytes: getEnvAsInt("MAX_IMAGE_UPLOAD_SIZE", 10<<20),
+
+		BaseCurrency:      strings.ToUpper(getEnv("BASE_CURRENCY", "EUR")),
+		ExchangeRatesFile: getEnv("EXCHANGE_RATES_FILE", "exchange_rates.json"),
+
+		TaxRulesFile:      getEnv("TAX_RULES_FILE", "tax_rules.json"),
+		SalesTaxRatesFile: getEnv("SALES_TAX_RATES_FILE", "sales_tax_rates.csv"),
+
+		PaymentProvider: getEnv("PAYMENT_PROVIDER", ""),
+
+		ReturnWindowDays: getEnvAsInt("RETURN_WINDOW_DAYS", 30),
+		RefundShipping:   getEnv("REFUND_SHIPPING", "at_fault"),
+
+		CompanyName:          getEnv("COMPANY_NAME", "Ecom"),
+		CompanyAddress:       splitLines(getEnv("COMPANY_ADDRESS", "")),
+		CompanyVATID:         getEnv("COMPANY_VAT_ID", ""),
+		CompanyRegistration:  getEnv("COMPANY_REGISTRATION", ""),
+		CompanyEmail:         getEnv("COMPANY_EMAIL", ""),
+		FiscalYearStartMonth: getEnvAsInt("FISCAL_YEAR_START_MONTH", 1),
+
+		SubscriptionScanIntervalInSeconds: getEnvAsInt("SUBSCRIPTION_SCAN_INTERVAL", 60*5),
+		SubscriptionRetryDays:             getEnvAsInts("SUBSCRIPTION_RETRY_DAYS", []int64{1, 3, 7}),
+	}
+}
+
+// getOIDCProviders function reads the configured identity providers.
+// OIDC_PROVIDERS holds a comma-separated list of provider names, and each provider NAME is configured with
+// OIDC_NAME_ISSUER, OIDC_NAME_CLIENT_ID, OIDC_NAME_CLIENT_SECRET and optionally OIDC_NAME_REDIRECT_URL and OIDC_NAME_SCOPES
+func getOIDCProviders() []OIDCProvider {
+	providers := []OIDCProvider{}
+
+	// Goes through every provider name in the list, skipping empty entries
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		// Builds the prefix of the provider's environment variables, e.g. OIDC_GOOGLE_
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		defaultRedirect := fmt.Sprintf("%s:%s/api/v1/oauth/%s/callback", getEnv("PUBLIC_HOST", "http://localhost"), getEnv("PORT", "8080"), name)
+
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			IssuerURL:    getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", defaultRedirect),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		})
 	}
+
+	return providers
 }
 
 // getEnv function retrieves the value of a specified environment variable, or returns a fallback value if the variable is not set
@@ -46,3 +230,53 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback // If it does not exist, returns the specified fallback value
 }
+
+// getEnvAsInt function retrieves the value of a specified environment variable as an integer,
+// or returns a fallback value if the variable is not set or is not a valid integer
+func getEnvAsInt(key string, fallback int64) int64 {
+	// Checks if the environment variable with the key 'key' exists
+	if value, ok := os.LookupEnv(key); ok {
+		// Tries to parse the value as a base-10, 64-bit integer
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fallback // If the value is not a valid integer, returns the fallback value
+		}
+
+		return i // If parsing succeeds, returns the parsed integer
+	}
+
+	return fallback // If it does not exist, returns the specified fallback value
+}
+
+// getEnvAsInts function retrieves the value of a specified environment variable as a comma-separated list of
+// integers, such as "1,3,7", or returns a fallback value if the variable is not set or any entry is not a valid integer
+func getEnvAsInts(key string, fallback []int64) []int64 {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	ints := []int64{}
+	for _, field := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+		i, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return fallback
+		}
+		ints = append(ints, i)
+	}
+
+	return ints
+}
+
+// splitLines function splits a value of lines separated by semicolons, such as "Main Street 1;1011 AB Amsterdam",
+// dropping empty lines
+func splitLines(value string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(value, ";") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	// Import the log package for logging errors and other messages
+	"log"
+	// Import the os package for reading the command line arguments
+	"os"
+
+	// Import the config and db packages to connect to the database the same way the API server does
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	"github.com/FreekAlberti/Ecom/cmd/db"
+
+	// Import the MySQL driver for the Go SQL package
+	mysqlCfg "github.com/go-sql-driver/mysql"
+	// Import golang-migrate, its MySQL database driver and its file source for reading the migration files
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// main applies or rolls back the SQL migrations in cmd/migrate/migrations.
+// Run it with "up" to apply all pending migrations or "down" to roll back the last one.
+func main() {
+	// Connect to the database using the same configuration as the API server.
+	db, err := db.NewMySQLStorage(mysqlCfg.Config{
+		User:                 config.Envs.DBUser,
+		Passwd:               config.Envs.DBPassword,
+		Addr:                 config.Envs.DBAddress,
+		DBName:               config.Envs.DBName,
+		Net:                  "tcp",
+		AllowNativePasswords: true,
+		ParseTime:            true,
+		MultiStatements:      true, // Migration files may contain more than one statement
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Wrap the connection in a golang-migrate database driver.
+	driver, err := mysql.WithInstance(db, &mysql.Config{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create the migrator, reading the migration files from disk.
+	m, err := migrate.NewWithDatabaseInstance("file://cmd/migrate/migrations", "mysql", driver)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Require a direction argument.
+	if len(os.Args) < 2 {
+		log.Fatal("usage: migrate up|down")
+	}
+
+	// Run the migrations in the requested direction.
+	switch os.Args[len(os.Args)-1] {
+	case "up":
+		err = m.Up()
+	case "down":
+		err = m.Steps(-1)
+	default:
+		log.Fatalf("unknown direction %q, expected up or down", os.Args[len(os.Args)-1])
+	}
+
+	// Having nothing to migrate is not an error.
+	if err != nil && err != migrate.ErrNoChange {
+		log.Fatal(err)
+	}
+}
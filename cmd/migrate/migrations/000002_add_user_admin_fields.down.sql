@@ -0,0 +1,8 @@
+ALTER TABLE users
+  DROP INDEX `idx_users_role`,
+  DROP INDEX `idx_users_lastName`,
+  DROP INDEX `idx_users_createdAt`,
+  DROP COLUMN `passwordResetRequired`,
+  DROP COLUMN `disabled`,
+  DROP COLUMN `verified`,
+  DROP COLUMN `role`;
@@ -0,0 +1,8 @@
+ALTER TABLE users
+  ADD COLUMN `role` VARCHAR(32) NOT NULL DEFAULT 'customer',
+  ADD COLUMN `verified` BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN `disabled` BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN `passwordResetRequired` BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD INDEX `idx_users_createdAt` (`createdAt`, `id`),
+  ADD INDEX `idx_users_lastName` (`lastName`, `id`),
+  ADD INDEX `idx_users_role` (`role`);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `actorId` INT UNSIGNED NOT NULL,
+  `action` VARCHAR(64) NOT NULL,
+  `targetType` VARCHAR(64) NOT NULL,
+  `targetId` INT UNSIGNED NOT NULL,
+  `details` JSON NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_audit_log_target` (`targetType`, `targetId`),
+  INDEX `idx_audit_log_actor` (`actorId`)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `userId` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(100) NOT NULL,
+  `prefix` VARCHAR(32) NOT NULL,
+  `secretHash` CHAR(64) NOT NULL,
+  `scopes` VARCHAR(255) NOT NULL DEFAULT '',
+  `expiresAt` TIMESTAMP NULL DEFAULT NULL,
+  `lastUsedAt` TIMESTAMP NULL DEFAULT NULL,
+  `revokedAt` TIMESTAMP NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY (prefix),
+  FOREIGN KEY (userId) REFERENCES users(id)
+);
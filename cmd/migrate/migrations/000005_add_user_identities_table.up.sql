@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `userId` INT UNSIGNED NOT NULL,
+  `provider` VARCHAR(64) NOT NULL,
+  `subject` VARCHAR(255) NOT NULL,
+  `email` VARCHAR(255) NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY (provider, subject),
+  FOREIGN KEY (userId) REFERENCES users(id)
+);
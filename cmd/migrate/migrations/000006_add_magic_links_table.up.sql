@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS magic_links (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `email` VARCHAR(255) NOT NULL,
+  `tokenHash` CHAR(64) NOT NULL,
+  `deviceHash` CHAR(64) NOT NULL,
+  `expiresAt` TIMESTAMP NOT NULL,
+  `usedAt` TIMESTAMP NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY (tokenHash),
+  INDEX `idx_magic_links_email` (`email`, `createdAt`)
+);
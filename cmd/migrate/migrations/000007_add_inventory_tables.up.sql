@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS warehouses (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `code` VARCHAR(32) NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `active` BOOLEAN NOT NULL DEFAULT TRUE,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY (code)
+);
+
+CREATE TABLE IF NOT EXISTS stock_levels (
+  `warehouseId` INT UNSIGNED NOT NULL,
+  `sku` VARCHAR(64) NOT NULL,
+  `onHand` INT NOT NULL DEFAULT 0,
+  `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (warehouseId, sku),
+  INDEX `idx_stock_levels_sku` (`sku`),
+  FOREIGN KEY (warehouseId) REFERENCES warehouses(id)
+);
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `warehouseId` INT UNSIGNED NOT NULL,
+  `sku` VARCHAR(64) NOT NULL,
+  `type` ENUM('receipt', 'sale', 'return', 'adjustment', 'transfer') NOT NULL,
+  `quantity` INT NOT NULL,
+  `reference` VARCHAR(255) NOT NULL DEFAULT '',
+  `actorId` INT UNSIGNED NOT NULL DEFAULT 0,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_stock_movements_sku` (`sku`, `id`),
+  FOREIGN KEY (warehouseId) REFERENCES warehouses(id)
+);
+
+CREATE TABLE IF NOT EXISTS reservations (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `sku` VARCHAR(64) NOT NULL,
+  `quantity` INT UNSIGNED NOT NULL,
+  `reference` VARCHAR(255) NOT NULL DEFAULT '',
+  `status` ENUM('active', 'released', 'committed', 'expired') NOT NULL DEFAULT 'active',
+  `expiresAt` TIMESTAMP NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_reservations_sku` (`sku`, `status`, `expiresAt`),
+  INDEX `idx_reservations_expiry` (`status`, `expiresAt`)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE warehouses
+  DROP COLUMN `longitude`,
+  DROP COLUMN `latitude`,
+  DROP COLUMN `priority`;
@@ -0,0 +1,4 @@
+ALTER TABLE warehouses
+  ADD COLUMN `priority` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `active`,
+  ADD COLUMN `latitude` DECIMAL(9, 6) NOT NULL DEFAULT 0 AFTER `priority`,
+  ADD COLUMN `longitude` DECIMAL(9, 6) NOT NULL DEFAULT 0 AFTER `latitude`;
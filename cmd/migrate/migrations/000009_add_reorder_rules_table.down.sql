@@ -0,0 +1,4 @@
+ALTER TABLE stock_movements
+  DROP INDEX `idx_stock_movements_type`;
+
+DROP TABLE IF EXISTS reorder_rules;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS reorder_rules (
+  `sku` VARCHAR(64) NOT NULL,
+  `threshold` INT UNSIGNED NOT NULL,
+  `leadTimeDays` INT UNSIGNED NOT NULL,
+  `coverDays` INT UNSIGNED NOT NULL,
+  `alertedAt` TIMESTAMP NULL DEFAULT NULL,
+  `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (sku)
+);
+
+ALTER TABLE stock_movements
+  ADD INDEX `idx_stock_movements_type` (`type`, `createdAt`);
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS backorder_policies (
+  `sku` VARCHAR(64) NOT NULL,
+  `mode` ENUM('backorder', 'preorder') NOT NULL,
+  `waitingLimit` INT UNSIGNED NOT NULL DEFAULT 0,
+  `waiting` INT UNSIGNED NOT NULL DEFAULT 0,
+  `expectedAt` TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (sku)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `userId` INT UNSIGNED NOT NULL,
+  `status` VARCHAR(32) NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_orders_userId` (`userId`, `createdAt`),
+  FOREIGN KEY (userId) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `orderId` INT UNSIGNED NOT NULL,
+  `sku` VARCHAR(64) NOT NULL,
+  `quantity` INT UNSIGNED NOT NULL,
+  `availability` ENUM('in_stock', 'backorder', 'preorder') NOT NULL,
+  `reservationId` INT UNSIGNED NULL DEFAULT NULL,
+  `expectedAt` TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  INDEX `idx_order_items_waiting` (`sku`, `reservationId`, `id`),
+  FOREIGN KEY (orderId) REFERENCES orders(id),
+  FOREIGN KEY (reservationId) REFERENCES reservations(id)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS products (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `sku` VARCHAR(64) NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `description` TEXT NOT NULL,
+  `image` VARCHAR(255) NOT NULL DEFAULT '',
+  `price` DECIMAL(10, 2) NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY (sku),
+  FULLTEXT INDEX `idx_products_search` (`name`, `description`)
+);
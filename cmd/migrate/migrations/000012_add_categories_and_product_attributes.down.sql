@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS product_attributes;
+
+ALTER TABLE products
+  DROP FOREIGN KEY `fk_products_category`,
+  DROP INDEX `idx_products_price`,
+  DROP INDEX `idx_products_rating`,
+  DROP COLUMN `reviewCount`,
+  DROP COLUMN `rating`,
+  DROP COLUMN `categoryId`;
+
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS categories (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `slug` VARCHAR(64) NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY (slug)
+);
+
+ALTER TABLE products
+  ADD COLUMN `categoryId` INT UNSIGNED NULL DEFAULT NULL AFTER `price`,
+  ADD COLUMN `rating` DECIMAL(3, 2) NOT NULL DEFAULT 0 AFTER `categoryId`,
+  ADD COLUMN `reviewCount` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `rating`,
+  ADD INDEX `idx_products_price` (`price`, `id`),
+  ADD INDEX `idx_products_rating` (`rating`, `id`),
+  ADD CONSTRAINT `fk_products_category` FOREIGN KEY (categoryId) REFERENCES categories(id);
+
+CREATE TABLE IF NOT EXISTS product_attributes (
+  `productId` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL,
+  `value` VARCHAR(255) NOT NULL,
+
+  PRIMARY KEY (productId, name),
+  INDEX `idx_product_attributes_value` (`name`, `value`, `productId`),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS search_queries (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `query` VARCHAR(255) NOT NULL,
+  `results` INT UNSIGNED NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_search_queries_createdAt` (`createdAt`, `results`, `query`)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS attribute_definitions (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `categoryId` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL,
+  `label` VARCHAR(255) NOT NULL,
+  `type` ENUM('string', 'number', 'enum', 'boolean') NOT NULL,
+  `unit` VARCHAR(16) NOT NULL DEFAULT '',
+  `options` JSON NOT NULL,
+  `required` BOOLEAN NOT NULL DEFAULT FALSE,
+  `filterable` BOOLEAN NOT NULL DEFAULT FALSE,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY (categoryId, name),
+  FOREIGN KEY (categoryId) REFERENCES categories(id) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS product_images (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `productId` INT UNSIGNED NOT NULL,
+  `variant` VARCHAR(64) NOT NULL DEFAULT '',
+  `position` INT UNSIGNED NOT NULL,
+  `alt` VARCHAR(255) NOT NULL DEFAULT '',
+  `width` INT UNSIGNED NOT NULL,
+  `height` INT UNSIGNED NOT NULL,
+  `contentType` VARCHAR(32) NOT NULL,
+  `blobKey` VARCHAR(255) NOT NULL,
+  `extension` VARCHAR(8) NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_product_images_gallery` (`productId`, `variant`, `position`),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS product_variants (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `productId` INT UNSIGNED NOT NULL,
+  `sku` VARCHAR(64) NOT NULL,
+  `name` VARCHAR(255) NOT NULL DEFAULT '',
+  `price` DECIMAL(10, 2) NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY (sku),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS variant_attributes (
+  `variantId` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL,
+  `value` VARCHAR(255) NOT NULL,
+
+  PRIMARY KEY (variantId, name),
+  FOREIGN KEY (variantId) REFERENCES product_variants(id) ON DELETE CASCADE
+);
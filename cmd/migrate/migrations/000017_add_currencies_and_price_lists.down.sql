@@ -0,0 +1,10 @@
+ALTER TABLE users
+  DROP COLUMN `currency`;
+
+DROP TABLE IF EXISTS product_prices;
+
+ALTER TABLE product_variants
+  MODIFY COLUMN `price` DECIMAL(10, 2) NULL DEFAULT NULL;
+
+ALTER TABLE products
+  MODIFY COLUMN `price` DECIMAL(10, 2) NOT NULL;
@@ -0,0 +1,17 @@
+ALTER TABLE products
+  MODIFY COLUMN `price` DECIMAL(19, 4) NOT NULL;
+
+ALTER TABLE product_variants
+  MODIFY COLUMN `price` DECIMAL(19, 4) NULL DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS product_prices (
+  `productId` INT UNSIGNED NOT NULL,
+  `currency` CHAR(3) NOT NULL,
+  `price` DECIMAL(19, 4) NOT NULL,
+
+  PRIMARY KEY (productId, currency),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE
+);
+
+ALTER TABLE users
+  ADD COLUMN `currency` CHAR(3) NOT NULL DEFAULT '' AFTER `passwordResetRequired`;
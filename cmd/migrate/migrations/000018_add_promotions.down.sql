@@ -0,0 +1,16 @@
+ALTER TABLE order_items
+  DROP COLUMN `discount`,
+  DROP COLUMN `unitPrice`;
+
+ALTER TABLE orders
+  DROP COLUMN `freeShipping`,
+  DROP COLUMN `total`,
+  DROP COLUMN `discount`,
+  DROP COLUMN `subtotal`,
+  DROP COLUMN `currency`;
+
+DROP TABLE IF EXISTS customer_segments;
+
+DROP TABLE IF EXISTS promotion_redemptions;
+
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS promotions (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `code` VARCHAR(32) NULL DEFAULT NULL,
+  `priority` INT NOT NULL DEFAULT 0,
+  `exclusive` BOOLEAN NOT NULL DEFAULT FALSE,
+  `usageLimit` INT UNSIGNED NOT NULL DEFAULT 0,
+  `perCustomerLimit` INT UNSIGNED NOT NULL DEFAULT 0,
+  `startsAt` TIMESTAMP NULL DEFAULT NULL,
+  `endsAt` TIMESTAMP NULL DEFAULT NULL,
+  `conditions` JSON NOT NULL,
+  `action` JSON NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY `idx_promotions_code` (`code`)
+);
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `promotionId` INT UNSIGNED NOT NULL,
+  `userId` INT UNSIGNED NOT NULL,
+  `orderId` INT UNSIGNED NULL DEFAULT NULL,
+  `code` VARCHAR(32) NOT NULL DEFAULT '',
+  `discount` DECIMAL(19, 4) NOT NULL,
+  `currency` CHAR(3) NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_promotion_redemptions_promotionId` (`promotionId`, `userId`),
+  FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (orderId) REFERENCES orders(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS customer_segments (
+  `userId` INT UNSIGNED NOT NULL,
+  `segment` VARCHAR(64) NOT NULL,
+
+  PRIMARY KEY (userId, segment),
+  FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE orders
+  ADD COLUMN `currency` CHAR(3) NOT NULL DEFAULT '' AFTER `status`,
+  ADD COLUMN `subtotal` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `currency`,
+  ADD COLUMN `discount` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `subtotal`,
+  ADD COLUMN `total` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `discount`,
+  ADD COLUMN `freeShipping` BOOLEAN NOT NULL DEFAULT FALSE AFTER `total`;
+
+ALTER TABLE order_items
+  ADD COLUMN `unitPrice` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `quantity`,
+  ADD COLUMN `discount` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `unitPrice`;
@@ -0,0 +1,8 @@
+ALTER TABLE orders
+  DROP COLUMN `amountDue`,
+  DROP COLUMN `storeCreditAmount`,
+  DROP COLUMN `giftCardAmount`;
+
+DROP TABLE IF EXISTS balance_entries;
+
+DROP TABLE IF EXISTS gift_cards;
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS gift_cards (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `codeHash` CHAR(64) NOT NULL,
+  `last4` CHAR(4) NOT NULL,
+  `value` DECIMAL(19, 4) NOT NULL,
+  `currency` CHAR(3) NOT NULL,
+  `note` VARCHAR(255) NOT NULL DEFAULT '',
+  `expiresAt` TIMESTAMP NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY `idx_gift_cards_codeHash` (`codeHash`)
+);
+
+CREATE TABLE IF NOT EXISTS balance_entries (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `giftCardId` INT UNSIGNED NULL DEFAULT NULL,
+  `userId` INT UNSIGNED NULL DEFAULT NULL,
+  `orderId` INT UNSIGNED NULL DEFAULT NULL,
+  `amount` DECIMAL(19, 4) NOT NULL,
+  `currency` CHAR(3) NOT NULL,
+  `reason` VARCHAR(32) NOT NULL,
+  `note` VARCHAR(255) NOT NULL DEFAULT '',
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_balance_entries_giftCardId` (`giftCardId`, `id`),
+  INDEX `idx_balance_entries_userId` (`userId`, `giftCardId`, `currency`),
+  FOREIGN KEY (giftCardId) REFERENCES gift_cards(id),
+  FOREIGN KEY (userId) REFERENCES users(id),
+  FOREIGN KEY (orderId) REFERENCES orders(id)
+);
+
+ALTER TABLE orders
+  ADD COLUMN `giftCardAmount` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `freeShipping`,
+  ADD COLUMN `storeCreditAmount` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `giftCardAmount`,
+  ADD COLUMN `amountDue` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `storeCreditAmount`;
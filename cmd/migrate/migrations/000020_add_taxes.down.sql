@@ -0,0 +1,16 @@
+ALTER TABLE order_items
+  DROP COLUMN `tax`,
+  DROP COLUMN `taxRate`,
+  DROP COLUMN `taxClass`;
+
+ALTER TABLE orders
+  DROP COLUMN `reverseCharge`,
+  DROP COLUMN `pricesIncludeTax`,
+  DROP COLUMN `tax`,
+  DROP COLUMN `vatId`,
+  DROP COLUMN `postalCode`,
+  DROP COLUMN `state`,
+  DROP COLUMN `country`;
+
+ALTER TABLE products
+  DROP COLUMN `taxClass`;
@@ -0,0 +1,16 @@
+ALTER TABLE products
+  ADD COLUMN `taxClass` VARCHAR(32) NOT NULL DEFAULT 'standard' AFTER `categoryId`;
+
+ALTER TABLE orders
+  ADD COLUMN `country` CHAR(2) NULL DEFAULT NULL AFTER `freeShipping`,
+  ADD COLUMN `state` VARCHAR(3) NOT NULL DEFAULT '' AFTER `country`,
+  ADD COLUMN `postalCode` VARCHAR(16) NOT NULL DEFAULT '' AFTER `state`,
+  ADD COLUMN `vatId` VARCHAR(20) NOT NULL DEFAULT '' AFTER `postalCode`,
+  ADD COLUMN `tax` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `vatId`,
+  ADD COLUMN `pricesIncludeTax` BOOLEAN NOT NULL DEFAULT FALSE AFTER `tax`,
+  ADD COLUMN `reverseCharge` BOOLEAN NOT NULL DEFAULT FALSE AFTER `pricesIncludeTax`;
+
+ALTER TABLE order_items
+  ADD COLUMN `taxClass` VARCHAR(32) NOT NULL DEFAULT 'standard' AFTER `discount`,
+  ADD COLUMN `taxRate` DECIMAL(7, 4) NOT NULL DEFAULT 0 AFTER `taxClass`,
+  ADD COLUMN `tax` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `taxRate`;
@@ -0,0 +1,13 @@
+ALTER TABLE orders
+  DROP FOREIGN KEY `fk_orders_shipping_method`,
+  DROP COLUMN `shippingTax`,
+  DROP COLUMN `shipping`,
+  DROP COLUMN `shippingMethod`,
+  DROP COLUMN `shippingMethodId`;
+
+ALTER TABLE products
+  DROP COLUMN `weight`;
+
+DROP TABLE IF EXISTS shipping_methods;
+
+DROP TABLE IF EXISTS shipping_zones;
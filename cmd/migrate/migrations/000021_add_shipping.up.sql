@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS shipping_zones (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `regions` JSON NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS shipping_methods (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `zoneId` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `provider` VARCHAR(32) NOT NULL DEFAULT 'table',
+  `basis` VARCHAR(16) NOT NULL,
+  `rates` JSON NOT NULL,
+  `freeAbove` DECIMAL(19, 4) NULL DEFAULT NULL,
+  `minDays` INT UNSIGNED NOT NULL DEFAULT 0,
+  `maxDays` INT UNSIGNED NOT NULL DEFAULT 0,
+  `active` BOOLEAN NOT NULL DEFAULT TRUE,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_shipping_methods_zoneId` (`zoneId`),
+  FOREIGN KEY (zoneId) REFERENCES shipping_zones(id) ON DELETE CASCADE
+);
+
+ALTER TABLE products
+  ADD COLUMN `weight` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `taxClass`;
+
+ALTER TABLE orders
+  ADD COLUMN `shippingMethodId` INT UNSIGNED NULL DEFAULT NULL AFTER `reverseCharge`,
+  ADD COLUMN `shippingMethod` VARCHAR(255) NOT NULL DEFAULT '' AFTER `shippingMethodId`,
+  ADD COLUMN `shipping` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `shippingMethod`,
+  ADD COLUMN `shippingTax` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `shipping`,
+  ADD CONSTRAINT `fk_orders_shipping_method` FOREIGN KEY (shippingMethodId) REFERENCES shipping_methods(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS shipment_lines;
+
+DROP TABLE IF EXISTS shipments;
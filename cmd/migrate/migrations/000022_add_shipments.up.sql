@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS shipments (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `orderId` INT UNSIGNED NOT NULL,
+  `carrier` VARCHAR(32) NOT NULL,
+  `trackingNumber` VARCHAR(64) NOT NULL DEFAULT '',
+  `trackingUrl` VARCHAR(255) NOT NULL DEFAULT '',
+  `status` VARCHAR(16) NOT NULL DEFAULT 'label_created',
+  `shippedAt` TIMESTAMP NULL DEFAULT NULL,
+  `deliveredAt` TIMESTAMP NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_shipments_orderId` (`orderId`),
+  FOREIGN KEY (orderId) REFERENCES orders(id)
+);
+
+CREATE TABLE IF NOT EXISTS shipment_lines (
+  `shipmentId` INT UNSIGNED NOT NULL,
+  `orderItemId` INT UNSIGNED NOT NULL,
+  `quantity` INT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (shipmentId, orderItemId),
+  INDEX `idx_shipment_lines_orderItemId` (`orderItemId`),
+  FOREIGN KEY (shipmentId) REFERENCES shipments(id) ON DELETE CASCADE,
+  FOREIGN KEY (orderItemId) REFERENCES order_items(id)
+);
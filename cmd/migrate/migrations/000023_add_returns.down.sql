@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS return_lines;
+
+DROP TABLE IF EXISTS returns;
+
+ALTER TABLE order_items
+  DROP COLUMN `total`;
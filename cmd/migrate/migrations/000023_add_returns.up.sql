@@ -0,0 +1,55 @@
+ALTER TABLE order_items
+  ADD COLUMN `total` DECIMAL(19, 4) NOT NULL DEFAULT 0 AFTER `tax`;
+
+-- Items ordered before their total was stored paid their price after discounts, and the tax on top when prices
+-- excluded it.
+UPDATE order_items oi JOIN orders o ON o.id = oi.orderId
+  SET oi.total = oi.unitPrice * oi.quantity - oi.discount + IF(o.pricesIncludeTax, 0, oi.tax);
+
+CREATE TABLE IF NOT EXISTS returns (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `orderId` INT UNSIGNED NOT NULL,
+  `userId` INT UNSIGNED NOT NULL,
+  `status` VARCHAR(16) NOT NULL DEFAULT 'requested',
+  `note` VARCHAR(1000) NOT NULL DEFAULT '',
+  `supportNote` VARCHAR(1000) NOT NULL DEFAULT '',
+  `carrier` VARCHAR(32) NOT NULL DEFAULT '',
+  `trackingNumber` VARCHAR(64) NOT NULL DEFAULT '',
+  `trackingUrl` VARCHAR(255) NOT NULL DEFAULT '',
+  `warehouseId` INT UNSIGNED NULL DEFAULT NULL,
+  `currency` CHAR(3) NOT NULL,
+  `refund` DECIMAL(19, 4) NOT NULL DEFAULT 0,
+  `shippingRefund` DECIMAL(19, 4) NOT NULL DEFAULT 0,
+  `providerRefund` DECIMAL(19, 4) NOT NULL DEFAULT 0,
+  `storeCreditRefund` DECIMAL(19, 4) NOT NULL DEFAULT 0,
+  `paymentRefundId` VARCHAR(64) NOT NULL DEFAULT '',
+  `storeCreditEntryId` INT UNSIGNED NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `decidedAt` TIMESTAMP NULL DEFAULT NULL,
+  `receivedAt` TIMESTAMP NULL DEFAULT NULL,
+  `refundedAt` TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  INDEX `idx_returns_orderId` (`orderId`),
+  INDEX `idx_returns_status` (`status`, `id`),
+  FOREIGN KEY (orderId) REFERENCES orders(id),
+  FOREIGN KEY (userId) REFERENCES users(id),
+  FOREIGN KEY (warehouseId) REFERENCES warehouses(id),
+  FOREIGN KEY (storeCreditEntryId) REFERENCES balance_entries(id)
+);
+
+CREATE TABLE IF NOT EXISTS return_lines (
+  `returnId` INT UNSIGNED NOT NULL,
+  `orderItemId` INT UNSIGNED NOT NULL,
+  `quantity` INT UNSIGNED NOT NULL,
+  `reason` VARCHAR(32) NOT NULL,
+  `comment` VARCHAR(1000) NOT NULL DEFAULT '',
+  `itemCondition` VARCHAR(16) NOT NULL DEFAULT '',
+  `restocked` BOOLEAN NOT NULL DEFAULT FALSE,
+  `refund` DECIMAL(19, 4) NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (returnId, orderItemId),
+  INDEX `idx_return_lines_orderItemId` (`orderItemId`),
+  FOREIGN KEY (returnId) REFERENCES returns(id) ON DELETE CASCADE,
+  FOREIGN KEY (orderItemId) REFERENCES order_items(id)
+);
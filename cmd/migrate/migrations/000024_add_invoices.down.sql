@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS invoices;
+
+DROP TABLE IF EXISTS invoice_sequences;
+
+ALTER TABLE orders
+  DROP COLUMN `shippingTaxRate`;
@@ -0,0 +1,44 @@
+ALTER TABLE orders
+  ADD COLUMN `shippingTaxRate` DECIMAL(7, 4) NOT NULL DEFAULT 0 AFTER `shippingTax`;
+
+-- Orders placed before the rate was stored get it back from the tax on shipping, to two decimals.
+UPDATE orders
+  SET shippingTaxRate = ROUND(shippingTax * 100 / (shipping - IF(pricesIncludeTax, shippingTax, 0)), 2)
+  WHERE shippingTax != 0 AND shipping - IF(pricesIncludeTax, shippingTax, 0) > 0;
+
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+  `kind` VARCHAR(16) NOT NULL,
+  `fiscalYear` SMALLINT UNSIGNED NOT NULL,
+  `lastNumber` INT UNSIGNED NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (kind, fiscalYear)
+);
+
+CREATE TABLE IF NOT EXISTS invoices (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `kind` VARCHAR(16) NOT NULL,
+  `number` VARCHAR(32) NOT NULL,
+  `fiscalYear` SMALLINT UNSIGNED NOT NULL,
+  `sequence` INT UNSIGNED NOT NULL,
+  `source` VARCHAR(32) NOT NULL,
+  `orderId` INT UNSIGNED NOT NULL,
+  `returnId` INT UNSIGNED NULL DEFAULT NULL,
+  `correctsId` INT UNSIGNED NULL DEFAULT NULL,
+  `userId` INT UNSIGNED NOT NULL,
+  `currency` CHAR(3) NOT NULL,
+  `net` DECIMAL(19, 4) NOT NULL,
+  `tax` DECIMAL(19, 4) NOT NULL,
+  `total` DECIMAL(19, 4) NOT NULL,
+  `pdf` MEDIUMBLOB NOT NULL,
+  `issuedAt` TIMESTAMP NOT NULL,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY `uq_invoices_number` (`number`),
+  UNIQUE KEY `uq_invoices_sequence` (`kind`, `fiscalYear`, `sequence`),
+  UNIQUE KEY `uq_invoices_source` (`source`),
+  INDEX `idx_invoices_orderId` (`orderId`),
+  FOREIGN KEY (orderId) REFERENCES orders(id),
+  FOREIGN KEY (returnId) REFERENCES returns(id),
+  FOREIGN KEY (correctsId) REFERENCES invoices(id),
+  FOREIGN KEY (userId) REFERENCES users(id)
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS subscription_renewals;
+
+DROP TABLE IF EXISTS subscriptions;
+
+DROP TABLE IF EXISTS subscription_plans;
+
+DROP TABLE IF EXISTS payment_methods;
@@ -0,0 +1,76 @@
+CREATE TABLE IF NOT EXISTS payment_methods (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `userId` INT UNSIGNED NOT NULL,
+  `token` VARCHAR(255) NOT NULL,
+  `brand` VARCHAR(32) NOT NULL,
+  `last4` CHAR(4) NOT NULL,
+  `expMonth` TINYINT UNSIGNED NOT NULL DEFAULT 0,
+  `expYear` SMALLINT UNSIGNED NOT NULL DEFAULT 0,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `removedAt` TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  INDEX `idx_payment_methods_userId` (`userId`),
+  FOREIGN KEY (userId) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS subscription_plans (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `sku` VARCHAR(64) NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `intervals` VARCHAR(255) NOT NULL,
+  `discount` DECIMAL(7, 4) NOT NULL DEFAULT 0,
+  `active` BOOLEAN NOT NULL DEFAULT TRUE,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_subscription_plans_sku` (`sku`)
+);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `userId` INT UNSIGNED NOT NULL,
+  `planId` INT UNSIGNED NOT NULL,
+  `sku` VARCHAR(64) NOT NULL,
+  `quantity` INT UNSIGNED NOT NULL,
+  `intervalDays` SMALLINT UNSIGNED NOT NULL,
+  `currency` CHAR(3) NOT NULL,
+  `paymentMethodId` INT UNSIGNED NOT NULL,
+  `country` CHAR(2) NOT NULL,
+  `state` VARCHAR(3) NOT NULL DEFAULT '',
+  `postalCode` VARCHAR(16) NOT NULL DEFAULT '',
+  `shippingMethodId` INT UNSIGNED NULL DEFAULT NULL,
+  `status` VARCHAR(16) NOT NULL,
+  `nextRunAt` TIMESTAMP NOT NULL,
+  `renewals` INT UNSIGNED NOT NULL DEFAULT 0,
+  `failedAttempts` INT UNSIGNED NOT NULL DEFAULT 0,
+  `lastError` VARCHAR(255) NOT NULL DEFAULT '',
+  `lastRenewedAt` TIMESTAMP NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `cancelledAt` TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  INDEX `idx_subscriptions_userId` (`userId`),
+  INDEX `idx_subscriptions_due` (`status`, `nextRunAt`),
+  FOREIGN KEY (userId) REFERENCES users(id),
+  FOREIGN KEY (planId) REFERENCES subscription_plans(id),
+  FOREIGN KEY (paymentMethodId) REFERENCES payment_methods(id),
+  FOREIGN KEY (shippingMethodId) REFERENCES shipping_methods(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS subscription_renewals (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `subscriptionId` INT UNSIGNED NOT NULL,
+  `renewal` INT UNSIGNED NOT NULL,
+  `attempt` INT UNSIGNED NOT NULL,
+  `status` VARCHAR(16) NOT NULL,
+  `orderId` INT UNSIGNED NULL DEFAULT NULL,
+  `chargeId` VARCHAR(255) NOT NULL DEFAULT '',
+  `error` VARCHAR(255) NOT NULL DEFAULT '',
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  INDEX `idx_subscription_renewals_subscriptionId` (`subscriptionId`),
+  FOREIGN KEY (subscriptionId) REFERENCES subscriptions(id),
+  FOREIGN KEY (orderId) REFERENCES orders(id)
+);
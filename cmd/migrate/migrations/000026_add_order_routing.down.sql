@@ -0,0 +1,7 @@
+ALTER TABLE order_items
+  DROP FOREIGN KEY `fk_order_items_warehouseId`,
+  DROP COLUMN `warehouseId`;
+
+ALTER TABLE orders
+  DROP COLUMN `longitude`,
+  DROP COLUMN `latitude`;
@@ -0,0 +1,7 @@
+ALTER TABLE orders
+  ADD COLUMN `latitude` DECIMAL(9, 6) NULL DEFAULT NULL AFTER `postalCode`,
+  ADD COLUMN `longitude` DECIMAL(9, 6) NULL DEFAULT NULL AFTER `latitude`;
+
+ALTER TABLE order_items
+  ADD COLUMN `warehouseId` INT UNSIGNED NULL DEFAULT NULL AFTER `reservationId`,
+  ADD CONSTRAINT `fk_order_items_warehouseId` FOREIGN KEY (`warehouseId`) REFERENCES warehouses(id);
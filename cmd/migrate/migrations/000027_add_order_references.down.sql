@@ -0,0 +1,3 @@
+ALTER TABLE orders
+  DROP INDEX `idx_orders_reference`,
+  DROP COLUMN `reference`;
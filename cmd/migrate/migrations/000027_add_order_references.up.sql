@@ -0,0 +1,3 @@
+ALTER TABLE orders
+  ADD COLUMN `reference` VARCHAR(255) NULL DEFAULT NULL AFTER `userId`,
+  ADD UNIQUE INDEX `idx_orders_reference` (`reference`);
@@ -0,0 +1,303 @@
+package admin
+
+import (
+	// Import the crypto/rand and hex packages for generating impersonation session IDs.
+	"crypto/rand"
+	"encoding/hex"
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the config package for the JWT secret and impersonation token lifetime.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the auth package for the admin-only middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle admin-only HTTP requests.
+// It contains the user store it manages and the audit store every change is recorded in.
+type Handler struct {
+	userStore  types.UserStore  // Interface for user-related data operations.
+	auditStore types.AuditStore // Interface for recording audit log entries.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(userStore types.UserStore, auditStore types.AuditStore) *Handler {
+	return &Handler{userStore: userStore, auditStore: auditStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the admin routes.
+// Every route is wrapped in auth.WithAdminAuth, so only admins can reach them.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Search and list users.
+	router.HandleFunc("/admin/users", auth.WithAdminAuth(h.handleListUsers, h.userStore)).Methods(http.MethodGet)
+
+	// Disable/enable accounts, force password resets and change roles.
+	router.HandleFunc("/admin/users/{id}", auth.WithAdminAuth(h.handleUpdateUser, h.userStore)).Methods(http.MethodPatch)
+
+	// Issue a short-lived token to act as a customer.
+	router.HandleFunc("/admin/users/{id}/impersonate", auth.WithAdminAuth(h.handleImpersonateUser, h.userStore)).Methods(http.MethodPost)
+}
+
+// handleListUsers handles GET /admin/users.
+// It supports the query parameters email (prefix), name, role, verified, createdFrom, createdTo (RFC 3339),
+// sort (a field name, prefixed with "-" for descending order), limit and cursor.
+func (h *Handler) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	// Build the filter from the query string.
+	filter, err := parseUserFilter(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Fetch the requested page of users.
+	users, next, err := h.userStore.ListUsers(filter)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"users":      users,
+		"nextCursor": next,
+	})
+}
+
+// handleUpdateUser handles PATCH /admin/users/{id}.
+// It applies the changes in the payload to the user and records an audit log entry describing them.
+func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+
+	// Read the ID of the user being changed from the URL.
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	// Parse and validate the requested changes.
+	var payload types.AdminUpdateUserPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// Admins cannot lock themselves out by disabling or demoting their own account.
+	if userID == admin.ID {
+		if (payload.Disabled != nil && *payload.Disabled) || (payload.Role != nil && *payload.Role != types.RoleAdmin) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("admins cannot disable or demote their own account"))
+			return
+		}
+	}
+
+	// Load the user being changed.
+	u, err := h.userStore.GetUserByID(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("user %d not found", userID))
+		return
+	}
+
+	// Apply the changes, remembering the old and new value of every field that actually changed.
+	changes := map[string]any{}
+	if payload.Disabled != nil && *payload.Disabled != u.Disabled {
+		changes["disabled"] = map[string]any{"from": u.Disabled, "to": *payload.Disabled}
+		u.Disabled = *payload.Disabled
+	}
+	if payload.ForcePasswordReset != nil && *payload.ForcePasswordReset != u.PasswordResetRequired {
+		changes["passwordResetRequired"] = map[string]any{"from": u.PasswordResetRequired, "to": *payload.ForcePasswordReset}
+		u.PasswordResetRequired = *payload.ForcePasswordReset
+	}
+	if payload.Role != nil && *payload.Role != u.Role {
+		changes["role"] = map[string]any{"from": u.Role, "to": *payload.Role}
+		u.Role = *payload.Role
+	}
+
+	// Nothing to save if the request did not change anything.
+	if len(changes) == 0 {
+		utils.WriteJSON(w, http.StatusOK, u)
+		return
+	}
+
+	// Save the changes.
+	if err := h.userStore.UpdateUser(*u); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Record who changed what. The change is already saved, so a failure here is logged rather than returned.
+	err = h.auditStore.CreateAuditEntry(types.AuditEntry{
+		ActorID:    admin.ID,
+		Action:     "user.update",
+		TargetType: "user",
+		TargetID:   u.ID,
+		Details:    changes,
+	})
+	if err != nil {
+		log.Printf("failed to record audit entry for user %d: %v", u.ID, err)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, u)
+}
+
+// handleImpersonateUser handles POST /admin/users/{id}/impersonate.
+// It issues a short-lived token for the target user whose claims record the impersonating admin,
+// and records the start of the impersonation session in the audit log.
+func (h *Handler) handleImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+
+	// Read the ID of the user to impersonate from the URL.
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	// Load the target user.
+	u, err := h.userStore.GetUserByID(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("user %d not found", userID))
+		return
+	}
+
+	// Only enabled customer accounts can be impersonated, so impersonation can never be used to gain
+	// another admin's privileges.
+	if u.Role != types.RoleCustomer {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("only customer accounts can be impersonated"))
+		return
+	}
+	if u.Disabled {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("disabled accounts cannot be impersonated"))
+		return
+	}
+
+	// Start a new impersonation session.
+	sessionID, err := newSessionID()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	impersonation := types.Impersonation{
+		AdminID:   admin.ID,
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Add(time.Second * time.Duration(config.Envs.ImpersonationExpirationInSeconds)).UTC(),
+	}
+
+	// Record the session before handing out the token, so there is never an unaudited session.
+	err = h.auditStore.CreateAuditEntry(types.AuditEntry{
+		ActorID:    admin.ID,
+		Action:     "user.impersonate",
+		TargetType: "user",
+		TargetID:   u.ID,
+		Details: map[string]any{
+			"sessionId": impersonation.SessionID,
+			"expiresAt": impersonation.ExpiresAt,
+		},
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Sign the impersonation token.
+	token, err := auth.CreateImpersonationJWT([]byte(config.Envs.JWTSecret), u.ID, impersonation)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"token":         token,
+		"impersonation": impersonation,
+	})
+}
+
+// newSessionID returns a random identifier for an impersonation session.
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// parseUserFilter builds a UserFilter from the query string of a GET /admin/users request.
+func parseUserFilter(r *http.Request) (types.UserFilter, error) {
+	q := r.URL.Query()
+
+	filter := types.UserFilter{
+		EmailPrefix: q.Get("email"),
+		Name:        q.Get("name"),
+		Role:        q.Get("role"),
+		Cursor:      q.Get("cursor"),
+	}
+
+	// Only known roles can be filtered on.
+	if filter.Role != "" && filter.Role != types.RoleCustomer && filter.Role != types.RoleAdmin {
+		return filter, fmt.Errorf("invalid role %q", filter.Role)
+	}
+
+	// Parse the verified flag.
+	if v := q.Get("verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid verified value %q", v)
+		}
+		filter.Verified = &verified
+	}
+
+	// Parse the creation date range.
+	for param, dst := range map[string]**time.Time{"createdFrom": &filter.CreatedFrom, "createdTo": &filter.CreatedTo} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s value %q, expected RFC 3339", param, v)
+			}
+			*dst = &t
+		}
+	}
+
+	// Parse the sort order, where a leading "-" means descending.
+	if sort := q.Get("sort"); sort != "" {
+		filter.SortDesc = strings.HasPrefix(sort, "-")
+		filter.SortBy = strings.TrimPrefix(sort, "-")
+
+		switch filter.SortBy {
+		case types.UserSortByID, types.UserSortByCreatedAt, types.UserSortByEmail, types.UserSortByLastName:
+		default:
+			return filter, fmt.Errorf("invalid sort field %q", filter.SortBy)
+		}
+	}
+
+	// Parse the page size.
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("invalid limit %q", v)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,417 @@
+package admin
+
+import (
+	"bytes"             // Import the bytes package to build request bodies
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for API key timestamps
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for user-related types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestAdminUserHandlers tests the admin user management HTTP handlers.
+func TestAdminUserHandlers(t *testing.T) {
+	// Create the stores with one admin (ID 1) and one customer (ID 2), and a router serving the handler.
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin},
+		2: {ID: 2, Email: "customer@example.com", Role: types.RoleCustomer},
+	}}
+	auditStore := &mockAuditStore{}
+
+	router := mux.NewRouter()
+	NewHandler(userStore, auditStore).RegisterRoutes(router)
+
+	t.Run("should reject non-admin users", func(t *testing.T) {
+		rr := serve(t, router, http.MethodGet, "/admin/users", nil, 2)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should pass the query filters to the store", func(t *testing.T) {
+		rr := serve(t, router, http.MethodGet, "/admin/users?email=cust&role=customer&verified=true&sort=-createdAt&limit=10", nil, 1)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		f := userStore.lastFilter
+		if f.EmailPrefix != "cust" || f.Role != types.RoleCustomer || f.Verified == nil || !*f.Verified ||
+			f.SortBy != types.UserSortByCreatedAt || !f.SortDesc || f.Limit != 10 {
+			t.Errorf("unexpected filter %+v", f)
+		}
+	})
+
+	t.Run("should reject an invalid sort field", func(t *testing.T) {
+		rr := serve(t, router, http.MethodGet, "/admin/users?sort=password", nil, 1)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should reject a cursor the store did not hand out", func(t *testing.T) {
+		rr := serve(t, router, http.MethodGet, "/admin/users?cursor=garbage", nil, 1)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should disable a user and record an audit entry", func(t *testing.T) {
+		rr := serve(t, router, http.MethodPatch, "/admin/users/2", []byte(`{"disabled": true, "role": "admin"}`), 1)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if u := userStore.users[2]; !u.Disabled || u.Role != types.RoleAdmin {
+			t.Errorf("expected user to be disabled and promoted, got %+v", u)
+		}
+		if len(auditStore.entries) != 1 || auditStore.entries[0].ActorID != 1 || auditStore.entries[0].TargetID != 2 {
+			t.Errorf("expected one audit entry by admin 1 for user 2, got %+v", auditStore.entries)
+		}
+	})
+
+	t.Run("should reject an invalid role", func(t *testing.T) {
+		rr := serve(t, router, http.MethodPatch, "/admin/users/2", []byte(`{"role": "superuser"}`), 1)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should not let an admin disable their own account", func(t *testing.T) {
+		entries := len(auditStore.entries)
+
+		rr := serve(t, router, http.MethodPatch, "/admin/users/1", []byte(`{"disabled": true}`), 1)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if len(auditStore.entries) != entries {
+			t.Errorf("expected no audit entries, got %d", len(auditStore.entries)-entries)
+		}
+	})
+}
+
+// TestAdminImpersonation tests issuing and using impersonation tokens.
+func TestAdminImpersonation(t *testing.T) {
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin},
+		2: {ID: 2, Email: "customer@example.com", Role: types.RoleCustomer},
+		3: {ID: 3, Email: "other-admin@example.com", Role: types.RoleAdmin},
+	}}
+	auditStore := &mockAuditStore{}
+
+	router := mux.NewRouter()
+	NewHandler(userStore, auditStore).RegisterRoutes(router)
+
+	t.Run("should not impersonate another admin", func(t *testing.T) {
+		rr := serve(t, router, http.MethodPost, "/admin/users/3/impersonate", nil, 1)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should issue an audited token that blocks sensitive actions", func(t *testing.T) {
+		rr := serve(t, router, http.MethodPost, "/admin/users/2/impersonate", nil, 1)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		// Decode the issued token.
+		var res struct {
+			Token         string              `json:"token"`
+			Impersonation types.Impersonation `json:"impersonation"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		// The session must be in the audit log.
+		last := auditStore.entries[len(auditStore.entries)-1]
+		if last.Action != "user.impersonate" || last.TargetID != 2 || last.Details["sessionId"] != res.Impersonation.SessionID {
+			t.Errorf("unexpected audit entry %+v", last)
+		}
+
+		// A request made with the token acts as the customer and carries the impersonating admin.
+		var seen *types.Impersonation
+		var seenUser *types.User
+		check := auth.WithAuth(func(w http.ResponseWriter, r *http.Request) {
+			seenUser = auth.GetUserFromContext(r.Context())
+			seen = auth.GetImpersonationFromContext(r.Context())
+		}, userStore)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+res.Token)
+		check(httptest.NewRecorder(), req)
+
+		if seenUser == nil || seenUser.ID != 2 || seen == nil || seen.AdminID != 1 {
+			t.Fatalf("expected customer 2 impersonated by admin 1, got user %+v and impersonation %+v", seenUser, seen)
+		}
+
+		// Sensitive actions are refused.
+		blocked := auth.WithAuth(auth.WithoutImpersonation(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("sensitive handler must not run while impersonating")
+		}), userStore)
+
+		rr = httptest.NewRecorder()
+		blocked(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+}
+
+// TestAdminAPIKeyAccess tests that admin endpoints accept API keys with the right scopes.
+func TestAdminAPIKeyAccess(t *testing.T) {
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin},
+	}}
+
+	router := mux.NewRouter()
+	NewHandler(userStore, &mockAuditStore{}).RegisterRoutes(router)
+
+	// newKey stores a new key for the admin with the given scopes and returns the full key.
+	newKey := func(scopes ...string) string {
+		key, prefix, secretHash, err := auth.GenerateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		userStore.CreateAPIKey(types.APIKey{UserID: 1, Prefix: prefix, SecretHash: secretHash, Scopes: scopes})
+		return key
+	}
+
+	// get lists users using the given API key and returns the status code.
+	get := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("should accept an unrestricted key and track its use", func(t *testing.T) {
+		key := newKey()
+
+		if code := get(key); code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, code)
+		}
+		if userStore.keys[len(userStore.keys)-1].LastUsedAt == nil {
+			t.Error("expected the key's last used time to be set")
+		}
+	})
+
+	t.Run("should reject a key without the admin scope", func(t *testing.T) {
+		if code := get(newKey(types.ScopeRead)); code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, code)
+		}
+	})
+
+	t.Run("should accept a key with the read and admin scopes", func(t *testing.T) {
+		if code := get(newKey(types.ScopeRead, types.ScopeAdmin)); code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, code)
+		}
+	})
+
+	t.Run("should reject a wrong secret and a revoked key", func(t *testing.T) {
+		key := newKey()
+		prefix, _, _ := auth.ParseAPIKey(key)
+
+		if code := get(prefix + "_wrong"); code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a wrong secret, got %d", http.StatusForbidden, code)
+		}
+
+		userStore.RevokeAPIKey(1, len(userStore.keys))
+		if code := get(key); code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a revoked key, got %d", http.StatusForbidden, code)
+		}
+	})
+}
+
+// TestPasswordResetRequired tests that a user who has to change their password is refused until they do.
+func TestPasswordResetRequired(t *testing.T) {
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin, PasswordResetRequired: true},
+	}}
+
+	router := mux.NewRouter()
+	NewHandler(userStore, &mockAuditStore{}).RegisterRoutes(router)
+
+	t.Run("should refuse a token", func(t *testing.T) {
+		rr := serve(t, router, http.MethodGet, "/admin/users", nil, 1)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should refuse an API key", func(t *testing.T) {
+		key, prefix, secretHash, err := auth.GenerateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		userStore.CreateAPIKey(types.APIKey{UserID: 1, Prefix: prefix, SecretHash: secretHash})
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should accept them once the password is changed", func(t *testing.T) {
+		userStore.UpdatePassword(1, "new")
+
+		if rr := serve(t, router, http.MethodGet, "/admin/users", nil, 1); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+}
+
+// serve sends a request authenticated as the given user through the router and returns the recorded response.
+func serve(t *testing.T, router *mux.Router, method, target string, body []byte, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign a token for the user, the same way the login handler does.
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an in-memory implementation of the UserStore interface, used for testing purposes.
+type mockUserStore struct {
+	users      map[int]*types.User // Users by ID.
+	keys       []*types.APIKey     // API keys, where the ID is the index plus one.
+	lastFilter types.UserFilter    // The filter passed to the last ListUsers call.
+}
+
+// GetUserByEmail is a mock method that always reports the user as not found.
+func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
+
+// CreateUser is a mock method that simply returns nil, indicating no error.
+func (m *mockUserStore) CreateUser(types.User) error {
+	return nil
+}
+
+// UpdateUser is a mock method that replaces the stored user.
+func (m *mockUserStore) UpdateUser(u types.User) error {
+	m.users[u.ID] = &u
+	return nil
+}
+
+// UpdatePassword is a mock method that replaces the stored password hash and clears the reset flag.
+func (m *mockUserStore) UpdatePassword(userID int, hashedPassword string) error {
+	m.users[userID].Password = hashedPassword
+	m.users[userID].PasswordResetRequired = false
+	return nil
+}
+
+// UpdateCurrency is a mock method that sets the preferred currency.
+func (m *mockUserStore) UpdateCurrency(userID int, currency string) error {
+	m.users[userID].Currency = currency
+	return nil
+}
+
+// ListUsers is a mock method that records the filter and returns no users.
+// It never hands out a cursor, so it rejects every cursor it is given.
+func (m *mockUserStore) ListUsers(filter types.UserFilter) ([]*types.User, string, error) {
+	m.lastFilter = filter
+	if filter.Cursor != "" {
+		return nil, "", types.ErrInvalidCursor
+	}
+	return nil, "", nil
+}
+
+// CreateAPIKey is a mock method that appends the key.
+func (m *mockUserStore) CreateAPIKey(key types.APIKey) (int, error) {
+	key.ID = len(m.keys) + 1
+	m.keys = append(m.keys, &key)
+	return key.ID, nil
+}
+
+// GetAPIKeyByPrefix is a mock method that looks up a key by its prefix.
+func (m *mockUserStore) GetAPIKeyByPrefix(prefix string) (*types.APIKey, error) {
+	for _, k := range m.keys {
+		if k.Prefix == prefix {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("API key not found")
+}
+
+// GetAPIKeysByUserID is a mock method that returns the keys of a user.
+func (m *mockUserStore) GetAPIKeysByUserID(userID int) ([]*types.APIKey, error) {
+	keys := []*types.APIKey{}
+	for _, k := range m.keys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey is a mock method that marks a key as revoked.
+func (m *mockUserStore) RevokeAPIKey(userID, keyID int) error {
+	if keyID < 1 || keyID > len(m.keys) || m.keys[keyID-1].UserID != userID {
+		return fmt.Errorf("API key not found")
+	}
+	now := time.Now()
+	m.keys[keyID-1].RevokedAt = &now
+	return nil
+}
+
+// TouchAPIKey is a mock method that sets the last used time of a key.
+func (m *mockUserStore) TouchAPIKey(keyID int) error {
+	now := time.Now()
+	m.keys[keyID-1].LastUsedAt = &now
+	return nil
+}
+
+// mockAuditStore is an in-memory implementation of the AuditStore interface, used for testing purposes.
+type mockAuditStore struct {
+	entries []types.AuditEntry // Recorded entries, in order.
+}
+
+// CreateAuditEntry is a mock method that records the entry.
+func (m *mockAuditStore) CreateAuditEntry(entry types.AuditEntry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	// Import the json package for storing the entry details as JSON.
+	"encoding/json"
+
+	// Import the types package for the AuditEntry type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Store struct represents the data store that writes audit log entries to the database.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+// It accepts a pointer to a sql.DB, which represents the database connection.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateAuditEntry is a method on the Store struct that appends an entry to the audit_log table.
+// The audit log is append-only: entries are never updated or deleted.
+func (s *Store) CreateAuditEntry(entry types.AuditEntry) error {
+	// Encode the free-form details as JSON, storing an empty object when there are none.
+	if entry.Details == nil {
+		entry.Details = map[string]any{}
+	}
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+
+	// Insert the entry into the audit_log table.
+	_, err = s.db.Exec(
+		"INSERT INTO audit_log (actorId, action, targetType, targetId, details) VALUES (?, ?, ?, ?, ?)",
+		entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, details,
+	)
+
+	return err
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	// Import the context package for storing the API key on the request.
+	"context"
+	// Import the crypto packages for generating and hashing API key secrets.
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+)
+
+// apiKeyPrefix is prepended to every API key, so keys are easy to recognise (for example by secret scanners).
+const apiKeyPrefix = "ek_"
+
+// GenerateAPIKey creates a new random API key.
+// It returns the full key to give to the user, its visible prefix and the hash of its secret to store.
+func GenerateAPIKey() (key, prefix, secretHash string, err error) {
+	// The public part identifies the key, the secret part proves possession of it.
+	public := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(public); err != nil {
+		return "", "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = apiKeyPrefix + hex.EncodeToString(public)
+	secretStr := hex.EncodeToString(secret)
+
+	return prefix + "_" + secretStr, prefix, HashAPIKeySecret(secretStr), nil
+}
+
+// ParseAPIKey splits a full API key into its visible prefix and its secret.
+func ParseAPIKey(key string) (prefix, secret string, err error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", "", fmt.Errorf("invalid API key")
+	}
+
+	// The key has the form ek_<public>_<secret>, so split after the prefix.
+	i := strings.LastIndex(key, "_")
+	if i <= len(apiKeyPrefix) || i == len(key)-1 {
+		return "", "", fmt.Errorf("invalid API key")
+	}
+
+	return key[:i], key[i+1:], nil
+}
+
+// HashAPIKeySecret returns the hex-encoded SHA-256 hash of an API key secret.
+// The secrets are long and random, so a fast hash is enough to protect them at rest.
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CompareAPIKeySecret checks in constant time whether a secret matches the stored hash.
+func CompareAPIKeySecret(secretHash, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(secretHash), []byte(HashAPIKeySecret(secret))) == 1
+}
+
+// WithoutAPIKey is a middleware that rejects requests authenticated with an API key.
+// It protects actions such as managing API keys, which should need an interactive login. It must be wrapped in WithAuth.
+func WithoutAPIKey(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if GetAPIKeyFromContext(r.Context()) != nil {
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("this action cannot be performed with an API key"))
+			return
+		}
+
+		handlerFunc(w, r)
+	}
+}
+
+// GetAPIKeyFromContext returns the API key the request was authenticated with, or nil if it used a bearer token.
+func GetAPIKeyFromContext(ctx context.Context) *types.APIKey {
+	key, ok := ctx.Value(APIKeyKey).(*types.APIKey)
+	if !ok {
+		return nil
+	}
+
+	return key
+}
+
+// authenticateAPIKey validates the API key of the request and returns a context holding its user and the key.
+func authenticateAPIKey(r *http.Request, key string, store types.UserStore) (context.Context, error) {
+	// Split the key and look it up by its prefix.
+	prefix, secret, err := ParseAPIKey(key)
+	if err != nil {
+		return nil, err
+	}
+	k, err := store.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key %s: %w", prefix, err)
+	}
+
+	// The secret must match, and the key must still be valid.
+	if !CompareAPIKeySecret(k.SecretHash, secret) {
+		return nil, fmt.Errorf("invalid secret for API key %s", prefix)
+	}
+	if k.RevokedAt != nil {
+		return nil, fmt.Errorf("API key %s is revoked", prefix)
+	}
+	if k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt) {
+		return nil, fmt.Errorf("API key %s is expired", prefix)
+	}
+
+	// Keys limited to scopes need the scope matching the kind of request.
+	if !k.HasScope(scopeForMethod(r.Method)) {
+		return nil, fmt.Errorf("API key %s lacks the %s scope", prefix, scopeForMethod(r.Method))
+	}
+
+	// Load the user the key belongs to.
+	u, err := getEnabledUser(store, k.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the use of the key. Failing to do so should not fail the request.
+	if err := store.TouchAPIKey(k.ID); err != nil {
+		log.Printf("failed to record use of API key %s: %v", prefix, err)
+	}
+
+	// Add the user and the key to the context.
+	ctx := context.WithValue(r.Context(), UserKey, u)
+	ctx = context.WithValue(ctx, APIKeyKey, k)
+
+	return ctx, nil
+}
+
+// scopeForMethod returns the scope an API key needs to make a request with the given HTTP method.
+func scopeForMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return types.ScopeRead
+	default:
+		return types.ScopeWrite
+	}
+}
@@ -0,0 +1,348 @@
+package auth
+
+import (
+	// Import the context package for storing the authenticated user on the request.
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the config package for the JWT secret and expiration settings.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	// Import the golang-jwt package for creating and validating JSON Web Tokens.
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// contextKey is a private type for context keys, so they cannot collide with keys from other packages.
+type contextKey string
+
+// Context keys under which the authenticated user, the impersonation details and the API key are stored.
+const (
+	UserKey          contextKey = "user"
+	ImpersonationKey contextKey = "impersonation"
+	APIKeyKey        contextKey = "apiKey"
+)
+
+// CreateJWT creates a signed JSON Web Token for the given user ID.
+// The token expires after config.Envs.JWTExpirationInSeconds seconds.
+func CreateJWT(secret []byte, userID int) (string, error) {
+	// Compute how long the token is valid for.
+	expiration := time.Second * time.Duration(config.Envs.JWTExpirationInSeconds)
+
+	// Build the token with the user ID and expiry as claims, and sign it with HMAC-SHA256.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userID":    strconv.Itoa(userID),
+		"expiresAt": time.Now().Add(expiration).Unix(),
+	})
+
+	return token.SignedString(secret)
+}
+
+// CreateLoginResponse builds the response body returned by every way of logging in:
+// a signed token for the user, and whether the user has to change their password.
+func CreateLoginResponse(u *types.User) (map[string]any, error) {
+	token, err := CreateJWT([]byte(config.Envs.JWTSecret), u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"token":                 token,
+		"passwordResetRequired": u.PasswordResetRequired,
+	}, nil
+}
+
+// CreateImpersonationJWT creates a short-lived token that lets an admin act as the given user.
+// Besides the user ID, the claims record the impersonating admin and the session ID, so every request
+// made with the token can be traced back to them. The token expires at the given time.
+func CreateImpersonationJWT(secret []byte, userID int, impersonation types.Impersonation) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userID":         strconv.Itoa(userID),
+		"impersonatorID": strconv.Itoa(impersonation.AdminID),
+		"sessionID":      impersonation.SessionID,
+		"expiresAt":      impersonation.ExpiresAt.Unix(),
+	})
+
+	return token.SignedString(secret)
+}
+
+// WithAuth is a middleware that only lets authenticated requests through.
+// A request is authenticated either by a bearer token in the Authorization header or by a personal
+// API key in the X-API-Key header. Both load the same user, reject disabled accounts and store the
+// user in the request context, so handlers do not need to know how the request was authenticated.
+// Users who have to change their password are refused until they do; see WithPasswordResetAuth.
+func WithAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
+	return withAuth(handlerFunc, store, false)
+}
+
+// WithPasswordResetAuth is a middleware like WithAuth that also lets through users who have to change their
+// password, for the routes they need to do so.
+func WithPasswordResetAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
+	return withAuth(handlerFunc, store, true)
+}
+
+// withAuth returns the authentication middleware, letting users who have to change their password through only
+// if allowPasswordReset is set.
+func withAuth(handlerFunc http.HandlerFunc, store types.UserStore, allowPasswordReset bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ctx context.Context
+		var err error
+
+		// An API key takes precedence over a bearer token.
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			ctx, err = authenticateAPIKey(r, key, store)
+		} else {
+			ctx, err = authenticateJWT(r, store)
+		}
+		if err != nil {
+			log.Printf("failed to authenticate request: %v", err)
+			permissionDenied(w)
+			return
+		}
+
+		// Credentials issued before an admin forced a password reset stop working until the password is changed.
+		if u := GetUserFromContext(ctx); u.PasswordResetRequired && !allowPasswordReset {
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("the password has to be changed first"))
+			return
+		}
+
+		// Call the wrapped handler.
+		handlerFunc(w, r.WithContext(ctx))
+	}
+}
+
+// authenticateJWT validates the bearer token of the request and returns a context holding its user.
+func authenticateJWT(r *http.Request, store types.UserStore) (context.Context, error) {
+	// Get the token from the Authorization header and validate it.
+	token, err := validateJWT(getTokenFromRequest(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate token: %w", err)
+	}
+
+	// Read the user ID from the token claims.
+	claims := token.Claims.(jwt.MapClaims)
+	userID, err := userIDFromClaims(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user ID from token: %w", err)
+	}
+
+	// Load the user, so handlers always see the current role and account flags.
+	u, err := getEnabledUser(store, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the user to the context.
+	ctx := context.WithValue(r.Context(), UserKey, u)
+
+	// If the token was issued to an admin impersonating the user, check the admin can still do so
+	// and add the impersonation details to the context as well.
+	if _, ok := claims["impersonatorID"]; ok {
+		impersonation, err := impersonationFromClaims(claims, store)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate impersonation: %w", err)
+		}
+		ctx = context.WithValue(ctx, ImpersonationKey, impersonation)
+	}
+
+	return ctx, nil
+}
+
+// getEnabledUser loads a user by ID and rejects disabled accounts,
+// so credentials issued before an account was disabled stop working.
+func getEnabledUser(store types.UserStore, userID int) (*types.User, error) {
+	u, err := store.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	if u.Disabled {
+		return nil, fmt.Errorf("user %d is disabled", userID)
+	}
+
+	return u, nil
+}
+
+// UserFromRequest returns the user authenticated by the credentials of a request, so public handlers can
+// personalize their response. It returns nil for guests and for credentials that are not valid.
+func UserFromRequest(r *http.Request, store types.UserStore) *types.User {
+	if u := GetUserFromContext(r.Context()); u != nil {
+		return u
+	}
+
+	var ctx context.Context
+	var err error
+	switch {
+	case r.Header.Get("X-API-Key") != "":
+		ctx, err = authenticateAPIKey(r, r.Header.Get("X-API-Key"), store)
+	case r.Header.Get("Authorization") != "":
+		ctx, err = authenticateJWT(r, store)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+
+	return GetUserFromContext(ctx)
+}
+
+// WithAdminAuth is a middleware that only lets authenticated users with the admin role through.
+// Requests made with an API key also need the key to have the admin scope.
+func WithAdminAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
+	return WithAuth(func(w http.ResponseWriter, r *http.Request) {
+		// Reject any authenticated user that is not an admin.
+		u := GetUserFromContext(r.Context())
+		if u == nil || u.Role != types.RoleAdmin {
+			permissionDenied(w)
+			return
+		}
+
+		// Reject API keys that were not given the admin scope.
+		if key := GetAPIKeyFromContext(r.Context()); key != nil && !key.HasScope(types.ScopeAdmin) {
+			permissionDenied(w)
+			return
+		}
+
+		handlerFunc(w, r)
+	}, store)
+}
+
+// WithoutImpersonation is a middleware that blocks sensitive actions, such as changing a password or
+// payment method, while an admin is impersonating the user. It must be wrapped in WithAuth.
+func WithoutImpersonation(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if GetImpersonationFromContext(r.Context()) != nil {
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("this action is not allowed while impersonating a user"))
+			return
+		}
+
+		handlerFunc(w, r)
+	}
+}
+
+// GetUserFromContext returns the authenticated user stored in the context, or nil if there is none.
+func GetUserFromContext(ctx context.Context) *types.User {
+	u, ok := ctx.Value(UserKey).(*types.User)
+	if !ok {
+		return nil
+	}
+
+	return u
+}
+
+// GetUserIDFromContext returns the ID of the authenticated user stored in the context, or -1 if there is none.
+func GetUserIDFromContext(ctx context.Context) int {
+	u := GetUserFromContext(ctx)
+	if u == nil {
+		return -1
+	}
+
+	return u.ID
+}
+
+// GetImpersonationFromContext returns the impersonation details stored in the context,
+// or nil if the request is not made by an admin impersonating the user.
+func GetImpersonationFromContext(ctx context.Context) *types.Impersonation {
+	impersonation, ok := ctx.Value(ImpersonationKey).(*types.Impersonation)
+	if !ok {
+		return nil
+	}
+
+	return impersonation
+}
+
+// getTokenFromRequest extracts the bearer token from the Authorization header.
+// It returns an empty string if the header is missing or malformed.
+func getTokenFromRequest(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+
+	// Accept both "Bearer <token>" and a bare token.
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return token
+	}
+
+	return header
+}
+
+// validateJWT parses a token string and checks its signature and expiry.
+func validateJWT(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		// Only accept tokens signed with HMAC, so a token cannot pick its own algorithm.
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		return []byte(config.Envs.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	// Reject expired tokens.
+	expiresAt, ok := token.Claims.(jwt.MapClaims)["expiresAt"].(float64)
+	if !ok || time.Now().Unix() > int64(expiresAt) {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	return token, nil
+}
+
+// userIDFromClaims reads the user ID stored in the token claims.
+func userIDFromClaims(claims jwt.MapClaims) (int, error) {
+	str, ok := claims["userID"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing userID claim")
+	}
+
+	return strconv.Atoi(str)
+}
+
+// impersonationFromClaims reads the impersonation details from the token claims.
+// The impersonating admin must still exist, be enabled and have the admin role, so demoting or
+// disabling an admin immediately ends their impersonation sessions.
+func impersonationFromClaims(claims jwt.MapClaims, store types.UserStore) (*types.Impersonation, error) {
+	str, ok := claims["impersonatorID"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid impersonatorID claim")
+	}
+	adminID, err := strconv.Atoi(str)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, ok := claims["sessionID"].(string)
+	if !ok || sessionID == "" {
+		return nil, fmt.Errorf("missing sessionID claim")
+	}
+
+	admin, err := store.GetUserByID(adminID)
+	if err != nil {
+		return nil, err
+	}
+	if admin.Disabled || admin.Role != types.RoleAdmin {
+		return nil, fmt.Errorf("user %d can no longer impersonate", adminID)
+	}
+
+	expiresAt, _ := claims["expiresAt"].(float64)
+
+	return &types.Impersonation{
+		AdminID:   adminID,
+		SessionID: sessionID,
+		ExpiresAt: time.Unix(int64(expiresAt), 0),
+	}, nil
+}
+
+// permissionDenied writes a 403 Forbidden error response.
+func permissionDenied(w http.ResponseWriter) {
+	utils.WriteError(w, http.StatusForbidden, fmt.Errorf("permission denied"))
+}
@@ -17,3 +17,11 @@ func HashPassword(password string) (string, error) {
 	// Convert the hashed password (byte slice) back to a string and return it.
 	return string(hash), nil
 }
+
+// ComparePasswords is a utility function that checks whether a plain text password matches a bcrypt hash.
+// It returns true if the password is correct and false otherwise.
+func ComparePasswords(hashed string, plain []byte) bool {
+	// bcrypt.CompareHashAndPassword returns nil only when the password matches the hash.
+	err := bcrypt.CompareHashAndPassword([]byte(hashed), plain)
+	return err == nil
+}
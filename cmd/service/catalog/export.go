@@ -0,0 +1,148 @@
+package catalog
+
+import (
+	"io"
+	"strconv"
+
+	// Import the types package for the store interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// exportBatchSize is the number of products read from the store at a time during an export.
+const exportBatchSize = 500
+
+// Exporter struct writes the catalog to an export file in the format imports read, so an export can be
+// edited and imported again.
+type Exporter struct {
+	products   types.ProductStore   // Interface for product data operations.
+	categories types.CategoryStore  // Interface for categories, to export their slugs.
+	variants   types.VariantStore   // Interface for product variant data operations.
+	inventory  types.InventoryStore // Interface for warehouses and stock levels.
+}
+
+// NewExporter is a constructor function that returns a new Exporter instance.
+func NewExporter(products types.ProductStore, categories types.CategoryStore, variants types.VariantStore, inventory types.InventoryStore) *Exporter {
+	return &Exporter{products: products, categories: categories, variants: variants, inventory: inventory}
+}
+
+// Export writes every product, followed by its variants, to w in the format with the given name.
+// Products are read in batches and rows written as they go, so the catalog is never held in memory.
+// CSV files have a column for every attribute in use and every warehouse.
+func (ex *Exporter) Export(format string, w io.Writer) error {
+	names, err := ex.products.GetAttributeNames()
+	if err != nil {
+		return err
+	}
+	warehouses, err := ex.inventory.GetWarehouses()
+	if err != nil {
+		return err
+	}
+	categories, err := ex.categories.GetCategories()
+	if err != nil {
+		return err
+	}
+
+	slugs := map[int]string{}
+	for _, c := range categories {
+		slugs[c.ID] = c.Slug
+	}
+	codes := map[int]string{}
+	columns := append([]string(nil), fixedColumns...)
+	for _, name := range names {
+		columns = append(columns, attributePrefix+name)
+	}
+	for _, w := range warehouses {
+		codes[w.ID] = w.Code
+		columns = append(columns, stockPrefix+w.Code)
+	}
+
+	rw, err := NewWriter(format, w, columns)
+	if err != nil {
+		return err
+	}
+
+	for afterID := 0; ; {
+		products, err := ex.products.GetProductsAfter(afterID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			break
+		}
+
+		for _, p := range products {
+			row := Row{Fields: map[string]string{
+				ColumnSKU:         p.SKU,
+				ColumnParentSKU:   "",
+				ColumnName:        p.Name,
+				ColumnDescription: p.Description,
+				ColumnPrice:       formatPrice(&p.Price),
+				ColumnCategory:    "",
+				ColumnImage:       p.Image,
+				ColumnTaxClass:    p.TaxClass,
+				ColumnWeight:      formatWeight(p.Weight),
+			}}
+			if p.CategoryID != nil {
+				row.Fields[ColumnCategory] = slugs[*p.CategoryID]
+			}
+			if err := ex.write(rw, row, p.Attributes, codes); err != nil {
+				return err
+			}
+
+			variants, err := ex.variants.GetVariants(p.ID)
+			if err != nil {
+				return err
+			}
+			for _, v := range variants {
+				row := Row{Fields: map[string]string{
+					ColumnSKU:       v.SKU,
+					ColumnParentSKU: p.SKU,
+					ColumnName:      v.Name,
+					ColumnPrice:     formatPrice(v.Price),
+				}}
+				if err := ex.write(rw, row, v.Attributes, codes); err != nil {
+					return err
+				}
+			}
+		}
+
+		afterID = products[len(products)-1].ID
+	}
+
+	return rw.Flush()
+}
+
+// write adds the attributes and stock levels of the SKU of a row to it, and writes it.
+func (ex *Exporter) write(rw RowWriter, row Row, attributes map[string]string, codes map[int]string) error {
+	for name, value := range attributes {
+		row.Fields[attributePrefix+name] = value
+	}
+
+	levels, err := ex.inventory.GetStockLevels(row.Fields[ColumnSKU])
+	if err != nil {
+		return err
+	}
+	for _, l := range levels {
+		if code, ok := codes[l.WarehouseID]; ok {
+			row.Fields[stockPrefix+code] = strconv.Itoa(l.OnHand)
+		}
+	}
+
+	return rw.Write(row)
+}
+
+// formatPrice returns a price as a decimal in the major unit of its currency, or an empty value for nil.
+func formatPrice(price *types.Money) string {
+	if price == nil {
+		return ""
+	}
+	return price.Decimal()
+}
+
+// formatWeight returns a weight in grams as a decimal, or an empty value for unknown weights.
+func formatWeight(weight int) string {
+	if weight == 0 {
+		return ""
+	}
+	return strconv.Itoa(weight)
+}
@@ -0,0 +1,485 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Import the product package to check attributes against the schema of their category.
+	"github.com/FreekAlberti/Ecom/cmd/service/product"
+	// Import the types package for the store interfaces and the import report.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for the shared validator.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxReportedErrors bounds the rejected rows listed in an import report, so a file full of mistakes
+// still gets a readable report. All rejected rows are counted.
+const maxReportedErrors = 1000
+
+// importReference is the reference of the stock movements recorded by imports.
+const importReference = "catalog import"
+
+// Importer struct creates and updates products, variants and stock levels from the rows of an import file,
+// matching them to the catalog by SKU.
+type Importer struct {
+	products   types.ProductStore   // Interface for product data operations.
+	categories types.CategoryStore  // Interface for categories and their attribute schemas.
+	variants   types.VariantStore   // Interface for product variant data operations.
+	inventory  types.InventoryStore // Interface for warehouses and stock levels.
+	allocator  types.StockAllocator // Gives imported stock to waiting orders, nil to leave it unallocated.
+	currency   string               // The base currency, which prices in import files are in.
+}
+
+// NewImporter is a constructor function that returns a new Importer instance.
+func NewImporter(products types.ProductStore, categories types.CategoryStore, variants types.VariantStore, inventory types.InventoryStore, allocator types.StockAllocator, currency string) *Importer {
+	return &Importer{products: products, categories: categories, variants: variants, inventory: inventory, allocator: allocator, currency: currency}
+}
+
+// ImportOptions struct holds the options of an import.
+type ImportOptions struct {
+	DryRun  bool // Check every row and report what would change, without writing anything.
+	ActorID int  // ID of the user running the import, recorded on stock movements; 0 for the system.
+}
+
+// Import reads the rows one at a time and applies every valid row, so a file of any size can be imported.
+// Rows with a parent SKU are variants of that product, which must already exist or come earlier in the file.
+// Invalid rows are skipped and listed in the report; the import is not atomic. Stock columns set the
+// on-hand quantity of a warehouse, recorded as an adjustment of the difference. An error is only returned
+// when the file cannot be read or a write fails, along with the report of the rows before it.
+func (im *Importer) Import(rows RowReader, opts ImportOptions) (*types.ImportReport, error) {
+	run, err := im.newRun(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		row, err := rows.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			run.report.Rows++
+			run.fail(rowErr.Line, "", rowErr.Err)
+			continue
+		}
+		if err != nil {
+			return run.report, err
+		}
+		run.report.Rows++
+
+		c, err := run.check(row)
+		if err != nil {
+			run.fail(row.Line, strings.TrimSpace(row.Fields[ColumnSKU]), err)
+			continue
+		}
+
+		if err := run.apply(c); err != nil {
+			return run.report, fmt.Errorf("line %d: %v", row.Line, err)
+		}
+		if c.create {
+			run.report.Created++
+		} else {
+			run.report.Updated++
+		}
+	}
+
+	return run.report, nil
+}
+
+// importRun struct holds the state of an import.
+type importRun struct {
+	*Importer
+	opts   ImportOptions
+	report *types.ImportReport
+
+	categories map[string]int                       // Category IDs by slug.
+	defs       map[int][]*types.AttributeDefinition // Attribute schemas by category ID.
+	warehouses map[string]int                       // Warehouse IDs by code.
+
+	seen     map[string]bool // SKUs of the rows read so far.
+	imported map[string]int  // IDs of the products imported so far by SKU; 0 for products a dry run would create.
+}
+
+// newRun loads the categories, attribute schemas and warehouses rows refer to.
+func (im *Importer) newRun(opts ImportOptions) (*importRun, error) {
+	run := &importRun{
+		Importer:   im,
+		opts:       opts,
+		report:     &types.ImportReport{DryRun: opts.DryRun, Errors: []types.ImportError{}},
+		categories: map[string]int{},
+		defs:       map[int][]*types.AttributeDefinition{},
+		warehouses: map[string]int{},
+		seen:       map[string]bool{},
+		imported:   map[string]int{},
+	}
+
+	categories, err := im.categories.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		run.categories[c.Slug] = c.ID
+		if run.defs[c.ID], err = im.categories.GetAttributeDefinitions(c.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	warehouses, err := im.inventory.GetWarehouses()
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range warehouses {
+		run.warehouses[w.Code] = w.ID
+	}
+
+	return run, nil
+}
+
+// fail records a rejected row in the report.
+func (run *importRun) fail(line int, sku string, err error) {
+	run.report.Failed++
+	if len(run.report.Errors) == maxReportedErrors {
+		run.report.Truncated = true
+		return
+	}
+	run.report.Errors = append(run.report.Errors, types.ImportError{Line: line, SKU: sku, Message: err.Error()})
+}
+
+// change struct is a checked row, ready to be written.
+type change struct {
+	product *types.Product        // The product to create or update, nil for variants.
+	variant *types.ProductVariant // The variant to create or update, nil for products.
+	create  bool                  // Whether the SKU is new.
+	stock   map[int]int           // On-hand quantity to set by warehouse ID.
+}
+
+// check returns the change a row describes, or what is wrong with it.
+func (run *importRun) check(row Row) (*change, error) {
+	f := row.Fields
+	sku := strings.TrimSpace(f[ColumnSKU])
+	if sku == "" {
+		return nil, fmt.Errorf("missing SKU")
+	}
+	if run.seen[sku] {
+		return nil, fmt.Errorf("SKU %s appears more than once in the file", sku)
+	}
+	run.seen[sku] = true
+
+	stock, err := run.stock(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var c *change
+	if parent := strings.TrimSpace(f[ColumnParentSKU]); parent == "" {
+		c, err = run.checkProduct(sku, f)
+	} else {
+		c, err = run.checkVariant(sku, parent, f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.stock = stock
+	return c, nil
+}
+
+// checkProduct returns the product a row creates or updates. Columns missing from the row keep the values
+// of an existing product.
+func (run *importRun) checkProduct(sku string, f map[string]string) (*change, error) {
+	if _, err := run.variants.GetVariantBySKU(sku); err == nil {
+		return nil, fmt.Errorf("SKU %s belongs to a variant, which needs a parent SKU", sku)
+	}
+
+	// The store does not tell missing products from failed lookups; a failed lookup of an existing product
+	// makes the create fail on the unique SKU instead.
+	p, err := run.products.GetProductBySKU(sku)
+	c := &change{product: p, create: err != nil}
+	if c.create {
+		p = &types.Product{SKU: sku}
+		c.product = p
+	}
+	if p.Attributes == nil {
+		p.Attributes = map[string]string{}
+	}
+
+	if v, ok := f[ColumnName]; ok {
+		p.Name = strings.TrimSpace(v)
+	}
+	if v, ok := f[ColumnDescription]; ok {
+		p.Description = strings.TrimSpace(v)
+	}
+	if v, ok := f[ColumnImage]; ok {
+		p.Image = strings.TrimSpace(v)
+	}
+	if v, ok := f[ColumnTaxClass]; ok {
+		p.TaxClass = strings.TrimSpace(v)
+	}
+	if v, ok := f[ColumnWeight]; ok {
+		if p.Weight, err = parseWeight(v); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := f[ColumnPrice]; ok {
+		price, err := parsePrice(v, run.currency)
+		if err != nil {
+			return nil, err
+		}
+		p.Price = types.Money{Currency: run.currency}
+		if price != nil {
+			p.Price = *price
+		}
+	}
+	if v, ok := f[ColumnCategory]; ok {
+		p.CategoryID = nil
+		if slug := strings.TrimSpace(v); slug != "" {
+			id, ok := run.categories[slug]
+			if !ok {
+				return nil, fmt.Errorf("unknown category %s", slug)
+			}
+			p.CategoryID = &id
+		}
+	}
+	setAttributes(p.Attributes, f)
+
+	payload := types.ProductPayload{
+		SKU:         p.SKU,
+		Name:        p.Name,
+		Description: p.Description,
+		Image:       p.Image,
+		Price:       p.Price,
+		CategoryID:  p.CategoryID,
+		TaxClass:    p.TaxClass,
+		Weight:      p.Weight,
+		Rating:      p.Rating,
+		ReviewCount: p.ReviewCount,
+		Attributes:  p.Attributes,
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, invalidRow(err)
+	}
+	if p.CategoryID != nil && len(run.defs[*p.CategoryID]) > 0 {
+		if p.Attributes, err = product.ValidateAttributes(run.defs[*p.CategoryID], p.Attributes); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// checkVariant returns the variant of the parent product a row creates or updates. Columns missing from the
+// row keep the values of an existing variant.
+func (run *importRun) checkVariant(sku, parent string, f map[string]string) (*change, error) {
+	for _, column := range []string{ColumnDescription, ColumnCategory, ColumnImage, ColumnTaxClass, ColumnWeight} {
+		if strings.TrimSpace(f[column]) != "" {
+			return nil, fmt.Errorf("column %s does not apply to variants", column)
+		}
+	}
+	if _, err := run.products.GetProductBySKU(sku); err == nil {
+		return nil, fmt.Errorf("SKU %s belongs to a product, which cannot have a parent SKU", sku)
+	}
+
+	parentID, ok := run.imported[parent]
+	if !ok {
+		p, err := run.products.GetProductBySKU(parent)
+		if err != nil {
+			return nil, fmt.Errorf("unknown parent product %s; products must come before their variants", parent)
+		}
+		parentID = p.ID
+	}
+
+	v, err := run.variants.GetVariantBySKU(sku)
+	c := &change{variant: v, create: err != nil}
+	if c.create {
+		v = &types.ProductVariant{ProductID: parentID, SKU: sku}
+		c.variant = v
+	} else if v.ProductID != parentID {
+		return nil, fmt.Errorf("variant %s belongs to another product", sku)
+	}
+	if v.Attributes == nil {
+		v.Attributes = map[string]string{}
+	}
+
+	if value, ok := f[ColumnName]; ok {
+		v.Name = strings.TrimSpace(value)
+	}
+	if value, ok := f[ColumnPrice]; ok {
+		if v.Price, err = parsePrice(value, run.currency); err != nil {
+			return nil, err
+		}
+	}
+	setAttributes(v.Attributes, f)
+
+	payload := types.VariantPayload{SKU: v.SKU, Name: v.Name, Price: v.Price, Attributes: v.Attributes}
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, invalidRow(err)
+	}
+
+	return c, nil
+}
+
+// stock returns the on-hand quantities a row sets by warehouse ID. Empty values leave the stock unchanged.
+func (run *importRun) stock(f map[string]string) (map[int]int, error) {
+	stock := map[int]int{}
+	for _, code := range sortedNames(f, stockPrefix) {
+		value := strings.TrimSpace(f[stockPrefix+code])
+		if value == "" {
+			continue
+		}
+
+		id, ok := run.warehouses[code]
+		if !ok {
+			return nil, fmt.Errorf("unknown warehouse %s", code)
+		}
+		quantity, err := strconv.Atoi(value)
+		if err != nil || quantity < 0 {
+			return nil, fmt.Errorf("invalid stock %q for warehouse %s", value, code)
+		}
+		stock[id] = quantity
+	}
+
+	return stock, nil
+}
+
+// apply writes a checked row, unless the import is a dry run.
+func (run *importRun) apply(c *change) error {
+	sku := ""
+	switch {
+	case c.product != nil:
+		sku = c.product.SKU
+		if !run.opts.DryRun {
+			var err error
+			if c.create {
+				c.product.ID, err = run.products.CreateProduct(*c.product)
+			} else {
+				err = run.products.UpdateProduct(*c.product)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		run.imported[sku] = c.product.ID
+	case c.variant != nil:
+		sku = c.variant.SKU
+		if !run.opts.DryRun {
+			var err error
+			if c.create {
+				c.variant.ID, err = run.variants.CreateVariant(*c.variant)
+			} else {
+				err = run.variants.UpdateVariant(*c.variant)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if run.opts.DryRun || len(c.stock) == 0 {
+		return nil
+	}
+	return run.setStock(sku, c.stock)
+}
+
+// setStock records the adjustments that bring the on-hand quantities of a SKU to the given ones,
+// and gives added stock to the orders waiting for it.
+func (run *importRun) setStock(sku string, stock map[int]int) error {
+	levels, err := run.inventory.GetStockLevels(sku)
+	if err != nil {
+		return err
+	}
+	onHand := map[int]int{}
+	for _, l := range levels {
+		onHand[l.WarehouseID] = l.OnHand
+	}
+
+	ids := make([]int, 0, len(stock))
+	for id := range stock {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	added := false
+	for _, id := range ids {
+		diff := stock[id] - onHand[id]
+		if diff == 0 {
+			continue
+		}
+
+		err := run.inventory.RecordMovement(types.StockMovement{
+			WarehouseID: id,
+			SKU:         sku,
+			Type:        types.MovementAdjustment,
+			Quantity:    diff,
+			Reference:   importReference,
+			ActorID:     run.opts.ActorID,
+		})
+		if err != nil {
+			return err
+		}
+		added = added || diff > 0
+	}
+
+	// The stock is already recorded, so a failed allocation is only logged; the next receipt retries it.
+	if added && run.allocator != nil {
+		if _, err := run.allocator.AllocateStock(sku); err != nil {
+			log.Printf("failed to allocate imported stock of %s: %v", sku, err)
+		}
+	}
+
+	return nil
+}
+
+// setAttributes applies the attribute columns of a row to attributes. Empty values remove the attribute.
+func setAttributes(attributes map[string]string, f map[string]string) {
+	for _, name := range sortedNames(f, attributePrefix) {
+		if value := strings.TrimSpace(f[attributePrefix+name]); value != "" {
+			attributes[name] = value
+		} else {
+			delete(attributes, name)
+		}
+	}
+}
+
+// parsePrice parses a price in the base currency, returning nil for an empty value.
+func parsePrice(value, currency string) (*types.Money, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	price, err := types.ParseMoney(value, currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price %q", value)
+	}
+	return &price, nil
+}
+
+// parseWeight parses the weight of a row in grams, which is 0 if the value is empty.
+func parseWeight(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 0 {
+		return 0, fmt.Errorf("invalid weight %q", value)
+	}
+	return weight, nil
+}
+
+// invalidRow returns the error of a row that fails validation, in the words of the API.
+func invalidRow(err error) error {
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		return fmt.Errorf("invalid row %v", errs)
+	}
+	return err
+}
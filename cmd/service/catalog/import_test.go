@@ -0,0 +1,469 @@
+package catalog
+
+import (
+	"bytes"   // Import the bytes package to hold exported files
+	"fmt"     // Import the fmt package for formatted I/O operations
+	"sort"    // Import the sort package to list stored SKUs
+	"strings" // Import the strings package to build import files
+	"testing" // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for catalog types
+)
+
+// TestImport tests importing products, variants and stock levels.
+func TestImport(t *testing.T) {
+	const header = "sku,parentSku,name,description,price,category,image,attr:color,attr:size,stock:AMS1\n"
+
+	t.Run("should create products, variants and stock", func(t *testing.T) {
+		store, inventory, allocator := newStores()
+
+		report := runImport(t, store, inventory, allocator, FormatCSV, header+
+			"SHIRT,,Shirt,Cotton shirt,19.99,clothing,,blue,,10\n"+
+			"SHIRT-M,SHIRT,\"Shirt, M\",,,,,,M,4\n"+
+			"SHIRT-L,SHIRT,\"Shirt, L\",,21.5,,,,L,\n", false)
+
+		if report.Created != 3 || report.Updated != 0 || report.Failed != 0 {
+			t.Fatalf("expected 3 rows created, got %+v", report)
+		}
+
+		p := store.productBySKU("SHIRT")
+		if p == nil || p.Name != "Shirt" || p.Price != types.NewMoney(1999, "EUR") || p.CategoryID == nil || *p.CategoryID != 1 || p.Attributes["color"] != "blue" {
+			t.Fatalf("unexpected product %+v", p)
+		}
+		large := store.variantBySKU("SHIRT-L")
+		if large == nil || large.ProductID != p.ID || large.Price == nil || *large.Price != types.NewMoney(2150, "EUR") || large.Attributes["size"] != "L" {
+			t.Fatalf("unexpected variant %+v", large)
+		}
+		if medium := store.variantBySKU("SHIRT-M"); medium.Price != nil {
+			t.Errorf("expected the medium shirt to sell at the product price, got %v", *medium.Price)
+		}
+
+		if inventory.stock["SHIRT"][1] != 10 || inventory.stock["SHIRT-M"][1] != 4 || len(inventory.stock["SHIRT-L"]) != 0 {
+			t.Errorf("unexpected stock %v", inventory.stock)
+		}
+		if fmt.Sprint(allocator.skus) != "[SHIRT SHIRT-M]" {
+			t.Errorf("expected the added stock to be allocated, got %v", allocator.skus)
+		}
+	})
+
+	t.Run("should update by SKU and keep missing columns", func(t *testing.T) {
+		store, inventory, allocator := newStores()
+		runImport(t, store, inventory, allocator, FormatCSV, header+"SHIRT,,Shirt,Cotton shirt,19.99,clothing,,blue,S,10\n", false)
+
+		report := runImport(t, store, inventory, allocator, FormatCSV, "sku,price,attr:size,stock:AMS1\nSHIRT,24,,7\n", false)
+		if report.Created != 0 || report.Updated != 1 {
+			t.Fatalf("expected 1 row updated, got %+v", report)
+		}
+
+		p := store.productBySKU("SHIRT")
+		if p.Name != "Shirt" || p.Description != "Cotton shirt" || p.Price != types.NewMoney(2400, "EUR") || p.Attributes["color"] != "blue" {
+			t.Errorf("unexpected product %+v", p)
+		}
+		if _, ok := p.Attributes["size"]; ok {
+			t.Errorf("expected the empty size to remove the attribute, got %v", p.Attributes)
+		}
+
+		last := inventory.movements[len(inventory.movements)-1]
+		if inventory.stock["SHIRT"][1] != 7 || last.Quantity != -3 || last.Type != types.MovementAdjustment || last.Reference != importReference {
+			t.Errorf("expected an adjustment of -3, got %+v", last)
+		}
+	})
+
+	t.Run("should report without writing in a dry run", func(t *testing.T) {
+		store, inventory, allocator := newStores()
+
+		report := runImport(t, store, inventory, allocator, FormatCSV, header+
+			"SHIRT,,Shirt,,19.99,,,,,10\n"+
+			"SHIRT-M,SHIRT,Medium,,,,,,M,4\n"+
+			"HAT,,Hat,,-1,,,,,\n", true)
+
+		if !report.DryRun || report.Rows != 3 || report.Created != 2 || report.Failed != 1 {
+			t.Fatalf("expected 2 rows to create and 1 to fail, got %+v", report)
+		}
+		if len(store.products) != 0 || len(store.variants) != 0 || len(inventory.movements) != 0 {
+			t.Errorf("expected nothing to be written, got %d products, %d variants and %d movements",
+				len(store.products), len(store.variants), len(inventory.movements))
+		}
+	})
+
+	t.Run("should reject invalid rows and apply the others", func(t *testing.T) {
+		store, inventory, allocator := newStores()
+		store.defs[1] = []*types.AttributeDefinition{{CategoryID: 1, Name: "size", Type: types.AttributeEnum, Options: []string{"S", "M"}}}
+
+		report := runImport(t, store, inventory, allocator, FormatCSV, header+
+			"ORPHAN-M,ORPHAN,Medium,,,,,,,\n"+ // Line 2: unknown parent.
+			"SHIRT,,Shirt,,abc,,,,,\n"+ // Line 3: invalid price.
+			"HAT,,Hat,,10,hats,,,,\n"+ // Line 4: unknown category.
+			"SOCK,,Sock,,5,clothing,,,XL,\n"+ // Line 5: not an option of the size attribute.
+			"BAG,,Bag,,30,,,,,-2\n"+ // Line 6: negative stock.
+			"SCARF,,,,12,,,,,\n"+ // Line 7: missing name.
+			"CAP,,Cap,,8,,,,,\n"+
+			"CAP,,Cap,,9,,,,,\n", false) // Line 9: duplicate SKU.
+
+		if report.Rows != 8 || report.Created != 1 || report.Failed != 7 {
+			t.Fatalf("expected 1 row created and 7 failed, got %+v", report)
+		}
+
+		lines := []int{}
+		for _, e := range report.Errors {
+			lines = append(lines, e.Line)
+		}
+		if fmt.Sprint(lines) != "[2 3 4 5 6 7 9]" {
+			t.Errorf("expected errors on lines 2 to 7 and 9, got %v", report.Errors)
+		}
+		if p := store.productBySKU("CAP"); p == nil || p.Price != types.NewMoney(800, "EUR") {
+			t.Errorf("expected the first cap to be imported, got %+v", p)
+		}
+	})
+
+	t.Run("should reject SKUs of the other kind", func(t *testing.T) {
+		store, inventory, allocator := newStores()
+		runImport(t, store, inventory, allocator, FormatCSV, header+"SHIRT,,Shirt,,19.99,,,,,\nSHIRT-M,SHIRT,,,,,,,M,\n", false)
+
+		report := runImport(t, store, inventory, allocator, FormatCSV, header+"SHIRT,HAT,,,,,,,,\nSHIRT-M,,,,,,,,,\n", false)
+		if report.Failed != 2 {
+			t.Errorf("expected 2 rows to fail, got %+v", report)
+		}
+	})
+
+	t.Run("should reject unknown columns", func(t *testing.T) {
+		if _, err := NewReader(FormatCSV, strings.NewReader("sku,colour\nSHIRT,blue\n")); err == nil {
+			t.Error("expected an unknown column to be rejected")
+		}
+		if _, err := NewReader(FormatCSV, strings.NewReader("name,price\nShirt,10\n")); err == nil {
+			t.Error("expected a file without SKUs to be rejected")
+		}
+	})
+
+	t.Run("should import JSON Lines", func(t *testing.T) {
+		store, inventory, allocator := newStores()
+
+		report := runImport(t, store, inventory, allocator, FormatJSONL,
+			`{"sku":"SHIRT","name":"Shirt","price":19.99,"attributes":{"color":"blue"},"stock":{"AMS1":3}}`+"\n"+
+				"\n"+
+				`{"sku":"SHIRT-M","parentSku":"SHIRT","price":null,"attributes":{"size":"M"}}`+"\n"+
+				`{"sku":"HAT",`+"\n"+
+				`{"sku":"CAP","weight":3}`+"\n", false)
+
+		if report.Created != 2 || report.Failed != 2 || report.Errors[0].Line != 4 || report.Errors[1].Line != 5 {
+			t.Fatalf("expected 2 rows created and lines 4 and 5 to fail, got %+v", report)
+		}
+		if p := store.productBySKU("SHIRT"); p.Price != types.NewMoney(1999, "EUR") || p.Attributes["color"] != "blue" || inventory.stock["SHIRT"][1] != 3 {
+			t.Errorf("unexpected product %+v", p)
+		}
+	})
+
+	t.Run("should list the first errors only", func(t *testing.T) {
+		store, inventory, allocator := newStores()
+
+		var file strings.Builder
+		file.WriteString("sku,price\n")
+		for i := 0; i < maxReportedErrors+5; i++ {
+			fmt.Fprintf(&file, "SKU-%d,abc\n", i)
+		}
+
+		report := runImport(t, store, inventory, allocator, FormatCSV, file.String(), true)
+		if report.Failed != maxReportedErrors+5 || len(report.Errors) != maxReportedErrors || !report.Truncated {
+			t.Errorf("expected %d errors listed of %d, got %d of %d", maxReportedErrors, maxReportedErrors+5, len(report.Errors), report.Failed)
+		}
+	})
+}
+
+// TestExport tests exporting the catalog.
+func TestExport(t *testing.T) {
+	const file = "sku,parentSku,name,description,price,category,image,taxClass,weight,attr:color,attr:size,stock:AMS1,stock:RTM1\n" +
+		"SHIRT,,Shirt,\"Cotton, blue\",19.99,clothing,https://example.com/shirt.jpg,standard,200,blue,,10,\n" +
+		"SHIRT-M,SHIRT,Medium,,,,,,,,M,4,2\n" +
+		"SHIRT-L,SHIRT,Large,,21.50,,,,,,L,,\n" +
+		"HAT,,Hat,,5.00,,,reduced,,,,,\n"
+
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		t.Run("should export what it imports as "+format, func(t *testing.T) {
+			store, inventory, allocator := newStores()
+			if report := runImport(t, store, inventory, allocator, FormatCSV, file, false); report.Failed != 0 {
+				t.Fatalf("expected the file to import, got %+v", report.Errors)
+			}
+
+			exported := export(t, store, inventory, format)
+
+			// Import the export into an empty catalog, and export that again.
+			copyStore, copyInventory, copyAllocator := newStores()
+			if report := runImport(t, copyStore, copyInventory, copyAllocator, format, exported, false); report.Failed != 0 || report.Created != 4 {
+				t.Fatalf("expected the export to import, got %+v", report)
+			}
+			if again := export(t, copyStore, copyInventory, format); again != exported {
+				t.Errorf("expected the same export, got\n%s\nand\n%s", exported, again)
+			}
+
+			if format == FormatCSV && exported != file {
+				t.Errorf("expected the export to be the imported file, got\n%s", exported)
+			}
+		})
+	}
+
+	t.Run("should write variants as JSON Lines", func(t *testing.T) {
+		store, inventory, allocator := newStores()
+		runImport(t, store, inventory, allocator, FormatCSV, file, false)
+
+		lines := strings.Split(export(t, store, inventory, FormatJSONL), "\n")
+		expected := `{"sku":"SHIRT-M","parentSku":"SHIRT","name":"Medium","price":null,"attributes":{"size":"M"},"stock":{"AMS1":4,"RTM1":2}}`
+		if lines[1] != expected {
+			t.Errorf("expected %s, got %s", expected, lines[1])
+		}
+	})
+}
+
+// runImport imports a file into the stores.
+func runImport(t *testing.T, store *mockCatalogStore, inventory *mockInventoryStore, allocator *mockAllocator, format, file string, dryRun bool) *types.ImportReport {
+	t.Helper()
+
+	rows, err := NewReader(format, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := NewImporter(store, store, store, inventory, allocator, "EUR").Import(rows, ImportOptions{DryRun: dryRun, ActorID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// export returns the catalog in the stores as a file.
+func export(t *testing.T, store *mockCatalogStore, inventory *mockInventoryStore, format string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := NewExporter(store, store, store, inventory).Export(format, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// newStores returns empty stores with the clothing category (ID 1) and the warehouses AMS1 (ID 1) and RTM1 (ID 2).
+func newStores() (*mockCatalogStore, *mockInventoryStore, *mockAllocator) {
+	store := &mockCatalogStore{
+		products:   map[int]*types.Product{},
+		variants:   map[int]*types.ProductVariant{},
+		categories: []*types.Category{{ID: 1, Slug: "clothing", Name: "Clothing"}},
+		defs:       map[int][]*types.AttributeDefinition{},
+	}
+	inventory := &mockInventoryStore{
+		warehouses: []*types.Warehouse{{ID: 1, Code: "AMS1"}, {ID: 2, Code: "RTM1"}},
+		stock:      map[string]map[int]int{},
+	}
+	return store, inventory, &mockAllocator{}
+}
+
+// mockCatalogStore is an in-memory implementation of the parts of the ProductStore, CategoryStore and
+// VariantStore interfaces used by imports and exports.
+type mockCatalogStore struct {
+	types.ProductStore
+	types.CategoryStore
+	products   map[int]*types.Product               // Products by ID.
+	variants   map[int]*types.ProductVariant        // Variants by ID.
+	categories []*types.Category                    // All categories.
+	defs       map[int][]*types.AttributeDefinition // Attribute schemas by category ID.
+	nextID     int                                  // ID of the last created product or variant.
+}
+
+// productBySKU returns the product with the given SKU, or nil.
+func (m *mockCatalogStore) productBySKU(sku string) *types.Product {
+	for _, p := range m.products {
+		if p.SKU == sku {
+			return p
+		}
+	}
+	return nil
+}
+
+// variantBySKU returns the variant with the given SKU, or nil.
+func (m *mockCatalogStore) variantBySKU(sku string) *types.ProductVariant {
+	for _, v := range m.variants {
+		if v.SKU == sku {
+			return v
+		}
+	}
+	return nil
+}
+
+// GetProductBySKU is a mock method that returns a copy of the product with the given SKU.
+func (m *mockCatalogStore) GetProductBySKU(sku string) (*types.Product, error) {
+	p := m.productBySKU(sku)
+	if p == nil {
+		return nil, types.ErrProductNotFound
+	}
+
+	c := *p
+	c.Attributes = copyAttributes(p.Attributes)
+	return &c, nil
+}
+
+// GetProductsAfter is a mock method that returns up to limit products with an ID above afterID, ordered by ID.
+func (m *mockCatalogStore) GetProductsAfter(afterID, limit int) ([]*types.Product, error) {
+	products := []*types.Product{}
+	for _, p := range m.products {
+		if p.ID > afterID {
+			products = append(products, p)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	if len(products) > limit {
+		products = products[:limit]
+	}
+	return products, nil
+}
+
+// GetAttributeNames is a mock method that returns the names of the attributes of products and variants, sorted.
+func (m *mockCatalogStore) GetAttributeNames() ([]string, error) {
+	seen := map[string]bool{}
+	for _, p := range m.products {
+		for name := range p.Attributes {
+			seen[name] = true
+		}
+	}
+	for _, v := range m.variants {
+		for name := range v.Attributes {
+			seen[name] = true
+		}
+	}
+
+	names := []string{}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CreateProduct is a mock method that stores a new product.
+func (m *mockCatalogStore) CreateProduct(p types.Product) (int, error) {
+	m.nextID++
+	p.ID = m.nextID
+	if p.TaxClass == "" {
+		p.TaxClass = types.TaxClassStandard
+	}
+	m.products[p.ID] = &p
+	return p.ID, nil
+}
+
+// UpdateProduct is a mock method that replaces a stored product.
+func (m *mockCatalogStore) UpdateProduct(p types.Product) error {
+	if _, ok := m.products[p.ID]; !ok {
+		return fmt.Errorf("product not found")
+	}
+	if p.TaxClass == "" {
+		p.TaxClass = types.TaxClassStandard
+	}
+	m.products[p.ID] = &p
+	return nil
+}
+
+// GetCategories is a mock method that returns all categories.
+func (m *mockCatalogStore) GetCategories() ([]*types.Category, error) {
+	return m.categories, nil
+}
+
+// GetAttributeDefinitions is a mock method that returns the attribute schema of a category.
+func (m *mockCatalogStore) GetAttributeDefinitions(categoryID int) ([]*types.AttributeDefinition, error) {
+	return m.defs[categoryID], nil
+}
+
+// GetVariants is a mock method that returns the variants of a product, ordered by ID.
+func (m *mockCatalogStore) GetVariants(productID int) ([]*types.ProductVariant, error) {
+	variants := []*types.ProductVariant{}
+	for _, v := range m.variants {
+		if v.ProductID == productID {
+			variants = append(variants, v)
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].ID < variants[j].ID })
+	return variants, nil
+}
+
+// GetVariantBySKU is a mock method that returns a copy of the variant with the given SKU.
+func (m *mockCatalogStore) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	v := m.variantBySKU(sku)
+	if v == nil {
+		return nil, types.ErrVariantNotFound
+	}
+
+	c := *v
+	c.Attributes = copyAttributes(v.Attributes)
+	return &c, nil
+}
+
+// CreateVariant is a mock method that stores a new variant.
+func (m *mockCatalogStore) CreateVariant(v types.ProductVariant) (int, error) {
+	m.nextID++
+	v.ID = m.nextID
+	m.variants[v.ID] = &v
+	return v.ID, nil
+}
+
+// UpdateVariant is a mock method that replaces a stored variant.
+func (m *mockCatalogStore) UpdateVariant(v types.ProductVariant) error {
+	if _, ok := m.variants[v.ID]; !ok {
+		return fmt.Errorf("variant not found")
+	}
+	m.variants[v.ID] = &v
+	return nil
+}
+
+// copyAttributes returns a copy of attributes, so callers cannot change stored ones.
+func copyAttributes(attributes map[string]string) map[string]string {
+	c := map[string]string{}
+	for name, value := range attributes {
+		c[name] = value
+	}
+	return c
+}
+
+// mockInventoryStore is an in-memory implementation of the parts of the InventoryStore interface used by
+// imports and exports.
+type mockInventoryStore struct {
+	types.InventoryStore
+	warehouses []*types.Warehouse     // All warehouses.
+	stock      map[string]map[int]int // On-hand quantities by SKU and warehouse ID.
+	movements  []types.StockMovement  // Recorded movements, oldest first.
+}
+
+// GetWarehouses is a mock method that returns all warehouses.
+func (m *mockInventoryStore) GetWarehouses() ([]*types.Warehouse, error) {
+	return m.warehouses, nil
+}
+
+// GetStockLevels is a mock method that returns the stock levels of a SKU, ordered by warehouse ID.
+func (m *mockInventoryStore) GetStockLevels(sku string) ([]*types.StockLevel, error) {
+	levels := []*types.StockLevel{}
+	for id, onHand := range m.stock[sku] {
+		levels = append(levels, &types.StockLevel{WarehouseID: id, SKU: sku, OnHand: onHand})
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].WarehouseID < levels[j].WarehouseID })
+	return levels, nil
+}
+
+// RecordMovement is a mock method that records a movement and applies it to the stock level of its warehouse.
+func (m *mockInventoryStore) RecordMovement(mv types.StockMovement) error {
+	if m.stock[mv.SKU] == nil {
+		m.stock[mv.SKU] = map[int]int{}
+	}
+	if m.stock[mv.SKU][mv.WarehouseID]+mv.Quantity < 0 {
+		return types.ErrInsufficientStock
+	}
+
+	m.stock[mv.SKU][mv.WarehouseID] += mv.Quantity
+	m.movements = append(m.movements, mv)
+	return nil
+}
+
+// mockAllocator is an implementation of the StockAllocator interface that records the SKUs it is called for.
+type mockAllocator struct {
+	skus []string // SKUs whose stock was allocated, in order.
+}
+
+// AllocateStock is a mock method that records the SKU.
+func (m *mockAllocator) AllocateStock(sku string) (int, error) {
+	m.skus = append(m.skus, sku)
+	return 0, nil
+}
@@ -0,0 +1,104 @@
+package catalog
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	// Import the auth package for the admin-only middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// contentTypes maps the formats of import and export files to their MIME type.
+var contentTypes = map[string]string{
+	FormatCSV:   "text/csv; charset=utf-8",
+	FormatJSONL: "application/x-ndjson",
+}
+
+// Handler struct groups the methods that handle bulk catalog requests.
+type Handler struct {
+	importer  *Importer       // Applies import files to the catalog.
+	exporter  *Exporter       // Writes the catalog to export files.
+	userStore types.UserStore // Interface for user-related data operations, used to authenticate admins.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(importer *Importer, exporter *Exporter, userStore types.UserStore) *Handler {
+	return &Handler{importer: importer, exporter: exporter, userStore: userStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the bulk catalog routes, all admin-only.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/catalog/import", auth.WithAdminAuth(h.handleImport, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/catalog/export", auth.WithAdminAuth(h.handleExport, h.userStore)).Methods(http.MethodGet)
+}
+
+// handleImport handles POST /admin/catalog/import?format=csv|jsonl&dryRun=true.
+// The body is the file itself, read as it arrives. The response is the import report, also when rows were rejected.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	format, ok := parseFormat(w, r)
+	if !ok {
+		return
+	}
+
+	opts := ImportOptions{ActorID: auth.GetUserIDFromContext(r.Context())}
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if opts.DryRun, err = strconv.ParseBool(v); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid dryRun %q", v))
+			return
+		}
+	}
+
+	rows, err := NewReader(format, r.Body)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.importer.Import(rows, opts)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// handleExport handles GET /admin/catalog/export?format=csv|jsonl.
+// The file is streamed as it is written, so errors after the first rows can only be logged.
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	format, ok := parseFormat(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="catalog.%s"`, format))
+	if err := h.exporter.Export(format, w); err != nil {
+		log.Printf("failed to export the catalog: %v", err)
+	}
+}
+
+// parseFormat returns the format in the query of a request, CSV if it has none.
+// It writes a 400 response and returns false for unknown formats.
+func parseFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		return FormatCSV, true
+	}
+	if _, ok := contentTypes[format]; !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q, expected %s or %s", format, FormatCSV, FormatJSONL))
+		return "", false
+	}
+
+	return format, true
+}
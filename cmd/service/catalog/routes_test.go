@@ -0,0 +1,146 @@
+package catalog
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for catalog types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestCatalogHandlers tests the bulk catalog HTTP handlers.
+func TestCatalogHandlers(t *testing.T) {
+	// Create empty stores, a user store with one admin (ID 1) and one customer (ID 2), and register the routes
+	// of a handler using them. The tests share the stores, so the dry run comes before the import.
+	store, inventory, allocator := newStores()
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin},
+		2: {ID: 2, Email: "customer@example.com", Role: types.RoleCustomer},
+	}}
+
+	router := mux.NewRouter()
+	NewHandler(
+		NewImporter(store, store, store, inventory, allocator, "EUR"),
+		NewExporter(store, store, store, inventory),
+		userStore,
+	).RegisterRoutes(router)
+
+	const file = "sku,name,price,stock:AMS1\nSHIRT,Shirt,19.99,5\nHAT,Hat,,\n"
+
+	t.Run("should not write in a dry run", func(t *testing.T) {
+		rr := send(t, router, http.MethodPost, "/admin/catalog/import?dryRun=true", file, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var report types.ImportReport
+		json.NewDecoder(rr.Body).Decode(&report)
+		if !report.DryRun || report.Created != 1 || len(store.products) != 0 {
+			t.Errorf("expected a report without writes, got %+v and %d products", report, len(store.products))
+		}
+	})
+
+	t.Run("should import a file and report the rejected rows", func(t *testing.T) {
+		rr := send(t, router, http.MethodPost, "/admin/catalog/import?format=csv", file, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var report types.ImportReport
+		json.NewDecoder(rr.Body).Decode(&report)
+		if report.Created != 1 || report.Failed != 1 || report.Errors[0].Line != 3 || report.Errors[0].SKU != "HAT" {
+			t.Errorf("expected the hat to be rejected, got %+v", report)
+		}
+		if store.productBySKU("SHIRT") == nil || inventory.movements[0].ActorID != 1 {
+			t.Errorf("expected the shirt to be imported by the admin, got %v", inventory.movements)
+		}
+	})
+
+	t.Run("should reject invalid files and options", func(t *testing.T) {
+		for _, target := range []string{"/admin/catalog/import?format=xml", "/admin/catalog/import?dryRun=maybe"} {
+			if rr := send(t, router, http.MethodPost, target, file, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", target, http.StatusBadRequest, rr.Code)
+			}
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/catalog/import", "sku,colour\n", 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an unknown column, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should export the catalog as a download", func(t *testing.T) {
+		// The catalog holds the shirt imported above.
+		rr := send(t, router, http.MethodGet, "/admin/catalog/export?format=jsonl", "", 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("expected JSON Lines, got %s", ct)
+		}
+		if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="catalog.jsonl"` {
+			t.Errorf("unexpected Content-Disposition %s", cd)
+		}
+
+		expected := `{"sku":"SHIRT","name":"Shirt","description":"","price":19.99,"category":"","image":"","taxClass":"standard","weight":null,"stock":{"AMS1":5}}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("expected %s, got %s", expected, rr.Body)
+		}
+	})
+
+	t.Run("should only allow admins", func(t *testing.T) {
+		for _, userID := range []int{0, 2} {
+			if rr := send(t, router, http.MethodGet, "/admin/catalog/export", "", userID); rr.Code == http.StatusOK {
+				t.Errorf("user %d: expected the export to be refused", userID)
+			}
+			if rr := send(t, router, http.MethodPost, "/admin/catalog/import", file, userID); rr.Code == http.StatusOK {
+				t.Errorf("user %d: expected the import to be refused", userID)
+			}
+		}
+	})
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID unless it is 0,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userID != 0 {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the auth middleware.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
@@ -0,0 +1,379 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats of import and export files.
+const (
+	FormatCSV   = "csv"   // Comma-separated values with a header row, as spreadsheets save them.
+	FormatJSONL = "jsonl" // JSON Lines: one JSON object per line.
+)
+
+// Columns of import and export files. Attributes and stock levels take one column each, named with a prefix:
+// "attr:color" holds the color attribute and "stock:AMS1" the on-hand quantity in the warehouse with code AMS1.
+// In JSON Lines they are the "attributes" and "stock" objects instead.
+const (
+	ColumnSKU         = "sku"         // SKU of the product or variant. Rows are matched to the catalog by SKU.
+	ColumnParentSKU   = "parentSku"   // SKU of the product a variant belongs to, empty for products.
+	ColumnName        = "name"        // Name of the product or variant.
+	ColumnDescription = "description" // Description of the product.
+	ColumnPrice       = "price"       // Price in the base currency of the product, or of the variant; empty for variants selling at the product price.
+	ColumnCategory    = "category"    // Slug of the category of the product, empty for none.
+	ColumnImage       = "image"       // URL of the image of the product.
+	ColumnTaxClass    = "taxClass"    // Tax class of the product, empty for the standard class.
+	ColumnWeight      = "weight"      // Shipping weight of the product in grams, empty for unknown.
+
+	attributePrefix = "attr:"
+	stockPrefix     = "stock:"
+)
+
+// fixedColumns are the columns that are not attributes or stock levels, in the order they are exported.
+var fixedColumns = []string{ColumnSKU, ColumnParentSKU, ColumnName, ColumnDescription, ColumnPrice, ColumnCategory, ColumnImage, ColumnTaxClass, ColumnWeight}
+
+// Row struct is a row of an import or export file: a product, or a variant of the product with the parent SKU.
+// Fields hold the values by column. On import, columns missing from a row leave what they describe unchanged,
+// while empty values clear it.
+type Row struct {
+	Line   int               // Line of the row in the file, for error reports.
+	Fields map[string]string // Values by column name.
+}
+
+// RowReader is an interface for reading the rows of an import file one at a time, so files of any size can be
+// imported without holding them in memory. Read returns io.EOF after the last row.
+type RowReader interface {
+	Read() (Row, error)
+}
+
+// RowWriter is an interface for writing the rows of an export file one at a time.
+type RowWriter interface {
+	// Write writes a row.
+	Write(Row) error
+
+	// Flush writes any buffered rows.
+	Flush() error
+}
+
+// RowError struct is returned by a RowReader for a row it could not parse. Reading can go on with the next row.
+type RowError struct {
+	Line int   // Line of the row in the file.
+	Err  error // What is wrong with it.
+}
+
+// Error is a method on the RowError struct that returns the error message.
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// NewReader returns a reader for an import file in the format with the given name.
+// For CSV it reads and checks the header row first.
+func NewReader(format string, r io.Reader) (RowReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		return &jsonlReader{r: bufio.NewReader(r)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q, expected %s or %s", format, FormatCSV, FormatJSONL)
+}
+
+// NewWriter returns a writer for an export file in the format with the given name. CSV files get a header row
+// with the columns, which must be all columns the rows have values for.
+func NewWriter(format string, w io.Writer, columns []string) (RowWriter, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw, columns: columns}, nil
+	case FormatJSONL:
+		return &jsonlWriter{w: bufio.NewWriter(w)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q, expected %s or %s", format, FormatCSV, FormatJSONL)
+}
+
+// checkColumn returns an error if name is not a column of import files.
+func checkColumn(name string) error {
+	for _, c := range fixedColumns {
+		if name == c {
+			return nil
+		}
+	}
+	for _, prefix := range []string{attributePrefix, stockPrefix} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown column %q", name)
+}
+
+// csvReader reads rows from a CSV file with a header row.
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+// newCSVReader reads the header row and returns a reader for the rows after it.
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+
+	// Spreadsheets sometimes save a byte order mark before the first column.
+	header = append([]string(nil), header...)
+	header[0] = strings.TrimPrefix(header[0], "\uFEFF")
+
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if err := checkColumn(name); err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %q appears more than once", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	if !seen[ColumnSKU] {
+		return nil, fmt.Errorf("missing column %q", ColumnSKU)
+	}
+
+	return &csvReader{r: cr, header: header}, nil
+}
+
+// Read is a method on the csvReader struct that returns the next row.
+func (c *csvReader) Read() (Row, error) {
+	record, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Row{}, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	row := Row{Line: line, Fields: make(map[string]string, len(record))}
+	for i, value := range record {
+		row.Fields[c.header[i]] = value
+	}
+	return row, nil
+}
+
+// jsonlReader reads rows from a JSON Lines file, skipping blank lines.
+type jsonlReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// Read is a method on the jsonlReader struct that returns the next row.
+func (j *jsonlReader) Read() (Row, error) {
+	for {
+		data, err := j.r.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return Row{}, err
+		}
+		if err != nil && err != io.EOF {
+			return Row{}, err
+		}
+		j.line++
+
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		fields, err := parseJSONRow(data)
+		if err != nil {
+			return Row{}, &RowError{Line: j.line, Err: err}
+		}
+		return Row{Line: j.line, Fields: fields}, nil
+	}
+}
+
+// parseJSONRow converts a JSON object to the fields of a row. Numbers and booleans become their text,
+// null becomes an empty value, and the attributes and stock objects become prefixed columns.
+func parseJSONRow(data []byte) (map[string]string, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var object map[string]any
+	if err := d.Decode(&object); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	fields := map[string]string{}
+	for key, value := range object {
+		switch key {
+		case "attributes", "stock":
+			nested, ok := value.(map[string]any)
+			if !ok && value != nil {
+				return nil, fmt.Errorf("%s must be an object", key)
+			}
+			prefix := attributePrefix
+			if key == "stock" {
+				prefix = stockPrefix
+			}
+			for name, v := range nested {
+				text, err := jsonText(v)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s %v", key, name, err)
+				}
+				fields[prefix+name] = text
+			}
+		default:
+			if err := checkColumn(key); err != nil || strings.Contains(key, ":") {
+				return nil, fmt.Errorf("unknown field %q", key)
+			}
+			text, err := jsonText(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s %v", key, err)
+			}
+			fields[key] = text
+		}
+	}
+
+	return fields, nil
+}
+
+// jsonText returns the text of a JSON scalar.
+func jsonText(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+
+	return "", fmt.Errorf("must be a string, number or boolean")
+}
+
+// csvWriter writes rows to a CSV file, in the columns of its header.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	record  []string
+}
+
+// Write is a method on the csvWriter struct that writes a row.
+func (c *csvWriter) Write(row Row) error {
+	c.record = c.record[:0]
+	for _, column := range c.columns {
+		c.record = append(c.record, row.Fields[column])
+	}
+	return c.w.Write(c.record)
+}
+
+// Flush is a method on the csvWriter struct that writes any buffered rows.
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlWriter writes rows to a JSON Lines file. Prices and stock levels are written as numbers, and only the
+// fields a row has are written.
+type jsonlWriter struct {
+	w *bufio.Writer
+}
+
+// Write is a method on the jsonlWriter struct that writes a row.
+func (j *jsonlWriter) Write(row Row) error {
+	// Fixed fields come first and in order, so the lines are easy to read.
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for _, column := range fixedColumns {
+		value, ok := row.Fields[column]
+		if !ok || column == ColumnParentSKU && value == "" {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		writeJSONString(&b, column)
+		b.WriteByte(':')
+		if (column == ColumnPrice || column == ColumnWeight) && value != "" {
+			b.WriteString(value)
+		} else if column == ColumnPrice || column == ColumnWeight {
+			b.WriteString("null")
+		} else {
+			writeJSONString(&b, value)
+		}
+	}
+
+	attributes, stock := map[string]string{}, map[string]json.Number{}
+	for column, value := range row.Fields {
+		if name, ok := strings.CutPrefix(column, attributePrefix); ok && value != "" {
+			attributes[name] = value
+		}
+		if code, ok := strings.CutPrefix(column, stockPrefix); ok && value != "" {
+			stock[code] = json.Number(value)
+		}
+	}
+	for _, nested := range []struct {
+		key   string
+		value any
+		empty bool
+	}{{"attributes", attributes, len(attributes) == 0}, {"stock", stock, len(stock) == 0}} {
+		if nested.empty {
+			continue
+		}
+		// encoding/json sorts the keys of maps.
+		data, err := json.Marshal(nested.value)
+		if err != nil {
+			return err
+		}
+		b.WriteString(`,"` + nested.key + `":`)
+		b.Write(data)
+	}
+	b.WriteString("}\n")
+
+	_, err := j.w.Write(b.Bytes())
+	return err
+}
+
+// Flush is a method on the jsonlWriter struct that writes any buffered rows.
+func (j *jsonlWriter) Flush() error {
+	return j.w.Flush()
+}
+
+// writeJSONString writes s as a JSON string.
+func writeJSONString(b *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	b.Write(data)
+}
+
+// sortedNames returns the names of the columns with the prefix, without it, sorted.
+func sortedNames(fields map[string]string, prefix string) []string {
+	names := []string{}
+	for column := range fields {
+		if name, ok := strings.CutPrefix(column, prefix); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
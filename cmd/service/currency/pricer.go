@@ -0,0 +1,136 @@
+package currency
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	// Import the auth package to find the user a request is made by.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and the Money type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Pricer struct shows product prices in the currency a customer selected, from the price lists of the products
+// or else by converting their base price at the current exchange rate.
+type Pricer struct {
+	rates  types.ExchangeRates  // The exchange rates of the base currency.
+	prices types.PriceListStore // The prices set for products in other currencies.
+}
+
+// NewPricer is a constructor function that returns a new Pricer instance.
+func NewPricer(rates types.ExchangeRates, prices types.PriceListStore) *Pricer {
+	return &Pricer{rates: rates, prices: prices}
+}
+
+// Base is a method on the Pricer struct that returns the base currency.
+func (p *Pricer) Base() string {
+	return p.rates.Base()
+}
+
+// Currencies is a method on the Pricer struct that returns the currencies prices can be shown in: the base
+// currency, followed by the currencies with an exchange rate in alphabetical order.
+func (p *Pricer) Currencies() []string {
+	table := p.rates.Rates()
+
+	currencies := make([]string, 0, len(table.Rates))
+	for currency := range table.Rates {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	return append([]string{p.rates.Base()}, currencies...)
+}
+
+// supports returns whether prices can be shown in a currency.
+func (p *Pricer) supports(currency string) bool {
+	if currency == p.rates.Base() {
+		return true
+	}
+
+	_, ok := p.rates.Rates().Rates[currency]
+	return ok
+}
+
+// SelectCurrency is a method on the Pricer struct that returns the currency to show prices in: the requested one,
+// else the preferred currency of the user while it is supported, else the base currency.
+func (p *Pricer) SelectCurrency(requested string, user *types.User) (string, error) {
+	if requested = strings.ToUpper(strings.TrimSpace(requested)); requested != "" {
+		if !p.supports(requested) {
+			return "", fmt.Errorf("%w %q", types.ErrUnsupportedCurrency, requested)
+		}
+		return requested, nil
+	}
+
+	if user != nil && user.Currency != "" && p.supports(user.Currency) {
+		return user.Currency, nil
+	}
+
+	return p.rates.Base(), nil
+}
+
+// PriceProducts is a method on the Pricer struct that sets the prices of the products in a currency: the price
+// set for the product in that currency, or else its converted base price.
+func (p *Pricer) PriceProducts(products []*types.Product, currency string) error {
+	if currency == p.rates.Base() || len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	prices, err := p.prices.GetPrices(ids, currency)
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		if price, ok := prices[product.ID]; ok {
+			product.Price = price
+			continue
+		}
+		if product.Price, err = p.rates.Convert(product.Price, currency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PriceVariants is a method on the Pricer struct that converts the prices of variants to a currency. Variants
+// without a price of their own sell at the price of their product.
+func (p *Pricer) PriceVariants(variants []*types.ProductVariant, currency string) error {
+	for _, variant := range variants {
+		if variant.Price == nil {
+			continue
+		}
+
+		price, err := p.rates.Convert(*variant.Price, currency)
+		if err != nil {
+			return err
+		}
+		variant.Price = &price
+	}
+
+	return nil
+}
+
+// Convert is a method on the Pricer struct that converts an amount to another currency at the current rate.
+func (p *Pricer) Convert(m types.Money, currency string) (types.Money, error) {
+	return p.rates.Convert(m, currency)
+}
+
+// FromRequest returns the currency to show prices in for a request: the one in the X-Currency header, else the
+// preferred currency of the user making it, if any, else the base currency. The request does not have to be
+// authenticated; invalid credentials only mean no preference is used.
+func FromRequest(r *http.Request, pricer types.Pricer, userStore types.UserStore) (string, error) {
+	requested := r.Header.Get(types.CurrencyHeader)
+	if requested != "" {
+		return pricer.SelectCurrency(requested, nil)
+	}
+
+	return pricer.SelectCurrency("", auth.UserFromRequest(r, userStore))
+}
@@ -0,0 +1,193 @@
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	// Import the types package for the Money type and the rate table.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// ratePattern matches exchange rates, which are positive decimals such as "1.0842".
+var ratePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// RatesFile struct holds the exchange rates of the base currency, kept in a local JSON file so they survive
+// restarts without depending on a rates provider being reachable. Updates replace the file atomically.
+type RatesFile struct {
+	path string // Path of the JSON file.
+	base string // The base currency.
+
+	mu    sync.RWMutex
+	table types.RateTable     // The rates as loaded or last updated.
+	rates map[string]*big.Rat // The parsed rates by currency.
+}
+
+// NewRatesFile is a constructor function that loads the exchange rates of the base currency from the file at path.
+// A missing file is not an error: prices are then shown in the base currency only, until rates are set.
+func NewRatesFile(path, base string) (*RatesFile, error) {
+	if _, ok := types.MinorDigits(base); !ok {
+		return nil, fmt.Errorf("%w %q as base currency", types.ErrUnknownCurrency, base)
+	}
+
+	f := &RatesFile{
+		path:  path,
+		base:  base,
+		table: types.RateTable{Base: base, Rates: map[string]string{}},
+		rates: map[string]*big.Rat{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var table types.RateTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("invalid exchange rates file %s: %v", path, err)
+	}
+	if f.rates, err = parseRates(table, base); err != nil {
+		return nil, fmt.Errorf("invalid exchange rates file %s: %v", path, err)
+	}
+	f.table = table
+
+	return f, nil
+}
+
+// Base is a method on the RatesFile struct that returns the base currency.
+func (f *RatesFile) Base() string {
+	return f.base
+}
+
+// Rates is a method on the RatesFile struct that returns a copy of the current rates.
+func (f *RatesFile) Rates() types.RateTable {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	table := f.table
+	table.Rates = make(map[string]string, len(f.table.Rates))
+	for currency, rate := range f.table.Rates {
+		table.Rates[currency] = rate
+	}
+	return table
+}
+
+// UpdateRates is a method on the RatesFile struct that checks and replaces the rates, writing them to the file
+// first, so the rates in use are always the ones a restart loads.
+func (f *RatesFile) UpdateRates(table types.RateTable) error {
+	rates, err := parseRates(table, f.base)
+	if err != nil {
+		return fmt.Errorf("%w: %w", types.ErrInvalidRates, err)
+	}
+
+	data, err := json.MarshalIndent(table, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := writeFileAtomic(f.path, data); err != nil {
+		return err
+	}
+	f.table, f.rates = table, rates
+
+	return nil
+}
+
+// Convert is a method on the RatesFile struct that converts an amount to another currency through the base
+// currency, rounding half up to the minor unit of the currency.
+func (f *RatesFile) Convert(m types.Money, currency string) (types.Money, error) {
+	if m.Currency == currency {
+		return m, nil
+	}
+
+	f.mu.RLock()
+	from, fromOK := f.rate(m.Currency)
+	to, toOK := f.rate(currency)
+	f.mu.RUnlock()
+
+	if !fromOK {
+		return types.Money{}, fmt.Errorf("%w %s", types.ErrUnsupportedCurrency, m.Currency)
+	}
+	if !toOK {
+		return types.Money{}, fmt.Errorf("%w %s", types.ErrUnsupportedCurrency, currency)
+	}
+
+	amount := new(big.Rat).Quo(m.Rat(), from)
+	amount.Mul(amount, to)
+	return types.RoundMoney(amount, currency, types.RoundHalfUp)
+}
+
+// rate returns the rate of a currency, which is 1 for the base currency. The lock must be held.
+func (f *RatesFile) rate(currency string) (*big.Rat, bool) {
+	if currency == f.base {
+		return big.NewRat(1, 1), true
+	}
+
+	r, ok := f.rates[currency]
+	return r, ok
+}
+
+// parseRates checks a rate table of the base currency and returns its parsed rates.
+func parseRates(table types.RateTable, base string) (map[string]*big.Rat, error) {
+	if table.Base != base {
+		return nil, fmt.Errorf("rates must be of the base currency %s, not %q", base, table.Base)
+	}
+
+	rates := map[string]*big.Rat{}
+	for currency, rate := range table.Rates {
+		if currency == base {
+			return nil, fmt.Errorf("the base currency %s has no rate", base)
+		}
+		if _, ok := types.MinorDigits(currency); !ok {
+			return nil, fmt.Errorf("%w %q", types.ErrUnknownCurrency, currency)
+		}
+
+		r, ok := new(big.Rat).SetString(rate)
+		if !ratePattern.MatchString(rate) || !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s, expected a positive decimal", rate, currency)
+		}
+		rates[currency] = r
+	}
+
+	return rates, nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path, so readers never see
+// a partly written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,93 @@
+package currency
+
+import (
+	"errors"        // Import the errors package to match errors
+	"os"            // Import the os package to write rates files
+	"path/filepath" // Import the filepath package to name rates files
+	"testing"       // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for the Money type
+)
+
+// TestRatesFile tests loading, updating and converting with the exchange rates.
+func TestRatesFile(t *testing.T) {
+	t.Run("should start without rates when there is no file", func(t *testing.T) {
+		f, err := NewRatesFile(filepath.Join(t.TempDir(), "rates.json"), "EUR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rates := f.Rates(); rates.Base != "EUR" || len(rates.Rates) != 0 {
+			t.Errorf("expected no rates, got %+v", rates)
+		}
+		if _, err := f.Convert(types.NewMoney(100, "EUR"), "USD"); !errors.Is(err, types.ErrUnsupportedCurrency) {
+			t.Errorf("expected an unsupported currency, got %v", err)
+		}
+	})
+
+	t.Run("should keep updated rates for the next start", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rates.json")
+		f, _ := NewRatesFile(path, "EUR")
+		if err := f.UpdateRates(types.RateTable{Base: "EUR", Rates: map[string]string{"USD": "1.0842", "JPY": "162.5"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded, err := NewRatesFile(path, "EUR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rates := reloaded.Rates(); rates.Rates["USD"] != "1.0842" || rates.Rates["JPY"] != "162.5" {
+			t.Errorf("expected the updated rates, got %+v", rates)
+		}
+	})
+
+	t.Run("should reject invalid rates and keep the current ones", func(t *testing.T) {
+		f, _ := NewRatesFile(filepath.Join(t.TempDir(), "rates.json"), "EUR")
+		f.UpdateRates(types.RateTable{Base: "EUR", Rates: map[string]string{"USD": "1.1"}})
+
+		for _, table := range []types.RateTable{
+			{Base: "USD", Rates: map[string]string{"EUR": "0.9"}},
+			{Base: "EUR", Rates: map[string]string{"EUR": "1"}},
+			{Base: "EUR", Rates: map[string]string{"XYZ": "1"}},
+			{Base: "EUR", Rates: map[string]string{"USD": "0"}},
+			{Base: "EUR", Rates: map[string]string{"USD": "-1.1"}},
+			{Base: "EUR", Rates: map[string]string{"USD": "1e3"}},
+		} {
+			if err := f.UpdateRates(table); !errors.Is(err, types.ErrInvalidRates) {
+				t.Errorf("expected %+v to be rejected, got %v", table, err)
+			}
+		}
+		if rates := f.Rates(); rates.Rates["USD"] != "1.1" || len(rates.Rates) != 1 {
+			t.Errorf("expected the rates to be unchanged, got %+v", rates)
+		}
+	})
+
+	t.Run("should refuse to start with an invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rates.json")
+		os.WriteFile(path, []byte(`{"base": "USD", "rates": {}}`), 0o644)
+
+		if _, err := NewRatesFile(path, "EUR"); err == nil {
+			t.Error("expected the rates of another base currency to be rejected")
+		}
+	})
+
+	t.Run("should convert through the base currency and round half up", func(t *testing.T) {
+		f, _ := NewRatesFile(filepath.Join(t.TempDir(), "rates.json"), "EUR")
+		f.UpdateRates(types.RateTable{Base: "EUR", Rates: map[string]string{"USD": "1.0842", "JPY": "162.5"}})
+
+		for _, tc := range []struct {
+			from     types.Money
+			currency string
+			expected types.Money
+		}{
+			{types.NewMoney(1999, "EUR"), "USD", types.NewMoney(2167, "USD")}, // 21.673158
+			{types.NewMoney(1999, "EUR"), "JPY", types.NewMoney(3248, "JPY")}, // 3248.375
+			{types.NewMoney(10842, "USD"), "EUR", types.NewMoney(10000, "EUR")},
+			{types.NewMoney(1625, "JPY"), "USD", types.NewMoney(1084, "USD")}, // 10.842
+			{types.NewMoney(1999, "EUR"), "EUR", types.NewMoney(1999, "EUR")},
+		} {
+			if m, err := f.Convert(tc.from, tc.currency); err != nil || m != tc.expected {
+				t.Errorf("%v to %s: expected %v, got %v (%v)", tc.from, tc.currency, tc.expected, m, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package currency
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the auth package for the authentication middlewares.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle currency requests.
+type Handler struct {
+	rates     types.ExchangeRates  // The exchange rates of the base currency.
+	pricer    types.Pricer         // Shows prices in the currency of the customer.
+	prices    types.PriceListStore // Interface for the prices of products in other currencies.
+	products  types.ProductStore   // Interface for the product catalog, to check products exist.
+	userStore types.UserStore      // Interface for user-related data operations.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(rates types.ExchangeRates, pricer types.Pricer, prices types.PriceListStore, products types.ProductStore, userStore types.UserStore) *Handler {
+	return &Handler{rates: rates, pricer: pricer, prices: prices, products: products, userStore: userStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the currency routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Currencies prices can be shown in, and the rates they are converted at.
+	router.HandleFunc("/currencies", h.handleGetCurrencies).Methods(http.MethodGet)
+	router.HandleFunc("/exchange-rates", h.handleGetRates).Methods(http.MethodGet)
+
+	// The preferred currency of the user.
+	router.HandleFunc("/me/currency", auth.WithAuth(h.handleSetPreference, h.userStore)).Methods(http.MethodPut)
+
+	// Manage the exchange rates and the price lists of products.
+	router.HandleFunc("/admin/exchange-rates", auth.WithAdminAuth(h.handleUpdateRates, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/products/{id:[0-9]+}/prices", auth.WithAdminAuth(h.handleGetPriceList, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/products/{id:[0-9]+}/prices/{currency}", auth.WithAdminAuth(h.handleSetPrice, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/products/{id:[0-9]+}/prices/{currency}", auth.WithAdminAuth(h.handleDeletePrice, h.userStore)).Methods(http.MethodDelete)
+}
+
+// handleGetCurrencies handles GET /currencies.
+// It lists the currencies prices can be shown in, and the one the request selects.
+func (h *Handler) handleGetCurrencies(w http.ResponseWriter, r *http.Request) {
+	selected, err := FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"base":       h.pricer.Base(),
+		"currencies": h.pricer.Currencies(),
+		"selected":   selected,
+	})
+}
+
+// handleGetRates handles GET /exchange-rates.
+func (h *Handler) handleGetRates(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, h.rates.Rates())
+}
+
+// handleUpdateRates handles PUT /admin/exchange-rates.
+// The body replaces all rates, so currencies left out can no longer be selected. The base may be left out.
+func (h *Handler) handleUpdateRates(w http.ResponseWriter, r *http.Request) {
+	var table types.RateTable
+	if err := utils.ParseJSON(r, &table); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if table.Base == "" {
+		table.Base = h.rates.Base()
+	}
+	if table.Rates == nil {
+		table.Rates = map[string]string{}
+	}
+	table.UpdatedAt = time.Now().UTC()
+
+	if err := h.rates.UpdateRates(table); errors.Is(err, types.ErrInvalidRates) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, h.rates.Rates())
+}
+
+// handleSetPreference handles PUT /me/currency.
+// An empty currency removes the preference, so prices are shown in the base currency again.
+func (h *Handler) handleSetPreference(w http.ResponseWriter, r *http.Request) {
+	var payload types.CurrencyPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	currency := strings.ToUpper(payload.Currency)
+	if currency != "" && !slices.Contains(h.pricer.Currencies(), currency) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%w %q", types.ErrUnsupportedCurrency, currency))
+		return
+	}
+
+	if err := h.userStore.UpdateCurrency(auth.GetUserIDFromContext(r.Context()), currency); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"currency": currency})
+}
+
+// handleGetPriceList handles GET /admin/products/{id}/prices.
+// It returns the base price of the product followed by the prices set in other currencies.
+func (h *Handler) handleGetPriceList(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	p, err := h.products.GetProductByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	prices, err := h.prices.GetPriceList(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, append([]types.Money{p.Price}, prices...))
+}
+
+// handleSetPrice handles PUT /admin/products/{id}/prices/{currency}.
+// Prices can be set in any currency with an exchange rate other than the base currency, whose price is the
+// price of the product itself.
+func (h *Handler) handleSetPrice(w http.ResponseWriter, r *http.Request) {
+	id, currency, ok := h.priceTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var payload types.PricePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	price, err := types.ParseMoney(payload.Amount, currency)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !price.IsPositive() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("price must be above zero"))
+		return
+	}
+
+	if err := h.prices.SetPrice(id, price); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, price)
+}
+
+// handleDeletePrice handles DELETE /admin/products/{id}/prices/{currency}.
+// The product then sells at its converted base price in that currency.
+func (h *Handler) handleDeletePrice(w http.ResponseWriter, r *http.Request) {
+	id, currency, ok := h.priceTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.prices.DeletePrice(id, currency); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// priceTarget returns the product ID and currency of a price list route, after checking the product exists and
+// the currency can have a price list. On failure it writes the error response and returns false.
+func (h *Handler) priceTarget(w http.ResponseWriter, r *http.Request) (int, string, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	currency := strings.ToUpper(mux.Vars(r)["currency"])
+
+	if _, err := h.products.GetProductByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return 0, "", false
+	}
+	if currency == h.pricer.Base() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("prices in %s are set on the product", currency))
+		return 0, "", false
+	}
+	if !slices.Contains(h.pricer.Currencies(), currency) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%w %q", types.ErrUnsupportedCurrency, currency))
+		return 0, "", false
+	}
+
+	return id, currency, true
+}
@@ -0,0 +1,233 @@
+package currency
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"path/filepath"     // Import the filepath package to name the rates file
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for currency types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestCurrencyHandlers tests the currency HTTP handlers.
+func TestCurrencyHandlers(t *testing.T) {
+	// Create a price list store with one product (ID 1), and a user store with one admin (ID 1) and one customer
+	// (ID 2). Prices are in euros, which buy 1.10 dollars.
+	rates, err := NewRatesFile(filepath.Join(t.TempDir(), "rates.json"), "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates.UpdateRates(types.RateTable{Base: "EUR", Rates: map[string]string{"USD": "1.10"}})
+
+	store := &mockPriceStore{
+		products: map[int]*types.Product{1: {ID: 1, SKU: "TS", Price: types.NewMoney(1995, "EUR")}},
+		prices:   map[string]types.Money{},
+	}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin},
+		2: {ID: 2, Email: "customer@example.com", Role: types.RoleCustomer},
+	}}
+
+	// Register the routes of a handler using the mock stores. The tests share them, so the rates are only updated
+	// by the last one.
+	router := mux.NewRouter()
+	NewHandler(rates, NewPricer(rates, store), store, store, userStore).RegisterRoutes(router)
+
+	t.Run("should list the currencies and select the preferred one", func(t *testing.T) {
+		userStore.users[2].Currency = "USD"
+
+		var res struct {
+			Base       string   `json:"base"`
+			Currencies []string `json:"currencies"`
+			Selected   string   `json:"selected"`
+		}
+		rr := send(t, router, http.MethodGet, "/currencies", "", 2)
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res.Base != "EUR" || fmt.Sprint(res.Currencies) != "[EUR USD]" || res.Selected != "USD" {
+			t.Errorf("unexpected currencies %+v", res)
+		}
+
+		if rr := send(t, router, http.MethodGet, "/currencies", "", 0); !strings.Contains(rr.Body.String(), `"selected":"EUR"`) {
+			t.Errorf("expected guests to get the base currency, got %s", rr.Body)
+		}
+	})
+
+	t.Run("should save the preferred currency of a user", func(t *testing.T) {
+		if rr := send(t, router, http.MethodPut, "/me/currency", `{"currency": "usd"}`, 2); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if userStore.users[2].Currency != "USD" {
+			t.Errorf("expected the preference to be saved, got %q", userStore.users[2].Currency)
+		}
+
+		if rr := send(t, router, http.MethodPut, "/me/currency", `{"currency": "GBP"}`, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for a currency without a rate, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPut, "/me/currency", `{"currency": ""}`, 2); rr.Code != http.StatusOK || userStore.users[2].Currency != "" {
+			t.Errorf("expected the preference to be removed, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should manage the price list of a product", func(t *testing.T) {
+		rr := send(t, router, http.MethodPut, "/admin/products/1/prices/usd", `{"amount": "21.99"}`, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if store.prices["1:USD"] != types.NewMoney(2199, "USD") {
+			t.Errorf("expected the price to be set, got %v", store.prices)
+		}
+
+		var prices []types.Money
+		json.NewDecoder(send(t, router, http.MethodGet, "/admin/products/1/prices", "", 1).Body).Decode(&prices)
+		if fmt.Sprint(prices) != "[19.95 EUR 21.99 USD]" {
+			t.Errorf("expected the base price and the dollar price, got %v", prices)
+		}
+
+		if rr := send(t, router, http.MethodDelete, "/admin/products/1/prices/USD", "", 1); rr.Code != http.StatusNoContent || len(store.prices) != 0 {
+			t.Errorf("expected the price to be removed, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should reject invalid prices", func(t *testing.T) {
+		for _, tc := range []struct{ target, body string }{
+			{"/admin/products/1/prices/EUR", `{"amount": "19.95"}`},
+			{"/admin/products/1/prices/GBP", `{"amount": "19.95"}`},
+			{"/admin/products/1/prices/USD", `{"amount": "0"}`},
+			{"/admin/products/1/prices/USD", `{"amount": "19.955"}`},
+			{"/admin/products/1/prices/USD", `{}`},
+		} {
+			if rr := send(t, router, http.MethodPut, tc.target, tc.body, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: expected status code %d, got %d", tc.target, tc.body, http.StatusBadRequest, rr.Code)
+			}
+		}
+		if rr := send(t, router, http.MethodPut, "/admin/products/2/prices/USD", `{"amount": "19.95"}`, 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for an unknown product, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should let admins update the rates", func(t *testing.T) {
+		rr := send(t, router, http.MethodPut, "/admin/exchange-rates", `{"rates": {"USD": "1.08", "GBP": "0.85"}}`, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var table types.RateTable
+		json.NewDecoder(send(t, router, http.MethodGet, "/exchange-rates", "", 0).Body).Decode(&table)
+		if table.Base != "EUR" || table.Rates["GBP"] != "0.85" || table.UpdatedAt.IsZero() {
+			t.Errorf("expected the new rates, got %+v", table)
+		}
+
+		if rr := send(t, router, http.MethodPut, "/admin/exchange-rates", `{"rates": {"USD": "-1"}}`, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an invalid rate, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPut, "/admin/exchange-rates", `{"rates": {}}`, 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a customer, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID unless it is 0,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userID != 0 {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
+
+// UpdateCurrency is a mock method that sets the preferred currency of a user.
+func (m *mockUserStore) UpdateCurrency(userID int, currency string) error {
+	m.users[userID].Currency = currency
+	return nil
+}
+
+// mockPriceStore is an in-memory implementation of the PriceListStore interface and of the parts of the
+// ProductStore interface used by the handler.
+type mockPriceStore struct {
+	types.ProductStore
+	products map[int]*types.Product // Products by ID.
+	prices   map[string]types.Money // Prices by "productID:currency".
+}
+
+// GetProductByID is a mock method that returns a copy of the product with the given ID.
+func (m *mockPriceStore) GetProductByID(id int) (*types.Product, error) {
+	p, ok := m.products[id]
+	if !ok {
+		return nil, types.ErrProductNotFound
+	}
+
+	c := *p
+	return &c, nil
+}
+
+// GetPriceList is a mock method that returns the prices of a product, ordered by currency.
+func (m *mockPriceStore) GetPriceList(productID int) ([]types.Money, error) {
+	prices := []types.Money{}
+	for _, currency := range []string{"GBP", "USD"} {
+		if price, ok := m.prices[fmt.Sprintf("%d:%s", productID, currency)]; ok {
+			prices = append(prices, price)
+		}
+	}
+	return prices, nil
+}
+
+// GetPrices is a mock method that returns the prices in a currency of the products with the given IDs.
+func (m *mockPriceStore) GetPrices(productIDs []int, currency string) (map[int]types.Money, error) {
+	prices := map[int]types.Money{}
+	for _, id := range productIDs {
+		if price, ok := m.prices[fmt.Sprintf("%d:%s", id, currency)]; ok {
+			prices[id] = price
+		}
+	}
+	return prices, nil
+}
+
+// SetPrice is a mock method that sets the price of a product in the currency of the price.
+func (m *mockPriceStore) SetPrice(productID int, price types.Money) error {
+	m.prices[fmt.Sprintf("%d:%s", productID, price.Currency)] = price
+	return nil
+}
+
+// DeletePrice is a mock method that removes the price of a product in a currency.
+func (m *mockPriceStore) DeletePrice(productID int, currency string) error {
+	delete(m.prices, fmt.Sprintf("%d:%s", productID, currency))
+	return nil
+}
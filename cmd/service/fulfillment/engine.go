@@ -0,0 +1,132 @@
+package fulfillment
+
+import (
+	// Import the types package for the store and strategy interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Engine struct routes orders to warehouses. It loads the active warehouses and their stock from the
+// inventory store and leaves the choice of warehouses to a strategy.
+type Engine struct {
+	store    types.InventoryStore      // Interface for inventory data operations.
+	strategy types.FulfillmentStrategy // The strategy used when no other is given.
+}
+
+// NewEngine is a constructor function that returns a new Engine using the given default strategy.
+func NewEngine(store types.InventoryStore, strategy types.FulfillmentStrategy) *Engine {
+	return &Engine{store: store, strategy: strategy}
+}
+
+// Plan is a method on the Engine struct that routes the lines of an order shipped to the destination,
+// using the default strategy.
+func (e *Engine) Plan(destination types.Location, lines []types.OrderLine) (*types.FulfillmentPlan, error) {
+	return e.PlanWith(e.strategy, destination, lines)
+}
+
+// PlanWith is a method on the Engine struct that routes the lines of an order shipped to the destination,
+// using the given strategy.
+// Plans are based on the stock on hand in each warehouse. Reservations are not tied to a warehouse until they
+// are committed, right after the order is placed, so they are not taken into account: the reservation made at
+// checkout already guarantees the total is available.
+func (e *Engine) PlanWith(strategy types.FulfillmentStrategy, destination types.Location, lines []types.OrderLine) (*types.FulfillmentPlan, error) {
+	req, err := e.buildRequest(destination, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	return strategy.Plan(req)
+}
+
+// Route is a method on the Engine struct that chooses the warehouse each line of an order ships from, using the
+// default strategy, or the priority strategy if the destination is unknown, since distances then mean nothing.
+// A line ships whole from one warehouse: the first warehouse the plan draws it from that has it all in stock, or
+// else the highest priority warehouse that has. A line no warehouse has entirely goes to the one the plan draws most of it
+// from; committing its stock there fails, so it waits for stock again until enough is moved there.
+func (e *Engine) Route(destination *types.Location, lines []types.OrderLine) ([]int, error) {
+	strategy := e.strategy
+	if destination == nil {
+		strategy = &greedyStrategy{name: StrategyPriority, rank: byPriority}
+		destination = &types.Location{}
+	}
+
+	req, err := e.buildRequest(*destination, lines)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := strategy.Plan(req)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := copyStock(req.Candidates)
+	warehouses := make([]int, len(lines))
+	for i, line := range lines {
+		// The warehouses the plan draws the line from, in the order it chose them.
+		drawn, largest := []int{}, 0
+		for _, a := range plan.Allocations {
+			if a.Line != i {
+				continue
+			}
+			drawn = append(drawn, a.WarehouseID)
+			if largest == 0 || a.Quantity > largest {
+				warehouses[i], largest = a.WarehouseID, a.Quantity
+			}
+		}
+		for _, c := range byPriority(req) {
+			drawn = append(drawn, c.Warehouse.ID)
+		}
+
+		for _, id := range drawn {
+			if stock[id][line.SKU] >= line.Quantity {
+				warehouses[i] = id
+				break
+			}
+		}
+		if id := warehouses[i]; id != 0 {
+			stock[id][line.SKU] -= min(line.Quantity, stock[id][line.SKU])
+		}
+	}
+
+	return warehouses, nil
+}
+
+// buildRequest loads the active warehouses and their stock of the ordered SKUs.
+func (e *Engine) buildRequest(destination types.Location, lines []types.OrderLine) (types.FulfillmentRequest, error) {
+	req := types.FulfillmentRequest{Destination: destination, Lines: lines}
+
+	warehouses, err := e.store.GetWarehouses()
+	if err != nil {
+		return req, err
+	}
+
+	// Only active warehouses can ship.
+	stock := map[int]map[string]int{}
+	for _, w := range warehouses {
+		if !w.Active {
+			continue
+		}
+		stock[w.ID] = map[string]int{}
+		req.Candidates = append(req.Candidates, types.FulfillmentCandidate{Warehouse: *w, Stock: stock[w.ID]})
+	}
+
+	// Load the stock of every ordered SKU once.
+	loaded := map[string]bool{}
+	for _, line := range lines {
+		if loaded[line.SKU] {
+			continue
+		}
+		loaded[line.SKU] = true
+
+		levels, err := e.store.GetStockLevels(line.SKU)
+		if err != nil {
+			return req, err
+		}
+		for _, l := range levels {
+			if s, ok := stock[l.WarehouseID]; ok && l.OnHand > 0 {
+				s[l.SKU] = l.OnHand
+			}
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,70 @@
+package fulfillment
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"fmt"
+	"net/http"
+
+	// Import the auth package for the admin-only middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle fulfillment routing requests.
+type Handler struct {
+	engine    *Engine         // The routing engine.
+	userStore types.UserStore // Interface for user-related data operations, used to authenticate admins.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(engine *Engine, userStore types.UserStore) *Handler {
+	return &Handler{engine: engine, userStore: userStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the fulfillment routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Preview which warehouses an order would ship from.
+	router.HandleFunc("/admin/fulfillment/plan", auth.WithAdminAuth(h.handlePlan, h.userStore)).Methods(http.MethodPost)
+}
+
+// handlePlan handles POST /admin/fulfillment/plan.
+// It routes the lines in the payload without changing any stock, using the configured strategy unless
+// the payload names another one.
+func (h *Handler) handlePlan(w http.ResponseWriter, r *http.Request) {
+	var payload types.FulfillmentPlanPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	strategy := h.engine.strategy
+	if payload.Strategy != "" {
+		s, err := NewStrategy(payload.Strategy)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		strategy = s
+	}
+
+	plan, err := h.engine.PlanWith(strategy, payload.Destination, payload.Lines)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, plan)
+}
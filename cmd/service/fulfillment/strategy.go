@@ -0,0 +1,222 @@
+package fulfillment
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	// Import the types package for the fulfillment types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Names of the built-in strategies.
+const (
+	StrategyPriority    = "priority"    // Ship from the warehouses with the lowest priority number first.
+	StrategyNearest     = "nearest"     // Ship from the warehouses nearest to the destination first.
+	StrategyConsolidate = "consolidate" // Ship from as few warehouses as possible, preferring the nearest.
+)
+
+// NewStrategy returns the built-in strategy with the given name.
+func NewStrategy(name string) (types.FulfillmentStrategy, error) {
+	switch name {
+	case StrategyPriority:
+		return &greedyStrategy{name: name, rank: byPriority}, nil
+	case StrategyNearest:
+		return &greedyStrategy{name: name, rank: byDistance}, nil
+	case StrategyConsolidate:
+		return &consolidateStrategy{rank: byDistance}, nil
+	}
+
+	return nil, fmt.Errorf("unknown fulfillment strategy %q", name)
+}
+
+// ranking orders the candidates of a request from most to least preferred.
+// Rankings must break every tie, so the strategies using them are deterministic.
+type ranking func(types.FulfillmentRequest) []types.FulfillmentCandidate
+
+// greedyStrategy fills each line in turn from the highest ranked warehouses that have stock,
+// splitting a line over several warehouses when none has enough on its own.
+type greedyStrategy struct {
+	name string  // Name of the strategy.
+	rank ranking // Order in which warehouses are tried.
+}
+
+// Name is a method on the greedyStrategy struct that returns the name of the strategy.
+func (s *greedyStrategy) Name() string {
+	return s.name
+}
+
+// Plan is a method on the greedyStrategy struct that assigns the lines of the request to warehouses.
+func (s *greedyStrategy) Plan(req types.FulfillmentRequest) (*types.FulfillmentPlan, error) {
+	candidates := s.rank(req)
+	stock := copyStock(candidates)
+	plan := &types.FulfillmentPlan{Strategy: s.name, Allocations: []types.Allocation{}, Unfulfilled: []types.OrderLine{}}
+
+	for i, line := range req.Lines {
+		remaining := line.Quantity
+		for _, c := range candidates {
+			remaining -= allocate(plan, stock, i, line.SKU, c.Warehouse.ID, remaining)
+			if remaining == 0 {
+				break
+			}
+		}
+		if remaining > 0 {
+			plan.Unfulfilled = append(plan.Unfulfilled, types.OrderLine{SKU: line.SKU, Quantity: remaining})
+		}
+	}
+
+	plan.Shipments = countShipments(plan.Allocations)
+	return plan, nil
+}
+
+// consolidateStrategy minimizes split shipments. It repeatedly picks the warehouse that can supply the most
+// of what is still unallocated, using the ranking to break ties, so an order that one warehouse can fulfill
+// entirely always ships from a single warehouse.
+type consolidateStrategy struct {
+	rank ranking // Order used to break ties between equally good warehouses.
+}
+
+// Name is a method on the consolidateStrategy struct that returns the name of the strategy.
+func (s *consolidateStrategy) Name() string {
+	return StrategyConsolidate
+}
+
+// Plan is a method on the consolidateStrategy struct that assigns the lines of the request to as few
+// warehouses as possible.
+func (s *consolidateStrategy) Plan(req types.FulfillmentRequest) (*types.FulfillmentPlan, error) {
+	candidates := s.rank(req)
+	stock := copyStock(candidates)
+	plan := &types.FulfillmentPlan{Strategy: StrategyConsolidate, Allocations: []types.Allocation{}, Unfulfilled: []types.OrderLine{}}
+
+	remaining := make([]int, len(req.Lines))
+	for i, line := range req.Lines {
+		remaining[i] = line.Quantity
+	}
+
+	used := map[int]bool{}
+	for {
+		// Find the unused warehouse that can supply the most units. Only a strictly better warehouse
+		// replaces the current best, so ties go to the higher ranked one.
+		best, bestUnits := -1, 0
+		for j, c := range candidates {
+			if used[c.Warehouse.ID] {
+				continue
+			}
+			units := 0
+			for i, line := range req.Lines {
+				units += min(remaining[i], stock[c.Warehouse.ID][line.SKU])
+			}
+			if units > bestUnits {
+				best, bestUnits = j, units
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		// Ship everything it can from that warehouse.
+		id := candidates[best].Warehouse.ID
+		used[id] = true
+		for i, line := range req.Lines {
+			remaining[i] -= allocate(plan, stock, i, line.SKU, id, remaining[i])
+		}
+	}
+
+	for i, line := range req.Lines {
+		if remaining[i] > 0 {
+			plan.Unfulfilled = append(plan.Unfulfilled, types.OrderLine{SKU: line.SKU, Quantity: remaining[i]})
+		}
+	}
+
+	// Allocations were made warehouse by warehouse; order them by line, keeping the warehouse order within a line.
+	sort.SliceStable(plan.Allocations, func(a, b int) bool {
+		return plan.Allocations[a].Line < plan.Allocations[b].Line
+	})
+
+	plan.Shipments = countShipments(plan.Allocations)
+	return plan, nil
+}
+
+// byPriority ranks the candidates by priority, then by warehouse ID.
+func byPriority(req types.FulfillmentRequest) []types.FulfillmentCandidate {
+	candidates := append([]types.FulfillmentCandidate{}, req.Candidates...)
+	sort.Slice(candidates, func(a, b int) bool {
+		wa, wb := candidates[a].Warehouse, candidates[b].Warehouse
+		if wa.Priority != wb.Priority {
+			return wa.Priority < wb.Priority
+		}
+		return wa.ID < wb.ID
+	})
+
+	return candidates
+}
+
+// byDistance ranks the candidates by distance to the destination, then by priority, then by warehouse ID.
+func byDistance(req types.FulfillmentRequest) []types.FulfillmentCandidate {
+	candidates := append([]types.FulfillmentCandidate{}, req.Candidates...)
+	distances := map[int]float64{}
+	for _, c := range candidates {
+		distances[c.Warehouse.ID] = Distance(c.Warehouse.Location, req.Destination)
+	}
+
+	sort.Slice(candidates, func(a, b int) bool {
+		wa, wb := candidates[a].Warehouse, candidates[b].Warehouse
+		if da, db := distances[wa.ID], distances[wb.ID]; da != db {
+			return da < db
+		}
+		if wa.Priority != wb.Priority {
+			return wa.Priority < wb.Priority
+		}
+		return wa.ID < wb.ID
+	})
+
+	return candidates
+}
+
+// Distance returns the great-circle distance between two locations, in kilometres.
+func Distance(a, b types.Location) float64 {
+	const earthRadius = 6371.0
+
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// copyStock returns a copy of the stock of the candidates, by warehouse ID and SKU, that strategies can draw down.
+func copyStock(candidates []types.FulfillmentCandidate) map[int]map[string]int {
+	stock := map[int]map[string]int{}
+	for _, c := range candidates {
+		stock[c.Warehouse.ID] = map[string]int{}
+		for sku, n := range c.Stock {
+			stock[c.Warehouse.ID][sku] = n
+		}
+	}
+
+	return stock
+}
+
+// allocate ships up to quantity units of a line from a warehouse, drawing down its stock,
+// and returns the quantity allocated.
+func allocate(plan *types.FulfillmentPlan, stock map[int]map[string]int, line int, sku string, warehouseID, quantity int) int {
+	n := min(quantity, stock[warehouseID][sku])
+	if n <= 0 {
+		return 0
+	}
+
+	stock[warehouseID][sku] -= n
+	plan.Allocations = append(plan.Allocations, types.Allocation{Line: line, SKU: sku, WarehouseID: warehouseID, Quantity: n})
+	return n
+}
+
+// countShipments returns the number of distinct warehouses in the allocations.
+func countShipments(allocations []types.Allocation) int {
+	warehouses := map[int]bool{}
+	for _, a := range allocations {
+		warehouses[a.WarehouseID] = true
+	}
+
+	return len(warehouses)
+}
@@ -0,0 +1,242 @@
+package fulfillment
+
+import (
+	"reflect" // Import the reflect package to compare plans
+	"testing" // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for fulfillment types
+)
+
+// Fixture locations.
+var (
+	amsterdam = types.Location{Latitude: 52.37, Longitude: 4.90}
+	berlin    = types.Location{Latitude: 52.52, Longitude: 13.40}
+	madrid    = types.Location{Latitude: 40.42, Longitude: -3.70}
+)
+
+// fixtureRequest returns an order shipped to Amsterdam, with three warehouses:
+// a nearby one with little stock, a distant one with the highest priority and everything in stock,
+// and one in between with part of the order.
+func fixtureRequest(lines ...types.OrderLine) types.FulfillmentRequest {
+	return types.FulfillmentRequest{
+		Destination: amsterdam,
+		Lines:       lines,
+		Candidates: []types.FulfillmentCandidate{
+			{Warehouse: types.Warehouse{ID: 1, Code: "AMS1", Priority: 2, Location: amsterdam}, Stock: map[string]int{"A": 1, "B": 2}},
+			{Warehouse: types.Warehouse{ID: 2, Code: "BER1", Priority: 1, Location: berlin}, Stock: map[string]int{"A": 5}},
+			{Warehouse: types.Warehouse{ID: 3, Code: "MAD1", Priority: 0, Location: madrid}, Stock: map[string]int{"A": 5, "B": 2}},
+		},
+	}
+}
+
+// TestStrategies tests the built-in fulfillment strategies against the fixture warehouses.
+func TestStrategies(t *testing.T) {
+	order := []types.OrderLine{{SKU: "A", Quantity: 2}, {SKU: "B", Quantity: 2}}
+
+	tests := []struct {
+		strategy    string
+		lines       []types.OrderLine
+		allocations []types.Allocation
+		shipments   int
+		unfulfilled []types.OrderLine
+	}{
+		{
+			// Everything ships from the highest priority warehouse, however far away it is.
+			strategy: StrategyPriority,
+			lines:    order,
+			allocations: []types.Allocation{
+				{Line: 0, SKU: "A", WarehouseID: 3, Quantity: 2},
+				{Line: 1, SKU: "B", WarehouseID: 3, Quantity: 2},
+			},
+			shipments:   1,
+			unfulfilled: []types.OrderLine{},
+		},
+		{
+			// The nearest warehouse ships what it has, and the next nearest makes up the rest.
+			strategy: StrategyNearest,
+			lines:    order,
+			allocations: []types.Allocation{
+				{Line: 0, SKU: "A", WarehouseID: 1, Quantity: 1},
+				{Line: 0, SKU: "A", WarehouseID: 2, Quantity: 1},
+				{Line: 1, SKU: "B", WarehouseID: 1, Quantity: 2},
+			},
+			shipments:   2,
+			unfulfilled: []types.OrderLine{},
+		},
+		{
+			// The only warehouse that has the whole order ships it, avoiding a split shipment.
+			strategy: StrategyConsolidate,
+			lines:    order,
+			allocations: []types.Allocation{
+				{Line: 0, SKU: "A", WarehouseID: 3, Quantity: 2},
+				{Line: 1, SKU: "B", WarehouseID: 3, Quantity: 2},
+			},
+			shipments:   1,
+			unfulfilled: []types.OrderLine{},
+		},
+		{
+			// When no single warehouse has enough, the nearest one that can ship the whole line wins the tie.
+			strategy: StrategyConsolidate,
+			lines:    []types.OrderLine{{SKU: "A", Quantity: 5}},
+			allocations: []types.Allocation{
+				{Line: 0, SKU: "A", WarehouseID: 2, Quantity: 5},
+			},
+			shipments:   1,
+			unfulfilled: []types.OrderLine{},
+		},
+		{
+			// Quantities no warehouse has are reported instead of failing the plan.
+			strategy: StrategyPriority,
+			lines:    []types.OrderLine{{SKU: "A", Quantity: 20}, {SKU: "C", Quantity: 1}},
+			allocations: []types.Allocation{
+				{Line: 0, SKU: "A", WarehouseID: 3, Quantity: 5},
+				{Line: 0, SKU: "A", WarehouseID: 2, Quantity: 5},
+				{Line: 0, SKU: "A", WarehouseID: 1, Quantity: 1},
+			},
+			shipments:   3,
+			unfulfilled: []types.OrderLine{{SKU: "A", Quantity: 9}, {SKU: "C", Quantity: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			strategy, err := NewStrategy(tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := strategy.Plan(fixtureRequest(tt.lines...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(plan.Allocations, tt.allocations) {
+				t.Errorf("expected allocations %+v, got %+v", tt.allocations, plan.Allocations)
+			}
+			if plan.Shipments != tt.shipments {
+				t.Errorf("expected %d shipments, got %d", tt.shipments, plan.Shipments)
+			}
+			if !reflect.DeepEqual(plan.Unfulfilled, tt.unfulfilled) {
+				t.Errorf("expected unfulfilled %+v, got %+v", tt.unfulfilled, plan.Unfulfilled)
+			}
+		})
+	}
+}
+
+// TestStrategiesAreDeterministic tests that the order of the candidates does not change the plan,
+// including when warehouses are tied on every rule but their ID.
+func TestStrategiesAreDeterministic(t *testing.T) {
+	req := fixtureRequest(types.OrderLine{SKU: "A", Quantity: 3})
+	req.Candidates = append(req.Candidates, types.FulfillmentCandidate{
+		Warehouse: types.Warehouse{ID: 4, Code: "AMS2", Priority: 2, Location: amsterdam},
+		Stock:     map[string]int{"A": 1},
+	})
+
+	// The same candidates, in reverse order.
+	reversed := req
+	reversed.Candidates = nil
+	for i := len(req.Candidates) - 1; i >= 0; i-- {
+		reversed.Candidates = append(reversed.Candidates, req.Candidates[i])
+	}
+
+	for _, name := range []string{StrategyPriority, StrategyNearest, StrategyConsolidate} {
+		strategy, _ := NewStrategy(name)
+
+		a, _ := strategy.Plan(req)
+		b, _ := strategy.Plan(reversed)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: expected the same plan for reordered candidates, got %+v and %+v", name, a, b)
+		}
+	}
+
+	if _, err := NewStrategy("cheapest"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
+
+// TestEngine tests that the engine only routes to active warehouses, using their stock from the inventory store.
+func TestEngine(t *testing.T) {
+	store := &mockInventoryStore{
+		warehouses: []*types.Warehouse{
+			{ID: 1, Active: false, Location: amsterdam},
+			{ID: 2, Active: true, Location: berlin},
+		},
+		levels: []*types.StockLevel{
+			{WarehouseID: 1, SKU: "A", OnHand: 10},
+			{WarehouseID: 2, SKU: "A", OnHand: 1},
+		},
+	}
+	strategy, _ := NewStrategy(StrategyNearest)
+
+	plan, err := NewEngine(store, strategy).Plan(amsterdam, []types.OrderLine{{SKU: "A", Quantity: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []types.Allocation{{Line: 0, SKU: "A", WarehouseID: 2, Quantity: 1}}
+	if !reflect.DeepEqual(plan.Allocations, expected) {
+		t.Errorf("expected allocations %+v, got %+v", expected, plan.Allocations)
+	}
+	if len(plan.Unfulfilled) != 1 || plan.Unfulfilled[0].Quantity != 1 {
+		t.Errorf("expected one unit unfulfilled, got %+v", plan.Unfulfilled)
+	}
+}
+
+// TestEngineRoute tests that the engine ships each line whole from one warehouse, preferring those the strategy
+// chose, and routes by priority when the destination is unknown.
+func TestEngineRoute(t *testing.T) {
+	store := &mockInventoryStore{}
+	for _, c := range fixtureRequest().Candidates {
+		w := c.Warehouse
+		w.Active = true
+		store.warehouses = append(store.warehouses, &w)
+		for sku, n := range c.Stock {
+			store.levels = append(store.levels, &types.StockLevel{WarehouseID: w.ID, SKU: sku, OnHand: n})
+		}
+	}
+	strategy, _ := NewStrategy(StrategyNearest)
+	engine := NewEngine(store, strategy)
+
+	// The nearest plan splits A over Amsterdam and Berlin, so it ships from Berlin, which has both units. No
+	// warehouse has 20 units of A; they go to Madrid, which the plan draws the most from.
+	lines := []types.OrderLine{{SKU: "A", Quantity: 2}, {SKU: "B", Quantity: 2}, {SKU: "A", Quantity: 20}}
+	warehouses, err := engine.Route(&amsterdam, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{2, 1, 3}; !reflect.DeepEqual(warehouses, expected) {
+		t.Errorf("expected warehouses %v, got %v", expected, warehouses)
+	}
+
+	// Without a destination, everything ships from the highest priority warehouse.
+	warehouses, err = engine.Route(nil, lines[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{3, 3}; !reflect.DeepEqual(warehouses, expected) {
+		t.Errorf("expected warehouses %v, got %v", expected, warehouses)
+	}
+}
+
+// mockInventoryStore is an in-memory implementation of the parts of the InventoryStore interface used by the engine.
+type mockInventoryStore struct {
+	types.InventoryStore
+	warehouses []*types.Warehouse  // All warehouses.
+	levels     []*types.StockLevel // All stock levels.
+}
+
+// GetWarehouses is a mock method that returns all warehouses.
+func (m *mockInventoryStore) GetWarehouses() ([]*types.Warehouse, error) {
+	return m.warehouses, nil
+}
+
+// GetStockLevels is a mock method that returns the stock levels of a SKU.
+func (m *mockInventoryStore) GetStockLevels(sku string) ([]*types.StockLevel, error) {
+	levels := []*types.StockLevel{}
+	for _, l := range m.levels {
+		if l.SKU == sku {
+			levels = append(levels, l)
+		}
+	}
+	return levels, nil
+}
@@ -0,0 +1,40 @@
+package giftcard
+
+import (
+	// Import the crypto/rand package for generating codes that cannot be guessed.
+	"crypto/rand"
+	"strings"
+)
+
+// codeAlphabet holds the characters of gift card codes: upper case letters and digits, without I, O, 0 and 1,
+// which are easily mistaken for each other when typed over from a card.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// codeLength is the number of characters of a code. With 32 possible characters each, a code holds 80 random
+// bits, far too many to guess one even at millions of tries per second.
+const codeLength = 16
+
+// NewCode returns a new random gift card code, in groups of four characters such as "ABCD-EFGH-JKLM-NPQR".
+func NewCode() (string, error) {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, c := range b {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		// 256 is a multiple of 32, so every character is equally likely.
+		code.WriteByte(codeAlphabet[int(c)%len(codeAlphabet)])
+	}
+
+	return code.String(), nil
+}
+
+// NormalizeCode returns a code as typed by a customer in the form it is stored in: upper case, without the
+// dashes and spaces between the groups.
+func NormalizeCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(strings.TrimSpace(code)))
+}
@@ -0,0 +1,33 @@
+package giftcard
+
+import (
+	"regexp"  // Import the regexp package to check the format of codes
+	"testing" // Import the testing package to write test cases
+)
+
+// TestCode tests generating and normalizing gift card codes.
+func TestCode(t *testing.T) {
+	t.Run("should generate distinct codes in groups of four", func(t *testing.T) {
+		format := regexp.MustCompile(`^[A-HJ-NP-Z2-9]{4}(-[A-HJ-NP-Z2-9]{4}){3}$`)
+
+		seen := map[string]bool{}
+		for range 100 {
+			code, err := NewCode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !format.MatchString(code) || seen[code] {
+				t.Fatalf("expected a new code like ABCD-EFGH-JKLM-NPQR, got %q", code)
+			}
+			seen[code] = true
+		}
+	})
+
+	t.Run("should normalize codes as typed by customers", func(t *testing.T) {
+		for _, code := range []string{"ABCD-EFGH-JKLM-NPQR", " abcd efgh jklm npqr ", "abcdefghjklmnpqr"} {
+			if got := NormalizeCode(code); got != "ABCDEFGHJKLMNPQR" {
+				t.Errorf("expected %q to normalize to ABCDEFGHJKLMNPQR, got %q", code, got)
+			}
+		}
+	})
+}
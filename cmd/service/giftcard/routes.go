@@ -0,0 +1,281 @@
+package giftcard
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	// Import the auth package for the authentication middlewares.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle gift card and store credit requests.
+type Handler struct {
+	store     types.BalanceStore // Interface for gift cards and store credit.
+	orders    types.OrderStore   // Interface for the orders, to check refunds.
+	pricer    types.Pricer       // Tells the currencies gift cards can be issued in.
+	userStore types.UserStore    // Interface for user-related data operations.
+	now       func() time.Time   // Returns the current time.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.BalanceStore, orders types.OrderStore, pricer types.Pricer, userStore types.UserStore) *Handler {
+	return &Handler{store: store, orders: orders, pricer: pricer, userStore: userStore, now: time.Now}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the gift card and store credit routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Look up the balance of a gift card, and the store credit of the user.
+	router.HandleFunc("/gift-cards/balance", auth.WithAuth(h.handleGetBalance, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/me/store-credit", auth.WithAuth(h.handleGetMyStoreCredit, h.userStore)).Methods(http.MethodGet)
+
+	// Issue gift cards and follow their use.
+	router.HandleFunc("/admin/gift-cards", auth.WithAdminAuth(h.handleGetGiftCards, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/gift-cards", auth.WithAdminAuth(h.handleIssueGiftCard, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/gift-cards/{id:[0-9]+}", auth.WithAdminAuth(h.handleGetGiftCard, h.userStore)).Methods(http.MethodGet)
+
+	// Give, refund and correct the store credit of customers.
+	router.HandleFunc("/admin/users/{id:[0-9]+}/store-credit", auth.WithAdminAuth(h.handleGetStoreCredit, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/users/{id:[0-9]+}/store-credit", auth.WithAdminAuth(h.handleAddStoreCredit, h.userStore)).Methods(http.MethodPost)
+}
+
+// handleGetBalance handles POST /gift-cards/balance.
+// The code is sent in the body rather than the URL, so it does not end up in access logs.
+func (h *Handler) handleGetBalance(w http.ResponseWriter, r *http.Request) {
+	var payload types.GiftCardCodePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	card, err := h.store.GetGiftCardByCode(payload.Code)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"last4":     card.Last4,
+		"balance":   card.Balance,
+		"expiresAt": card.ExpiresAt,
+		"expired":   card.Expired(h.now()),
+	})
+}
+
+// handleGetMyStoreCredit handles GET /me/store-credit.
+func (h *Handler) handleGetMyStoreCredit(w http.ResponseWriter, r *http.Request) {
+	h.writeStoreCredit(w, auth.GetUserIDFromContext(r.Context()))
+}
+
+// handleGetGiftCards handles GET /admin/gift-cards.
+func (h *Handler) handleGetGiftCards(w http.ResponseWriter, r *http.Request) {
+	cards, err := h.store.GetGiftCards()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cards)
+}
+
+// handleGetGiftCard handles GET /admin/gift-cards/{id}.
+// It returns the gift card with its ledger.
+func (h *Handler) handleGetGiftCard(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	card, err := h.store.GetGiftCardByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	entries, err := h.store.GetGiftCardLedger(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"giftCard": card, "entries": entries})
+}
+
+// handleIssueGiftCard handles POST /admin/gift-cards.
+// The code is only in this response: the store keeps a hash of it.
+func (h *Handler) handleIssueGiftCard(w http.ResponseWriter, r *http.Request) {
+	var payload types.GiftCardPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	if !payload.Value.IsPositive() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("value must be above zero"))
+		return
+	}
+	if !slices.Contains(h.pricer.Currencies(), payload.Value.Currency) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%w %q", types.ErrUnsupportedCurrency, payload.Value.Currency))
+		return
+	}
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(h.now()) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("expiresAt must be in the future"))
+		return
+	}
+
+	code, err := NewCode()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	id, err := h.store.CreateGiftCard(types.GiftCard{Value: payload.Value, Note: payload.Note, ExpiresAt: payload.ExpiresAt}, code)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	card, err := h.store.GetGiftCardByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{"giftCard": card, "code": code})
+}
+
+// handleGetStoreCredit handles GET /admin/users/{id}/store-credit.
+func (h *Handler) handleGetStoreCredit(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.userStore.GetUserByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	h.writeStoreCredit(w, id)
+}
+
+// handleAddStoreCredit handles POST /admin/users/{id}/store-credit.
+// Refunds go to the store credit in the currency of their order, and never add up to more than its total.
+// Goodwill adds credit; adjustments can also take it away, down to zero.
+func (h *Handler) handleAddStoreCredit(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.userStore.GetUserByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.StoreCreditPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	if payload.Reason != types.EntryAdjustment && !payload.Amount.IsPositive() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("amount of a %s must be above zero", payload.Reason))
+		return
+	}
+	if !slices.Contains(h.pricer.Currencies(), payload.Amount.Currency) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%w %q", types.ErrUnsupportedCurrency, payload.Amount.Currency))
+		return
+	}
+	if payload.Reason == types.EntryRefund {
+		if status, err := h.checkRefund(id, payload); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+	}
+
+	entryID, err := h.store.AddStoreCredit(types.BalanceEntry{
+		UserID:  &id,
+		OrderID: payload.OrderID,
+		Amount:  payload.Amount,
+		Reason:  payload.Reason,
+		Note:    payload.Note,
+	})
+	if errors.Is(err, types.ErrInsufficientBalance) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": entryID})
+}
+
+// checkRefund returns why a refund to store credit cannot be made, with the HTTP status code to respond with,
+// or nil if it can: the order must be the user's, in the currency of the refund, and not be refunded beyond
+// its total.
+func (h *Handler) checkRefund(userID int, payload types.StoreCreditPayload) (int, error) {
+	if payload.OrderID == nil {
+		return http.StatusBadRequest, fmt.Errorf("orderId is required for refunds")
+	}
+
+	o, err := h.orders.GetOrderByID(*payload.OrderID)
+	if err != nil || o.UserID != userID {
+		return http.StatusBadRequest, fmt.Errorf("order %d of user %d not found", *payload.OrderID, userID)
+	}
+	if o.Total.Currency != payload.Amount.Currency {
+		return http.StatusBadRequest, fmt.Errorf("order %d was paid in %s", o.ID, o.Total.Currency)
+	}
+
+	entries, err := h.store.GetStoreCreditLedger(userID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	refunded := payload.Amount.Amount
+	for _, e := range entries {
+		if e.Reason == types.EntryRefund && e.OrderID != nil && *e.OrderID == o.ID {
+			refunded += e.Amount.Amount
+		}
+	}
+	if refunded > o.Total.Amount {
+		return http.StatusConflict, fmt.Errorf("refunds of order %d would exceed its total of %v", o.ID, o.Total)
+	}
+
+	return 0, nil
+}
+
+// writeStoreCredit writes the store credit balances and ledger of a user.
+func (h *Handler) writeStoreCredit(w http.ResponseWriter, userID int) {
+	balances, err := h.store.GetStoreCredit(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	entries, err := h.store.GetStoreCreditLedger(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"balances": balances, "entries": entries})
+}
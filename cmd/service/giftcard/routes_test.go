@@ -0,0 +1,274 @@
+package giftcard
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package to fix the current time
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for gift card types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestGiftCardHandlers tests the gift card and store credit handlers.
+func TestGiftCardHandlers(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// Create an empty balance store, an order of 30.00 (ID 7) of customer 2, and a user store with one admin (ID 1)
+	// and two customers (IDs 2 and 3).
+	store := &mockBalanceStore{codes: map[string]int{}}
+	orders := &mockOrderStore{orders: map[int]*types.Order{
+		7: {ID: 7, UserID: 2, Total: types.NewMoney(3000, "EUR")},
+	}}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleAdmin},
+		2: {ID: 2, Role: types.RoleCustomer},
+		3: {ID: 3, Role: types.RoleCustomer},
+	}}
+
+	// Initialize a handler using the mock stores at a fixed time, and register its routes.
+	h := NewHandler(store, orders, &mockPricer{}, userStore)
+	h.now = func() time.Time { return now }
+
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	t.Run("should issue gift cards and show their code only once", func(t *testing.T) {
+		rr := send(t, router, http.MethodPost, "/admin/gift-cards", `{"value": {"amount": "25.00", "currency": "EUR"}, "note": "Raffle"}`, 1)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		var res struct {
+			GiftCard types.GiftCard `json:"giftCard"`
+			Code     string         `json:"code"`
+		}
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res.GiftCard.ID != 1 || res.GiftCard.Balance != types.NewMoney(2500, "EUR") || store.codes[NormalizeCode(res.Code)] != 1 {
+			t.Errorf("expected the gift card with its code, got %+v", res)
+		}
+
+		rr = send(t, router, http.MethodGet, "/admin/gift-cards/1", "", 1)
+		if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), res.Code) {
+			t.Errorf("expected the gift card without its code, got %d: %s", rr.Code, rr.Body)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/gift-cards", `{"value": {"amount": "25.00", "currency": "EUR"}}`, 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a customer, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should reject invalid gift cards", func(t *testing.T) {
+		issued := len(store.cards)
+		for _, body := range []string{
+			`{}`,
+			`{"value": {"amount": "-5.00", "currency": "EUR"}}`,
+			`{"value": {"amount": "25.00", "currency": "JPY"}}`,
+			`{"value": {"amount": "25.00", "currency": "EUR"}, "expiresAt": "2024-01-01T00:00:00Z"}`,
+		} {
+			if rr := send(t, router, http.MethodPost, "/admin/gift-cards", body, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+		if len(store.cards) != issued {
+			t.Errorf("expected no gift cards to be issued")
+		}
+	})
+
+	t.Run("should look up balances ignoring case and dashes", func(t *testing.T) {
+		expired := now.Add(-time.Hour)
+		store.CreateGiftCard(types.GiftCard{Value: types.NewMoney(1000, "EUR")}, "ABCD-EFGH-JKLM-NPQR")
+		store.CreateGiftCard(types.GiftCard{Value: types.NewMoney(1000, "EUR"), ExpiresAt: &expired}, "BBBB-BBBB-BBBB-BBBB")
+
+		rr := send(t, router, http.MethodPost, "/gift-cards/balance", `{"code": "abcd efgh-jklmnpqr"}`, 2)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"last4":"NPQR"`) || !strings.Contains(rr.Body.String(), `"expired":false`) {
+			t.Errorf("expected the balance of the gift card, got %d: %s", rr.Code, rr.Body)
+		}
+
+		rr = send(t, router, http.MethodPost, "/gift-cards/balance", `{"code": "BBBBBBBBBBBBBBBB"}`, 2)
+		if !strings.Contains(rr.Body.String(), `"expired":true`) {
+			t.Errorf("expected the gift card to have expired, got %s", rr.Body)
+		}
+		if rr := send(t, router, http.MethodPost, "/gift-cards/balance", `{"code": "NOPE"}`, 2); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for an unknown code, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should refund orders to store credit up to their total", func(t *testing.T) {
+		body := func(amount string, orderID int) string {
+			return fmt.Sprintf(`{"amount": {"amount": "%s", "currency": "EUR"}, "reason": "refund", "orderId": %d}`, amount, orderID)
+		}
+
+		if rr := send(t, router, http.MethodPost, "/admin/users/2/store-credit", body("20.00", 7), 1); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/users/2/store-credit", body("10.01", 7), 1); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d beyond the total, got %d", http.StatusConflict, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/users/1/store-credit", body("5.00", 7), 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for the order of another user, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/users/2/store-credit", `{"amount": {"amount": "5.00", "currency": "EUR"}, "reason": "refund"}`, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d without an order, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr := send(t, router, http.MethodGet, "/me/store-credit", "", 2)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"amount":"20.00"`) || len(store.entries) != 1 {
+			t.Errorf("expected 20.00 of store credit, got %d: %s", rr.Code, rr.Body)
+		}
+	})
+
+	t.Run("should not take away more store credit than there is", func(t *testing.T) {
+		send(t, router, http.MethodPost, "/admin/users/3/store-credit", `{"amount": {"amount": "5.00", "currency": "EUR"}, "reason": "goodwill"}`, 1)
+
+		if rr := send(t, router, http.MethodPost, "/admin/users/3/store-credit", `{"amount": {"amount": "-6.00", "currency": "EUR"}, "reason": "adjustment"}`, 1); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/users/3/store-credit", `{"amount": {"amount": "-5.00", "currency": "EUR"}, "reason": "goodwill"}`, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for negative goodwill, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/users/4/store-credit", `{"amount": {"amount": "5.00", "currency": "EUR"}, "reason": "goodwill"}`, 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for an unknown user, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockBalanceStore is an in-memory implementation of the parts of the BalanceStore interface used by the
+// handler.
+type mockBalanceStore struct {
+	types.BalanceStore
+	cards   []*types.GiftCard    // Issued gift cards, by ID minus one.
+	codes   map[string]int       // IDs of the gift cards, by normalized code.
+	entries []types.BalanceEntry // Store credit entries.
+}
+
+// CreateGiftCard is a mock method that issues a gift card with its full value as balance.
+func (m *mockBalanceStore) CreateGiftCard(card types.GiftCard, code string) (int, error) {
+	card.ID = len(m.cards) + 1
+	card.Last4 = code[len(code)-4:]
+	card.Balance = card.Value
+	m.cards = append(m.cards, &card)
+	m.codes[NormalizeCode(code)] = card.ID
+	return card.ID, nil
+}
+
+// GetGiftCardByID is a mock method that returns the gift card with the given ID.
+func (m *mockBalanceStore) GetGiftCardByID(id int) (*types.GiftCard, error) {
+	if id < 1 || id > len(m.cards) {
+		return nil, fmt.Errorf("gift card not found")
+	}
+	return m.cards[id-1], nil
+}
+
+// GetGiftCardByCode is a mock method that returns the gift card with the given code.
+func (m *mockBalanceStore) GetGiftCardByCode(code string) (*types.GiftCard, error) {
+	return m.GetGiftCardByID(m.codes[NormalizeCode(code)])
+}
+
+// GetGiftCardLedger is a mock method that returns no entries.
+func (m *mockBalanceStore) GetGiftCardLedger(giftCardID int) ([]types.BalanceEntry, error) {
+	return []types.BalanceEntry{}, nil
+}
+
+// GetStoreCredit is a mock method that sums the store credit entries of a user in euros.
+func (m *mockBalanceStore) GetStoreCredit(userID int) ([]types.Money, error) {
+	balance := types.NewMoney(0, "EUR")
+	for _, e := range m.entries {
+		if *e.UserID == userID {
+			balance.Amount += e.Amount.Amount
+		}
+	}
+	return []types.Money{balance}, nil
+}
+
+// GetStoreCreditLedger is a mock method that returns the store credit entries of a user.
+func (m *mockBalanceStore) GetStoreCreditLedger(userID int) ([]types.BalanceEntry, error) {
+	entries := []types.BalanceEntry{}
+	for _, e := range m.entries {
+		if *e.UserID == userID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// AddStoreCredit is a mock method that appends an entry, unless it would make the balance negative.
+func (m *mockBalanceStore) AddStoreCredit(entry types.BalanceEntry) (int, error) {
+	balances, _ := m.GetStoreCredit(*entry.UserID)
+	if balances[0].Amount+entry.Amount.Amount < 0 {
+		return 0, types.ErrInsufficientBalance
+	}
+
+	entry.ID = len(m.entries) + 1
+	m.entries = append(m.entries, entry)
+	return entry.ID, nil
+}
+
+// mockOrderStore is an in-memory implementation of the parts of the OrderStore interface used by the handler.
+type mockOrderStore struct {
+	types.OrderStore
+	orders map[int]*types.Order // Orders by ID.
+}
+
+// GetOrderByID is a mock method that returns the order with the given ID.
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("order not found")
+	}
+	return o, nil
+}
+
+// mockPricer is an implementation of the parts of the Pricer interface used for gift cards, selling in euros
+// and dollars.
+type mockPricer struct {
+	types.Pricer
+}
+
+// Currencies is a mock method that returns the euro and the dollar.
+func (m *mockPricer) Currencies() []string {
+	return []string{"EUR", "USD"}
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
@@ -0,0 +1,435 @@
+package giftcard
+
+import (
+	// Import the sha256 and hex packages for hashing gift card codes.
+	"crypto/sha256"
+	"encoding/hex"
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	// Import the types package for the gift card types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// giftCardColumns lists the columns of a gift card with its balance, in the order scanRowIntoGiftCard reads them.
+const giftCardColumns = `
+	g.id, g.last4, g.value, g.currency, g.note, g.expiresAt, g.createdAt,
+	(SELECT COALESCE(SUM(e.amount), 0) FROM balance_entries e WHERE e.giftCardId = g.id)`
+
+// errNotFound is returned when there is no gift card with the given ID or code.
+var errNotFound = errors.New("gift card not found")
+
+// entryColumns lists the columns of the balance_entries table, in the order scanRowIntoEntry reads them.
+const entryColumns = "id, giftCardId, userId, orderId, amount, currency, reason, note, createdAt"
+
+// Store struct represents the data store of gift cards and store credit.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db  *sql.DB          // SQL database connection.
+	now func() time.Time // Returns the current time, to tell expired gift cards.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// CreateGiftCard is a method on the Store struct that issues a gift card with an entry for its value, in a
+// single transaction, and returns its ID.
+func (s *Store) CreateGiftCard(card types.GiftCard, code string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"INSERT INTO gift_cards (codeHash, last4, value, currency, note, expiresAt) VALUES (?, ?, ?, ?, ?, ?)",
+		hashCode(code), last4(code), card.Value.Decimal(), card.Value.Currency, card.Note, card.ExpiresAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO balance_entries (giftCardId, amount, currency, reason) VALUES (?, ?, ?, ?)",
+		id, card.Value.Decimal(), card.Value.Currency, types.EntryIssue,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// GetGiftCards is a method on the Store struct that retrieves all gift cards with their balances, newest first.
+func (s *Store) GetGiftCards() ([]*types.GiftCard, error) {
+	rows, err := s.db.Query("SELECT " + giftCardColumns + " FROM gift_cards g ORDER BY g.id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []*types.GiftCard{}
+	for rows.Next() {
+		card, err := scanRowIntoGiftCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+// GetGiftCardByID is a method on the Store struct that retrieves a gift card with its balance by ID.
+func (s *Store) GetGiftCardByID(id int) (*types.GiftCard, error) {
+	return getGiftCard(s.db, "SELECT "+giftCardColumns+" FROM gift_cards g WHERE g.id = ?", id)
+}
+
+// GetGiftCardByCode is a method on the Store struct that retrieves a gift card with its balance by its code.
+func (s *Store) GetGiftCardByCode(code string) (*types.GiftCard, error) {
+	return getGiftCard(s.db, "SELECT "+giftCardColumns+" FROM gift_cards g WHERE g.codeHash = ?", hashCode(code))
+}
+
+// GetGiftCardLedger is a method on the Store struct that retrieves the entries of a gift card, oldest first.
+func (s *Store) GetGiftCardLedger(giftCardID int) ([]types.BalanceEntry, error) {
+	return queryEntries(s.db, "SELECT "+entryColumns+" FROM balance_entries WHERE giftCardId = ? ORDER BY id", giftCardID)
+}
+
+// GetStoreCredit is a method on the Store struct that retrieves the store credit balances of a user, one per
+// currency, sorted by currency.
+func (s *Store) GetStoreCredit(userID int) ([]types.Money, error) {
+	rows, err := s.db.Query(
+		"SELECT currency, SUM(amount) FROM balance_entries WHERE giftCardId IS NULL AND userId = ? GROUP BY currency ORDER BY currency",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []types.Money{}
+	for rows.Next() {
+		var currency, amount string
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return nil, err
+		}
+		balance, err := types.ParseMoney(amount, currency)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+
+	return balances, rows.Err()
+}
+
+// GetStoreCreditLedger is a method on the Store struct that retrieves the store credit entries of a user,
+// oldest first.
+func (s *Store) GetStoreCreditLedger(userID int) ([]types.BalanceEntry, error) {
+	return queryEntries(s.db, "SELECT "+entryColumns+" FROM balance_entries WHERE giftCardId IS NULL AND userId = ? ORDER BY id", userID)
+}
+
+// AddStoreCredit is a method on the Store struct that appends an entry to the store credit of a user. The user
+// is locked while the balance is checked, so concurrent entries cannot take it below zero.
+func (s *Store) AddStoreCredit(entry types.BalanceEntry) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	balance, err := lockStoreCredit(tx, *entry.UserID, entry.Amount.Currency)
+	if err != nil {
+		return 0, err
+	}
+	if balance.Amount+entry.Amount.Amount < 0 {
+		return 0, fmt.Errorf("%w: the store credit is %v", types.ErrInsufficientBalance, balance)
+	}
+
+	entry.GiftCardID = nil
+	id, err := insertEntry(tx, entry)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// ChargeBalances is a method on the Store struct that pays as much of the amount due as the gift cards and the
+// store credit cover, in a single transaction.
+func (s *Store) ChargeBalances(userID int, due types.Money, codes []string, storeCredit bool) ([]types.BalanceCharge, []int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	charges := []types.BalanceCharge{}
+	ids := []int{}
+	remaining := due.Amount
+
+	// charge takes what it can of the remaining amount from a balance.
+	charge := func(giftCardID *int, last4 string, balance types.Money) error {
+		amount := min(balance.Amount, remaining)
+		if amount <= 0 {
+			return nil
+		}
+
+		id, err := insertEntry(tx, types.BalanceEntry{
+			GiftCardID: giftCardID,
+			UserID:     &userID,
+			Amount:     types.NewMoney(-amount, due.Currency),
+			Reason:     types.EntryRedemption,
+		})
+		if err != nil {
+			return err
+		}
+
+		charges = append(charges, types.BalanceCharge{Last4: last4, Amount: types.NewMoney(amount, due.Currency)})
+		if giftCardID != nil {
+			charges[len(charges)-1].GiftCardID = *giftCardID
+		}
+		ids = append(ids, id)
+		remaining -= amount
+		return nil
+	}
+
+	for _, code := range codes {
+		card, err := getGiftCard(tx, "SELECT "+giftCardColumns+" FROM gift_cards g WHERE g.codeHash = ? FOR UPDATE", hashCode(code))
+		switch {
+		case errors.Is(err, errNotFound):
+			return nil, nil, fmt.Errorf("%w: unknown code", types.ErrInvalidGiftCard)
+		case err != nil:
+			return nil, nil, err
+		case card.Expired(s.now()):
+			return nil, nil, fmt.Errorf("%w: the card ending in %s has expired", types.ErrInvalidGiftCard, card.Last4)
+		case card.Value.Currency != due.Currency:
+			return nil, nil, fmt.Errorf("%w: the card ending in %s is in %s", types.ErrInvalidGiftCard, card.Last4, card.Value.Currency)
+		}
+
+		if err := charge(&card.ID, card.Last4, card.Balance); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if storeCredit && remaining > 0 {
+		balance, err := lockStoreCredit(tx, userID, due.Currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := charge(nil, "", balance); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return charges, ids, tx.Commit()
+}
+
+// SetEntriesOrder is a method on the Store struct that links entries to the order they were made for.
+func (s *Store) SetEntriesOrder(entryIDs []int, orderID int) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+
+	query, args := inClause("UPDATE balance_entries SET orderId = ? WHERE orderId IS NULL AND id IN", entryIDs)
+	_, err := s.db.Exec(query, append([]any{orderID}, args...)...)
+	return err
+}
+
+// ReverseEntries is a method on the Store struct that appends entries cancelling out the given ones, in a
+// single transaction.
+func (s *Store) ReverseEntries(entryIDs []int) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query, args := inClause("SELECT "+entryColumns+" FROM balance_entries WHERE id IN", entryIDs)
+	entries, err := queryEntries(tx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		e.Amount = e.Amount.Neg()
+		e.Reason = types.EntryReversal
+		e.Note = fmt.Sprintf("reverses entry %d", e.ID)
+		if _, err := insertEntry(tx, e); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// getGiftCard runs a query selecting giftCardColumns of a single gift card, inside a transaction or not.
+func getGiftCard(q querier, query string, args ...any) (*types.GiftCard, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errNotFound
+	}
+
+	return scanRowIntoGiftCard(rows)
+}
+
+// queryEntries runs a query selecting entryColumns and returns the entries.
+func queryEntries(q querier, query string, args ...any) ([]types.BalanceEntry, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []types.BalanceEntry{}
+	for rows.Next() {
+		e, err := scanRowIntoEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+
+	return entries, rows.Err()
+}
+
+// lockStoreCredit locks a user, so no other transaction changes their store credit, and returns the balance in
+// a currency. It must run inside a transaction.
+func lockStoreCredit(tx *sql.Tx, userID int, currency string) (types.Money, error) {
+	var id int
+	err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return types.Money{}, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return types.Money{}, err
+	}
+
+	var amount string
+	err = tx.QueryRow(
+		"SELECT COALESCE(SUM(amount), 0) FROM balance_entries WHERE giftCardId IS NULL AND userId = ? AND currency = ?",
+		userID, currency,
+	).Scan(&amount)
+	if err != nil {
+		return types.Money{}, err
+	}
+
+	return types.ParseMoney(amount, currency)
+}
+
+// insertEntry appends an entry to the ledger and returns its ID. It must run inside a transaction.
+func insertEntry(tx *sql.Tx, e types.BalanceEntry) (int, error) {
+	res, err := tx.Exec(
+		"INSERT INTO balance_entries (giftCardId, userId, orderId, amount, currency, reason, note) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		e.GiftCardID, e.UserID, e.OrderID, e.Amount.Decimal(), e.Amount.Currency, e.Reason, e.Note,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// scanRowIntoGiftCard scans a row selected with giftCardColumns into a GiftCard.
+func scanRowIntoGiftCard(rows *sql.Rows) (*types.GiftCard, error) {
+	card := new(types.GiftCard)
+	var value, currency, balance string
+	var expiresAt sql.NullTime
+
+	err := rows.Scan(&card.ID, &card.Last4, &value, &currency, &card.Note, &expiresAt, &card.CreatedAt, &balance)
+	if err != nil {
+		return nil, err
+	}
+
+	if card.Value, err = types.ParseMoney(value, currency); err != nil {
+		return nil, err
+	}
+	if card.Balance, err = types.ParseMoney(balance, currency); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		card.ExpiresAt = &expiresAt.Time
+	}
+
+	return card, nil
+}
+
+// scanRowIntoEntry scans a row selected with entryColumns into a BalanceEntry.
+func scanRowIntoEntry(rows *sql.Rows) (*types.BalanceEntry, error) {
+	e := new(types.BalanceEntry)
+	var giftCardID, userID, orderID sql.NullInt64
+	var amount, currency string
+
+	err := rows.Scan(&e.ID, &giftCardID, &userID, &orderID, &amount, &currency, &e.Reason, &e.Note, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Amount, err = types.ParseMoney(amount, currency); err != nil {
+		return nil, err
+	}
+	e.GiftCardID = nullInt(giftCardID)
+	e.UserID = nullInt(userID)
+	e.OrderID = nullInt(orderID)
+
+	return e, nil
+}
+
+// nullInt returns a nullable integer column as a pointer, nil for NULL.
+func nullInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+
+	i := int(n.Int64)
+	return &i
+}
+
+// hashCode returns the hex-encoded SHA-256 hash of a normalized code, the form codes are stored in.
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(NormalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// inClause appends a placeholder list for the IDs to a query ending in IN.
+func inClause(query string, ids []int) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return query + " (?" + strings.Repeat(", ?", len(ids)-1) + ")", args
+}
+
+// last4 returns the last four characters of a normalized code.
+func last4(code string) string {
+	code = NormalizeCode(code)
+	return code[max(len(code)-4, 0):]
+}
@@ -0,0 +1,288 @@
+package history
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	// Import the auth package for the authentication middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the currency package to select the currency of a request.
+	"github.com/FreekAlberti/Ecom/cmd/service/currency"
+	// Import the types package for the store interfaces and the order types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle the order history of customers.
+type Handler struct {
+	orders    types.OrderStore           // Interface for the orders.
+	shipments types.ShipmentStore        // Interface for the packages of orders.
+	returns   types.ReturnStore          // Interface for the returns of orders.
+	carts     types.CartPricer           // Prices carts at the current prices, with their promotions.
+	products  types.ProductStore         // Interface for the products, to tell whether a SKU is still sold.
+	variants  types.VariantStore         // Interface for the variants of the products.
+	inventory types.InventoryStore       // Interface for the stock available to sell.
+	policies  types.BackorderPolicyStore // Interface for the backorder and preorder policies.
+	pricer    types.Pricer               // Selects the currency of the customer.
+	userStore types.UserStore            // Interface for user-related data operations, used to authenticate customers.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(orders types.OrderStore, shipments types.ShipmentStore, returns types.ReturnStore, carts types.CartPricer, products types.ProductStore, variants types.VariantStore, inventory types.InventoryStore, policies types.BackorderPolicyStore, pricer types.Pricer, userStore types.UserStore) *Handler {
+	return &Handler{
+		orders:    orders,
+		shipments: shipments,
+		returns:   returns,
+		carts:     carts,
+		products:  products,
+		variants:  variants,
+		inventory: inventory,
+		policies:  policies,
+		pricer:    pricer,
+		userStore: userStore,
+	}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the order history routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// List the orders of the user, and show one with its packages and returns.
+	router.HandleFunc("/me/orders", auth.WithAuth(h.handleGetMyOrders, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/me/orders/{id:[0-9]+}", auth.WithAuth(h.handleGetMyOrder, h.userStore)).Methods(http.MethodGet)
+
+	// Fill a cart with the items of a past order.
+	router.HandleFunc("/me/orders/{id:[0-9]+}/reorder", auth.WithAuth(h.handleReorder, h.userStore)).Methods(http.MethodPost)
+}
+
+// handleGetMyOrders handles GET /me/orders.
+// It lists the orders of the user, newest first, with their items. It supports the query parameters status
+// (comma-separated statuses, such as shipped,delivered), limit and cursor.
+func (h *Handler) handleGetMyOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter.UserID = auth.GetUserIDFromContext(r.Context())
+
+	orders, next, err := h.orders.ListOrders(filter)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"orders":     orders,
+		"nextCursor": next,
+	})
+}
+
+// parseOrderFilter builds an OrderFilter from the query string of a request, without the user.
+func parseOrderFilter(r *http.Request) (types.OrderFilter, error) {
+	q := r.URL.Query()
+	filter := types.OrderFilter{Statuses: []string{}, Cursor: q.Get("cursor")}
+
+	for _, status := range strings.Split(q.Get("status"), ",") {
+		if status == "" {
+			continue
+		}
+		if !slices.Contains(types.OrderStatuses, status) {
+			return filter, fmt.Errorf("invalid status %q", status)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("invalid limit %q", v)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// handleGetMyOrder handles GET /me/orders/{id}.
+// It shows an order of the user with its packages and returns. Orders of other users are reported as not found,
+// so their IDs cannot be probed.
+func (h *Handler) handleGetMyOrder(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.myOrder(w, r)
+	if !ok {
+		return
+	}
+
+	shipments, err := h.shipments.GetShipmentsByOrder(o.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	returns, err := h.returns.GetReturnsByOrder(o.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, types.OrderDetail{Order: o, Shipments: shipments, Returns: returns})
+}
+
+// handleReorder handles POST /me/orders/{id}/reorder.
+// It rebuilds the cart of a past order of the user, shipping to the same address, and prices it in the currency
+// of the request at the current prices and promotions. SKUs no longer sold are left out. SKUs short of stock are
+// kept in full if their backorder or preorder policy would accept them at checkout, cut to the stock left if not,
+// and left out if there is none. Every SKU of the order is listed with what became of it. Nothing is reserved:
+// the cart is checked out as any other.
+func (h *Handler) handleReorder(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.myOrder(w, r)
+	if !ok {
+		return
+	}
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reorder := types.Reorder{
+		OrderID: o.ID,
+		Lines:   []types.ReorderLine{},
+		Cart: types.CheckoutPayload{
+			Items:     []types.OrderLine{},
+			Codes:     []string{},
+			GiftCards: []string{},
+			VATID:     o.VATID,
+		},
+	}
+	if o.Address != nil {
+		address := *o.Address
+		reorder.Cart.Address = &address
+	}
+
+	// lines maps each item in the cart to its line in the reorder.
+	lines := []int{}
+	for _, line := range orderedLines(o) {
+		if err := h.fill(&line); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		reorder.Lines = append(reorder.Lines, line)
+		if line.Quantity > 0 {
+			reorder.Cart.Items = append(reorder.Cart.Items, types.OrderLine{SKU: line.SKU, Quantity: line.Quantity})
+			lines = append(lines, len(reorder.Lines)-1)
+		}
+	}
+
+	if len(reorder.Cart.Items) > 0 {
+		pricing, err := h.carts.PriceCart(userID, cur, reorder.Cart.Items, nil)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for i, priced := range pricing.Lines {
+			price := priced.UnitPrice
+			reorder.Lines[lines[i]].UnitPrice = &price
+		}
+		reorder.Pricing = pricing
+	}
+
+	utils.WriteJSON(w, http.StatusOK, reorder)
+}
+
+// orderedLines returns the SKUs of an order with the quantity ordered of each, in the order they were first
+// ordered, and the price of one unit.
+func orderedLines(o *types.Order) []types.ReorderLine {
+	lines := []types.ReorderLine{}
+	index := map[string]int{}
+	for _, item := range o.Items {
+		if i, ok := index[item.SKU]; ok {
+			lines[i].Ordered += item.Quantity
+			continue
+		}
+		index[item.SKU] = len(lines)
+		lines = append(lines, types.ReorderLine{SKU: item.SKU, Ordered: item.Quantity, PreviousUnitPrice: item.UnitPrice})
+	}
+
+	return lines
+}
+
+// fill sets the quantity of a line that can be ordered again, and the status saying why. A SKU that is neither a
+// variant nor a product is no longer sold.
+func (h *Handler) fill(line *types.ReorderLine) error {
+	_, err := h.variants.GetVariantBySKU(line.SKU)
+	if errors.Is(err, types.ErrVariantNotFound) {
+		_, err = h.products.GetProductBySKU(line.SKU)
+	}
+	if errors.Is(err, types.ErrProductNotFound) {
+		line.Status = types.ReorderDiscontinued
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	available, err := h.inventory.AvailableToSell(line.SKU)
+	if err != nil {
+		return err
+	}
+	policy, err := h.policies.GetBackorderPolicy(line.SKU)
+	if err != nil {
+		return err
+	}
+
+	// Checkout holds stock for the full quantity or accepts all of it under the policy, so the policy is checked
+	// for the full quantity too. Preorders always wait for the release, whatever the stock.
+	switch {
+	case policy != nil && policy.Mode == types.PolicyPreorder:
+		line.Quantity, line.Status, line.ExpectedAt = line.Ordered, types.ReorderPreorder, policy.ExpectedAt
+		if !accepts(policy, line.Ordered) {
+			line.Quantity, line.Status, line.ExpectedAt = 0, types.ReorderOutOfStock, nil
+		}
+	case available >= line.Ordered:
+		line.Quantity, line.Status = line.Ordered, types.ReorderAdded
+	case policy != nil && accepts(policy, line.Ordered):
+		line.Quantity, line.Status, line.ExpectedAt = line.Ordered, types.ReorderBackorder, policy.ExpectedAt
+	case available > 0:
+		line.Quantity, line.Status = available, types.ReorderReduced
+	default:
+		line.Quantity, line.Status = 0, types.ReorderOutOfStock
+	}
+
+	return nil
+}
+
+// accepts reports whether a backorder or preorder policy has room left for a quantity.
+func accepts(policy *types.BackorderPolicy, quantity int) bool {
+	return policy.Limit == 0 || policy.Waiting+quantity <= policy.Limit
+}
+
+// myOrder returns the order in the URL if it belongs to the authenticated user. It writes a not found error if the
+// order does not exist or belongs to someone else, and an internal server error if it could not be read.
+func (h *Handler) myOrder(w http.ResponseWriter, r *http.Request) (*types.Order, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	o, err := h.orders.GetOrderByID(id)
+	if err != nil && !errors.Is(err, types.ErrOrderNotFound) {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	if err != nil || o.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, types.ErrOrderNotFound)
+		return nil, false
+	}
+
+	return o, true
+}
@@ -0,0 +1,414 @@
+package history
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"slices"            // Import the slices package to filter on statuses
+	"strconv"           // Import the strconv package for the mock cursors
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for expected dates
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for order types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestHistoryHandlers tests listing, showing and reordering the orders of a customer.
+func TestHistoryHandlers(t *testing.T) {
+	eur := func(amount int64) types.Money { return types.NewMoney(amount, "EUR") }
+	expected := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Create the stores the history is read from. Jane (ID 2) has a delivered order of everything the shop sold
+	// (order 1), a shipped order (order 2) and a cancelled order of a SKU no longer sold (order 3); another customer
+	// (ID 3) has order 4. Coffee went up from 10.90 to 11.50 since order 1.
+	orders := &mockOrderStore{orders: map[int]*types.Order{
+		1: {ID: 1, UserID: 2, Status: types.OrderDelivered, Address: &types.Address{Country: "NL", PostalCode: "1011 AB"}, VATID: "NL123456789B01", Items: []types.OrderItem{
+			{ID: 11, SKU: "COFFEE", Quantity: 2, UnitPrice: eur(1090)},
+			{ID: 12, SKU: "MUG", Quantity: 1, UnitPrice: eur(1210)},
+			{ID: 13, SKU: "COFFEE", Quantity: 1, UnitPrice: eur(1090)},
+			{ID: 14, SKU: "OLD", Quantity: 1, UnitPrice: eur(500)},
+			{ID: 15, SKU: "TEA", Quantity: 3, UnitPrice: eur(400)},
+			{ID: 16, SKU: "BEANS", Quantity: 2, UnitPrice: eur(800)},
+			{ID: 17, SKU: "KETTLE", Quantity: 1, UnitPrice: eur(4000)},
+			{ID: 18, SKU: "GRINDER", Quantity: 1, UnitPrice: eur(9000)},
+			{ID: 19, SKU: "FILTER", Quantity: 4, UnitPrice: eur(300)},
+		}},
+		2: {ID: 2, UserID: 2, Status: types.OrderShipped, Items: []types.OrderItem{{ID: 21, SKU: "MUG", Quantity: 1, UnitPrice: eur(1210)}}},
+		3: {ID: 3, UserID: 2, Status: types.OrderCancelled, Items: []types.OrderItem{{ID: 31, SKU: "OLD", Quantity: 2, UnitPrice: eur(500)}}},
+		4: {ID: 4, UserID: 3, Status: types.OrderPending, Items: []types.OrderItem{{ID: 41, SKU: "MUG", Quantity: 1, UnitPrice: eur(1210)}}},
+	}}
+	shipments := &mockShipmentStore{shipments: []*types.Shipment{{ID: 1, OrderID: 1, Carrier: "dhl", Status: types.ShipmentDelivered}}}
+	returns := &mockReturnStore{returns: []*types.Return{{ID: 1, OrderID: 1, Status: types.ReturnRequested}}}
+	carts := &mockCartPricer{prices: map[string]types.Money{
+		"COFFEE": eur(1150), "MUG": eur(1210), "TEA": eur(450), "BEANS": eur(800), "KETTLE": eur(4000), "GRINDER": eur(9000), "FILTER": eur(300),
+	}}
+	products := &mockProductStore{skus: []string{"COFFEE", "TEA", "BEANS", "KETTLE", "GRINDER", "FILTER"}}
+	variants := &mockVariantStore{skus: []string{"MUG"}}
+	inventory := &mockInventoryStore{available: map[string]int{"COFFEE": 10, "MUG": 10, "TEA": 2, "KETTLE": 3}}
+	policies := &mockPolicyStore{policies: map[string]*types.BackorderPolicy{
+		"BEANS":   {SKU: "BEANS", Mode: types.PolicyBackorder, Limit: 10, Waiting: 8, ExpectedAt: &expected},
+		"KETTLE":  {SKU: "KETTLE", Mode: types.PolicyPreorder, ExpectedAt: &expected},
+		"GRINDER": {SKU: "GRINDER", Mode: types.PolicyPreorder, Limit: 5, Waiting: 5},
+	}}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		2: {ID: 2, Role: types.RoleCustomer},
+		3: {ID: 3, Role: types.RoleCustomer},
+	}}
+
+	// Initialize a handler and register its routes.
+	router := mux.NewRouter()
+	NewHandler(orders, shipments, returns, carts, products, variants, inventory, policies, &mockPricer{}, userStore).RegisterRoutes(router)
+
+	// list returns the IDs of the orders listed at the target, and the cursor of the next page.
+	list := func(t *testing.T, router *mux.Router, target string) ([]int, string) {
+		t.Helper()
+
+		rr := send(t, router, http.MethodGet, target, "", 2)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var page struct {
+			Orders     []*types.Order `json:"orders"`
+			NextCursor string         `json:"nextCursor"`
+		}
+		json.NewDecoder(rr.Body).Decode(&page)
+
+		ids := []int{}
+		for _, o := range page.Orders {
+			ids = append(ids, o.ID)
+		}
+		return ids, page.NextCursor
+	}
+
+	t.Run("should list the orders of the customer by page and status", func(t *testing.T) {
+		if ids, next := list(t, router, "/me/orders"); !slices.Equal(ids, []int{3, 2, 1}) || next != "" {
+			t.Errorf("expected the orders of Jane, newest first, got %v (next %q)", ids, next)
+		}
+		if ids, _ := list(t, router, "/me/orders?status=shipped,delivered"); !slices.Equal(ids, []int{2, 1}) {
+			t.Errorf("expected the shipped and delivered orders, got %v", ids)
+		}
+
+		ids, next := list(t, router, "/me/orders?limit=2")
+		if !slices.Equal(ids, []int{3, 2}) || next == "" {
+			t.Fatalf("expected the first page of 2 orders, got %v (next %q)", ids, next)
+		}
+		if ids, next := list(t, router, "/me/orders?limit=2&cursor="+next); !slices.Equal(ids, []int{1}) || next != "" {
+			t.Errorf("expected the last page, got %v (next %q)", ids, next)
+		}
+
+		for _, target := range []string{"/me/orders?status=lost", "/me/orders?limit=0", "/me/orders?limit=many", "/me/orders?cursor=garbage"} {
+			if rr := send(t, router, http.MethodGet, target, "", 2); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, target, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should show an order with its shipments and returns", func(t *testing.T) {
+		rr := send(t, router, http.MethodGet, "/me/orders/1", "", 2)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var detail types.OrderDetail
+		json.NewDecoder(rr.Body).Decode(&detail)
+		if detail.Order == nil || detail.ID != 1 || len(detail.Items) != 9 || len(detail.Shipments) != 1 || len(detail.Returns) != 1 {
+			t.Errorf("expected order 1 with its package and return, got %+v", detail)
+		}
+
+		if rr := send(t, router, http.MethodGet, "/me/orders/4", "", 2); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for the order of another customer, got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/me/orders/9", "", 2); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for an order that does not exist, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should not hide a failure to read the order as not found", func(t *testing.T) {
+		orders.fail = fmt.Errorf("connection refused")
+		defer func() { orders.fail = nil }()
+
+		for _, target := range []string{"/me/orders/1", "/me/orders/4"} {
+			if rr := send(t, router, http.MethodGet, target, "", 2); rr.Code != http.StatusInternalServerError {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusInternalServerError, target, rr.Code)
+			}
+		}
+		if rr := send(t, router, http.MethodPost, "/me/orders/1/reorder", "", 2); rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code %d for the reorder, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+
+	t.Run("should rebuild the cart of an order at the current prices", func(t *testing.T) {
+		rr := send(t, router, http.MethodPost, "/me/orders/1/reorder", "", 2)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var reorder types.Reorder
+		json.NewDecoder(rr.Body).Decode(&reorder)
+
+		price := func(amount int64) *types.Money { m := eur(amount); return &m }
+		expectedLines := []types.ReorderLine{
+			{SKU: "COFFEE", Ordered: 3, Quantity: 3, Status: types.ReorderAdded, PreviousUnitPrice: eur(1090), UnitPrice: price(1150)},
+			{SKU: "MUG", Ordered: 1, Quantity: 1, Status: types.ReorderAdded, PreviousUnitPrice: eur(1210), UnitPrice: price(1210)},
+			{SKU: "OLD", Ordered: 1, Quantity: 0, Status: types.ReorderDiscontinued, PreviousUnitPrice: eur(500)},
+			{SKU: "TEA", Ordered: 3, Quantity: 2, Status: types.ReorderReduced, PreviousUnitPrice: eur(400), UnitPrice: price(450)},
+			{SKU: "BEANS", Ordered: 2, Quantity: 2, Status: types.ReorderBackorder, PreviousUnitPrice: eur(800), UnitPrice: price(800), ExpectedAt: &expected},
+			{SKU: "KETTLE", Ordered: 1, Quantity: 1, Status: types.ReorderPreorder, PreviousUnitPrice: eur(4000), UnitPrice: price(4000), ExpectedAt: &expected},
+			{SKU: "GRINDER", Ordered: 1, Quantity: 0, Status: types.ReorderOutOfStock, PreviousUnitPrice: eur(9000)},
+			{SKU: "FILTER", Ordered: 4, Quantity: 0, Status: types.ReorderOutOfStock, PreviousUnitPrice: eur(300)},
+		}
+		if len(reorder.Lines) != len(expectedLines) {
+			t.Fatalf("expected %d lines, got %+v", len(expectedLines), reorder.Lines)
+		}
+		for i, line := range reorder.Lines {
+			e := expectedLines[i]
+			if line.SKU != e.SKU || line.Ordered != e.Ordered || line.Quantity != e.Quantity || line.Status != e.Status || line.PreviousUnitPrice != e.PreviousUnitPrice ||
+				(line.UnitPrice == nil) != (e.UnitPrice == nil) || (line.UnitPrice != nil && *line.UnitPrice != *e.UnitPrice) ||
+				(line.ExpectedAt == nil) != (e.ExpectedAt == nil) || (line.ExpectedAt != nil && !line.ExpectedAt.Equal(*e.ExpectedAt)) {
+				t.Errorf("expected line %d to be %+v, got %+v", i, e, line)
+			}
+		}
+
+		cart := []string{}
+		for _, item := range reorder.Cart.Items {
+			cart = append(cart, fmt.Sprintf("%s:%d", item.SKU, item.Quantity))
+		}
+		if strings.Join(cart, ",") != "COFFEE:3,MUG:1,TEA:2,BEANS:2,KETTLE:1" {
+			t.Errorf("expected the cart to hold what can be ordered, got %v", cart)
+		}
+		if reorder.Cart.Address == nil || reorder.Cart.Address.PostalCode != "1011 AB" || reorder.Cart.VATID != "NL123456789B01" {
+			t.Errorf("expected the cart to ship to the address of the order, got %+v", reorder.Cart)
+		}
+		if reorder.Pricing == nil || reorder.Pricing.Total != eur(11160) {
+			t.Errorf("expected the cart to be priced at 111.60, got %+v", reorder.Pricing)
+		}
+	})
+
+	t.Run("should leave out everything no longer sold", func(t *testing.T) {
+		rr := send(t, router, http.MethodPost, "/me/orders/3/reorder", "", 2)
+		var reorder types.Reorder
+		json.NewDecoder(rr.Body).Decode(&reorder)
+		if rr.Code != http.StatusOK || len(reorder.Cart.Items) != 0 || reorder.Pricing != nil || len(reorder.Lines) != 1 || reorder.Lines[0].Status != types.ReorderDiscontinued {
+			t.Errorf("expected an empty cart, got %d: %+v", rr.Code, reorder)
+		}
+
+		if rr := send(t, router, http.MethodPost, "/me/orders/4/reorder", "", 2); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for the order of another customer, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockOrderStore is an in-memory implementation of the parts of the OrderStore interface used by the handler.
+type mockOrderStore struct {
+	types.OrderStore
+	orders map[int]*types.Order // Orders by ID.
+	fail   error                // Error returned when reading an order, if set.
+}
+
+// GetOrderByID is a mock method that returns a copy of the order with the given ID.
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	if m.fail != nil {
+		return nil, m.fail
+	}
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, types.ErrOrderNotFound
+	}
+
+	c := *o
+	return &c, nil
+}
+
+// ListOrders is a mock method that pages through the orders of a user, newest first. The cursor is the ID of the
+// last order of the previous page.
+func (m *mockOrderStore) ListOrders(filter types.OrderFilter) ([]*types.Order, string, error) {
+	after := len(m.orders) + 1
+	if filter.Cursor != "" {
+		id, err := strconv.Atoi(filter.Cursor)
+		if err != nil {
+			return nil, "", types.ErrInvalidCursor
+		}
+		after = id
+	}
+	limit := filter.Limit
+	if limit == 0 {
+		limit = 20
+	}
+
+	orders := []*types.Order{}
+	for id := after - 1; id > 0; id-- {
+		o := m.orders[id]
+		if o.UserID != filter.UserID || (len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, o.Status)) {
+			continue
+		}
+		if len(orders) == limit {
+			return orders, strconv.Itoa(orders[len(orders)-1].ID), nil
+		}
+		orders = append(orders, o)
+	}
+
+	return orders, "", nil
+}
+
+// mockShipmentStore is an in-memory implementation of the parts of the ShipmentStore interface used by the handler.
+type mockShipmentStore struct {
+	types.ShipmentStore
+	shipments []*types.Shipment // Shipments, oldest first.
+}
+
+// GetShipmentsByOrder is a mock method that returns the shipments of an order.
+func (m *mockShipmentStore) GetShipmentsByOrder(orderID int) ([]*types.Shipment, error) {
+	shipments := []*types.Shipment{}
+	for _, sh := range m.shipments {
+		if sh.OrderID == orderID {
+			shipments = append(shipments, sh)
+		}
+	}
+	return shipments, nil
+}
+
+// mockReturnStore is an in-memory implementation of the parts of the ReturnStore interface used by the handler.
+type mockReturnStore struct {
+	types.ReturnStore
+	returns []*types.Return // Returns, oldest first.
+}
+
+// GetReturnsByOrder is a mock method that returns the returns of an order.
+func (m *mockReturnStore) GetReturnsByOrder(orderID int) ([]*types.Return, error) {
+	returns := []*types.Return{}
+	for _, ret := range m.returns {
+		if ret.OrderID == orderID {
+			returns = append(returns, ret)
+		}
+	}
+	return returns, nil
+}
+
+// mockCartPricer is an implementation of the CartPricer interface that prices lines from a price list, without
+// promotions.
+type mockCartPricer struct {
+	prices map[string]types.Money // Current prices by SKU; other SKUs are not sold.
+}
+
+// PriceCart is a mock method that prices the items at their current prices.
+func (m *mockCartPricer) PriceCart(userID int, currency string, items []types.OrderLine, codes []string) (*types.CartPricing, error) {
+	pricing := &types.CartPricing{Currency: currency, Lines: []types.PricedLine{}, Subtotal: types.NewMoney(0, currency)}
+	for _, item := range items {
+		price, ok := m.prices[item.SKU]
+		if !ok {
+			return nil, fmt.Errorf("%w %s", types.ErrUnknownSKU, item.SKU)
+		}
+		subtotal := types.NewMoney(price.Amount*int64(item.Quantity), currency)
+		pricing.Lines = append(pricing.Lines, types.PricedLine{
+			SKU: item.SKU, Quantity: item.Quantity, UnitPrice: price, Subtotal: subtotal, Discount: types.NewMoney(0, currency), Total: subtotal,
+		})
+		pricing.Subtotal.Amount += subtotal.Amount
+	}
+	pricing.Discount, pricing.Total = types.NewMoney(0, currency), pricing.Subtotal
+
+	return pricing, nil
+}
+
+// mockProductStore is an implementation of the parts of the ProductStore interface used by the handler.
+type mockProductStore struct {
+	types.ProductStore
+	skus []string // SKUs of the products.
+}
+
+// GetProductBySKU is a mock method that returns the product with the given SKU.
+func (m *mockProductStore) GetProductBySKU(sku string) (*types.Product, error) {
+	if !slices.Contains(m.skus, sku) {
+		return nil, types.ErrProductNotFound
+	}
+	return &types.Product{SKU: sku}, nil
+}
+
+// mockVariantStore is an implementation of the parts of the VariantStore interface used by the handler.
+type mockVariantStore struct {
+	types.VariantStore
+	skus []string // SKUs of the variants.
+}
+
+// GetVariantBySKU is a mock method that returns the variant with the given SKU.
+func (m *mockVariantStore) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	if !slices.Contains(m.skus, sku) {
+		return nil, types.ErrVariantNotFound
+	}
+	return &types.ProductVariant{SKU: sku}, nil
+}
+
+// mockInventoryStore is an implementation of the parts of the InventoryStore interface used by the handler.
+type mockInventoryStore struct {
+	types.InventoryStore
+	available map[string]int // Quantity available to sell by SKU.
+}
+
+// AvailableToSell is a mock method that returns the quantity available to sell of a SKU.
+func (m *mockInventoryStore) AvailableToSell(sku string) (int, error) {
+	return m.available[sku], nil
+}
+
+// mockPolicyStore is an implementation of the parts of the BackorderPolicyStore interface used by the handler.
+type mockPolicyStore struct {
+	types.BackorderPolicyStore
+	policies map[string]*types.BackorderPolicy // Policies by SKU.
+}
+
+// GetBackorderPolicy is a mock method that returns the policy of a SKU, or nil if it has none.
+func (m *mockPolicyStore) GetBackorderPolicy(sku string) (*types.BackorderPolicy, error) {
+	return m.policies[sku], nil
+}
+
+// mockPricer is an implementation of the parts of the Pricer interface used by the handler.
+type mockPricer struct {
+	types.Pricer
+}
+
+// SelectCurrency is a mock method that selects euros, the only currency.
+func (m *mockPricer) SelectCurrency(requested string, user *types.User) (string, error) {
+	if requested != "" && requested != "EUR" {
+		return "", types.ErrUnsupportedCurrency
+	}
+	return "EUR", nil
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
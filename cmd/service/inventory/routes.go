@@ -0,0 +1,329 @@
+package inventory
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	// Import the auth package for the admin-only middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Default and maximum number of ledger entries returned by the movements endpoint.
+const (
+	defaultMovementsLimit = 50
+	maxMovementsLimit     = 500
+)
+
+// Handler struct groups the methods that handle inventory requests.
+type Handler struct {
+	store     types.InventoryStore       // Interface for inventory data operations.
+	policies  types.BackorderPolicyStore // Interface for the backorder and preorder policies.
+	userStore types.UserStore            // Interface for user-related data operations, used to authenticate admins.
+	allocator types.StockAllocator       // Gives received stock to waiting orders, nil to leave it unallocated.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.InventoryStore, policies types.BackorderPolicyStore, userStore types.UserStore, allocator types.StockAllocator) *Handler {
+	return &Handler{store: store, policies: policies, userStore: userStore, allocator: allocator}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the inventory routes.
+// Only the available to sell quantity is public; everything else is wrapped in auth.WithAdminAuth.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Quantity of a SKU that can still be sold.
+	router.HandleFunc("/inventory/{sku}", h.handleGetAvailable).Methods(http.MethodGet)
+
+	// Manage warehouses.
+	router.HandleFunc("/admin/warehouses", auth.WithAdminAuth(h.handleGetWarehouses, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/warehouses", auth.WithAdminAuth(h.handleCreateWarehouse, h.userStore)).Methods(http.MethodPost)
+
+	// Record stock movements and transfers.
+	router.HandleFunc("/admin/inventory/movements", auth.WithAdminAuth(h.handleRecordMovement, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/inventory/transfers", auth.WithAdminAuth(h.handleTransfer, h.userStore)).Methods(http.MethodPost)
+
+	// Inspect the stock levels and ledger of a SKU.
+	router.HandleFunc("/admin/inventory/{sku}", auth.WithAdminAuth(h.handleGetStock, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/inventory/{sku}/movements", auth.WithAdminAuth(h.handleGetMovements, h.userStore)).Methods(http.MethodGet)
+
+	// Manage whether a SKU can be backordered or preordered.
+	router.HandleFunc("/admin/inventory/{sku}/backorder-policy", auth.WithAdminAuth(h.handleGetPolicy, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/inventory/{sku}/backorder-policy", auth.WithAdminAuth(h.handleSavePolicy, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/inventory/{sku}/backorder-policy", auth.WithAdminAuth(h.handleDeletePolicy, h.userStore)).Methods(http.MethodDelete)
+}
+
+// handleGetAvailable handles GET /inventory/{sku}.
+// Along with the quantity available to sell, it tells whether the SKU can be backordered or preordered,
+// and when stock is expected.
+func (h *Handler) handleGetAvailable(w http.ResponseWriter, r *http.Request) {
+	sku := mux.Vars(r)["sku"]
+
+	available, err := h.store.AvailableToSell(sku)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	policy, err := h.policies.GetBackorderPolicy(sku)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Never show a negative quantity, e.g. after a stock count correction.
+	res := map[string]any{
+		"sku":       sku,
+		"available": max(available, 0),
+	}
+	if policy != nil {
+		res["backorder"] = policy.Mode
+		res["expectedAt"] = policy.ExpectedAt
+	}
+
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// handleGetWarehouses handles GET /admin/warehouses.
+func (h *Handler) handleGetWarehouses(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.store.GetWarehouses()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, warehouses)
+}
+
+// handleCreateWarehouse handles POST /admin/warehouses.
+func (h *Handler) handleCreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	var payload types.CreateWarehousePayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	id, err := h.store.CreateWarehouse(types.Warehouse{
+		Code:     payload.Code,
+		Name:     payload.Name,
+		Priority: payload.Priority,
+		Location: payload.Location,
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": id})
+}
+
+// handleRecordMovement handles POST /admin/inventory/movements.
+// Receipts and returns must add stock, sales must remove it, and adjustments can do either.
+func (h *Handler) handleRecordMovement(w http.ResponseWriter, r *http.Request) {
+	var payload types.RecordMovementPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	m := types.StockMovement{
+		WarehouseID: payload.WarehouseID,
+		SKU:         payload.SKU,
+		Type:        payload.Type,
+		Quantity:    payload.Quantity,
+		Reference:   payload.Reference,
+		ActorID:     auth.GetUserIDFromContext(r.Context()),
+	}
+	if err := validateMovement(m); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.RecordMovement(m); err != nil {
+		writeStockError(w, err)
+		return
+	}
+
+	// Give incoming stock to the orders waiting for it, oldest first. The movement is already recorded,
+	// so a failure here is only logged; the next receipt retries the allocation.
+	if m.Quantity > 0 && h.allocator != nil {
+		if _, err := h.allocator.AllocateStock(m.SKU); err != nil {
+			log.Printf("failed to allocate received stock of %s: %v", m.SKU, err)
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, nil)
+}
+
+// handleTransfer handles POST /admin/inventory/transfers.
+func (h *Handler) handleTransfer(w http.ResponseWriter, r *http.Request) {
+	var payload types.TransferPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	err := h.store.Transfer(types.StockTransfer{
+		FromWarehouseID: payload.FromWarehouseID,
+		ToWarehouseID:   payload.ToWarehouseID,
+		SKU:             payload.SKU,
+		Quantity:        payload.Quantity,
+		Reference:       payload.Reference,
+		ActorID:         auth.GetUserIDFromContext(r.Context()),
+	})
+	if err != nil {
+		writeStockError(w, err)
+		return
+	}
+
+	// Orders wait for stock no single warehouse has whole, so moving stock can let them ship. As with receipts,
+	// a failure is only logged.
+	if h.allocator != nil {
+		if _, err := h.allocator.AllocateStock(payload.SKU); err != nil {
+			log.Printf("failed to allocate moved stock of %s: %v", payload.SKU, err)
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, nil)
+}
+
+// handleGetStock handles GET /admin/inventory/{sku}.
+// It returns the stock level of the SKU in every warehouse, along with the quantity available to sell.
+func (h *Handler) handleGetStock(w http.ResponseWriter, r *http.Request) {
+	sku := mux.Vars(r)["sku"]
+
+	levels, err := h.store.GetStockLevels(sku)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	available, err := h.store.AvailableToSell(sku)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"sku":       sku,
+		"levels":    levels,
+		"available": available,
+	})
+}
+
+// handleGetMovements handles GET /admin/inventory/{sku}/movements.
+// It returns the most recent ledger entries of the SKU, newest first, up to the limit query parameter.
+func (h *Handler) handleGetMovements(w http.ResponseWriter, r *http.Request) {
+	limit := defaultMovementsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxMovementsLimit {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxMovementsLimit))
+			return
+		}
+		limit = n
+	}
+
+	movements, err := h.store.GetMovements(mux.Vars(r)["sku"], limit)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, movements)
+}
+
+// handleGetPolicy handles GET /admin/inventory/{sku}/backorder-policy.
+func (h *Handler) handleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.policies.GetBackorderPolicy(mux.Vars(r)["sku"])
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if policy == nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no backorder policy"))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, policy)
+}
+
+// handleSavePolicy handles PUT /admin/inventory/{sku}/backorder-policy.
+func (h *Handler) handleSavePolicy(w http.ResponseWriter, r *http.Request) {
+	var payload types.BackorderPolicyPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	policy := types.BackorderPolicy{
+		SKU:        mux.Vars(r)["sku"],
+		Mode:       payload.Mode,
+		Limit:      payload.Limit,
+		ExpectedAt: payload.ExpectedAt,
+	}
+	if err := h.policies.SaveBackorderPolicy(policy); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, policy)
+}
+
+// handleDeletePolicy handles DELETE /admin/inventory/{sku}/backorder-policy.
+// Orders already waiting for stock keep waiting; only new ones are refused.
+func (h *Handler) handleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	if err := h.policies.DeleteBackorderPolicy(mux.Vars(r)["sku"]); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeStockError responds to a failed stock change, with a conflict if there was not enough stock.
+func writeStockError(w http.ResponseWriter, err error) {
+	if errors.Is(err, types.ErrInsufficientStock) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+
+	utils.WriteError(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,366 @@
+package inventory
+
+import (
+	"bytes"             // Import the bytes package to build request bodies
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for reservation expiry
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for inventory types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestInventoryHandlers tests the inventory HTTP handlers.
+func TestInventoryHandlers(t *testing.T) {
+	// Create a user store with one admin (ID 1) and one customer (ID 2).
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin},
+		2: {ID: 2, Email: "customer@example.com", Role: types.RoleCustomer},
+	}}
+
+	t.Run("should record movements with the admin as actor and update availability", func(t *testing.T) {
+		store := &mockInventoryStore{warehouses: map[int]bool{1: true}, onHand: map[int]map[string]int{}}
+		router := mux.NewRouter()
+		NewHandler(store, store, userStore, &mockAllocator{}).RegisterRoutes(router)
+
+		body := []byte(`{"warehouseId": 1, "sku": "TSHIRT-M", "type": "receipt", "quantity": 10, "reference": "PO-1"}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/inventory/movements", body, 1); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		if len(store.movements) != 1 || store.movements[0].ActorID != 1 {
+			t.Fatalf("expected one movement recorded by the admin, got %+v", store.movements)
+		}
+
+		// Reservations reduce what is available to sell.
+		store.CreateReservation(types.Reservation{SKU: "TSHIRT-M", Quantity: 3, ExpiresAt: time.Now().Add(time.Minute)})
+
+		rr := serve(t, router, http.MethodGet, "/inventory/TSHIRT-M", nil, 0)
+		var res map[string]any
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res["available"] != float64(7) {
+			t.Errorf("expected 7 available, got %v", res["available"])
+		}
+	})
+
+	t.Run("should reject movements whose sign does not match their type", func(t *testing.T) {
+		store := &mockInventoryStore{}
+		router := mux.NewRouter()
+		NewHandler(store, store, userStore, &mockAllocator{}).RegisterRoutes(router)
+
+		for _, body := range []string{
+			`{"warehouseId": 1, "sku": "TSHIRT-M", "type": "receipt", "quantity": -1}`,
+			`{"warehouseId": 1, "sku": "TSHIRT-M", "type": "sale", "quantity": 1}`,
+			`{"warehouseId": 1, "sku": "TSHIRT-M", "type": "transfer", "quantity": 1}`,
+			`{"warehouseId": 1, "sku": "TSHIRT-M", "type": "adjustment", "quantity": 0}`,
+		} {
+			if rr := serve(t, router, http.MethodPost, "/admin/inventory/movements", []byte(body), 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should refuse to take more stock than is on hand", func(t *testing.T) {
+		store := &mockInventoryStore{warehouses: map[int]bool{1: true, 2: true}, onHand: map[int]map[string]int{}}
+		allocator := &mockAllocator{}
+		router := mux.NewRouter()
+		NewHandler(store, store, userStore, allocator).RegisterRoutes(router)
+		store.RecordMovement(types.StockMovement{WarehouseID: 1, SKU: "TSHIRT-M", Type: types.MovementReceipt, Quantity: 2})
+
+		body := []byte(`{"fromWarehouseId": 1, "toWarehouseId": 2, "sku": "TSHIRT-M", "quantity": 3}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/inventory/transfers", body, 1); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		body = []byte(`{"fromWarehouseId": 1, "toWarehouseId": 2, "sku": "TSHIRT-M", "quantity": 2}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/inventory/transfers", body, 1); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		if store.onHand[1]["TSHIRT-M"] != 0 || store.onHand[2]["TSHIRT-M"] != 2 {
+			t.Errorf("expected the stock to move to warehouse 2, got %v", store.onHand)
+		}
+
+		// Moving stock can let waiting orders ship from the warehouse it moved to.
+		if len(allocator.skus) != 1 || allocator.skus[0] != "TSHIRT-M" {
+			t.Errorf("expected one allocation for TSHIRT-M, got %v", allocator.skus)
+		}
+	})
+
+	t.Run("should allocate received stock and show backorder policies", func(t *testing.T) {
+		store := &mockInventoryStore{warehouses: map[int]bool{1: true}, onHand: map[int]map[string]int{}, policies: map[string]*types.BackorderPolicy{}}
+		allocator := &mockAllocator{}
+		router := mux.NewRouter()
+		NewHandler(store, store, userStore, allocator).RegisterRoutes(router)
+
+		// Set a backorder policy with an expected date.
+		body := []byte(`{"mode": "backorder", "limit": 10, "expectedAt": "2030-01-01T00:00:00Z"}`)
+		if rr := serve(t, router, http.MethodPut, "/admin/inventory/TSHIRT-M/backorder-policy", body, 1); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if p := store.policies["TSHIRT-M"]; p == nil || p.Mode != types.PolicyBackorder || p.Limit != 10 {
+			t.Fatalf("expected the policy to be saved, got %+v", p)
+		}
+
+		// Customers see that the SKU can be backordered.
+		rr := serve(t, router, http.MethodGet, "/inventory/TSHIRT-M", nil, 0)
+		var res map[string]any
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res["backorder"] != types.PolicyBackorder || res["expectedAt"] != "2030-01-01T00:00:00Z" {
+			t.Errorf("expected the backorder policy in the response, got %v", res)
+		}
+
+		// Receiving stock allocates it to waiting orders; removing stock does not.
+		body = []byte(`{"warehouseId": 1, "sku": "TSHIRT-M", "type": "receipt", "quantity": 5}`)
+		serve(t, router, http.MethodPost, "/admin/inventory/movements", body, 1)
+		body = []byte(`{"warehouseId": 1, "sku": "TSHIRT-M", "type": "adjustment", "quantity": -1}`)
+		serve(t, router, http.MethodPost, "/admin/inventory/movements", body, 1)
+		if len(allocator.skus) != 1 || allocator.skus[0] != "TSHIRT-M" {
+			t.Errorf("expected one allocation for TSHIRT-M, got %v", allocator.skus)
+		}
+	})
+
+	t.Run("should only let admins manage inventory", func(t *testing.T) {
+		store := &mockInventoryStore{}
+		router := mux.NewRouter()
+		NewHandler(store, store, userStore, &mockAllocator{}).RegisterRoutes(router)
+
+		body := []byte(`{"code": "AMS1", "name": "Amsterdam"}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/warehouses", body, 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a customer, got %d", http.StatusForbidden, rr.Code)
+		}
+		if rr := serve(t, router, http.MethodGet, "/admin/inventory/TSHIRT-M", nil, 0); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d without a token, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+}
+
+// serve sends a request through the router and returns the recorded response.
+// The request is authenticated as the given user, unless the user ID is 0.
+func serve(t *testing.T, router *mux.Router, method, target string, body []byte, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userID != 0 {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the auth middleware.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
+
+// mockInventoryStore is an in-memory implementation of the InventoryStore interface, used for testing purposes.
+type mockInventoryStore struct {
+	warehouses   map[int]bool                      // Whether each warehouse is active, by ID.
+	onHand       map[int]map[string]int            // On-hand quantity by warehouse ID and SKU.
+	movements    []types.StockMovement             // The ledger.
+	reservations []*types.Reservation              // Reservations, where the ID is the index plus one.
+	policies     map[string]*types.BackorderPolicy // Backorder policies by SKU.
+}
+
+// CreateWarehouse is a mock method that adds an active warehouse.
+func (m *mockInventoryStore) CreateWarehouse(w types.Warehouse) (int, error) {
+	id := len(m.warehouses) + 1
+	m.warehouses[id] = true
+	return id, nil
+}
+
+// GetWarehouses is a mock method that returns the warehouses.
+func (m *mockInventoryStore) GetWarehouses() ([]*types.Warehouse, error) {
+	warehouses := []*types.Warehouse{}
+	for id, active := range m.warehouses {
+		warehouses = append(warehouses, &types.Warehouse{ID: id, Active: active})
+	}
+	return warehouses, nil
+}
+
+// GetStockLevels is a mock method that returns the stock levels of a SKU.
+func (m *mockInventoryStore) GetStockLevels(sku string) ([]*types.StockLevel, error) {
+	levels := []*types.StockLevel{}
+	for id, skus := range m.onHand {
+		if n, ok := skus[sku]; ok {
+			levels = append(levels, &types.StockLevel{WarehouseID: id, SKU: sku, OnHand: n})
+		}
+	}
+	return levels, nil
+}
+
+// GetMovements is a mock method that returns the whole ledger.
+func (m *mockInventoryStore) GetMovements(sku string, limit int) ([]*types.StockMovement, error) {
+	movements := []*types.StockMovement{}
+	for i := range m.movements {
+		movements = append(movements, &m.movements[i])
+	}
+	return movements, nil
+}
+
+// RecordMovement is a mock method that applies a movement, refusing to make the stock negative.
+func (m *mockInventoryStore) RecordMovement(mv types.StockMovement) error {
+	if m.onHand[mv.WarehouseID] == nil {
+		m.onHand[mv.WarehouseID] = map[string]int{}
+	}
+	if m.onHand[mv.WarehouseID][mv.SKU]+mv.Quantity < 0 {
+		return types.ErrInsufficientStock
+	}
+
+	m.onHand[mv.WarehouseID][mv.SKU] += mv.Quantity
+	m.movements = append(m.movements, mv)
+	return nil
+}
+
+// Transfer is a mock method that records the two movements of a transfer.
+func (m *mockInventoryStore) Transfer(t types.StockTransfer) error {
+	err := m.RecordMovement(types.StockMovement{WarehouseID: t.FromWarehouseID, SKU: t.SKU, Type: types.MovementTransfer, Quantity: -t.Quantity})
+	if err != nil {
+		return err
+	}
+	return m.RecordMovement(types.StockMovement{WarehouseID: t.ToWarehouseID, SKU: t.SKU, Type: types.MovementTransfer, Quantity: t.Quantity})
+}
+
+// AvailableToSell is a mock method that subtracts the active reservations from the stock in active warehouses.
+func (m *mockInventoryStore) AvailableToSell(sku string) (int, error) {
+	available := 0
+	for id, skus := range m.onHand {
+		if m.warehouses[id] {
+			available += skus[sku]
+		}
+	}
+	for _, r := range m.reservations {
+		if r.SKU == sku && r.Status == types.ReservationActive && r.ExpiresAt.After(time.Now()) {
+			available -= r.Quantity
+		}
+	}
+	return available, nil
+}
+
+// CreateReservation is a mock method that adds an active reservation if there is enough stock.
+func (m *mockInventoryStore) CreateReservation(r types.Reservation) (int, error) {
+	available, _ := m.AvailableToSell(r.SKU)
+	if available < r.Quantity {
+		return 0, types.ErrInsufficientStock
+	}
+
+	r.ID = len(m.reservations) + 1
+	r.Status = types.ReservationActive
+	m.reservations = append(m.reservations, &r)
+	return r.ID, nil
+}
+
+// GetReservation is a mock method that returns a reservation by ID.
+func (m *mockInventoryStore) GetReservation(id int) (*types.Reservation, error) {
+	if id < 1 || id > len(m.reservations) {
+		return nil, fmt.Errorf("reservation %d not found", id)
+	}
+	return m.reservations[id-1], nil
+}
+
+// ReleaseReservation is a mock method that marks a reservation as released.
+func (m *mockInventoryStore) ReleaseReservation(id int) error {
+	r, err := m.GetReservation(id)
+	if err != nil {
+		return err
+	}
+	r.Status = types.ReservationReleased
+	return nil
+}
+
+// CommitReservation is a mock method that marks an unexpired reservation as committed and records the sale.
+func (m *mockInventoryStore) CommitReservation(id, warehouseID int) error {
+	r, err := m.GetReservation(id)
+	if err != nil {
+		return err
+	}
+	if r.Status == types.ReservationExpired || !r.ExpiresAt.After(time.Now()) {
+		return types.ErrReservationExpired
+	}
+	r.Status = types.ReservationCommitted
+	return m.RecordMovement(types.StockMovement{WarehouseID: warehouseID, SKU: r.SKU, Type: types.MovementSale, Quantity: -r.Quantity})
+}
+
+// ExpireReservations is a mock method that marks the active reservations expired before the given time.
+func (m *mockInventoryStore) ExpireReservations(before time.Time) (int, error) {
+	n := 0
+	for _, r := range m.reservations {
+		if r.Status == types.ReservationActive && !r.ExpiresAt.After(before) {
+			r.Status = types.ReservationExpired
+			n++
+		}
+	}
+	return n, nil
+}
+
+// GetBackorderPolicy is a mock method that returns the policy of a SKU, or nil.
+func (m *mockInventoryStore) GetBackorderPolicy(sku string) (*types.BackorderPolicy, error) {
+	return m.policies[sku], nil
+}
+
+// SaveBackorderPolicy is a mock method that stores the policy.
+func (m *mockInventoryStore) SaveBackorderPolicy(p types.BackorderPolicy) error {
+	m.policies[p.SKU] = &p
+	return nil
+}
+
+// DeleteBackorderPolicy is a mock method that removes the policy of a SKU.
+func (m *mockInventoryStore) DeleteBackorderPolicy(sku string) error {
+	delete(m.policies, sku)
+	return nil
+}
+
+// AcceptBackorder is a mock method that adds to the waiting quantity within the limit.
+func (m *mockInventoryStore) AcceptBackorder(sku string, quantity int) error {
+	p := m.policies[sku]
+	if p == nil || (p.Limit > 0 && p.Waiting+quantity > p.Limit) {
+		return types.ErrBackorderLimit
+	}
+	p.Waiting += quantity
+	return nil
+}
+
+// ReleaseBackorder is a mock method that subtracts from the waiting quantity.
+func (m *mockInventoryStore) ReleaseBackorder(sku string, quantity int) error {
+	if p := m.policies[sku]; p != nil {
+		p.Waiting = max(p.Waiting-quantity, 0)
+	}
+	return nil
+}
+
+// mockAllocator is an implementation of the StockAllocator interface that records the SKUs it was asked to allocate.
+type mockAllocator struct {
+	skus []string
+}
+
+// AllocateStock is a mock method that records the SKU.
+func (m *mockAllocator) AllocateStock(sku string) (int, error) {
+	m.skus = append(m.skus, sku)
+	return 0, nil
+}
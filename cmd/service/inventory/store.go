@@ -0,0 +1,437 @@
+package inventory
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+	"time"
+
+	// Import the types package for the inventory types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx, so helpers can run inside or outside a transaction.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Store struct represents the data store of the inventory subsystem.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateWarehouse is a method on the Store struct that adds a new, active warehouse and returns its ID.
+func (s *Store) CreateWarehouse(w types.Warehouse) (int, error) {
+	res, err := s.db.Exec(
+		"INSERT INTO warehouses (code, name, active, priority, latitude, longitude) VALUES (?, ?, TRUE, ?, ?, ?)",
+		w.Code, w.Name, w.Priority, w.Location.Latitude, w.Location.Longitude,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetWarehouses is a method on the Store struct that retrieves all warehouses, ordered by ID.
+func (s *Store) GetWarehouses() ([]*types.Warehouse, error) {
+	rows, err := s.db.Query("SELECT id, code, name, active, priority, latitude, longitude, createdAt FROM warehouses ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	warehouses := []*types.Warehouse{}
+	for rows.Next() {
+		w := new(types.Warehouse)
+		err := rows.Scan(&w.ID, &w.Code, &w.Name, &w.Active, &w.Priority, &w.Location.Latitude, &w.Location.Longitude, &w.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, w)
+	}
+
+	return warehouses, rows.Err()
+}
+
+// GetStockLevels is a method on the Store struct that retrieves the stock levels of a SKU in every warehouse.
+func (s *Store) GetStockLevels(sku string) ([]*types.StockLevel, error) {
+	rows, err := s.db.Query(
+		"SELECT warehouseId, sku, onHand, updatedAt FROM stock_levels WHERE sku = ? ORDER BY warehouseId",
+		sku,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := []*types.StockLevel{}
+	for rows.Next() {
+		l := new(types.StockLevel)
+		if err := rows.Scan(&l.WarehouseID, &l.SKU, &l.OnHand, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		levels = append(levels, l)
+	}
+
+	return levels, rows.Err()
+}
+
+// GetMovements is a method on the Store struct that retrieves the most recent ledger entries of a SKU.
+func (s *Store) GetMovements(sku string, limit int) ([]*types.StockMovement, error) {
+	rows, err := s.db.Query(
+		"SELECT id, warehouseId, sku, type, quantity, reference, actorId, createdAt FROM stock_movements WHERE sku = ? ORDER BY id DESC LIMIT ?",
+		sku, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []*types.StockMovement{}
+	for rows.Next() {
+		m := new(types.StockMovement)
+		err := rows.Scan(&m.ID, &m.WarehouseID, &m.SKU, &m.Type, &m.Quantity, &m.Reference, &m.ActorID, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+
+	return movements, rows.Err()
+}
+
+// RecordMovement is a method on the Store struct that appends a movement to the ledger and updates the
+// stock level of its warehouse, in a single transaction.
+func (s *Store) RecordMovement(m types.StockMovement) error {
+	if err := validateMovement(m); err != nil {
+		return err
+	}
+
+	return s.inTx(func(tx *sql.Tx) error {
+		return applyMovement(tx, m)
+	})
+}
+
+// Transfer is a method on the Store struct that moves stock between two warehouses in a single transaction,
+// recording a negative transfer movement at the source and a positive one at the destination.
+func (s *Store) Transfer(t types.StockTransfer) error {
+	if t.Quantity <= 0 || t.FromWarehouseID == t.ToWarehouseID {
+		return fmt.Errorf("invalid transfer")
+	}
+
+	out := types.StockMovement{WarehouseID: t.FromWarehouseID, SKU: t.SKU, Type: types.MovementTransfer, Quantity: -t.Quantity, Reference: t.Reference, ActorID: t.ActorID}
+	in := types.StockMovement{WarehouseID: t.ToWarehouseID, SKU: t.SKU, Type: types.MovementTransfer, Quantity: t.Quantity, Reference: t.Reference, ActorID: t.ActorID}
+
+	// Lock the two stock levels in a fixed order, so concurrent opposite transfers cannot deadlock.
+	first, second := out, in
+	if in.WarehouseID < out.WarehouseID {
+		first, second = in, out
+	}
+
+	return s.inTx(func(tx *sql.Tx) error {
+		if err := applyMovement(tx, first); err != nil {
+			return err
+		}
+		return applyMovement(tx, second)
+	})
+}
+
+// AvailableToSell is a method on the Store struct that returns the quantity of a SKU that can still be sold.
+func (s *Store) AvailableToSell(sku string) (int, error) {
+	return availableToSell(s.db, sku)
+}
+
+// CreateReservation is a method on the Store struct that holds stock for a checkout.
+// The SKU's stock levels are locked while checking availability, so concurrent checkouts cannot both
+// reserve the last unit.
+func (s *Store) CreateReservation(r types.Reservation) (int, error) {
+	if r.Quantity <= 0 {
+		return 0, fmt.Errorf("invalid reservation quantity %d", r.Quantity)
+	}
+
+	var id int
+	err := s.inTx(func(tx *sql.Tx) error {
+		// Lock the stock levels of the SKU until the transaction ends.
+		if _, err := tx.Exec("SELECT onHand FROM stock_levels WHERE sku = ? FOR UPDATE", r.SKU); err != nil {
+			return err
+		}
+
+		available, err := availableToSell(tx, r.SKU)
+		if err != nil {
+			return err
+		}
+		if available < r.Quantity {
+			return types.ErrInsufficientStock
+		}
+
+		res, err := tx.Exec(
+			"INSERT INTO reservations (sku, quantity, reference, status, expiresAt) VALUES (?, ?, ?, ?, ?)",
+			r.SKU, r.Quantity, r.Reference, types.ReservationActive, r.ExpiresAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		lastID, err := res.LastInsertId()
+		id = int(lastID)
+		return err
+	})
+
+	return id, err
+}
+
+// GetReservation is a method on the Store struct that retrieves a reservation by ID.
+func (s *Store) GetReservation(id int) (*types.Reservation, error) {
+	return getReservation(s.db, id, false)
+}
+
+// ReleaseReservation is a method on the Store struct that gives the stock held by an active reservation back.
+func (s *Store) ReleaseReservation(id int) error {
+	res, err := s.db.Exec(
+		"UPDATE reservations SET status = ? WHERE id = ? AND status = ?",
+		types.ReservationReleased, id, types.ReservationActive,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("reservation %d is not active", id)
+	}
+
+	return nil
+}
+
+// CommitReservation is a method on the Store struct that turns an active reservation into a sale.
+// A reservation past its expiry is rejected even if the sweeper has not marked it yet: its stock already counts
+// as available again, and may have been sold to someone else.
+func (s *Store) CommitReservation(id, warehouseID int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		r, err := getReservation(tx, id, true)
+		if err != nil {
+			return err
+		}
+		if r.Status == types.ReservationExpired || (r.Status == types.ReservationActive && !r.ExpiresAt.After(time.Now())) {
+			return types.ErrReservationExpired
+		}
+		if r.Status != types.ReservationActive {
+			return fmt.Errorf("reservation %d is %s", id, r.Status)
+		}
+
+		if _, err := tx.Exec("UPDATE reservations SET status = ? WHERE id = ?", types.ReservationCommitted, id); err != nil {
+			return err
+		}
+
+		return applyMovement(tx, types.StockMovement{
+			WarehouseID: warehouseID,
+			SKU:         r.SKU,
+			Type:        types.MovementSale,
+			Quantity:    -r.Quantity,
+			Reference:   fmt.Sprintf("reservation:%d", id),
+		})
+	})
+}
+
+// ExpireReservations is a method on the Store struct that marks the active reservations that expired
+// before the given time as expired.
+func (s *Store) ExpireReservations(before time.Time) (int, error) {
+	res, err := s.db.Exec(
+		"UPDATE reservations SET status = ? WHERE status = ? AND expiresAt <= ?",
+		types.ReservationExpired, types.ReservationActive, before,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds and rolling it back otherwise.
+func (s *Store) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applyMovement locks the stock level of the movement's warehouse and SKU, checks it does not go negative,
+// updates it and appends the movement to the ledger. It must run inside a transaction.
+func applyMovement(tx *sql.Tx, m types.StockMovement) error {
+	// Make sure the stock level row exists, so it can be locked.
+	_, err := tx.Exec(
+		"INSERT INTO stock_levels (warehouseId, sku, onHand) VALUES (?, ?, 0) ON DUPLICATE KEY UPDATE onHand = onHand",
+		m.WarehouseID, m.SKU,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Lock the row and read the current quantity.
+	var onHand int
+	err = tx.QueryRow(
+		"SELECT onHand FROM stock_levels WHERE warehouseId = ? AND sku = ? FOR UPDATE",
+		m.WarehouseID, m.SKU,
+	).Scan(&onHand)
+	if err != nil {
+		return err
+	}
+
+	if onHand+m.Quantity < 0 {
+		return types.ErrInsufficientStock
+	}
+
+	_, err = tx.Exec(
+		"UPDATE stock_levels SET onHand = onHand + ?, updatedAt = NOW() WHERE warehouseId = ? AND sku = ?",
+		m.Quantity, m.WarehouseID, m.SKU,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO stock_movements (warehouseId, sku, type, quantity, reference, actorId) VALUES (?, ?, ?, ?, ?, ?)",
+		m.WarehouseID, m.SKU, m.Type, m.Quantity, m.Reference, m.ActorID,
+	)
+
+	return err
+}
+
+// validateMovement checks that the sign of a movement's quantity matches its type.
+func validateMovement(m types.StockMovement) error {
+	switch {
+	case m.Quantity == 0:
+		return fmt.Errorf("movement quantity cannot be zero")
+	case (m.Type == types.MovementReceipt || m.Type == types.MovementReturn) && m.Quantity < 0:
+		return fmt.Errorf("%s movements must add stock", m.Type)
+	case m.Type == types.MovementSale && m.Quantity > 0:
+		return fmt.Errorf("sale movements must remove stock")
+	case m.Type == types.MovementTransfer:
+		return fmt.Errorf("transfers must be recorded with Transfer")
+	case m.Type != types.MovementReceipt && m.Type != types.MovementReturn && m.Type != types.MovementSale && m.Type != types.MovementAdjustment:
+		return fmt.Errorf("unknown movement type %q", m.Type)
+	}
+
+	return nil
+}
+
+// availableToSell computes the stock on hand of a SKU in active warehouses minus its active, unexpired reservations.
+func availableToSell(q querier, sku string) (int, error) {
+	var available int
+	err := q.QueryRow(`
+		SELECT
+			COALESCE((SELECT SUM(sl.onHand) FROM stock_levels sl JOIN warehouses w ON w.id = sl.warehouseId WHERE sl.sku = ? AND w.active), 0)
+			- COALESCE((SELECT SUM(r.quantity) FROM reservations r WHERE r.sku = ? AND r.status = ? AND r.expiresAt > ?), 0)`,
+		sku, sku, types.ReservationActive, time.Now(),
+	).Scan(&available)
+
+	return available, err
+}
+
+// getReservation retrieves a reservation by ID, optionally locking it until the transaction ends.
+func getReservation(q querier, id int, forUpdate bool) (*types.Reservation, error) {
+	query := "SELECT id, sku, quantity, reference, status, expiresAt, createdAt FROM reservations WHERE id = ?"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	r := new(types.Reservation)
+	err := q.QueryRow(query, id).Scan(&r.ID, &r.SKU, &r.Quantity, &r.Reference, &r.Status, &r.ExpiresAt, &r.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("reservation %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetBackorderPolicy is a method on the Store struct that retrieves the backorder policy of a SKU, or nil if it has none.
+func (s *Store) GetBackorderPolicy(sku string) (*types.BackorderPolicy, error) {
+	p := new(types.BackorderPolicy)
+	var expectedAt sql.NullTime
+
+	err := s.db.QueryRow(
+		"SELECT sku, mode, waitingLimit, waiting, expectedAt FROM backorder_policies WHERE sku = ?", sku,
+	).Scan(&p.SKU, &p.Mode, &p.Limit, &p.Waiting, &expectedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedAt.Valid {
+		p.ExpectedAt = &expectedAt.Time
+	}
+
+	return p, nil
+}
+
+// SaveBackorderPolicy is a method on the Store struct that creates or replaces the backorder policy of a SKU.
+// The quantity already waiting for stock is kept.
+func (s *Store) SaveBackorderPolicy(p types.BackorderPolicy) error {
+	_, err := s.db.Exec(`
+		INSERT INTO backorder_policies (sku, mode, waitingLimit, expectedAt) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE mode = VALUES(mode), waitingLimit = VALUES(waitingLimit), expectedAt = VALUES(expectedAt)`,
+		p.SKU, p.Mode, p.Limit, p.ExpectedAt,
+	)
+
+	return err
+}
+
+// DeleteBackorderPolicy is a method on the Store struct that removes the backorder policy of a SKU.
+func (s *Store) DeleteBackorderPolicy(sku string) error {
+	_, err := s.db.Exec("DELETE FROM backorder_policies WHERE sku = ?", sku)
+	return err
+}
+
+// AcceptBackorder is a method on the Store struct that adds to the quantity of a SKU waiting for stock.
+// The limit is checked in the same statement, so concurrent checkouts cannot exceed it together.
+func (s *Store) AcceptBackorder(sku string, quantity int) error {
+	res, err := s.db.Exec(
+		"UPDATE backorder_policies SET waiting = waiting + ? WHERE sku = ? AND (waitingLimit = 0 OR waiting + ? <= waitingLimit)",
+		quantity, sku, quantity,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return types.ErrBackorderLimit
+	}
+
+	return nil
+}
+
+// ReleaseBackorder is a method on the Store struct that subtracts from the quantity of a SKU waiting for stock.
+func (s *Store) ReleaseBackorder(sku string, quantity int) error {
+	_, err := s.db.Exec("UPDATE backorder_policies SET waiting = GREATEST(waiting - ?, 0) WHERE sku = ?", quantity, sku)
+	return err
+}
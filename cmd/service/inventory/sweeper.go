@@ -0,0 +1,30 @@
+package inventory
+
+import (
+	"log"
+	"time"
+
+	// Import the types package for the inventory store interface.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// StartReservationSweeper starts a goroutine that expires the reservations of abandoned checkouts every interval,
+// giving their stock back. Available to sell already ignores expired reservations, so the sweeper only keeps
+// the reservation statuses accurate; it does not need to run often.
+func StartReservationSweeper(store types.InventoryStore, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			n, err := store.ExpireReservations(now)
+			if err != nil {
+				log.Printf("failed to expire reservations: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("expired %d reservations", n)
+			}
+		}
+	}()
+}
@@ -0,0 +1,310 @@
+package invoice
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the config package for the company details.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the types package for the order, return and money types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// document struct holds what is printed on an invoice or credit note.
+type document struct {
+	Title     string         // "Invoice" or "Credit note".
+	Number    string         // Number of the document.
+	IssuedAt  time.Time      // When the document was issued.
+	OrderID   int            // ID of the order.
+	OrderedAt time.Time      // When the order was placed.
+	Corrects  string         // Number of the invoice a credit note corrects, empty for invoices.
+	Seller    []string       // Name and details of the shop.
+	Buyer     []string       // Name and details of the customer.
+	Currency  string         // Currency of the amounts.
+	Lines     []documentLine // What is invoiced.
+	Taxes     []taxLine      // The tax, by rate.
+	Net       types.Money    // Sum of the lines without tax.
+	Tax       types.Money    // Sum of the tax.
+	Total     types.Money    // Net plus tax.
+	Notes     []string       // Notes printed under the totals, such as how the order was paid.
+}
+
+// documentLine struct represents a line of a document.
+type documentLine struct {
+	Description string      // What was sold.
+	Quantity    int         // Quantity sold; negative on credit notes.
+	UnitPrice   string      // Price of one unit as shown in the shop, empty if not shown.
+	Discount    string      // Discount given on the line, empty if none.
+	Rate        string      // Tax rate as a percentage, such as "21".
+	Net         types.Money // The line without tax.
+	Tax         types.Money // The tax on the line.
+	Gross       types.Money // Net plus tax.
+}
+
+// taxLine struct represents the tax at one rate.
+type taxLine struct {
+	Rate string      // Tax rate as a percentage, such as "21".
+	Net  types.Money // Sum of the lines taxed at the rate, without tax.
+	Tax  types.Money // The tax at the rate.
+}
+
+// invoiceDocument returns the invoice of an order. Names holds the names of the products by SKU; items of
+// products that are gone are described by their SKU.
+func invoiceDocument(o *types.Order, u *types.User, names map[string]string) (*document, error) {
+	d := newDocument("Invoice", o, u)
+
+	for _, item := range o.Items {
+		net, err := item.Total.Sub(item.Tax)
+		if err != nil {
+			return nil, err
+		}
+		line := documentLine{
+			Description: describe(item.SKU, names),
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice.Decimal(),
+			Rate:        item.TaxRate,
+			Net:         net,
+			Tax:         item.Tax,
+			Gross:       item.Total,
+		}
+		if !item.Discount.IsZero() {
+			line.Discount = item.Discount.Neg().Decimal()
+		}
+		d.Lines = append(d.Lines, line)
+	}
+
+	shipping, err := shippingPaid(o)
+	if err != nil {
+		return nil, err
+	}
+	if o.ShippingMethod != "" || shipping.IsPositive() {
+		net, err := shipping.Sub(o.ShippingTax)
+		if err != nil {
+			return nil, err
+		}
+		line := documentLine{
+			Description: strings.TrimSpace("Shipping " + o.ShippingMethod),
+			Quantity:    1,
+			UnitPrice:   o.Shipping.Decimal(),
+			Rate:        o.ShippingTaxRate,
+			Net:         net,
+			Tax:         o.ShippingTax,
+			Gross:       shipping,
+		}
+		if o.FreeShipping && o.Shipping.IsPositive() {
+			line.Discount = o.Shipping.Neg().Decimal()
+		}
+		d.Lines = append(d.Lines, line)
+	}
+
+	// How the order was paid.
+	for _, paid := range []struct {
+		label  string
+		amount types.Money
+	}{
+		{"Paid with gift cards", o.GiftCardAmount},
+		{"Paid with store credit", o.StoreCreditAmount},
+		{"Paid by card or bank", o.AmountDue},
+	} {
+		if paid.amount.IsPositive() {
+			d.Notes = append(d.Notes, fmt.Sprintf("%s: %s", paid.label, paid.amount))
+		}
+	}
+
+	return d, d.sum()
+}
+
+// creditNoteDocument returns the credit note of a refunded return of an order, correcting its invoice.
+func creditNoteDocument(o *types.Order, u *types.User, ret *types.Return, corrects *types.Invoice, names map[string]string) (*document, error) {
+	d := newDocument("Credit note", o, u)
+	d.Corrects = corrects.Number
+
+	items := map[int]types.OrderItem{}
+	for _, item := range o.Items {
+		items[item.ID] = item
+	}
+
+	for _, rl := range ret.Lines {
+		item, ok := items[rl.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("order item %d is not in order %d", rl.OrderItemID, o.ID)
+		}
+		line, err := refundLine(describe(item.SKU, names), -rl.Quantity, item.TaxRate, rl.Refund, item.Tax, item.Total)
+		if err != nil {
+			return nil, err
+		}
+		line.UnitPrice = item.UnitPrice.Decimal()
+		d.Lines = append(d.Lines, line)
+	}
+
+	if ret.ShippingRefund.IsPositive() {
+		shipping, err := shippingPaid(o)
+		if err != nil {
+			return nil, err
+		}
+		line, err := refundLine(strings.TrimSpace("Shipping "+o.ShippingMethod), -1, o.ShippingTaxRate, ret.ShippingRefund, o.ShippingTax, shipping)
+		if err != nil {
+			return nil, err
+		}
+		d.Lines = append(d.Lines, line)
+	}
+
+	d.Notes = append(d.Notes, fmt.Sprintf("Refund of return #%d of order #%d.", ret.ID, o.ID))
+	for _, paid := range []struct {
+		label  string
+		amount types.Money
+	}{
+		{"Paid back by card or bank", ret.ProviderRefund},
+		{"Paid back as store credit", ret.StoreCreditRefund},
+	} {
+		if paid.amount.IsPositive() {
+			d.Notes = append(d.Notes, fmt.Sprintf("%s: %s", paid.label, paid.amount))
+		}
+	}
+
+	return d, d.sum()
+}
+
+// refundLine returns a credit note line paying back a refund of something the customer paid gross for, with its
+// part of the tax on it.
+func refundLine(description string, quantity int, rate string, refund, tax, gross types.Money) (documentLine, error) {
+	refundTax := types.NewMoney(0, refund.Currency)
+	if gross.Amount != 0 {
+		var err error
+		if refundTax, err = refund.MulRat(big.NewRat(tax.Amount, gross.Amount), types.RoundHalfUp); err != nil {
+			return documentLine{}, err
+		}
+	}
+	net, err := refund.Sub(refundTax)
+	if err != nil {
+		return documentLine{}, err
+	}
+
+	return documentLine{
+		Description: description,
+		Quantity:    quantity,
+		Rate:        rate,
+		Net:         net.Neg(),
+		Tax:         refundTax.Neg(),
+		Gross:       refund.Neg(),
+	}, nil
+}
+
+// newDocument returns a document for an order without lines, with the details of the shop and the customer.
+func newDocument(title string, o *types.Order, u *types.User) *document {
+	d := &document{
+		Title:     title,
+		OrderID:   o.ID,
+		OrderedAt: o.CreatedAt,
+		Currency:  o.Total.Currency,
+	}
+
+	d.Seller = append([]string{config.Envs.CompanyName}, config.Envs.CompanyAddress...)
+	if config.Envs.CompanyVATID != "" {
+		d.Seller = append(d.Seller, "VAT ID: "+config.Envs.CompanyVATID)
+	}
+	if config.Envs.CompanyRegistration != "" {
+		d.Seller = append(d.Seller, "Registration: "+config.Envs.CompanyRegistration)
+	}
+	if config.Envs.CompanyEmail != "" {
+		d.Seller = append(d.Seller, config.Envs.CompanyEmail)
+	}
+
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		d.Buyer = append(d.Buyer, name)
+	}
+	d.Buyer = append(d.Buyer, u.Email)
+	if a := o.Address; a != nil {
+		d.Buyer = append(d.Buyer, strings.Join(slices.DeleteFunc([]string{a.PostalCode, a.State, a.Country}, func(s string) bool { return s == "" }), " "))
+	}
+	if o.VATID != "" {
+		d.Buyer = append(d.Buyer, "VAT ID: "+o.VATID)
+	}
+
+	if o.ReverseCharge {
+		d.Notes = append(d.Notes, "VAT reverse charged: the customer accounts for the VAT.")
+	} else if o.PricesIncludeTax {
+		d.Notes = append(d.Notes, "Unit prices include VAT.")
+	} else {
+		d.Notes = append(d.Notes, "Unit prices exclude VAT.")
+	}
+
+	return d
+}
+
+// sum adds the lines of the document up into its tax breakdown and totals.
+func (d *document) sum() error {
+	d.Net = types.NewMoney(0, d.Currency)
+	d.Tax = types.NewMoney(0, d.Currency)
+	d.Total = types.NewMoney(0, d.Currency)
+	d.Taxes = nil
+
+	var err error
+	for _, line := range d.Lines {
+		if d.Net, err = d.Net.Add(line.Net); err != nil {
+			return err
+		}
+		if d.Tax, err = d.Tax.Add(line.Tax); err != nil {
+			return err
+		}
+		if d.Total, err = d.Total.Add(line.Gross); err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(d.Taxes, func(t taxLine) bool { return t.Rate == line.Rate })
+		if i < 0 {
+			d.Taxes = append(d.Taxes, taxLine{Rate: line.Rate, Net: types.NewMoney(0, d.Currency), Tax: types.NewMoney(0, d.Currency)})
+			i = len(d.Taxes) - 1
+		}
+		if d.Taxes[i].Net, err = d.Taxes[i].Net.Add(line.Net); err != nil {
+			return err
+		}
+		if d.Taxes[i].Tax, err = d.Taxes[i].Tax.Add(line.Tax); err != nil {
+			return err
+		}
+	}
+
+	// List the rates from low to high.
+	slices.SortFunc(d.Taxes, func(a, b taxLine) int {
+		x, _ := strconv.ParseFloat(a.Rate, 64)
+		y, _ := strconv.ParseFloat(b.Rate, 64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	})
+
+	return nil
+}
+
+// describe returns the description of a SKU on a document: the name of its product, or the SKU itself.
+func describe(sku string, names map[string]string) string {
+	if name := names[sku]; name != "" {
+		return fmt.Sprintf("%s (%s)", name, sku)
+	}
+	return sku
+}
+
+// shippingPaid returns what the customer paid for shipping, with tax as it was due: the part of the total not paid
+// for the items.
+func shippingPaid(o *types.Order) (types.Money, error) {
+	shipping := o.Total
+	for _, item := range o.Items {
+		var err error
+		if shipping, err = shipping.Sub(item.Total); err != nil {
+			return types.Money{}, err
+		}
+	}
+
+	if shipping.IsNegative() {
+		return types.NewMoney(0, shipping.Currency), nil
+	}
+	return shipping, nil
+}
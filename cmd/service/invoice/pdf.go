@@ -0,0 +1,229 @@
+package invoice
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Size of an A4 page, in points.
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+)
+
+// pdfWriter draws text and lines on A4 pages and writes them as a PDF document. It uses the standard Helvetica
+// fonts every PDF reader has, so no font is embedded, and text is limited to what the WinAnsi encoding holds.
+// Positions are given in points from the top left of the page.
+type pdfWriter struct {
+	pages []*bytes.Buffer // Content stream of each page.
+	page  *bytes.Buffer   // Page being drawn on.
+}
+
+// newPDF returns a writer with one empty page.
+func newPDF() *pdfWriter {
+	p := &pdfWriter{}
+	p.addPage()
+	return p
+}
+
+// addPage adds an empty page and draws on it from now on.
+func (p *pdfWriter) addPage() {
+	p.page = &bytes.Buffer{}
+	p.pages = append(p.pages, p.page)
+}
+
+// setPage draws on an existing page from now on, counting from 0.
+func (p *pdfWriter) setPage(i int) {
+	p.page = p.pages[i]
+}
+
+// text draws text with its left end at x and its baseline at y.
+func (p *pdfWriter) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	fmt.Fprintf(p.page, "BT /%s %s Tf %s %s Td (", font, num(size), num(x), num(pageHeight-y))
+	p.page.Write(encodeText(s))
+	p.page.WriteString(") Tj ET\n")
+}
+
+// textRight draws text with its right end at x and its baseline at y.
+func (p *pdfWriter) textRight(x, y, size float64, bold bool, s string) {
+	p.text(x-textWidth(s, size, bold), y, size, bold, s)
+}
+
+// line draws a thin line between two points.
+func (p *pdfWriter) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(p.page, "0.5 w %s %s m %s %s l S\n", num(x1), num(pageHeight-y1), num(x2), num(pageHeight-y2))
+}
+
+// bytes returns the PDF document, titled and dated with the metadata given.
+func (p *pdfWriter) bytes(title string, created time.Time) ([]byte, error) {
+	var out bytes.Buffer
+	var offsets []int
+
+	// object starts the next object and returns its number.
+	object := func() int {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n", len(offsets))
+		return len(offsets)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 to 4 are the catalog, the page tree and the fonts; the pages and their contents follow.
+	object()
+	out.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	object()
+	out.WriteString("<< /Type /Pages /Kids [")
+	for i := range p.pages {
+		fmt.Fprintf(&out, " %d 0 R", 5+2*i)
+	}
+	fmt.Fprintf(&out, " ] /Count %d >>\nendobj\n", len(p.pages))
+
+	for _, font := range []string{"Helvetica", "Helvetica-Bold"} {
+		object()
+		fmt.Fprintf(&out, "<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>\nendobj\n", font)
+	}
+
+	for _, page := range p.pages {
+		n := object()
+		fmt.Fprintf(&out, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+			num(pageWidth), num(pageHeight), n+1)
+
+		var content bytes.Buffer
+		zw := zlib.NewWriter(&content)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		object()
+		fmt.Fprintf(&out, "<< /Length %d /Filter /FlateDecode >>\nstream\n", content.Len())
+		out.Write(content.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	info := object()
+	out.WriteString("<< /Title (")
+	out.Write(encodeText(title))
+	fmt.Fprintf(&out, ") /Producer (Ecom) /CreationDate (D:%s) >>\nendobj\n", created.UTC().Format("20060102150405Z"))
+
+	// The cross-reference table gives the byte offset of every object; its entries are exactly 20 bytes long.
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+
+	return out.Bytes(), nil
+}
+
+// num formats a number for a PDF, with at most two decimals.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// encodeText encodes text as the contents of a PDF string in the WinAnsi encoding. Parentheses and backslashes
+// are escaped; characters the encoding does not hold become question marks.
+func encodeText(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		c, ok := winAnsi(r)
+		if !ok {
+			c = '?'
+		}
+		if c == '(' || c == ')' || c == '\\' {
+			b = append(b, '\\')
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// winAnsi returns the WinAnsi code of a character. It holds ASCII, Latin-1 and some punctuation and symbols,
+// such as the euro sign.
+func winAnsi(r rune) (byte, bool) {
+	switch {
+	case r >= 0x20 && r <= 0x7e, r >= 0xa0 && r <= 0xff:
+		return byte(r), true
+	}
+
+	switch r {
+	case '€':
+		return 0x80, true
+	case '‚':
+		return 0x82, true
+	case '„':
+		return 0x84, true
+	case '…':
+		return 0x85, true
+	case '‘':
+		return 0x91, true
+	case '’':
+		return 0x92, true
+	case '“':
+		return 0x93, true
+	case '”':
+		return 0x94, true
+	case '•':
+		return 0x95, true
+	case '–':
+		return 0x96, true
+	case '—':
+		return 0x97, true
+	case '™':
+		return 0x99, true
+	}
+	return 0, false
+}
+
+// textWidth returns the width of text in points. Characters outside ASCII are measured as digits, which is close
+// enough for the accented letters and symbols they mostly are.
+func textWidth(s string, size float64, bold bool) float64 {
+	widths := helveticaWidths
+	if bold {
+		widths = helveticaBoldWidths
+	}
+
+	units := 0
+	for _, r := range s {
+		if r >= 0x20 && r <= 0x7e {
+			units += widths[r-0x20]
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// helveticaWidths holds the widths of the ASCII characters from space to tilde in Helvetica, in thousandths of
+// the font size, from its Adobe font metrics.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// helveticaBoldWidths holds the widths of the ASCII characters from space to tilde in Helvetica Bold.
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611, // 0 to ?
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556, // P to _
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611, // ` to o
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584, // p to ~
+}
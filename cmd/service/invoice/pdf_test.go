@@ -0,0 +1,106 @@
+package invoice
+
+import (
+	"bytes"         // Import the bytes package to search the document
+	"compress/zlib" // Import the zlib package to read the page contents
+	"fmt"           // Import the fmt package to find objects
+	"io"            // Import the io package to read decompressed streams
+	"regexp"        // Import the regexp package to read the cross-reference table
+	"strconv"       // Import the strconv package to parse offsets
+	"strings"       // Import the strings package to build long texts
+	"testing"       // Import the testing package to write test cases
+	"time"          // Import the time package for the creation date
+)
+
+// TestPDFWriter tests that written documents are well-formed PDF.
+func TestPDFWriter(t *testing.T) {
+	p := newPDF()
+	p.text(50, 60, 12, true, "Invoice (copy) \\ 10 €")
+	p.textRight(545, 80, 9, false, "19.99")
+	p.line(50, 90, 545, 90)
+	p.addPage()
+	p.text(50, 60, 9, false, "Page two, 你好")
+
+	pdf, err := p.bytes("Invoice INV-2024-000001", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Errorf("expected a PDF header and end of file marker")
+	}
+	if !bytes.Contains(pdf, []byte("/Count 2")) || !bytes.Contains(pdf, []byte("/CreationDate (D:20240501120000Z)")) {
+		t.Errorf("expected two pages and the creation date")
+	}
+
+	// Every offset in the cross-reference table points at its object.
+	start := bytes.LastIndex(pdf, []byte("startxref\n"))
+	xref, _ := strconv.Atoi(strings.Fields(string(pdf[start+len("startxref\n"):]))[0])
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("expected startxref to point at the cross-reference table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(pdf[xref:], -1)
+	if len(entries) != 9 {
+		t.Fatalf("expected 9 objects, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if !bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))) {
+			t.Errorf("expected object %d at offset %d", i+1, offset)
+		}
+	}
+
+	text := pdfText(t, pdf)
+	for _, expected := range []string{"(Invoice \\(copy\\) \\\\ 10 \x80) Tj", "(19.99) Tj", "(Page two, ??) Tj"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected the content to draw %q, got %q", expected, text)
+		}
+	}
+}
+
+// TestTextWidth tests measuring and truncating text.
+func TestTextWidth(t *testing.T) {
+	if w := textWidth("10.00", 10, false); w != 25.02 {
+		t.Errorf("expected 4 digits and a period to be 25.02 points wide, got %v", w)
+	}
+	if textWidth("W", 10, true) <= textWidth("i", 10, true) {
+		t.Errorf("expected a W to be wider than an i")
+	}
+
+	long := strings.Repeat("Organic coffee beans ", 10)
+	short := truncate(long, 9, 190)
+	if !strings.HasSuffix(short, "…") || textWidth(short, 9, false) > 190 {
+		t.Errorf("expected the text to be cut to 190 points, got %q", short)
+	}
+	if truncate("Mug", 9, 190) != "Mug" {
+		t.Errorf("expected short text to stay as it is")
+	}
+}
+
+// pdfText returns the decompressed content streams of a PDF document, joined.
+func pdfText(t *testing.T, pdf []byte) string {
+	t.Helper()
+
+	var text strings.Builder
+	for rest := pdf; ; {
+		start := bytes.Index(rest, []byte("stream\n"))
+		if start < 0 {
+			break
+		}
+		rest = rest[start+len("stream\n"):]
+		end := bytes.Index(rest, []byte("\nendstream"))
+
+		zr, err := zlib.NewReader(bytes.NewReader(rest[:end]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text.Write(content)
+		rest = rest[end+len("\nendstream"):]
+	}
+
+	return text.String()
+}
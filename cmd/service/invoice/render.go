@@ -0,0 +1,153 @@
+package invoice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout of a page, in points from the top left.
+const (
+	marginLeft   = 50.0  // Left end of the text.
+	marginRight  = 545.0 // Right end of the text.
+	contentEnd   = 770.0 // Lowest baseline of the content; below are the footer and margin.
+	footerY      = 805.0 // Baseline of the footer.
+	lineHeight   = 14.0  // Distance between the baselines of table rows.
+	textSize     = 9.0   // Size of the text of the table and details.
+	descriptionW = 190.0 // Widest description of a line.
+)
+
+// columns holds the right ends of the amount columns of the table, with their headings.
+var columns = []struct {
+	heading string
+	right   float64
+}{
+	{"Qty", 275},
+	{"Unit price", 335},
+	{"Discount", 393},
+	{"VAT", 428},
+	{"Net", 486},
+	{"Total", marginRight},
+}
+
+// render renders a document as a PDF. Lines that do not fit on the first page continue on more, under the
+// headings of the table, and every page has a footer with the details of the shop and its number.
+func render(d *document) ([]byte, error) {
+	p := newPDF()
+
+	// The shop, top left, and the document, top right.
+	y := 60.0
+	p.text(marginLeft, y, 14, true, d.Seller[0])
+	for i, line := range d.Seller[1:] {
+		p.text(marginLeft, y+16+float64(i)*12, textSize, false, line)
+	}
+
+	p.textRight(marginRight, y, 18, true, d.Title)
+	details := []string{
+		"Number: " + d.Number,
+		"Date: " + d.IssuedAt.Format(time.DateOnly),
+		fmt.Sprintf("Order: #%d of %s", d.OrderID, d.OrderedAt.Format(time.DateOnly)),
+	}
+	if d.Corrects != "" {
+		details = append(details, "Corrects invoice: "+d.Corrects)
+	}
+	details = append(details, "Amounts in "+d.Currency)
+	for i, line := range details {
+		p.textRight(marginRight, y+18+float64(i)*12, textSize, false, line)
+	}
+
+	// The customer.
+	y = 60 + 16 + float64(max(len(d.Seller)-1, len(details)))*12 + 24
+	p.text(marginLeft, y, 10, true, "Bill to")
+	for _, line := range d.Buyer {
+		y += 12
+		p.text(marginLeft, y, textSize, false, line)
+	}
+
+	// The lines.
+	y = tableHeader(p, y+30)
+	for _, line := range d.Lines {
+		if y+lineHeight > contentEnd {
+			p.addPage()
+			y = tableHeader(p, 60)
+		}
+		y += lineHeight
+
+		cells := []string{
+			strconv.Itoa(line.Quantity),
+			line.UnitPrice,
+			line.Discount,
+			line.Rate + "%",
+			line.Net.Decimal(),
+			line.Gross.Decimal(),
+		}
+		p.text(marginLeft, y, textSize, false, truncate(line.Description, textSize, descriptionW))
+		for i, cell := range cells {
+			p.textRight(columns[i].right, y, textSize, false, cell)
+		}
+	}
+
+	// The totals, the tax by rate and the notes. They are kept together on a page.
+	height := float64(len(d.Taxes)+3)*lineHeight + 20 + float64(len(d.Notes))*12
+	if y+height > contentEnd {
+		p.addPage()
+		y = 60
+	}
+	y += 8
+	p.line(marginLeft, y, marginRight, y)
+
+	totals := [][2]string{{"Net", d.Net.Decimal()}}
+	for _, t := range d.Taxes {
+		totals = append(totals, [2]string{fmt.Sprintf("VAT %s%% of %s", t.Rate, t.Net.Decimal()), t.Tax.Decimal()})
+	}
+	for _, total := range totals {
+		y += lineHeight
+		p.textRight(columns[4].right, y, textSize, false, total[0])
+		p.textRight(marginRight, y, textSize, false, total[1])
+	}
+	y += lineHeight + 4
+	p.textRight(columns[4].right, y, 11, true, "Total "+d.Currency)
+	p.textRight(marginRight, y, 11, true, d.Total.Decimal())
+
+	y += 20
+	for _, note := range d.Notes {
+		y += 12
+		p.text(marginLeft, y, textSize, false, note)
+	}
+
+	// The footer of every page.
+	footer := strings.Join(d.Seller, " - ")
+	for i := range p.pages {
+		p.setPage(i)
+		p.line(marginLeft, footerY-12, marginRight, footerY-12)
+		p.text(marginLeft, footerY, 7, false, truncate(footer, 7, marginRight-marginLeft-60))
+		p.textRight(marginRight, footerY, 7, false, fmt.Sprintf("Page %d of %d", i+1, len(p.pages)))
+	}
+
+	return p.bytes(d.Title+" "+d.Number, d.IssuedAt)
+}
+
+// tableHeader draws the headings of the table of lines with their baseline at y, and returns where the rows start.
+func tableHeader(p *pdfWriter, y float64) float64 {
+	p.text(marginLeft, y, textSize, true, "Description")
+	for _, c := range columns {
+		p.textRight(c.right, y, textSize, true, c.heading)
+	}
+	p.line(marginLeft, y+5, marginRight, y+5)
+
+	return y + 2
+}
+
+// truncate shortens text that is wider than width, ending it with an ellipsis.
+func truncate(s string, size, width float64) string {
+	if textWidth(s, size, false) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"…", size, false) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,305 @@
+package invoice
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	// Import the config package for the start of the fiscal year.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the auth package for the authentication middlewares.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle invoice requests.
+type Handler struct {
+	store     types.InvoiceStore // Interface for invoices and credit notes.
+	orders    types.OrderStore   // Interface for the orders invoiced.
+	returns   types.ReturnStore  // Interface for the returns credit notes pay back.
+	products  types.ProductStore // Interface for the names of the products invoiced.
+	userStore types.UserStore    // Interface for user-related data operations.
+	now       func() time.Time   // Returns the current time.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.InvoiceStore, orders types.OrderStore, returns types.ReturnStore, products types.ProductStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, orders: orders, returns: returns, products: products, userStore: userStore, now: time.Now}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the invoice routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Download the invoice of an order of the user, and the credit notes of its returns.
+	router.HandleFunc("/orders/{id:[0-9]+}/invoice.pdf", auth.WithAuth(h.handleGetMyInvoice, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/orders/{id:[0-9]+}/returns/{returnId:[0-9]+}/credit-note.pdf", auth.WithAuth(h.handleGetMyCreditNote, h.userStore)).Methods(http.MethodGet)
+
+	// Issue documents, and list and download them for the accounts.
+	router.HandleFunc("/admin/invoices", auth.WithAdminAuth(h.handleGetInvoices, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/invoices/{id:[0-9]+}.pdf", auth.WithAdminAuth(h.handleGetInvoicePDF, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/orders/{id:[0-9]+}/invoice", auth.WithAdminAuth(h.handleIssueInvoice, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/returns/{id:[0-9]+}/credit-note", auth.WithAdminAuth(h.handleIssueCreditNote, h.userStore)).Methods(http.MethodPost)
+}
+
+// handleGetMyInvoice handles GET /orders/{id}/invoice.pdf.
+// The invoice is issued the first time it is asked for, and the same document is returned ever after. Orders of
+// other users are reported as not found, so their IDs cannot be probed.
+func (h *Handler) handleGetMyInvoice(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	o, err := h.orders.GetOrderByID(id)
+	if err != nil || o.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order not found"))
+		return
+	}
+
+	inv, status, err := h.invoiceFor(o)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	h.writePDF(w, inv)
+}
+
+// handleGetMyCreditNote handles GET /orders/{id}/returns/{returnId}/credit-note.pdf.
+// Refunded returns have a credit note, issued the first time it is asked for.
+func (h *Handler) handleGetMyCreditNote(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	returnID, _ := strconv.Atoi(mux.Vars(r)["returnId"])
+
+	o, err := h.orders.GetOrderByID(id)
+	if err != nil || o.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order not found"))
+		return
+	}
+	ret, err := h.returns.GetReturnByID(returnID)
+	if err != nil || ret.OrderID != o.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("return not found"))
+		return
+	}
+
+	inv, status, err := h.creditNoteFor(o, ret)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	h.writePDF(w, inv)
+}
+
+// handleGetInvoices handles GET /admin/invoices.
+// The year query parameter, such as ?year=2024, picks the fiscal year; the current one by default.
+func (h *Handler) handleGetInvoices(w http.ResponseWriter, r *http.Request) {
+	year := fiscalYear(h.now(), int(config.Envs.FiscalYearStartMonth))
+	if v := r.URL.Query().Get("year"); v != "" {
+		var err error
+		if year, err = strconv.Atoi(v); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid year %q", v))
+			return
+		}
+	}
+
+	invoices, err := h.store.GetInvoices(year)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, invoices)
+}
+
+// handleGetInvoicePDF handles GET /admin/invoices/{id}.pdf.
+func (h *Handler) handleGetInvoicePDF(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	pdf, err := h.store.GetInvoicePDF(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="invoice-%d.pdf"`, id))
+	w.Write(pdf)
+}
+
+// handleIssueInvoice handles POST /admin/orders/{id}/invoice.
+// It issues the invoice of an order, or returns the one issued before.
+func (h *Handler) handleIssueInvoice(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	o, err := h.orders.GetOrderByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	inv, status, err := h.invoiceFor(o)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, inv)
+}
+
+// handleIssueCreditNote handles POST /admin/returns/{id}/credit-note.
+// It issues the credit note of a refunded return, or returns the one issued before.
+func (h *Handler) handleIssueCreditNote(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	ret, err := h.returns.GetReturnByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	o, err := h.orders.GetOrderByID(ret.OrderID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	inv, status, err := h.creditNoteFor(o, ret)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, inv)
+}
+
+// invoiceFor returns the invoice of an order, issuing it if it has none yet. Cancelled orders only have an invoice
+// if it was issued before they were cancelled. On failure it also returns the status code to respond with.
+func (h *Handler) invoiceFor(o *types.Order) (*types.Invoice, int, error) {
+	source := fmt.Sprintf("order:%d", o.ID)
+	if o.Status == types.OrderCancelled {
+		inv, err := h.store.GetInvoiceBySource(source)
+		if err != nil {
+			return nil, http.StatusNotFound, fmt.Errorf("order %d was cancelled without an invoice", o.ID)
+		}
+		return inv, 0, nil
+	}
+
+	u, err := h.userStore.GetUserByID(o.UserID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	d, err := invoiceDocument(o, u, h.productNames(o))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	inv, err := h.issue(types.Invoice{
+		Kind:    types.InvoiceKindInvoice,
+		Source:  source,
+		OrderID: o.ID,
+		UserID:  o.UserID,
+	}, d)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return inv, 0, nil
+}
+
+// creditNoteFor returns the credit note of a refunded return, issuing it if it has none yet. The invoice of the
+// order it corrects is issued first if need be. On failure it also returns the status code to respond with.
+func (h *Handler) creditNoteFor(o *types.Order, ret *types.Return) (*types.Invoice, int, error) {
+	if ret.Status != types.ReturnRefunded {
+		return nil, http.StatusBadRequest, fmt.Errorf("return %d is %s, not refunded", ret.ID, ret.Status)
+	}
+
+	corrects, status, err := h.invoiceFor(o)
+	if err != nil {
+		return nil, status, err
+	}
+
+	u, err := h.userStore.GetUserByID(o.UserID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	d, err := creditNoteDocument(o, u, ret, corrects, h.productNames(o))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	inv, err := h.issue(types.Invoice{
+		Kind:       types.InvoiceKindCreditNote,
+		Source:     fmt.Sprintf("return:%d", ret.ID),
+		OrderID:    o.ID,
+		ReturnID:   &ret.ID,
+		CorrectsID: &corrects.ID,
+		UserID:     o.UserID,
+	}, d)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return inv, 0, nil
+}
+
+// issue issues a document dated now, numbered in the current fiscal year, with the totals of its content.
+func (h *Handler) issue(inv types.Invoice, d *document) (*types.Invoice, error) {
+	inv.IssuedAt = h.now().UTC().Truncate(time.Second)
+	inv.FiscalYear = fiscalYear(inv.IssuedAt, int(config.Envs.FiscalYearStartMonth))
+	inv.Net, inv.Tax, inv.Total = d.Net, d.Tax, d.Total
+
+	return h.store.IssueInvoice(inv, func(inv types.Invoice) ([]byte, error) {
+		d.Number = inv.Number
+		d.IssuedAt = inv.IssuedAt
+		return render(d)
+	})
+}
+
+// productNames returns the names of the products of the items of an order, by SKU. Products that are gone are
+// left out.
+func (h *Handler) productNames(o *types.Order) map[string]string {
+	names := map[string]string{}
+	for _, item := range o.Items {
+		if p, err := h.products.GetProductBySKU(item.SKU); err == nil {
+			names[item.SKU] = p.Name
+		}
+	}
+	return names
+}
+
+// writePDF writes the PDF of a document, named after its number.
+func (h *Handler) writePDF(w http.ResponseWriter, inv *types.Invoice) {
+	pdf, err := h.store.GetInvoicePDF(inv.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, inv.Number))
+	w.Write(pdf)
+}
+
+// fiscalYear returns the fiscal year a time falls in, named after the calendar year it starts in. StartMonth is
+// the month fiscal years start in, 1 for January.
+func fiscalYear(t time.Time, startMonth int) int {
+	if startMonth < 1 || startMonth > 12 {
+		startMonth = 1
+	}
+	if int(t.Month()) < startMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// formatNumber returns the number of a document, such as "INV-2024-000001" or "CN-2024-000001".
+func formatNumber(kind string, fiscalYear, sequence int) string {
+	prefix := "INV"
+	if kind == types.InvoiceKindCreditNote {
+		prefix = "CN"
+	}
+	return fmt.Sprintf("%s-%d-%06d", prefix, fiscalYear, sequence)
+}
@@ -0,0 +1,394 @@
+package invoice
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for issue dates and fiscal years
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret and fiscal year
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for invoice types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestInvoiceHandlers tests issuing and downloading invoices and credit notes.
+func TestInvoiceHandlers(t *testing.T) {
+	eur := func(amount int64) types.Money { return types.NewMoney(amount, "EUR") }
+
+	// Create the stores the invoices are made from. There is an admin (ID 1), Jane (ID 2) and another customer
+	// (ID 3). Jane's order 1 holds 2 bags of coffee taxed at 9% and a mug taxed at 21%, shipped for 4.95 with
+	// prices including tax; one bag was returned and refunded (return 1), the mug is being returned (return 2). Her
+	// order 2 was cancelled and order 3 is pending. Each test issues its documents into an empty invoice store, on
+	// 1 May 2024.
+	order := func(id int, status string) *types.Order {
+		return &types.Order{
+			ID: id, UserID: 2, Status: status, CreatedAt: time.Date(2024, 4, 28, 9, 0, 0, 0, time.UTC),
+			Items: []types.OrderItem{
+				{ID: id*10 + 1, SKU: "COFFEE", Quantity: 2, UnitPrice: eur(1090), Discount: eur(180), TaxRate: "9", Tax: eur(165), Total: eur(2000)},
+				{ID: id*10 + 2, SKU: "MUG", Quantity: 1, UnitPrice: eur(1210), Discount: eur(0), TaxRate: "21", Tax: eur(210), Total: eur(1210)},
+			},
+			Total: eur(3705), Tax: eur(461), PricesIncludeTax: true, Address: &types.Address{Country: "NL", PostalCode: "1011 AB"},
+			ShippingMethod: "Standard", Shipping: eur(495), ShippingTax: eur(86), ShippingTaxRate: "21",
+			GiftCardAmount: eur(705), AmountDue: eur(3000),
+		}
+	}
+	orders := &mockOrderStore{orders: map[int]*types.Order{
+		1: order(1, types.OrderDelivered),
+		2: order(2, types.OrderCancelled),
+		3: order(3, types.OrderPending),
+	}}
+	returns := &mockReturnStore{returns: map[int]*types.Return{
+		1: {ID: 1, OrderID: 1, Status: types.ReturnRefunded, Refund: eur(1000), ProviderRefund: eur(1000), Lines: []types.ReturnLine{
+			{OrderItemID: 11, SKU: "COFFEE", Quantity: 1, Refund: eur(1000)},
+		}},
+		2: {ID: 2, OrderID: 1, Status: types.ReturnApproved, Lines: []types.ReturnLine{{OrderItemID: 12, SKU: "MUG", Quantity: 1}}},
+	}}
+	products := &mockProductStore{names: map[string]string{"COFFEE": "Organic coffee"}}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleAdmin},
+		2: {ID: 2, Role: types.RoleCustomer, FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"},
+		3: {ID: 3, Role: types.RoleCustomer},
+	}}
+
+	t.Run("should issue the invoice of an order once", func(t *testing.T) {
+		store := &mockInvoiceStore{}
+		h := NewHandler(store, orders, returns, products, userStore)
+		h.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		rr := send(t, router, http.MethodGet, "/orders/1/invoice.pdf", "", 2)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if rr.Header().Get("Content-Type") != "application/pdf" || !strings.Contains(rr.Header().Get("Content-Disposition"), `filename="INV-2024-000001.pdf"`) {
+			t.Errorf("expected the PDF of invoice INV-2024-000001, got %v", rr.Header())
+		}
+		text := pdfText(t, rr.Body.Bytes())
+		for _, expected := range []string{
+			"(Number: INV-2024-000001)", "(Date: 2024-05-01)", "(Jane Doe)", "(1011 AB NL)",
+			"(Organic coffee \\(COFFEE\\))", "(-1.80)", "(MUG)", "(Shipping Standard)",
+			"(VAT 9% of 18.35)", "(1.65)", "(VAT 21% of 14.09)", "(2.96)", "(37.05)",
+			"(Paid with gift cards: 7.05 EUR)", "(Unit prices include VAT.)", "(Page 1 of 1)",
+		} {
+			if !strings.Contains(text, expected) {
+				t.Errorf("expected the invoice to show %q", expected)
+			}
+		}
+
+		inv := store.invoices[0]
+		if inv.Net != eur(3244) || inv.Tax != eur(461) || inv.Total != eur(3705) || inv.FiscalYear != 2024 || inv.Source != "order:1" {
+			t.Errorf("expected an invoice of 37.05 with 4.61 tax in 2024, got %+v", inv)
+		}
+
+		if rr := send(t, router, http.MethodGet, "/orders/1/invoice.pdf", "", 2); rr.Code != http.StatusOK || len(store.invoices) != 1 || store.renders != 1 {
+			t.Errorf("expected the same invoice again, got %d with %d invoices", rr.Code, len(store.invoices))
+		}
+		if rr := send(t, router, http.MethodGet, "/orders/1/invoice.pdf", "", 3); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for the invoice of another customer, got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/orders/2/invoice.pdf", "", 2); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for a cancelled order, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should number invoices without gaps per fiscal year", func(t *testing.T) {
+		store := &mockInvoiceStore{}
+		h := NewHandler(store, orders, returns, products, userStore)
+		h.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		send(t, router, http.MethodPost, "/admin/orders/1/invoice", "", 1)
+		rr := send(t, router, http.MethodPost, "/admin/orders/3/invoice", "", 1)
+		var inv types.Invoice
+		json.NewDecoder(rr.Body).Decode(&inv)
+		if rr.Code != http.StatusOK || inv.Number != "INV-2024-000002" || inv.Sequence != 2 {
+			t.Errorf("expected the second invoice of 2024, got %d: %+v", rr.Code, inv)
+		}
+
+		// The renderer fails once: the number is not used up.
+		store.invoices = store.invoices[:1]
+		store.last[types.InvoiceKindInvoice+"/2024"] = 1
+		store.fail = true
+		if rr := send(t, router, http.MethodPost, "/admin/orders/3/invoice", "", 1); rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+		store.fail = false
+		rr = send(t, router, http.MethodPost, "/admin/orders/3/invoice", "", 1)
+		json.NewDecoder(rr.Body).Decode(&inv)
+		if inv.Number != "INV-2024-000002" {
+			t.Errorf("expected the failed number to be used again, got %+v", inv)
+		}
+
+		// A fiscal year starting in July puts March 2025 in 2024, and July 2025 in 2025.
+		config.Envs.FiscalYearStartMonth = 7
+		defer func() { config.Envs.FiscalYearStartMonth = 1 }()
+		store.invoices = store.invoices[:0]
+		h.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+		rr = send(t, router, http.MethodPost, "/admin/orders/1/invoice", "", 1)
+		json.NewDecoder(rr.Body).Decode(&inv)
+		if inv.Number != "INV-2024-000003" {
+			t.Errorf("expected the third invoice of fiscal year 2024, got %+v", inv)
+		}
+		h.now = func() time.Time { return time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC) }
+		rr = send(t, router, http.MethodPost, "/admin/orders/3/invoice", "", 1)
+		json.NewDecoder(rr.Body).Decode(&inv)
+		if inv.Number != "INV-2025-000001" {
+			t.Errorf("expected the first invoice of fiscal year 2025, got %+v", inv)
+		}
+	})
+
+	t.Run("should issue credit notes for refunded returns", func(t *testing.T) {
+		store := &mockInvoiceStore{}
+		h := NewHandler(store, orders, returns, products, userStore)
+		h.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		rr := send(t, router, http.MethodGet, "/orders/1/returns/1/credit-note.pdf", "", 2)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if len(store.invoices) != 2 || store.invoices[0].Kind != types.InvoiceKindInvoice {
+			t.Fatalf("expected the invoice to be issued before the credit note, got %+v", store.invoices)
+		}
+		cn := store.invoices[1]
+		if cn.Number != "CN-2024-000001" || cn.CorrectsID == nil || *cn.CorrectsID != store.invoices[0].ID || cn.ReturnID == nil || *cn.ReturnID != 1 {
+			t.Errorf("expected the first credit note, correcting the invoice, got %+v", cn)
+		}
+		if cn.Total != eur(-1000) || cn.Tax != eur(-83) || cn.Net != eur(-917) {
+			t.Errorf("expected -10.00 of which -0.83 tax, got %+v", cn)
+		}
+		text := pdfText(t, rr.Body.Bytes())
+		for _, expected := range []string{"(Credit note)", "(Corrects invoice: INV-2024-000001)", "(-1)", "(VAT 9% of -9.17)", "(Paid back by card or bank: 10.00 EUR)"} {
+			if !strings.Contains(text, expected) {
+				t.Errorf("expected the credit note to show %q", expected)
+			}
+		}
+
+		if rr := send(t, router, http.MethodGet, "/orders/1/returns/2/credit-note.pdf", "", 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for a return not refunded, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/orders/3/returns/1/credit-note.pdf", "", 2); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for a return of another order, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should list the documents of a fiscal year for admins", func(t *testing.T) {
+		h := NewHandler(&mockInvoiceStore{}, orders, returns, products, userStore)
+		h.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+		send(t, router, http.MethodPost, "/admin/returns/1/credit-note", "", 1)
+
+		rr := send(t, router, http.MethodGet, "/admin/invoices?year=2024", "", 1)
+		var invoices []types.Invoice
+		json.NewDecoder(rr.Body).Decode(&invoices)
+		if rr.Code != http.StatusOK || len(invoices) != 2 {
+			t.Errorf("expected the invoice and credit note of 2024, got %d: %+v", rr.Code, invoices)
+		}
+		if rr := send(t, router, http.MethodGet, "/admin/invoices?year=2023", "", 1); rr.Body.String() != "[]\n" {
+			t.Errorf("expected no documents in 2023, got %s", rr.Body)
+		}
+
+		rr = send(t, router, http.MethodGet, fmt.Sprintf("/admin/invoices/%d.pdf", invoices[1].ID), "", 1)
+		if rr.Code != http.StatusOK || !strings.Contains(pdfText(t, rr.Body.Bytes()), "(Credit note)") {
+			t.Errorf("expected the PDF of the credit note, got %d", rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/admin/invoices", "", 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a customer, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+}
+
+// TestFiscalYear tests which fiscal year a time falls in.
+func TestFiscalYear(t *testing.T) {
+	cases := []struct {
+		date       time.Time
+		startMonth int
+		expected   int
+	}{
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1, 2024},
+		{time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC), 1, 2024},
+		{time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), 4, 2023},
+		{time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 4, 2024},
+		{time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), 0, 2024},
+	}
+	for _, c := range cases {
+		if year := fiscalYear(c.date, c.startMonth); year != c.expected {
+			t.Errorf("expected %v to fall in fiscal year %d starting in month %d, got %d", c.date, c.expected, c.startMonth, year)
+		}
+	}
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockInvoiceStore is an in-memory implementation of the InvoiceStore interface.
+type mockInvoiceStore struct {
+	invoices []*types.Invoice // Documents, in the order they were issued.
+	pdfs     map[int][]byte   // PDFs by document ID.
+	last     map[string]int   // Last number by kind and fiscal year.
+	nextID   int              // ID of the last document.
+	renders  int              // Number of documents rendered.
+	fail     bool             // Whether rendering fails.
+}
+
+// IssueInvoice is a mock method that numbers, renders and stores a document, or returns the one of its source.
+func (m *mockInvoiceStore) IssueInvoice(inv types.Invoice, render func(types.Invoice) ([]byte, error)) (*types.Invoice, error) {
+	for _, existing := range m.invoices {
+		if existing.Source == inv.Source {
+			c := *existing
+			return &c, nil
+		}
+	}
+	if m.last == nil {
+		m.last, m.pdfs = map[string]int{}, map[int][]byte{}
+	}
+
+	key := fmt.Sprintf("%s/%d", inv.Kind, inv.FiscalYear)
+	inv.Sequence = m.last[key] + 1
+	inv.Number = formatNumber(inv.Kind, inv.FiscalYear, inv.Sequence)
+	if m.fail {
+		return nil, fmt.Errorf("render failed")
+	}
+	pdf, err := render(inv)
+	if err != nil {
+		return nil, err
+	}
+	m.renders++
+
+	m.nextID++
+	inv.ID = m.nextID
+	m.last[key] = inv.Sequence
+	m.pdfs[inv.ID] = pdf
+	m.invoices = append(m.invoices, &inv)
+
+	c := inv
+	return &c, nil
+}
+
+// GetInvoiceBySource is a mock method that returns the document of a source.
+func (m *mockInvoiceStore) GetInvoiceBySource(source string) (*types.Invoice, error) {
+	for _, inv := range m.invoices {
+		if inv.Source == source {
+			c := *inv
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("invoice not found")
+}
+
+// GetInvoices is a mock method that returns the documents of a fiscal year.
+func (m *mockInvoiceStore) GetInvoices(fiscalYear int) ([]*types.Invoice, error) {
+	invoices := []*types.Invoice{}
+	for _, inv := range m.invoices {
+		if inv.FiscalYear == fiscalYear {
+			invoices = append(invoices, inv)
+		}
+	}
+	return invoices, nil
+}
+
+// GetInvoicePDF is a mock method that returns the PDF of a document.
+func (m *mockInvoiceStore) GetInvoicePDF(id int) ([]byte, error) {
+	pdf, ok := m.pdfs[id]
+	if !ok {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	return pdf, nil
+}
+
+// mockOrderStore is an in-memory implementation of the parts of the OrderStore interface used by the handler.
+type mockOrderStore struct {
+	types.OrderStore
+	orders map[int]*types.Order // Orders by ID.
+}
+
+// GetOrderByID is a mock method that returns a copy of the order with the given ID.
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	c := *o
+	return &c, nil
+}
+
+// mockReturnStore is an in-memory implementation of the parts of the ReturnStore interface used by the handler.
+type mockReturnStore struct {
+	types.ReturnStore
+	returns map[int]*types.Return // Returns by ID.
+}
+
+// GetReturnByID is a mock method that returns a copy of the return with the given ID.
+func (m *mockReturnStore) GetReturnByID(id int) (*types.Return, error) {
+	ret, ok := m.returns[id]
+	if !ok {
+		return nil, fmt.Errorf("return not found")
+	}
+
+	c := *ret
+	return &c, nil
+}
+
+// mockProductStore is an in-memory implementation of the parts of the ProductStore interface used by the handler.
+type mockProductStore struct {
+	types.ProductStore
+	names map[string]string // Names of the products by SKU.
+}
+
+// GetProductBySKU is a mock method that returns a product with the name of the SKU.
+func (m *mockProductStore) GetProductBySKU(sku string) (*types.Product, error) {
+	name, ok := m.names[sku]
+	if !ok {
+		return nil, types.ErrProductNotFound
+	}
+	return &types.Product{SKU: sku, Name: name}, nil
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
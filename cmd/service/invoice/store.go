@@ -0,0 +1,189 @@
+package invoice
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+
+	// Import the types package for the invoice types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// invoiceColumns lists the columns of the invoices table, in the order scanRowIntoInvoice reads them.
+const invoiceColumns = "id, kind, number, fiscalYear, sequence, source, orderId, returnId, correctsId, userId, currency, net, tax, total, issuedAt"
+
+// Store struct represents the data store of invoices and credit notes.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// IssueInvoice is a method on the Store struct that numbers, renders and stores a document in a single
+// transaction. The sequence of its kind and fiscal year is locked until the transaction ends, so documents are
+// numbered one at a time, and a failure anywhere rolls the number back with the rest: numbers have no gaps.
+// A source that already has a document gets that one back.
+func (s *Store) IssueInvoice(inv types.Invoice, render func(types.Invoice) ([]byte, error)) (*types.Invoice, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO invoice_sequences (kind, fiscalYear) VALUES (?, ?) ON DUPLICATE KEY UPDATE lastNumber = lastNumber",
+		inv.Kind, inv.FiscalYear,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var last int
+	err = tx.QueryRow(
+		"SELECT lastNumber FROM invoice_sequences WHERE kind = ? AND fiscalYear = ? FOR UPDATE",
+		inv.Kind, inv.FiscalYear,
+	).Scan(&last)
+	if err != nil {
+		return nil, err
+	}
+
+	// Documents are issued one at a time now, so a document of the source cannot appear until we commit.
+	rows, err := tx.Query("SELECT "+invoiceColumns+" FROM invoices WHERE source = ?", inv.Source)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := scanInvoices(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return existing[0], nil
+	}
+
+	inv.Sequence = last + 1
+	inv.Number = formatNumber(inv.Kind, inv.FiscalYear, inv.Sequence)
+	pdf, err := render(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.Exec(
+		`INSERT INTO invoices (kind, number, fiscalYear, sequence, source, orderId, returnId, correctsId, userId, currency, net, tax, total, pdf, issuedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		inv.Kind, inv.Number, inv.FiscalYear, inv.Sequence, inv.Source, inv.OrderID, inv.ReturnID, inv.CorrectsID, inv.UserID,
+		inv.Total.Currency, inv.Net.Decimal(), inv.Tax.Decimal(), inv.Total.Decimal(), pdf, inv.IssuedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	inv.ID = int(id)
+
+	_, err = tx.Exec(
+		"UPDATE invoice_sequences SET lastNumber = ? WHERE kind = ? AND fiscalYear = ?",
+		inv.Sequence, inv.Kind, inv.FiscalYear,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, tx.Commit()
+}
+
+// GetInvoiceBySource is a method on the Store struct that retrieves the document issued for a source.
+func (s *Store) GetInvoiceBySource(source string) (*types.Invoice, error) {
+	rows, err := s.db.Query("SELECT "+invoiceColumns+" FROM invoices WHERE source = ?", source)
+	if err != nil {
+		return nil, err
+	}
+	invoices, err := scanInvoices(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(invoices) == 0 {
+		return nil, fmt.Errorf("invoice not found")
+	}
+
+	return invoices[0], nil
+}
+
+// GetInvoices is a method on the Store struct that retrieves the documents of a fiscal year, invoices first, by
+// number.
+func (s *Store) GetInvoices(fiscalYear int) ([]*types.Invoice, error) {
+	rows, err := s.db.Query("SELECT "+invoiceColumns+" FROM invoices WHERE fiscalYear = ? ORDER BY kind DESC, sequence", fiscalYear)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanInvoices(rows)
+}
+
+// GetInvoicePDF is a method on the Store struct that retrieves the PDF of a document.
+func (s *Store) GetInvoicePDF(id int) ([]byte, error) {
+	var pdf []byte
+	err := s.db.QueryRow("SELECT pdf FROM invoices WHERE id = ?", id).Scan(&pdf)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invoice not found")
+	}
+
+	return pdf, err
+}
+
+// scanInvoices scans and closes rows selected with invoiceColumns.
+func scanInvoices(rows *sql.Rows) ([]*types.Invoice, error) {
+	defer rows.Close()
+
+	invoices := []*types.Invoice{}
+	for rows.Next() {
+		inv, err := scanRowIntoInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+
+	return invoices, rows.Err()
+}
+
+// scanRowIntoInvoice scans a row selected with invoiceColumns into an Invoice.
+func scanRowIntoInvoice(rows *sql.Rows) (*types.Invoice, error) {
+	inv := new(types.Invoice)
+	var currency, net, tax, total string
+	var returnID, correctsID sql.NullInt64
+
+	err := rows.Scan(&inv.ID, &inv.Kind, &inv.Number, &inv.FiscalYear, &inv.Sequence, &inv.Source, &inv.OrderID,
+		&returnID, &correctsID, &inv.UserID, &currency, &net, &tax, &total, &inv.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, amount := range []struct {
+		dest  *types.Money
+		value string
+	}{
+		{&inv.Net, net},
+		{&inv.Tax, tax},
+		{&inv.Total, total},
+	} {
+		if *amount.dest, err = types.ParseMoney(amount.value, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	if returnID.Valid {
+		id := int(returnID.Int64)
+		inv.ReturnID = &id
+	}
+	if correctsID.Valid {
+		id := int(correctsID.Int64)
+		inv.CorrectsID = &id
+	}
+
+	return inv, nil
+}
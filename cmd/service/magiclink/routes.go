@@ -0,0 +1,256 @@
+package magiclink
+
+import (
+	// Import the crypto packages for generating, signing and hashing tokens.
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	// Import the config package for the link settings and the signing secret.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the auth package for issuing the login token.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store and mailer interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// deviceCookieName is the name of the cookie binding a login link to the browser that requested it.
+const deviceCookieName = "magic_link_device"
+
+// Handler struct groups the methods that handle passwordless login requests.
+type Handler struct {
+	userStore types.UserStore      // Interface for user-related data operations.
+	linkStore types.MagicLinkStore // Interface for storing login links.
+	mailer    types.Mailer         // Interface for sending the login links.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(userStore types.UserStore, linkStore types.MagicLinkStore, mailer types.Mailer) *Handler {
+	return &Handler{userStore: userStore, linkStore: linkStore, mailer: mailer}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the passwordless login routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Request a login link by email.
+	router.HandleFunc("/login/magic-link", h.handleRequestLink).Methods(http.MethodPost)
+
+	// Exchange the token from a login link for a login token. This is a POST, so email scanners
+	// that open links cannot use them up.
+	router.HandleFunc("/login/magic-link/exchange", h.handleExchangeLink).Methods(http.MethodPost)
+}
+
+// handleRequestLink handles POST /login/magic-link.
+// It emails a single-use login link valid for config.Envs.MagicLinkExpirationInSeconds, bound to the
+// requesting browser through a device cookie. The response is the same whether or not the email belongs
+// to an account, so the endpoint cannot be used to find out which emails are registered.
+func (h *Handler) handleRequestLink(w http.ResponseWriter, r *http.Request) {
+	var payload types.MagicLinkRequestPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+	email := strings.ToLower(payload.Email)
+
+	// Limit the number of links per email. Links are counted for every email, registered or not.
+	window := time.Second * time.Duration(config.Envs.MagicLinkWindowInSeconds)
+	n, err := h.linkStore.CountMagicLinksSince(email, time.Now().Add(-window))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if int64(n) >= config.Envs.MagicLinkMaxPerWindow {
+		utils.WriteError(w, http.StatusTooManyRequests, fmt.Errorf("too many login links requested, please try again later"))
+		return
+	}
+
+	// Reuse the browser's device cookie if it has one, so requesting a second link does not break the first.
+	device, err := randomString()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if c, err := r.Cookie(deviceCookieName); err == nil && c.Value != "" {
+		device = c.Value
+	}
+
+	// Create the token and store the link.
+	token, tokenHash, err := newToken()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	lifetime := time.Second * time.Duration(config.Envs.MagicLinkExpirationInSeconds)
+	err = h.linkStore.CreateMagicLink(types.MagicLink{
+		Email:      email,
+		TokenHash:  tokenHash,
+		DeviceHash: hashString(device),
+		ExpiresAt:  time.Now().Add(lifetime).UTC(),
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Bind the link to this browser.
+	http.SetCookie(w, &http.Cookie{
+		Name:     deviceCookieName,
+		Value:    device,
+		Path:     "/",
+		MaxAge:   int(lifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.Envs.PublicHost, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Only send the email to enabled accounts.
+	if u, err := h.userStore.GetUserByEmail(email); err == nil && !u.Disabled {
+		err := h.mailer.Send(types.Email{
+			To:      u.Email,
+			Subject: "Your login link",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nUse this link to log in. It works once, in the browser you requested it from, for the next %d minutes:\n\n%s%s\n\nIf you did not request this link, you can ignore this email.\n",
+				u.FirstName, int(lifetime.Minutes()), config.Envs.MagicLinkURL, token,
+			),
+		})
+		if err != nil {
+			log.Printf("failed to send login link to user %d: %v", u.ID, err)
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if an account exists for this email, a login link has been sent",
+	})
+}
+
+// handleExchangeLink handles POST /login/magic-link/exchange.
+// It checks the token, its expiry and the device cookie, uses the link up and returns the same
+// response as a password login.
+func (h *Handler) handleExchangeLink(w http.ResponseWriter, r *http.Request) {
+	var payload types.MagicLinkExchangePayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// Check the token's signature before touching the database.
+	tokenHash, ok := verifyToken(payload.Token)
+	if !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid or expired login link"))
+		return
+	}
+
+	link, err := h.linkStore.GetMagicLinkByTokenHash(tokenHash)
+	if err != nil || link.UsedAt != nil || time.Now().After(link.ExpiresAt) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid or expired login link"))
+		return
+	}
+
+	// The link only works in the browser that requested it. The link is not used up here, so a
+	// forwarded link that fails this check still works for its owner.
+	c, err := r.Cookie(deviceCookieName)
+	if err != nil || subtle.ConstantTimeCompare([]byte(hashString(c.Value)), []byte(link.DeviceHash)) != 1 {
+		utils.WriteError(w, http.StatusForbidden, fmt.Errorf("this login link must be opened in the browser it was requested from"))
+		return
+	}
+
+	// Load the user.
+	u, err := h.userStore.GetUserByEmail(link.Email)
+	if err != nil || u.Disabled {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid or expired login link"))
+		return
+	}
+
+	// Use the link up. Only one of several concurrent exchanges can succeed.
+	if err := h.linkStore.MarkMagicLinkUsed(link.ID); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid or expired login link"))
+		return
+	}
+
+	// Following the link proves the user owns the email address.
+	if !u.Verified {
+		u.Verified = true
+		if err := h.userStore.UpdateUser(*u); err != nil {
+			log.Printf("failed to mark user %d as verified: %v", u.ID, err)
+		}
+	}
+
+	// Issue the same token as a password login.
+	res, err := auth.CreateLoginResponse(u)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// newToken creates a new signed login link token and returns it with the hash to store.
+// The token is a random value followed by its HMAC, so forged tokens are rejected without a database lookup.
+func newToken() (token, tokenHash string, err error) {
+	value, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	return value + "." + sign(value), hashString(value), nil
+}
+
+// verifyToken checks the signature of a token and returns the hash of its random value.
+func verifyToken(token string) (string, bool) {
+	value, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(value))) {
+		return "", false
+	}
+
+	return hashString(value), true
+}
+
+// sign returns the base64url-encoded HMAC-SHA256 of a value, keyed with the JWT secret.
+func sign(value string) string {
+	mac := hmac.New(sha256.New, []byte(config.Envs.JWTSecret))
+	mac.Write([]byte("magic-link:" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashString returns the hex-encoded SHA-256 hash of a string.
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomString returns a random, URL-safe string.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
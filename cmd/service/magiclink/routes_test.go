@@ -0,0 +1,220 @@
+package magiclink
+
+import (
+	"bytes"             // Import the bytes package to build request bodies
+	"encoding/json"     // Import the encoding/json package for JSON encoding and decoding
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to find the token in the email
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for link timestamps
+
+	"github.com/FreekAlberti/Ecom/cmd/config" // Import the config package for the link settings
+	"github.com/FreekAlberti/Ecom/cmd/types"  // Import the custom types package for user-related types
+	"github.com/gorilla/mux"                  // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestMagicLinkHandlers tests requesting and exchanging passwordless login links.
+func TestMagicLinkHandlers(t *testing.T) {
+	// Create a user store with one user. Each test gets its own links and mailer, since requests are rate limited
+	// per email.
+	userStore := &mockUserStore{users: map[string]*types.User{
+		"jane@example.com": {ID: 1, Email: "jane@example.com", FirstName: "Jane"},
+	}}
+
+	t.Run("should email a link that logs in once, from the requesting browser", func(t *testing.T) {
+		mailer := &mockMailer{}
+		router := mux.NewRouter()
+		NewHandler(userStore, &mockLinkStore{}, mailer).RegisterRoutes(router)
+
+		rr := post(router, "/login/magic-link", `{"email": "Jane@example.com"}`, nil)
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body)
+		}
+		if len(mailer.sent) != 1 {
+			t.Fatalf("expected one email, got %d", len(mailer.sent))
+		}
+		token := tokenFromEmail(t, mailer.sent[0])
+		cookies := rr.Result().Cookies()
+
+		// Another browser cannot use the link.
+		body := fmt.Sprintf(`{"token": %q}`, token)
+		if rr := post(router, "/login/magic-link/exchange", body, nil); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d without the device cookie, got %d", http.StatusForbidden, rr.Code)
+		}
+
+		// The requesting browser can, and receives a login token.
+		rr = post(router, "/login/magic-link/exchange", body, cookies)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var res map[string]any
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res["token"] == "" || res["token"] == nil {
+			t.Errorf("expected a login token, got %v", res)
+		}
+		if !userStore.users["jane@example.com"].Verified {
+			t.Error("expected the user to be marked as verified")
+		}
+
+		// The link only works once.
+		if rr := post(router, "/login/magic-link/exchange", body, cookies); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d on reuse, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should reject expired and forged tokens", func(t *testing.T) {
+		linkStore := &mockLinkStore{}
+		mailer := &mockMailer{}
+		router := mux.NewRouter()
+		NewHandler(userStore, linkStore, mailer).RegisterRoutes(router)
+
+		rr := post(router, "/login/magic-link", `{"email": "jane@example.com"}`, nil)
+		token := tokenFromEmail(t, mailer.sent[0])
+		cookies := rr.Result().Cookies()
+
+		// Tamper with the signature.
+		if rr := post(router, "/login/magic-link/exchange", fmt.Sprintf(`{"token": %q}`, token+"x"), cookies); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for a forged token, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		// Let the link expire.
+		linkStore.links[0].ExpiresAt = time.Now().Add(-time.Second)
+		if rr := post(router, "/login/magic-link/exchange", fmt.Sprintf(`{"token": %q}`, token), cookies); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an expired link, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should answer the same for unknown emails without sending anything", func(t *testing.T) {
+		mailer := &mockMailer{}
+		router := mux.NewRouter()
+		NewHandler(userStore, &mockLinkStore{}, mailer).RegisterRoutes(router)
+
+		if rr := post(router, "/login/magic-link", `{"email": "nobody@example.com"}`, nil); rr.Code != http.StatusAccepted {
+			t.Errorf("expected status code %d, got %d", http.StatusAccepted, rr.Code)
+		}
+		if len(mailer.sent) != 0 {
+			t.Errorf("expected no emails, got %d", len(mailer.sent))
+		}
+	})
+
+	t.Run("should rate limit requests per email", func(t *testing.T) {
+		router := mux.NewRouter()
+		NewHandler(userStore, &mockLinkStore{}, &mockMailer{}).RegisterRoutes(router)
+
+		for i := int64(0); i < config.Envs.MagicLinkMaxPerWindow; i++ {
+			if rr := post(router, "/login/magic-link", `{"email": "jane@example.com"}`, nil); rr.Code != http.StatusAccepted {
+				t.Fatalf("expected status code %d, got %d", http.StatusAccepted, rr.Code)
+			}
+		}
+
+		if rr := post(router, "/login/magic-link", `{"email": "jane@example.com"}`, nil); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status code %d, got %d", http.StatusTooManyRequests, rr.Code)
+		}
+	})
+}
+
+// post sends a JSON POST request with the given cookies through the router and returns the recorded response.
+func post(router *mux.Router, target, body string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString(body))
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// tokenFromEmail extracts the login token from the link in an email.
+func tokenFromEmail(t *testing.T, email types.Email) string {
+	t.Helper()
+
+	i := strings.Index(email.Body, config.Envs.MagicLinkURL)
+	if i < 0 {
+		t.Fatalf("no login link in email: %s", email.Body)
+	}
+
+	return strings.Fields(email.Body[i+len(config.Envs.MagicLinkURL):])[0]
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[string]*types.User // Users by email.
+}
+
+// GetUserByEmail is a mock method that looks up a user by email.
+func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
+	u, ok := m.users[email]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	c := *u
+	return &c, nil
+}
+
+// UpdateUser is a mock method that replaces the stored user.
+func (m *mockUserStore) UpdateUser(u types.User) error {
+	m.users[u.Email] = &u
+	return nil
+}
+
+// mockLinkStore is an in-memory implementation of the MagicLinkStore interface.
+type mockLinkStore struct {
+	links []*types.MagicLink // Links, where the ID is the index plus one.
+}
+
+// CreateMagicLink is a mock method that appends the link.
+func (m *mockLinkStore) CreateMagicLink(link types.MagicLink) error {
+	link.ID = len(m.links) + 1
+	link.CreatedAt = time.Now()
+	m.links = append(m.links, &link)
+	return nil
+}
+
+// CountMagicLinksSince is a mock method that counts the links for an email created since the given time.
+func (m *mockLinkStore) CountMagicLinksSince(email string, since time.Time) (int, error) {
+	n := 0
+	for _, l := range m.links {
+		if l.Email == email && !l.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// GetMagicLinkByTokenHash is a mock method that looks up a link by the hash of its token.
+func (m *mockLinkStore) GetMagicLinkByTokenHash(tokenHash string) (*types.MagicLink, error) {
+	for _, l := range m.links {
+		if l.TokenHash == tokenHash {
+			c := *l
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("magic link not found")
+}
+
+// MarkMagicLinkUsed is a mock method that marks a link as used, failing if it already was.
+func (m *mockLinkStore) MarkMagicLinkUsed(id int) error {
+	l := m.links[id-1]
+	if l.UsedAt != nil {
+		return fmt.Errorf("magic link already used")
+	}
+	now := time.Now()
+	l.UsedAt = &now
+	return nil
+}
+
+// mockMailer is an implementation of the Mailer interface that records the emails instead of sending them.
+type mockMailer struct {
+	sent []types.Email
+}
+
+// Send is a mock method that records the email.
+func (m *mockMailer) Send(email types.Email) error {
+	m.sent = append(m.sent, email)
+	return nil
+}
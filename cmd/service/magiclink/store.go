@@ -0,0 +1,91 @@
+package magiclink
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+	"time"
+
+	// Import the types package for the MagicLink type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Store struct represents the data store for passwordless login links.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateMagicLink is a method on the Store struct that stores a new login link.
+func (s *Store) CreateMagicLink(link types.MagicLink) error {
+	_, err := s.db.Exec(
+		"INSERT INTO magic_links (email, tokenHash, deviceHash, expiresAt) VALUES (?, ?, ?, ?)",
+		link.Email, link.TokenHash, link.DeviceHash, link.ExpiresAt,
+	)
+
+	return err
+}
+
+// CountMagicLinksSince is a method on the Store struct that counts the links requested for an email since the given time.
+func (s *Store) CountMagicLinksSince(email string, since time.Time) (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM magic_links WHERE email = ? AND createdAt >= ?", email, since).Scan(&n)
+
+	return n, err
+}
+
+// GetMagicLinkByTokenHash is a method on the Store struct that retrieves a link by the hash of its token.
+// It returns an error if there is no such link.
+func (s *Store) GetMagicLinkByTokenHash(tokenHash string) (*types.MagicLink, error) {
+	link := new(types.MagicLink)
+	var usedAt sql.NullTime
+
+	err := s.db.QueryRow(
+		"SELECT id, email, tokenHash, deviceHash, expiresAt, usedAt, createdAt FROM magic_links WHERE tokenHash = ?",
+		tokenHash,
+	).Scan(
+		&link.ID,
+		&link.Email,
+		&link.TokenHash,
+		&link.DeviceHash,
+		&link.ExpiresAt,
+		&usedAt,
+		&link.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("magic link not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		link.UsedAt = &usedAt.Time
+	}
+
+	return link, nil
+}
+
+// MarkMagicLinkUsed is a method on the Store struct that marks a link as used.
+// The update only matches unused links, so of two concurrent exchanges only one succeeds.
+func (s *Store) MarkMagicLinkUsed(id int) error {
+	res, err := s.db.Exec("UPDATE magic_links SET usedAt = NOW() WHERE id = ? AND usedAt IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("magic link already used")
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	// Import the fmt and strings packages for building the email message.
+	"fmt"
+	"log"
+	// Import the net/smtp package for sending emails through an SMTP server.
+	"net/smtp"
+	"strings"
+
+	// Import the config package for the SMTP settings.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the types package for the Mailer interface and Email type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// New returns the mailer configured in config.Envs: an SMTP mailer when an SMTP host is set,
+// and a mailer that only logs emails otherwise, which is convenient in development.
+func New() types.Mailer {
+	if config.Envs.SMTPHost == "" {
+		return &LogMailer{}
+	}
+
+	return NewSMTPMailer(config.Envs.SMTPHost, config.Envs.SMTPPort, config.Envs.SMTPUser, config.Envs.SMTPPassword, config.Envs.MailFrom)
+}
+
+// SMTPMailer struct sends emails through an SMTP server.
+type SMTPMailer struct {
+	addr string    // Address of the SMTP server, host:port.
+	auth smtp.Auth // Credentials for the SMTP server, nil when it needs none.
+	from string    // Address emails are sent from.
+}
+
+// NewSMTPMailer is a constructor function that returns a new SMTPMailer.
+func NewSMTPMailer(host, port, user, password, from string) *SMTPMailer {
+	m := &SMTPMailer{addr: host + ":" + port, from: from}
+	if user != "" {
+		m.auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return m
+}
+
+// Send is a method on the SMTPMailer struct that sends a plain text email.
+func (m *SMTPMailer) Send(email types.Email) error {
+	// Header values must not contain line breaks, or they could inject extra headers.
+	if strings.ContainsAny(email.To+email.Subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + email.To,
+		"Subject: " + email.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		email.Body,
+	}, "\r\n")
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{email.To}, []byte(msg))
+}
+
+// LogMailer struct writes emails to the log instead of sending them.
+type LogMailer struct{}
+
+// Send is a method on the LogMailer struct that logs the email.
+func (m *LogMailer) Send(email types.Email) error {
+	log.Printf("email to %s: %s\n%s", email.To, email.Subject, email.Body)
+	return nil
+}
@@ -0,0 +1,135 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	// Import the config package for the storage settings.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the types package for the BlobStore interface.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Names of the blob storage backends.
+const (
+	BackendLocal = "local" // Files on the local filesystem, served by the API server itself.
+	BackendS3    = "s3"    // An S3-compatible object storage service, such as AWS S3 or MinIO.
+)
+
+// LocalPathPrefix is the path the API server serves the files of a LocalStore under.
+const LocalPathPrefix = "/media/"
+
+// NewBlobStore returns the blob store configured in config.Envs.
+func NewBlobStore() (types.BlobStore, error) {
+	switch config.Envs.BlobBackend {
+	case BackendLocal:
+		baseURL := config.Envs.MediaPublicURL
+		if baseURL == "" {
+			baseURL = config.Envs.PublicHost + ":" + config.Envs.Port + LocalPathPrefix
+		}
+		return NewLocalStore(config.Envs.MediaDir, baseURL), nil
+	case BackendS3:
+		return NewS3Store(config.Envs.S3Endpoint, config.Envs.S3Region, config.Envs.S3Bucket, config.Envs.S3AccessKey, config.Envs.S3SecretKey, config.Envs.MediaPublicURL), nil
+	}
+
+	return nil, fmt.Errorf("unknown blob backend %q", config.Envs.BlobBackend)
+}
+
+// LocalStore struct stores files in a directory of the local filesystem, which suits development
+// and single-server deployments.
+type LocalStore struct {
+	dir     string // Directory the files are stored in.
+	baseURL string // Public URL the directory is served at.
+}
+
+// NewLocalStore is a constructor function that returns a LocalStore keeping its files in dir,
+// which are publicly available under baseURL.
+func NewLocalStore(dir, baseURL string) *LocalStore {
+	return &LocalStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Put is a method on the LocalStore struct that writes a file. It writes to a temporary file first,
+// so the file is never served half-written.
+func (s *LocalStore) Put(key string, content []byte, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// Get is a method on the LocalStore struct that reads a file.
+func (s *LocalStore) Get(key string) ([]byte, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(name)
+}
+
+// Delete is a method on the LocalStore struct that removes a file.
+func (s *LocalStore) Delete(key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// URL is a method on the LocalStore struct that returns the public URL of a file.
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// Handler is a method on the LocalStore struct that returns a handler serving the files, without directory listings.
+// It expects the path of requests to be the key of the file.
+func (s *LocalStore) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// path returns the file name of a key, rejecting keys that would escape the directory.
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,137 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	// Register the decoders of the accepted image formats.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	// Import the mimetype package to detect the type of uploads from their content.
+	"github.com/gabriel-vasile/mimetype"
+	// Import the x/image draw package for high-quality resizing.
+	"golang.org/x/image/draw"
+	// Register the WebP decoder.
+	_ "golang.org/x/image/webp"
+)
+
+// Names of the files stored for every image.
+const (
+	Original  = "original"  // The uploaded file, as is.
+	Thumbnail = "thumbnail" // For listings and carts.
+	Medium    = "medium"    // For product pages.
+	Large     = "large"     // For zooming in.
+)
+
+// Size struct is a resized version of an image: it fits in a square of MaxSide pixels.
+type Size struct {
+	Name    string // Name of the version, such as "thumbnail".
+	MaxSide int    // Longest side of the version, in pixels.
+}
+
+// Sizes are the resized versions generated for every image, largest first, since each is resized from the one before.
+var Sizes = []Size{{Large, 1200}, {Medium, 600}, {Thumbnail, 150}}
+
+// maxPixels bounds the number of pixels of an upload, so a small file cannot expand into gigabytes of memory.
+const maxPixels = 50_000_000
+
+// acceptedTypes maps the MIME types of the accepted images to their file extension.
+var acceptedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ErrUnsupportedType is returned for uploads that are not a JPEG, PNG, GIF or WebP image.
+var ErrUnsupportedType = errors.New("unsupported image type, expected JPEG, PNG, GIF or WebP")
+
+// errInvalidDimensions is returned for images that are empty or too large.
+var errInvalidDimensions = errors.New("image dimensions are out of range")
+
+// Processed struct is an uploaded image with its resized versions.
+type Processed struct {
+	ContentType string            // MIME type of the upload, detected from its content.
+	Extension   string            // File extension for the MIME type.
+	Width       int               // Width of the upload, in pixels.
+	Height      int               // Height of the upload, in pixels.
+	Versions    map[string][]byte // The resized versions as WebP images, by size name.
+}
+
+// Process checks that data is an image of an accepted type, whatever the uploader claimed it was,
+// and generates its resized versions. Images are never enlarged: a version of a smaller image has its size.
+func Process(data []byte) (*Processed, error) {
+	// Trust the content, not the file name or the Content-Type of the upload.
+	contentType := mimetype.Detect(data).String()
+	extension, ok := acceptedTypes[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	// Check the dimensions before decoding the pixels.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+	if cfg.Width < 1 || cfg.Height < 1 || cfg.Width*cfg.Height > maxPixels {
+		return nil, errInvalidDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+
+	p := &Processed{
+		ContentType: contentType,
+		Extension:   extension,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Versions:    map[string][]byte{},
+	}
+
+	// Resizing from the previous, smaller, version is much faster than from the original every time.
+	// The dimensions come from the original, so rounding does not add up over the versions.
+	src := img
+	for _, size := range Sizes {
+		width, height := fit(cfg.Width, cfg.Height, size.MaxSide)
+		src = resize(src, width, height)
+
+		var buf bytes.Buffer
+		if err := EncodeWebP(&buf, src); err != nil {
+			return nil, err
+		}
+		p.Versions[size.Name] = buf.Bytes()
+	}
+
+	return p, nil
+}
+
+// resize scales img to width by height pixels with the Catmull-Rom filter, which keeps downscaled images sharp.
+// An image that already has the size is returned unchanged.
+func resize(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	if width == b.Dx() && height == b.Dy() {
+		return img
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// fit returns the dimensions of a width by height image scaled down to fit in a square of maxSide pixels,
+// never less than one pixel.
+func fit(width, height, maxSide int) (int, int) {
+	if width <= maxSide && height <= maxSide {
+		return width, height
+	}
+
+	if width >= height {
+		return maxSide, max(1, (height*maxSide+width/2)/width)
+	}
+	return max(1, (width*maxSide+height/2)/height), maxSide
+}
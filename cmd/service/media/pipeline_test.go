@@ -0,0 +1,146 @@
+package media
+
+import (
+	"bytes"       // Import the bytes package to compare pixels and read encoded images
+	"image"       // Import the image package to build test images
+	"image/color" // Import the image/color package for pixel colors
+	"image/png"   // Import the image/png package to encode uploads
+	"math/rand"   // Import the math/rand package for noisy test images
+	"testing"     // Import the testing package to write test cases
+
+	"golang.org/x/image/webp" // Import the WebP decoder to check the encoder
+)
+
+// testImage returns a width by height image: a gradient, with noise and varying transparency if noisy.
+func testImage(width, height int, noisy bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	r := rand.New(rand.NewSource(1))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{uint8(x), uint8(y), uint8(x + y), 0xff}
+			if noisy {
+				c = color.NRGBA{uint8(r.Intn(256)), uint8(x), uint8(r.Intn(256)), uint8(r.Intn(256))}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestEncodeWebP tests that encoded images decode to exactly the same pixels.
+func TestEncodeWebP(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{"single pixel", testImage(1, 1, false)},
+		{"single row", testImage(40, 1, false)},
+		{"gradient", testImage(70, 45, false)},
+		{"noise with transparency", testImage(33, 17, true)},
+		{"solid color", image.NewNRGBA(image.Rect(0, 0, 20, 20))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, tt.img); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			got, ok := decoded.(*image.NRGBA)
+			if !ok {
+				t.Fatalf("expected a lossless image, got %T", decoded)
+			}
+			if got.Bounds() != tt.img.Bounds() || !bytes.Equal(got.Pix, tt.img.Pix) {
+				t.Error("decoded pixels differ from the encoded ones")
+			}
+		})
+	}
+}
+
+// TestProcess tests that uploads are sniffed and resized into every size.
+func TestProcess(t *testing.T) {
+	t.Run("resizes to every size, keeping the aspect ratio", func(t *testing.T) {
+		var upload bytes.Buffer
+		png.Encode(&upload, testImage(1600, 900, false))
+
+		p, err := Process(upload.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ContentType != "image/png" || p.Extension != ".png" || p.Width != 1600 || p.Height != 900 {
+			t.Errorf("unexpected upload details %+v", p)
+		}
+
+		want := map[string]image.Point{Large: {1200, 675}, Medium: {600, 338}, Thumbnail: {150, 84}}
+		for name, size := range want {
+			cfg, err := webp.DecodeConfig(bytes.NewReader(p.Versions[name]))
+			if err != nil {
+				t.Fatalf("failed to decode %s: %v", name, err)
+			}
+			if cfg.Width != size.X || cfg.Height != size.Y {
+				t.Errorf("expected %s to be %v, got %dx%d", name, size, cfg.Width, cfg.Height)
+			}
+		}
+	})
+
+	t.Run("does not enlarge small images", func(t *testing.T) {
+		var upload bytes.Buffer
+		png.Encode(&upload, testImage(100, 300, false))
+
+		p, err := Process(upload.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, _ := webp.DecodeConfig(bytes.NewReader(p.Versions[Large]))
+		if cfg.Width != 100 || cfg.Height != 300 {
+			t.Errorf("expected the large version to keep the upload size, got %dx%d", cfg.Width, cfg.Height)
+		}
+		cfg, _ = webp.DecodeConfig(bytes.NewReader(p.Versions[Thumbnail]))
+		if cfg.Width != 50 || cfg.Height != 150 {
+			t.Errorf("expected a 50x150 thumbnail, got %dx%d", cfg.Width, cfg.Height)
+		}
+	})
+
+	t.Run("rejects files that are not images", func(t *testing.T) {
+		for _, data := range [][]byte{[]byte("%PDF-1.4 not an image"), []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")} {
+			if _, err := Process(data); err != ErrUnsupportedType {
+				t.Errorf("expected ErrUnsupportedType, got %v", err)
+			}
+		}
+	})
+
+	t.Run("rejects truncated images", func(t *testing.T) {
+		var upload bytes.Buffer
+		png.Encode(&upload, testImage(50, 50, false))
+
+		if _, err := Process(upload.Bytes()[:upload.Len()/2]); err == nil {
+			t.Error("expected an error for a truncated image")
+		}
+	})
+}
+
+// TestFit tests the dimensions images are resized to.
+func TestFit(t *testing.T) {
+	tests := []struct {
+		width, height, maxSide int
+		want                   image.Point
+	}{
+		{100, 50, 200, image.Point{100, 50}},
+		{400, 200, 200, image.Point{200, 100}},
+		{200, 400, 200, image.Point{100, 200}},
+		{3000, 2, 150, image.Point{150, 1}},
+	}
+
+	for _, tt := range tests {
+		w, h := fit(tt.width, tt.height, tt.maxSide)
+		if w != tt.want.X || h != tt.want.Y {
+			t.Errorf("fit(%d, %d, %d) = %d, %d, want %v", tt.width, tt.height, tt.maxSide, w, h, tt.want)
+		}
+	}
+}
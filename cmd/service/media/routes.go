@@ -0,0 +1,289 @@
+package media
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	// Import the auth package for the admin-only middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// formOverhead is how much larger than the image an upload request may be, for the other form fields and the
+// multipart framing.
+const formOverhead = 1 << 20
+
+// Handler struct groups the methods that handle product image requests.
+type Handler struct {
+	images    types.ImageStore   // Interface for the image galleries.
+	blobs     types.BlobStore    // Interface for the storage of the image files.
+	products  types.ProductStore // Interface for the product catalog, to check products exist.
+	userStore types.UserStore    // Interface for user-related data operations, used to authenticate admins.
+	maxUpload int64              // Largest image that can be uploaded, in bytes.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(images types.ImageStore, blobs types.BlobStore, products types.ProductStore, userStore types.UserStore, maxUpload int64) *Handler {
+	return &Handler{images: images, blobs: blobs, products: products, userStore: userStore, maxUpload: maxUpload}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the product image routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Show the galleries.
+	router.HandleFunc("/products/{id:[0-9]+}/images", h.handleGetImages).Methods(http.MethodGet)
+
+	// Manage the galleries.
+	router.HandleFunc("/admin/products/{id:[0-9]+}/images", auth.WithAdminAuth(h.handleUploadImage, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/products/{id:[0-9]+}/images/order", auth.WithAdminAuth(h.handleReorderImages, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/products/{id:[0-9]+}/images/{imageId:[0-9]+}", auth.WithAdminAuth(h.handleDeleteImage, h.userStore)).Methods(http.MethodDelete)
+}
+
+// handleGetImages handles GET /products/{id}/images.
+// It returns the product gallery, or with the variant query parameter, the gallery of the variant with that SKU.
+func (h *Handler) handleGetImages(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if _, err := h.products.GetProductByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	h.writeGallery(w, http.StatusOK, id, r.URL.Query().Get("variant"))
+}
+
+// handleUploadImage handles POST /admin/products/{id}/images.
+// It takes a multipart form with the image file in the image field, and optionally the variant SKU and alt text.
+// The type of the image is detected from its content; JPEG, PNG, GIF and WebP images are accepted.
+// The image is stored as uploaded, along with resized WebP versions, and added at the end of its gallery.
+func (h *Handler) handleUploadImage(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if _, err := h.products.GetProductByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	// Parse and validate the form.
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUpload+formOverhead)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("image must be at most %d bytes", h.maxUpload))
+			return
+		}
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing image file: %v", err))
+		return
+	}
+	defer file.Close()
+
+	payload := types.ImageUploadPayload{Variant: r.FormValue("variant"), Alt: r.FormValue("alt")}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, h.maxUpload+1))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if int64(len(data)) > h.maxUpload {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("image must be at most %d bytes", h.maxUpload))
+		return
+	}
+
+	processed, err := Process(data)
+	if err == ErrUnsupportedType {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Every upload gets a new random key, so its files can be cached forever.
+	key, err := newKey(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	img := types.ProductImage{
+		ProductID:   id,
+		Variant:     payload.Variant,
+		Alt:         payload.Alt,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		ContentType: processed.ContentType,
+		Key:         key,
+		Extension:   processed.Extension,
+	}
+
+	// Store the files, and only then the image, so a gallery never shows an image without its files.
+	keys := blobKeys(&img)
+	if err := h.blobs.Put(keys[Original], data, processed.ContentType); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, size := range Sizes {
+		if err := h.blobs.Put(keys[size.Name], processed.Versions[size.Name], "image/webp"); err != nil {
+			h.deleteBlobs(&img)
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if img.ID, err = h.images.CreateImage(img); err != nil {
+		h.deleteBlobs(&img)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.images.GetImageByID(img.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, h.withURLs(created))
+}
+
+// handleReorderImages handles PUT /admin/products/{id}/images/order.
+// The payload lists the IDs of all images of the gallery in their new order, and the gallery is returned reordered.
+func (h *Handler) handleReorderImages(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if _, err := h.products.GetProductByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.ImageOrderPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// The order must contain every image of the gallery exactly once.
+	images, err := h.images.GetImages(id, payload.Variant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	inGallery := map[int]bool{}
+	for _, img := range images {
+		inGallery[img.ID] = true
+	}
+	for _, imageID := range payload.ImageIDs {
+		if !inGallery[imageID] {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("image %d is not in the gallery, or listed twice", imageID))
+			return
+		}
+		delete(inGallery, imageID)
+	}
+	if len(inGallery) > 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("the order must list all %d images of the gallery", len(images)))
+		return
+	}
+
+	if err := h.images.ReorderImages(id, payload.Variant, payload.ImageIDs); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeGallery(w, http.StatusOK, id, payload.Variant)
+}
+
+// handleDeleteImage handles DELETE /admin/products/{id}/images/{imageId}.
+// The image is removed from its gallery and its files are deleted.
+func (h *Handler) handleDeleteImage(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	imageID, _ := strconv.Atoi(mux.Vars(r)["imageId"])
+
+	img, err := h.images.GetImageByID(imageID)
+	if err != nil || img.ProductID != id {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("image not found"))
+		return
+	}
+
+	if err := h.images.DeleteImage(imageID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The image is gone from the gallery, so files left behind are only wasted space.
+	h.deleteBlobs(img)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeGallery writes a gallery with the URLs of its images.
+func (h *Handler) writeGallery(w http.ResponseWriter, status int, productID int, variant string) {
+	images, err := h.images.GetImages(productID, variant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for _, img := range images {
+		h.withURLs(img)
+	}
+
+	utils.WriteJSON(w, status, images)
+}
+
+// withURLs fills in the public URLs of the files of an image, and returns it.
+func (h *Handler) withURLs(img *types.ProductImage) *types.ProductImage {
+	img.URLs = map[string]string{}
+	for name, key := range blobKeys(img) {
+		img.URLs[name] = h.blobs.URL(key)
+	}
+	return img
+}
+
+// deleteBlobs deletes the files of an image, logging failures since the caller can do nothing about them.
+func (h *Handler) deleteBlobs(img *types.ProductImage) {
+	for _, key := range blobKeys(img) {
+		if err := h.blobs.Delete(key); err != nil {
+			log.Printf("failed to delete image file %s: %v", key, err)
+		}
+	}
+}
+
+// blobKeys returns the keys of the files of an image, by name: the uploaded file and its resized versions.
+func blobKeys(img *types.ProductImage) map[string]string {
+	keys := map[string]string{Original: img.Key + "/" + Original + img.Extension}
+	for _, size := range Sizes {
+		keys[size.Name] = img.Key + "/" + size.Name + ".webp"
+	}
+	return keys
+}
+
+// newKey returns a new random key prefix for the files of an image of a product.
+func newKey(productID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("products/%d/%s", productID, hex.EncodeToString(b)), nil
+}
@@ -0,0 +1,390 @@
+package media
+
+import (
+	"bytes"             // Import the bytes package to build request bodies
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"image/png"         // Import the image/png package to encode uploads
+	"io"                // Import the io package for request bodies
+	"mime/multipart"    // Import the mime/multipart package to build upload forms
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"sort"              // Import the sort package to order galleries
+	"strings"           // Import the strings package to check URLs
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for upload timestamps
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for image types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestImageHandlers tests the product image HTTP handlers.
+func TestImageHandlers(t *testing.T) {
+	// Create a product store with one product (ID 1), and a user store with one admin (ID 1) and one customer
+	// (ID 2). Register the routes of a handler accepting uploads up to 100 KB into empty image and file stores, for
+	// the tests that store nothing; the others register a handler with stores of their own.
+	products := &mockProductStore{products: map[int]*types.Product{1: {ID: 1, SKU: "SHIRT", Name: "Shirt"}}}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin},
+		2: {ID: 2, Email: "customer@example.com", Role: types.RoleCustomer},
+	}}
+
+	images := &mockImageStore{images: map[int]*types.ProductImage{}}
+	blobs := &mockBlobStore{files: map[string][]byte{}}
+	router := mux.NewRouter()
+	NewHandler(images, blobs, products, userStore, 100<<10).RegisterRoutes(router)
+
+	// pngImage returns a width by height PNG file.
+	pngImage := func(width, height int) []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, testImage(width, height, false))
+		return buf.Bytes()
+	}
+
+	// uploadImage uploads a file as an admin, and returns the created image.
+	uploadImage := func(t *testing.T, router *mux.Router, file []byte, fields map[string]string) types.ProductImage {
+		t.Helper()
+
+		rr := upload(t, router, "/admin/products/1/images", file, fields, 1)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		var img types.ProductImage
+		json.NewDecoder(rr.Body).Decode(&img)
+		return img
+	}
+
+	// galleryIDs returns the IDs of the images of a gallery, in order.
+	galleryIDs := func(t *testing.T, router *mux.Router, target string) []int {
+		t.Helper()
+
+		rr := serve(t, router, http.MethodGet, target, nil, 0)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var images []types.ProductImage
+		json.NewDecoder(rr.Body).Decode(&images)
+		ids := []int{}
+		for _, img := range images {
+			ids = append(ids, img.ID)
+		}
+		return ids
+	}
+
+	t.Run("should store the upload and its resized versions", func(t *testing.T) {
+		blobs := &mockBlobStore{files: map[string][]byte{}}
+		router := mux.NewRouter()
+		NewHandler(&mockImageStore{images: map[int]*types.ProductImage{}}, blobs, products, userStore, 100<<10).RegisterRoutes(router)
+
+		img := uploadImage(t, router, pngImage(800, 400), map[string]string{"alt": "Front"})
+
+		if img.ContentType != "image/png" || img.Width != 800 || img.Height != 400 || img.Alt != "Front" || img.Position != 0 {
+			t.Errorf("unexpected image %+v", img)
+		}
+		for _, name := range []string{Original, Large, Medium, Thumbnail} {
+			url := img.URLs[name]
+			key := strings.TrimPrefix(url, "https://cdn.example.com/")
+			if _, ok := blobs.files[key]; !ok {
+				t.Errorf("expected the %s file at %s to be stored", name, url)
+			}
+		}
+		if !strings.HasSuffix(img.URLs[Original], "/original.png") || !strings.HasSuffix(img.URLs[Thumbnail], "/thumbnail.webp") {
+			t.Errorf("unexpected URLs %v", img.URLs)
+		}
+	})
+
+	t.Run("should detect the type from the content, not the file name", func(t *testing.T) {
+		rr := upload(t, router, "/admin/products/1/images", []byte("#!/bin/sh\necho not an image\n"), nil, 1)
+		if rr.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusUnsupportedMediaType, rr.Code, rr.Body)
+		}
+		if len(images.images) != 0 || len(blobs.files) != 0 {
+			t.Error("expected nothing to be stored")
+		}
+	})
+
+	t.Run("should reject uploads over the size limit", func(t *testing.T) {
+		rr := upload(t, router, "/admin/products/1/images", bytes.Repeat([]byte{0}, 200<<10), nil, 1)
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusRequestEntityTooLarge, rr.Code, rr.Body)
+		}
+	})
+
+	t.Run("should reject uploads by customers and for unknown products", func(t *testing.T) {
+		if rr := upload(t, router, "/admin/products/1/images", pngImage(10, 10), nil, 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+		if rr := upload(t, router, "/admin/products/99/images", pngImage(10, 10), nil, 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should keep separate galleries per variant", func(t *testing.T) {
+		router := mux.NewRouter()
+		NewHandler(&mockImageStore{images: map[int]*types.ProductImage{}}, &mockBlobStore{files: map[string][]byte{}}, products, userStore, 100<<10).RegisterRoutes(router)
+
+		first := uploadImage(t, router, pngImage(10, 10), nil)
+		red := uploadImage(t, router, pngImage(10, 10), map[string]string{"variant": "SHIRT-RED"})
+		second := uploadImage(t, router, pngImage(10, 10), nil)
+
+		if got := galleryIDs(t, router, "/products/1/images"); fmt.Sprint(got) != fmt.Sprint([]int{first.ID, second.ID}) {
+			t.Errorf("expected the product gallery to be %v, got %v", []int{first.ID, second.ID}, got)
+		}
+		if got := galleryIDs(t, router, "/products/1/images?variant=SHIRT-RED"); fmt.Sprint(got) != fmt.Sprint([]int{red.ID}) {
+			t.Errorf("expected the variant gallery to be %v, got %v", []int{red.ID}, got)
+		}
+		if red.Position != 0 || second.Position != 1 {
+			t.Errorf("expected positions to count per gallery, got %d and %d", red.Position, second.Position)
+		}
+	})
+
+	t.Run("should reorder a gallery", func(t *testing.T) {
+		router := mux.NewRouter()
+		NewHandler(&mockImageStore{images: map[int]*types.ProductImage{}}, &mockBlobStore{files: map[string][]byte{}}, products, userStore, 100<<10).RegisterRoutes(router)
+
+		a := uploadImage(t, router, pngImage(10, 10), nil)
+		b := uploadImage(t, router, pngImage(10, 10), nil)
+		c := uploadImage(t, router, pngImage(10, 10), nil)
+
+		body, _ := json.Marshal(types.ImageOrderPayload{ImageIDs: []int{c.ID, a.ID, b.ID}})
+		rr := serve(t, router, http.MethodPut, "/admin/products/1/images/order", body, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if got := galleryIDs(t, router, "/products/1/images"); fmt.Sprint(got) != fmt.Sprint([]int{c.ID, a.ID, b.ID}) {
+			t.Errorf("expected the gallery to be reordered, got %v", got)
+		}
+	})
+
+	t.Run("should reject orders that do not list the gallery exactly", func(t *testing.T) {
+		router := mux.NewRouter()
+		NewHandler(&mockImageStore{images: map[int]*types.ProductImage{}}, &mockBlobStore{files: map[string][]byte{}}, products, userStore, 100<<10).RegisterRoutes(router)
+
+		a := uploadImage(t, router, pngImage(10, 10), nil)
+		b := uploadImage(t, router, pngImage(10, 10), nil)
+		red := uploadImage(t, router, pngImage(10, 10), map[string]string{"variant": "SHIRT-RED"})
+
+		for _, ids := range [][]int{{a.ID}, {a.ID, a.ID}, {a.ID, b.ID, red.ID}, {b.ID, 99}} {
+			body, _ := json.Marshal(types.ImageOrderPayload{ImageIDs: ids})
+			rr := serve(t, router, http.MethodPut, "/admin/products/1/images/order", body, 1)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %v, got %d", http.StatusBadRequest, ids, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should delete an image and its files", func(t *testing.T) {
+		blobs := &mockBlobStore{files: map[string][]byte{}}
+		router := mux.NewRouter()
+		NewHandler(&mockImageStore{images: map[int]*types.ProductImage{}}, blobs, products, userStore, 100<<10).RegisterRoutes(router)
+
+		a := uploadImage(t, router, pngImage(10, 10), nil)
+		b := uploadImage(t, router, pngImage(10, 10), nil)
+
+		if rr := serve(t, router, http.MethodDelete, fmt.Sprintf("/admin/products/2/images/%d", a.ID), nil, 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for an image of another product, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		rr := serve(t, router, http.MethodDelete, fmt.Sprintf("/admin/products/1/images/%d", a.ID), nil, 1)
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+
+		if got := galleryIDs(t, router, "/products/1/images"); fmt.Sprint(got) != fmt.Sprint([]int{b.ID}) {
+			t.Errorf("expected only the other image to remain, got %v", got)
+		}
+		if len(blobs.files) != 4 {
+			t.Errorf("expected the files of the deleted image to be deleted, %d files left", len(blobs.files))
+		}
+	})
+}
+
+// upload sends a multipart form with the file in the image field and the other fields through the router,
+// authenticated as the user with the given ID.
+func upload(t *testing.T, router *mux.Router, target string, file []byte, fields map[string]string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	// The file name and type claimed by the client are ignored.
+	part, _ := form.CreateFormFile("image", "photo.jpg")
+	part.Write(file)
+	form.Close()
+
+	return send(t, router, http.MethodPost, target, &body, form.FormDataContentType(), userID)
+}
+
+// serve sends a JSON request through the router, authenticated as the user with the given ID unless it is 0,
+// and returns the recorded response.
+func serve(t *testing.T, router *mux.Router, method, target string, body []byte, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return send(t, router, method, target, bytes.NewBuffer(body), "application/json", userID)
+}
+
+// send sends a request through the router, authenticated as the user with the given ID unless it is 0.
+func send(t *testing.T, router *mux.Router, method, target string, body io.Reader, contentType string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	if userID != 0 {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockImageStore is an in-memory implementation of the ImageStore interface, used for testing purposes.
+type mockImageStore struct {
+	images map[int]*types.ProductImage // Images by ID.
+	nextID int                         // ID of the last created image.
+}
+
+// gallery returns the images of a gallery, ordered by position.
+func (m *mockImageStore) gallery(productID int, variant string) []*types.ProductImage {
+	images := []*types.ProductImage{}
+	for _, img := range m.images {
+		if img.ProductID == productID && img.Variant == variant {
+			c := *img
+			images = append(images, &c)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Position < images[j].Position })
+	return images
+}
+
+// GetImages is a mock method that returns a copy of a gallery.
+func (m *mockImageStore) GetImages(productID int, variant string) ([]*types.ProductImage, error) {
+	return m.gallery(productID, variant), nil
+}
+
+// GetImageByID is a mock method that returns a copy of the image with the given ID.
+func (m *mockImageStore) GetImageByID(id int) (*types.ProductImage, error) {
+	img, ok := m.images[id]
+	if !ok {
+		return nil, fmt.Errorf("image not found")
+	}
+
+	c := *img
+	return &c, nil
+}
+
+// CreateImage is a mock method that adds an image at the end of its gallery.
+func (m *mockImageStore) CreateImage(img types.ProductImage) (int, error) {
+	m.nextID++
+	img.ID = m.nextID
+	img.Position = len(m.gallery(img.ProductID, img.Variant))
+	img.CreatedAt = time.Now()
+	m.images[img.ID] = &img
+
+	return img.ID, nil
+}
+
+// DeleteImage is a mock method that removes an image, moving the images after it up one position.
+func (m *mockImageStore) DeleteImage(id int) error {
+	img, ok := m.images[id]
+	if !ok {
+		return fmt.Errorf("image not found")
+	}
+
+	delete(m.images, id)
+	for _, other := range m.images {
+		if other.ProductID == img.ProductID && other.Variant == img.Variant && other.Position > img.Position {
+			other.Position--
+		}
+	}
+	return nil
+}
+
+// ReorderImages is a mock method that sets the positions of a gallery.
+func (m *mockImageStore) ReorderImages(productID int, variant string, ids []int) error {
+	for position, id := range ids {
+		m.images[id].Position = position
+	}
+	return nil
+}
+
+// mockBlobStore is an in-memory implementation of the BlobStore interface, used for testing purposes.
+type mockBlobStore struct {
+	files map[string][]byte // Stored files by key.
+}
+
+// Put is a mock method that stores a file.
+func (m *mockBlobStore) Put(key string, content []byte, contentType string) error {
+	m.files[key] = content
+	return nil
+}
+
+// Get is a mock method that returns a stored file.
+func (m *mockBlobStore) Get(key string) ([]byte, error) {
+	content, ok := m.files[key]
+	if !ok {
+		return nil, fmt.Errorf("file not found")
+	}
+	return content, nil
+}
+
+// Delete is a mock method that removes a stored file.
+func (m *mockBlobStore) Delete(key string) error {
+	delete(m.files, key)
+	return nil
+}
+
+// URL is a mock method that returns the URL of a file on a CDN.
+func (m *mockBlobStore) URL(key string) string {
+	return "https://cdn.example.com/" + key
+}
+
+// mockProductStore is an in-memory implementation of the parts of the ProductStore interface used by the handler.
+type mockProductStore struct {
+	types.ProductStore
+	products map[int]*types.Product // Products by ID.
+}
+
+// GetProductByID is a mock method that returns a copy of the product with the given ID.
+func (m *mockProductStore) GetProductByID(id int) (*types.Product, error) {
+	p, ok := m.products[id]
+	if !ok {
+		return nil, types.ErrProductNotFound
+	}
+
+	c := *p
+	return &c, nil
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the auth middleware.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
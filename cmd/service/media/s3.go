@@ -0,0 +1,188 @@
+package media
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Store struct stores files in a bucket of an S3-compatible object storage service, such as AWS S3, MinIO
+// or Cloudflare R2. It talks to the service's REST API directly, addressing the bucket in the path, and signs
+// its requests with AWS Signature Version 4, which all of them support.
+type S3Store struct {
+	endpoint  string           // URL of the service, such as https://s3.eu-west-1.amazonaws.com.
+	region    string           // Region of the bucket, such as eu-west-1.
+	bucket    string           // Name of the bucket.
+	accessKey string           // Access key ID of the credentials.
+	secretKey string           // Secret access key of the credentials.
+	publicURL string           // Public URL of the bucket, such as a CDN in front of it. When empty, the bucket URL.
+	client    *http.Client     // Client sending the requests.
+	now       func() time.Time // Clock the requests are signed with.
+}
+
+// NewS3Store is a constructor function that returns a new S3Store.
+func NewS3Store(endpoint, region, bucket, accessKey, secretKey, publicURL string) *S3Store {
+	return &S3Store{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		now:       time.Now,
+	}
+}
+
+// Put is a method on the S3Store struct that uploads a file. Keys of uploads are never reused,
+// so the file may be cached forever.
+func (s *S3Store) Put(key string, content []byte, contentType string) error {
+	req, err := s.request(http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	_, err = s.do(req, content, http.StatusOK)
+	return err
+}
+
+// Get is a method on the S3Store struct that downloads a file.
+func (s *S3Store) Get(key string) ([]byte, error) {
+	req, err := s.request(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.do(req, nil, http.StatusOK)
+}
+
+// Delete is a method on the S3Store struct that removes a file. S3 reports success for missing files too.
+func (s *S3Store) Delete(key string) error {
+	req, err := s.request(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.do(req, nil, http.StatusNoContent)
+	return err
+}
+
+// URL is a method on the S3Store struct that returns the public URL of a file.
+func (s *S3Store) URL(key string) string {
+	if s.publicURL != "" {
+		return s.publicURL + "/" + key
+	}
+	return s.endpoint + "/" + s.bucket + "/" + key
+}
+
+// request returns a request for the object with the key.
+func (s *S3Store) request(method, key string, content []byte) (*http.Request, error) {
+	return http.NewRequest(method, s.endpoint+"/"+s.bucket+"/"+escapePath(key), bytes.NewReader(content))
+}
+
+// do signs and sends a request, and returns the response body if the status is the expected one.
+func (s *S3Store) do(req *http.Request, content []byte, expected int) ([]byte, error) {
+	signRequest(req, content, s.accessKey, s.secretKey, s.region, s.now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	// Deleting answers 204, but some services answer 200.
+	if res.StatusCode != expected && !(expected == http.StatusNoContent && res.StatusCode == http.StatusOK) {
+		return nil, fmt.Errorf("%s %s: unexpected status %s: %s", req.Method, req.URL.Path, res.Status, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
+
+// signRequest signs a request to S3 with AWS Signature Version 4, signing the host and all headers of the request.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func signRequest(req *http.Request, content []byte, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(content)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// The canonical headers are the lowercase names, sorted, with their trimmed values.
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+// escapePath percent-encodes everything in a path but unreserved characters and slashes, as Signature Version 4 expects.
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// sha256Hex returns the hex-encoded SHA-256 hash of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with the key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,152 @@
+package media
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+
+	// Import the types package for the image types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// imageColumns lists the columns of the product_images table, in the order scanImage reads them.
+const imageColumns = "id, productId, variant, position, alt, width, height, contentType, blobKey, extension, createdAt"
+
+// Store struct represents the data store of the product image galleries.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetImages is a method on the Store struct that retrieves a gallery, ordered by position.
+func (s *Store) GetImages(productID int, variant string) ([]*types.ProductImage, error) {
+	rows, err := s.db.Query("SELECT "+imageColumns+" FROM product_images WHERE productId = ? AND variant = ? ORDER BY position, id", productID, variant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []*types.ProductImage{}
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	return images, rows.Err()
+}
+
+// GetImageByID is a method on the Store struct that retrieves an image by ID.
+func (s *Store) GetImageByID(id int) (*types.ProductImage, error) {
+	img, err := scanImage(s.db.QueryRow("SELECT "+imageColumns+" FROM product_images WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("image not found")
+	}
+
+	return img, err
+}
+
+// CreateImage is a method on the Store struct that adds an image at the end of its gallery and returns its ID.
+func (s *Store) CreateImage(img types.ProductImage) (int, error) {
+	var id int64
+	err := s.inTx(func(tx *sql.Tx) error {
+		// Lock the gallery, so concurrent uploads do not take the same position.
+		var position int
+		err := tx.QueryRow(
+			"SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE productId = ? AND variant = ? FOR UPDATE",
+			img.ProductID, img.Variant,
+		).Scan(&position)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Exec(
+			"INSERT INTO product_images (productId, variant, position, alt, width, height, contentType, blobKey, extension) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			img.ProductID, img.Variant, position, img.Alt, img.Width, img.Height, img.ContentType, img.Key, img.Extension,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err = res.LastInsertId()
+		return err
+	})
+
+	return int(id), err
+}
+
+// DeleteImage is a method on the Store struct that removes an image, moving the images after it up one position.
+func (s *Store) DeleteImage(id int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		var productID, position int
+		var variant string
+		err := tx.QueryRow("SELECT productId, variant, position FROM product_images WHERE id = ? FOR UPDATE", id).Scan(&productID, &variant, &position)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("image not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM product_images WHERE id = ?", id); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"UPDATE product_images SET position = position - 1 WHERE productId = ? AND variant = ? AND position > ?",
+			productID, variant, position,
+		)
+		return err
+	})
+}
+
+// ReorderImages is a method on the Store struct that sets the order of a gallery: each image takes the position
+// of its ID in ids.
+func (s *Store) ReorderImages(productID int, variant string, ids []int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		for position, id := range ids {
+			_, err := tx.Exec(
+				"UPDATE product_images SET position = ? WHERE id = ? AND productId = ? AND variant = ?",
+				position, id, productID, variant,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// inTx runs fn inside a transaction, committing if it succeeds and rolling back if it fails.
+func (s *Store) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scanImage scans a row selected with imageColumns into a ProductImage.
+func scanImage(row interface{ Scan(...any) error }) (*types.ProductImage, error) {
+	img := new(types.ProductImage)
+	err := row.Scan(&img.ID, &img.ProductID, &img.Variant, &img.Position, &img.Alt, &img.Width, &img.Height, &img.ContentType, &img.Key, &img.Extension, &img.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
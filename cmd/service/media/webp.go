@@ -0,0 +1,450 @@
+package media
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// EncodeWebP writes img to w as a lossless WebP image.
+//
+// The encoder is deliberately small: it applies the subtract-green and predictor transforms, which take care of
+// most of the redundancy in photos, and then Huffman codes every pixel as a literal. It uses no backward
+// references or color cache, so files are larger than what libwebp produces, but they are valid, lossless,
+// and decode in every browser. The standard library only decodes WebP, and the encoders available as Go
+// modules need cgo.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > 1<<14 || height > 1<<14 {
+		return errInvalidDimensions
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+
+	argb := make([]uint32, width*height)
+	hasAlpha := false
+	for i := range argb {
+		p := nrgba.Pix[i*4 : i*4+4]
+		argb[i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		if p[3] != 0xff {
+			hasAlpha = true
+		}
+	}
+
+	bw := &bitWriter{}
+
+	// Header: signature, dimensions, alpha hint and version 0.
+	bw.writeBits(0x2f, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	bw.writeBits(boolBit(hasAlpha), 1)
+	bw.writeBits(0, 3)
+
+	// The subtract-green transform decorrelates the colors: red and blue are stored relative to green.
+	subtractGreen(argb)
+	bw.writeBits(1, 1)
+	bw.writeBits(transformSubtractGreen, 2)
+
+	// The predictor transform stores every pixel relative to a prediction from its neighbours. All blocks use
+	// the same predictor, so the image of predictor modes costs a few bytes.
+	predict(argb, width, height)
+	bw.writeBits(1, 1)
+	bw.writeBits(transformPredictor, 2)
+	bw.writeBits(predictorBlockBits-2, 3)
+	blocks := make([]uint32, subSampleSize(width, predictorBlockBits)*subSampleSize(height, predictorBlockBits))
+	for i := range blocks {
+		blocks[i] = predictorSelect << 8
+	}
+	writeEntropyImage(bw, blocks, false)
+
+	// No more transforms, then the pixels themselves.
+	bw.writeBits(0, 1)
+	writeEntropyImage(bw, argb, true)
+
+	data := bw.bytes()
+	padding := len(data) & 1
+
+	// Wrap the bitstream in a RIFF container.
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+len(data)+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// Transform types of the lossless bitstream.
+const (
+	transformPredictor     = 0
+	transformSubtractGreen = 2
+)
+
+// predictorSelect is the "select" predictor mode, which predicts a pixel as its left or top neighbour,
+// whichever is closer to the gradient estimate. It works well on both edges and smooth areas.
+const predictorSelect = 11
+
+// predictorBlockBits is the log2 of the size of the blocks the predictor transform chooses a mode for.
+const predictorBlockBits = 4
+
+// Sizes of the five prefix code alphabets: green with the backward reference lengths, red, blue, alpha and distance.
+var alphabetSizes = [5]int{256 + 24, 256, 256, 256, 40}
+
+// codeLengthOrder is the order the code lengths of the code length code are written in.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Longest codes allowed for the symbols and for the code length code.
+const (
+	maxCodeLength       = 15
+	maxCodeLengthLength = 7
+)
+
+// subtractGreen subtracts the green component of every pixel from its red and blue components.
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		g := (p >> 8) & 0xff
+		r := ((p >> 16) - g) & 0xff
+		b := (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | b
+	}
+}
+
+// predict replaces every pixel with its difference from its prediction. The top-left pixel is predicted as
+// opaque black, the rest of the first row from the left, the rest of the first column from the top, and
+// every other pixel with the select predictor. It works from the last pixel back, so every prediction
+// still sees the original neighbours.
+func predict(argb []uint32, width, height int) {
+	for y := height - 1; y >= 0; y-- {
+		for x := width - 1; x >= 0; x-- {
+			i := y*width + x
+			var prediction uint32
+			switch {
+			case x == 0 && y == 0:
+				prediction = 0xff000000
+			case y == 0:
+				prediction = argb[i-1]
+			case x == 0:
+				prediction = argb[i-width]
+			default:
+				prediction = selectPredictor(argb[i-1], argb[i-width], argb[i-width-1])
+			}
+			argb[i] = subPixels(argb[i], prediction)
+		}
+	}
+}
+
+// selectPredictor returns the left or the top pixel, whichever is closer to left + top - topLeft.
+func selectPredictor(left, top, topLeft uint32) uint32 {
+	distLeft, distTop := 0, 0
+	for shift := 0; shift < 32; shift += 8 {
+		l, t, tl := int(left>>shift&0xff), int(top>>shift&0xff), int(topLeft>>shift&0xff)
+		estimate := l + t - tl
+		distLeft += abs(estimate - l)
+		distTop += abs(estimate - t)
+	}
+	if distLeft < distTop {
+		return left
+	}
+	return top
+}
+
+// subPixels subtracts b from a per component, modulo 256.
+func subPixels(a, b uint32) uint32 {
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		out |= ((a>>shift&0xff - b>>shift&0xff) & 0xff) << shift
+	}
+	return out
+}
+
+// writeEntropyImage writes pixels as literals with one prefix code per channel.
+// The main image also says it uses a single group of prefix codes for the whole image.
+func writeEntropyImage(bw *bitWriter, argb []uint32, main bool) {
+	// No color cache.
+	bw.writeBits(0, 1)
+	if main {
+		// No meta prefix codes.
+		bw.writeBits(0, 1)
+	}
+
+	var histograms [5][]int
+	for i, size := range alphabetSizes {
+		histograms[i] = make([]int, size)
+	}
+	for _, p := range argb {
+		histograms[0][p>>8&0xff]++
+		histograms[1][p>>16&0xff]++
+		histograms[2][p&0xff]++
+		histograms[3][p>>24]++
+	}
+
+	var codes [5]prefixCode
+	for i, h := range histograms {
+		codes[i] = writePrefixCode(bw, h)
+	}
+
+	for _, p := range argb {
+		codes[0].write(bw, int(p>>8&0xff))
+		codes[1].write(bw, int(p>>16&0xff))
+		codes[2].write(bw, int(p&0xff))
+		codes[3].write(bw, int(p>>24))
+	}
+}
+
+// prefixCode is a canonical Huffman code, with the codes bit-reversed since the bitstream is read from the
+// least significant bit.
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+// write writes the code of a symbol.
+func (c prefixCode) write(bw *bitWriter, symbol int) {
+	bw.writeBits(c.codes[symbol], c.lengths[symbol])
+}
+
+// writePrefixCode builds a prefix code for the symbol counts, writes it, and returns it.
+// One or two symbols below 256 are written as a simple code; anything else as a normal code.
+func writePrefixCode(bw *bitWriter, counts []int) prefixCode {
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		lengths := make([]int, len(counts))
+		if len(used) == 0 {
+			used = []int{0}
+		}
+
+		// Simple code: the number of symbols, then each symbol in 1 or 8 bits.
+		bw.writeBits(1, 1)
+		bw.writeBits(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.writeBits(0, 1)
+			bw.writeBits(uint32(used[0]), 1)
+		} else {
+			bw.writeBits(1, 1)
+			bw.writeBits(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.writeBits(uint32(used[1]), 8)
+			lengths[used[0]], lengths[used[1]] = 1, 1
+		}
+		// A single symbol takes no bits at all.
+		return newPrefixCode(lengths)
+	}
+
+	lengths := huffmanLengths(counts, maxCodeLength)
+
+	// Normal code: the code lengths are themselves Huffman coded, with the code length code written first.
+	// Only the literal lengths 0 to 15 are used, not the repeat codes.
+	lengthCounts := make([]int, len(codeLengthOrder))
+	for _, l := range lengths {
+		lengthCounts[l]++
+	}
+	// The code length code needs two symbols to be a complete code.
+	if nonZero(lengthCounts) == 1 {
+		for l := range lengthCounts {
+			if lengthCounts[l] == 0 {
+				lengthCounts[l] = 1
+				break
+			}
+		}
+	}
+	lengthCode := newPrefixCode(huffmanLengths(lengthCounts, maxCodeLengthLength))
+
+	numLengths := len(codeLengthOrder)
+	for numLengths > 4 && lengthCode.lengths[codeLengthOrder[numLengths-1]] == 0 {
+		numLengths--
+	}
+
+	bw.writeBits(0, 1)
+	bw.writeBits(uint32(numLengths-4), 4)
+	for _, symbol := range codeLengthOrder[:numLengths] {
+		bw.writeBits(uint32(lengthCode.lengths[symbol]), 3)
+	}
+	// All symbols have a code length, so no maximum symbol is given.
+	bw.writeBits(0, 1)
+	for _, l := range lengths {
+		lengthCode.write(bw, l)
+	}
+
+	return newPrefixCode(lengths)
+}
+
+// newPrefixCode assigns canonical codes to code lengths: shorter codes first, and in symbol order within a length.
+func newPrefixCode(lengths []int) prefixCode {
+	var lengthCounts [maxCodeLength + 1]int
+	for _, l := range lengths {
+		lengthCounts[l]++
+	}
+	lengthCounts[0] = 0
+
+	var next [maxCodeLength + 1]uint32
+	code := uint32(0)
+	for l := 1; l <= maxCodeLength; l++ {
+		code = (code + uint32(lengthCounts[l-1])) << 1
+		next[l] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		codes[symbol] = reverseBits(next[l], l)
+		next[l]++
+	}
+
+	return prefixCode{lengths: lengths, codes: codes}
+}
+
+// huffmanLengths returns the Huffman code lengths for the symbol counts, no longer than maxLength.
+// Symbols with a zero count get no code. When the optimal code is too long, the counts are flattened
+// until it fits, as libwebp does.
+func huffmanLengths(counts []int, maxLength int) []int {
+	scaled := append([]int(nil), counts...)
+	for {
+		lengths := optimalLengths(scaled)
+		longest := 0
+		for _, l := range lengths {
+			longest = max(longest, l)
+		}
+		if longest <= maxLength {
+			return lengths
+		}
+
+		for i, c := range scaled {
+			if c > 0 {
+				scaled[i] = c/2 + 1
+			}
+		}
+	}
+}
+
+// optimalLengths returns the code lengths of an unrestricted Huffman code for the counts.
+func optimalLengths(counts []int) []int {
+	type node struct {
+		count       int
+		symbol      int // The symbol of a leaf, or -1.
+		left, right *node
+	}
+
+	var nodes []*node
+	for symbol, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, &node{count: count, symbol: symbol})
+		}
+	}
+
+	lengths := make([]int, len(counts))
+	if len(nodes) == 1 {
+		lengths[nodes[0].symbol] = 1
+		return lengths
+	}
+
+	// Repeatedly merge the two least common nodes. Sorting each round is fast enough for 280 symbols.
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].count < nodes[j].count })
+		merged := &node{count: nodes[0].count + nodes[1].count, symbol: -1, left: nodes[0], right: nodes[1]}
+		nodes = append([]*node{merged}, nodes[2:]...)
+	}
+
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		if n.symbol >= 0 {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(nodes[0], 0)
+
+	return lengths
+}
+
+// bitWriter writes bits least significant bit first.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits int
+}
+
+// writeBits writes the n low bits of v.
+func (bw *bitWriter) writeBits(v uint32, n int) {
+	bw.acc |= uint64(v) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+// bytes flushes the remaining bits and returns everything written.
+func (bw *bitWriter) bytes() []byte {
+	if bw.nbits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nbits = 0, 0
+	}
+	return bw.buf
+}
+
+// reverseBits reverses the n low bits of v.
+func reverseBits(v uint32, n int) uint32 {
+	var out uint32
+	for i := 0; i < n; i++ {
+		out = out<<1 | v>>i&1
+	}
+	return out
+}
+
+// subSampleSize returns the size of a dimension divided in blocks of 1<<bits, rounded up.
+func subSampleSize(size, bits int) int {
+	return (size + 1<<bits - 1) >> bits
+}
+
+// nonZero returns the number of non-zero counts.
+func nonZero(counts []int) int {
+	n := 0
+	for _, c := range counts {
+		if c > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// boolBit returns 1 for true and 0 for false.
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,349 @@
+package oidc
+
+import (
+	// Import the crypto packages for PKCE challenges and parsing the provider's RSA signing keys.
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	// Import the config package for the provider settings.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the golang-jwt package for validating ID tokens.
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryDocument holds the parts of the provider's /.well-known/openid-configuration we use.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDTokenClaims struct holds the validated claims of an ID token that are used to log a user in.
+type IDTokenClaims struct {
+	Subject       string // The provider's unique identifier for the account.
+	Email         string // The account's email address.
+	EmailVerified bool   // Whether the provider has verified the email address.
+	GivenName     string // The account's first name, if shared.
+	FamilyName    string // The account's last name, if shared.
+}
+
+// Provider struct is an OpenID Connect relying party for a single identity provider.
+// The provider's endpoints and signing keys are fetched on first use and cached.
+type Provider struct {
+	config config.OIDCProvider // The provider settings.
+	client *http.Client        // The HTTP client used to talk to the provider.
+
+	mu          sync.Mutex                // Guards the cached discovery document and keys.
+	discovery   *discoveryDocument        // The cached discovery document.
+	keys        map[string]*rsa.PublicKey // The cached signing keys, by key ID.
+	refreshedAt time.Time                 // When the keys were last fetched.
+}
+
+// minKeyRefreshInterval limits how often unknown key IDs can trigger a JWKS fetch,
+// so tokens with made-up key IDs cannot be used to flood the provider.
+const minKeyRefreshInterval = time.Minute
+
+// defaultHTTPClient is the HTTP client used to talk to providers, with a timeout so a slow provider
+// cannot hold requests forever.
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewProvider is a constructor function that returns a new Provider for the given settings.
+func NewProvider(cfg config.OIDCProvider, client *http.Client) *Provider {
+	return &Provider{config: cfg, client: client}
+}
+
+// NewProviders returns a Provider for each of the given settings, keyed by provider name.
+func NewProviders(cfgs []config.OIDCProvider) map[string]*Provider {
+	providers := map[string]*Provider{}
+	for _, cfg := range cfgs {
+		providers[cfg.Name] = NewProvider(cfg, defaultHTTPClient)
+	}
+
+	return providers
+}
+
+// Name returns the name of the provider, as configured.
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the URL to send the user to, to start the authorization code flow.
+// The state and nonce protect against forged callbacks and replayed ID tokens, and the code challenge
+// is derived from the PKCE verifier with NewCodeChallenge.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	// Keep any query parameters already present in the authorization endpoint.
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades an authorization code for the provider's tokens and returns the raw ID token.
+// The code verifier must be the one the code challenge in AuthCodeURL was derived from.
+func (p *Provider) Exchange(code, codeVerifier string) (string, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	// Send the code to the token endpoint, authenticating with the client credentials.
+	res, err := p.client.PostForm(d.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d", res.StatusCode)
+	}
+
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("token endpoint returned no ID token")
+	}
+
+	return body.IDToken, nil
+}
+
+// VerifyIDToken validates an ID token and returns its claims.
+// It checks the signature against the provider's JWKS, the issuer, the audience, the expiry and the nonce.
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*IDTokenClaims, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse and validate the token, only accepting RSA signatures made with one of the provider's keys.
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.getKey(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	// The nonce must match the one sent in the authorization request, so the token cannot be replayed.
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, fmt.Errorf("invalid ID token: missing subject")
+	}
+
+	c := &IDTokenClaims{Subject: sub}
+	c.Email, _ = claims["email"].(string)
+	c.GivenName, _ = claims["given_name"].(string)
+	c.FamilyName, _ = claims["family_name"].(string)
+
+	// Some providers send email_verified as a string.
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		c.EmailVerified = v
+	case string:
+		c.EmailVerified = v == "true"
+	}
+
+	return c, nil
+}
+
+// getDiscovery returns the provider's discovery document, fetching it on first use.
+func (p *Provider) getDiscovery() (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	d := new(discoveryDocument)
+	if err := p.getJSON(wellKnown, d); err != nil {
+		return nil, fmt.Errorf("failed to discover provider %s: %w", p.config.Name, err)
+	}
+
+	// The discovery document must be for the configured issuer.
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(p.config.IssuerURL, "/") {
+		return nil, fmt.Errorf("provider %s reported issuer %q, expected %q", p.config.Name, d.Issuer, p.config.IssuerURL)
+	}
+
+	p.discovery = d
+	return d, nil
+}
+
+// getKey returns the signing key with the given ID.
+// Unknown key IDs trigger a refetch of the JWKS, so key rotations at the provider are picked up.
+func (p *Provider) getKey(kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	recent := time.Since(p.refreshedAt) < minKeyRefreshInterval
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if !recent {
+		if err := p.refreshKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Tokens without a key ID are accepted if the provider has a single key.
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, nil
+		}
+	}
+
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+// jsonWebKey holds the fields of a JSON Web Key needed to build an RSA public key.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// refreshKeys fetches the provider's JWKS and replaces the cached keys.
+func (p *Provider) refreshKeys() error {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(d.JWKSURI, &set); err != nil {
+		return fmt.Errorf("failed to fetch JWKS of provider %s: %w", p.config.Name, err)
+	}
+
+	// Keep the RSA signing keys, skipping any other key types.
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		key, err := parseRSAKey(k)
+		if err != nil {
+			return err
+		}
+		keys[k.Kid] = key
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.refreshedAt = time.Now()
+	p.mu.Unlock()
+
+	return nil
+}
+
+// parseRSAKey builds an RSA public key from the base64url-encoded modulus and exponent of a JSON Web Key.
+func parseRSAKey(k jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus of key %q: %w", k.Kid, err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent of key %q: %w", k.Kid, err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// getJSON fetches a URL and decodes the JSON response into v.
+func (p *Provider) getJSON(url string, v any) error {
+	res, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// NewRandomString returns a random, URL-safe string, used for states, nonces and PKCE verifiers.
+func NewRandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewCodeChallenge derives the S256 PKCE code challenge from a code verifier.
+func NewCodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
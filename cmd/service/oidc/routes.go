@@ -0,0 +1,317 @@
+package oidc
+
+import (
+	// Import the hmac and sha256 packages for deriving the key flow cookies are signed with.
+	"crypto/hmac"
+	"crypto/sha256"
+	// Import the subtle package for comparing the state in constant time.
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	// Import the config package for the JWT secret and public host.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the auth package for hashing passwords and creating tokens.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON responses and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	// Import the golang-jwt package for signing the flow cookie.
+	"github.com/golang-jwt/jwt/v5"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// flowCookieName is the name of the cookie holding the state of an ongoing login.
+const flowCookieName = "oidc_flow"
+
+// flowLifetime is how long a user has to complete the login at the provider.
+const flowLifetime = 10 * time.Minute
+
+// flowKeyPurpose separates the key flow cookies are signed with from the key of access tokens.
+const flowKeyPurpose = "oidc-flow"
+
+// Handler struct groups the methods that handle social login requests.
+type Handler struct {
+	providers     map[string]*Provider // The configured identity providers, by name.
+	userStore     types.UserStore      // Interface for user-related data operations.
+	identityStore types.IdentityStore  // Interface for the external identities linked to users.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(providers map[string]*Provider, userStore types.UserStore, identityStore types.IdentityStore) *Handler {
+	return &Handler{providers: providers, userStore: userStore, identityStore: identityStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the social login routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Start a login: redirects the user to the provider.
+	router.HandleFunc("/oauth/{provider}/login", h.handleLogin).Methods(http.MethodGet)
+
+	// Finish a login: the provider redirects the user back here with an authorization code.
+	router.HandleFunc("/oauth/{provider}/callback", h.handleCallback).Methods(http.MethodGet)
+}
+
+// handleLogin handles GET /oauth/{provider}/login.
+// It generates the state, nonce and PKCE verifier of the login, keeps them in a signed cookie and
+// redirects the user to the provider's authorization endpoint.
+func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.providers[mux.Vars(r)["provider"]]
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("unknown identity provider"))
+		return
+	}
+
+	// Generate the per-login secrets.
+	state, err := NewRandomString()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	nonce, err := NewRandomString()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	verifier, err := NewRandomString()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Build the URL of the provider's authorization endpoint.
+	authURL, err := p.AuthCodeURL(state, nonce, NewCodeChallenge(verifier))
+	if err != nil {
+		log.Printf("failed to start login with %s: %v", p.Name(), err)
+		utils.WriteError(w, http.StatusBadGateway, fmt.Errorf("identity provider is unavailable"))
+		return
+	}
+
+	// Keep the secrets in a signed cookie, so the callback can check them without server-side state.
+	cookie, err := newFlowCookie(p.Name(), state, nonce, verifier)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	http.SetCookie(w, cookie)
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// handleCallback handles GET /oauth/{provider}/callback.
+// It checks the state, exchanges the code for an ID token, validates it and logs the linked user in,
+// linking or creating the user on first login.
+func (h *Handler) handleCallback(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.providers[mux.Vars(r)["provider"]]
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("unknown identity provider"))
+		return
+	}
+
+	// The flow cookie is single-use, so clear it whatever happens next.
+	http.SetCookie(w, &http.Cookie{Name: flowCookieName, Path: "/", MaxAge: -1, HttpOnly: true})
+
+	// The provider reports errors, such as the user cancelling, in the query string.
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("login failed: %s", e))
+		return
+	}
+
+	// Read the flow cookie and check the state, so the callback cannot be forged by another site.
+	flow, err := readFlowCookie(r)
+	if err != nil || flow.provider != p.Name() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("login session expired, please try again"))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(flow.state)) != 1 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid login state"))
+		return
+	}
+
+	// Exchange the code for an ID token, proving with the PKCE verifier that we started the login.
+	rawIDToken, err := p.Exchange(q.Get("code"), flow.verifier)
+	if err != nil {
+		log.Printf("failed to exchange code with %s: %v", p.Name(), err)
+		utils.WriteError(w, http.StatusBadGateway, fmt.Errorf("failed to complete login with the identity provider"))
+		return
+	}
+
+	// Validate the ID token.
+	claims, err := p.VerifyIDToken(rawIDToken, flow.nonce)
+	if err != nil {
+		log.Printf("failed to verify ID token from %s: %v", p.Name(), err)
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid identity token"))
+		return
+	}
+
+	// Find, link or create the user.
+	u, status, err := h.resolveUser(p.Name(), claims)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	// Disabled accounts cannot log in.
+	if u.Disabled {
+		utils.WriteError(w, http.StatusForbidden, fmt.Errorf("account is disabled"))
+		return
+	}
+
+	// Issue the same token as a password login.
+	res, err := auth.CreateLoginResponse(u)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// resolveUser returns the user an external identity belongs to.
+// Identities already linked log in as their user. Otherwise the identity is linked by its verified email:
+// to the existing user with that email, or to a new user if there is none. On failure it also returns
+// the HTTP status code to respond with.
+func (h *Handler) resolveUser(provider string, claims *IDTokenClaims) (*types.User, int, error) {
+	// Identities that have been linked before log in directly.
+	if identity, err := h.identityStore.GetIdentity(provider, claims.Subject); err == nil {
+		u, err := h.userStore.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return u, 0, nil
+	}
+
+	// Linking is done by email, so the provider must have verified it.
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, http.StatusForbidden, fmt.Errorf("the identity provider has not verified your email address")
+	}
+	email := strings.ToLower(claims.Email)
+
+	u, err := h.userStore.GetUserByEmail(email)
+	if err == nil {
+		// Never link to an account whose email we have not verified ourselves: anyone could have
+		// registered it, and linking would give them access to the real owner's logins.
+		if !u.Verified {
+			return nil, http.StatusConflict, fmt.Errorf("an account with this email already exists, log in with your password to continue")
+		}
+	} else {
+		// Create a new, verified user. It gets no password, so it can only log in through the provider
+		// until the user sets one at /me/password.
+		u, err = h.createUser(email, claims)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	// Link the identity, so future logins find the user even if the email changes.
+	err = h.identityStore.CreateIdentity(types.Identity{
+		UserID:   u.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return u, 0, nil
+}
+
+// createUser creates a verified customer for a first-time social login. The user has no password: an empty hash
+// never matches one, so password logins fail until the user sets one.
+func (h *Handler) createUser(email string, claims *IDTokenClaims) (*types.User, error) {
+	// Fall back to the local part of the email when the provider did not share a name.
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(email, "@")
+	}
+
+	err := h.userStore.CreateUser(types.User{
+		FirstName: firstName,
+		LastName:  lastName,
+		Email:     email,
+		Role:      types.RoleCustomer,
+		Verified:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the user back to get its ID.
+	return h.userStore.GetUserByEmail(email)
+}
+
+// flowState is the state of an ongoing login, kept in the flow cookie.
+type flowState struct {
+	provider string
+	state    string
+	nonce    string
+	verifier string
+}
+
+// newFlowCookie returns the signed cookie holding the state of a new login.
+func newFlowCookie(provider, state, nonce, verifier string) (*http.Cookie, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"provider": provider,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"exp":      time.Now().Add(flowLifetime).Unix(),
+	})
+
+	value, err := token.SignedString(flowKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Cookie{
+		Name:     flowCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(flowLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.Envs.PublicHost, "https://"),
+		// Lax, so the cookie is sent along with the provider's top-level redirect back to us.
+		SameSite: http.SameSiteLaxMode,
+	}, nil
+}
+
+// flowKey returns the key flow cookies are signed with. It is derived from the JWT secret for flow cookies only, so
+// a flow cookie is never accepted as an access token, nor an access token as a flow cookie.
+func flowKey() []byte {
+	mac := hmac.New(sha256.New, []byte(config.Envs.JWTSecret))
+	mac.Write([]byte(flowKeyPurpose))
+	return mac.Sum(nil)
+}
+
+// readFlowCookie reads and verifies the flow cookie of a callback request.
+func readFlowCookie(r *http.Request) (*flowState, error) {
+	cookie, err := r.Cookie(flowCookieName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(cookie.Value, claims, func(t *jwt.Token) (interface{}, error) {
+		return flowKey(), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	flow := new(flowState)
+	flow.provider, _ = claims["provider"].(string)
+	flow.state, _ = claims["state"].(string)
+	flow.nonce, _ = claims["nonce"].(string)
+	flow.verifier, _ = claims["verifier"].(string)
+	if flow.state == "" || flow.nonce == "" || flow.verifier == "" {
+		return nil, fmt.Errorf("incomplete flow cookie")
+	}
+
+	return flow, nil
+}
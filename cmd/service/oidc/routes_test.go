@@ -0,0 +1,385 @@
+package oidc
+
+import (
+	"crypto/rand"       // Import the crypto/rand package to generate the fake provider's signing key
+	"crypto/rsa"        // Import the crypto/rsa package for the fake provider's signing key
+	"encoding/base64"   // Import the encoding/base64 package to publish the key in the JWKS
+	"encoding/json"     // Import the encoding/json package for JSON encoding and decoding
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"math/big"          // Import the math/big package to encode the key exponent
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"net/url"           // Import the net/url package for reading redirect URLs
+	"strings"           // Import the strings package for string helpers
+	"sync"              // Import the sync package to guard the fake provider's state
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for token expiry
+
+	"github.com/FreekAlberti/Ecom/cmd/config" // Import the config package for the provider settings
+	"github.com/FreekAlberti/Ecom/cmd/types"  // Import the custom types package for user-related types
+	"github.com/golang-jwt/jwt/v5"            // Import the golang-jwt package to sign ID tokens
+	"github.com/gorilla/mux"                  // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestSocialLogin tests the OIDC login flow against an in-process fake identity provider.
+func TestSocialLogin(t *testing.T) {
+	// Start a fake provider, and register the routes of a handler logging in through it with empty stores. The
+	// tests that look at the stores register a handler of their own.
+	fp := newFakeProvider(t)
+	providers := NewProviders([]config.OIDCProvider{{
+		Name:         "fake",
+		IssuerURL:    fp.server.URL,
+		ClientID:     fp.clientID,
+		ClientSecret: fp.clientSecret,
+		RedirectURL:  "http://shop.example.com/api/v1/oauth/fake/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}})
+
+	router := mux.NewRouter()
+	NewHandler(providers, &mockUserStore{users: map[int]*types.User{}}, &mockIdentityStore{}).RegisterRoutes(router)
+
+	t.Run("should create and link a new user, then reuse the link", func(t *testing.T) {
+		userStore := &mockUserStore{users: map[int]*types.User{}}
+		identityStore := &mockIdentityStore{}
+		router := mux.NewRouter()
+		NewHandler(providers, userStore, identityStore).RegisterRoutes(router)
+		fp.claims = map[string]any{"sub": "abc", "email": "Jane@example.com", "email_verified": true, "given_name": "Jane"}
+
+		rr := login(t, fp, router, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if len(userStore.users) != 1 || len(identityStore.identities) != 1 {
+			t.Fatalf("expected one user and one identity, got %d and %d", len(userStore.users), len(identityStore.identities))
+		}
+		u := userStore.users[1]
+		if u.Email != "jane@example.com" || !u.Verified || u.FirstName != "Jane" {
+			t.Errorf("unexpected user %+v", u)
+		}
+
+		// A second login, even with a changed email, finds the user through the link.
+		fp.claims["email"] = "jane.doe@example.com"
+		if rr := login(t, fp, router, nil); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if len(userStore.users) != 1 || len(identityStore.identities) != 1 {
+			t.Errorf("expected no new user or identity, got %d and %d", len(userStore.users), len(identityStore.identities))
+		}
+	})
+
+	t.Run("should link to an existing verified user by email", func(t *testing.T) {
+		userStore := &mockUserStore{users: map[int]*types.User{7: {ID: 7, Email: "jane@example.com", Verified: true}}}
+		identityStore := &mockIdentityStore{}
+		router := mux.NewRouter()
+		NewHandler(providers, userStore, identityStore).RegisterRoutes(router)
+		fp.claims = map[string]any{"sub": "abc", "email": "jane@example.com", "email_verified": "true"}
+
+		if rr := login(t, fp, router, nil); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if len(identityStore.identities) != 1 || identityStore.identities[0].UserID != 7 {
+			t.Errorf("expected the identity to be linked to user 7, got %+v", identityStore.identities)
+		}
+	})
+
+	t.Run("should not link to an unverified local account", func(t *testing.T) {
+		userStore := &mockUserStore{users: map[int]*types.User{7: {ID: 7, Email: "jane@example.com"}}}
+		identityStore := &mockIdentityStore{}
+		router := mux.NewRouter()
+		NewHandler(providers, userStore, identityStore).RegisterRoutes(router)
+		fp.claims = map[string]any{"sub": "abc", "email": "jane@example.com", "email_verified": true}
+
+		if rr := login(t, fp, router, nil); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if len(identityStore.identities) != 0 {
+			t.Errorf("expected no identities, got %d", len(identityStore.identities))
+		}
+	})
+
+	t.Run("should reject an email the provider has not verified", func(t *testing.T) {
+		fp.claims = map[string]any{"sub": "abc", "email": "jane@example.com", "email_verified": false}
+
+		if rr := login(t, fp, router, nil); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should reject a callback with the wrong state", func(t *testing.T) {
+		fp.claims = map[string]any{"sub": "abc", "email": "jane@example.com", "email_verified": true}
+
+		rr := login(t, fp, router, func(q url.Values) { q.Set("state", "forged") })
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should reject an ID token for another audience", func(t *testing.T) {
+		fp.claims = map[string]any{"sub": "abc", "email": "jane@example.com", "email_verified": true, "aud": "someone-else"}
+
+		if rr := login(t, fp, router, nil); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should reject an ID token with the wrong nonce", func(t *testing.T) {
+		fp.claims = map[string]any{"sub": "abc", "email": "jane@example.com", "email_verified": true, "nonce": "replayed"}
+
+		if rr := login(t, fp, router, nil); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should reject a callback without the flow cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/oauth/fake/callback?code=x&state=y", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should keep flow cookies and access tokens apart", func(t *testing.T) {
+		cookie, err := newFlowCookie("fake", "state", "nonce", "verifier")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A flow cookie does not verify with the key of access tokens.
+		if _, err := jwt.Parse(cookie.Value, func(*jwt.Token) (interface{}, error) { return []byte(config.Envs.JWTSecret), nil }); err == nil {
+			t.Error("expected the flow cookie not to verify as an access token")
+		}
+
+		// A token signed with the key of access tokens is not accepted as a flow cookie.
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"provider": "fake", "state": "state", "nonce": "nonce", "verifier": "verifier",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}).SignedString([]byte(config.Envs.JWTSecret))
+		req := httptest.NewRequest(http.MethodGet, "/oauth/fake/callback", nil)
+		req.AddCookie(&http.Cookie{Name: flowCookieName, Value: token})
+		if _, err := readFlowCookie(req); err == nil {
+			t.Error("expected a token signed with the JWT secret to be rejected as a flow cookie")
+		}
+	})
+}
+
+// login runs a full login through the router and the fake provider, and returns the callback response.
+// The optional tamper function can change the callback query before it is sent.
+func login(t *testing.T, fp *fakeProvider, router *mux.Router, tamper func(url.Values)) *httptest.ResponseRecorder {
+	t.Helper()
+
+	// Start the login, which redirects to the provider and sets the flow cookie.
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/oauth/fake/login", nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusFound, rr.Code, rr.Body)
+	}
+	cookies := rr.Result().Cookies()
+
+	// Visit the provider's authorization endpoint without following its redirect back.
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := callback.Query()
+	if tamper != nil {
+		tamper(q)
+	}
+
+	// Follow the redirect back to the callback, sending the flow cookie along.
+	req := httptest.NewRequest(http.MethodGet, "/oauth/fake/callback?"+q.Encode(), nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// fakeProvider is an in-process OpenID Connect provider used for testing purposes.
+// It authorizes every request immediately and issues ID tokens with the configured claims.
+type fakeProvider struct {
+	server       *httptest.Server
+	key          *rsa.PrivateKey
+	clientID     string
+	clientSecret string
+	claims       map[string]any // Claims added to (or overriding) the standard ID token claims.
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthorization // Issued authorization codes.
+}
+
+// fakeAuthorization holds what the fake provider remembers about an authorization code.
+type fakeAuthorization struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// newFakeProvider starts a fake provider that is stopped when the test ends.
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fp := &fakeProvider{
+		key:          key,
+		clientID:     "shop",
+		clientSecret: "shop-secret",
+		codes:        map[string]fakeAuthorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", fp.handleDiscovery)
+	mux.HandleFunc("/jwks", fp.handleJWKS)
+	mux.HandleFunc("/authorize", fp.handleAuthorize)
+	mux.HandleFunc("/token", fp.handleToken)
+
+	fp.server = httptest.NewServer(mux)
+	t.Cleanup(fp.server.Close)
+
+	return fp
+}
+
+// handleDiscovery serves the discovery document.
+func (fp *fakeProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 fp.server.URL,
+		"authorization_endpoint": fp.server.URL + "/authorize",
+		"token_endpoint":         fp.server.URL + "/token",
+		"jwks_uri":               fp.server.URL + "/jwks",
+	})
+}
+
+// handleJWKS serves the public signing key.
+func (fp *fakeProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "key-1",
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(fp.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(fp.key.E)).Bytes()),
+	}}})
+}
+
+// handleAuthorize issues an authorization code and redirects back to the client.
+func (fp *fakeProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != fp.clientID || q.Get("code_challenge_method") != "S256" || !strings.Contains(q.Get("scope"), "openid") {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	fp.mu.Lock()
+	code := fmt.Sprintf("code-%d", len(fp.codes)+1)
+	fp.codes[code] = fakeAuthorization{
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+	}
+	fp.mu.Unlock()
+
+	http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+}
+
+// handleToken exchanges an authorization code for an ID token, checking the client and the PKCE verifier.
+func (fp *fakeProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	fp.mu.Lock()
+	authz, ok := fp.codes[r.Form.Get("code")]
+	delete(fp.codes, r.Form.Get("code"))
+	fp.mu.Unlock()
+
+	if !ok || r.Form.Get("client_id") != fp.clientID || r.Form.Get("client_secret") != fp.clientSecret ||
+		r.Form.Get("redirect_uri") != authz.redirectURI || NewCodeChallenge(r.Form.Get("code_verifier")) != authz.codeChallenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Build the ID token from the standard claims and the configured ones.
+	claims := jwt.MapClaims{
+		"iss":   fp.server.URL,
+		"aud":   fp.clientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": authz.nonce,
+	}
+	for k, v := range fp.claims {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key-1"
+	idToken, err := token.SignedString(fp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User
+}
+
+// GetUserByEmail is a mock method that looks up a user by email.
+func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+// GetUserByID is a mock method that looks up a user by ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	return u, nil
+}
+
+// CreateUser is a mock method that stores the user with the next free ID.
+func (m *mockUserStore) CreateUser(u types.User) error {
+	u.ID = len(m.users) + 1
+	m.users[u.ID] = &u
+	return nil
+}
+
+// mockIdentityStore is an in-memory implementation of the IdentityStore interface.
+type mockIdentityStore struct {
+	identities []types.Identity
+}
+
+// GetIdentity is a mock method that looks up an identity by provider and subject.
+func (m *mockIdentityStore) GetIdentity(provider, subject string) (*types.Identity, error) {
+	for _, i := range m.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return &i, nil
+		}
+	}
+	return nil, fmt.Errorf("identity not found")
+}
+
+// CreateIdentity is a mock method that records the identity.
+func (m *mockIdentityStore) CreateIdentity(identity types.Identity) error {
+	m.identities = append(m.identities, identity)
+	return nil
+}
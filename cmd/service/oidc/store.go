@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+
+	// Import the types package for the Identity type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Store struct represents the data store for the external identities linked to users.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetIdentity is a method on the Store struct that retrieves a linked identity by provider and subject.
+// It returns an error if the identity has not been linked.
+func (s *Store) GetIdentity(provider, subject string) (*types.Identity, error) {
+	rows, err := s.db.Query(
+		"SELECT id, userId, provider, subject, email, createdAt FROM user_identities WHERE provider = ? AND subject = ?",
+		provider, subject,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// The provider and subject are unique together, so there is at most one row.
+	if !rows.Next() {
+		return nil, fmt.Errorf("identity not found")
+	}
+
+	identity := new(types.Identity)
+	err = rows.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// CreateIdentity is a method on the Store struct that links an external identity to a user.
+func (s *Store) CreateIdentity(identity types.Identity) error {
+	_, err := s.db.Exec(
+		"INSERT INTO user_identities (userId, provider, subject, email) VALUES (?, ?, ?, ?)",
+		identity.UserID, identity.Provider, identity.Subject, identity.Email,
+	)
+
+	return err
+}
@@ -0,0 +1,124 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	// Import the config package for how long allocated stock is held.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the types package for the store interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Allocator struct gives received stock to the backordered and preordered items waiting for it.
+type Allocator struct {
+	orders    types.OrderStore           // Interface for the orders.
+	inventory types.InventoryStore       // Interface for inventory data operations.
+	policies  types.BackorderPolicyStore // Interface for the backorder and preorder policies.
+	router    types.OrderRouter          // Chooses the warehouses allocated items ship from.
+}
+
+// NewAllocator is a constructor function that returns a new Allocator.
+func NewAllocator(orders types.OrderStore, inventory types.InventoryStore, policies types.BackorderPolicyStore, router types.OrderRouter) *Allocator {
+	return &Allocator{orders: orders, inventory: inventory, policies: policies, router: router}
+}
+
+// AllocateStock is a method on the Allocator struct that reserves the stock available to sell of a SKU for the
+// items waiting for it, first come first served, and returns how many items were allocated. Each allocated item
+// is routed to the warehouse it ships from, and its reservation committed there.
+// Items are allocated whole and strictly in order: when the oldest waiting item cannot be covered, allocation
+// stops, so a later, smaller order never jumps the queue.
+func (a *Allocator) AllocateStock(sku string) (int, error) {
+	items, err := a.orders.GetWaitingItems(sku)
+	if err != nil {
+		return 0, err
+	}
+
+	hold := time.Second * time.Duration(config.Envs.BackorderHoldInSeconds)
+	allocated := 0
+	for _, item := range items {
+		id, err := a.inventory.CreateReservation(types.Reservation{
+			SKU:       sku,
+			Quantity:  item.Quantity,
+			Reference: fmt.Sprintf("order:%d", item.OrderID),
+			ExpiresAt: time.Now().Add(hold).UTC(),
+		})
+		if errors.Is(err, types.ErrInsufficientStock) {
+			break
+		}
+		if err != nil {
+			return allocated, err
+		}
+
+		warehouseID, err := a.route(item)
+		if err == nil {
+			err = a.orders.AllocateItem(item.ID, id, warehouseID)
+		}
+		if err != nil {
+			a.inventory.ReleaseReservation(id)
+			return allocated, err
+		}
+
+		// Take the stock out of the warehouse the item ships from, so it stays allocated once the hold would expire.
+		// If no single warehouse has it, the item waits again, still first in line, until its stock is moved.
+		item.ReservationID = &id
+		if warehouseID != 0 {
+			item.WarehouseID = &warehouseID
+		}
+		if commitItem(a.orders, a.inventory, item.OrderID, *item) {
+			break
+		}
+		allocated++
+
+		// The item no longer waits, so it no longer counts against the policy's limit. Items put back to wait
+		// after checkout never counted.
+		if item.Availability != types.ItemInStock {
+			if err := a.policies.ReleaseBackorder(sku, item.Quantity); err != nil {
+				log.Printf("failed to release backorder of %s for order %d: %v", sku, item.OrderID, err)
+			}
+		}
+
+		if err := a.updateStatus(item.OrderID); err != nil {
+			return allocated, err
+		}
+	}
+
+	return allocated, nil
+}
+
+// route returns the warehouse an item ships from, towards the destination of its order, or 0 if none has it.
+func (a *Allocator) route(item *types.OrderItem) (int, error) {
+	o, err := a.orders.GetOrderByID(item.OrderID)
+	if err != nil {
+		return 0, err
+	}
+
+	warehouses, err := a.router.Route(o.Destination, []types.OrderLine{{SKU: item.SKU, Quantity: item.Quantity}})
+	if err != nil {
+		return 0, err
+	}
+
+	return warehouses[0], nil
+}
+
+// updateStatus marks a backordered or preordered order as pending once none of its items wait for stock anymore.
+// Orders that have started shipping keep their status.
+func (a *Allocator) updateStatus(orderID int) error {
+	o, err := a.orders.GetOrderByID(orderID)
+	if err != nil {
+		return err
+	}
+	if o.Status != types.OrderBackordered && o.Status != types.OrderPreordered {
+		return nil
+	}
+
+	for _, item := range o.Items {
+		if item.ReservationID == nil {
+			return nil
+		}
+	}
+
+	return a.orders.UpdateOrderStatus(orderID, types.OrderPending)
+}
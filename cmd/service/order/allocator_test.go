@@ -0,0 +1,83 @@
+package order
+
+import (
+	"testing" // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for order types
+)
+
+// TestAllocateStock tests that received stock goes to waiting items first come first served.
+func TestAllocateStock(t *testing.T) {
+	orders := &mockOrderStore{}
+	inventory := &mockInventoryStore{available: map[string]int{}}
+	policies := &mockPolicyStore{policies: map[string]*types.BackorderPolicy{
+		"BACK": {SKU: "BACK", Mode: types.PolicyBackorder, Waiting: 6},
+	}}
+
+	// Three orders wait for 3, 2 and 1 units.
+	for _, quantity := range []int{3, 2, 1} {
+		orders.CreateOrder(types.Order{UserID: 1, Status: types.OrderBackordered, Items: []types.OrderItem{
+			{SKU: "BACK", Quantity: quantity, Availability: types.ItemBackorder},
+		}})
+	}
+	allocator := NewAllocator(orders, inventory, policies, &mockOrderRouter{})
+
+	// 2 units arrive: not enough for the oldest order, and the others must not jump the queue.
+	inventory.available["BACK"] = 2
+	if n, err := allocator.AllocateStock("BACK"); err != nil || n != 0 {
+		t.Fatalf("expected no allocation, got %d (%v)", n, err)
+	}
+
+	// 3 more arrive: the oldest and the second order get theirs.
+	inventory.available["BACK"] += 3
+	if n, err := allocator.AllocateStock("BACK"); err != nil || n != 2 {
+		t.Fatalf("expected 2 allocations, got %d (%v)", n, err)
+	}
+
+	for i, expected := range []string{types.OrderPending, types.OrderPending, types.OrderBackordered} {
+		if status := orders.orders[i].Status; status != expected {
+			t.Errorf("expected order %d to be %s, got %s", i+1, expected, status)
+		}
+	}
+	if id := orders.orders[0].Items[0].WarehouseID; id == nil || *id != 1 {
+		t.Errorf("expected the allocated item to ship from warehouse 1, got %v", id)
+	}
+	if len(inventory.sales) != 2 {
+		t.Errorf("expected the 2 allocations to be committed, got %v", inventory.sales)
+	}
+	if waiting := policies.policies["BACK"].Waiting; waiting != 1 {
+		t.Errorf("expected 1 unit still waiting, got %d", waiting)
+	}
+
+	// The last order shipped its other items meanwhile, and stays partially shipped when its unit arrives.
+	orders.orders[2].Status = types.OrderPartiallyShipped
+	inventory.available["BACK"]++
+	if n, err := allocator.AllocateStock("BACK"); err != nil || n != 1 {
+		t.Fatalf("expected 1 allocation, got %d (%v)", n, err)
+	}
+	if status := orders.orders[2].Status; status != types.OrderPartiallyShipped {
+		t.Errorf("expected order 3 to stay %s, got %s", types.OrderPartiallyShipped, status)
+	}
+
+	// An item that no single warehouse has in stock waits again, and holds back the items behind it.
+	orders.CreateOrder(types.Order{UserID: 1, Status: types.OrderBackordered, Items: []types.OrderItem{
+		{SKU: "BACK", Quantity: 1, Availability: types.ItemBackorder},
+	}})
+	orders.CreateOrder(types.Order{UserID: 1, Status: types.OrderBackordered, Items: []types.OrderItem{
+		{SKU: "BACK", Quantity: 1, Availability: types.ItemBackorder},
+	}})
+	inventory.short = 1
+	inventory.available["BACK"] = 2
+	if n, err := allocator.AllocateStock("BACK"); err != nil || n != 0 {
+		t.Fatalf("expected no allocation, got %d (%v)", n, err)
+	}
+	if item := orders.orders[3].Items[0]; item.ReservationID != nil || inventory.available["BACK"] != 2 || orders.orders[4].Items[0].ReservationID != nil {
+		t.Errorf("expected both items to wait with the stock given back, got %+v", item)
+	}
+
+	// Once the stock is moved, the next allocation gives it to them.
+	inventory.short = 0
+	if n, err := allocator.AllocateStock("BACK"); err != nil || n != 2 {
+		t.Fatalf("expected 2 allocations, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,85 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	// Import the types package for the store interfaces and the cart types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// CartPricer struct prices the items of a cart from the catalog, in the currency of the customer, and applies
+// the promotions to them.
+type CartPricer struct {
+	products   types.ProductStore       // Interface for the product catalog.
+	variants   types.VariantStore       // Interface for the variants of products.
+	pricer     types.Pricer             // Shows prices in the currency of the customer.
+	promotions types.PromotionEvaluator // Applies the promotions.
+}
+
+// NewCartPricer is a constructor function that returns a new CartPricer instance.
+func NewCartPricer(products types.ProductStore, variants types.VariantStore, pricer types.Pricer, promotions types.PromotionEvaluator) *CartPricer {
+	return &CartPricer{products: products, variants: variants, pricer: pricer, promotions: promotions}
+}
+
+// PriceCart is a method on the CartPricer struct that prices the items for a user in a currency. SKUs of variants
+// sell at the price of the variant, or of their product if the variant has none.
+func (c *CartPricer) PriceCart(userID int, currency string, items []types.OrderLine, codes []string) (*types.CartPricing, error) {
+	cart := types.Cart{UserID: userID, Currency: currency, Lines: []types.CartLine{}, Codes: []string{}}
+	for _, code := range codes {
+		cart.Codes = append(cart.Codes, strings.ToUpper(strings.TrimSpace(code)))
+	}
+
+	for _, item := range items {
+		line, err := c.priceLine(item, currency)
+		if err != nil {
+			return nil, err
+		}
+		cart.Lines = append(cart.Lines, *line)
+	}
+
+	return c.promotions.Evaluate(cart)
+}
+
+// priceLine looks up the SKU of an item as a variant, then as a product, and returns it as a cart line.
+func (c *CartPricer) priceLine(item types.OrderLine, currency string) (*types.CartLine, error) {
+	variant, err := c.variants.GetVariantBySKU(item.SKU)
+	if err != nil && !errors.Is(err, types.ErrVariantNotFound) {
+		return nil, err
+	}
+
+	var p *types.Product
+	if variant != nil {
+		p, err = c.products.GetProductByID(variant.ProductID)
+	} else {
+		p, err = c.products.GetProductBySKU(item.SKU)
+	}
+	if errors.Is(err, types.ErrProductNotFound) {
+		return nil, fmt.Errorf("%w %s", types.ErrUnknownSKU, item.SKU)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.pricer.PriceProducts([]*types.Product{p}, currency); err != nil {
+		return nil, err
+	}
+	price := p.Price
+	if variant != nil && variant.Price != nil {
+		if err := c.pricer.PriceVariants([]*types.ProductVariant{variant}, currency); err != nil {
+			return nil, err
+		}
+		price = *variant.Price
+	}
+
+	return &types.CartLine{
+		SKU:        item.SKU,
+		ProductID:  p.ID,
+		CategoryID: p.CategoryID,
+		TaxClass:   p.TaxClass,
+		Weight:     p.Weight,
+		Quantity:   item.Quantity,
+		UnitPrice:  price,
+	}, nil
+}
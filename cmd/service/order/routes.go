@@ -0,0 +1,704 @@
+package order
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the config package for how long stock is held for a checkout.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the auth package for the authentication middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the currency package to select the currency of a request.
+	"github.com/FreekAlberti/Ecom/cmd/service/currency"
+	// Import the tax package to normalize VAT IDs.
+	"github.com/FreekAlberti/Ecom/cmd/service/tax"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// shippingSKU is what the tax on shipping is listed under, among the lines of the cart.
+const shippingSKU = "shipping"
+
+// Handler struct groups the methods that handle order requests.
+type Handler struct {
+	store      types.OrderStore           // Interface for the orders.
+	inventory  types.InventoryStore       // Interface for inventory data operations.
+	policies   types.BackorderPolicyStore // Interface for the backorder and preorder policies.
+	router     types.OrderRouter          // Chooses the warehouses items ship from.
+	carts      types.CartPricer           // Prices the items of carts, with their promotions.
+	promotions types.PromotionStore       // Interface for the redemptions of promotions.
+	balances   types.BalanceStore         // Interface for gift cards and store credit.
+	methods    types.PaymentMethodStore   // Interface for the saved payment methods checkout charges.
+	payments   types.PaymentProvider      // Charges what gift cards and store credit leave due.
+	taxes      types.TaxCalculator        // Works out the tax on orders.
+	shipping   types.ShippingQuoter       // Lists the shipping methods of carts, with their prices.
+	pricer     types.Pricer               // Selects the currency of the customer.
+	userStore  types.UserStore            // Interface for user-related data operations, used to authenticate customers.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.OrderStore, inventory types.InventoryStore, policies types.BackorderPolicyStore, router types.OrderRouter, carts types.CartPricer, promotions types.PromotionStore, balances types.BalanceStore, methods types.PaymentMethodStore, payments types.PaymentProvider, taxes types.TaxCalculator, shipping types.ShippingQuoter, pricer types.Pricer, userStore types.UserStore) *Handler {
+	return &Handler{
+		store:      store,
+		inventory:  inventory,
+		policies:   policies,
+		router:     router,
+		carts:      carts,
+		promotions: promotions,
+		balances:   balances,
+		methods:    methods,
+		payments:   payments,
+		taxes:      taxes,
+		shipping:   shipping,
+		pricer:     pricer,
+		userStore:  userStore,
+	}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the order routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Show the prices and discounts of the items in the cart; guests can too.
+	router.HandleFunc("/cart/price", h.handlePriceCart).Methods(http.MethodPost)
+
+	// List the shipping methods that can ship the cart to an address, with their prices; guests can too.
+	router.HandleFunc("/cart/shipping-options", h.handleShippingOptions).Methods(http.MethodGet)
+
+	// Place an order for the items in the cart.
+	router.HandleFunc("/cart/checkout", auth.WithAuth(h.handleCheckout, h.userStore)).Methods(http.MethodPost)
+}
+
+// handlePriceCart handles POST /cart/price.
+// It prices the items as checkout would, in the currency of the request and with the tax of the address, and lists
+// the promotions applied and the coupon codes that were not, with the reason. Promotions for signed in customers
+// need the customer's credentials.
+func (h *Handler) handlePriceCart(w http.ResponseWriter, r *http.Request) {
+	var payload types.CheckoutPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	userID := 0
+	if u := auth.UserFromRequest(r, h.userStore); u != nil {
+		userID = u.ID
+	}
+
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pricing, status, err := h.priceCart(cur, userID, payload)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, pricing)
+}
+
+// handleShippingOptions handles GET /cart/shipping-options.
+// The cart and its address are given in the query, such as ?items=SHIRT:2,HAT:1&codes=SUMMER&country=NL&postalCode=1011AB.
+// It lists the methods of the shipping zone of the address that can ship the items, cheapest first, priced in the
+// currency of the request after the promotions of the cart. Choosing one at checkout adds its price to the order.
+func (h *Handler) handleShippingOptions(w http.ResponseWriter, r *http.Request) {
+	payload, err := parseCartQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid query %v", errors))
+		return
+	}
+
+	userID := 0
+	if u := auth.UserFromRequest(r, h.userStore); u != nil {
+		userID = u.ID
+	}
+
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pricing, err := h.carts.PriceCart(userID, cur, payload.Items, payload.Codes)
+	if errors.Is(err, types.ErrUnknownSKU) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	options, err := h.shipping.ShippingOptions(*payload.Address, pricing)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, options)
+}
+
+// parseCartQuery returns the cart described by the query of a request: the items as comma-separated SKU:quantity
+// pairs, the comma-separated coupon codes, and the country, state and postal code of the address.
+func parseCartQuery(r *http.Request) (types.CheckoutPayload, error) {
+	q := r.URL.Query()
+	payload := types.CheckoutPayload{
+		Items:   []types.OrderLine{},
+		Codes:   []string{},
+		Address: &types.Address{Country: q.Get("country"), State: q.Get("state"), PostalCode: q.Get("postalCode")},
+	}
+
+	for _, item := range strings.Split(q.Get("items"), ",") {
+		if item == "" {
+			continue
+		}
+		sku, quantity, ok := strings.Cut(item, ":")
+		n, err := strconv.Atoi(quantity)
+		if !ok || err != nil {
+			return payload, fmt.Errorf("invalid item %q, expected SKU:quantity", item)
+		}
+		payload.Items = append(payload.Items, types.OrderLine{SKU: sku, Quantity: n})
+	}
+	for _, code := range strings.Split(q.Get("codes"), ",") {
+		if code != "" {
+			payload.Codes = append(payload.Codes, code)
+		}
+	}
+
+	return payload, nil
+}
+
+// priceCart prices the items and codes of a payload for a user in a currency, takes off the subscription discount,
+// adds the shipping method chosen, and works out the tax on them. On failure it also returns the HTTP status code
+// to respond with.
+func (h *Handler) priceCart(cur string, userID int, payload types.CheckoutPayload) (*types.CartPricing, int, error) {
+	pricing, err := h.carts.PriceCart(userID, cur, payload.Items, payload.Codes)
+	if errors.Is(err, types.ErrUnknownSKU) {
+		return nil, http.StatusBadRequest, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// The subscription discount comes on top of promotions, before shipping is priced, so free shipping
+	// thresholds see what the customer pays.
+	if payload.SubscriptionDiscount != "" {
+		if err := discount(pricing, payload.SubscriptionDiscount); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	if payload.ShippingMethodID != nil {
+		if status, err := h.ship(pricing, payload); err != nil {
+			return nil, status, err
+		}
+	}
+
+	req := types.TaxRequest{VATID: payload.VATID, Currency: pricing.Currency, Lines: []types.TaxableLine{}}
+	if payload.Address != nil {
+		req.Address = *payload.Address
+	}
+	for _, line := range pricing.Lines {
+		req.Lines = append(req.Lines, types.TaxableLine{SKU: line.SKU, TaxClass: line.TaxClass, Amount: line.Total})
+	}
+	// Shipping is taxed at the standard rate, as the last line.
+	if pricing.Shipping != nil {
+		req.Lines = append(req.Lines, types.TaxableLine{SKU: shippingSKU, TaxClass: types.TaxClassStandard, Amount: pricing.Shipping.Price})
+	}
+
+	result, err := h.taxes.CalculateTax(req)
+	if errors.Is(err, types.ErrInvalidVATID) {
+		return nil, http.StatusBadRequest, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	pricing.Tax = result
+	pricing.Total = result.Gross
+
+	return pricing, 0, nil
+}
+
+// discount takes a percentage, such as "10", off every line of a priced cart, rounding half up per line.
+func discount(pricing *types.CartPricing, percentage string) error {
+	f, ok := new(big.Rat).SetString(percentage)
+	if !ok {
+		return fmt.Errorf("invalid discount %q", percentage)
+	}
+	f.Quo(f, big.NewRat(100, 1))
+
+	for i := range pricing.Lines {
+		line := &pricing.Lines[i]
+		off, err := line.Total.MulRat(f, types.RoundHalfUp)
+		if err != nil {
+			return err
+		}
+		line.Discount.Amount += off.Amount
+		line.Total.Amount -= off.Amount
+		pricing.Discount.Amount += off.Amount
+		pricing.Total.Amount -= off.Amount
+	}
+
+	return nil
+}
+
+// ship sets the shipping method chosen in a payload on the pricing of its cart, priced for the address.
+// On failure it also returns the HTTP status code to respond with.
+func (h *Handler) ship(pricing *types.CartPricing, payload types.CheckoutPayload) (int, error) {
+	if payload.Address == nil {
+		return http.StatusBadRequest, fmt.Errorf("an address is required to choose a shipping method")
+	}
+
+	options, err := h.shipping.ShippingOptions(*payload.Address, pricing)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	for i := range options {
+		if options[i].MethodID == *payload.ShippingMethodID {
+			pricing.Shipping = &options[i]
+			return 0, nil
+		}
+	}
+
+	return http.StatusBadRequest, fmt.Errorf("shipping method %d cannot ship this cart to the address", *payload.ShippingMethodID)
+}
+
+// handleCheckout handles POST /cart/checkout.
+// Stock is reserved for every item that has it. Items without stock are accepted under the backorder or
+// preorder policy of their SKU, if it has one with room left, and the order is flagged as backordered or
+// preordered until stock is allocated to them. The items, and the shipping method chosen, are priced in the
+// currency of the request and taxed for the address, and the promotions applied are redeemed with the order.
+// Gift cards, and store credit if asked, pay what they can of the total before the payment provider charges the
+// rest to the saved payment method given. If any item, code or payment cannot be accepted, nothing is. Admins
+// impersonating a customer cannot charge their payment methods.
+func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
+	var payload types.CheckoutPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var method *types.PaymentMethod
+	if payload.PaymentMethodID != nil {
+		if auth.GetImpersonationFromContext(r.Context()) != nil {
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("payment methods cannot be charged while impersonating"))
+			return
+		}
+		method, err = h.methods.GetPaymentMethodByID(*payload.PaymentMethodID)
+		if err != nil || method.UserID != userID || method.RemovedAt != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("payment method %d is not available", *payload.PaymentMethodID))
+			return
+		}
+	}
+
+	reference, err := newChargeReference(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var charged types.Money
+	created, status, err := h.placeOrder(userID, cur, payload, func(o types.Order) error {
+		if o.AmountDue.Amount <= 0 {
+			return nil
+		}
+		if method == nil {
+			return fmt.Errorf("a payment method is required to pay the %v due", o.AmountDue)
+		}
+
+		if _, err := h.payments.Charge(types.PaymentCharge{UserID: userID, Token: method.Token, Amount: o.AmountDue, Reference: reference}); err != nil {
+			return err
+		}
+		charged = o.AmountDue
+		return nil
+	})
+
+	// The customer was charged, but the order was not stored: give the money back.
+	if err != nil && charged.Amount > 0 {
+		if _, refundErr := h.payments.Refund(types.PaymentRefund{Amount: charged, Reference: reference + ":refund"}); refundErr != nil {
+			log.Printf("failed to refund the charge %s of user %d: %v", reference, userID, refundErr)
+		}
+	}
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// newChargeReference returns a reference for the charge of a checkout of a user. Every checkout is a new attempt
+// to pay, so it gets a random reference of its own.
+func newChargeReference(userID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("checkout:user:%d:%s", userID, hex.EncodeToString(b)), nil
+}
+
+// PlaceOrder is a method on the Handler struct that places an order for a user in a currency as checkout does,
+// for orders placed without a request, such as the renewals of subscriptions. pay, if not nil, is called with
+// the order once everything it needs is held, just before it is stored; if it fails, nothing is held.
+// An order already placed under the reference of the payload, by a run that stopped before recording it, is
+// returned instead of being placed again.
+func (h *Handler) PlaceOrder(userID int, currency string, payload types.CheckoutPayload, pay func(types.Order) error) (*types.Order, error) {
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, fmt.Errorf("invalid payload %v", err)
+	}
+
+	if payload.Reference != "" {
+		o, err := h.store.GetOrderByReference(payload.Reference)
+		if err != nil || o != nil {
+			return o, err
+		}
+	}
+
+	o, _, err := h.placeOrder(userID, currency, payload, pay)
+	return o, err
+}
+
+// placeOrder places an order for the payload, and returns it as stored. On failure it also returns the HTTP status
+// code to respond with.
+func (h *Handler) placeOrder(userID int, cur string, payload types.CheckoutPayload, pay func(types.Order) error) (*types.Order, int, error) {
+	pricing, status, err := h.priceCart(cur, userID, payload)
+	if err != nil {
+		return nil, status, err
+	}
+	if len(pricing.RejectedCodes) > 0 {
+		reasons := []string{}
+		for _, rejected := range pricing.RejectedCodes {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", rejected.Code, rejected.Reason))
+		}
+		return nil, http.StatusBadRequest, fmt.Errorf("coupon codes not applied: %s", strings.Join(reasons, "; "))
+	}
+
+	c := &checkout{handler: h, reference: fmt.Sprintf("checkout:user:%d", userID)}
+
+	o := types.Order{
+		UserID:       userID,
+		Reference:    payload.Reference,
+		Status:       types.OrderPending,
+		Subtotal:     pricing.Subtotal,
+		Discount:     pricing.Discount,
+		Total:        pricing.Total,
+		FreeShipping: pricing.FreeShipping,
+
+		Tax:              pricing.Tax.Tax,
+		PricesIncludeTax: pricing.Tax.PricesIncludeTax,
+		ReverseCharge:    pricing.Tax.ReverseCharge,
+
+		Shipping:    types.NewMoney(0, pricing.Currency),
+		ShippingTax: types.NewMoney(0, pricing.Currency),
+	}
+	if pricing.Shipping != nil {
+		methodID := pricing.Shipping.MethodID
+		o.ShippingMethodID = &methodID
+		o.ShippingMethod = pricing.Shipping.Name
+		o.Shipping = pricing.Shipping.Price
+		o.ShippingTax = pricing.Tax.Lines[len(pricing.Tax.Lines)-1].Tax
+		o.ShippingTaxRate = pricing.Tax.Lines[len(pricing.Tax.Lines)-1].Rate
+	}
+	if payload.Address != nil {
+		address := *payload.Address
+		address.Country, address.State = strings.ToUpper(address.Country), strings.ToUpper(address.State)
+		o.Address = &address
+	}
+	if payload.Location != nil {
+		location := *payload.Location
+		o.Destination = &location
+	}
+	if payload.VATID != "" {
+		o.VATID = tax.NormalizeVATID(payload.VATID)
+	}
+	for i, line := range payload.Items {
+		item, status, err := c.accept(line)
+		if err != nil {
+			c.undo()
+			return nil, status, err
+		}
+		item.UnitPrice = pricing.Lines[i].UnitPrice
+		item.Discount = pricing.Lines[i].Discount
+		item.TaxClass = pricing.Tax.Lines[i].TaxClass
+		item.TaxRate = pricing.Tax.Lines[i].Rate
+		item.Tax = pricing.Tax.Lines[i].Tax
+		item.Total = pricing.Tax.Lines[i].Gross
+		o.Items = append(o.Items, item)
+
+		// Flag the order; a preorder outweighs a backorder, since it waits for a release.
+		switch {
+		case item.Availability == types.ItemPreorder:
+			o.Status = types.OrderPreordered
+		case item.Availability == types.ItemBackorder && o.Status == types.OrderPending:
+			o.Status = types.OrderBackordered
+		}
+	}
+
+	// Choose the warehouse each item holding stock ships from; waiting items are routed when their stock arrives.
+	if err := h.route(o.Destination, o.Items); err != nil {
+		c.undo()
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// Redeem the promotions last, so their limits are checked against the latest redemptions.
+	redemptions, err := h.promotions.RedeemPromotions(userID, pricing.Promotions)
+	if err != nil {
+		c.undo()
+		if errors.Is(err, types.ErrPromotionLimit) {
+			return nil, http.StatusConflict, err
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	c.redemptions = redemptions
+
+	// Pay what gift cards and store credit cover; the payment provider charges the rest.
+	if status, err := c.pay(&o, payload); err != nil {
+		c.undo()
+		return nil, status, err
+	}
+	if pay != nil {
+		if err := pay(o); err != nil {
+			c.undo()
+			return nil, http.StatusPaymentRequired, err
+		}
+	}
+
+	id, err := h.store.CreateOrder(o)
+	if err != nil {
+		c.undo()
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// Take the held stock out of the warehouses it ships from, so it stays sold once the reservations would expire.
+	// Items that cannot be taken out of a warehouse wait for stock, as backorders do.
+	waiting := false
+	for _, item := range o.Items {
+		waiting = commitItem(h.store, h.inventory, id, item) || waiting
+	}
+	if waiting && o.Status == types.OrderPending {
+		if err := h.store.UpdateOrderStatus(id, types.OrderBackordered); err != nil {
+			log.Printf("failed to mark order %d as backordered: %v", id, err)
+		}
+	}
+
+	// The order is placed; failing to link the redemptions and charges to it only loses which order they were for.
+	if err := h.promotions.SetRedemptionOrder(c.redemptions, id); err != nil {
+		log.Printf("failed to link redemptions to order %d: %v", id, err)
+	}
+	if err := h.balances.SetEntriesOrder(c.entries, id); err != nil {
+		log.Printf("failed to link balance charges to order %d: %v", id, err)
+	}
+
+	// The order is placed and paid, so failing to read it back must not fail the checkout: the caller would
+	// otherwise place and charge it again.
+	created, err := h.store.GetOrderByID(id)
+	if err != nil {
+		log.Printf("failed to read back order %d: %v", id, err)
+		o.ID = id
+		return &o, 0, nil
+	}
+
+	return created, 0, nil
+}
+
+// route sets the warehouse each item holding stock ships from.
+func (h *Handler) route(destination *types.Location, items []types.OrderItem) error {
+	lines, held := []types.OrderLine{}, []int{}
+	for i, item := range items {
+		if item.ReservationID != nil {
+			lines = append(lines, types.OrderLine{SKU: item.SKU, Quantity: item.Quantity})
+			held = append(held, i)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	warehouses, err := h.router.Route(destination, lines)
+	if err != nil {
+		return err
+	}
+	for j, i := range held {
+		if id := warehouses[j]; id != 0 {
+			items[i].WarehouseID = &id
+		}
+	}
+
+	return nil
+}
+
+// commitItem turns the reservation of an item into a sale from the warehouse it ships from. The order is placed
+// either way, so when that fails, because the warehouse lacks the stock or the hold has expired, the item is put
+// back to wait for stock instead: its reservation is released, and the next allocation of its SKU holds and
+// routes stock for it again. It returns whether the item waits.
+func commitItem(orders types.OrderStore, inventory types.InventoryStore, orderID int, item types.OrderItem) bool {
+	if item.ReservationID == nil {
+		return false
+	}
+	id := *item.ReservationID
+
+	if item.WarehouseID == nil {
+		log.Printf("no warehouse has the %d x %s of order %d in stock to commit reservation %d to", item.Quantity, item.SKU, orderID, id)
+	} else if err := inventory.CommitReservation(id, *item.WarehouseID); err != nil {
+		log.Printf("failed to commit reservation %d of order %d to warehouse %d: %v", id, orderID, *item.WarehouseID, err)
+	} else {
+		return false
+	}
+
+	// An expired reservation has already given its stock back, so only an active one is released.
+	if err := inventory.ReleaseReservation(id); err != nil {
+		log.Printf("failed to release reservation %d of order %d: %v", id, orderID, err)
+	}
+	if err := orders.RequeueItem(id); err != nil {
+		log.Printf("failed to put the %d x %s of order %d back to wait for stock: %v", item.Quantity, item.SKU, orderID, err)
+		return false
+	}
+
+	return true
+}
+
+// checkout struct tracks the stock held by a checkout in progress, so it can be given back if the checkout fails.
+type checkout struct {
+	handler      *Handler          // The handler, for its stores.
+	reference    string            // Reference of the reservations.
+	reservations []int             // IDs of the reservations made.
+	backorders   []types.OrderLine // Quantities accepted under a backorder or preorder policy.
+	redemptions  []int             // IDs of the redemptions of promotions.
+	entries      []int             // IDs of the ledger entries charging gift cards and store credit.
+}
+
+// accept holds stock for a line, or accepts it under the backorder or preorder policy of its SKU.
+// On failure it also returns the HTTP status code to respond with.
+func (c *checkout) accept(line types.OrderLine) (types.OrderItem, int, error) {
+	item := types.OrderItem{SKU: line.SKU, Quantity: line.Quantity, Availability: types.ItemInStock}
+
+	policy, err := c.handler.policies.GetBackorderPolicy(line.SKU)
+	if err != nil {
+		return item, http.StatusInternalServerError, err
+	}
+
+	// Preorders always wait for the release, even if stock has already arrived.
+	if policy == nil || policy.Mode != types.PolicyPreorder {
+		lifetime := time.Second * time.Duration(config.Envs.CheckoutReservationInSeconds)
+		id, err := c.handler.inventory.CreateReservation(types.Reservation{
+			SKU:       line.SKU,
+			Quantity:  line.Quantity,
+			Reference: c.reference,
+			ExpiresAt: time.Now().Add(lifetime).UTC(),
+		})
+		if err == nil {
+			c.reservations = append(c.reservations, id)
+			item.ReservationID = &id
+			return item, 0, nil
+		}
+		if !errors.Is(err, types.ErrInsufficientStock) {
+			return item, http.StatusInternalServerError, err
+		}
+		if policy == nil {
+			return item, http.StatusConflict, fmt.Errorf("not enough stock of %s", line.SKU)
+		}
+	}
+
+	// Accept the line under the policy, if its limit allows.
+	if err := c.handler.policies.AcceptBackorder(line.SKU, line.Quantity); err != nil {
+		if errors.Is(err, types.ErrBackorderLimit) {
+			return item, http.StatusConflict, fmt.Errorf("not enough stock of %s, and no more %ss are accepted", line.SKU, policy.Mode)
+		}
+		return item, http.StatusInternalServerError, err
+	}
+	c.backorders = append(c.backorders, line)
+
+	item.Availability = types.ItemBackorder
+	if policy.Mode == types.PolicyPreorder {
+		item.Availability = types.ItemPreorder
+	}
+	item.ExpectedAt = policy.ExpectedAt
+
+	return item, 0, nil
+}
+
+// undo gives back everything the checkout held.
+func (c *checkout) undo() {
+	for _, id := range c.reservations {
+		c.handler.inventory.ReleaseReservation(id)
+	}
+	for _, line := range c.backorders {
+		c.handler.policies.ReleaseBackorder(line.SKU, line.Quantity)
+	}
+	if len(c.redemptions) > 0 {
+		c.handler.promotions.ReleaseRedemptions(c.redemptions)
+	}
+	if len(c.entries) > 0 {
+		c.handler.balances.ReverseEntries(c.entries)
+	}
+}
+
+// pay charges the gift cards of the payload and, if asked, the store credit of the customer, and sets what they
+// paid and what is left due on the order. On failure it also returns the HTTP status code to respond with.
+func (c *checkout) pay(o *types.Order, payload types.CheckoutPayload) (int, error) {
+	zero := types.NewMoney(0, o.Total.Currency)
+	o.GiftCardAmount, o.StoreCreditAmount, o.AmountDue = zero, zero, o.Total
+	if len(payload.GiftCards) == 0 && !payload.UseStoreCredit {
+		return 0, nil
+	}
+
+	charges, entries, err := c.handler.balances.ChargeBalances(o.UserID, o.Total, payload.GiftCards, payload.UseStoreCredit)
+	if errors.Is(err, types.ErrInvalidGiftCard) {
+		return http.StatusBadRequest, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	c.entries = entries
+
+	for _, charge := range charges {
+		if charge.GiftCardID != 0 {
+			o.GiftCardAmount.Amount += charge.Amount.Amount
+		} else {
+			o.StoreCreditAmount.Amount += charge.Amount.Amount
+		}
+		o.AmountDue.Amount -= charge.Amount.Amount
+	}
+
+	return 0, nil
+}
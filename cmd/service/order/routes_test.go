@@ -0,0 +1,1309 @@
+package order
+
+import (
+	"bytes"             // Import the bytes package to build request bodies
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"errors"            // Import the errors package to match declined payments
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to match gift card codes
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for the times promotions run at
+
+	"github.com/FreekAlberti/Ecom/cmd/config"            // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"      // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/service/promotion" // Import the promotion package for the promotion engine
+	"github.com/FreekAlberti/Ecom/cmd/types"             // Import the custom types package for order types
+	"github.com/gorilla/mux"                             // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestCheckout tests placing orders with in-stock, backordered and preordered items.
+func TestCheckout(t *testing.T) {
+	// Create the catalog and the stores every checkout only reads. IN sells at 10.00 euros, BACK at 25.00, PRE at
+	// 40.00 and OUT at 5.00. User 1 saved a card (ID 1) and user 2 another one (ID 2). Standard shipping (ID 1) costs
+	// 4.95 euros to the Netherlands. Each test checks out into stores of its own, with the stock, policies and
+	// balances of newMockInventoryStore, newMockPolicyStore and newMockBalanceStore, and without tax.
+	products := &mockProductStore{products: map[string]*types.Product{
+		"IN":   {ID: 1, SKU: "IN", Price: types.NewMoney(1000, "EUR")},
+		"BACK": {ID: 2, SKU: "BACK", Price: types.NewMoney(2500, "EUR")},
+		"PRE":  {ID: 3, SKU: "PRE", Price: types.NewMoney(4000, "EUR")},
+		"OUT":  {ID: 4, SKU: "OUT", Price: types.NewMoney(500, "EUR")},
+	}}
+	variants := &mockVariantStore{variants: map[string]*types.ProductVariant{}}
+	pricer := &mockPricer{}
+	methods := &mockPaymentMethodStore{methods: map[int]*types.PaymentMethod{
+		1: {ID: 1, UserID: 1, Token: "tok_visa"},
+		2: {ID: 2, UserID: 2, Token: "tok_other"},
+	}}
+	shipping := &mockShippingQuoter{options: []types.ShippingOption{
+		{MethodID: 1, Name: "Standard", Price: types.NewMoney(495, "EUR"), MinDays: 1, MaxDays: 2},
+	}}
+	userStore := &mockUserStore{}
+
+	t.Run("should reserve stock for in-stock items", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory := newMockInventoryStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 2}]}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		var o types.Order
+		json.NewDecoder(rr.Body).Decode(&o)
+		if o.Status != types.OrderPending || o.UserID != 1 || o.Items[0].ReservationID == nil {
+			t.Errorf("expected a pending order with reserved stock, got %+v", o)
+		}
+		if inventory.available["IN"] != 3 {
+			t.Errorf("expected 3 units left, got %d", inventory.available["IN"])
+		}
+	})
+
+	t.Run("should route in-stock items to a warehouse", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, newMockInventoryStore(), newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}, {"sku": "BACK", "quantity": 1}], "location": {"latitude": 52.37, "longitude": 4.9}}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		// The backordered item is routed when its stock arrives.
+		o := orders.orders[0]
+		if o.Destination == nil || o.Items[0].WarehouseID == nil || *o.Items[0].WarehouseID != 2 || o.Items[1].WarehouseID != nil {
+			t.Errorf("expected IN to ship from warehouse 2 and BACK to wait, got %+v", o.Items)
+		}
+	})
+
+	t.Run("should keep sold stock unavailable after the reservation would have expired", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory := newMockInventoryStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 2}]}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		// The reservation was committed to the warehouse the item ships from.
+		id := *orders.orders[0].Items[0].ReservationID
+		if inventory.sales[id] != 1 {
+			t.Fatalf("expected reservation %d to be sold from warehouse 1, got %v", id, inventory.sales)
+		}
+
+		// The sweeper runs after the checkout hold; the sold units do not come back.
+		lifetime := time.Second * time.Duration(config.Envs.CheckoutReservationInSeconds)
+		if n, _ := inventory.ExpireReservations(time.Now().Add(lifetime + time.Minute)); n != 0 {
+			t.Errorf("expected no reservation to expire, got %d", n)
+		}
+		if inventory.available["IN"] != 3 {
+			t.Errorf("expected 3 units left, got %d", inventory.available["IN"])
+		}
+	})
+
+	t.Run("should put items back to wait when their warehouse lacks the stock", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory, policies := newMockInventoryStore(), newMockPolicyStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, policies, &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		inventory.short = 1
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 2}]}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		// The hold is given back rather than left to expire, and the item waits like a backorder.
+		o := orders.orders[0]
+		if o.Status != types.OrderBackordered || o.Items[0].ReservationID != nil || o.Items[0].WarehouseID != nil {
+			t.Errorf("expected a backordered order with the item waiting, got %+v", o)
+		}
+		if inventory.available["IN"] != 5 || inventory.reservations[0].Status != types.ReservationReleased {
+			t.Errorf("expected the reservation to be released, got %+v", inventory.reservations[0])
+		}
+
+		// Once the checkout hold would have expired, the next allocation holds stock for the item again.
+		lifetime := time.Second * time.Duration(config.Envs.CheckoutReservationInSeconds)
+		inventory.ExpireReservations(time.Now().Add(lifetime + time.Minute))
+		inventory.short = 0
+		allocator := NewAllocator(orders, inventory, policies, &mockOrderRouter{})
+		if n, err := allocator.AllocateStock("IN"); err != nil || n != 1 {
+			t.Fatalf("expected 1 allocation, got %d (%v)", n, err)
+		}
+		if o.Status != types.OrderPending || o.Items[0].ReservationID == nil || len(inventory.sales) != 1 || inventory.available["IN"] != 3 {
+			t.Errorf("expected the item to be sold from its warehouse, got %+v", o)
+		}
+	})
+
+	t.Run("should flag orders with backordered and preordered items", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		policies := newMockPolicyStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, newMockInventoryStore(), policies, &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}, {"sku": "BACK", "quantity": 2}]}`)
+		var o types.Order
+		json.NewDecoder(rr.Body).Decode(&o)
+		if o.Status != types.OrderBackordered || o.Items[1].Availability != types.ItemBackorder || o.Items[1].ReservationID != nil {
+			t.Errorf("expected a backordered order, got %+v", o)
+		}
+		if policies.policies["BACK"].Waiting != 2 {
+			t.Errorf("expected 2 units waiting, got %d", policies.policies["BACK"].Waiting)
+		}
+
+		// Preorders wait for the release even though stock has arrived, and outweigh backorders.
+		rr = postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "BACK", "quantity": 1}, {"sku": "PRE", "quantity": 1}]}`)
+		json.NewDecoder(rr.Body).Decode(&o)
+		if o.Status != types.OrderPreordered || o.Items[1].Availability != types.ItemPreorder || o.Items[1].ReservationID != nil {
+			t.Errorf("expected a preordered order, got %+v", o)
+		}
+	})
+
+	t.Run("should refuse the whole order when an item cannot be accepted", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory, policies := newMockInventoryStore(), newMockPolicyStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, policies, &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		// BACK is over its limit of 3.
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 2}, {"sku": "BACK", "quantity": 1}, {"sku": "BACK", "quantity": 3}]}`)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		// OUT has no stock and no policy.
+		rr = postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 2}, {"sku": "OUT", "quantity": 1}]}`)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		// Everything held was given back.
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 || policies.policies["BACK"].Waiting != 0 {
+			t.Errorf("expected nothing to be held, got %d orders, %d IN available and %d BACK waiting",
+				len(orders.orders), inventory.available["IN"], policies.policies["BACK"].Waiting)
+		}
+
+		// UNKNOWN is not in the catalog.
+		rr = postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "UNKNOWN", "quantity": 1}]}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an unknown SKU, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should price the order and redeem its promotions", func(t *testing.T) {
+		orders := &mockOrderStore{}
+		promotions := &mockPromotionStore{promotions: []*types.Promotion{
+			{ID: 1, Name: "Summer sale", Action: types.PromotionAction{Type: types.ActionPercentage, Percent: 10}},
+			{ID: 2, Name: "Welcome", Code: "WELCOME", Action: types.PromotionAction{Type: types.ActionFreeShipping}},
+		}}
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, newMockInventoryStore(), newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 2}, {"sku": "BACK", "quantity": 1}], "codes": ["welcome"]}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		o := orders.orders[0]
+		if o.Subtotal != types.NewMoney(4500, "EUR") || o.Discount != types.NewMoney(450, "EUR") || o.Total != types.NewMoney(4050, "EUR") || !o.FreeShipping {
+			t.Errorf("expected 45.00 less 4.50 with free shipping, got %v less %v (%v)", o.Subtotal, o.Discount, o.FreeShipping)
+		}
+		if o.Items[0].UnitPrice != types.NewMoney(1000, "EUR") || o.Items[0].Discount != types.NewMoney(200, "EUR") || o.Items[1].Discount != types.NewMoney(250, "EUR") {
+			t.Errorf("expected the discount to be spread over the items, got %+v", o.Items)
+		}
+		if len(promotions.redemptions) != 2 || promotions.redemptions[0].orderID != 1 || promotions.redemptions[1].orderID != 1 {
+			t.Errorf("expected both promotions to be redeemed for the order, got %+v", promotions.redemptions)
+		}
+	})
+
+	t.Run("should refuse coupon codes that cannot be applied", func(t *testing.T) {
+		orders := &mockOrderStore{}
+		promotions := &mockPromotionStore{promotions: []*types.Promotion{
+			{ID: 1, Name: "Big spender", Code: "BIG", Action: types.PromotionAction{Type: types.ActionPercentage, Percent: 20},
+				Conditions: types.PromotionConditions{MinSubtotal: &types.Money{Amount: 10000, Currency: "EUR"}}},
+		}}
+		inventory := newMockInventoryStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}], "codes": ["BIG", "NOPE"]}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 || len(promotions.redemptions) != 0 {
+			t.Errorf("expected nothing to be held or redeemed")
+		}
+	})
+
+	t.Run("should give everything back when a promotion runs out during checkout", func(t *testing.T) {
+		orders := &mockOrderStore{}
+		promotions := &mockPromotionStore{promotions: []*types.Promotion{
+			{ID: 1, Name: "First come", Code: "FIRST", UsageLimit: 1, Action: types.PromotionAction{Type: types.ActionPercentage, Percent: 50}},
+		}}
+		inventory := newMockInventoryStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		// Another checkout redeems the last use between pricing and redeeming.
+		promotions.raceUserID = 2
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}], "codes": ["FIRST"]}`)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body)
+		}
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 {
+			t.Errorf("expected nothing to be held")
+		}
+	})
+
+	t.Run("should pay with gift cards and store credit before the payment provider", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		balances := newMockBalanceStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, newMockInventoryStore(), newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, balances, methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		// 40.00 is due: 20.00 from the gift card, then 15.00 of store credit.
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 4}], "giftCards": ["gift"], "useStoreCredit": true}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		o := orders.orders[0]
+		if o.GiftCardAmount != types.NewMoney(2000, "EUR") || o.StoreCreditAmount != types.NewMoney(1500, "EUR") || o.AmountDue != types.NewMoney(500, "EUR") {
+			t.Errorf("expected 20.00 by gift card, 15.00 by store credit and 5.00 due, got %v, %v and %v",
+				o.GiftCardAmount, o.StoreCreditAmount, o.AmountDue)
+		}
+		if balances.giftCards["GIFT"] != 0 || balances.credit[1] != 0 || balances.orders[1] != 1 || balances.orders[2] != 1 {
+			t.Errorf("expected both balances to be spent on order 1, got %+v", balances)
+		}
+
+		// Without balances, the whole total is due.
+		postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}]}`)
+		if o := orders.orders[1]; o.AmountDue != o.Total || !o.GiftCardAmount.IsZero() {
+			t.Errorf("expected the total to be due, got %+v", o)
+		}
+	})
+
+	t.Run("should charge what is left due to the payment method", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		payments := &mockPaymentProvider{}
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, newMockInventoryStore(), newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, payments, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 3}], "giftCards": ["GIFT"]}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		if c := payments.charges; len(c) != 1 || c[0].Amount != types.NewMoney(1000, "EUR") || c[0].Token != "tok_visa" || !strings.HasPrefix(c[0].Reference, "checkout:user:1:") {
+			t.Errorf("expected 10.00 charged to the card of user 1, got %+v", c)
+		}
+
+		// Every checkout charges under a reference of its own.
+		postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}]}`)
+		if c := payments.charges; len(c) != 2 || c[1].Reference == c[0].Reference {
+			t.Errorf("expected a new reference, got %+v", c)
+		}
+
+		// Nothing is charged when balances pay everything.
+		postCheckout(t, router, `{"items": [{"sku": "IN", "quantity": 1}], "useStoreCredit": true}`)
+		if len(orders.orders) != 3 || len(payments.charges) != 2 {
+			t.Errorf("expected an order paid by store credit alone, got %+v", payments.charges)
+		}
+	})
+
+	t.Run("should refuse payment methods that cannot be charged", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory, payments := newMockInventoryStore(), &mockPaymentProvider{}
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, payments, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		for body, code := range map[string]int{
+			`{"items": [{"sku": "IN", "quantity": 1}]}`:                       http.StatusPaymentRequired,
+			`{"paymentMethodId": 2, "items": [{"sku": "IN", "quantity": 1}]}`: http.StatusBadRequest,
+			`{"paymentMethodId": 9, "items": [{"sku": "IN", "quantity": 1}]}`: http.StatusBadRequest,
+		} {
+			if rr := postCheckout(t, router, body); rr.Code != code {
+				t.Errorf("expected status code %d for %s, got %d: %s", code, body, rr.Code, rr.Body)
+			}
+		}
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 || len(payments.charges) != 0 {
+			t.Errorf("expected nothing to be held or charged")
+		}
+	})
+
+	t.Run("should give everything back when the charge is declined", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory, balances, payments := newMockInventoryStore(), newMockBalanceStore(), &mockPaymentProvider{decline: true}
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, balances, methods, payments, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 3}], "giftCards": ["GIFT"]}`)
+		if rr.Code != http.StatusPaymentRequired {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusPaymentRequired, rr.Code, rr.Body)
+		}
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 || balances.giftCards["GIFT"] != 2000 || len(payments.refunds) != 0 {
+			t.Errorf("expected nothing to be held, spent or refunded")
+		}
+	})
+
+	t.Run("should refund the charge when the order cannot be stored", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{failCreate: true}, &mockPromotionStore{}
+		payments := &mockPaymentProvider{}
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, newMockInventoryStore(), newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, payments, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}]}`)
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusInternalServerError, rr.Code, rr.Body)
+		}
+		if r := payments.refunds; len(r) != 1 || r[0].Amount != types.NewMoney(1000, "EUR") || r[0].Reference != payments.charges[0].Reference+":refund" {
+			t.Errorf("expected the 10.00 charged to be refunded, got %+v", r)
+		}
+	})
+
+	t.Run("should give everything back when a gift card cannot be used", func(t *testing.T) {
+		orders := &mockOrderStore{}
+		promotions := &mockPromotionStore{promotions: []*types.Promotion{
+			{ID: 1, Name: "Sale", Action: types.PromotionAction{Type: types.ActionPercentage, Percent: 10}},
+		}}
+		inventory, balances := newMockInventoryStore(), newMockBalanceStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, balances, methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}], "giftCards": ["NOPE"], "useStoreCredit": true}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body)
+		}
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 || promotions.redemptions[0] != nil || balances.credit[1] != 1500 {
+			t.Errorf("expected nothing to be held, redeemed or spent")
+		}
+	})
+
+	t.Run("should place orders without a request, at the subscription discount", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory := newMockInventoryStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+		handler := NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore)
+
+		// 2 x 10.00 at 15% off leaves 17.00 to pay.
+		var charged types.Money
+		payload := types.CheckoutPayload{Items: []types.OrderLine{{SKU: "IN", Quantity: 2}}, SubscriptionDiscount: "15"}
+		o, err := handler.PlaceOrder(1, "EUR", payload, func(o types.Order) error {
+			charged = o.AmountDue
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Discount != types.NewMoney(300, "EUR") || o.Total != types.NewMoney(1700, "EUR") || charged != types.NewMoney(1700, "EUR") {
+			t.Errorf("expected 3.00 off and 17.00 charged, got %v off and %v charged", o.Discount, charged)
+		}
+		if inventory.available["IN"] != 3 {
+			t.Errorf("expected 3 units left, got %d", inventory.available["IN"])
+		}
+	})
+
+	t.Run("should place an order under a reference only once", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory := newMockInventoryStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+		handler := NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore)
+
+		payload := types.CheckoutPayload{Items: []types.OrderLine{{SKU: "IN", Quantity: 2}}, Reference: "subscription:1:renewal:1:attempt:1"}
+		paid := 0
+		pay := func(o types.Order) error {
+			paid++
+			return nil
+		}
+		first, err := handler.PlaceOrder(1, "EUR", payload, pay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := handler.PlaceOrder(1, "EUR", payload, pay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.ID != first.ID || len(orders.orders) != 1 || paid != 1 || inventory.available["IN"] != 3 {
+			t.Errorf("expected the first order back without placing or paying it again, got order %d of %d, paid %d times", again.ID, len(orders.orders), paid)
+		}
+	})
+
+	t.Run("should give everything back when the payment fails", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory, balances := newMockInventoryStore(), newMockBalanceStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+		handler := NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, balances, methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore)
+
+		payload := types.CheckoutPayload{Items: []types.OrderLine{{SKU: "IN", Quantity: 2}}, UseStoreCredit: true}
+		_, err := handler.PlaceOrder(1, "EUR", payload, func(o types.Order) error { return types.ErrPaymentDeclined })
+		if !errors.Is(err, types.ErrPaymentDeclined) {
+			t.Errorf("expected the payment to be declined, got %v", err)
+		}
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 || balances.credit[1] != 1500 {
+			t.Errorf("expected nothing to be held or spent")
+		}
+	})
+
+	t.Run("should store the tax on the order and its items", func(t *testing.T) {
+		orders := &mockOrderStore{}
+		promotions := &mockPromotionStore{promotions: []*types.Promotion{
+			{ID: 1, Name: "Sale", Action: types.PromotionAction{Type: types.ActionPercentage, Percent: 10}},
+		}}
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, newMockInventoryStore(), newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{percent: 21}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		// 2 × 10.00 minus 10% is 18.00, plus 3.78 of tax.
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 2}], "address": {"country": "nl", "postalCode": "1011 AB"}, "vatId": "nl 123456789b01"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		o := orders.orders[0]
+		if o.Tax != types.NewMoney(378, "EUR") || o.Total != types.NewMoney(2178, "EUR") || o.AmountDue != o.Total {
+			t.Errorf("expected 3.78 of tax in a total of 21.78, got %v in %v", o.Tax, o.Total)
+		}
+		if o.Items[0].TaxClass != types.TaxClassStandard || o.Items[0].TaxRate != "21" || o.Items[0].Tax != types.NewMoney(378, "EUR") || o.Items[0].Total != o.Total {
+			t.Errorf("expected the tax on the item, got %+v", o.Items[0])
+		}
+		if o.Address == nil || o.Address.Country != "NL" || o.VATID != "NL123456789B01" {
+			t.Errorf("expected the address and VAT ID of the customer, got %+v and %q", o.Address, o.VATID)
+		}
+	})
+
+	t.Run("should add the shipping method chosen to the order, and tax it", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, newMockInventoryStore(), newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{percent: 21}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		// 2 × 10.00 plus 4.20 of tax, and 4.95 of shipping plus 1.03 of tax.
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 2}], "address": {"country": "NL"}, "shippingMethodId": 1}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		o := orders.orders[0]
+		if o.ShippingMethodID == nil || *o.ShippingMethodID != 1 || o.ShippingMethod != "Standard" || o.Shipping != types.NewMoney(495, "EUR") {
+			t.Errorf("expected standard shipping of 4.95, got %+v", o)
+		}
+		if o.ShippingTax != types.NewMoney(103, "EUR") || o.Tax != types.NewMoney(523, "EUR") || o.Total != types.NewMoney(3018, "EUR") {
+			t.Errorf("expected 5.23 of tax in a total of 30.18, got %v in %v", o.Tax, o.Total)
+		}
+		if o.ShippingTaxRate != "21" {
+			t.Errorf("expected shipping taxed at 21%%, got %q", o.ShippingTaxRate)
+		}
+		if o.Items[0].Tax != types.NewMoney(420, "EUR") {
+			t.Errorf("expected 4.20 of tax on the item, got %v", o.Items[0].Tax)
+		}
+	})
+
+	t.Run("should refuse shipping methods that cannot ship the cart", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory := newMockInventoryStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		for _, body := range []string{
+			`{"items": [{"sku": "IN", "quantity": 1}], "address": {"country": "NL"}, "shippingMethodId": 2}`,
+			`{"items": [{"sku": "IN", "quantity": 1}], "address": {"country": "US"}, "shippingMethodId": 1}`,
+			`{"items": [{"sku": "IN", "quantity": 1}], "shippingMethodId": 1}`,
+		} {
+			rr := postCheckout(t, router, body)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d: %s", http.StatusBadRequest, body, rr.Code, rr.Body)
+			}
+		}
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 {
+			t.Errorf("expected nothing to be held")
+		}
+	})
+
+	t.Run("should reject invalid VAT IDs", func(t *testing.T) {
+		orders, promotions := &mockOrderStore{}, &mockPromotionStore{}
+		inventory := newMockInventoryStore()
+		carts := NewCartPricer(products, variants, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+		router := mux.NewRouter()
+		NewHandler(orders, inventory, newMockPolicyStore(), &mockOrderRouter{}, carts, promotions, newMockBalanceStore(), methods, &mockPaymentProvider{}, &mockTaxCalculator{}, shipping, pricer, userStore).RegisterRoutes(router)
+
+		rr := postCheckout(t, router, `{"paymentMethodId": 1, "items": [{"sku": "IN", "quantity": 1}], "address": {"country": "DE"}, "vatId": "INVALID"}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body)
+		}
+		if len(orders.orders) != 0 || inventory.available["IN"] != 5 {
+			t.Errorf("expected nothing to be held")
+		}
+	})
+}
+
+// TestPriceCart tests pricing carts for guests and customers.
+func TestPriceCart(t *testing.T) {
+	promotions := &mockPromotionStore{promotions: []*types.Promotion{
+		{ID: 1, Name: "Welcome", Code: "WELCOME", Conditions: types.PromotionConditions{FirstOrder: true},
+			Action: types.PromotionAction{Type: types.ActionFixedAmount, Amount: &types.Money{Amount: 500, Currency: "EUR"}}},
+	}}
+	orders := &mockOrderStore{}
+	orders.CreateOrder(types.Order{UserID: 1, Status: types.OrderPending})
+	products := &mockProductStore{products: map[string]*types.Product{"IN": {ID: 1, SKU: "IN", Price: types.NewMoney(1000, "EUR")}}}
+	pricer := &mockPricer{}
+	carts := NewCartPricer(products, &mockVariantStore{}, pricer, promotion.NewEngine(promotions, orders, pricer))
+
+	router := mux.NewRouter()
+	NewHandler(orders, nil, nil, nil, carts, promotions, nil, nil, nil, &mockTaxCalculator{}, &mockShippingQuoter{}, pricer, &mockUserStore{}).RegisterRoutes(router)
+
+	send := func(token string) types.CartPricing {
+		req, _ := http.NewRequest(http.MethodPost, "/cart/price", bytes.NewBufferString(`{"items": [{"sku": "IN", "quantity": 3}], "codes": ["welcome"]}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var pricing types.CartPricing
+		json.NewDecoder(rr.Body).Decode(&pricing)
+		return pricing
+	}
+
+	// Guests are treated as first time customers.
+	if pricing := send(""); pricing.Total != types.NewMoney(2500, "EUR") || len(pricing.Promotions) != 1 {
+		t.Errorf("expected the welcome discount for guests, got %+v", pricing)
+	}
+
+	// User 1 has ordered before.
+	token, _ := auth.CreateJWT([]byte(config.Envs.JWTSecret), 1)
+	pricing := send(token)
+	if pricing.Total != types.NewMoney(3000, "EUR") || len(pricing.RejectedCodes) != 1 || pricing.RejectedCodes[0].Code != "WELCOME" {
+		t.Errorf("expected the code to be rejected for a returning customer, got %+v", pricing)
+	}
+	if len(promotions.redemptions) != 0 {
+		t.Errorf("expected pricing not to redeem anything")
+	}
+	// A failing variant store fails pricing, rather than pricing the SKU as a product.
+	failing := NewCartPricer(products, &mockVariantStore{fail: fmt.Errorf("connection refused")}, pricer, promotion.NewEngine(promotions, orders, pricer))
+	if _, err := failing.PriceCart(1, "EUR", []types.OrderLine{{SKU: "IN", Quantity: 1}}, nil); err == nil || errors.Is(err, types.ErrUnknownSKU) {
+		t.Errorf("expected the store error, got %v", err)
+	}
+}
+
+// TestShippingOptions tests listing the shipping options of carts given in the query.
+func TestShippingOptions(t *testing.T) {
+	promotions := &mockPromotionStore{promotions: []*types.Promotion{
+		{ID: 1, Name: "Free shipping", Action: types.PromotionAction{Type: types.ActionFreeShipping}},
+	}}
+	products := &mockProductStore{products: map[string]*types.Product{"IN": {ID: 1, SKU: "IN", Price: types.NewMoney(1000, "EUR"), Weight: 250}}}
+	pricer := &mockPricer{}
+	carts := NewCartPricer(products, &mockVariantStore{}, pricer, promotion.NewEngine(promotions, &mockOrderStore{}, pricer))
+	shipping := &mockShippingQuoter{options: []types.ShippingOption{
+		{MethodID: 1, Name: "Standard", Price: types.NewMoney(495, "EUR"), MinDays: 1, MaxDays: 2},
+	}}
+
+	router := mux.NewRouter()
+	NewHandler(&mockOrderStore{}, nil, nil, nil, carts, promotions, nil, nil, nil, &mockTaxCalculator{}, shipping, pricer, &mockUserStore{}).RegisterRoutes(router)
+
+	send := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/cart/shipping-options?"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("items=IN:3&country=nl&postalCode=1011+AB")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+	var options []types.ShippingOption
+	json.NewDecoder(rr.Body).Decode(&options)
+	if len(options) != 1 || options[0].MethodID != 1 || options[0].Price != types.NewMoney(495, "EUR") {
+		t.Errorf("expected standard shipping, got %+v", options)
+	}
+
+	// The quoter gets the cart priced with its promotions, and the address.
+	if p := shipping.pricing; p == nil || p.Total != types.NewMoney(3000, "EUR") || !p.FreeShipping || p.Lines[0].Weight != 750 {
+		t.Errorf("expected the priced cart of 750 grams with free shipping, got %+v", p)
+	}
+	if shipping.address.Country != "nl" || shipping.address.PostalCode != "1011 AB" {
+		t.Errorf("expected the address of the query, got %+v", shipping.address)
+	}
+
+	for _, query := range []string{"items=IN:3", "items=IN&country=NL", "items=IN:0&country=NL", "items=IN:2000000000&country=NL", "country=NL", "items=NOPE:1&country=NL"} {
+		if rr := send(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for %s, got %d: %s", http.StatusBadRequest, query, rr.Code, rr.Body)
+		}
+	}
+}
+
+// postCheckout sends a checkout request as user 1 through the router and returns the recorded response.
+func postCheckout(t *testing.T, router *mux.Router, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, "/cart/checkout", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an implementation of the parts of the UserStore interface used by the auth middleware.
+// Every user exists and is a customer.
+type mockUserStore struct {
+	types.UserStore
+}
+
+// GetUserByID is a mock method that returns a customer with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	return &types.User{ID: id, Role: types.RoleCustomer}, nil
+}
+
+// mockOrderStore is an in-memory implementation of the OrderStore interface.
+type mockOrderStore struct {
+	orders     []*types.Order // Orders, where the ID is the index plus one.
+	items      int            // Number of items created, used to assign item IDs.
+	failCreate bool           // Whether storing orders fails.
+}
+
+// CreateOrder is a mock method that appends the order, assigning IDs to it and its items, unless asked to fail.
+func (m *mockOrderStore) CreateOrder(o types.Order) (int, error) {
+	if m.failCreate {
+		return 0, fmt.Errorf("orders cannot be stored")
+	}
+	o.ID = len(m.orders) + 1
+	items := []types.OrderItem{}
+	for _, item := range o.Items {
+		m.items++
+		item.ID, item.OrderID = m.items, o.ID
+		items = append(items, item)
+	}
+	o.Items = items
+	m.orders = append(m.orders, &o)
+	return o.ID, nil
+}
+
+// GetOrderByID is a mock method that returns an order by ID.
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	if id < 1 || id > len(m.orders) {
+		return nil, fmt.Errorf("order not found")
+	}
+	return m.orders[id-1], nil
+}
+
+// GetOrderByReference is a mock method that returns the order placed under a reference, or nil.
+func (m *mockOrderStore) GetOrderByReference(reference string) (*types.Order, error) {
+	for _, o := range m.orders {
+		if o.Reference == reference {
+			return o, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetWaitingItems is a mock method that returns the unallocated items of a SKU, oldest first.
+func (m *mockOrderStore) GetWaitingItems(sku string) ([]*types.OrderItem, error) {
+	items := []*types.OrderItem{}
+	for _, o := range m.orders {
+		for i := range o.Items {
+			if o.Items[i].SKU == sku && o.Items[i].ReservationID == nil && o.Status != types.OrderCancelled {
+				items = append(items, &o.Items[i])
+			}
+		}
+	}
+	return items, nil
+}
+
+// AllocateItem is a mock method that sets the reservation and warehouse of an item.
+func (m *mockOrderStore) AllocateItem(itemID, reservationID, warehouseID int) error {
+	for _, o := range m.orders {
+		for i := range o.Items {
+			if o.Items[i].ID == itemID {
+				o.Items[i].ReservationID = &reservationID
+				if warehouseID != 0 {
+					o.Items[i].WarehouseID = &warehouseID
+				}
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("order item %d not found", itemID)
+}
+
+// RequeueItem is a mock method that clears the reservation and warehouse of the item holding a reservation.
+func (m *mockOrderStore) RequeueItem(reservationID int) error {
+	for _, o := range m.orders {
+		for i := range o.Items {
+			if id := o.Items[i].ReservationID; id != nil && *id == reservationID {
+				o.Items[i].ReservationID, o.Items[i].WarehouseID = nil, nil
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no order item holds reservation %d", reservationID)
+}
+
+// UpdateOrderStatus is a mock method that changes the status of an order.
+func (m *mockOrderStore) UpdateOrderStatus(id int, status string) error {
+	m.orders[id-1].Status = status
+	return nil
+}
+
+// CountOrders is a mock method that counts the orders of a user that were not cancelled.
+func (m *mockOrderStore) CountOrders(userID int) (int, error) {
+	n := 0
+	for _, o := range m.orders {
+		if o.UserID == userID && o.Status != types.OrderCancelled {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// GetSalesSince is a mock method that sums the items of each SKU in orders that were not cancelled, ignoring the time.
+func (m *mockOrderStore) GetSalesSince(since time.Time) (map[string]int, error) {
+	sales := map[string]int{}
+	for _, o := range m.orders {
+		if o.Status == types.OrderCancelled {
+			continue
+		}
+		for _, item := range o.Items {
+			sales[item.SKU] += item.Quantity
+		}
+	}
+	return sales, nil
+}
+
+// ListOrders is a mock method that returns all orders of a user, newest first, on a single page.
+func (m *mockOrderStore) ListOrders(filter types.OrderFilter) ([]*types.Order, string, error) {
+	orders := []*types.Order{}
+	for i := len(m.orders) - 1; i >= 0; i-- {
+		if m.orders[i].UserID == filter.UserID {
+			orders = append(orders, m.orders[i])
+		}
+	}
+	return orders, "", nil
+}
+
+// mockInventoryStore is an implementation of the parts of the InventoryStore interface used for orders.
+type mockInventoryStore struct {
+	types.InventoryStore
+	available    map[string]int       // Quantity available to sell by SKU.
+	reservations []*types.Reservation // Reservations, where the ID is the index plus one.
+	sales        map[int]int          // Warehouse committed reservations were sold from, by reservation ID.
+	short        int                  // ID of a warehouse without the stock of any reservation, 0 for none.
+}
+
+// newMockInventoryStore returns a mockInventoryStore with 5 units of IN and 10 of PRE available.
+func newMockInventoryStore() *mockInventoryStore {
+	return &mockInventoryStore{available: map[string]int{"IN": 5, "PRE": 10}}
+}
+
+// CreateReservation is a mock method that holds stock if enough is available.
+func (m *mockInventoryStore) CreateReservation(r types.Reservation) (int, error) {
+	if m.available[r.SKU] < r.Quantity {
+		return 0, types.ErrInsufficientStock
+	}
+	m.available[r.SKU] -= r.Quantity
+	r.Status = types.ReservationActive
+	m.reservations = append(m.reservations, &r)
+	return len(m.reservations), nil
+}
+
+// ReleaseReservation is a mock method that gives the stock of an active reservation back.
+func (m *mockInventoryStore) ReleaseReservation(id int) error {
+	r := m.reservations[id-1]
+	if r.Status != types.ReservationActive {
+		return fmt.Errorf("reservation %d is not active", id)
+	}
+	r.Status = types.ReservationReleased
+	m.available[r.SKU] += r.Quantity
+	return nil
+}
+
+// CommitReservation is a mock method that records the sale of an unexpired reservation from a warehouse.
+func (m *mockInventoryStore) CommitReservation(id, warehouseID int) error {
+	r := m.reservations[id-1]
+	if r.Status != types.ReservationActive || !r.ExpiresAt.After(time.Now()) {
+		return types.ErrReservationExpired
+	}
+	if warehouseID == m.short {
+		return types.ErrInsufficientStock
+	}
+
+	r.Status = types.ReservationCommitted
+	if m.sales == nil {
+		m.sales = map[int]int{}
+	}
+	m.sales[id] = warehouseID
+	return nil
+}
+
+// ExpireReservations is a mock method that gives the stock of the active reservations expired before the given
+// time back, as the sweeper does.
+func (m *mockInventoryStore) ExpireReservations(before time.Time) (int, error) {
+	n := 0
+	for _, r := range m.reservations {
+		if r.Status == types.ReservationActive && !r.ExpiresAt.After(before) {
+			r.Status = types.ReservationExpired
+			m.available[r.SKU] += r.Quantity
+			n++
+		}
+	}
+	return n, nil
+}
+
+// mockOrderRouter is an implementation of the OrderRouter interface that ships everything from warehouse 1, or
+// from warehouse 2 when the destination is known.
+type mockOrderRouter struct {
+	routed [][]types.OrderLine // Lines routed, by call.
+}
+
+// Route is a mock method that records the lines and returns the same warehouse for each of them.
+func (m *mockOrderRouter) Route(destination *types.Location, lines []types.OrderLine) ([]int, error) {
+	m.routed = append(m.routed, lines)
+	id := 1
+	if destination != nil {
+		id = 2
+	}
+
+	warehouses := []int{}
+	for range lines {
+		warehouses = append(warehouses, id)
+	}
+	return warehouses, nil
+}
+
+// mockPolicyStore is an in-memory implementation of the BackorderPolicyStore interface.
+type mockPolicyStore struct {
+	policies map[string]*types.BackorderPolicy // Policies by SKU.
+}
+
+// newMockPolicyStore returns a mockPolicyStore with a backorder policy for BACK, limited to 3 units, and a preorder
+// policy for PRE.
+func newMockPolicyStore() *mockPolicyStore {
+	return &mockPolicyStore{policies: map[string]*types.BackorderPolicy{
+		"BACK": {SKU: "BACK", Mode: types.PolicyBackorder, Limit: 3},
+		"PRE":  {SKU: "PRE", Mode: types.PolicyPreorder},
+	}}
+}
+
+// GetBackorderPolicy is a mock method that returns the policy of a SKU, or nil.
+func (m *mockPolicyStore) GetBackorderPolicy(sku string) (*types.BackorderPolicy, error) {
+	return m.policies[sku], nil
+}
+
+// SaveBackorderPolicy is a mock method that stores the policy.
+func (m *mockPolicyStore) SaveBackorderPolicy(p types.BackorderPolicy) error {
+	m.policies[p.SKU] = &p
+	return nil
+}
+
+// DeleteBackorderPolicy is a mock method that removes the policy of a SKU.
+func (m *mockPolicyStore) DeleteBackorderPolicy(sku string) error {
+	delete(m.policies, sku)
+	return nil
+}
+
+// AcceptBackorder is a mock method that adds to the waiting quantity within the limit.
+func (m *mockPolicyStore) AcceptBackorder(sku string, quantity int) error {
+	p := m.policies[sku]
+	if p == nil || (p.Limit > 0 && p.Waiting+quantity > p.Limit) {
+		return types.ErrBackorderLimit
+	}
+	p.Waiting += quantity
+	return nil
+}
+
+// ReleaseBackorder is a mock method that subtracts from the waiting quantity.
+func (m *mockPolicyStore) ReleaseBackorder(sku string, quantity int) error {
+	if p := m.policies[sku]; p != nil {
+		p.Waiting = max(p.Waiting-quantity, 0)
+	}
+	return nil
+}
+
+// mockProductStore is an implementation of the parts of the ProductStore interface used to price carts.
+type mockProductStore struct {
+	types.ProductStore
+	products map[string]*types.Product // Products by SKU.
+}
+
+// GetProductBySKU is a mock method that returns a copy of the product with the given SKU.
+func (m *mockProductStore) GetProductBySKU(sku string) (*types.Product, error) {
+	p, ok := m.products[sku]
+	if !ok {
+		return nil, types.ErrProductNotFound
+	}
+
+	c := *p
+	return &c, nil
+}
+
+// GetProductByID is a mock method that returns a copy of the product with the given ID.
+func (m *mockProductStore) GetProductByID(id int) (*types.Product, error) {
+	for _, p := range m.products {
+		if p.ID == id {
+			c := *p
+			return &c, nil
+		}
+	}
+	return nil, types.ErrProductNotFound
+}
+
+// mockVariantStore is an implementation of the parts of the VariantStore interface used to price carts.
+type mockVariantStore struct {
+	types.VariantStore
+	variants map[string]*types.ProductVariant // Variants by SKU.
+	fail     error                            // Error returned for every SKU, if set.
+}
+
+// GetVariantBySKU is a mock method that returns a copy of the variant with the given SKU, or fails if asked.
+func (m *mockVariantStore) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	if m.fail != nil {
+		return nil, m.fail
+	}
+	v, ok := m.variants[sku]
+	if !ok {
+		return nil, types.ErrVariantNotFound
+	}
+
+	c := *v
+	return &c, nil
+}
+
+// mockPricer is an implementation of the Pricer interface that only knows euros, the base currency.
+type mockPricer struct {
+	types.Pricer
+}
+
+// SelectCurrency is a mock method that selects euros, the only currency.
+func (m *mockPricer) SelectCurrency(requested string, user *types.User) (string, error) {
+	if requested != "" && requested != "EUR" {
+		return "", types.ErrUnsupportedCurrency
+	}
+	return "EUR", nil
+}
+
+// PriceProducts is a mock method that leaves the prices in euros.
+func (m *mockPricer) PriceProducts(products []*types.Product, currency string) error {
+	return nil
+}
+
+// PriceVariants is a mock method that leaves the prices in euros.
+func (m *mockPricer) PriceVariants(variants []*types.ProductVariant, currency string) error {
+	return nil
+}
+
+// Convert is a mock method that returns amounts in euros unchanged.
+func (m *mockPricer) Convert(money types.Money, currency string) (types.Money, error) {
+	if money.Currency != currency {
+		return types.Money{}, types.ErrUnsupportedCurrency
+	}
+	return money, nil
+}
+
+// redemption struct is a redemption recorded by mockPromotionStore.
+type redemption struct {
+	promotionID int
+	userID      int
+	orderID     int
+}
+
+// mockPromotionStore is an in-memory implementation of the parts of the PromotionStore interface used to price
+// carts and redeem promotions.
+type mockPromotionStore struct {
+	types.PromotionStore
+	promotions  []*types.Promotion // The promotions.
+	redemptions []*redemption      // Redemptions, where the ID is the index plus one; released ones are nil.
+	raceUserID  int                // If set, a redemption by this user is recorded just before the next redeem.
+}
+
+// GetPromotionByCode is a mock method that returns the promotion with the code.
+func (m *mockPromotionStore) GetPromotionByCode(code string) (*types.Promotion, error) {
+	for _, p := range m.promotions {
+		if p.Code == code {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("promotion not found")
+}
+
+// GetAutomaticPromotions is a mock method that returns the promotions without a code.
+func (m *mockPromotionStore) GetAutomaticPromotions(at time.Time) ([]*types.Promotion, error) {
+	promotions := []*types.Promotion{}
+	for _, p := range m.promotions {
+		if p.Code == "" {
+			promotions = append(promotions, p)
+		}
+	}
+	return promotions, nil
+}
+
+// CountRedemptions is a mock method that counts the redemptions of a promotion, in total and by the user.
+func (m *mockPromotionStore) CountRedemptions(promotionID, userID int) (total, byUser int, err error) {
+	for _, r := range m.redemptions {
+		if r != nil && r.promotionID == promotionID {
+			total++
+			if r.userID == userID {
+				byUser++
+			}
+		}
+	}
+	return total, byUser, nil
+}
+
+// RedeemPromotions is a mock method that records redemptions unless a promotion is at its usage limit.
+func (m *mockPromotionStore) RedeemPromotions(userID int, applied []types.AppliedPromotion) ([]int, error) {
+	if m.raceUserID != 0 {
+		for _, a := range applied {
+			m.redemptions = append(m.redemptions, &redemption{promotionID: a.PromotionID, userID: m.raceUserID})
+		}
+		m.raceUserID = 0
+	}
+
+	for _, a := range applied {
+		p, _ := m.GetPromotionByCode(a.Code)
+		total, _, _ := m.CountRedemptions(a.PromotionID, userID)
+		if p != nil && p.UsageLimit > 0 && total >= p.UsageLimit {
+			return nil, types.ErrPromotionLimit
+		}
+	}
+
+	ids := []int{}
+	for _, a := range applied {
+		m.redemptions = append(m.redemptions, &redemption{promotionID: a.PromotionID, userID: userID})
+		ids = append(ids, len(m.redemptions))
+	}
+	return ids, nil
+}
+
+// SetRedemptionOrder is a mock method that links redemptions to an order.
+func (m *mockPromotionStore) SetRedemptionOrder(redemptionIDs []int, orderID int) error {
+	for _, id := range redemptionIDs {
+		m.redemptions[id-1].orderID = orderID
+	}
+	return nil
+}
+
+// ReleaseRedemptions is a mock method that removes redemptions.
+func (m *mockPromotionStore) ReleaseRedemptions(redemptionIDs []int) error {
+	for _, id := range redemptionIDs {
+		m.redemptions[id-1] = nil
+	}
+	return nil
+}
+
+// mockPaymentMethodStore is an in-memory implementation of the parts of the PaymentMethodStore interface used
+// by checkout.
+type mockPaymentMethodStore struct {
+	types.PaymentMethodStore
+	methods map[int]*types.PaymentMethod // Payment methods by ID.
+}
+
+// GetPaymentMethodByID is a mock method that returns the payment method with the given ID.
+func (m *mockPaymentMethodStore) GetPaymentMethodByID(id int) (*types.PaymentMethod, error) {
+	method, ok := m.methods[id]
+	if !ok {
+		return nil, fmt.Errorf("payment method not found")
+	}
+	return method, nil
+}
+
+// mockPaymentProvider is an implementation of the PaymentProvider interface that records charges and refunds.
+type mockPaymentProvider struct {
+	charges []types.PaymentCharge // Charges made.
+	refunds []types.PaymentRefund // Refunds made.
+	decline bool                  // Whether to decline charges.
+}
+
+// Charge is a mock method that records a charge, or declines it if asked.
+func (m *mockPaymentProvider) Charge(c types.PaymentCharge) (string, error) {
+	if m.decline {
+		return "", types.ErrPaymentDeclined
+	}
+	m.charges = append(m.charges, c)
+	return fmt.Sprintf("ch_%d", len(m.charges)), nil
+}
+
+// Refund is a mock method that records a refund.
+func (m *mockPaymentProvider) Refund(r types.PaymentRefund) (string, error) {
+	m.refunds = append(m.refunds, r)
+	return fmt.Sprintf("re_%d", len(m.refunds)), nil
+}
+
+// mockBalanceStore is an in-memory implementation of the parts of the BalanceStore interface used at checkout.
+type mockBalanceStore struct {
+	types.BalanceStore
+	giftCards map[string]int64 // Balances of gift cards in euro cents, by code.
+	credit    map[int]int64    // Store credit in euro cents, by user ID.
+	orders    map[int]int      // IDs of the orders of entries, by entry ID.
+	entries   int              // Number of entries made, used to assign entry IDs.
+	reverse   map[int]func()   // Functions giving back what entries took, by entry ID.
+}
+
+// newMockBalanceStore returns a mockBalanceStore with a gift card GIFT of 20.00 euros, and 15.00 euros of store
+// credit for user 1.
+func newMockBalanceStore() *mockBalanceStore {
+	return &mockBalanceStore{giftCards: map[string]int64{"GIFT": 2000}, credit: map[int]int64{1: 1500}}
+}
+
+// ChargeBalances is a mock method that takes what it can of the amount from the gift cards and store credit.
+// Unknown codes are rejected before anything is charged.
+func (m *mockBalanceStore) ChargeBalances(userID int, due types.Money, codes []string, storeCredit bool) ([]types.BalanceCharge, []int, error) {
+	for _, code := range codes {
+		if _, ok := m.giftCards[strings.ToUpper(code)]; !ok {
+			return nil, nil, types.ErrInvalidGiftCard
+		}
+	}
+
+	charges := []types.BalanceCharge{}
+	ids := []int{}
+	remaining := due.Amount
+	take := func(balance *int64, giftCardID int, giveBack func(amount int64)) {
+		amount := min(*balance, remaining)
+		if amount > 0 {
+			*balance -= amount
+			remaining -= amount
+			m.entries++
+			charges = append(charges, types.BalanceCharge{GiftCardID: giftCardID, Amount: types.NewMoney(amount, due.Currency)})
+			ids = append(ids, m.entries)
+			if m.reverse == nil {
+				m.reverse = map[int]func(){}
+			}
+			m.reverse[m.entries] = func() { giveBack(amount) }
+		}
+	}
+
+	for i, code := range codes {
+		code = strings.ToUpper(code)
+		balance := m.giftCards[code]
+		take(&balance, i+1, func(amount int64) { m.giftCards[code] += amount })
+		m.giftCards[code] = balance
+	}
+	if storeCredit {
+		balance := m.credit[userID]
+		take(&balance, 0, func(amount int64) { m.credit[userID] += amount })
+		m.credit[userID] = balance
+	}
+
+	return charges, ids, nil
+}
+
+// SetEntriesOrder is a mock method that links entries to an order.
+func (m *mockBalanceStore) SetEntriesOrder(entryIDs []int, orderID int) error {
+	if m.orders == nil {
+		m.orders = map[int]int{}
+	}
+	for _, id := range entryIDs {
+		m.orders[id] = orderID
+	}
+	return nil
+}
+
+// ReverseEntries is a mock method that gives back what entries took.
+func (m *mockBalanceStore) ReverseEntries(entryIDs []int) error {
+	for _, id := range entryIDs {
+		m.reverse[id]()
+	}
+	return nil
+}
+
+// mockTaxCalculator is an implementation of the TaxCalculator interface that adds a single rate to prices.
+// The VAT ID "INVALID" is rejected.
+type mockTaxCalculator struct {
+	percent int64 // The rate, as a whole percentage.
+}
+
+// CalculateTax is a mock method that adds the rate to each line, rounding down.
+func (m *mockTaxCalculator) CalculateTax(req types.TaxRequest) (*types.TaxResult, error) {
+	if req.VATID == "INVALID" {
+		return nil, types.ErrInvalidVATID
+	}
+
+	zero := types.NewMoney(0, req.Currency)
+	result := &types.TaxResult{Lines: []types.LineTax{}, Net: zero, Tax: zero, Gross: zero}
+	for _, line := range req.Lines {
+		tax := types.NewMoney(line.Amount.Amount*m.percent/100, req.Currency)
+		gross := types.NewMoney(line.Amount.Amount+tax.Amount, req.Currency)
+		result.Lines = append(result.Lines, types.LineTax{
+			SKU:      line.SKU,
+			TaxClass: types.TaxClassStandard,
+			Rate:     fmt.Sprint(m.percent),
+			Net:      line.Amount,
+			Tax:      tax,
+			Gross:    gross,
+		})
+		result.Net.Amount += line.Amount.Amount
+		result.Tax.Amount += tax.Amount
+		result.Gross.Amount += gross.Amount
+	}
+
+	return result, nil
+}
+
+// mockShippingQuoter is an implementation of the ShippingQuoter interface that offers the same options to the
+// Netherlands, and none elsewhere. It records the last cart it quoted.
+type mockShippingQuoter struct {
+	options []types.ShippingOption // The options offered.
+	address types.Address          // The address of the last cart quoted.
+	pricing *types.CartPricing     // The last cart quoted.
+}
+
+// ShippingOptions is a mock method that returns the options for addresses in the Netherlands.
+func (m *mockShippingQuoter) ShippingOptions(address types.Address, pricing *types.CartPricing) ([]types.ShippingOption, error) {
+	m.address, m.pricing = address, pricing
+	if !strings.EqualFold(address.Country, "NL") {
+		return []types.ShippingOption{}, nil
+	}
+	return m.options, nil
+}
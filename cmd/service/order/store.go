@@ -0,0 +1,473 @@
+package order
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	// Import the base64 and json packages for encoding pagination cursors.
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	// Import the types package for the order types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// itemColumns lists the columns of the order_items table, aliased oi, and the currency of the order, aliased o,
+// in the order scanRowIntoItem reads them.
+const itemColumns = "oi.id, oi.orderId, oi.sku, oi.quantity, oi.unitPrice, oi.discount, oi.taxClass, oi.taxRate, oi.tax, oi.total, oi.availability, oi.reservationId, oi.warehouseId, oi.expectedAt, o.currency"
+
+// orderColumns lists the columns of the orders table, in the order scanRowIntoOrder reads them.
+const orderColumns = `id, userId, reference, status, currency, subtotal, discount, total, freeShipping,
+	country, state, postalCode, latitude, longitude, vatId, tax, pricesIncludeTax, reverseCharge, shippingMethodId, shippingMethod, shipping, shippingTax,
+	shippingTaxRate, giftCardAmount, storeCreditAmount, amountDue, createdAt`
+
+// Default and maximum number of orders returned by ListOrders.
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// Store struct represents the data store of the orders.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateOrder is a method on the Store struct that stores an order and its items in a single transaction,
+// and returns the ID of the order.
+func (s *Store) CreateOrder(o types.Order) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var country any
+	address := types.Address{}
+	if o.Address != nil {
+		address = *o.Address
+		country = address.Country
+	}
+
+	var latitude, longitude any
+	if o.Destination != nil {
+		latitude, longitude = o.Destination.Latitude, o.Destination.Longitude
+	}
+
+	shippingTaxRate := o.ShippingTaxRate
+	if shippingTaxRate == "" {
+		shippingTaxRate = "0"
+	}
+
+	var reference any
+	if o.Reference != "" {
+		reference = o.Reference
+	}
+
+	res, err := tx.Exec(
+		`INSERT INTO orders (userId, reference, status, currency, subtotal, discount, total, freeShipping,
+			country, state, postalCode, latitude, longitude, vatId, tax, pricesIncludeTax, reverseCharge, shippingMethodId, shippingMethod, shipping, shippingTax,
+			shippingTaxRate, giftCardAmount, storeCreditAmount, amountDue)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		o.UserID, reference, o.Status, o.Total.Currency, o.Subtotal.Decimal(), o.Discount.Decimal(), o.Total.Decimal(), o.FreeShipping,
+		country, address.State, address.PostalCode, latitude, longitude, o.VATID, o.Tax.Decimal(), o.PricesIncludeTax, o.ReverseCharge,
+		o.ShippingMethodID, o.ShippingMethod, o.Shipping.Decimal(), o.ShippingTax.Decimal(),
+		shippingTaxRate, o.GiftCardAmount.Decimal(), o.StoreCreditAmount.Decimal(), o.AmountDue.Decimal(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range o.Items {
+		taxRate := item.TaxRate
+		if taxRate == "" {
+			taxRate = "0"
+		}
+
+		_, err := tx.Exec(
+			`INSERT INTO order_items (orderId, sku, quantity, unitPrice, discount, taxClass, taxRate, tax, total, availability, reservationId, warehouseId, expectedAt)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, item.SKU, item.Quantity, item.UnitPrice.Decimal(), item.Discount.Decimal(), item.TaxClass, taxRate, item.Tax.Decimal(), item.Total.Decimal(),
+			item.Availability, item.ReservationID, item.WarehouseID, item.ExpectedAt,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int(id), tx.Commit()
+}
+
+// GetOrderByID is a method on the Store struct that retrieves an order, with its items, by ID.
+func (s *Store) GetOrderByID(id int) (*types.Order, error) {
+	orders, err := s.queryOrders("SELECT "+orderColumns+" FROM orders WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, types.ErrOrderNotFound
+	}
+
+	return orders[0], nil
+}
+
+// GetOrderByReference is a method on the Store struct that retrieves the order placed under a reference, with its
+// items, or nil if there is none.
+func (s *Store) GetOrderByReference(reference string) (*types.Order, error) {
+	orders, err := s.queryOrders("SELECT "+orderColumns+" FROM orders WHERE reference = ?", reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	return orders[0], nil
+}
+
+// orderCursor is the decoded form of the opaque pagination cursor returned by ListOrders.
+// It holds the ID of the last order on the previous page.
+type orderCursor struct {
+	ID int `json:"id"`
+}
+
+// ListOrders is a method on the Store struct that retrieves a page of the orders matching the filter, newest
+// first, with their items. It uses keyset pagination on the ID, so pages stay stable while orders are placed.
+// It returns the orders on the page and the cursor for the next page, which is empty on the last page.
+func (s *Store) ListOrders(filter types.OrderFilter) ([]*types.Order, string, error) {
+	// Clamp the page size.
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	where := []string{"userId = ?"}
+	args := []any{filter.UserID}
+	if len(filter.Statuses) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(filter.Statuses)-1)+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+
+	// Continue after the last order of the previous page, if a cursor was given.
+	if filter.Cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		c := new(orderCursor)
+		if err != nil || json.Unmarshal(b, c) != nil {
+			return nil, "", types.ErrInvalidCursor
+		}
+		where = append(where, "id < ?")
+		args = append(args, c.ID)
+	}
+
+	// Fetch one extra order to find out whether there is a next page.
+	args = append(args, limit+1)
+	orders, err := s.queryOrders("SELECT "+orderColumns+" FROM orders WHERE "+strings.Join(where, " AND ")+" ORDER BY id DESC LIMIT ?", args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// If there are no more orders than requested, this is the last page.
+	if len(orders) <= limit {
+		return orders, "", nil
+	}
+
+	// Otherwise drop the extra order and build the cursor from the last order on the page.
+	orders = orders[:limit]
+	b, err := json.Marshal(orderCursor{ID: orders[len(orders)-1].ID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return orders, base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// queryOrders runs a query selecting orderColumns and returns the orders it selects, in its order, with their items.
+func (s *Store) queryOrders(query string, args ...any) ([]*types.Order, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []*types.Order{}
+	byID := map[int]*types.Order{}
+	for rows.Next() {
+		o, err := scanRowIntoOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+		byID[o.ID] = o
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	// Load the items of all the orders at once.
+	ids := make([]any, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+	items, err := s.db.Query(
+		"SELECT "+itemColumns+" FROM order_items oi JOIN orders o ON o.id = oi.orderId WHERE oi.orderId IN (?"+strings.Repeat(", ?", len(ids)-1)+") ORDER BY oi.id",
+		ids...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer items.Close()
+
+	for items.Next() {
+		item, err := scanRowIntoItem(items)
+		if err != nil {
+			return nil, err
+		}
+		o := byID[item.OrderID]
+		o.Items = append(o.Items, *item)
+	}
+
+	return orders, items.Err()
+}
+
+// GetWaitingItems is a method on the Store struct that retrieves the items of a SKU waiting for stock,
+// in the order they were placed. Items of cancelled orders do not wait.
+func (s *Store) GetWaitingItems(sku string) ([]*types.OrderItem, error) {
+	rows, err := s.db.Query(`
+		SELECT `+itemColumns+`
+		FROM order_items oi JOIN orders o ON o.id = oi.orderId
+		WHERE oi.sku = ? AND oi.reservationId IS NULL AND o.status != ?
+		ORDER BY oi.id`,
+		sku, types.OrderCancelled,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*types.OrderItem{}
+	for rows.Next() {
+		item, err := scanRowIntoItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// AllocateItem is a method on the Store struct that records the reservation holding the stock of a waiting item,
+// and the warehouse it ships from. It fails if the item has already been allocated.
+func (s *Store) AllocateItem(itemID, reservationID, warehouseID int) error {
+	var warehouse any
+	if warehouseID != 0 {
+		warehouse = warehouseID
+	}
+
+	res, err := s.db.Exec(
+		"UPDATE order_items SET reservationId = ?, warehouseId = ? WHERE id = ? AND reservationId IS NULL",
+		reservationID, warehouse, itemID,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("order item %d is not waiting for stock", itemID)
+	}
+
+	return nil
+}
+
+// RequeueItem is a method on the Store struct that puts the item holding a reservation back to wait for stock, so
+// the next allocation of its SKU holds stock for it again. It fails if no item holds the reservation.
+func (s *Store) RequeueItem(reservationID int) error {
+	res, err := s.db.Exec("UPDATE order_items SET reservationId = NULL, warehouseId = NULL WHERE reservationId = ?", reservationID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no order item holds reservation %d", reservationID)
+	}
+
+	return nil
+}
+
+// UpdateOrderStatus is a method on the Store struct that changes the status of an order.
+func (s *Store) UpdateOrderStatus(id int, status string) error {
+	_, err := s.db.Exec("UPDATE orders SET status = ? WHERE id = ?", status, id)
+	return err
+}
+
+// CountOrders is a method on the Store struct that counts the orders a user placed that were not cancelled.
+func (s *Store) CountOrders(userID int) (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM orders WHERE userId = ? AND status != ?", userID, types.OrderCancelled).Scan(&n)
+	return n, err
+}
+
+// GetSalesSince is a method on the Store struct that returns the units of each SKU ordered since the given time,
+// in orders that were not cancelled.
+func (s *Store) GetSalesSince(since time.Time) (map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT oi.sku, SUM(oi.quantity)
+		FROM order_items oi JOIN orders o ON o.id = oi.orderId
+		WHERE o.createdAt >= ? AND o.status != ?
+		GROUP BY oi.sku`,
+		since, types.OrderCancelled,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sales := map[string]int{}
+	for rows.Next() {
+		var sku string
+		var units int
+		if err := rows.Scan(&sku, &units); err != nil {
+			return nil, err
+		}
+		sales[sku] = units
+	}
+
+	return sales, rows.Err()
+}
+
+// scanRowIntoOrder scans a row selected with orderColumns into an Order, without its items.
+func scanRowIntoOrder(rows *sql.Rows) (*types.Order, error) {
+	o := &types.Order{Items: []types.OrderItem{}}
+	var currency, subtotal, discount, total, tax, shipping, shippingTax, giftCardAmount, storeCreditAmount, amountDue string
+	var country, reference sql.NullString
+	var latitude, longitude sql.NullFloat64
+	var shippingMethodID sql.NullInt64
+	var address types.Address
+
+	err := rows.Scan(&o.ID, &o.UserID, &reference, &o.Status, &currency, &subtotal, &discount, &total, &o.FreeShipping,
+		&country, &address.State, &address.PostalCode, &latitude, &longitude, &o.VATID, &tax, &o.PricesIncludeTax, &o.ReverseCharge,
+		&shippingMethodID, &o.ShippingMethod, &shipping, &shippingTax,
+		&o.ShippingTaxRate, &giftCardAmount, &storeCreditAmount, &amountDue, &o.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	o.Reference = reference.String
+	if country.Valid {
+		address.Country = country.String
+		o.Address = &address
+	}
+	if latitude.Valid && longitude.Valid {
+		o.Destination = &types.Location{Latitude: latitude.Float64, Longitude: longitude.Float64}
+	}
+	if shippingMethodID.Valid {
+		methodID := int(shippingMethodID.Int64)
+		o.ShippingMethodID = &methodID
+	}
+	o.ShippingTaxRate = trimRate(o.ShippingTaxRate)
+	for _, amount := range []struct {
+		dest  *types.Money
+		value string
+	}{
+		{&o.Subtotal, subtotal},
+		{&o.Discount, discount},
+		{&o.Total, total},
+		{&o.Tax, tax},
+		{&o.Shipping, shipping},
+		{&o.ShippingTax, shippingTax},
+		{&o.GiftCardAmount, giftCardAmount},
+		{&o.StoreCreditAmount, storeCreditAmount},
+		{&o.AmountDue, amountDue},
+	} {
+		if *amount.dest, err = parseAmount(amount.value, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// scanRowIntoItem scans a row selected with itemColumns into an OrderItem, with prices in the currency of its order.
+func scanRowIntoItem(rows *sql.Rows) (*types.OrderItem, error) {
+	item := new(types.OrderItem)
+	var unitPrice, discount, tax, total, currency string
+	var reservationID, warehouseID sql.NullInt64
+	var expectedAt sql.NullTime
+
+	err := rows.Scan(&item.ID, &item.OrderID, &item.SKU, &item.Quantity, &unitPrice, &discount, &item.TaxClass, &item.TaxRate, &tax, &total,
+		&item.Availability, &reservationID, &warehouseID, &expectedAt, &currency)
+	if err != nil {
+		return nil, err
+	}
+	item.TaxRate = trimRate(item.TaxRate)
+
+	if item.UnitPrice, err = parseAmount(unitPrice, currency); err != nil {
+		return nil, err
+	}
+	if item.Discount, err = parseAmount(discount, currency); err != nil {
+		return nil, err
+	}
+	if item.Tax, err = parseAmount(tax, currency); err != nil {
+		return nil, err
+	}
+	if item.Total, err = parseAmount(total, currency); err != nil {
+		return nil, err
+	}
+
+	if reservationID.Valid {
+		id := int(reservationID.Int64)
+		item.ReservationID = &id
+	}
+	if warehouseID.Valid {
+		id := int(warehouseID.Int64)
+		item.WarehouseID = &id
+	}
+	if expectedAt.Valid {
+		item.ExpectedAt = &expectedAt.Time
+	}
+
+	return item, nil
+}
+
+// parseAmount parses an amount stored in the currency of an order. Orders placed before prices were recorded
+// have no currency, and their amounts are left zero.
+func parseAmount(amount, currency string) (types.Money, error) {
+	if currency == "" {
+		return types.Money{}, nil
+	}
+	return types.ParseMoney(amount, currency)
+}
+
+// trimRate returns a tax rate read from a DECIMAL column, such as "21.0000", without trailing zeros.
+func trimRate(rate string) string {
+	if !strings.Contains(rate, ".") {
+		return rate
+	}
+	return strings.TrimSuffix(strings.TrimRight(rate, "0"), ".")
+}
@@ -0,0 +1,44 @@
+package payment
+
+import (
+	"fmt"
+	"log"
+
+	// Import the types package for the PaymentProvider interface.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Names of the payment providers.
+const (
+	ProviderLog = "log" // Only logs charges and refunds, which always succeed. For development only.
+)
+
+// New returns the payment provider with the given name, which charges and refunds are made through. No real
+// provider is integrated yet; a provider's API client can be added by implementing the interface. There is no
+// default, so that a shop cannot take orders without charging for them by mistake.
+func New(name string) (types.PaymentProvider, error) {
+	switch name {
+	case ProviderLog:
+		return &LogProvider{}, nil
+	case "":
+		return nil, fmt.Errorf("no payment provider configured, set PAYMENT_PROVIDER")
+	}
+
+	return nil, fmt.Errorf("unknown payment provider %q", name)
+}
+
+// LogProvider struct writes charges and refunds to the log instead of making them. Every charge and refund
+// succeeds without any money moving, so it must only be used in development.
+type LogProvider struct{}
+
+// Charge is a method on the LogProvider struct that logs the charge, and returns its reference as its ID.
+func (p *LogProvider) Charge(charge types.PaymentCharge) (string, error) {
+	log.Printf("charge of %v to a payment method of user %d (%s)", charge.Amount, charge.UserID, charge.Reference)
+	return charge.Reference, nil
+}
+
+// Refund is a method on the LogProvider struct that logs the refund, and returns its reference as its ID.
+func (p *LogProvider) Refund(refund types.PaymentRefund) (string, error) {
+	log.Printf("refund of %v for order %d (%s)", refund.Amount, refund.OrderID, refund.Reference)
+	return refund.Reference, nil
+}
@@ -0,0 +1,109 @@
+package payment
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the auth package for the authentication middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle the payment methods of customers.
+type Handler struct {
+	store     types.PaymentMethodStore // Interface for saved payment methods.
+	userStore types.UserStore          // Interface for user-related data operations, used to authenticate customers.
+	now       func() time.Time         // Returns the current time.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.PaymentMethodStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore, now: time.Now}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the payment method routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Save payment methods to be charged later, such as for subscriptions, and remove them.
+	router.HandleFunc("/me/payment-methods", auth.WithAuth(h.handleGetMyPaymentMethods, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/me/payment-methods", auth.WithAuth(auth.WithoutImpersonation(h.handleSavePaymentMethod), h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/me/payment-methods/{id:[0-9]+}", auth.WithAuth(auth.WithoutImpersonation(h.handleRemovePaymentMethod), h.userStore)).Methods(http.MethodDelete)
+}
+
+// handleGetMyPaymentMethods handles GET /me/payment-methods.
+func (h *Handler) handleGetMyPaymentMethods(w http.ResponseWriter, r *http.Request) {
+	methods, err := h.store.GetPaymentMethods(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, methods)
+}
+
+// handleSavePaymentMethod handles POST /me/payment-methods.
+// The token comes from the payment provider's form; the shop never sees card numbers. Admins impersonating a
+// customer cannot save payment methods for them.
+func (h *Handler) handleSavePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	var payload types.PaymentMethodPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	m := types.PaymentMethod{
+		UserID:   auth.GetUserIDFromContext(r.Context()),
+		Token:    payload.Token,
+		Brand:    strings.ToLower(payload.Brand),
+		Last4:    payload.Last4,
+		ExpMonth: payload.ExpMonth,
+		ExpYear:  payload.ExpYear,
+	}
+	id, err := h.store.CreatePaymentMethod(m)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetPaymentMethodByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// handleRemovePaymentMethod handles DELETE /me/payment-methods/{id}.
+// Subscriptions still set to pay with the method fail to renew until the customer chooses another one. Admins
+// impersonating a customer cannot remove their payment methods.
+func (h *Handler) handleRemovePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	m, err := h.store.GetPaymentMethodByID(id)
+	if err != nil || m.UserID != auth.GetUserIDFromContext(r.Context()) || m.RemovedAt != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("payment method not found"))
+		return
+	}
+
+	if err := h.store.RemovePaymentMethod(id, h.now().UTC()); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package payment
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+	"time"
+
+	// Import the types package for the payment method types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// paymentMethodColumns lists the columns of the payment_methods table, in the order scanRowIntoPaymentMethod reads them.
+const paymentMethodColumns = "id, userId, token, brand, last4, expMonth, expYear, createdAt, removedAt"
+
+// Store struct represents the data store of saved payment methods.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreatePaymentMethod is a method on the Store struct that stores a payment method and returns its ID.
+func (s *Store) CreatePaymentMethod(m types.PaymentMethod) (int, error) {
+	res, err := s.db.Exec(
+		"INSERT INTO payment_methods (userId, token, brand, last4, expMonth, expYear) VALUES (?, ?, ?, ?, ?, ?)",
+		m.UserID, m.Token, m.Brand, m.Last4, m.ExpMonth, m.ExpYear,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetPaymentMethodByID is a method on the Store struct that retrieves a payment method by ID, including removed ones.
+func (s *Store) GetPaymentMethodByID(id int) (*types.PaymentMethod, error) {
+	rows, err := s.db.Query("SELECT "+paymentMethodColumns+" FROM payment_methods WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := scanPaymentMethods(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("payment method not found")
+	}
+
+	return methods[0], nil
+}
+
+// GetPaymentMethods is a method on the Store struct that retrieves the payment methods a user has not removed,
+// oldest first.
+func (s *Store) GetPaymentMethods(userID int) ([]*types.PaymentMethod, error) {
+	rows, err := s.db.Query("SELECT "+paymentMethodColumns+" FROM payment_methods WHERE userId = ? AND removedAt IS NULL ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPaymentMethods(rows)
+}
+
+// RemovePaymentMethod is a method on the Store struct that marks a payment method as removed. The row is kept, so
+// what was charged to it can still be told.
+func (s *Store) RemovePaymentMethod(id int, at time.Time) error {
+	_, err := s.db.Exec("UPDATE payment_methods SET removedAt = ? WHERE id = ? AND removedAt IS NULL", at, id)
+	return err
+}
+
+// scanPaymentMethods scans and closes rows selected with paymentMethodColumns.
+func scanPaymentMethods(rows *sql.Rows) ([]*types.PaymentMethod, error) {
+	defer rows.Close()
+
+	methods := []*types.PaymentMethod{}
+	for rows.Next() {
+		m, err := scanRowIntoPaymentMethod(rows)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+
+	return methods, rows.Err()
+}
+
+// scanRowIntoPaymentMethod scans a row selected with paymentMethodColumns into a PaymentMethod.
+func scanRowIntoPaymentMethod(rows *sql.Rows) (*types.PaymentMethod, error) {
+	m := new(types.PaymentMethod)
+	var removedAt sql.NullTime
+
+	err := rows.Scan(&m.ID, &m.UserID, &m.Token, &m.Brand, &m.Last4, &m.ExpMonth, &m.ExpYear, &m.CreatedAt, &removedAt)
+	if err != nil {
+		return nil, err
+	}
+	if removedAt.Valid {
+		m.RemovedAt = &removedAt.Time
+	}
+
+	return m, nil
+}
@@ -0,0 +1,158 @@
+package product
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Import the types package for the attribute definition types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for the validator.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+)
+
+// attributeNamePattern matches valid attribute names: camelCase identifiers such as "screenSize".
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+// reservedNames are the listing query parameters, which attributes cannot be named after
+// since filterable attributes are filtered on with a query parameter of the same name.
+var reservedNames = map[string]bool{
+	"category": true, "minPrice": true, "maxPrice": true, "inStock": true, "minRating": true,
+	"sort": true, "limit": true, "cursor": true, "q": true, "price": true, "rating": true,
+}
+
+// validateDefinition checks the parts of an attribute definition the payload tags cannot.
+func validateDefinition(payload types.AttributeDefinitionPayload) error {
+	if !attributeNamePattern.MatchString(payload.Name) {
+		return fmt.Errorf("attribute name must be a camelCase identifier, such as screenSize")
+	}
+	if reservedNames[payload.Name] {
+		return fmt.Errorf("attribute name %q is reserved", payload.Name)
+	}
+	if payload.Type != types.AttributeEnum && len(payload.Options) > 0 {
+		return fmt.Errorf("only enum attributes have options")
+	}
+	if payload.Type != types.AttributeNumber && payload.Unit != "" {
+		return fmt.Errorf("only number attributes have a unit")
+	}
+	// The oneof rule quotes options with single quotes, so they cannot contain one.
+	for _, option := range payload.Options {
+		if strings.ContainsRune(option, '\'') {
+			return fmt.Errorf("option %q must not contain a single quote", option)
+		}
+	}
+
+	return nil
+}
+
+// attributeRule returns the validation rule of the values of an attribute, in the tag syntax of utils.Validate.
+func attributeRule(d *types.AttributeDefinition) string {
+	switch d.Type {
+	case types.AttributeNumber:
+		return "numeric"
+	case types.AttributeEnum:
+		quoted := make([]string, len(d.Options))
+		for i, option := range d.Options {
+			quoted[i] = "'" + option + "'"
+		}
+		return "oneof=" + strings.Join(quoted, " ")
+	case types.AttributeBoolean:
+		return "boolean"
+	}
+	return "max=255"
+}
+
+// ValidateAttributes checks the attribute values of a product in a category against the category's definitions,
+// and returns them normalized: numbers in their shortest form and booleans as "true" or "false".
+// Values of attributes the category does not define are rejected, and required attributes must have a value.
+func ValidateAttributes(defs []*types.AttributeDefinition, values map[string]string) (map[string]string, error) {
+	byName := map[string]*types.AttributeDefinition{}
+	for _, d := range defs {
+		byName[d.Name] = d
+	}
+
+	// Report unknown attributes in a fixed order.
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if byName[name] == nil {
+			return nil, fmt.Errorf("unknown attribute %q for this category", name)
+		}
+	}
+
+	normalized := map[string]string{}
+	for _, d := range defs {
+		value := strings.TrimSpace(values[d.Name])
+		if value == "" {
+			if d.Required {
+				return nil, fmt.Errorf("attribute %q is required", d.Name)
+			}
+			continue
+		}
+
+		if err := utils.Validate.Var(value, attributeRule(d)); err != nil {
+			return nil, fmt.Errorf("invalid value %q for attribute %q, expected %s", value, d.Name, describe(d))
+		}
+
+		switch d.Type {
+		case types.AttributeNumber:
+			f, _ := strconv.ParseFloat(value, 64)
+			value = strconv.FormatFloat(f, 'f', -1, 64)
+		case types.AttributeBoolean:
+			b, _ := strconv.ParseBool(value)
+			value = strconv.FormatBool(b)
+		}
+		normalized[d.Name] = value
+	}
+
+	return normalized, nil
+}
+
+// describe returns what values an attribute takes, for error messages.
+func describe(d *types.AttributeDefinition) string {
+	switch d.Type {
+	case types.AttributeNumber:
+		if d.Unit != "" {
+			return "a number in " + d.Unit
+		}
+		return "a number"
+	case types.AttributeEnum:
+		return "one of " + strings.Join(d.Options, ", ")
+	case types.AttributeBoolean:
+		return "true or false"
+	}
+	return "at most 255 characters"
+}
+
+// parseRange parses a range filter on a number attribute: "40..55", "40..", "..55", or a single number.
+func parseRange(v string) (types.Range, error) {
+	var r types.Range
+
+	low, high, isRange := strings.Cut(v, "..")
+	if !isRange {
+		high = low
+	}
+	for _, bound := range []struct {
+		text string
+		dest **float64
+	}{{low, &r.Min}, {high, &r.Max}} {
+		if bound.text == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(bound.text, 64)
+		if err != nil {
+			return r, fmt.Errorf("invalid range %q", v)
+		}
+		*bound.dest = &f
+	}
+
+	if r.Min == nil && r.Max == nil || r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return r, fmt.Errorf("invalid range %q", v)
+	}
+	return r, nil
+}
@@ -0,0 +1,55 @@
+package product
+
+import (
+	"strconv" // Import the strconv package to print range bounds
+	"testing" // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for attribute definitions
+)
+
+// TestParseRange tests the range syntax of number attribute filters.
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max string
+		ok       bool
+	}{
+		{"40..55", "40", "55", true},
+		{"40..", "40", "-", true},
+		{"..55", "-", "55", true},
+		{"42", "42", "42", true},
+		{"..", "", "", false},
+		{"55..40", "", "", false},
+		{"big", "", "", false},
+	}
+
+	bound := func(f *float64) string {
+		if f == nil {
+			return "-"
+		}
+		return strconv.FormatFloat(*f, 'f', -1, 64)
+	}
+
+	for _, tt := range tests {
+		r, err := parseRange(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseRange(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && (bound(r.Min) != tt.min || bound(r.Max) != tt.max) {
+			t.Errorf("parseRange(%q) = %s..%s, want %s..%s", tt.in, bound(r.Min), bound(r.Max), tt.min, tt.max)
+		}
+	}
+}
+
+// TestAttributeRule tests the validation rules built from attribute definitions.
+func TestAttributeRule(t *testing.T) {
+	d := &types.AttributeDefinition{Type: types.AttributeEnum, Options: []string{"Navy blue", "Red"}}
+
+	if got := attributeRule(d); got != "oneof='Navy blue' 'Red'" {
+		t.Errorf("unexpected rule %q", got)
+	}
+	if _, err := ValidateAttributes([]*types.AttributeDefinition{{Name: "color", Type: types.AttributeEnum, Options: d.Options}}, map[string]string{"color": "Navy blue"}); err != nil {
+		t.Errorf("expected an option with a space to be valid, got %v", err)
+	}
+}
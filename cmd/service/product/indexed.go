@@ -0,0 +1,82 @@
+package product
+
+import (
+	"log"
+
+	// Import the types package for the store and search index interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// IndexedStore struct wraps a ProductStore and keeps a search index in sync with every write,
+// so no code path can change a product without updating the index.
+type IndexedStore struct {
+	types.ProductStore                   // The wrapped store, used for all reads.
+	index              types.SearchIndex // The search index kept in sync.
+}
+
+// NewIndexedStore is a constructor function that returns a store keeping the index in sync with the given store.
+func NewIndexedStore(store types.ProductStore, index types.SearchIndex) *IndexedStore {
+	return &IndexedStore{ProductStore: store, index: index}
+}
+
+// CreateProduct is a method on the IndexedStore struct that adds a new product and indexes it.
+func (s *IndexedStore) CreateProduct(p types.Product) (int, error) {
+	id, err := s.ProductStore.CreateProduct(p)
+	if err != nil {
+		return 0, err
+	}
+
+	s.reindex(id)
+	return id, nil
+}
+
+// UpdateProduct is a method on the IndexedStore struct that replaces the details of a product and reindexes it.
+func (s *IndexedStore) UpdateProduct(p types.Product) error {
+	if err := s.ProductStore.UpdateProduct(p); err != nil {
+		return err
+	}
+
+	s.reindex(p.ID)
+	return nil
+}
+
+// DeleteProduct is a method on the IndexedStore struct that removes a product and its index entry.
+func (s *IndexedStore) DeleteProduct(id int) error {
+	if err := s.ProductStore.DeleteProduct(id); err != nil {
+		return err
+	}
+
+	if err := s.index.Remove(id); err != nil {
+		log.Printf("failed to remove product %d from the search index: %v", id, err)
+	}
+	return nil
+}
+
+// Reindex is a method on the IndexedStore struct that indexes every product in the store,
+// for example to fill an in-memory index at startup.
+func (s *IndexedStore) Reindex() error {
+	products, err := s.ProductStore.GetProducts()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		if err := s.index.Index(*p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reindex indexes the stored version of a product, so the index sees the same data as readers of the store.
+// The write has already succeeded, so failures are only logged.
+func (s *IndexedStore) reindex(id int) {
+	p, err := s.ProductStore.GetProductByID(id)
+	if err == nil {
+		err = s.index.Index(*p)
+	}
+	if err != nil {
+		log.Printf("failed to index product %d: %v", id, err)
+	}
+}
@@ -0,0 +1,387 @@
+package product
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the config package for the popularity window.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the types package for the listing types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// FacetAttributes are the attributes product listings can always be filtered on and count facet values for,
+// besides the filterable attributes defined for categories.
+var FacetAttributes = []string{"brand", "size", "color"}
+
+// priceBounds are the upper bounds, in whole units of the base currency, of the price ranges counted by
+// the price facet. The last range is open.
+var priceBounds = []int64{25, 50, 100, 200}
+
+// ratingSteps are the minimum ratings counted by the rating facet.
+var ratingSteps = []int{4, 3, 2, 1}
+
+// sortOrder describes how a listing is sorted: by a key, then by product ID ascending to break ties.
+type sortOrder struct {
+	key  string // SQL expression the products are sorted by.
+	desc bool   // Whether the highest key comes first.
+}
+
+// sortOrders maps the Sort constants to how they sort. Newest first sorts on the ID, which increases with every
+// product added and is unique, so pages never skip products created in the same second.
+var sortOrders = map[string]sortOrder{
+	types.SortNewest:     {key: "p.id", desc: true},
+	types.SortPriceAsc:   {key: "p.price"},
+	types.SortPriceDesc:  {key: "p.price", desc: true},
+	types.SortPopularity: {key: "COALESCE(s.sold, 0)", desc: true},
+	types.SortRating:     {key: "p.rating", desc: true},
+}
+
+// ValidSort reports whether sort is one of the Sort constants.
+func ValidSort(sort string) bool {
+	_, ok := sortOrders[sort]
+	return ok
+}
+
+// condition is a condition of a listing query, belonging to the filter of a facet.
+type condition struct {
+	facet string // The facet the condition filters on, or "" if it is not a filter.
+	sql   string // The SQL condition on the products p.
+	args  []any  // Arguments of the SQL condition.
+}
+
+// listingQuery holds the SQL of a listing query, built from a ProductQuery.
+type listingQuery struct {
+	from       string      // FROM clause joining products p with their category c and the units s ordered recently.
+	fromArgs   []any       // Arguments of the FROM clause.
+	available  string      // SQL expression for the quantity of a product available to sell.
+	availArgs  []any       // Arguments of the available expression.
+	conditions []condition // The filters.
+}
+
+// newListingQuery builds the SQL of a listing query at the given time.
+func newListingQuery(q types.ProductQuery, now time.Time) *listingQuery {
+	l := &listingQuery{
+		from: " FROM products p LEFT JOIN categories c ON c.id = p.categoryId" +
+			" LEFT JOIN (SELECT oi.sku, SUM(oi.quantity) AS sold FROM order_items oi JOIN orders o ON o.id = oi.orderId" +
+			" WHERE o.createdAt >= ? AND o.status != ? GROUP BY oi.sku) s ON s.sku = p.sku",
+		fromArgs: []any{now.AddDate(0, 0, -int(config.Envs.PopularityWindowDays)), types.OrderCancelled},
+
+		// The same calculation as the inventory store's availableToSell, for every product at once.
+		available: "(COALESCE((SELECT SUM(sl.onHand) FROM stock_levels sl JOIN warehouses w ON w.id = sl.warehouseId WHERE sl.sku = p.sku AND w.active), 0)" +
+			" - COALESCE((SELECT SUM(r.quantity) FROM reservations r WHERE r.sku = p.sku AND r.status = ? AND r.expiresAt > ?), 0))",
+		availArgs: []any{types.ReservationActive, now},
+	}
+
+	if len(q.Categories) > 0 {
+		l.add(types.FacetCategory, "c.slug IN ("+placeholders(len(q.Categories))+")", stringArgs(q.Categories)...)
+	}
+
+	// Both price bounds are one filter, on the price facet.
+	var prices []string
+	var priceArgs []any
+	if q.MinPrice != nil {
+		prices = append(prices, "p.price >= ?")
+		priceArgs = append(priceArgs, q.MinPrice.Decimal())
+	}
+	if q.MaxPrice != nil {
+		prices = append(prices, "p.price <= ?")
+		priceArgs = append(priceArgs, q.MaxPrice.Decimal())
+	}
+	if len(prices) > 0 {
+		l.add(types.FacetPrice, strings.Join(prices, " AND "), priceArgs...)
+	}
+
+	for _, name := range sortedKeys(q.Attributes) {
+		values := q.Attributes[name]
+		if len(values) == 0 {
+			continue
+		}
+		l.add(name,
+			"EXISTS (SELECT 1 FROM product_attributes pa WHERE pa.productId = p.id AND pa.name = ? AND pa.value IN ("+placeholders(len(values))+"))",
+			append([]any{name}, stringArgs(values)...)...,
+		)
+	}
+
+	for _, name := range sortedKeys(q.Ranges) {
+		r := q.Ranges[name]
+		conds := []string{"pa.productId = p.id", "pa.name = ?"}
+		args := []any{name}
+		if r.Min != nil {
+			conds = append(conds, "CAST(pa.value AS DECIMAL(20, 6)) >= ?")
+			args = append(args, *r.Min)
+		}
+		if r.Max != nil {
+			conds = append(conds, "CAST(pa.value AS DECIMAL(20, 6)) <= ?")
+			args = append(args, *r.Max)
+		}
+		l.add(name, "EXISTS (SELECT 1 FROM product_attributes pa WHERE "+strings.Join(conds, " AND ")+")", args...)
+	}
+
+	if q.InStock {
+		l.add(types.FacetInStock, l.available+" > 0", l.availArgs...)
+	}
+	if q.MinRating > 0 {
+		l.add(types.FacetRating, "p.rating >= ?", q.MinRating)
+	}
+
+	return l
+}
+
+// add adds the filter of a facet.
+func (l *listingQuery) add(facet, sql string, args ...any) {
+	l.conditions = append(l.conditions, condition{facet: facet, sql: sql, args: args})
+}
+
+// where returns the WHERE clause combining the filters, leaving out the filter of the except facet,
+// and any extra conditions, followed by the arguments of the FROM and WHERE clauses.
+func (l *listingQuery) where(except string, extra ...condition) (string, []any) {
+	var conds []string
+	args := append([]any{}, l.fromArgs...)
+
+	for _, c := range append(append([]condition{}, l.conditions...), extra...) {
+		if c.facet != "" && c.facet == except {
+			continue
+		}
+		conds = append(conds, c.sql)
+		args = append(args, c.args...)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// ListProducts is a method on the Store struct that retrieves a page of the products matching the query,
+// the number of products matching it in total, and the facet counts.
+func (s *Store) ListProducts(q types.ProductQuery) (*types.ProductListing, error) {
+	order, ok := sortOrders[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort order %q", q.Sort)
+	}
+
+	l := newListingQuery(q, time.Now().UTC())
+	listing := &types.ProductListing{Facets: map[string][]types.FacetValue{}}
+
+	where, args := l.where("")
+	if err := s.db.QueryRow("SELECT COUNT(*)"+l.from+where, args...).Scan(&listing.Total); err != nil {
+		return nil, err
+	}
+
+	products, next, err := s.listPage(l, order, q)
+	if err != nil {
+		return nil, err
+	}
+	listing.Products, listing.Next = products, next
+
+	if err := s.countFacets(l, q, listing.Facets); err != nil {
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+// listPage retrieves the page of products of a listing, and the cursor of the next page if there is one.
+func (s *Store) listPage(l *listingQuery, order sortOrder, q types.ProductQuery) ([]*types.Product, *types.ListingCursor, error) {
+	dir, cmp := "ASC", ">"
+	if order.desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	// Start after the last product of the previous page: further along the sort key,
+	// or at the same key with a higher ID.
+	var extra []condition
+	if q.After != nil {
+		extra = append(extra, condition{
+			sql:  "(" + order.key + " " + cmp + " ? OR (" + order.key + " = ? AND p.id > ?))",
+			args: []any{q.After.Value, q.After.Value, q.After.ID},
+		})
+	}
+	where, args := l.where("", extra...)
+
+	// Fetch one product more than the page holds, to know whether there is a next page.
+	rows, err := s.db.Query(
+		"SELECT "+productColumns+", "+order.key+l.from+where+" ORDER BY "+order.key+" "+dir+", p.id ASC LIMIT ?",
+		append(args, q.Limit+1)...,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	products := []*types.Product{}
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		p, err := s.scanProduct(rows, &key)
+		if err != nil {
+			return nil, nil, err
+		}
+		products = append(products, p)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *types.ListingCursor
+	if len(products) > q.Limit {
+		products = products[:q.Limit]
+		last := products[q.Limit-1]
+		next = &types.ListingCursor{Sort: q.Sort, Value: keys[q.Limit-1], ID: last.ID}
+	}
+
+	return products, next, s.loadAttributes(products)
+}
+
+// countFacets counts the values of every facet. Each facet is counted with all filters except its own.
+func (s *Store) countFacets(l *listingQuery, q types.ProductQuery, facets map[string][]types.FacetValue) error {
+	var err error
+
+	// Categories.
+	where, args := l.where(types.FacetCategory, condition{sql: "p.categoryId IS NOT NULL"})
+	facets[types.FacetCategory], err = s.countValues("SELECT c.slug, COUNT(*)"+l.from+where+" GROUP BY c.slug ORDER BY c.slug", args)
+	if err != nil {
+		return err
+	}
+
+	// Attributes, including any filtered on that are not facets.
+	names := append([]string{}, q.Facets...)
+	for _, name := range append(sortedKeys(q.Attributes), sortedKeys(q.Ranges)...) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		where, args := l.where(name)
+		// The attribute join comes after the FROM clause, so its argument goes after the FROM arguments.
+		args = append(append(append([]any{}, l.fromArgs...), name), args[len(l.fromArgs):]...)
+		facets[name], err = s.countValues(
+			"SELECT fa.value, COUNT(*)"+l.from+" JOIN product_attributes fa ON fa.productId = p.id AND fa.name = ?"+where+" GROUP BY fa.value ORDER BY fa.value",
+			args,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Price ranges.
+	var cases strings.Builder
+	cases.WriteString("CASE")
+	for _, r := range priceRanges() {
+		if r.max > 0 {
+			fmt.Fprintf(&cases, " WHEN p.price < %d THEN '%s'", r.max, r.label)
+		} else {
+			fmt.Fprintf(&cases, " ELSE '%s'", r.label)
+		}
+	}
+	cases.WriteString(" END")
+
+	where, args = l.where(types.FacetPrice)
+	counts, err := s.countValues("SELECT "+cases.String()+" AS bucket, COUNT(*)"+l.from+where+" GROUP BY bucket", args)
+	if err != nil {
+		return err
+	}
+	facets[types.FacetPrice] = []types.FacetValue{}
+	for _, r := range priceRanges() {
+		for _, c := range counts {
+			if c.Value == r.label {
+				facets[types.FacetPrice] = append(facets[types.FacetPrice], c)
+			}
+		}
+	}
+
+	// Minimum ratings, counted in one row.
+	sums := make([]string, len(ratingSteps))
+	for i, step := range ratingSteps {
+		sums[i] = fmt.Sprintf("COALESCE(SUM(p.rating >= %d), 0)", step)
+	}
+	where, args = l.where(types.FacetRating)
+	ratingCounts := make([]int, len(ratingSteps))
+	dest := make([]any, len(ratingSteps))
+	for i := range ratingCounts {
+		dest[i] = &ratingCounts[i]
+	}
+	if err := s.db.QueryRow("SELECT "+strings.Join(sums, ", ")+l.from+where, args...).Scan(dest...); err != nil {
+		return err
+	}
+	facets[types.FacetRating] = []types.FacetValue{}
+	for i, step := range ratingSteps {
+		facets[types.FacetRating] = append(facets[types.FacetRating], types.FacetValue{Value: strconv.Itoa(step), Count: ratingCounts[i]})
+	}
+
+	// Products in stock.
+	where, args = l.where(types.FacetInStock)
+	var inStock int
+	query := "SELECT COALESCE(SUM(" + l.available + " > 0), 0)" + l.from + where
+	// The available expression is in the SELECT clause, so its arguments go first.
+	if err := s.db.QueryRow(query, append(append([]any{}, l.availArgs...), args...)...).Scan(&inStock); err != nil {
+		return err
+	}
+	facets[types.FacetInStock] = []types.FacetValue{{Value: "true", Count: inStock}}
+
+	return nil
+}
+
+// countValues runs a query selecting values and their counts.
+func (s *Store) countValues(query string, args []any) ([]types.FacetValue, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []types.FacetValue{}
+	for rows.Next() {
+		var v types.FacetValue
+		if err := rows.Scan(&v.Value, &v.Count); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
+
+// priceRange is a range of the price facet. The highest range has no maximum.
+type priceRange struct {
+	label string // The facet value, such as "25-50" or "200-".
+	max   int64  // Exclusive upper bound, or 0 for the highest range.
+}
+
+// priceRanges returns the ranges of the price facet, from cheapest to most expensive.
+func priceRanges() []priceRange {
+	ranges := []priceRange{}
+	low := int64(0)
+	for _, high := range priceBounds {
+		ranges = append(ranges, priceRange{label: fmt.Sprintf("%d-%d", low, high), max: high})
+		low = high
+	}
+
+	return append(ranges, priceRange{label: fmt.Sprintf("%d-", low)})
+}
+
+// sortedKeys returns the keys of the map in order, so queries are built the same way every time.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// stringArgs converts strings to query arguments.
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,52 @@
+package product
+
+import (
+	"fmt"     // Import the fmt package to compare arguments
+	"strings" // Import the strings package to inspect the SQL
+	"testing" // Import the testing package to write test cases
+	"time"    // Import the time package for the time of the query
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for listing types
+)
+
+// TestListingQueryWhere tests that facets are counted without their own filter, and arguments stay in order.
+func TestListingQueryWhere(t *testing.T) {
+	now := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	minPrice := types.NewMoney(1000, "EUR")
+	l := newListingQuery(types.ProductQuery{
+		Categories: []string{"shirts"},
+		MinPrice:   &minPrice,
+		Attributes: map[string][]string{"size": {"M", "L"}},
+	}, now)
+
+	where, args := l.where("")
+	if !strings.HasPrefix(where, " WHERE c.slug IN (?) AND p.price >= ? AND EXISTS (") {
+		t.Errorf("expected the three filters, got %q", where)
+	}
+	// The FROM arguments come first, then the filters in the order they were added.
+	if got := fmt.Sprint(args[2:]); got != "[shirts 10.00 size M L]" {
+		t.Errorf("unexpected arguments %s", got)
+	}
+
+	// Counting sizes leaves out the size filter, so other sizes still get counts.
+	where, args = l.where("size")
+	if strings.Contains(where, "product_attributes") || fmt.Sprint(args[2:]) != "[shirts 10.00]" {
+		t.Errorf("expected the size filter to be left out, got %q %v", where, args)
+	}
+
+	if where, _ := newListingQuery(types.ProductQuery{}, now).where(""); where != "" {
+		t.Errorf("expected no WHERE clause without filters, got %q", where)
+	}
+}
+
+// TestPriceRanges tests the labels of the price facet.
+func TestPriceRanges(t *testing.T) {
+	labels := []string{}
+	for _, r := range priceRanges() {
+		labels = append(labels, r.label)
+	}
+
+	if got := strings.Join(labels, " "); got != "0-25 25-50 50-100 100-200 200-" {
+		t.Errorf("unexpected price ranges %s", got)
+	}
+}
@@ -0,0 +1,84 @@
+package product
+
+import (
+	"fmt"
+
+	// Import the types package for the Money type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// GetPriceList is a method on the Store struct that retrieves the prices set for a product in other currencies
+// than the base currency, ordered by currency.
+func (s *Store) GetPriceList(productID int) ([]types.Money, error) {
+	rows, err := s.db.Query("SELECT currency, price FROM product_prices WHERE productId = ? ORDER BY currency", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []types.Money{}
+	for rows.Next() {
+		var currency, amount string
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return nil, err
+		}
+		price, err := types.ParseMoney(amount, currency)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+// GetPrices is a method on the Store struct that retrieves the prices set in a currency for the products with
+// the given IDs, by product ID.
+func (s *Store) GetPrices(productIDs []int, currency string) (map[int]types.Money, error) {
+	prices := map[int]types.Money{}
+	if len(productIDs) == 0 {
+		return prices, nil
+	}
+
+	rows, err := s.db.Query(
+		"SELECT productId, price FROM product_prices WHERE currency = ? AND productId IN ("+placeholders(len(productIDs))+")",
+		append([]any{currency}, intArgs(productIDs)...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var amount string
+		if err := rows.Scan(&id, &amount); err != nil {
+			return nil, err
+		}
+		if prices[id], err = types.ParseMoney(amount, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	return prices, rows.Err()
+}
+
+// SetPrice is a method on the Store struct that sets the price of a product in the currency of the price.
+// Base prices are set on the product itself.
+func (s *Store) SetPrice(productID int, price types.Money) error {
+	if price.Currency == s.currency {
+		return fmt.Errorf("prices in %s are set on the product", s.currency)
+	}
+
+	_, err := s.db.Exec(
+		"INSERT INTO product_prices (productId, currency, price) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE price = VALUES(price)",
+		productID, price.Currency, price.Decimal(),
+	)
+	return err
+}
+
+// DeletePrice is a method on the Store struct that removes the price of a product in a currency.
+func (s *Store) DeletePrice(productID int, currency string) error {
+	_, err := s.db.Exec("DELETE FROM product_prices WHERE productId = ? AND currency = ?", productID, currency)
+	return err
+}
@@ -0,0 +1,626 @@
+package product
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	// Import the auth package for the admin-only middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the currency package to find the currency prices are shown in.
+	"github.com/FreekAlberti/Ecom/cmd/service/currency"
+	// Import the search package to normalize logged queries.
+	"github.com/FreekAlberti/Ecom/cmd/service/search"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Default and maximum number of products in search results and listing pages.
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// maxQueryLength is the longest search query logged, in characters.
+const maxQueryLength = 255
+
+// slugPattern matches valid category slugs, such as "t-shirts".
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Handler struct groups the methods that handle product requests.
+type Handler struct {
+	store      types.ProductStore  // Interface for the product catalog.
+	categories types.CategoryStore // Interface for the product categories.
+	variants   types.VariantStore  // Interface for the product variants.
+	index      types.SearchIndex   // Interface for full-text search.
+	suggester  types.Suggester     // Interface for spelling corrections of searches without results.
+	queries    types.QueryLogStore // Interface for the log of search queries.
+	pricer     types.Pricer        // Shows prices in the currency of the customer.
+	userStore  types.UserStore     // Interface for user-related data operations, used to authenticate admins.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+// The store should keep the index in sync, for example by being an IndexedStore.
+func NewHandler(store types.ProductStore, categories types.CategoryStore, variants types.VariantStore, index types.SearchIndex, suggester types.Suggester, queries types.QueryLogStore, pricer types.Pricer, userStore types.UserStore) *Handler {
+	return &Handler{store: store, categories: categories, variants: variants, index: index, suggester: suggester, queries: queries, pricer: pricer, userStore: userStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the product routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Browse and search the catalog.
+	router.HandleFunc("/products", h.handleGetProducts).Methods(http.MethodGet)
+	router.HandleFunc("/products/search", h.handleSearch).Methods(http.MethodGet)
+	router.HandleFunc("/products/{id:[0-9]+}", h.handleGetProduct).Methods(http.MethodGet)
+	router.HandleFunc("/products/{id:[0-9]+}/variants", h.handleGetVariants).Methods(http.MethodGet)
+	router.HandleFunc("/categories", h.handleGetCategories).Methods(http.MethodGet)
+	router.HandleFunc("/categories/{id:[0-9]+}/attributes", h.handleGetAttributeDefinitions).Methods(http.MethodGet)
+
+	// Manage the catalog.
+	router.HandleFunc("/admin/categories", auth.WithAdminAuth(h.handleCreateCategory, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/categories/{id:[0-9]+}/attributes", auth.WithAdminAuth(h.handleCreateAttributeDefinition, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/categories/{id:[0-9]+}/attributes/{name}", auth.WithAdminAuth(h.handleDeleteAttributeDefinition, h.userStore)).Methods(http.MethodDelete)
+	router.HandleFunc("/admin/products", auth.WithAdminAuth(h.handleCreateProduct, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/products/{id:[0-9]+}", auth.WithAdminAuth(h.handleUpdateProduct, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/products/{id:[0-9]+}", auth.WithAdminAuth(h.handleDeleteProduct, h.userStore)).Methods(http.MethodDelete)
+}
+
+// handleGetProducts handles GET /products.
+// It supports the query parameters category, minPrice, maxPrice, brand, size, color, inStock and minRating
+// to filter the products, sort, limit and cursor, the nextCursor of the previous page.
+// Filterable attributes of categories are filtered on with a parameter of their name.
+// Filters taking a list of values accept them comma-separated, and match products with any of them.
+// Number attributes take a range instead, such as 40..55.
+// Prices, including the price filters, are in the currency of the request; price facets stay in the base currency.
+func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	filterable, err := h.categories.GetFilterableAttributes()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	q, err := h.parseProductQuery(r.URL.Query(), filterable, cur)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	listing, err := h.store.ListProducts(q)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.pricer.PriceProducts(listing.Products, cur); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := map[string]any{
+		"products": listing.Products,
+		"total":    listing.Total,
+		"facets":   listing.Facets,
+	}
+	if listing.Next != nil {
+		res["nextCursor"] = encodeCursor(*listing.Next)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// handleGetProduct handles GET /products/{id}.
+func (h *Handler) handleGetProduct(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	p, err := h.store.GetProductByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err := h.pricer.PriceProducts([]*types.Product{p}, cur); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, p)
+}
+
+// handleGetVariants handles GET /products/{id}/variants.
+func (h *Handler) handleGetVariants(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := h.store.GetProductByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	variants, err := h.variants.GetVariants(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.pricer.PriceVariants(variants, cur); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, variants)
+}
+
+// handleSearch handles GET /products/search.
+// It supports the query parameters q, the search terms, and limit.
+// Every search is logged for autocomplete, and searches without results suggest a corrected query.
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing search query"))
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	hits, err := h.index.Search(q, limit)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Load the matching products, keeping the ranking.
+	ids := make([]int, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ProductID
+	}
+	products, err := h.store.GetProductsByIDs(ids)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.pricer.PriceProducts(products, cur); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	scores := map[int]float64{}
+	for _, hit := range hits {
+		scores[hit.ProductID] = hit.Score
+	}
+	results := []types.SearchResult{}
+	for _, p := range products {
+		results = append(results, types.SearchResult{Product: p, Score: scores[p.ID]})
+	}
+
+	// The log only feeds suggestions, so a failure to log does not fail the search.
+	if err := h.queries.LogQuery(logQuery(q), len(results)); err != nil {
+		log.Printf("failed to log search query: %v", err)
+	}
+
+	res := map[string]any{
+		"query":   q,
+		"results": results,
+	}
+	if len(results) == 0 {
+		if suggestion := h.suggester.DidYouMean(q); suggestion != "" {
+			res["didYouMean"] = suggestion
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// logQuery returns the query as it is logged: normalized, and cut to the length of the log column.
+func logQuery(q string) string {
+	runes := []rune(search.NormalizeQuery(q))
+	return string(runes[:min(len(runes), maxQueryLength)])
+}
+
+// handleGetCategories handles GET /categories.
+func (h *Handler) handleGetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categories.GetCategories()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, categories)
+}
+
+// handleCreateCategory handles POST /admin/categories.
+func (h *Handler) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+	var payload types.CategoryPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+	if !slugPattern.MatchString(payload.Slug) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("slug may only contain lowercase letters, digits and single hyphens"))
+		return
+	}
+
+	id, err := h.categories.CreateCategory(types.Category{Slug: payload.Slug, Name: payload.Name})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": id})
+}
+
+// handleGetAttributeDefinitions handles GET /categories/{id}/attributes.
+func (h *Handler) handleGetAttributeDefinitions(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if _, err := h.categories.GetCategoryByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	defs, err := h.categories.GetAttributeDefinitions(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, defs)
+}
+
+// handleCreateAttributeDefinition handles POST /admin/categories/{id}/attributes.
+func (h *Handler) handleCreateAttributeDefinition(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if _, err := h.categories.GetCategoryByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.AttributeDefinitionPayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+	if err := validateDefinition(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Names are unique within a category.
+	defs, err := h.categories.GetAttributeDefinitions(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, d := range defs {
+		if d.Name == payload.Name {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("attribute %q is already defined for this category", payload.Name))
+			return
+		}
+	}
+
+	d := types.AttributeDefinition{
+		CategoryID: id,
+		Name:       payload.Name,
+		Label:      payload.Label,
+		Type:       payload.Type,
+		Unit:       payload.Unit,
+		Options:    payload.Options,
+		Required:   payload.Required,
+		Filterable: payload.Filterable,
+	}
+	if d.Options == nil {
+		d.Options = []string{}
+	}
+
+	if d.ID, err = h.categories.CreateAttributeDefinition(d); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, d)
+}
+
+// handleDeleteAttributeDefinition handles DELETE /admin/categories/{id}/attributes/{name}.
+func (h *Handler) handleDeleteAttributeDefinition(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err := h.categories.DeleteAttributeDefinition(id, mux.Vars(r)["name"]); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCreateProduct handles POST /admin/products.
+func (h *Handler) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
+	payload, ok := h.parseProductPayload(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := h.store.CreateProduct(productFromPayload(payload))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": id})
+}
+
+// handleUpdateProduct handles PUT /admin/products/{id}.
+func (h *Handler) handleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if _, err := h.store.GetProductByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	payload, ok := h.parseProductPayload(w, r)
+	if !ok {
+		return
+	}
+
+	p := productFromPayload(payload)
+	p.ID = id
+	if err := h.store.UpdateProduct(p); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, p)
+}
+
+// handleDeleteProduct handles DELETE /admin/products/{id}.
+func (h *Handler) handleDeleteProduct(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err := h.store.DeleteProduct(id); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseProductPayload parses and validates the product in the request body, checks its category exists,
+// and validates and normalizes its attributes against the attributes defined for the category.
+// Products without a category, or in a category without defined attributes, may have any attributes.
+// On failure it writes the error response and returns false.
+func (h *Handler) parseProductPayload(w http.ResponseWriter, r *http.Request) (types.ProductPayload, bool) {
+	var payload types.ProductPayload
+
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return payload, false
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return payload, false
+	}
+	if payload.Price.Currency != h.pricer.Base() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("price must be in %s; set prices in other currencies on the price list", h.pricer.Base()))
+		return payload, false
+	}
+	if payload.CategoryID != nil {
+		if _, err := h.categories.GetCategoryByID(*payload.CategoryID); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid category %d", *payload.CategoryID))
+			return payload, false
+		}
+
+		defs, err := h.categories.GetAttributeDefinitions(*payload.CategoryID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return payload, false
+		}
+		if len(defs) > 0 {
+			if payload.Attributes, err = ValidateAttributes(defs, payload.Attributes); err != nil {
+				utils.WriteError(w, http.StatusBadRequest, err)
+				return payload, false
+			}
+		}
+	}
+
+	return payload, true
+}
+
+// productFromPayload returns the product described by a payload.
+func productFromPayload(payload types.ProductPayload) types.Product {
+	return types.Product{
+		SKU:         payload.SKU,
+		Name:        payload.Name,
+		Description: payload.Description,
+		Image:       payload.Image,
+		Price:       payload.Price,
+		CategoryID:  payload.CategoryID,
+		TaxClass:    payload.TaxClass,
+		Weight:      payload.Weight,
+		Rating:      payload.Rating,
+		ReviewCount: payload.ReviewCount,
+		Attributes:  payload.Attributes,
+	}
+}
+
+// parseProductQuery parses the query parameters of a product listing, with the filterable attributes of all categories.
+// The price filters are in the currency prices are shown in, and are converted to the base currency.
+func (h *Handler) parseProductQuery(values url.Values, filterable []*types.AttributeDefinition, cur string) (types.ProductQuery, error) {
+	q := types.ProductQuery{
+		Categories: splitList(values.Get("category")),
+		Attributes: map[string][]string{},
+		Ranges:     map[string]types.Range{},
+		Facets:     append([]string{}, FacetAttributes...),
+		Sort:       types.SortNewest,
+	}
+
+	var err error
+	if q.Limit, err = parseLimit(values); err != nil {
+		return q, err
+	}
+
+	// Price range.
+	for _, bound := range []struct {
+		name string
+		dest **types.Money
+	}{{"minPrice", &q.MinPrice}, {"maxPrice", &q.MaxPrice}} {
+		if v := values.Get(bound.name); v != "" {
+			m, err := types.ParseMoney(v, cur)
+			if err != nil || m.IsNegative() {
+				return q, fmt.Errorf("%s must be a non-negative amount in %s", bound.name, cur)
+			}
+			if m, err = h.pricer.Convert(m, h.pricer.Base()); err != nil {
+				return q, err
+			}
+			*bound.dest = &m
+		}
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && q.MinPrice.Amount > q.MaxPrice.Amount {
+		return q, fmt.Errorf("minPrice must not be above maxPrice")
+	}
+
+	// Defined attributes are filtered on by their type; the default facets take lists of values.
+	numbers := map[string]bool{}
+	for _, d := range filterable {
+		if !slices.Contains(q.Facets, d.Name) {
+			q.Facets = append(q.Facets, d.Name)
+		}
+		numbers[d.Name] = d.Type == types.AttributeNumber
+	}
+	for _, name := range q.Facets {
+		v := values.Get(name)
+		if v == "" {
+			continue
+		}
+
+		if numbers[name] {
+			r, err := parseRange(v)
+			if err != nil {
+				return q, fmt.Errorf("%s: %v", name, err)
+			}
+			q.Ranges[name] = r
+		} else if list := splitList(v); len(list) > 0 {
+			q.Attributes[name] = list
+		}
+	}
+
+	if v := values.Get("inStock"); v != "" {
+		if q.InStock, err = strconv.ParseBool(v); err != nil {
+			return q, fmt.Errorf("inStock must be true or false")
+		}
+	}
+
+	if v := values.Get("minRating"); v != "" {
+		if q.MinRating, err = strconv.ParseFloat(v, 64); err != nil || q.MinRating < 0 || q.MinRating > 5 {
+			return q, fmt.Errorf("minRating must be between 0 and 5")
+		}
+	}
+
+	if v := values.Get("sort"); v != "" {
+		if !ValidSort(v) {
+			return q, fmt.Errorf("unknown sort order %q", v)
+		}
+		q.Sort = v
+	}
+
+	// A cursor only continues the listing it came from.
+	if v := values.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil || c.Sort != q.Sort {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		q.After = &c
+	}
+
+	return q, nil
+}
+
+// parseLimit parses the limit query parameter, which defaults to defaultLimit.
+func parseLimit(values url.Values) (int, error) {
+	v := values.Get("limit")
+	if v == "" {
+		return defaultLimit, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > maxLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	return n, nil
+}
+
+// splitList splits a comma-separated query parameter, dropping empty values.
+func splitList(v string) []string {
+	list := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// encodeCursor encodes a listing cursor as an opaque string.
+func encodeCursor(c types.ListingCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor decodes a string made by encodeCursor.
+func decodeCursor(s string) (types.ListingCursor, error) {
+	var c types.ListingCursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
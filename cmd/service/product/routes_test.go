@@ -0,0 +1,665 @@
+package product
+
+import (
+	"bytes"             // Import the bytes package to build request bodies
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"path/filepath"     // Import the filepath package to name the exchange rates file
+	"slices"            // Import the slices package to remove definitions
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for the query log
+
+	"github.com/FreekAlberti/Ecom/cmd/config"           // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"     // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/service/currency" // Import the currency package for the pricer
+	"github.com/FreekAlberti/Ecom/cmd/service/search"   // Import the search package for the in-memory index
+	"github.com/FreekAlberti/Ecom/cmd/types"            // Import the custom types package for product types
+	"github.com/gorilla/mux"                            // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestProductHandlers tests the product HTTP handlers, with a real in-memory search index.
+func TestProductHandlers(t *testing.T) {
+	// Create a user store with one admin (ID 1) and one customer (ID 2) preferring US dollars. Prices are in
+	// euros, which buy 2 dollars.
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "admin@example.com", Role: types.RoleAdmin},
+		2: {ID: 2, Email: "customer@example.com", Role: types.RoleCustomer, Currency: "USD"},
+	}}
+
+	rates, err := currency.NewRatesFile(filepath.Join(t.TempDir(), "rates.json"), "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rates.UpdateRates(types.RateTable{Base: "EUR", Rates: map[string]string{"USD": "2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Register the routes of a handler using an empty store, for the tests that create no products; the others
+	// register a handler with a store of their own.
+	store, index := newMockProductStore(), search.NewInvertedIndex()
+	router := mux.NewRouter()
+	NewHandler(NewIndexedStore(store, index), store, store, index, search.NewSuggester(), &mockQueryLog{}, currency.NewPricer(rates, store), userStore).RegisterRoutes(router)
+
+	// searchIDs searches through the router and returns the IDs of the products found, in order.
+	searchIDs := func(t *testing.T, router *mux.Router, query string) []int {
+		rr := serve(t, router, http.MethodGet, "/products/search?q="+query, nil, 0)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var res struct {
+			Results []types.SearchResult `json:"results"`
+		}
+		json.NewDecoder(rr.Body).Decode(&res)
+
+		ids := []int{}
+		for _, r := range res.Results {
+			ids = append(ids, r.Product.ID)
+		}
+		return ids
+	}
+
+	t.Run("should make created products searchable and keep the index in sync", func(t *testing.T) {
+		store, index := newMockProductStore(), search.NewInvertedIndex()
+		router := mux.NewRouter()
+		NewHandler(NewIndexedStore(store, index), store, store, index, search.NewSuggester(), &mockQueryLog{}, currency.NewPricer(rates, store), userStore).RegisterRoutes(router)
+
+		for _, body := range []string{
+			`{"sku": "TS-RED", "name": "Red cotton shirt", "description": "Soft and light.", "price": {"amount": "19.95", "currency": "EUR"}}`,
+			`{"sku": "JK-BLU", "name": "Blue denim jacket", "description": "Goes well with any shirt.", "price": {"amount": "79", "currency": "EUR"}}`,
+		} {
+			if rr := serve(t, router, http.MethodPost, "/admin/products", []byte(body), 1); rr.Code != http.StatusCreated {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+			}
+		}
+
+		// The product with the term in its name ranks first.
+		if ids := searchIDs(t, router, "shirts"); fmt.Sprint(ids) != "[1 2]" {
+			t.Errorf("expected products [1 2], got %v", ids)
+		}
+
+		// Renaming a product reindexes it.
+		body := []byte(`{"sku": "JK-BLU", "name": "Blue denim coat", "description": "Warm.", "price": {"amount": "79", "currency": "EUR"}}`)
+		if rr := serve(t, router, http.MethodPut, "/admin/products/2", body, 1); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if ids := searchIDs(t, router, "jacket"); len(ids) != 0 {
+			t.Errorf("expected no products, got %v", ids)
+		}
+
+		// Deleting a product removes it from the index.
+		if rr := serve(t, router, http.MethodDelete, "/admin/products/1", nil, 1); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		if ids := searchIDs(t, router, "shirt"); len(ids) != 0 {
+			t.Errorf("expected no products, got %v", ids)
+		}
+	})
+
+	t.Run("should log searches and suggest corrections when nothing is found", func(t *testing.T) {
+		store, index := newMockProductStore(), search.NewInvertedIndex()
+		suggester, queries := search.NewSuggester(), &mockQueryLog{}
+		router := mux.NewRouter()
+		NewHandler(NewIndexedStore(store, index), store, store, index, suggester, queries, currency.NewPricer(rates, store), userStore).RegisterRoutes(router)
+
+		body := []byte(`{"sku": "JK-BLU", "name": "Blue denim jacket", "price": {"amount": "79", "currency": "EUR"}}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/products", body, 1); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		products, _ := store.GetProducts()
+		suggester.Rebuild(products, nil, nil)
+
+		rr := serve(t, router, http.MethodGet, "/products/search?q=Blue%20%20Denin%20Jackit", nil, 0)
+		var res map[string]any
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res["didYouMean"] != nil {
+			t.Errorf("expected no correction when the typos still match, got %v", res["didYouMean"])
+		}
+
+		rr = serve(t, router, http.MethodGet, "/products/search?q=demin%20parka", nil, 0)
+		res = map[string]any{}
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res["didYouMean"] != "denim parka" {
+			t.Errorf("expected the correction %q, got %v", "denim parka", res["didYouMean"])
+		}
+
+		if fmt.Sprint(queries.logged) != "[blue denin jackit:1 demin parka:0]" {
+			t.Errorf("unexpected query log %v", queries.logged)
+		}
+	})
+
+	t.Run("should parse listing filters and continue from the cursor", func(t *testing.T) {
+		store.next = &types.ListingCursor{Sort: types.SortPriceAsc, Value: "19.95", ID: 7}
+
+		rr := serve(t, router, http.MethodGet, "/products?category=shirts,polos&minPrice=10&maxPrice=50&size=M,L&inStock=true&minRating=4&sort=price&limit=2", nil, 0)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		q := store.query
+		if fmt.Sprint(q.Categories) != "[shirts polos]" || *q.MinPrice != types.NewMoney(1000, "EUR") || *q.MaxPrice != types.NewMoney(5000, "EUR") ||
+			fmt.Sprint(q.Attributes) != "map[size:[M L]]" || !q.InStock || q.MinRating != 4 ||
+			q.Sort != types.SortPriceAsc || q.Limit != 2 || q.After != nil {
+			t.Fatalf("unexpected query %+v", q)
+		}
+
+		var res struct {
+			NextCursor string `json:"nextCursor"`
+		}
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res.NextCursor == "" {
+			t.Fatal("expected a next cursor")
+		}
+
+		// The cursor continues the same listing.
+		if rr := serve(t, router, http.MethodGet, "/products?sort=price&cursor="+res.NextCursor, nil, 0); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if after := store.query.After; after == nil || after.Value != "19.95" || after.ID != 7 {
+			t.Fatalf("expected to continue after product 7, got %+v", after)
+		}
+
+		// But not a listing in another order.
+		if rr := serve(t, router, http.MethodGet, "/products?sort=rating&cursor="+res.NextCursor, nil, 0); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should show prices in the selected currency", func(t *testing.T) {
+		store, index := newMockProductStore(), search.NewInvertedIndex()
+		router := mux.NewRouter()
+		NewHandler(NewIndexedStore(store, index), store, store, index, search.NewSuggester(), &mockQueryLog{}, currency.NewPricer(rates, store), userStore).RegisterRoutes(router)
+		store.CreateProduct(types.Product{SKU: "TS", Name: "Shirt", Price: types.NewMoney(1995, "EUR")})
+		store.CreateProduct(types.Product{SKU: "JK", Name: "Jacket", Price: types.NewMoney(7900, "EUR")})
+		store.prices[2] = types.NewMoney(14900, "USD")
+
+		// priceOf returns the price of the product shown for a request with the header, by the user.
+		priceOf := func(t *testing.T, id int, header string, userID int) string {
+			req := fmt.Sprintf("/products/%d", id)
+			rr := serveWithCurrency(t, router, req, header, userID)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+			}
+			var p types.Product
+			json.NewDecoder(rr.Body).Decode(&p)
+			return p.Price.String()
+		}
+
+		// The shirt is converted, the jacket has a price of its own.
+		if price := priceOf(t, 1, "usd", 0); price != "39.90 USD" {
+			t.Errorf("expected the converted price, got %s", price)
+		}
+		if price := priceOf(t, 2, "USD", 0); price != "149.00 USD" {
+			t.Errorf("expected the listed price, got %s", price)
+		}
+
+		// Without a header, the preference of the customer applies, and the header overrides it.
+		if price := priceOf(t, 1, "", 2); price != "39.90 USD" {
+			t.Errorf("expected the preferred currency, got %s", price)
+		}
+		if price := priceOf(t, 1, "EUR", 2); price != "19.95 EUR" {
+			t.Errorf("expected the requested currency, got %s", price)
+		}
+
+		// Price filters are in the selected currency.
+		if rr := serveWithCurrency(t, router, "/products?minPrice=40", "USD", 0); rr.Code != http.StatusOK || *store.query.MinPrice != types.NewMoney(2000, "EUR") {
+			t.Errorf("expected the filter in euros, got %d %v", rr.Code, store.query.MinPrice)
+		}
+
+		if rr := serveWithCurrency(t, router, "/products", "GBP", 0); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for a currency without a rate, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should reject prices in other currencies than the base currency", func(t *testing.T) {
+		body := []byte(`{"sku": "TS-RED", "name": "Red cotton shirt", "price": {"amount": "19.95", "currency": "USD"}}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/products", body, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should reject invalid listing filters", func(t *testing.T) {
+		for _, query := range []string{"minPrice=-1", "minPrice=50&maxPrice=10", "inStock=maybe", "minRating=6", "sort=cheapest", "cursor=nonsense", "limit=0"} {
+			if rr := serve(t, router, http.MethodGet, "/products?"+query, nil, 0); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, query, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should reject products in unknown categories", func(t *testing.T) {
+		store, index := newMockProductStore(), search.NewInvertedIndex()
+		router := mux.NewRouter()
+		NewHandler(NewIndexedStore(store, index), store, store, index, search.NewSuggester(), &mockQueryLog{}, currency.NewPricer(rates, store), userStore).RegisterRoutes(router)
+
+		body := []byte(`{"sku": "TS-RED", "name": "Red cotton shirt", "price": {"amount": "19.95", "currency": "EUR"}, "categoryId": 2}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/products", body, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		body = []byte(`{"sku": "TS-RED", "name": "Red cotton shirt", "price": {"amount": "19.95", "currency": "EUR"}, "categoryId": 1, "attributes": {"size": "M"}}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/products", body, 1); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		if p := store.products[1]; *p.CategoryID != 1 || p.Attributes["size"] != "M" {
+			t.Errorf("expected the category and attributes to be saved, got %+v", p)
+		}
+	})
+
+	t.Run("should validate product attributes against the category schema", func(t *testing.T) {
+		store, index := newMockProductStore(), search.NewInvertedIndex()
+		router := mux.NewRouter()
+		NewHandler(NewIndexedStore(store, index), store, store, index, search.NewSuggester(), &mockQueryLog{}, currency.NewPricer(rates, store), userStore).RegisterRoutes(router)
+
+		for _, body := range []string{
+			`{"name": "screenSize", "label": "Screen size", "type": "number", "unit": "in", "required": true, "filterable": true}`,
+			`{"name": "panel", "label": "Panel", "type": "enum", "options": ["LED", "OLED"], "filterable": true}`,
+			`{"name": "smart", "label": "Smart TV", "type": "boolean"}`,
+		} {
+			if rr := serve(t, router, http.MethodPost, "/admin/categories/1/attributes", []byte(body), 1); rr.Code != http.StatusCreated {
+				t.Fatalf("expected status code %d for %s, got %d: %s", http.StatusCreated, body, rr.Code, rr.Body)
+			}
+		}
+
+		// Invalid definitions are rejected.
+		for _, body := range []string{
+			`{"name": "panel", "label": "Panel", "type": "string"}`,
+			`{"name": "Screen size", "label": "Screen size", "type": "number"}`,
+			`{"name": "sort", "label": "Sort", "type": "string"}`,
+			`{"name": "finish", "label": "Finish", "type": "enum"}`,
+			`{"name": "weight", "label": "Weight", "type": "string", "unit": "kg"}`,
+		} {
+			if rr := serve(t, router, http.MethodPost, "/admin/categories/1/attributes", []byte(body), 1); rr.Code == http.StatusCreated {
+				t.Errorf("expected %s to be rejected", body)
+			}
+		}
+
+		// Values must match their definitions.
+		for _, attributes := range []string{
+			`{"panel": "LED"}`,
+			`{"screenSize": "big"}`,
+			`{"screenSize": "55", "panel": "Plasma"}`,
+			`{"screenSize": "55", "smart": "maybe"}`,
+			`{"screenSize": "55", "color": "black"}`,
+		} {
+			body := []byte(`{"sku": "TV-55", "name": "TV", "price": {"amount": "499", "currency": "EUR"}, "categoryId": 1, "attributes": ` + attributes + `}`)
+			if rr := serve(t, router, http.MethodPost, "/admin/products", body, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, attributes, rr.Code)
+			}
+		}
+
+		body := []byte(`{"sku": "TV-55", "name": "TV", "price": {"amount": "499", "currency": "EUR"}, "categoryId": 1, "attributes": {"screenSize": "55.0", "panel": "OLED", "smart": "1"}}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/products", body, 1); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		if got := fmt.Sprint(store.products[1].Attributes); got != "map[panel:OLED screenSize:55 smart:true]" {
+			t.Errorf("expected normalized attributes, got %s", got)
+		}
+
+		// Filterable attributes become listing filters and facets.
+		if rr := serve(t, router, http.MethodGet, "/products?screenSize=50..65&panel=OLED", nil, 0); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		q := store.query
+		if r := q.Ranges["screenSize"]; r.Min == nil || *r.Min != 50 || *r.Max != 65 || fmt.Sprint(q.Attributes) != "map[panel:[OLED]]" {
+			t.Errorf("unexpected filters %+v", q)
+		}
+		if fmt.Sprint(q.Facets) != "[brand size color screenSize panel]" {
+			t.Errorf("unexpected facets %v", q.Facets)
+		}
+		if rr := serve(t, router, http.MethodGet, "/products?screenSize=large", nil, 0); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should list the variants of a product", func(t *testing.T) {
+		store, index := newMockProductStore(), search.NewInvertedIndex()
+		router := mux.NewRouter()
+		NewHandler(NewIndexedStore(store, index), store, store, index, search.NewSuggester(), &mockQueryLog{}, currency.NewPricer(rates, store), userStore).RegisterRoutes(router)
+		store.CreateProduct(types.Product{SKU: "TS", Name: "Shirt", Price: types.NewMoney(1995, "EUR")})
+		store.CreateVariant(types.ProductVariant{ProductID: 1, SKU: "TS-RED", Name: "Red", Attributes: map[string]string{"color": "red"}})
+
+		rr := serve(t, router, http.MethodGet, "/products/1/variants", nil, 0)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var variants []types.ProductVariant
+		json.NewDecoder(rr.Body).Decode(&variants)
+		if len(variants) != 1 || variants[0].SKU != "TS-RED" || variants[0].Price != nil {
+			t.Errorf("unexpected variants %+v", variants)
+		}
+
+		if rr := serve(t, router, http.MethodGet, "/products/2/variants", nil, 0); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should only let admins manage products", func(t *testing.T) {
+		body := []byte(`{"sku": "TS-RED", "name": "Red cotton shirt", "price": {"amount": "19.95", "currency": "EUR"}}`)
+		if rr := serve(t, router, http.MethodPost, "/admin/products", body, 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+		if len(store.products) != 0 {
+			t.Errorf("expected no products, got %d", len(store.products))
+		}
+	})
+
+	t.Run("should reject invalid searches", func(t *testing.T) {
+		for _, target := range []string{"/products/search", "/products/search?q=shirt&limit=0", "/products/search?q=shirt&limit=1000"} {
+			if rr := serve(t, router, http.MethodGet, target, nil, 0); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, target, rr.Code)
+			}
+		}
+	})
+}
+
+// serve sends a request through the router and returns the recorded response.
+// The request is authenticated as the given user, unless the user ID is 0.
+func serve(t *testing.T, router *mux.Router, method, target string, body []byte, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userID != 0 {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// serveWithCurrency sends a GET request selecting a currency through the router, authenticated as the given
+// user unless the user ID is 0, and returns the recorded response.
+func serveWithCurrency(t *testing.T, router *mux.Router, target, currency string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if currency != "" {
+		req.Header.Set(types.CurrencyHeader, currency)
+	}
+	if userID != 0 {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the auth middleware.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
+
+// mockQueryLog is an in-memory implementation of the QueryLogStore interface, used for testing purposes.
+type mockQueryLog struct {
+	logged []string // The logged queries, as "query:results".
+}
+
+// LogQuery is a mock method that records a query.
+func (m *mockQueryLog) LogQuery(query string, results int) error {
+	m.logged = append(m.logged, fmt.Sprintf("%s:%d", query, results))
+	return nil
+}
+
+// GetPopularQueries is a mock method that returns no queries.
+func (m *mockQueryLog) GetPopularQueries(since time.Time, limit int) ([]types.PopularQuery, error) {
+	return []types.PopularQuery{}, nil
+}
+
+// mockProductStore is an in-memory implementation of the ProductStore, CategoryStore and VariantStore interfaces,
+// and of the price lists read by the pricer, used for testing purposes.
+type mockProductStore struct {
+	types.PriceListStore
+
+	products   map[int]*types.Product       // Products by ID.
+	nextID     int                          // ID of the last product created.
+	categories map[int]*types.Category      // Categories by ID.
+	defs       []*types.AttributeDefinition // Attribute definitions of all categories.
+	variants   []*types.ProductVariant      // Variants of all products.
+	prices     map[int]types.Money          // Prices in US dollars by product ID.
+
+	query types.ProductQuery   // The last listing query.
+	next  *types.ListingCursor // The cursor listings return.
+}
+
+// newMockProductStore returns a mockProductStore without products, and with one category, shirts (ID 1).
+func newMockProductStore() *mockProductStore {
+	return &mockProductStore{
+		products:   map[int]*types.Product{},
+		categories: map[int]*types.Category{1: {ID: 1, Slug: "shirts", Name: "Shirts"}},
+		prices:     map[int]types.Money{},
+	}
+}
+
+// ListProducts is a mock method that records the query and returns all products, with the configured cursor.
+func (m *mockProductStore) ListProducts(q types.ProductQuery) (*types.ProductListing, error) {
+	m.query = q
+	products, _ := m.GetProducts()
+	return &types.ProductListing{Products: products, Total: len(products), Facets: map[string][]types.FacetValue{}, Next: m.next}, nil
+}
+
+// GetCategories is a mock method that returns all categories.
+func (m *mockProductStore) GetCategories() ([]*types.Category, error) {
+	categories := []*types.Category{}
+	for _, c := range m.categories {
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+// GetCategoryByID is a mock method that returns the category with the given ID.
+func (m *mockProductStore) GetCategoryByID(id int) (*types.Category, error) {
+	c, ok := m.categories[id]
+	if !ok {
+		return nil, fmt.Errorf("category not found")
+	}
+	return c, nil
+}
+
+// CreateCategory is a mock method that adds a category.
+func (m *mockProductStore) CreateCategory(c types.Category) (int, error) {
+	c.ID = len(m.categories) + 1
+	m.categories[c.ID] = &c
+	return c.ID, nil
+}
+
+// GetProducts is a mock method that returns copies of all products, ordered by ID.
+func (m *mockProductStore) GetProducts() ([]*types.Product, error) {
+	products := []*types.Product{}
+	for id := 1; id <= m.nextID; id++ {
+		if p, ok := m.products[id]; ok {
+			c := *p
+			products = append(products, &c)
+		}
+	}
+	return products, nil
+}
+
+// GetProductByID is a mock method that returns a copy of the product with the given ID.
+func (m *mockProductStore) GetProductByID(id int) (*types.Product, error) {
+	p, ok := m.products[id]
+	if !ok {
+		return nil, types.ErrProductNotFound
+	}
+
+	c := *p
+	return &c, nil
+}
+
+// GetProductsByIDs is a mock method that returns the products with the given IDs, in order.
+func (m *mockProductStore) GetProductsByIDs(ids []int) ([]*types.Product, error) {
+	products := []*types.Product{}
+	for _, id := range ids {
+		if p, ok := m.products[id]; ok {
+			c := *p
+			products = append(products, &c)
+		}
+	}
+	return products, nil
+}
+
+// GetProductBySKU is a mock method that returns the product with the given SKU.
+func (m *mockProductStore) GetProductBySKU(sku string) (*types.Product, error) {
+	for _, p := range m.products {
+		if p.SKU == sku {
+			return p, nil
+		}
+	}
+	return nil, types.ErrProductNotFound
+}
+
+// GetProductsAfter is a mock method that returns up to limit products with an ID above afterID, ordered by ID.
+func (m *mockProductStore) GetProductsAfter(afterID, limit int) ([]*types.Product, error) {
+	products := []*types.Product{}
+	for id := afterID + 1; id <= m.nextID && len(products) < limit; id++ {
+		if p, ok := m.products[id]; ok {
+			c := *p
+			products = append(products, &c)
+		}
+	}
+	return products, nil
+}
+
+// GetAttributeNames is a mock method that returns the names of the attributes of all products, sorted.
+func (m *mockProductStore) GetAttributeNames() ([]string, error) {
+	seen := map[string]bool{}
+	for _, p := range m.products {
+		for name := range p.Attributes {
+			seen[name] = true
+		}
+	}
+	return sortedKeys(seen), nil
+}
+
+// GetVariants is a mock method that returns the variants of a product.
+func (m *mockProductStore) GetVariants(productID int) ([]*types.ProductVariant, error) {
+	variants := []*types.ProductVariant{}
+	for _, v := range m.variants {
+		if v.ProductID == productID {
+			variants = append(variants, v)
+		}
+	}
+	return variants, nil
+}
+
+// GetVariantBySKU is a mock method that returns the variant with the given SKU.
+func (m *mockProductStore) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	for _, v := range m.variants {
+		if v.SKU == sku {
+			return v, nil
+		}
+	}
+	return nil, types.ErrVariantNotFound
+}
+
+// CreateVariant is a mock method that adds a variant.
+func (m *mockProductStore) CreateVariant(v types.ProductVariant) (int, error) {
+	v.ID = len(m.variants) + 1
+	m.variants = append(m.variants, &v)
+	return v.ID, nil
+}
+
+// UpdateVariant is a mock method that replaces a variant.
+func (m *mockProductStore) UpdateVariant(v types.ProductVariant) error {
+	m.variants[v.ID-1] = &v
+	return nil
+}
+
+// CreateProduct is a mock method that adds a product.
+func (m *mockProductStore) CreateProduct(p types.Product) (int, error) {
+	m.nextID++
+	p.ID = m.nextID
+	m.products[p.ID] = &p
+	return p.ID, nil
+}
+
+// UpdateProduct is a mock method that replaces a product.
+func (m *mockProductStore) UpdateProduct(p types.Product) error {
+	m.products[p.ID] = &p
+	return nil
+}
+
+// DeleteProduct is a mock method that removes a product.
+func (m *mockProductStore) DeleteProduct(id int) error {
+	delete(m.products, id)
+	return nil
+}
+
+// GetAttributeDefinitions is a mock method that returns the attributes defined for a category.
+func (m *mockProductStore) GetAttributeDefinitions(categoryID int) ([]*types.AttributeDefinition, error) {
+	defs := []*types.AttributeDefinition{}
+	for _, d := range m.defs {
+		if d.CategoryID == categoryID {
+			defs = append(defs, d)
+		}
+	}
+	return defs, nil
+}
+
+// GetFilterableAttributes is a mock method that returns the filterable attributes.
+func (m *mockProductStore) GetFilterableAttributes() ([]*types.AttributeDefinition, error) {
+	defs := []*types.AttributeDefinition{}
+	for _, d := range m.defs {
+		if d.Filterable {
+			defs = append(defs, d)
+		}
+	}
+	return defs, nil
+}
+
+// CreateAttributeDefinition is a mock method that adds an attribute definition.
+func (m *mockProductStore) CreateAttributeDefinition(d types.AttributeDefinition) (int, error) {
+	d.ID = len(m.defs) + 1
+	m.defs = append(m.defs, &d)
+	return d.ID, nil
+}
+
+// DeleteAttributeDefinition is a mock method that removes an attribute definition.
+func (m *mockProductStore) DeleteAttributeDefinition(categoryID int, name string) error {
+	m.defs = slices.DeleteFunc(m.defs, func(d *types.AttributeDefinition) bool {
+		return d.CategoryID == categoryID && d.Name == name
+	})
+	return nil
+}
+
+// GetPrices is a mock method that returns the prices of the products with the given IDs in the currency.
+func (m *mockProductStore) GetPrices(productIDs []int, currency string) (map[int]types.Money, error) {
+	prices := map[int]types.Money{}
+	for _, id := range productIDs {
+		if price, ok := m.prices[id]; ok && price.Currency == currency {
+			prices[id] = price
+		}
+	}
+	return prices, nil
+}
@@ -0,0 +1,573 @@
+package product
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	// Import the types package for the product types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// productColumns lists the columns of the products table, in the order scanProduct reads them.
+const productColumns = "p.id, p.sku, p.name, p.description, p.image, p.price, p.categoryId, p.taxClass, p.weight, p.rating, p.reviewCount, p.createdAt"
+
+// Store struct represents the data store of the product catalog.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db       *sql.DB // SQL database connection.
+	currency string  // The base currency, which product and variant prices are stored in.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+// Product and variant prices are stored in the given base currency; prices in other currencies go in price lists.
+func NewStore(db *sql.DB, currency string) *Store {
+	return &Store{db: db, currency: currency}
+}
+
+// GetProducts is a method on the Store struct that retrieves all products, ordered by ID.
+func (s *Store) GetProducts() ([]*types.Product, error) {
+	return s.queryProducts("SELECT " + productColumns + " FROM products p ORDER BY p.id")
+}
+
+// GetProductByID is a method on the Store struct that retrieves a product by ID.
+func (s *Store) GetProductByID(id int) (*types.Product, error) {
+	products, err := s.queryProducts("SELECT "+productColumns+" FROM products p WHERE p.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, types.ErrProductNotFound
+	}
+
+	return products[0], nil
+}
+
+// GetProductsByIDs is a method on the Store struct that retrieves the products with the given IDs,
+// in the order of the IDs.
+func (s *Store) GetProductsByIDs(ids []int) ([]*types.Product, error) {
+	if len(ids) == 0 {
+		return []*types.Product{}, nil
+	}
+
+	found, err := s.queryProducts("SELECT "+productColumns+" FROM products p WHERE p.id IN ("+placeholders(len(ids))+")", intArgs(ids)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Put the products back in the order of the IDs.
+	byID := map[int]*types.Product{}
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+	products := []*types.Product{}
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+
+	return products, nil
+}
+
+// GetProductBySKU is a method on the Store struct that retrieves a product by SKU.
+func (s *Store) GetProductBySKU(sku string) (*types.Product, error) {
+	products, err := s.queryProducts("SELECT "+productColumns+" FROM products p WHERE p.sku = ?", sku)
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, types.ErrProductNotFound
+	}
+
+	return products[0], nil
+}
+
+// GetProductsAfter is a method on the Store struct that retrieves up to limit products with an ID above afterID,
+// ordered by ID.
+func (s *Store) GetProductsAfter(afterID, limit int) ([]*types.Product, error) {
+	return s.queryProducts("SELECT "+productColumns+" FROM products p WHERE p.id > ? ORDER BY p.id LIMIT ?", afterID, limit)
+}
+
+// GetAttributeNames is a method on the Store struct that retrieves the names of all attributes set on products
+// or their variants, sorted.
+func (s *Store) GetAttributeNames() ([]string, error) {
+	rows, err := s.db.Query("SELECT name FROM product_attributes UNION SELECT name FROM variant_attributes ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// CreateProduct is a method on the Store struct that adds a new product with its attributes and returns its ID.
+func (s *Store) CreateProduct(p types.Product) (int, error) {
+	if p.Price.Currency != s.currency {
+		return 0, fmt.Errorf("price must be in %s", s.currency)
+	}
+
+	var id int64
+	err := s.inTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(
+			"INSERT INTO products (sku, name, description, image, price, categoryId, taxClass, weight, rating, reviewCount) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			p.SKU, p.Name, p.Description, p.Image, p.Price.Decimal(), p.CategoryID, taxClass(p), p.Weight, p.Rating, p.ReviewCount,
+		)
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+
+		return saveAttributes(tx, int(id), p.Attributes)
+	})
+
+	return int(id), err
+}
+
+// UpdateProduct is a method on the Store struct that replaces the details and attributes of an existing product.
+func (s *Store) UpdateProduct(p types.Product) error {
+	if p.Price.Currency != s.currency {
+		return fmt.Errorf("price must be in %s", s.currency)
+	}
+
+	return s.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			"UPDATE products SET sku = ?, name = ?, description = ?, image = ?, price = ?, categoryId = ?, taxClass = ?, weight = ?, rating = ?, reviewCount = ? WHERE id = ?",
+			p.SKU, p.Name, p.Description, p.Image, p.Price.Decimal(), p.CategoryID, taxClass(p), p.Weight, p.Rating, p.ReviewCount, p.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		return saveAttributes(tx, p.ID, p.Attributes)
+	})
+}
+
+// DeleteProduct is a method on the Store struct that removes a product and its attributes.
+func (s *Store) DeleteProduct(id int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM product_attributes WHERE productId = ?", id); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec("DELETE FROM products WHERE id = ?", id)
+		return err
+	})
+}
+
+// GetVariants is a method on the Store struct that retrieves the variants of a product, ordered by ID.
+func (s *Store) GetVariants(productID int) ([]*types.ProductVariant, error) {
+	return s.queryVariants("SELECT id, productId, sku, name, price, createdAt FROM product_variants WHERE productId = ? ORDER BY id", productID)
+}
+
+// GetVariantBySKU is a method on the Store struct that retrieves a variant by SKU.
+func (s *Store) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	variants, err := s.queryVariants("SELECT id, productId, sku, name, price, createdAt FROM product_variants WHERE sku = ?", sku)
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, types.ErrVariantNotFound
+	}
+
+	return variants[0], nil
+}
+
+// CreateVariant is a method on the Store struct that adds a new variant with its attributes and returns its ID.
+func (s *Store) CreateVariant(v types.ProductVariant) (int, error) {
+	price, err := s.variantPrice(v)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = s.inTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec("INSERT INTO product_variants (productId, sku, name, price) VALUES (?, ?, ?, ?)", v.ProductID, v.SKU, v.Name, price)
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+
+		return saveVariantAttributes(tx, int(id), v.Attributes)
+	})
+
+	return int(id), err
+}
+
+// UpdateVariant is a method on the Store struct that replaces the details and attributes of an existing variant.
+func (s *Store) UpdateVariant(v types.ProductVariant) error {
+	price, err := s.variantPrice(v)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE product_variants SET sku = ?, name = ?, price = ? WHERE id = ?", v.SKU, v.Name, price, v.ID)
+		if err != nil {
+			return err
+		}
+
+		return saveVariantAttributes(tx, v.ID, v.Attributes)
+	})
+}
+
+// variantPrice returns the price column of a variant: its price as a decimal, or nil if it has none.
+func (s *Store) variantPrice(v types.ProductVariant) (any, error) {
+	if v.Price == nil {
+		return nil, nil
+	}
+	if v.Price.Currency != s.currency {
+		return nil, fmt.Errorf("price must be in %s", s.currency)
+	}
+
+	return v.Price.Decimal(), nil
+}
+
+// queryVariants runs a query selecting the columns of the product_variants table and returns the variants
+// with their attributes.
+func (s *Store) queryVariants(query string, args ...any) ([]*types.ProductVariant, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []*types.ProductVariant{}
+	byID := map[int]*types.ProductVariant{}
+	for rows.Next() {
+		v := &types.ProductVariant{Attributes: map[string]string{}}
+		var price sql.NullString
+		if err := rows.Scan(&v.ID, &v.ProductID, &v.SKU, &v.Name, &price, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		if price.Valid {
+			m, err := types.ParseMoney(price.String, s.currency)
+			if err != nil {
+				return nil, err
+			}
+			v.Price = &m
+		}
+		variants = append(variants, v)
+		byID[v.ID] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return variants, nil
+	}
+
+	ids := make([]int, len(variants))
+	for i, v := range variants {
+		ids[i] = v.ID
+	}
+	attributes, err := s.db.Query("SELECT variantId, name, value FROM variant_attributes WHERE variantId IN ("+placeholders(len(ids))+")", intArgs(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	defer attributes.Close()
+
+	for attributes.Next() {
+		var id int
+		var name, value string
+		if err := attributes.Scan(&id, &name, &value); err != nil {
+			return nil, err
+		}
+		byID[id].Attributes[name] = value
+	}
+
+	return variants, attributes.Err()
+}
+
+// GetCategories is a method on the Store struct that retrieves all categories, ordered by name.
+func (s *Store) GetCategories() ([]*types.Category, error) {
+	rows, err := s.db.Query("SELECT id, slug, name, createdAt FROM categories ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*types.Category{}
+	for rows.Next() {
+		c := new(types.Category)
+		if err := rows.Scan(&c.ID, &c.Slug, &c.Name, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+// GetCategoryByID is a method on the Store struct that retrieves a category by ID.
+func (s *Store) GetCategoryByID(id int) (*types.Category, error) {
+	c := new(types.Category)
+	err := s.db.QueryRow("SELECT id, slug, name, createdAt FROM categories WHERE id = ?", id).Scan(&c.ID, &c.Slug, &c.Name, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("category not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// CreateCategory is a method on the Store struct that adds a new category and returns its ID.
+func (s *Store) CreateCategory(c types.Category) (int, error) {
+	res, err := s.db.Exec("INSERT INTO categories (slug, name) VALUES (?, ?)", c.Slug, c.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// attributeColumns lists the columns of the attribute_definitions table, in the order scanAttributeDefinitions reads them.
+const attributeColumns = "id, categoryId, name, label, type, unit, options, required, filterable, createdAt"
+
+// GetAttributeDefinitions is a method on the Store struct that retrieves the attributes defined for a category.
+func (s *Store) GetAttributeDefinitions(categoryID int) ([]*types.AttributeDefinition, error) {
+	return s.queryAttributeDefinitions("SELECT "+attributeColumns+" FROM attribute_definitions WHERE categoryId = ? ORDER BY id", categoryID)
+}
+
+// GetFilterableAttributes is a method on the Store struct that retrieves the filterable attributes of all categories,
+// keeping only the first definition of each name.
+func (s *Store) GetFilterableAttributes() ([]*types.AttributeDefinition, error) {
+	all, err := s.queryAttributeDefinitions("SELECT " + attributeColumns + " FROM attribute_definitions WHERE filterable ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	defs := []*types.AttributeDefinition{}
+	for _, d := range all {
+		if !seen[d.Name] {
+			seen[d.Name] = true
+			defs = append(defs, d)
+		}
+	}
+
+	return defs, nil
+}
+
+// CreateAttributeDefinition is a method on the Store struct that adds an attribute to a category and returns its ID.
+func (s *Store) CreateAttributeDefinition(d types.AttributeDefinition) (int, error) {
+	options, err := json.Marshal(d.Options)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.db.Exec(
+		"INSERT INTO attribute_definitions (categoryId, name, label, type, unit, options, required, filterable) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		d.CategoryID, d.Name, d.Label, d.Type, d.Unit, options, d.Required, d.Filterable,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// DeleteAttributeDefinition is a method on the Store struct that removes an attribute from a category.
+func (s *Store) DeleteAttributeDefinition(categoryID int, name string) error {
+	_, err := s.db.Exec("DELETE FROM attribute_definitions WHERE categoryId = ? AND name = ?", categoryID, name)
+	return err
+}
+
+// queryAttributeDefinitions runs a query selecting attributeColumns and returns the definitions.
+func (s *Store) queryAttributeDefinitions(query string, args ...any) ([]*types.AttributeDefinition, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := []*types.AttributeDefinition{}
+	for rows.Next() {
+		d := new(types.AttributeDefinition)
+		var options []byte
+		err := rows.Scan(&d.ID, &d.CategoryID, &d.Name, &d.Label, &d.Type, &d.Unit, &options, &d.Required, &d.Filterable, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(options, &d.Options); err != nil {
+			return nil, err
+		}
+		defs = append(defs, d)
+	}
+
+	return defs, rows.Err()
+}
+
+// queryProducts runs a query selecting productColumns and returns the products with their attributes.
+func (s *Store) queryProducts(query string, args ...any) ([]*types.Product, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products, err := s.scanRowsIntoProducts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return products, s.loadAttributes(products)
+}
+
+// loadAttributes fills in the attributes of the products.
+func (s *Store) loadAttributes(products []*types.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	byID := map[int]*types.Product{}
+	ids := make([]int, len(products))
+	for i, p := range products {
+		p.Attributes = map[string]string{}
+		byID[p.ID] = p
+		ids[i] = p.ID
+	}
+
+	rows, err := s.db.Query("SELECT productId, name, value FROM product_attributes WHERE productId IN ("+placeholders(len(ids))+")", intArgs(ids)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name, value string
+		if err := rows.Scan(&id, &name, &value); err != nil {
+			return err
+		}
+		byID[id].Attributes[name] = value
+	}
+
+	return rows.Err()
+}
+
+// inTx runs fn inside a transaction, committing if it succeeds and rolling back if it fails.
+func (s *Store) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// saveAttributes replaces the attributes of a product. It must run inside a transaction.
+func saveAttributes(tx *sql.Tx, productID int, attributes map[string]string) error {
+	if _, err := tx.Exec("DELETE FROM product_attributes WHERE productId = ?", productID); err != nil {
+		return err
+	}
+
+	for name, value := range attributes {
+		_, err := tx.Exec("INSERT INTO product_attributes (productId, name, value) VALUES (?, ?, ?)", productID, name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveVariantAttributes replaces the attributes of a variant. It must run inside a transaction.
+func saveVariantAttributes(tx *sql.Tx, variantID int, attributes map[string]string) error {
+	if _, err := tx.Exec("DELETE FROM variant_attributes WHERE variantId = ?", variantID); err != nil {
+		return err
+	}
+
+	for name, value := range attributes {
+		_, err := tx.Exec("INSERT INTO variant_attributes (variantId, name, value) VALUES (?, ?, ?)", variantID, name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanRowsIntoProducts scans rows selected with productColumns into Products, without their attributes.
+func (s *Store) scanRowsIntoProducts(rows *sql.Rows) ([]*types.Product, error) {
+	products := []*types.Product{}
+	for rows.Next() {
+		p, err := s.scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+// scanProduct scans the current row, selected with productColumns followed by any extra columns, into a Product.
+// The extra columns are scanned into extra. Prices are read as decimal text, so they stay exact.
+func (s *Store) scanProduct(rows *sql.Rows, extra ...any) (*types.Product, error) {
+	p := new(types.Product)
+	var price string
+	var categoryID sql.NullInt64
+
+	dest := append([]any{&p.ID, &p.SKU, &p.Name, &p.Description, &p.Image, &price, &categoryID, &p.TaxClass, &p.Weight, &p.Rating, &p.ReviewCount, &p.CreatedAt}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if p.Price, err = types.ParseMoney(price, s.currency); err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		p.CategoryID = &id
+	}
+
+	return p, nil
+}
+
+// taxClass returns the tax class of a product, which is the standard class unless it has another.
+func taxClass(p types.Product) string {
+	if p.TaxClass == "" {
+		return types.TaxClassStandard
+	}
+	return p.TaxClass
+}
+
+// placeholders returns n comma-separated query placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// intArgs converts IDs to query arguments.
+func intArgs(ids []int) []any {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
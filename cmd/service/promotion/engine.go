@@ -0,0 +1,419 @@
+package promotion
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"time"
+
+	// Import the types package for the store interfaces, carts and the Money type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Reasons coupon codes are rejected for, as shown to customers.
+const (
+	reasonUnknown      = "unknown code"
+	reasonNotStarted   = "not valid yet"
+	reasonEnded        = "expired"
+	reasonConditions   = "the cart does not qualify"
+	reasonUsageLimit   = "no longer available"
+	reasonExclusive    = "cannot be combined with other promotions"
+	reasonNoDiscount   = "nothing in the cart is discounted"
+	reasonDuplicate    = "entered more than once"
+	reasonGuestLimited = "only for signed in customers"
+)
+
+// Engine struct applies promotions to carts. Promotions are applied one at a time, highest priority first and
+// then oldest first, each to what is left of the lines after the ones before, so the result never depends on
+// the order codes were entered in and discounts never exceed the price.
+type Engine struct {
+	store  types.PromotionStore // Interface for promotions, redemptions and customer segments.
+	orders types.OrderStore     // Interface for the orders, to tell first orders.
+	pricer types.Pricer         // Converts amounts of promotions to the currency of the cart.
+	now    func() time.Time     // Returns the current time.
+}
+
+// NewEngine is a constructor function that returns a new Engine instance.
+func NewEngine(store types.PromotionStore, orders types.OrderStore, pricer types.Pricer) *Engine {
+	return &Engine{store: store, orders: orders, pricer: pricer, now: time.Now}
+}
+
+// candidate struct is a promotion considered for a cart, with the code it was entered with.
+type candidate struct {
+	promotion *types.Promotion
+	code      string // The code entered, empty for automatic promotions.
+}
+
+// evaluation struct holds the state of applying promotions to a cart.
+type evaluation struct {
+	*Engine
+	cart      types.Cart
+	remaining []int64 // What is left of every line after the discounts so far, in minor units.
+	pricing   *types.CartPricing
+
+	exclusive bool      // Whether an exclusive promotion was applied.
+	orders    *int      // Number of orders of the customer, loaded when needed.
+	segments  *[]string // Segments of the customer, loaded when needed.
+}
+
+// Evaluate is a method on the Engine struct that applies the automatic promotions and the promotions of the
+// codes of the cart. Codes that cannot be applied are listed with the reason in the pricing.
+func (e *Engine) Evaluate(cart types.Cart) (*types.CartPricing, error) {
+	pricing, err := newPricing(cart)
+	if err != nil {
+		return nil, err
+	}
+	ev := &evaluation{Engine: e, cart: cart, pricing: pricing}
+	for _, line := range ev.pricing.Lines {
+		ev.remaining = append(ev.remaining, line.Subtotal.Amount)
+	}
+
+	candidates, err := ev.candidates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range candidates {
+		reason, err := ev.apply(c)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" && c.code != "" {
+			ev.pricing.RejectedCodes = append(ev.pricing.RejectedCodes, types.RejectedCode{Code: c.code, Reason: reason})
+		}
+	}
+
+	// The discount of every line is what was taken off it.
+	discount := types.NewMoney(0, cart.Currency)
+	for i := range ev.pricing.Lines {
+		line := &ev.pricing.Lines[i]
+		line.Discount = types.NewMoney(line.Subtotal.Amount-ev.remaining[i], cart.Currency)
+		line.Total = types.NewMoney(ev.remaining[i], cart.Currency)
+		discount.Amount += line.Discount.Amount
+	}
+	ev.pricing.Discount = discount
+	ev.pricing.Total = types.NewMoney(ev.pricing.Subtotal.Amount-discount.Amount, cart.Currency)
+
+	return ev.pricing, nil
+}
+
+// newPricing returns the pricing of a cart without discounts, or an error if the amounts overflow.
+func newPricing(cart types.Cart) (*types.CartPricing, error) {
+	zero := types.NewMoney(0, cart.Currency)
+	pricing := &types.CartPricing{
+		Currency:      cart.Currency,
+		Lines:         []types.PricedLine{},
+		Promotions:    []types.AppliedPromotion{},
+		RejectedCodes: []types.RejectedCode{},
+		Subtotal:      zero,
+		Discount:      zero,
+		Total:         zero,
+	}
+
+	for _, line := range cart.Lines {
+		subtotal, err := line.UnitPrice.Mul(int64(line.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		pricing.Lines = append(pricing.Lines, types.PricedLine{
+			SKU:       line.SKU,
+			ProductID: line.ProductID,
+			TaxClass:  line.TaxClass,
+			Weight:    line.Weight * line.Quantity,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			Subtotal:  subtotal,
+			Discount:  zero,
+			Total:     subtotal,
+		})
+		if pricing.Subtotal, err = pricing.Subtotal.Add(subtotal); err != nil {
+			return nil, err
+		}
+	}
+
+	return pricing, nil
+}
+
+// candidates returns the running automatic promotions and the promotions of the codes, in the order they are
+// applied. Codes that do not belong to a running promotion are rejected right away.
+func (ev *evaluation) candidates() ([]candidate, error) {
+	now := ev.now()
+
+	automatic, err := ev.store.GetAutomaticPromotions(now)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []candidate{}
+	for _, p := range automatic {
+		candidates = append(candidates, candidate{promotion: p})
+	}
+
+	seen := map[string]bool{}
+	for _, code := range ev.cart.Codes {
+		if seen[code] {
+			ev.reject(code, reasonDuplicate)
+			continue
+		}
+		seen[code] = true
+
+		p, err := ev.store.GetPromotionByCode(code)
+		switch {
+		case err != nil:
+			ev.reject(code, reasonUnknown)
+		case p.StartsAt != nil && now.Before(*p.StartsAt):
+			ev.reject(code, reasonNotStarted)
+		case p.EndsAt != nil && !now.Before(*p.EndsAt):
+			ev.reject(code, reasonEnded)
+		default:
+			candidates = append(candidates, candidate{promotion: p, code: code})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].promotion, candidates[j].promotion
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.ID < b.ID
+	})
+
+	return candidates, nil
+}
+
+// reject lists a code as rejected for a reason.
+func (ev *evaluation) reject(code, reason string) {
+	ev.pricing.RejectedCodes = append(ev.pricing.RejectedCodes, types.RejectedCode{Code: code, Reason: reason})
+}
+
+// apply applies a promotion to the cart if it qualifies, or returns the reason it does not.
+func (ev *evaluation) apply(c candidate) (string, error) {
+	p := c.promotion
+
+	// An exclusive promotion goes alone: first, or not at all.
+	if ev.exclusive || p.Exclusive && len(ev.pricing.Promotions) > 0 {
+		return reasonExclusive, nil
+	}
+
+	eligible := ev.eligible(p.Conditions)
+	if len(eligible) == 0 {
+		return reasonConditions, nil
+	}
+	ok, err := ev.qualifies(p.Conditions, eligible)
+	if err != nil || !ok {
+		return reasonConditions, err
+	}
+
+	if reason, err := ev.withinLimits(p); reason != "" || err != nil {
+		return reason, err
+	}
+
+	discounts, err := ev.discounts(p.Action, eligible)
+	if err != nil {
+		return "", err
+	}
+
+	total := int64(0)
+	for _, d := range discounts {
+		total += d
+	}
+	freeShipping := p.Action.Type == types.ActionFreeShipping
+	if total == 0 && !freeShipping {
+		return reasonNoDiscount, nil
+	}
+
+	for i, line := range eligible {
+		ev.remaining[line] -= discounts[i]
+	}
+	ev.pricing.Promotions = append(ev.pricing.Promotions, types.AppliedPromotion{
+		PromotionID:  p.ID,
+		Name:         p.Name,
+		Code:         c.code,
+		Discount:     types.NewMoney(total, ev.cart.Currency),
+		FreeShipping: freeShipping,
+	})
+	ev.pricing.FreeShipping = ev.pricing.FreeShipping || freeShipping
+	ev.exclusive = p.Exclusive
+
+	return "", nil
+}
+
+// eligible returns the indexes of the lines the conditions apply to: the listed products and the products in
+// the listed categories, or every line if none are listed.
+func (ev *evaluation) eligible(cond types.PromotionConditions) []int {
+	all := len(cond.ProductIDs) == 0 && len(cond.CategoryIDs) == 0
+
+	lines := []int{}
+	for i, line := range ev.cart.Lines {
+		inCategory := line.CategoryID != nil && slices.Contains(cond.CategoryIDs, *line.CategoryID)
+		if all || inCategory || slices.Contains(cond.ProductIDs, line.ProductID) {
+			lines = append(lines, i)
+		}
+	}
+	return lines
+}
+
+// qualifies returns whether the cart meets the conditions, with the eligible lines.
+func (ev *evaluation) qualifies(cond types.PromotionConditions, eligible []int) (bool, error) {
+	if cond.MinSubtotal != nil {
+		min, err := ev.pricer.Convert(*cond.MinSubtotal, ev.cart.Currency)
+		if err != nil {
+			return false, err
+		}
+
+		subtotal := int64(0)
+		for _, i := range eligible {
+			subtotal += ev.pricing.Lines[i].Subtotal.Amount
+		}
+		if subtotal < min.Amount {
+			return false, nil
+		}
+	}
+
+	// Guests are assumed to place their first order and to be in no segment; checkout is signed in.
+	if cond.FirstOrder && ev.cart.UserID != 0 {
+		if ev.orders == nil {
+			n, err := ev.Engine.orders.CountOrders(ev.cart.UserID)
+			if err != nil {
+				return false, err
+			}
+			ev.orders = &n
+		}
+		if *ev.orders > 0 {
+			return false, nil
+		}
+	}
+
+	if len(cond.Segments) > 0 {
+		if ev.segments == nil {
+			segments := []string{}
+			if ev.cart.UserID != 0 {
+				var err error
+				if segments, err = ev.store.GetSegments(ev.cart.UserID); err != nil {
+					return false, err
+				}
+			}
+			ev.segments = &segments
+		}
+		if !slices.ContainsFunc(cond.Segments, func(s string) bool { return slices.Contains(*ev.segments, s) }) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// withinLimits returns the reason a promotion can no longer be redeemed, if it has reached a usage limit.
+func (ev *evaluation) withinLimits(p *types.Promotion) (string, error) {
+	if p.UsageLimit == 0 && p.PerCustomerLimit == 0 {
+		return "", nil
+	}
+	if p.PerCustomerLimit > 0 && ev.cart.UserID == 0 {
+		return reasonGuestLimited, nil
+	}
+
+	total, byUser, err := ev.store.CountRedemptions(p.ID, ev.cart.UserID)
+	if err != nil {
+		return "", err
+	}
+	if p.UsageLimit > 0 && total >= p.UsageLimit || p.PerCustomerLimit > 0 && byUser >= p.PerCustomerLimit {
+		return reasonUsageLimit, nil
+	}
+
+	return "", nil
+}
+
+// discounts returns the discount of an action on each of the eligible lines, in minor units, never more than
+// what is left of a line.
+func (ev *evaluation) discounts(action types.PromotionAction, eligible []int) ([]int64, error) {
+	remaining := make([]int64, len(eligible))
+	left := int64(0)
+	for i, line := range eligible {
+		remaining[i] = ev.remaining[line]
+		left += remaining[i]
+	}
+
+	switch action.Type {
+	case types.ActionPercentage:
+		// Round the total once and spread it, so the lines add up to exactly the percentage of their sum.
+		total, err := types.NewMoney(left, ev.cart.Currency).MulRat(big.NewRat(int64(action.Percent), 100), types.RoundHalfUp)
+		if err != nil {
+			return nil, err
+		}
+		return spread(total.Amount, remaining)
+
+	case types.ActionFixedAmount:
+		amount, err := ev.pricer.Convert(*action.Amount, ev.cart.Currency)
+		if err != nil {
+			return nil, err
+		}
+		return spread(min(amount.Amount, left), remaining)
+
+	case types.ActionBuyXGetY:
+		return ev.buyXGetY(action, eligible, remaining)
+
+	case types.ActionFreeShipping:
+		return make([]int64, len(eligible)), nil
+	}
+
+	return nil, fmt.Errorf("unknown promotion action %q", action.Type)
+}
+
+// spread splits an amount over the lines in proportion to what is left of them.
+func spread(amount int64, remaining []int64) ([]int64, error) {
+	if amount == 0 || slices.Max(remaining) == 0 {
+		return make([]int64, len(remaining)), nil
+	}
+
+	parts, err := types.NewMoney(amount, "").Allocate(remaining...)
+	if err != nil {
+		return nil, err
+	}
+
+	discounts := make([]int64, len(parts))
+	for i, part := range parts {
+		discounts[i] = part.Amount
+	}
+	return discounts, nil
+}
+
+// buyXGetY returns the discounts of a buy X get Y action: out of every X+Y eligible units, the Y cheapest are
+// discounted by the percentage. Units are ranked by unit price, then by line, so the result is deterministic.
+// The free units are counted per line rather than one by one, so large quantities cost nothing extra.
+func (ev *evaluation) buyXGetY(action types.PromotionAction, eligible []int, remaining []int64) ([]int64, error) {
+	total := 0
+	for _, line := range eligible {
+		total += ev.cart.Lines[line].Quantity
+	}
+	free := total / (action.BuyQuantity + action.GetQuantity) * action.GetQuantity
+
+	// The eligible lines, cheapest first; the sort is stable, so equal prices keep the order of the lines.
+	order := make([]int, len(eligible))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ev.cart.Lines[eligible[order[a]]].UnitPrice.Amount < ev.cart.Lines[eligible[order[b]]].UnitPrice.Amount
+	})
+
+	discounts := make([]int64, len(eligible))
+	for _, i := range order {
+		if free == 0 {
+			break
+		}
+		line := ev.cart.Lines[eligible[i]]
+		units := min(free, line.Quantity)
+		free -= units
+
+		price, err := types.NewMoney(line.UnitPrice.Amount, ev.cart.Currency).Mul(int64(units))
+		if err != nil {
+			return nil, err
+		}
+		d, err := price.MulRat(big.NewRat(int64(action.Percent), 100), types.RoundHalfUp)
+		if err != nil {
+			return nil, err
+		}
+		discounts[i] = min(d.Amount, remaining[i])
+	}
+	return discounts, nil
+}
@@ -0,0 +1,384 @@
+package promotion
+
+import (
+	"fmt"     // Import the fmt package for formatted I/O operations
+	"testing" // Import the testing package to write test cases
+	"time"    // Import the time package for the times promotions run at
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for promotion types
+)
+
+// TestEngine tests applying promotions to carts.
+func TestEngine(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	yesterday, tomorrow := now.AddDate(0, 0, -1), now.AddDate(0, 0, 1)
+	shoes := 7
+
+	// newEngine returns an engine with the promotions at noon on 1 June 2026. User 1 has ordered before, user 2
+	// has not and is in the "vip" segment.
+	newEngine := func(promotions ...*types.Promotion) (*Engine, *mockPromotionStore) {
+		store := &mockPromotionStore{promotions: promotions, segments: map[int][]string{2: {"vip"}}}
+		orders := &mockOrderStore{counts: map[int]int{1: 3}}
+		e := NewEngine(store, orders, &mockPricer{})
+		e.now = func() time.Time { return now }
+		return e, store
+	}
+
+	// cart returns a cart in euros of a shirt (product 1, 20.00) and two pairs of shoes (product 2 in category 7,
+	// 50.00 each), for a user and with codes.
+	cart := func(userID int, codes ...string) types.Cart {
+		return types.Cart{UserID: userID, Currency: "EUR", Codes: codes, Lines: []types.CartLine{
+			{SKU: "SHIRT", ProductID: 1, Quantity: 1, UnitPrice: types.NewMoney(2000, "EUR")},
+			{SKU: "SHOES", ProductID: 2, CategoryID: &shoes, Quantity: 2, UnitPrice: types.NewMoney(5000, "EUR")},
+		}}
+	}
+
+	percent := func(p int) types.PromotionAction {
+		return types.PromotionAction{Type: types.ActionPercentage, Percent: p}
+	}
+	euros := func(cents int64) *types.Money {
+		m := types.NewMoney(cents, "EUR")
+		return &m
+	}
+
+	t.Run("should apply promotions one after another by priority", func(t *testing.T) {
+		e, _ := newEngine(
+			&types.Promotion{ID: 1, Name: "Ten off", Code: "TENOFF", Action: types.PromotionAction{Type: types.ActionFixedAmount, Amount: euros(1000)}},
+			&types.Promotion{ID: 2, Name: "Sale", Priority: 10, Action: percent(10)},
+		)
+
+		pricing, err := e.Evaluate(cart(1, "TENOFF"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 10% off 120.00 first, then 10.00 off the 108.00 left.
+		if pricing.Subtotal != types.NewMoney(12000, "EUR") || pricing.Discount != types.NewMoney(2200, "EUR") || pricing.Total != types.NewMoney(9800, "EUR") {
+			t.Errorf("expected 120.00 less 22.00, got %v less %v", pricing.Subtotal, pricing.Discount)
+		}
+		if len(pricing.Promotions) != 2 || pricing.Promotions[0].PromotionID != 2 || pricing.Promotions[1].Discount != types.NewMoney(1000, "EUR") {
+			t.Errorf("expected the sale and then the code, got %+v", pricing.Promotions)
+		}
+
+		// The discounts are spread over the lines by what is left of them, and add up.
+		if d := pricing.Lines[0].Discount.Amount + pricing.Lines[1].Discount.Amount; d != 2200 || pricing.Lines[0].Discount.Amount != 367 {
+			t.Errorf("expected 3.67 off the shirt and 22.00 in total, got %+v", pricing.Lines)
+		}
+	})
+
+	t.Run("should give the same result whatever order codes are entered in", func(t *testing.T) {
+		e, _ := newEngine(
+			&types.Promotion{ID: 1, Name: "Ten off", Code: "TENOFF", Action: types.PromotionAction{Type: types.ActionFixedAmount, Amount: euros(1000)}},
+			&types.Promotion{ID: 2, Name: "Twenty percent", Code: "TWENTY", Action: percent(20)},
+		)
+
+		a, _ := e.Evaluate(cart(1, "TENOFF", "TWENTY"))
+		b, _ := e.Evaluate(cart(1, "TWENTY", "TENOFF"))
+		if fmt.Sprint(a) != fmt.Sprint(b) {
+			t.Errorf("expected the same pricing, got %+v and %+v", a, b)
+		}
+	})
+
+	t.Run("should not combine exclusive promotions", func(t *testing.T) {
+		e, _ := newEngine(
+			&types.Promotion{ID: 1, Name: "Sale", Priority: 10, Action: percent(10)},
+			&types.Promotion{ID: 2, Name: "Half off", Code: "HALF", Exclusive: true, Action: percent(50)},
+		)
+
+		pricing, _ := e.Evaluate(cart(1, "HALF"))
+		if len(pricing.Promotions) != 1 || len(pricing.RejectedCodes) != 1 || pricing.RejectedCodes[0].Reason != reasonExclusive {
+			t.Errorf("expected the code to be rejected after the sale, got %+v", pricing)
+		}
+
+		// Once it goes first, nothing else is applied, and automatic promotions are skipped silently.
+		e, _ = newEngine(
+			&types.Promotion{ID: 1, Name: "Sale", Action: percent(10)},
+			&types.Promotion{ID: 2, Name: "Half off", Code: "HALF", Priority: 10, Exclusive: true, Action: percent(50)},
+		)
+		pricing, _ = e.Evaluate(cart(1, "HALF"))
+		if len(pricing.Promotions) != 1 || pricing.Promotions[0].PromotionID != 2 || len(pricing.RejectedCodes) != 0 || pricing.Total != types.NewMoney(6000, "EUR") {
+			t.Errorf("expected only the exclusive promotion, got %+v", pricing)
+		}
+	})
+
+	t.Run("should discount the cheapest units of buy X get Y", func(t *testing.T) {
+		e, _ := newEngine(&types.Promotion{ID: 1, Name: "3 for 2", Action: types.PromotionAction{Type: types.ActionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Percent: 100}})
+
+		pricing, _ := e.Evaluate(cart(1))
+		if pricing.Lines[0].Discount != types.NewMoney(2000, "EUR") || pricing.Lines[1].Discount.Amount != 0 {
+			t.Errorf("expected the shirt to be free, got %+v", pricing.Lines)
+		}
+
+		// Two units of the three are not enough.
+		c := cart(1)
+		c.Lines = c.Lines[1:]
+		if pricing, _ := e.Evaluate(c); len(pricing.Promotions) != 0 {
+			t.Errorf("expected no discount for two units, got %+v", pricing.Promotions)
+		}
+	})
+
+	t.Run("should count free units per line for large quantities", func(t *testing.T) {
+		e, _ := newEngine(&types.Promotion{ID: 1, Name: "3 for 2", Action: types.PromotionAction{Type: types.ActionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Percent: 100}})
+
+		// 9,000 shirts and 2 pairs of shoes make 3,000 free units, all shirts.
+		c := cart(1)
+		c.Lines[0].Quantity = 9000
+		pricing, err := e.Evaluate(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pricing.Lines[0].Discount != types.NewMoney(3000*2000, "EUR") || pricing.Lines[1].Discount.Amount != 0 {
+			t.Errorf("expected 3,000 free shirts, got %+v", pricing.Lines)
+		}
+	})
+
+	t.Run("should fail when the subtotal overflows", func(t *testing.T) {
+		e, _ := newEngine()
+
+		c := cart(1)
+		c.Lines[0].UnitPrice = types.NewMoney(1<<62, "EUR")
+		c.Lines[0].Quantity = 4
+		if _, err := e.Evaluate(c); err == nil {
+			t.Error("expected an error for a line that overflows")
+		}
+	})
+
+	t.Run("should only discount qualifying products", func(t *testing.T) {
+		e, _ := newEngine(
+			&types.Promotion{ID: 1, Name: "Shoe sale", Conditions: types.PromotionConditions{CategoryIDs: []int{shoes}}, Action: percent(20)},
+			&types.Promotion{ID: 2, Name: "Shirt deal", Code: "SHIRT", Conditions: types.PromotionConditions{ProductIDs: []int{1}, MinSubtotal: euros(2500)}, Action: percent(50)},
+		)
+
+		pricing, _ := e.Evaluate(cart(1, "SHIRT"))
+		if pricing.Lines[0].Discount.Amount != 0 || pricing.Lines[1].Discount != types.NewMoney(2000, "EUR") {
+			t.Errorf("expected 20%% off the shoes only, got %+v", pricing.Lines)
+		}
+
+		// The minimum subtotal counts the shirt only, at 20.00.
+		if len(pricing.RejectedCodes) != 1 || pricing.RejectedCodes[0].Reason != reasonConditions {
+			t.Errorf("expected the shirt code to be rejected, got %+v", pricing.RejectedCodes)
+		}
+	})
+
+	t.Run("should convert amounts to the currency of the cart", func(t *testing.T) {
+		e, _ := newEngine(&types.Promotion{ID: 1, Name: "Ten off", Code: "TENOFF", Action: types.PromotionAction{Type: types.ActionFixedAmount, Amount: euros(1000)},
+			Conditions: types.PromotionConditions{MinSubtotal: euros(5000)}})
+
+		c := cart(1, "TENOFF")
+		c.Currency = "USD"
+		for i := range c.Lines {
+			c.Lines[i].UnitPrice = types.NewMoney(c.Lines[i].UnitPrice.Amount*2, "USD")
+		}
+
+		pricing, err := e.Evaluate(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pricing.Discount != types.NewMoney(2000, "USD") {
+			t.Errorf("expected 20.00 dollars off, got %v", pricing.Discount)
+		}
+	})
+
+	t.Run("should check who the customer is", func(t *testing.T) {
+		e, _ := newEngine(
+			&types.Promotion{ID: 1, Name: "Welcome", Code: "WELCOME", Conditions: types.PromotionConditions{FirstOrder: true}, Action: percent(10)},
+			&types.Promotion{ID: 2, Name: "VIP", Code: "VIP", Conditions: types.PromotionConditions{Segments: []string{"vip", "staff"}}, Action: percent(10)},
+		)
+
+		if pricing, _ := e.Evaluate(cart(1, "WELCOME", "VIP")); len(pricing.Promotions) != 0 || len(pricing.RejectedCodes) != 2 {
+			t.Errorf("expected both codes to be rejected for user 1, got %+v", pricing)
+		}
+		if pricing, _ := e.Evaluate(cart(2, "WELCOME", "VIP")); len(pricing.Promotions) != 2 {
+			t.Errorf("expected both codes to apply for user 2, got %+v", pricing)
+		}
+	})
+
+	t.Run("should reject codes that cannot be used", func(t *testing.T) {
+		e, store := newEngine(
+			&types.Promotion{ID: 1, Name: "Soon", Code: "SOON", StartsAt: &tomorrow, Action: percent(10)},
+			&types.Promotion{ID: 2, Name: "Gone", Code: "GONE", EndsAt: &yesterday, Action: percent(10)},
+			&types.Promotion{ID: 3, Name: "Once", Code: "ONCE", UsageLimit: 1, Action: percent(10)},
+			&types.Promotion{ID: 4, Name: "Per customer", Code: "MINE", PerCustomerLimit: 1, Action: percent(10)},
+			&types.Promotion{ID: 5, Name: "Shipping", Code: "SHIP", Action: types.PromotionAction{Type: types.ActionFreeShipping}},
+		)
+		store.redemptions = map[int][]int{3: {2}}
+
+		pricing, _ := e.Evaluate(cart(0, "SOON", "GONE", "ONCE", "MINE", "NOPE", "SHIP", "SHIP"))
+		reasons := map[string]string{}
+		for _, r := range pricing.RejectedCodes {
+			reasons[r.Code] += r.Reason
+		}
+		expected := map[string]string{
+			"SOON": reasonNotStarted,
+			"GONE": reasonEnded,
+			"ONCE": reasonUsageLimit,
+			"MINE": reasonGuestLimited,
+			"NOPE": reasonUnknown,
+			"SHIP": reasonDuplicate,
+		}
+		if fmt.Sprint(reasons) != fmt.Sprint(expected) {
+			t.Errorf("expected %v, got %v", expected, reasons)
+		}
+		if !pricing.FreeShipping || pricing.Discount.Amount != 0 {
+			t.Errorf("expected free shipping only, got %+v", pricing)
+		}
+	})
+}
+
+// mockPromotionStore is an in-memory implementation of the PromotionStore interface.
+type mockPromotionStore struct {
+	promotions  []*types.Promotion // Promotions, ordered by ID.
+	redemptions map[int][]int      // IDs of the users who redeemed a promotion, by promotion ID.
+	segments    map[int][]string   // Segments by user ID.
+}
+
+// GetPromotions is a mock method that returns the promotions, newest first.
+func (m *mockPromotionStore) GetPromotions() ([]*types.Promotion, error) {
+	promotions := []*types.Promotion{}
+	for i := len(m.promotions) - 1; i >= 0; i-- {
+		promotions = append(promotions, m.promotions[i])
+	}
+	return promotions, nil
+}
+
+// GetPromotionByID is a mock method that returns a promotion by ID.
+func (m *mockPromotionStore) GetPromotionByID(id int) (*types.Promotion, error) {
+	for _, p := range m.promotions {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("promotion not found")
+}
+
+// GetPromotionByCode is a mock method that returns the promotion of a code.
+func (m *mockPromotionStore) GetPromotionByCode(code string) (*types.Promotion, error) {
+	for _, p := range m.promotions {
+		if p.Code == code {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("promotion not found")
+}
+
+// GetAutomaticPromotions is a mock method that returns the promotions without a code running at the time.
+func (m *mockPromotionStore) GetAutomaticPromotions(at time.Time) ([]*types.Promotion, error) {
+	promotions := []*types.Promotion{}
+	for _, p := range m.promotions {
+		if p.Code == "" && (p.StartsAt == nil || !at.Before(*p.StartsAt)) && (p.EndsAt == nil || at.Before(*p.EndsAt)) {
+			promotions = append(promotions, p)
+		}
+	}
+	return promotions, nil
+}
+
+// CreatePromotion is a mock method that appends a promotion with the next ID.
+func (m *mockPromotionStore) CreatePromotion(p types.Promotion) (int, error) {
+	p.ID = len(m.promotions) + 1
+	p.CreatedAt = time.Now()
+	m.promotions = append(m.promotions, &p)
+	return p.ID, nil
+}
+
+// UpdatePromotion is a mock method that replaces a promotion.
+func (m *mockPromotionStore) UpdatePromotion(p types.Promotion) error {
+	for i := range m.promotions {
+		if m.promotions[i].ID == p.ID {
+			m.promotions[i] = &p
+		}
+	}
+	return nil
+}
+
+// DeletePromotion is a mock method that removes a promotion.
+func (m *mockPromotionStore) DeletePromotion(id int) error {
+	for i, p := range m.promotions {
+		if p.ID == id {
+			m.promotions = append(m.promotions[:i], m.promotions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// CountRedemptions is a mock method that counts the redemptions of a promotion, in total and by the user.
+func (m *mockPromotionStore) CountRedemptions(promotionID, userID int) (total, byUser int, err error) {
+	for _, id := range m.redemptions[promotionID] {
+		total++
+		if id == userID {
+			byUser++
+		}
+	}
+	return total, byUser, nil
+}
+
+// RedeemPromotions is not used by the engine or the handler.
+func (m *mockPromotionStore) RedeemPromotions(userID int, applied []types.AppliedPromotion) ([]int, error) {
+	panic("not implemented")
+}
+
+// SetRedemptionOrder is not used by the engine or the handler.
+func (m *mockPromotionStore) SetRedemptionOrder(redemptionIDs []int, orderID int) error {
+	panic("not implemented")
+}
+
+// ReleaseRedemptions is not used by the engine or the handler.
+func (m *mockPromotionStore) ReleaseRedemptions(redemptionIDs []int) error {
+	panic("not implemented")
+}
+
+// GetSegments is a mock method that returns the segments of a user.
+func (m *mockPromotionStore) GetSegments(userID int) ([]string, error) {
+	return append([]string{}, m.segments[userID]...), nil
+}
+
+// AddSegment is a mock method that adds a user to a segment.
+func (m *mockPromotionStore) AddSegment(userID int, segment string) error {
+	m.segments[userID] = append(m.segments[userID], segment)
+	return nil
+}
+
+// RemoveSegment is a mock method that removes a user from a segment.
+func (m *mockPromotionStore) RemoveSegment(userID int, segment string) error {
+	segments := []string{}
+	for _, s := range m.segments[userID] {
+		if s != segment {
+			segments = append(segments, s)
+		}
+	}
+	m.segments[userID] = segments
+	return nil
+}
+
+// mockOrderStore is an implementation of the parts of the OrderStore interface used by the engine.
+type mockOrderStore struct {
+	types.OrderStore
+	counts map[int]int // Number of orders by user ID.
+}
+
+// CountOrders is a mock method that returns the number of orders of a user.
+func (m *mockOrderStore) CountOrders(userID int) (int, error) {
+	return m.counts[userID], nil
+}
+
+// mockPricer is an implementation of the parts of the Pricer interface used for promotions. The base currency
+// is the euro, which buys 2 dollars.
+type mockPricer struct {
+	types.Pricer
+}
+
+// Base is a mock method that returns the euro.
+func (m *mockPricer) Base() string {
+	return "EUR"
+}
+
+// Convert is a mock method that converts euros to dollars at 2 dollars to the euro.
+func (m *mockPricer) Convert(money types.Money, currency string) (types.Money, error) {
+	switch {
+	case money.Currency == currency:
+		return money, nil
+	case money.Currency == "EUR" && currency == "USD":
+		return types.NewMoney(money.Amount*2, "USD"), nil
+	}
+	return types.Money{}, types.ErrUnsupportedCurrency
+}
@@ -0,0 +1,273 @@
+package promotion
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	// Import the auth package for the authentication middlewares.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle promotion requests.
+type Handler struct {
+	store     types.PromotionStore // Interface for promotions and customer segments.
+	pricer    types.Pricer         // Tells the base currency amounts of promotions are set in.
+	userStore types.UserStore      // Interface for user-related data operations.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.PromotionStore, pricer types.Pricer, userStore types.UserStore) *Handler {
+	return &Handler{store: store, pricer: pricer, userStore: userStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the promotion routes. All of them are for admins.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Manage promotions and coupon codes.
+	router.HandleFunc("/admin/promotions", auth.WithAdminAuth(h.handleGetPromotions, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/promotions", auth.WithAdminAuth(h.handleCreatePromotion, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/promotions/{id:[0-9]+}", auth.WithAdminAuth(h.handleGetPromotion, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/promotions/{id:[0-9]+}", auth.WithAdminAuth(h.handleUpdatePromotion, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/promotions/{id:[0-9]+}", auth.WithAdminAuth(h.handleDeletePromotion, h.userStore)).Methods(http.MethodDelete)
+
+	// Manage the segments customers are in, which promotions can target.
+	router.HandleFunc("/admin/users/{id:[0-9]+}/segments", auth.WithAdminAuth(h.handleGetSegments, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/users/{id:[0-9]+}/segments/{segment:[a-z0-9_-]{1,64}}", auth.WithAdminAuth(h.handleAddSegment, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/users/{id:[0-9]+}/segments/{segment:[a-z0-9_-]{1,64}}", auth.WithAdminAuth(h.handleRemoveSegment, h.userStore)).Methods(http.MethodDelete)
+}
+
+// handleGetPromotions handles GET /admin/promotions.
+func (h *Handler) handleGetPromotions(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.store.GetPromotions()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, promotions)
+}
+
+// handleGetPromotion handles GET /admin/promotions/{id}.
+func (h *Handler) handleGetPromotion(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	p, err := h.store.GetPromotionByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, p)
+}
+
+// handleCreatePromotion handles POST /admin/promotions.
+func (h *Handler) handleCreatePromotion(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.parsePromotion(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.store.GetPromotionByCode(p.Code); p.Code != "" && err == nil {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("code %s is already used", p.Code))
+		return
+	}
+
+	id, err := h.store.CreatePromotion(*p)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetPromotionByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdatePromotion handles PUT /admin/promotions/{id}.
+// The body replaces the promotion; redemptions so far keep counting towards its limits.
+func (h *Handler) handleUpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	existing, err := h.store.GetPromotionByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	p, ok := h.parsePromotion(w, r)
+	if !ok {
+		return
+	}
+
+	if other, err := h.store.GetPromotionByCode(p.Code); p.Code != "" && err == nil && other.ID != id {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("code %s is already used", p.Code))
+		return
+	}
+
+	p.ID = id
+	p.CreatedAt = existing.CreatedAt
+	if err := h.store.UpdatePromotion(*p); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, p)
+}
+
+// handleDeletePromotion handles DELETE /admin/promotions/{id}.
+func (h *Handler) handleDeletePromotion(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.store.GetPromotionByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeletePromotion(id); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetSegments handles GET /admin/users/{id}/segments.
+func (h *Handler) handleGetSegments(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.segmentUser(w, r)
+	if !ok {
+		return
+	}
+
+	segments, err := h.store.GetSegments(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, segments)
+}
+
+// handleAddSegment handles PUT /admin/users/{id}/segments/{segment}.
+func (h *Handler) handleAddSegment(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.segmentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.AddSegment(id, mux.Vars(r)["segment"]); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRemoveSegment handles DELETE /admin/users/{id}/segments/{segment}.
+func (h *Handler) handleRemoveSegment(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.segmentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.RemoveSegment(id, mux.Vars(r)["segment"]); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// segmentUser returns the ID of the user of a segment route, after checking the user exists.
+// On failure it writes the error response and returns false.
+func (h *Handler) segmentUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.userStore.GetUserByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// parsePromotion parses and validates the promotion in the body of a request. Beyond the payload tags, it checks
+// the action has what its type needs, amounts are in the base currency and the promotion ends after it starts.
+// On failure it writes the error response and returns false.
+func (h *Handler) parsePromotion(w http.ResponseWriter, r *http.Request) (*types.Promotion, bool) {
+	var payload types.PromotionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return nil, false
+	}
+	if err := h.check(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	return &types.Promotion{
+		Name:             payload.Name,
+		Code:             strings.ToUpper(payload.Code),
+		Conditions:       payload.Conditions,
+		Action:           payload.Action,
+		Priority:         payload.Priority,
+		Exclusive:        payload.Exclusive,
+		UsageLimit:       payload.UsageLimit,
+		PerCustomerLimit: payload.PerCustomerLimit,
+		StartsAt:         payload.StartsAt,
+		EndsAt:           payload.EndsAt,
+	}, true
+}
+
+// check returns why a promotion payload cannot be saved, or nil if it can.
+func (h *Handler) check(payload types.PromotionPayload) error {
+	if payload.StartsAt != nil && payload.EndsAt != nil && !payload.EndsAt.After(*payload.StartsAt) {
+		return fmt.Errorf("endsAt must be after startsAt")
+	}
+
+	if m := payload.Conditions.MinSubtotal; m != nil && (m.Currency != h.pricer.Base() || m.IsNegative()) {
+		return fmt.Errorf("minSubtotal must be an amount in %s that is not negative", h.pricer.Base())
+	}
+
+	a := payload.Action
+	switch a.Type {
+	case types.ActionPercentage:
+		if a.Percent < 1 || a.Percent > 100 {
+			return fmt.Errorf("percent must be between 1 and 100")
+		}
+	case types.ActionFixedAmount:
+		if a.Amount == nil || a.Amount.Currency != h.pricer.Base() || !a.Amount.IsPositive() {
+			return fmt.Errorf("amount must be a positive amount in %s", h.pricer.Base())
+		}
+	case types.ActionBuyXGetY:
+		if a.BuyQuantity < 1 || a.GetQuantity < 1 {
+			return fmt.Errorf("buyQuantity and getQuantity must be at least 1")
+		}
+		if a.Percent < 1 || a.Percent > 100 {
+			return fmt.Errorf("percent must be between 1 and 100")
+		}
+	case types.ActionFreeShipping:
+	default:
+		return fmt.Errorf("action type must be one of %s, %s, %s or %s",
+			types.ActionPercentage, types.ActionFixedAmount, types.ActionFreeShipping, types.ActionBuyXGetY)
+	}
+
+	return nil
+}
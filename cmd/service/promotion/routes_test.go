@@ -0,0 +1,138 @@
+package promotion
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for promotion types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestPromotionHandlers tests the admin promotion handlers.
+func TestPromotionHandlers(t *testing.T) {
+	// Create an empty promotion store, and a user store with one admin (ID 1) and one customer (ID 2).
+	store := &mockPromotionStore{segments: map[int][]string{}}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleAdmin},
+		2: {ID: 2, Role: types.RoleCustomer},
+	}}
+
+	// Register the routes of a handler using the mock stores.
+	router := mux.NewRouter()
+	NewHandler(store, &mockPricer{}, userStore).RegisterRoutes(router)
+
+	t.Run("should create, update and delete promotions", func(t *testing.T) {
+		body := `{"name": "Ten off", "code": "tenoff", "usageLimit": 100,
+			"conditions": {"minSubtotal": {"amount": "50.00", "currency": "EUR"}},
+			"action": {"type": "fixed_amount", "amount": {"amount": "10.00", "currency": "EUR"}}}`
+		rr := send(t, router, http.MethodPost, "/admin/promotions", body, 1)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		var p types.Promotion
+		json.NewDecoder(rr.Body).Decode(&p)
+		if p.ID != 1 || p.Code != "TENOFF" || *p.Action.Amount != types.NewMoney(1000, "EUR") || *p.Conditions.MinSubtotal != types.NewMoney(5000, "EUR") {
+			t.Errorf("expected the promotion with an upper case code, got %+v", p)
+		}
+
+		if rr := send(t, router, http.MethodPost, "/admin/promotions", body, 1); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d for a used code, got %d", http.StatusConflict, rr.Code)
+		}
+
+		rr = send(t, router, http.MethodPut, "/admin/promotions/1", `{"name": "Sale", "action": {"type": "percentage", "percent": 15}}`, 1)
+		if rr.Code != http.StatusOK || store.promotions[0].Code != "" || store.promotions[0].Action.Percent != 15 {
+			t.Errorf("expected the promotion to be replaced, got %d: %s", rr.Code, rr.Body)
+		}
+
+		if rr := send(t, router, http.MethodDelete, "/admin/promotions/1", "", 1); rr.Code != http.StatusNoContent || len(store.promotions) != 0 {
+			t.Errorf("expected the promotion to be deleted, got %d", rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/admin/promotions/1", "", 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should reject invalid promotions", func(t *testing.T) {
+		for _, body := range []string{
+			`{"action": {"type": "percentage", "percent": 10}}`,
+			`{"name": "Sale", "action": {"type": "half_price"}}`,
+			`{"name": "Sale", "action": {"type": "percentage", "percent": 0}}`,
+			`{"name": "Sale", "action": {"type": "percentage", "percent": 101}}`,
+			`{"name": "Sale", "action": {"type": "fixed_amount"}}`,
+			`{"name": "Sale", "action": {"type": "fixed_amount", "amount": {"amount": "10.00", "currency": "USD"}}}`,
+			`{"name": "Sale", "action": {"type": "buy_x_get_y", "buyQuantity": 2, "percent": 100}}`,
+			`{"name": "Sale", "code": "TEN OFF", "action": {"type": "free_shipping"}}`,
+			`{"name": "Sale", "usageLimit": -1, "action": {"type": "free_shipping"}}`,
+			`{"name": "Sale", "startsAt": "2026-07-01T00:00:00Z", "endsAt": "2026-06-01T00:00:00Z", "action": {"type": "free_shipping"}}`,
+		} {
+			if rr := send(t, router, http.MethodPost, "/admin/promotions", body, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", body, http.StatusBadRequest, rr.Code)
+			}
+		}
+
+		if rr := send(t, router, http.MethodGet, "/admin/promotions", "", 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a customer, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should manage the segments of customers", func(t *testing.T) {
+		if rr := send(t, router, http.MethodPut, "/admin/users/2/segments/vip", "", 1); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+		if rr := send(t, router, http.MethodGet, "/admin/users/2/segments", "", 1); strings.TrimSpace(rr.Body.String()) != `["vip"]` {
+			t.Errorf("expected the segment, got %s", rr.Body)
+		}
+		if rr := send(t, router, http.MethodDelete, "/admin/users/2/segments/vip", "", 1); rr.Code != http.StatusNoContent || len(store.segments[2]) != 0 {
+			t.Errorf("expected the segment to be removed, got %d", rr.Code)
+		}
+		if rr := send(t, router, http.MethodPut, "/admin/users/3/segments/vip", "", 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for an unknown user, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
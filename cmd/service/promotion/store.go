@@ -0,0 +1,296 @@
+package promotion
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	// Import the json package for storing conditions and actions as JSON.
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	// Import the types package for the promotion types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// promotionColumns lists the columns of the promotions table, in the order scanRowIntoPromotion reads them.
+const promotionColumns = "id, name, code, priority, exclusive, usageLimit, perCustomerLimit, startsAt, endsAt, conditions, action, createdAt"
+
+// Store struct represents the data store of promotions, their redemptions and customer segments.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetPromotions is a method on the Store struct that retrieves all promotions, newest first.
+func (s *Store) GetPromotions() ([]*types.Promotion, error) {
+	return s.queryPromotions("SELECT " + promotionColumns + " FROM promotions ORDER BY id DESC")
+}
+
+// GetPromotionByID is a method on the Store struct that retrieves a promotion by ID.
+func (s *Store) GetPromotionByID(id int) (*types.Promotion, error) {
+	return s.getPromotion("SELECT "+promotionColumns+" FROM promotions WHERE id = ?", id)
+}
+
+// GetPromotionByCode is a method on the Store struct that retrieves the promotion of a coupon code.
+func (s *Store) GetPromotionByCode(code string) (*types.Promotion, error) {
+	return s.getPromotion("SELECT "+promotionColumns+" FROM promotions WHERE code = ?", code)
+}
+
+// GetAutomaticPromotions is a method on the Store struct that retrieves the promotions without a code that run
+// at the given time.
+func (s *Store) GetAutomaticPromotions(at time.Time) ([]*types.Promotion, error) {
+	return s.queryPromotions(
+		"SELECT "+promotionColumns+" FROM promotions WHERE code IS NULL AND (startsAt IS NULL OR startsAt <= ?) AND (endsAt IS NULL OR endsAt > ?) ORDER BY id",
+		at, at,
+	)
+}
+
+// CreatePromotion is a method on the Store struct that stores a new promotion and returns its ID.
+func (s *Store) CreatePromotion(p types.Promotion) (int, error) {
+	conditions, action, err := encode(p)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.db.Exec(
+		"INSERT INTO promotions (name, code, priority, exclusive, usageLimit, perCustomerLimit, startsAt, endsAt, conditions, action) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		p.Name, nullCode(p.Code), p.Priority, p.Exclusive, p.UsageLimit, p.PerCustomerLimit, p.StartsAt, p.EndsAt, conditions, action,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// UpdatePromotion is a method on the Store struct that replaces a promotion.
+func (s *Store) UpdatePromotion(p types.Promotion) error {
+	conditions, action, err := encode(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"UPDATE promotions SET name = ?, code = ?, priority = ?, exclusive = ?, usageLimit = ?, perCustomerLimit = ?, startsAt = ?, endsAt = ?, conditions = ?, action = ? WHERE id = ?",
+		p.Name, nullCode(p.Code), p.Priority, p.Exclusive, p.UsageLimit, p.PerCustomerLimit, p.StartsAt, p.EndsAt, conditions, action, p.ID,
+	)
+	return err
+}
+
+// DeletePromotion is a method on the Store struct that removes a promotion. Its redemptions are kept.
+func (s *Store) DeletePromotion(id int) error {
+	_, err := s.db.Exec("DELETE FROM promotions WHERE id = ?", id)
+	return err
+}
+
+// CountRedemptions is a method on the Store struct that returns how often a promotion was redeemed in total,
+// and by the user.
+func (s *Store) CountRedemptions(promotionID, userID int) (total, byUser int, err error) {
+	err = s.db.QueryRow(
+		"SELECT COUNT(*), COALESCE(SUM(userId = ?), 0) FROM promotion_redemptions WHERE promotionId = ?",
+		userID, promotionID,
+	).Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+// RedeemPromotions is a method on the Store struct that records the redemptions of the applied promotions in a
+// single transaction. Each promotion row is locked while its limits are checked, so concurrent checkouts queue
+// up behind each other instead of both taking the last redemption.
+func (s *Store) RedeemPromotions(userID int, applied []types.AppliedPromotion) ([]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := []int{}
+	for _, a := range applied {
+		var usageLimit, perCustomerLimit int
+		err := tx.QueryRow("SELECT usageLimit, perCustomerLimit FROM promotions WHERE id = ? FOR UPDATE", a.PromotionID).
+			Scan(&usageLimit, &perCustomerLimit)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("promotion not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if usageLimit > 0 || perCustomerLimit > 0 {
+			var total, byUser int
+			err := tx.QueryRow(
+				"SELECT COUNT(*), COALESCE(SUM(userId = ?), 0) FROM promotion_redemptions WHERE promotionId = ?",
+				userID, a.PromotionID,
+			).Scan(&total, &byUser)
+			if err != nil {
+				return nil, err
+			}
+			if usageLimit > 0 && total >= usageLimit || perCustomerLimit > 0 && byUser >= perCustomerLimit {
+				return nil, fmt.Errorf("%w: %s", types.ErrPromotionLimit, a.Name)
+			}
+		}
+
+		res, err := tx.Exec(
+			"INSERT INTO promotion_redemptions (promotionId, userId, code, discount, currency) VALUES (?, ?, ?, ?, ?)",
+			a.PromotionID, userID, a.Code, a.Discount.Decimal(), a.Discount.Currency,
+		)
+		if err != nil {
+			return nil, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, int(id))
+	}
+
+	return ids, tx.Commit()
+}
+
+// SetRedemptionOrder is a method on the Store struct that links redemptions to the order they were made for.
+func (s *Store) SetRedemptionOrder(redemptionIDs []int, orderID int) error {
+	if len(redemptionIDs) == 0 {
+		return nil
+	}
+
+	query, args := inClause("UPDATE promotion_redemptions SET orderId = ? WHERE id IN", redemptionIDs)
+	_, err := s.db.Exec(query, append([]any{orderID}, args...)...)
+	return err
+}
+
+// ReleaseRedemptions is a method on the Store struct that removes the redemptions of a failed checkout.
+func (s *Store) ReleaseRedemptions(redemptionIDs []int) error {
+	if len(redemptionIDs) == 0 {
+		return nil
+	}
+
+	query, args := inClause("DELETE FROM promotion_redemptions WHERE id IN", redemptionIDs)
+	_, err := s.db.Exec(query, args...)
+	return err
+}
+
+// GetSegments is a method on the Store struct that retrieves the segments of a customer, sorted.
+func (s *Store) GetSegments(userID int) ([]string, error) {
+	rows, err := s.db.Query("SELECT segment FROM customer_segments WHERE userId = ? ORDER BY segment", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []string{}
+	for rows.Next() {
+		var segment string
+		if err := rows.Scan(&segment); err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, rows.Err()
+}
+
+// AddSegment is a method on the Store struct that adds a customer to a segment. Adding it twice is harmless.
+func (s *Store) AddSegment(userID int, segment string) error {
+	_, err := s.db.Exec("INSERT IGNORE INTO customer_segments (userId, segment) VALUES (?, ?)", userID, segment)
+	return err
+}
+
+// RemoveSegment is a method on the Store struct that removes a customer from a segment.
+func (s *Store) RemoveSegment(userID int, segment string) error {
+	_, err := s.db.Exec("DELETE FROM customer_segments WHERE userId = ? AND segment = ?", userID, segment)
+	return err
+}
+
+// getPromotion runs a query selecting promotionColumns of a single promotion.
+func (s *Store) getPromotion(query string, args ...any) (*types.Promotion, error) {
+	promotions, err := s.queryPromotions(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(promotions) == 0 {
+		return nil, fmt.Errorf("promotion not found")
+	}
+
+	return promotions[0], nil
+}
+
+// queryPromotions runs a query selecting promotionColumns and returns the promotions.
+func (s *Store) queryPromotions(query string, args ...any) ([]*types.Promotion, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []*types.Promotion{}
+	for rows.Next() {
+		p, err := scanRowIntoPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+
+	return promotions, rows.Err()
+}
+
+// scanRowIntoPromotion scans a row selected with promotionColumns into a Promotion.
+func scanRowIntoPromotion(rows *sql.Rows) (*types.Promotion, error) {
+	p := new(types.Promotion)
+	var code sql.NullString
+	var startsAt, endsAt sql.NullTime
+	var conditions, action []byte
+
+	err := rows.Scan(&p.ID, &p.Name, &code, &p.Priority, &p.Exclusive, &p.UsageLimit, &p.PerCustomerLimit,
+		&startsAt, &endsAt, &conditions, &action, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Code = code.String
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	if err := json.Unmarshal(conditions, &p.Conditions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(action, &p.Action); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// encode returns the conditions and action of a promotion as JSON.
+func encode(p types.Promotion) (conditions, action []byte, err error) {
+	if conditions, err = json.Marshal(p.Conditions); err != nil {
+		return nil, nil, err
+	}
+	if action, err = json.Marshal(p.Action); err != nil {
+		return nil, nil, err
+	}
+	return conditions, action, nil
+}
+
+// nullCode stores automatic promotions without a code as NULL, so the unique index allows any number of them.
+func nullCode(code string) sql.NullString {
+	return sql.NullString{String: code, Valid: code != ""}
+}
+
+// inClause appends a placeholder list for the IDs to a query ending in IN.
+func inClause(query string, ids []int) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return query + " (?" + strings.Repeat(", ?", len(ids)-1) + ")", args
+}
@@ -0,0 +1,200 @@
+package restock
+
+import (
+	// Import the crypto/rand and hex packages for generating event IDs.
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	// Import the config package for the default reorder settings.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the types package for the store, mailer and notifier interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Monitor struct checks stock levels against the reorder rules and alerts the ops team when stock runs low.
+type Monitor struct {
+	rules     types.ReorderRuleStore // Interface for the reorder rules.
+	inventory types.InventoryStore   // Interface for inventory data operations.
+	orders    types.OrderStore       // Interface for the orders, which sales velocity is computed from.
+	mailer    types.Mailer           // Interface for emailing alerts.
+	opsEmail  string                 // The address alerts are emailed to, empty to not email them.
+	notifier  types.Notifier         // Interface for sending low-stock events to other systems.
+}
+
+// NewMonitor is a constructor function that returns a new Monitor.
+func NewMonitor(rules types.ReorderRuleStore, inventory types.InventoryStore, orders types.OrderStore, mailer types.Mailer, opsEmail string, notifier types.Notifier) *Monitor {
+	return &Monitor{rules: rules, inventory: inventory, orders: orders, mailer: mailer, opsEmail: opsEmail, notifier: notifier}
+}
+
+// Start is a method on the Monitor struct that starts a goroutine scanning the stock levels every interval.
+func (m *Monitor) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			if _, err := m.Scan(now); err != nil {
+				log.Printf("failed to scan stock levels: %v", err)
+			}
+		}
+	}()
+}
+
+// Scan is a method on the Monitor struct that checks every SKU with a reorder rule, and returns the
+// low-stock events it emitted. A SKU is only alerted about once: the alert is cleared when its stock
+// goes back above the threshold, so the next drop alerts again.
+func (m *Monitor) Scan(now time.Time) ([]types.LowStockEvent, error) {
+	rules, err := m.rules.GetReorderRules()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []types.LowStockEvent{}
+	for _, r := range rules {
+		available, err := m.inventory.AvailableToSell(r.SKU)
+		if err != nil {
+			return events, err
+		}
+
+		low := available <= r.Threshold
+		switch {
+		case low && r.AlertedAt == nil:
+			e := types.LowStockEvent{SKU: r.SKU, Available: available, Threshold: r.Threshold}
+			m.notify(e, now)
+			if err := m.rules.SetAlertedAt(r.SKU, &now); err != nil {
+				return events, err
+			}
+			events = append(events, e)
+		case !low && r.AlertedAt != nil:
+			if err := m.rules.SetAlertedAt(r.SKU, nil); err != nil {
+				return events, err
+			}
+		}
+	}
+
+	// Email the ops team a single digest of the SKUs that ran low.
+	if len(events) > 0 && m.opsEmail != "" {
+		if err := m.mailer.Send(lowStockEmail(m.opsEmail, events)); err != nil {
+			log.Printf("failed to email low-stock alert: %v", err)
+		}
+	}
+
+	return events, nil
+}
+
+// Suggest is a method on the Monitor struct that suggests reorder quantities for every SKU with a reorder
+// rule or sales in the last windowDays days, counting the items of the orders that were not cancelled. SKUs without a
+// rule use the configured lead time and cover.
+// The most urgent SKUs, with the fewest days of stock left, come first.
+func (m *Monitor) Suggest(now time.Time, windowDays int) ([]types.ReorderSuggestion, error) {
+	rules, err := m.rules.GetReorderRules()
+	if err != nil {
+		return nil, err
+	}
+	sales, err := m.orders.GetSalesSince(now.AddDate(0, 0, -windowDays))
+	if err != nil {
+		return nil, err
+	}
+
+	// Combine the SKUs with a rule and the SKUs that sold.
+	byRule := map[string]types.ReorderRule{}
+	for _, r := range rules {
+		byRule[r.SKU] = *r
+	}
+	for sku := range sales {
+		if _, ok := byRule[sku]; !ok {
+			byRule[sku] = types.ReorderRule{
+				SKU:          sku,
+				LeadTimeDays: int(config.Envs.ReorderLeadTimeDays),
+				CoverDays:    int(config.Envs.ReorderCoverDays),
+			}
+		}
+	}
+
+	suggestions := []types.ReorderSuggestion{}
+	for sku, r := range byRule {
+		available, err := m.inventory.AvailableToSell(sku)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggest(r, available, sales[sku], windowDays))
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if (a.DaysOfStock < 0) != (b.DaysOfStock < 0) {
+			return b.DaysOfStock < 0 // SKUs that do not sell go last.
+		}
+		if a.DaysOfStock != b.DaysOfStock {
+			return a.DaysOfStock < b.DaysOfStock
+		}
+		return a.SKU < b.SKU
+	})
+
+	return suggestions, nil
+}
+
+// notify sends a low-stock event to other systems, logging failures so one unreachable endpoint does not stop the scan.
+func (m *Monitor) notify(e types.LowStockEvent, now time.Time) {
+	id, err := newEventID()
+	if err != nil {
+		log.Printf("failed to create low-stock event for %s: %v", e.SKU, err)
+		return
+	}
+
+	err = m.notifier.Notify(types.Event{ID: id, Type: types.EventLowStock, CreatedAt: now.UTC(), Data: e})
+	if err != nil {
+		log.Printf("failed to send low-stock event for %s: %v", e.SKU, err)
+	}
+}
+
+// suggest computes the reorder suggestion of a SKU from the units sold in the last windowDays days.
+// The suggested quantity covers the expected sales during the lead time and the cover period, and brings
+// the stock back above the threshold.
+func suggest(r types.ReorderRule, available, sold, windowDays int) types.ReorderSuggestion {
+	s := types.ReorderSuggestion{SKU: r.SKU, Available: available, Threshold: r.Threshold, DaysOfStock: -1}
+
+	if windowDays > 0 {
+		s.DailyVelocity = float64(sold) / float64(windowDays)
+	}
+	if s.DailyVelocity > 0 {
+		s.DaysOfStock = math.Max(float64(available), 0) / s.DailyVelocity
+	}
+
+	demand := int(math.Ceil(s.DailyVelocity * float64(r.LeadTimeDays+r.CoverDays)))
+	s.SuggestedQuantity = max(demand+r.Threshold-available, 0)
+
+	return s
+}
+
+// lowStockEmail builds the email alerting the ops team about SKUs that ran low.
+func lowStockEmail(to string, events []types.LowStockEvent) types.Email {
+	var b strings.Builder
+	b.WriteString("The following SKUs have reached their reorder threshold:\n\n")
+	for _, e := range events {
+		fmt.Fprintf(&b, "- %s: %d available (threshold %d)\n", e.SKU, e.Available, e.Threshold)
+	}
+	b.WriteString("\nSee the reorder suggestions in the admin API for how much to order.\n")
+
+	return types.Email{
+		To:      to,
+		Subject: fmt.Sprintf("Low stock: %d SKUs need reordering", len(events)),
+		Body:    b.String(),
+	}
+}
+
+// newEventID returns a random event ID.
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
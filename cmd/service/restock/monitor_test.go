@@ -0,0 +1,163 @@
+package restock
+
+import (
+	"strings" // Import the strings package to check the email body
+	"testing" // Import the testing package to write test cases
+	"time"    // Import the time package for scan times
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for reorder types
+)
+
+// TestMonitorScan tests that low stock is alerted about once per drop, by email and webhook.
+func TestMonitorScan(t *testing.T) {
+	rules := &mockRuleStore{rules: []*types.ReorderRule{
+		{SKU: "A", Threshold: 5},
+		{SKU: "B", Threshold: 5},
+	}}
+	inventory := &mockInventoryStore{available: map[string]int{"A": 3, "B": 10}}
+	mailer := &mockMailer{}
+	notifier := &mockNotifier{}
+	monitor := NewMonitor(rules, inventory, &mockOrderStore{}, mailer, "ops@example.com", notifier)
+
+	// A is at its threshold, B is not.
+	events, err := monitor.Scan(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].SKU != "A" || events[0].Available != 3 {
+		t.Fatalf("expected a low-stock event for A, got %+v", events)
+	}
+	if len(notifier.events) != 1 || notifier.events[0].Type != types.EventLowStock || notifier.events[0].ID == "" {
+		t.Errorf("expected one webhook event, got %+v", notifier.events)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "ops@example.com" || !strings.Contains(mailer.sent[0].Body, "- A: 3 available") {
+		t.Errorf("expected an email to the ops team about A, got %+v", mailer.sent)
+	}
+
+	// Stock stays low: no new alert.
+	if events, _ := monitor.Scan(time.Now()); len(events) != 0 {
+		t.Errorf("expected no repeated alert, got %+v", events)
+	}
+
+	// Stock is replenished, then drops again: a new alert.
+	inventory.available["A"] = 20
+	monitor.Scan(time.Now())
+	if rules.rules[0].AlertedAt != nil {
+		t.Error("expected the alert to be cleared after replenishment")
+	}
+	inventory.available["A"] = 1
+	if events, _ := monitor.Scan(time.Now()); len(events) != 1 {
+		t.Errorf("expected a new alert after stock dropped again, got %+v", events)
+	}
+	if len(mailer.sent) != 2 || len(notifier.events) != 2 {
+		t.Errorf("expected two emails and two webhook events, got %d and %d", len(mailer.sent), len(notifier.events))
+	}
+}
+
+// TestMonitorSuggest tests the reorder suggestions computed from recent sales.
+func TestMonitorSuggest(t *testing.T) {
+	rules := &mockRuleStore{rules: []*types.ReorderRule{
+		{SKU: "A", Threshold: 10, LeadTimeDays: 7, CoverDays: 14},
+		{SKU: "C", Threshold: 0, LeadTimeDays: 7, CoverDays: 14},
+	}}
+	inventory := &mockInventoryStore{available: map[string]int{"A": 20, "B": 5, "C": 50}}
+	orders := &mockOrderStore{sales: map[string]int{"A": 60, "B": 30}}
+	monitor := NewMonitor(rules, inventory, orders, &mockMailer{}, "", &mockNotifier{})
+
+	suggestions, err := monitor.Suggest(time.Now(), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// B sells 1 a day with 5 left, A sells 2 a day with 20 left, and C does not sell.
+	expected := []types.ReorderSuggestion{
+		// 1/day over 7+14 days, with the default threshold of 0: 21 - 5.
+		{SKU: "B", Available: 5, Threshold: 0, DailyVelocity: 1, DaysOfStock: 5, SuggestedQuantity: 16},
+		// 2/day over 7+14 days, plus the threshold of 10: 42 + 10 - 20.
+		{SKU: "A", Available: 20, Threshold: 10, DailyVelocity: 2, DaysOfStock: 10, SuggestedQuantity: 32},
+		{SKU: "C", Available: 50, Threshold: 0, DailyVelocity: 0, DaysOfStock: -1, SuggestedQuantity: 0},
+	}
+	if len(suggestions) != len(expected) {
+		t.Fatalf("expected %d suggestions, got %+v", len(expected), suggestions)
+	}
+	for i := range expected {
+		if suggestions[i] != expected[i] {
+			t.Errorf("expected suggestion %+v, got %+v", expected[i], suggestions[i])
+		}
+	}
+}
+
+// mockRuleStore is an in-memory implementation of the ReorderRuleStore interface.
+type mockRuleStore struct {
+	rules []*types.ReorderRule
+}
+
+// GetReorderRules is a mock method that returns the rules.
+func (m *mockRuleStore) GetReorderRules() ([]*types.ReorderRule, error) {
+	return m.rules, nil
+}
+
+// SaveReorderRule is a mock method that appends the rule.
+func (m *mockRuleStore) SaveReorderRule(r types.ReorderRule) error {
+	m.rules = append(m.rules, &r)
+	return nil
+}
+
+// DeleteReorderRule is a mock method that does nothing.
+func (m *mockRuleStore) DeleteReorderRule(sku string) error {
+	return nil
+}
+
+// SetAlertedAt is a mock method that sets the alert time of a rule.
+func (m *mockRuleStore) SetAlertedAt(sku string, at *time.Time) error {
+	for _, r := range m.rules {
+		if r.SKU == sku {
+			r.AlertedAt = at
+		}
+	}
+	return nil
+}
+
+// mockInventoryStore is an implementation of the parts of the InventoryStore interface used by the monitor.
+type mockInventoryStore struct {
+	types.InventoryStore
+	available map[string]int // Quantity available to sell by SKU.
+}
+
+// AvailableToSell is a mock method that returns the available quantity of a SKU.
+func (m *mockInventoryStore) AvailableToSell(sku string) (int, error) {
+	return m.available[sku], nil
+}
+
+// mockOrderStore is an implementation of the parts of the OrderStore interface used by the monitor.
+type mockOrderStore struct {
+	types.OrderStore
+	sales map[string]int // Units ordered in the window by SKU.
+}
+
+// GetSalesSince is a mock method that returns the sales, ignoring the time.
+func (m *mockOrderStore) GetSalesSince(since time.Time) (map[string]int, error) {
+	return m.sales, nil
+}
+
+// mockMailer is an implementation of the Mailer interface that records the emails instead of sending them.
+type mockMailer struct {
+	sent []types.Email
+}
+
+// Send is a mock method that records the email.
+func (m *mockMailer) Send(email types.Email) error {
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+// mockNotifier is an implementation of the Notifier interface that records the events instead of sending them.
+type mockNotifier struct {
+	events []types.Event
+}
+
+// Notify is a mock method that records the event.
+func (m *mockNotifier) Notify(event types.Event) error {
+	m.events = append(m.events, event)
+	return nil
+}
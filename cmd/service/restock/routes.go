@@ -0,0 +1,130 @@
+package restock
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	// Import the config package for the default reorder settings.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the auth package for the admin-only middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// maxWindowDays is the longest sales window the reorder suggestions can be based on.
+const maxWindowDays = 365
+
+// Handler struct groups the methods that handle reorder requests.
+type Handler struct {
+	monitor   *Monitor        // The monitor computing the reorder suggestions.
+	userStore types.UserStore // Interface for user-related data operations, used to authenticate admins.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(monitor *Monitor, userStore types.UserStore) *Handler {
+	return &Handler{monitor: monitor, userStore: userStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the reorder routes.
+// Every route is wrapped in auth.WithAdminAuth, so only admins can reach them.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Manage the reorder rules.
+	router.HandleFunc("/admin/reorder/rules", auth.WithAdminAuth(h.handleGetRules, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/reorder/rules/{sku}", auth.WithAdminAuth(h.handleSaveRule, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/reorder/rules/{sku}", auth.WithAdminAuth(h.handleDeleteRule, h.userStore)).Methods(http.MethodDelete)
+
+	// Report how much of each SKU to reorder.
+	router.HandleFunc("/admin/reorder/suggestions", auth.WithAdminAuth(h.handleGetSuggestions, h.userStore)).Methods(http.MethodGet)
+}
+
+// handleGetRules handles GET /admin/reorder/rules.
+func (h *Handler) handleGetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.monitor.rules.GetReorderRules()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rules)
+}
+
+// handleSaveRule handles PUT /admin/reorder/rules/{sku}.
+// The lead time and cover default to the configured ones when left out.
+func (h *Handler) handleSaveRule(w http.ResponseWriter, r *http.Request) {
+	var payload types.ReorderRulePayload
+
+	// Parse and validate the request body.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	rule := types.ReorderRule{
+		SKU:          mux.Vars(r)["sku"],
+		Threshold:    payload.Threshold,
+		LeadTimeDays: payload.LeadTimeDays,
+		CoverDays:    payload.CoverDays,
+	}
+	if rule.LeadTimeDays == 0 {
+		rule.LeadTimeDays = int(config.Envs.ReorderLeadTimeDays)
+	}
+	if rule.CoverDays == 0 {
+		rule.CoverDays = int(config.Envs.ReorderCoverDays)
+	}
+
+	if err := h.monitor.rules.SaveReorderRule(rule); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rule)
+}
+
+// handleDeleteRule handles DELETE /admin/reorder/rules/{sku}.
+func (h *Handler) handleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	if err := h.monitor.rules.DeleteReorderRule(mux.Vars(r)["sku"]); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetSuggestions handles GET /admin/reorder/suggestions.
+// The days query parameter sets how many days of sales the velocity is computed from.
+func (h *Handler) handleGetSuggestions(w http.ResponseWriter, r *http.Request) {
+	days := int(config.Envs.ReorderSalesWindowDays)
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxWindowDays {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("days must be between 1 and %d", maxWindowDays))
+			return
+		}
+		days = n
+	}
+
+	suggestions, err := h.monitor.Suggest(time.Now(), days)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"windowDays":  days,
+		"suggestions": suggestions,
+	})
+}
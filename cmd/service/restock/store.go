@@ -0,0 +1,70 @@
+package restock
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"time"
+
+	// Import the types package for the reorder rule types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Store struct represents the data store of the reorder rules.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetReorderRules is a method on the Store struct that retrieves all reorder rules, ordered by SKU.
+func (s *Store) GetReorderRules() ([]*types.ReorderRule, error) {
+	rows, err := s.db.Query("SELECT sku, threshold, leadTimeDays, coverDays, alertedAt, updatedAt FROM reorder_rules ORDER BY sku")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*types.ReorderRule{}
+	for rows.Next() {
+		r := new(types.ReorderRule)
+		var alertedAt sql.NullTime
+		if err := rows.Scan(&r.SKU, &r.Threshold, &r.LeadTimeDays, &r.CoverDays, &alertedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if alertedAt.Valid {
+			r.AlertedAt = &alertedAt.Time
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// SaveReorderRule is a method on the Store struct that creates or replaces the reorder rule of a SKU.
+// Changing a rule clears its alert, so a lowered threshold is checked again on the next scan.
+func (s *Store) SaveReorderRule(r types.ReorderRule) error {
+	_, err := s.db.Exec(`
+		INSERT INTO reorder_rules (sku, threshold, leadTimeDays, coverDays) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE threshold = VALUES(threshold), leadTimeDays = VALUES(leadTimeDays), coverDays = VALUES(coverDays), alertedAt = NULL, updatedAt = NOW()`,
+		r.SKU, r.Threshold, r.LeadTimeDays, r.CoverDays,
+	)
+
+	return err
+}
+
+// DeleteReorderRule is a method on the Store struct that removes the reorder rule of a SKU.
+func (s *Store) DeleteReorderRule(sku string) error {
+	_, err := s.db.Exec("DELETE FROM reorder_rules WHERE sku = ?", sku)
+	return err
+}
+
+// SetAlertedAt is a method on the Store struct that records when a low-stock alert was sent for a SKU,
+// or clears it when at is nil.
+func (s *Store) SetAlertedAt(sku string, at *time.Time) error {
+	_, err := s.db.Exec("UPDATE reorder_rules SET alertedAt = ? WHERE sku = ?", at, sku)
+	return err
+}
@@ -0,0 +1,162 @@
+package returns
+
+import (
+	"fmt"
+	"math/big"
+
+	// Import the types package for the order, return and money types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Policies for paying shipping back, set with config.Envs.RefundShipping.
+const (
+	ShippingNever   = "never"    // Shipping is never paid back.
+	ShippingAtFault = "at_fault" // Shipping is paid back when the shop is at fault for every item returned.
+	ShippingAlways  = "always"   // Shipping is paid back with the last items of the order.
+)
+
+// shopAtFault reports whether a reason for returning an item is the fault of the shop rather than of the customer.
+func shopAtFault(reason string) bool {
+	switch reason {
+	case types.ReturnReasonDamaged, types.ReturnReasonDefective, types.ReturnReasonWrongItem, types.ReturnReasonNotAsDescribed:
+		return true
+	}
+	return false
+}
+
+// computeRefund sets the refund of a received return and of its lines, and splits it between the payment provider
+// and store credit. Earlier holds the other received and refunded returns of the order.
+//
+// The items are paid back at what the customer paid for them, so discounts given on them are prorated. Each unit
+// is priced as its share of the item total, counting the units returned earlier, so the refunds of an item
+// returned in parts add up to exactly its total. Shipping is paid back once, with the last items of the order, if
+// the policy allows it. The refund goes back through the payment provider as far as the provider charged the order,
+// and the part gift cards and store credit paid is given as store credit.
+func computeRefund(o *types.Order, ret *types.Return, earlier []*types.Return, policy string) error {
+	items := map[int]types.OrderItem{}
+	for _, item := range o.Items {
+		items[item.ID] = item
+	}
+
+	// Units of each item returned before, and the refunds already made.
+	returned := map[int]int{}
+	refunded := types.NewMoney(0, o.Total.Currency)
+	providerRefunded := types.NewMoney(0, o.Total.Currency)
+	shippingRefunded := false
+	for _, e := range earlier {
+		for _, line := range e.Lines {
+			returned[line.OrderItemID] += line.Quantity
+		}
+		var err error
+		if refunded, err = refunded.Add(e.Refund); err != nil {
+			return err
+		}
+		if providerRefunded, err = providerRefunded.Add(e.ProviderRefund); err != nil {
+			return err
+		}
+		shippingRefunded = shippingRefunded || e.ShippingRefund.IsPositive()
+	}
+
+	total := types.NewMoney(0, o.Total.Currency)
+	atFault := true
+	for i := range ret.Lines {
+		line := &ret.Lines[i]
+		item, ok := items[line.OrderItemID]
+		if !ok {
+			return fmt.Errorf("order item %d is not in order %d", line.OrderItemID, o.ID)
+		}
+
+		before := returned[item.ID]
+		from, err := share(item, before)
+		if err != nil {
+			return err
+		}
+		to, err := share(item, before+line.Quantity)
+		if err != nil {
+			return err
+		}
+		if line.Refund, err = to.Sub(from); err != nil {
+			return err
+		}
+		if total, err = total.Add(line.Refund); err != nil {
+			return err
+		}
+
+		returned[item.ID] += line.Quantity
+		atFault = atFault && shopAtFault(line.Reason)
+	}
+
+	// Shipping is paid back with the return that brings the last units of the order back.
+	ret.ShippingRefund = types.NewMoney(0, o.Total.Currency)
+	allReturned := true
+	for _, item := range o.Items {
+		allReturned = allReturned && returned[item.ID] >= item.Quantity
+	}
+	if allReturned && !shippingRefunded && (policy == ShippingAlways || policy == ShippingAtFault && atFault) {
+		shipping, err := shippingPaid(o)
+		if err != nil {
+			return err
+		}
+		ret.ShippingRefund = shipping
+	}
+
+	var err error
+	if ret.Refund, err = total.Add(ret.ShippingRefund); err != nil {
+		return err
+	}
+
+	// Never pay back more than the order was paid, whatever rounding did.
+	left, err := o.Total.Sub(refunded)
+	if err != nil {
+		return err
+	}
+	ret.Refund = minMoney(ret.Refund, maxMoney(left, types.NewMoney(0, left.Currency)))
+
+	providerLeft, err := o.AmountDue.Sub(providerRefunded)
+	if err != nil {
+		return err
+	}
+	ret.ProviderRefund = minMoney(ret.Refund, maxMoney(providerLeft, types.NewMoney(0, providerLeft.Currency)))
+	ret.StoreCreditRefund, err = ret.Refund.Sub(ret.ProviderRefund)
+
+	return err
+}
+
+// share returns the part of the total of an order item paid for its first n units.
+func share(item types.OrderItem, n int) (types.Money, error) {
+	if n >= item.Quantity {
+		return item.Total, nil
+	}
+
+	return item.Total.MulRat(big.NewRat(int64(n), int64(item.Quantity)), types.RoundHalfUp)
+}
+
+// shippingPaid returns what the customer paid for shipping, with tax as it was due: the part of the total not paid
+// for the items.
+func shippingPaid(o *types.Order) (types.Money, error) {
+	shipping := o.Total
+	for _, item := range o.Items {
+		var err error
+		if shipping, err = shipping.Sub(item.Total); err != nil {
+			return types.Money{}, err
+		}
+	}
+
+	return maxMoney(shipping, types.NewMoney(0, shipping.Currency)), nil
+}
+
+// minMoney returns the smaller of two amounts in the same currency.
+func minMoney(a, b types.Money) types.Money {
+	if a.Amount < b.Amount {
+		return a
+	}
+	return b
+}
+
+// maxMoney returns the larger of two amounts in the same currency.
+func maxMoney(a, b types.Money) types.Money {
+	if a.Amount > b.Amount {
+		return a
+	}
+	return b
+}
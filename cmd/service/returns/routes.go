@@ -0,0 +1,626 @@
+package returns
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the config package for the return window and shipping refund policy.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the auth package for the authentication middlewares.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the shipment package for the tracking pages of carriers.
+	"github.com/FreekAlberti/Ecom/cmd/service/shipment"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle return requests.
+type Handler struct {
+	store     types.ReturnStore     // Interface for returns.
+	orders    types.OrderStore      // Interface for the orders returns belong to.
+	shipments types.ShipmentStore   // Interface for when orders were delivered.
+	inventory types.InventoryStore  // Interface for putting returned items back in stock.
+	allocator types.StockAllocator  // Gives restocked items to orders waiting for them.
+	balances  types.BalanceStore    // Interface for refunds given as store credit.
+	payments  types.PaymentProvider // Interface for refunds paid back to what the order was paid with.
+	userStore types.UserStore       // Interface for user-related data operations.
+	now       func() time.Time      // Returns the current time.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.ReturnStore, orders types.OrderStore, shipments types.ShipmentStore, inventory types.InventoryStore, allocator types.StockAllocator, balances types.BalanceStore, payments types.PaymentProvider, userStore types.UserStore) *Handler {
+	return &Handler{
+		store:     store,
+		orders:    orders,
+		shipments: shipments,
+		inventory: inventory,
+		allocator: allocator,
+		balances:  balances,
+		payments:  payments,
+		userStore: userStore,
+		now:       time.Now,
+	}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the return routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Return items of an order of the user, and tell how they are sent back.
+	router.HandleFunc("/orders/{id:[0-9]+}/returns", auth.WithAuth(h.handleGetMyReturns, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/orders/{id:[0-9]+}/returns", auth.WithAuth(h.handleCreateReturn, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/orders/{id:[0-9]+}/returns/{returnId:[0-9]+}", auth.WithAuth(h.handleGetMyReturn, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/orders/{id:[0-9]+}/returns/{returnId:[0-9]+}/tracking", auth.WithAuth(h.handleSetTracking, h.userStore)).Methods(http.MethodPut)
+
+	// Decide on returns, grade and restock the items that arrive, and pay them back.
+	router.HandleFunc("/admin/returns", auth.WithAdminAuth(h.handleGetReturns, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/returns/{id:[0-9]+}", auth.WithAdminAuth(h.handleGetReturn, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/returns/{id:[0-9]+}/approve", auth.WithAdminAuth(h.handleApprove, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/returns/{id:[0-9]+}/reject", auth.WithAdminAuth(h.handleReject, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/returns/{id:[0-9]+}/receive", auth.WithAdminAuth(h.handleReceive, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/returns/{id:[0-9]+}/refund", auth.WithAdminAuth(h.handleRefund, h.userStore)).Methods(http.MethodPost)
+}
+
+// handleGetMyReturns handles GET /orders/{id}/returns.
+// Orders of other users are reported as not found, so their IDs cannot be probed.
+func (h *Handler) handleGetMyReturns(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.myOrder(w, r)
+	if !ok {
+		return
+	}
+
+	returns, err := h.store.GetReturnsByOrder(o.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, returns)
+}
+
+// handleGetMyReturn handles GET /orders/{id}/returns/{returnId}.
+func (h *Handler) handleGetMyReturn(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.myOrder(w, r)
+	if !ok {
+		return
+	}
+
+	ret, ok := h.myReturn(w, r, o)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// handleCreateReturn handles POST /orders/{id}/returns.
+// Customers return items that shipped, each with a reason, until the return window closes: a number of days after
+// the order was delivered. Support then approves or rejects the return.
+func (h *Handler) handleCreateReturn(w http.ResponseWriter, r *http.Request) {
+	var payload types.ReturnPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	o, ok := h.myOrder(w, r)
+	if !ok {
+		return
+	}
+
+	closes, err := h.windowCloses(o)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if closes != nil && h.now().After(*closes) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("the return window of order %d closed on %s", o.ID, closes.Format(time.DateOnly)))
+		return
+	}
+
+	items := map[int]types.OrderItem{}
+	for _, item := range o.Items {
+		items[item.ID] = item
+	}
+
+	ret := types.Return{
+		OrderID: o.ID,
+		UserID:  o.UserID,
+		Status:  types.ReturnRequested,
+		Note:    strings.TrimSpace(payload.Note),
+	}
+	for _, line := range payload.Lines {
+		item, ok := items[line.OrderItemID]
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order item %d is not in order %d", line.OrderItemID, o.ID))
+			return
+		}
+		if slices.ContainsFunc(ret.Lines, func(l types.ReturnLine) bool { return l.OrderItemID == item.ID }) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order item %d is listed twice", item.ID))
+			return
+		}
+		ret.Lines = append(ret.Lines, types.ReturnLine{
+			OrderItemID: item.ID,
+			SKU:         item.SKU,
+			Quantity:    line.Quantity,
+			Reason:      line.Reason,
+			Comment:     strings.TrimSpace(line.Comment),
+		})
+	}
+
+	returnID, err := h.store.CreateReturn(ret)
+	if errors.Is(err, types.ErrNotReturnable) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetReturnByID(returnID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// handleSetTracking handles PUT /orders/{id}/returns/{returnId}/tracking.
+// Once a return is approved, the customer tells which carrier brings the items back, so support can follow them.
+func (h *Handler) handleSetTracking(w http.ResponseWriter, r *http.Request) {
+	var payload types.ReturnTrackingPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	o, ok := h.myOrder(w, r)
+	if !ok {
+		return
+	}
+	ret, ok := h.myReturn(w, r, o)
+	if !ok {
+		return
+	}
+	if ret.Status != types.ReturnApproved {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("return %d is %s, not approved", ret.ID, ret.Status))
+		return
+	}
+
+	ret.Carrier = strings.ToLower(strings.TrimSpace(payload.Carrier))
+	ret.TrackingNumber = strings.TrimSpace(payload.TrackingNumber)
+	ret.TrackingURL = shipment.TrackingURL(ret.Carrier, ret.TrackingNumber)
+	if ret.Carrier == "" || ret.TrackingNumber == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("carrier and tracking number cannot be empty"))
+		return
+	}
+
+	if err := h.store.SetReturnTracking(ret.ID, ret.Carrier, ret.TrackingNumber, ret.TrackingURL); err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// myOrder returns the order in the URL if it belongs to the authenticated user, and writes a not found error
+// otherwise.
+func (h *Handler) myOrder(w http.ResponseWriter, r *http.Request) (*types.Order, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	o, err := h.orders.GetOrderByID(id)
+	if err != nil || o.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order not found"))
+		return nil, false
+	}
+
+	return o, true
+}
+
+// myReturn returns the return in the URL if it belongs to the order, and writes a not found error otherwise.
+func (h *Handler) myReturn(w http.ResponseWriter, r *http.Request, o *types.Order) (*types.Return, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["returnId"])
+
+	ret, err := h.store.GetReturnByID(id)
+	if err != nil || ret.OrderID != o.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("return not found"))
+		return nil, false
+	}
+
+	return ret, true
+}
+
+// windowCloses returns when the items of an order can no longer be returned: config.Envs.ReturnWindowDays after
+// its last package was delivered. Orders that were not delivered yet return nil, since their window has not
+// started.
+func (h *Handler) windowCloses(o *types.Order) (*time.Time, error) {
+	if o.Status != types.OrderDelivered {
+		return nil, nil
+	}
+
+	shipments, err := h.shipments.GetShipmentsByOrder(o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var delivered *time.Time
+	for _, sh := range shipments {
+		if sh.DeliveredAt != nil && (delivered == nil || sh.DeliveredAt.After(*delivered)) {
+			delivered = sh.DeliveredAt
+		}
+	}
+	if delivered == nil {
+		return nil, nil
+	}
+
+	closes := delivered.AddDate(0, 0, int(config.Envs.ReturnWindowDays))
+	return &closes, nil
+}
+
+// handleGetReturns handles GET /admin/returns.
+// The status query parameter, such as ?status=requested, lists the returns waiting on support.
+func (h *Handler) handleGetReturns(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	statuses := []string{types.ReturnRequested, types.ReturnApproved, types.ReturnRejected, types.ReturnReceived,
+		types.ReturnRestocking, types.ReturnRefunding, types.ReturnRefunded}
+	if status != "" && !slices.Contains(statuses, status) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown status %q", status))
+		return
+	}
+
+	returns, err := h.store.GetReturns(status)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, returns)
+}
+
+// handleGetReturn handles GET /admin/returns/{id}.
+func (h *Handler) handleGetReturn(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	ret, err := h.store.GetReturnByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// handleApprove handles POST /admin/returns/{id}/approve.
+func (h *Handler) handleApprove(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, types.ReturnApproved)
+}
+
+// handleReject handles POST /admin/returns/{id}/reject.
+// A note telling the customer why is required. The items of a rejected return can be returned again.
+func (h *Handler) handleReject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, types.ReturnRejected)
+}
+
+// decide moves a requested return to approved or rejected.
+func (h *Handler) decide(w http.ResponseWriter, r *http.Request, status string) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ReturnDecisionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+	note := strings.TrimSpace(payload.Note)
+	if status == types.ReturnRejected && note == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("a note is required to reject a return"))
+		return
+	}
+
+	ret, err := h.store.GetReturnByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if ret.Status != types.ReturnRequested {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("return %d is already %s", ret.ID, ret.Status))
+		return
+	}
+
+	now := h.now().UTC()
+	ret.Status = status
+	ret.SupportNote = note
+	ret.DecidedAt = &now
+	if err := h.store.UpdateReturn(*ret, types.ReturnRequested); err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// handleReceive handles POST /admin/returns/{id}/receive.
+// Every line of an approved return is graded when its items arrive, and the return is marked received. Items that
+// are new or opened then go back in stock in the warehouse given, and to orders waiting for them; damaged items do
+// not. The refund is then worked out and made. When restocking or the payment provider fails, the return stays
+// received and both can be retried.
+func (h *Handler) handleReceive(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ReceiveReturnPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	ret, err := h.store.GetReturnByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if ret.Status != types.ReturnApproved {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("return %d is %s, not approved", ret.ID, ret.Status))
+		return
+	}
+
+	// Grade every line, once.
+	conditions := map[int]string{}
+	for _, line := range payload.Lines {
+		if _, ok := conditions[line.OrderItemID]; ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order item %d is listed twice", line.OrderItemID))
+			return
+		}
+		conditions[line.OrderItemID] = line.Condition
+	}
+	restock := false
+	for i := range ret.Lines {
+		condition, ok := conditions[ret.Lines[i].OrderItemID]
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order item %d is not graded", ret.Lines[i].OrderItemID))
+			return
+		}
+		delete(conditions, ret.Lines[i].OrderItemID)
+		ret.Lines[i].Condition = condition
+		restock = restock || condition != types.ConditionDamaged
+	}
+	for itemID := range conditions {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order item %d is not in return %d", itemID, ret.ID))
+		return
+	}
+
+	if restock {
+		ok, err := h.warehouseExists(payload.WarehouseID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("warehouse %d not found", payload.WarehouseID))
+			return
+		}
+		ret.WarehouseID = &payload.WarehouseID
+	}
+
+	o, err := h.orders.GetOrderByID(ret.OrderID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Only one request claims the return from approved, with the order locked while its refund is worked out, so
+	// its items are restocked and refunded once and the returns of the order refund no more than was paid.
+	now := h.now().UTC()
+	ret.Status = types.ReturnRestocking
+	ret.ReceivedAt = &now
+	err = h.store.ReceiveReturn(ret, func(others []*types.Return) error {
+		return computeRefund(o, ret, earlierReturns(ret, others), config.Envs.RefundShipping)
+	})
+	if err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	h.restockAndRefund(w, o, ret, auth.GetUserIDFromContext(r.Context()))
+}
+
+// handleRefund handles POST /admin/returns/{id}/refund.
+// It retries the restocking and refund of a received return whose restocking or refund failed. Lines already
+// restocked and parts of the refund already made are not done again.
+func (h *Handler) handleRefund(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	ret, err := h.store.GetReturnByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if ret.Status != types.ReturnReceived {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("return %d is %s, not received", ret.ID, ret.Status))
+		return
+	}
+
+	o, err := h.orders.GetOrderByID(ret.OrderID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Claim the return, so a retry never runs alongside another one.
+	ret.Status = types.ReturnRestocking
+	if err := h.store.UpdateReturn(*ret, types.ReturnReceived); err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	h.restockAndRefund(w, o, ret, auth.GetUserIDFromContext(r.Context()))
+}
+
+// restockAndRefund restocks and then refunds a return claimed as restocking, and writes the response. The return
+// is claimed as refunding before the refund, and put back to received if either step fails, so it can be retried.
+func (h *Handler) restockAndRefund(w http.ResponseWriter, o *types.Order, ret *types.Return, actorID int) {
+	if err := h.restock(ret, actorID); err != nil {
+		h.release(ret, types.ReturnRestocking)
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("restocking of return %d failed: %v", ret.ID, err))
+		return
+	}
+
+	ret.Status = types.ReturnRefunding
+	if err := h.store.UpdateReturn(*ret, types.ReturnRestocking); err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+	if err := h.refund(o, ret); err != nil {
+		h.release(ret, types.ReturnRefunding)
+		utils.WriteError(w, http.StatusBadGateway, fmt.Errorf("refund of return %d failed: %v", ret.ID, err))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// release puts a return whose restocking or refund failed back to received, keeping what was done.
+func (h *Handler) release(ret *types.Return, status string) {
+	ret.Status = types.ReturnReceived
+	if err := h.store.UpdateReturn(*ret, status); err != nil {
+		log.Printf("failed to release return %d: %v", ret.ID, err)
+	}
+}
+
+// restock puts the items of a return claimed as restocking that are not damaged back in stock in its warehouse, and gives them
+// to orders waiting for them. Each line is saved as soon as it is restocked, so a retry after a failure never
+// restocks it twice.
+func (h *Handler) restock(ret *types.Return, actorID int) error {
+	for i := range ret.Lines {
+		line := &ret.Lines[i]
+		if line.Restocked || line.Condition == types.ConditionDamaged {
+			continue
+		}
+
+		err := h.inventory.RecordMovement(types.StockMovement{
+			WarehouseID: *ret.WarehouseID,
+			SKU:         line.SKU,
+			Type:        types.MovementReturn,
+			Quantity:    line.Quantity,
+			Reference:   fmt.Sprintf("return:%d", ret.ID),
+			ActorID:     actorID,
+		})
+		if err != nil {
+			return err
+		}
+		line.Restocked = true
+		if err := h.store.UpdateReturn(*ret, types.ReturnRestocking); err != nil {
+			return err
+		}
+
+		if _, err := h.allocator.AllocateStock(line.SKU); err != nil {
+			log.Printf("failed to allocate returned stock of %s: %v", line.SKU, err)
+		}
+	}
+
+	return nil
+}
+
+// refund pays a return claimed as refunding back through the payment provider and as store credit, and marks it refunded.
+// Each part is saved as soon as it is made, so a retry after a failure never makes it twice.
+func (h *Handler) refund(o *types.Order, ret *types.Return) error {
+	reference := fmt.Sprintf("return:%d", ret.ID)
+
+	if ret.ProviderRefund.IsPositive() && ret.PaymentRefundID == "" {
+		refundID, err := h.payments.Refund(types.PaymentRefund{OrderID: o.ID, Amount: ret.ProviderRefund, Reference: reference})
+		if err != nil {
+			return err
+		}
+		ret.PaymentRefundID = refundID
+		if err := h.store.UpdateReturn(*ret, types.ReturnRefunding); err != nil {
+			return err
+		}
+	}
+
+	if ret.StoreCreditRefund.IsPositive() && ret.StoreCreditEntry == nil {
+		entryID, err := h.balances.AddStoreCredit(types.BalanceEntry{
+			UserID:  &o.UserID,
+			OrderID: &o.ID,
+			Amount:  ret.StoreCreditRefund,
+			Reason:  types.EntryRefund,
+			Note:    reference,
+		})
+		if err != nil {
+			return err
+		}
+		ret.StoreCreditEntry = &entryID
+		if err := h.store.UpdateReturn(*ret, types.ReturnRefunding); err != nil {
+			return err
+		}
+	}
+
+	now := h.now().UTC()
+	ret.Status = types.ReturnRefunded
+	ret.RefundedAt = &now
+
+	return h.store.UpdateReturn(*ret, types.ReturnRefunding)
+}
+
+// writeUpdateError writes the error of saving a return: a conflict if another request changed it first.
+func writeUpdateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, types.ErrReturnChanged) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	utils.WriteError(w, http.StatusInternalServerError, err)
+}
+
+// earlierReturns returns the returns of the order of a return whose items arrived before its own.
+func earlierReturns(ret *types.Return, others []*types.Return) []*types.Return {
+	earlier := []*types.Return{}
+	for _, other := range others {
+		switch other.Status {
+		case types.ReturnReceived, types.ReturnRestocking, types.ReturnRefunding, types.ReturnRefunded:
+			if other.ID != ret.ID {
+				earlier = append(earlier, other)
+			}
+		}
+	}
+
+	return earlier
+}
+
+// warehouseExists reports whether a warehouse exists.
+func (h *Handler) warehouseExists(id int) (bool, error) {
+	warehouses, err := h.inventory.GetWarehouses()
+	if err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(warehouses, func(wh *types.Warehouse) bool { return wh.ID == id }), nil
+}
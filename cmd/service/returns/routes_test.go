@@ -0,0 +1,695 @@
+package returns
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for delivery dates and the return window
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret and return policies
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for return types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestReturnHandlers tests returning items, from the request of the customer to their refund.
+func TestReturnHandlers(t *testing.T) {
+	delivered := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Create the stores the returns are checked against. Order 1 of Jane (ID 2) holds 3 shirts, 30.00 after a
+	// discount, and a mug of 10.00; shipping was 5.00. A gift card paid 15.00 and the payment provider charged the
+	// other 30.00. There is an admin (ID 1) and another customer (ID 3). Each test returns items into an empty
+	// return store, a week after the order was delivered.
+	orders := &mockOrderStore{orders: map[int]*types.Order{
+		1: {
+			ID: 1, UserID: 2, Status: types.OrderDelivered,
+			Items: []types.OrderItem{
+				{ID: 11, OrderID: 1, SKU: "SHIRT", Quantity: 3, Total: types.NewMoney(3000, "EUR")},
+				{ID: 12, OrderID: 1, SKU: "MUG", Quantity: 1, Total: types.NewMoney(1000, "EUR")},
+			},
+			Total:          types.NewMoney(4500, "EUR"),
+			GiftCardAmount: types.NewMoney(1500, "EUR"),
+			AmountDue:      types.NewMoney(3000, "EUR"),
+		},
+	}}
+	shipments := &mockShipmentStore{shipments: []*types.Shipment{
+		{ID: 1, OrderID: 1, Status: types.ShipmentDelivered, DeliveredAt: &delivered},
+	}}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleAdmin},
+		2: {ID: 2, Role: types.RoleCustomer},
+		3: {ID: 3, Role: types.RoleCustomer},
+	}}
+	weekLater := func() time.Time { return delivered.AddDate(0, 0, 7) }
+
+	t.Run("should return, restock and refund items", func(t *testing.T) {
+		inventory, allocator, balances, payments := &mockInventoryStore{}, &mockAllocator{}, &mockBalanceStore{}, &mockPaymentProvider{}
+		h := NewHandler(&mockReturnStore{orders: orders}, orders, shipments, inventory, allocator, balances, payments, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		rr := send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 1, "reason": "no_longer_needed"}]}`, 2)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		var ret types.Return
+		json.NewDecoder(rr.Body).Decode(&ret)
+		if ret.ID != 1 || ret.Status != types.ReturnRequested || len(ret.Lines) != 1 || ret.Lines[0].SKU != "SHIRT" {
+			t.Errorf("expected a requested return of a shirt, got %+v", ret)
+		}
+
+		if rr := send(t, router, http.MethodPut, "/orders/1/returns/1/tracking", `{"carrier": "UPS", "trackingNumber": "1Z999"}`, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d adding tracking before approval, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/reject", `{}`, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d rejecting without a note, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/approve", `{"note": "Print the label in the email"}`, 1); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/approve", `{}`, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d approving twice, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr = send(t, router, http.MethodPut, "/orders/1/returns/1/tracking", `{"carrier": "UPS", "trackingNumber": "1Z999"}`, 2)
+		json.NewDecoder(rr.Body).Decode(&ret)
+		if rr.Code != http.StatusOK || ret.TrackingURL != "https://www.ups.com/track?tracknum=1Z999" {
+			t.Errorf("expected the package sent back tracked at UPS, got %d: %+v", rr.Code, ret)
+		}
+
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/receive", `{"warehouseId": 9, "lines": [{"orderItemId": 11, "condition": "opened"}]}`, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d restocking in an unknown warehouse, got %d", http.StatusBadRequest, rr.Code)
+		}
+		rr = send(t, router, http.MethodPost, "/admin/returns/1/receive", `{"warehouseId": 1, "lines": [{"orderItemId": 11, "condition": "opened"}]}`, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		json.NewDecoder(rr.Body).Decode(&ret)
+		if ret.Status != types.ReturnRefunded || ret.Refund.Decimal() != "10.00" || ret.ProviderRefund.Decimal() != "10.00" || !ret.Lines[0].Restocked {
+			t.Errorf("expected a shirt restocked and 10.00 refunded, got %+v", ret)
+		}
+		if len(inventory.movements) != 1 || inventory.movements[0].Type != types.MovementReturn || inventory.movements[0].Reference != "return:1" || inventory.movements[0].ActorID != 1 {
+			t.Errorf("expected a return movement recorded by the admin, got %+v", inventory.movements)
+		}
+		if len(allocator.skus) != 1 || allocator.skus[0] != "SHIRT" {
+			t.Errorf("expected the shirt to be given to waiting orders, got %v", allocator.skus)
+		}
+		if len(payments.refunds) != 1 || payments.refunds[0].Reference != "return:1" || len(balances.entries) != 0 {
+			t.Errorf("expected a single refund through the provider, got %+v and %+v", payments.refunds, balances.entries)
+		}
+	})
+
+	t.Run("should not save a return another request changed first", func(t *testing.T) {
+		store, inventory, balances, payments := &mockReturnStore{orders: orders}, &mockInventoryStore{}, &mockBalanceStore{}, &mockPaymentProvider{}
+		h := NewHandler(store, orders, shipments, inventory, &mockAllocator{}, balances, payments, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 1, "reason": "no_longer_needed"}]}`, 2)
+		send(t, router, http.MethodPost, "/admin/returns/1/approve", `{}`, 1)
+
+		// The customer reads the approved return just before support receives it, and adds tracking after.
+		approved := copyReturn(store.returns[0])
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/receive", `{"warehouseId": 1, "lines": [{"orderItemId": 11, "condition": "new"}]}`, 1); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		store.stale = map[int]*types.Return{1: approved}
+		if rr := send(t, router, http.MethodPut, "/orders/1/returns/1/tracking", `{"carrier": "UPS", "trackingNumber": "1Z999"}`, 2); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if ret := store.returns[0]; ret.Status != types.ReturnRefunded || ret.Carrier != "" {
+			t.Errorf("expected the return to stay refunded, got %+v", ret)
+		}
+
+		// Receiving it again from the stale copy neither restocks nor refunds twice.
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/receive", `{"warehouseId": 1, "lines": [{"orderItemId": 11, "condition": "new"}]}`, 1); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if len(inventory.movements) != 1 || len(payments.refunds) != 1 || len(balances.entries) != 0 {
+			t.Errorf("expected a single restock and refund, got %+v, %+v and %+v", inventory.movements, payments.refunds, balances.entries)
+		}
+	})
+
+	t.Run("should only return what shipped and was not returned yet", func(t *testing.T) {
+		h := NewHandler(&mockReturnStore{orders: orders}, orders, shipments, &mockInventoryStore{}, &mockAllocator{}, &mockBalanceStore{}, &mockPaymentProvider{}, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		if rr := send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 2, "reason": "defective"}]}`, 2); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		if rr := send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 2, "reason": "defective"}]}`, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d returning a shirt twice, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		send(t, router, http.MethodPost, "/admin/returns/1/reject", `{"note": "Worn"}`, 1)
+		if rr := send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 2, "reason": "defective"}]}`, 2); rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d returning rejected items again, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		if rr := send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 99, "quantity": 1, "reason": "other"}]}`, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d returning an item of another order, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 12, "quantity": 1, "reason": "bored"}]}`, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an unknown reason, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 12, "quantity": 1, "reason": "other"}]}`, 3); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d returning items of another customer, got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/orders/1/returns/1", "", 3); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d reading a return of another customer, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should refuse returns once the window closed", func(t *testing.T) {
+		h := NewHandler(&mockReturnStore{orders: orders}, orders, shipments, &mockInventoryStore{}, &mockAllocator{}, &mockBalanceStore{}, &mockPaymentProvider{}, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		config.Envs.ReturnWindowDays = 5
+		defer func() { config.Envs.ReturnWindowDays = 30 }()
+
+		rr := send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 12, "quantity": 1, "reason": "other"}]}`, 2)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "closed on 2024-05-06") {
+			t.Errorf("expected the window to be closed, got %d: %s", rr.Code, rr.Body)
+		}
+	})
+
+	t.Run("should not restock damaged items", func(t *testing.T) {
+		inventory := &mockInventoryStore{}
+		h := NewHandler(&mockReturnStore{orders: orders}, orders, shipments, inventory, &mockAllocator{}, &mockBalanceStore{}, &mockPaymentProvider{}, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 1, "reason": "damaged"}, {"orderItemId": 12, "quantity": 1, "reason": "damaged"}]}`, 2)
+		send(t, router, http.MethodPost, "/admin/returns/1/approve", `{}`, 1)
+
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/receive", `{"lines": [{"orderItemId": 11, "condition": "damaged"}]}`, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d leaving a line ungraded, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr := send(t, router, http.MethodPost, "/admin/returns/1/receive", `{"lines": [{"orderItemId": 11, "condition": "damaged"}, {"orderItemId": 12, "condition": "damaged"}]}`, 1)
+		var ret types.Return
+		json.NewDecoder(rr.Body).Decode(&ret)
+		if rr.Code != http.StatusOK || ret.WarehouseID != nil || ret.Refund.Decimal() != "20.00" {
+			t.Errorf("expected 20.00 refunded without a warehouse, got %d: %+v", rr.Code, ret)
+		}
+		if len(inventory.movements) != 0 {
+			t.Errorf("expected nothing restocked, got %+v", inventory.movements)
+		}
+	})
+
+	t.Run("should retry a refund the payment provider failed", func(t *testing.T) {
+		store, inventory, balances, payments := &mockReturnStore{orders: orders}, &mockInventoryStore{}, &mockBalanceStore{}, &mockPaymentProvider{}
+		h := NewHandler(store, orders, shipments, inventory, &mockAllocator{}, balances, payments, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 3, "reason": "wrong_item"}, {"orderItemId": 12, "quantity": 1, "reason": "wrong_item"}]}`, 2)
+		send(t, router, http.MethodPost, "/admin/returns/1/approve", `{}`, 1)
+
+		payments.fail = true
+		rr := send(t, router, http.MethodPost, "/admin/returns/1/receive", `{"warehouseId": 1, "lines": [{"orderItemId": 11, "condition": "new"}, {"orderItemId": 12, "condition": "new"}]}`, 1)
+		if rr.Code != http.StatusBadGateway {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusBadGateway, rr.Code, rr.Body)
+		}
+		ret := store.returns[0]
+		if ret.Status != types.ReturnReceived || ret.ShippingRefund.Decimal() != "5.00" || ret.ProviderRefund.Decimal() != "30.00" || ret.StoreCreditRefund.Decimal() != "15.00" {
+			t.Errorf("expected 45.00 to refund, 15.00 of it as store credit, got %+v", ret)
+		}
+		if len(balances.entries) != 0 {
+			t.Errorf("expected no store credit before the provider refunded, got %+v", balances.entries)
+		}
+
+		payments.fail = false
+		rr = send(t, router, http.MethodPost, "/admin/returns/1/refund", "", 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		ret = store.returns[0]
+		if ret.Status != types.ReturnRefunded || ret.PaymentRefundID != "return:1" || ret.StoreCreditEntry == nil {
+			t.Errorf("expected the return to be refunded, got %+v", ret)
+		}
+		if len(balances.entries) != 1 || balances.entries[0].Amount.Decimal() != "15.00" || *balances.entries[0].UserID != 2 {
+			t.Errorf("expected 15.00 store credit for Jane, got %+v", balances.entries)
+		}
+		if len(inventory.movements) != 2 {
+			t.Errorf("expected the items to be restocked once, got %+v", inventory.movements)
+		}
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/refund", "", 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d refunding twice, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should retry restocking that failed halfway", func(t *testing.T) {
+		store, inventory, payments := &mockReturnStore{orders: orders}, &mockInventoryStore{}, &mockPaymentProvider{}
+		h := NewHandler(store, orders, shipments, inventory, &mockAllocator{}, &mockBalanceStore{}, payments, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 1, "reason": "other"}, {"orderItemId": 12, "quantity": 1, "reason": "other"}]}`, 2)
+		send(t, router, http.MethodPost, "/admin/returns/1/approve", `{}`, 1)
+
+		inventory.fail = "MUG"
+		rr := send(t, router, http.MethodPost, "/admin/returns/1/receive", `{"warehouseId": 1, "lines": [{"orderItemId": 11, "condition": "new"}, {"orderItemId": 12, "condition": "new"}]}`, 1)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusInternalServerError, rr.Code, rr.Body)
+		}
+		if ret := store.returns[0]; ret.Status != types.ReturnReceived || ret.Lines[0].Restocked == ret.Lines[1].Restocked || len(payments.refunds) != 0 {
+			t.Errorf("expected a received return with one line restocked and nothing refunded, got %+v", ret)
+		}
+
+		inventory.fail = ""
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/refund", "", 1); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if ret := store.returns[0]; ret.Status != types.ReturnRefunded || len(inventory.movements) != 2 || len(payments.refunds) != 1 {
+			t.Errorf("expected both lines restocked once and the return refunded, got %+v and %+v", ret, inventory.movements)
+		}
+	})
+
+	t.Run("should not refund a return whose receipt is still in progress", func(t *testing.T) {
+		store, inventory, balances, payments := &mockReturnStore{orders: orders}, &mockInventoryStore{}, &mockBalanceStore{}, &mockPaymentProvider{}
+		h := NewHandler(store, orders, shipments, inventory, &mockAllocator{}, balances, payments, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 1, "reason": "other"}]}`, 2)
+		send(t, router, http.MethodPost, "/admin/returns/1/approve", `{}`, 1)
+
+		// The receiving request claimed the return and is restocking it, and then refunding it.
+		for _, status := range []string{types.ReturnRestocking, types.ReturnRefunding} {
+			store.returns[0].Status = status
+			if rr := send(t, router, http.MethodPost, "/admin/returns/1/refund", "", 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d while %s, got %d", http.StatusBadRequest, status, rr.Code)
+			}
+		}
+		if len(inventory.movements) != 0 || len(payments.refunds) != 0 || len(balances.entries) != 0 {
+			t.Errorf("expected nothing restocked or refunded, got %+v, %+v and %+v", inventory.movements, payments.refunds, balances.entries)
+		}
+
+		// A retry that read the return before another retry claimed it is refused.
+		store.returns[0].Status = types.ReturnReceived
+		store.stale = map[int]*types.Return{1: copyReturn(store.returns[0])}
+		store.returns[0].Status = types.ReturnRestocking
+		if rr := send(t, router, http.MethodPost, "/admin/returns/1/refund", "", 1); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if len(inventory.movements) != 0 || len(payments.refunds) != 0 {
+			t.Errorf("expected nothing restocked or refunded, got %+v and %+v", inventory.movements, payments.refunds)
+		}
+	})
+
+	t.Run("should list returns by status", func(t *testing.T) {
+		h := NewHandler(&mockReturnStore{orders: orders}, orders, shipments, &mockInventoryStore{}, &mockAllocator{}, &mockBalanceStore{}, &mockPaymentProvider{}, userStore)
+		h.now = weekLater
+
+		router := mux.NewRouter()
+		h.RegisterRoutes(router)
+
+		send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 11, "quantity": 1, "reason": "other"}]}`, 2)
+		send(t, router, http.MethodPost, "/orders/1/returns", `{"lines": [{"orderItemId": 12, "quantity": 1, "reason": "other"}]}`, 2)
+		send(t, router, http.MethodPost, "/admin/returns/2/approve", `{}`, 1)
+
+		rr := send(t, router, http.MethodGet, "/admin/returns?status=requested", "", 1)
+		var returns []types.Return
+		json.NewDecoder(rr.Body).Decode(&returns)
+		if rr.Code != http.StatusOK || len(returns) != 1 || returns[0].ID != 1 {
+			t.Errorf("expected the requested return, got %d: %+v", rr.Code, returns)
+		}
+		if rr := send(t, router, http.MethodGet, "/admin/returns?status=lost", "", 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an unknown status, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/admin/returns", "", 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a customer, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+}
+
+// TestComputeRefund tests the refund of returned items, shipping and how it is paid back.
+func TestComputeRefund(t *testing.T) {
+	eur := func(amount int64) types.Money { return types.NewMoney(amount, "EUR") }
+
+	// The order holds 3 shirts of 10.00 after discounts and a mug of 5.00; shipping was 4.95. Store credit paid
+	// 10.00 of the total.
+	o := &types.Order{
+		ID: 1,
+		Items: []types.OrderItem{
+			{ID: 11, Quantity: 3, Total: eur(1000)},
+			{ID: 12, Quantity: 1, Total: eur(500)},
+		},
+		Total:             eur(1995),
+		StoreCreditAmount: eur(1000),
+		AmountDue:         eur(995),
+	}
+	line := func(itemID, quantity int, reason string) types.ReturnLine {
+		return types.ReturnLine{OrderItemID: itemID, Quantity: quantity, Reason: reason}
+	}
+
+	t.Run("should prorate items returned in parts", func(t *testing.T) {
+		var earlier []*types.Return
+		var refunds []string
+		for range 3 {
+			ret := &types.Return{Lines: []types.ReturnLine{line(11, 1, types.ReturnReasonNoLongerNeeded)}}
+			if err := computeRefund(o, ret, earlier, ShippingAlways); err != nil {
+				t.Fatal(err)
+			}
+			refunds = append(refunds, ret.Refund.Decimal())
+			earlier = append(earlier, ret)
+		}
+		if strings.Join(refunds, " ") != "3.33 3.34 3.33" {
+			t.Errorf("expected the shirts to add up to 10.00, got %v", refunds)
+		}
+	})
+
+	cases := []struct {
+		name     string
+		policy   string
+		lines    []types.ReturnLine
+		shipping string
+	}{
+		{"everything, shop at fault", ShippingAtFault, []types.ReturnLine{line(11, 3, types.ReturnReasonDefective), line(12, 1, types.ReturnReasonDamaged)}, "4.95"},
+		{"everything, customer changed their mind", ShippingAtFault, []types.ReturnLine{line(11, 3, types.ReturnReasonDefective), line(12, 1, types.ReturnReasonNoLongerNeeded)}, "0.00"},
+		{"everything, always paid back", ShippingAlways, []types.ReturnLine{line(11, 3, types.ReturnReasonOther), line(12, 1, types.ReturnReasonOther)}, "4.95"},
+		{"everything, never paid back", ShippingNever, []types.ReturnLine{line(11, 3, types.ReturnReasonDefective), line(12, 1, types.ReturnReasonDefective)}, "0.00"},
+		{"part of the order", ShippingAlways, []types.ReturnLine{line(11, 3, types.ReturnReasonDefective)}, "0.00"},
+	}
+	for _, c := range cases {
+		ret := &types.Return{Lines: c.lines}
+		if err := computeRefund(o, ret, nil, c.policy); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if ret.ShippingRefund.Decimal() != c.shipping {
+			t.Errorf("%s: expected %s shipping refunded, got %s", c.name, c.shipping, ret.ShippingRefund.Decimal())
+		}
+	}
+
+	t.Run("should pay back through the provider first, and the rest as store credit", func(t *testing.T) {
+		first := &types.Return{Lines: []types.ReturnLine{line(11, 3, types.ReturnReasonDefective)}}
+		if err := computeRefund(o, first, nil, ShippingAtFault); err != nil {
+			t.Fatal(err)
+		}
+		if first.ProviderRefund.Decimal() != "9.95" || first.StoreCreditRefund.Decimal() != "0.05" {
+			t.Errorf("expected 9.95 through the provider and 0.05 as store credit, got %+v", first)
+		}
+
+		second := &types.Return{Lines: []types.ReturnLine{line(12, 1, types.ReturnReasonDefective)}}
+		if err := computeRefund(o, second, []*types.Return{first}, ShippingAtFault); err != nil {
+			t.Fatal(err)
+		}
+		if second.Refund.Decimal() != "9.95" || !second.ProviderRefund.IsZero() || second.StoreCreditRefund.Decimal() != "9.95" {
+			t.Errorf("expected the mug and shipping paid back as store credit, got %+v", second)
+		}
+	})
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockReturnStore is an in-memory implementation of the ReturnStore interface. Every item of an order counts as
+// shipped.
+type mockReturnStore struct {
+	orders  *mockOrderStore       // The orders, to check what can be returned.
+	returns []*types.Return       // Returns, where the ID is the index plus one.
+	stale   map[int]*types.Return // Copies returned instead of the stored returns, as read by a request that raced another.
+}
+
+// CreateReturn is a mock method that appends the return, failing if it returns more than is left of an item.
+func (m *mockReturnStore) CreateReturn(ret types.Return) (int, error) {
+	o, ok := m.orders.orders[ret.OrderID]
+	if !ok {
+		return 0, fmt.Errorf("order not found")
+	}
+
+	for _, line := range ret.Lines {
+		left := 0
+		for _, item := range o.Items {
+			if item.ID == line.OrderItemID {
+				left = item.Quantity
+			}
+		}
+		for _, r := range m.returns {
+			for _, l := range r.Lines {
+				if l.OrderItemID == line.OrderItemID && r.Status != types.ReturnRejected {
+					left -= l.Quantity
+				}
+			}
+		}
+		if line.Quantity > left {
+			return 0, types.ErrNotReturnable
+		}
+	}
+
+	ret.ID = len(m.returns) + 1
+	ret.CreatedAt = time.Now()
+	m.returns = append(m.returns, &ret)
+	return ret.ID, nil
+}
+
+// GetReturnByID is a mock method that returns a copy of the return with the given ID, or of its stale copy.
+func (m *mockReturnStore) GetReturnByID(id int) (*types.Return, error) {
+	if ret, ok := m.stale[id]; ok {
+		return copyReturn(ret), nil
+	}
+	if id < 1 || id > len(m.returns) {
+		return nil, fmt.Errorf("return not found")
+	}
+
+	return copyReturn(m.returns[id-1]), nil
+}
+
+// GetReturnsByOrder is a mock method that returns copies of the returns of an order.
+func (m *mockReturnStore) GetReturnsByOrder(orderID int) ([]*types.Return, error) {
+	returns := []*types.Return{}
+	for _, ret := range m.returns {
+		if ret.OrderID == orderID {
+			returns = append(returns, copyReturn(ret))
+		}
+	}
+	return returns, nil
+}
+
+// GetReturns is a mock method that returns copies of the returns with a status, or of all returns.
+func (m *mockReturnStore) GetReturns(status string) ([]*types.Return, error) {
+	returns := []*types.Return{}
+	for _, ret := range m.returns {
+		if status == "" || ret.Status == status {
+			returns = append(returns, copyReturn(ret))
+		}
+	}
+	return returns, nil
+}
+
+// UpdateReturn is a mock method that replaces the stored return, keeping its tracking, if it has the given status.
+func (m *mockReturnStore) UpdateReturn(ret types.Return, status string) error {
+	if ret.ID < 1 || ret.ID > len(m.returns) {
+		return fmt.Errorf("return not found")
+	}
+	stored := m.returns[ret.ID-1]
+	if stored.Status != status {
+		return types.ErrReturnChanged
+	}
+
+	ret.Carrier, ret.TrackingNumber, ret.TrackingURL = stored.Carrier, stored.TrackingNumber, stored.TrackingURL
+	m.returns[ret.ID-1] = copyReturn(&ret)
+	return nil
+}
+
+// ReceiveReturn is a mock method that works out the refund against copies of the other returns of the order, and
+// replaces the stored return if it is approved.
+func (m *mockReturnStore) ReceiveReturn(ret *types.Return, refund func(others []*types.Return) error) error {
+	if ret.ID < 1 || ret.ID > len(m.returns) {
+		return fmt.Errorf("return not found")
+	}
+	if m.returns[ret.ID-1].Status != types.ReturnApproved {
+		return types.ErrReturnChanged
+	}
+
+	others := []*types.Return{}
+	for _, other := range m.returns {
+		if other.OrderID == ret.OrderID && other.ID != ret.ID {
+			others = append(others, copyReturn(other))
+		}
+	}
+	if err := refund(others); err != nil {
+		return err
+	}
+
+	return m.UpdateReturn(*ret, types.ReturnApproved)
+}
+
+// SetReturnTracking is a mock method that sets the tracking of a stored approved return.
+func (m *mockReturnStore) SetReturnTracking(id int, carrier, trackingNumber, trackingURL string) error {
+	if id < 1 || id > len(m.returns) {
+		return fmt.Errorf("return not found")
+	}
+	stored := m.returns[id-1]
+	if stored.Status != types.ReturnApproved {
+		return types.ErrReturnChanged
+	}
+
+	stored.Carrier, stored.TrackingNumber, stored.TrackingURL = carrier, trackingNumber, trackingURL
+	return nil
+}
+
+// copyReturn returns a copy of a return that shares no lines with it.
+func copyReturn(ret *types.Return) *types.Return {
+	c := *ret
+	c.Lines = append([]types.ReturnLine{}, ret.Lines...)
+	return &c
+}
+
+// mockOrderStore is an in-memory implementation of the parts of the OrderStore interface used by the handler.
+type mockOrderStore struct {
+	types.OrderStore
+	orders map[int]*types.Order // Orders by ID.
+}
+
+// GetOrderByID is a mock method that returns a copy of the order with the given ID.
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	c := *o
+	return &c, nil
+}
+
+// mockShipmentStore is an in-memory implementation of the parts of the ShipmentStore interface used by the
+// handler.
+type mockShipmentStore struct {
+	types.ShipmentStore
+	shipments []*types.Shipment // The shipments.
+}
+
+// GetShipmentsByOrder is a mock method that returns the shipments of an order.
+func (m *mockShipmentStore) GetShipmentsByOrder(orderID int) ([]*types.Shipment, error) {
+	shipments := []*types.Shipment{}
+	for _, sh := range m.shipments {
+		if sh.OrderID == orderID {
+			shipments = append(shipments, sh)
+		}
+	}
+	return shipments, nil
+}
+
+// mockInventoryStore is an in-memory implementation of the parts of the InventoryStore interface used by the
+// handler. There is one warehouse, with ID 1.
+type mockInventoryStore struct {
+	types.InventoryStore
+	movements []types.StockMovement // The movements recorded.
+	fail      string                // SKU whose movements fail, empty for none.
+}
+
+// GetWarehouses is a mock method that returns the warehouse.
+func (m *mockInventoryStore) GetWarehouses() ([]*types.Warehouse, error) {
+	return []*types.Warehouse{{ID: 1, Code: "AMS1", Active: true}}, nil
+}
+
+// RecordMovement is a mock method that records the movement, unless it is of the failing SKU.
+func (m *mockInventoryStore) RecordMovement(movement types.StockMovement) error {
+	if movement.SKU == m.fail {
+		return fmt.Errorf("stock of %s cannot be recorded", movement.SKU)
+	}
+	m.movements = append(m.movements, movement)
+	return nil
+}
+
+// mockAllocator is an implementation of the StockAllocator interface that records the SKUs it is asked to
+// allocate.
+type mockAllocator struct {
+	skus []string
+}
+
+// AllocateStock is a mock method that records the SKU.
+func (m *mockAllocator) AllocateStock(sku string) (int, error) {
+	m.skus = append(m.skus, sku)
+	return 0, nil
+}
+
+// mockBalanceStore is an in-memory implementation of the parts of the BalanceStore interface used by the handler.
+type mockBalanceStore struct {
+	types.BalanceStore
+	entries []types.BalanceEntry // Store credit entries, where the ID is the index plus one.
+}
+
+// AddStoreCredit is a mock method that records the entry.
+func (m *mockBalanceStore) AddStoreCredit(entry types.BalanceEntry) (int, error) {
+	entry.ID = len(m.entries) + 1
+	m.entries = append(m.entries, entry)
+	return entry.ID, nil
+}
+
+// mockPaymentProvider is an implementation of the parts of the PaymentProvider interface used by the handler,
+// recording refunds, or failing.
+type mockPaymentProvider struct {
+	types.PaymentProvider
+	fail    bool                  // Whether refunds fail.
+	refunds []types.PaymentRefund // The refunds made.
+}
+
+// Refund is a mock method that records the refund and returns its reference as its ID.
+func (m *mockPaymentProvider) Refund(refund types.PaymentRefund) (string, error) {
+	if m.fail {
+		return "", fmt.Errorf("provider unavailable")
+	}
+
+	m.refunds = append(m.refunds, refund)
+	return refund.Reference, nil
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
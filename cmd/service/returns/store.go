@@ -0,0 +1,365 @@
+package returns
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+	"strings"
+
+	// Import the types package for the return types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// returnColumns lists the columns of the returns table, in the order scanRowIntoReturn reads them.
+const returnColumns = `id, orderId, userId, status, note, supportNote, carrier, trackingNumber, trackingUrl, warehouseId,
+	currency, refund, shippingRefund, providerRefund, storeCreditRefund, paymentRefundId, storeCreditEntryId,
+	createdAt, decidedAt, receivedAt, refundedAt`
+
+// Store struct represents the data store of returns.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateReturn is a method on the Store struct that stores a return and its lines in a single transaction,
+// and returns its ID. The order is locked while the quantities shipped and already being returned are read;
+// rejected returns do not count.
+func (s *Store) CreateReturn(ret types.Return) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var currency string
+	err = tx.QueryRow("SELECT currency FROM orders WHERE id = ? FOR UPDATE", ret.OrderID).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("order not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range ret.Lines {
+		var shipped, returned int
+		err := tx.QueryRow(`
+			SELECT
+				COALESCE((SELECT SUM(sl.quantity) FROM shipment_lines sl WHERE sl.orderItemId = oi.id), 0),
+				COALESCE((SELECT SUM(rl.quantity) FROM return_lines rl JOIN returns r ON r.id = rl.returnId
+					WHERE rl.orderItemId = oi.id AND r.status != ?), 0)
+			FROM order_items oi WHERE oi.id = ? AND oi.orderId = ?`,
+			types.ReturnRejected, line.OrderItemID, ret.OrderID,
+		).Scan(&shipped, &returned)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("order item %d not found", line.OrderItemID)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if returned+line.Quantity > shipped {
+			return 0, fmt.Errorf("%w: order item %d has %d left to return", types.ErrNotReturnable, line.OrderItemID, max(shipped-returned, 0))
+		}
+	}
+
+	res, err := tx.Exec(
+		"INSERT INTO returns (orderId, userId, status, note, currency) VALUES (?, ?, ?, ?, ?)",
+		ret.OrderID, ret.UserID, ret.Status, ret.Note, currency,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range ret.Lines {
+		_, err := tx.Exec(
+			"INSERT INTO return_lines (returnId, orderItemId, quantity, reason, comment) VALUES (?, ?, ?, ?, ?)",
+			id, line.OrderItemID, line.Quantity, line.Reason, line.Comment,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int(id), tx.Commit()
+}
+
+// GetReturnByID is a method on the Store struct that retrieves a return, with its lines, by ID.
+func (s *Store) GetReturnByID(id int) (*types.Return, error) {
+	returns, err := queryReturns(s.db, "SELECT "+returnColumns+" FROM returns WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return nil, fmt.Errorf("return not found")
+	}
+
+	return returns[0], nil
+}
+
+// GetReturnsByOrder is a method on the Store struct that retrieves the returns of an order, with their lines,
+// oldest first.
+func (s *Store) GetReturnsByOrder(orderID int) ([]*types.Return, error) {
+	return queryReturns(s.db, "SELECT "+returnColumns+" FROM returns WHERE orderId = ? ORDER BY id", orderID)
+}
+
+// GetReturns is a method on the Store struct that retrieves the returns with a status, or all returns for an
+// empty status, oldest first.
+func (s *Store) GetReturns(status string) ([]*types.Return, error) {
+	if status == "" {
+		return queryReturns(s.db, "SELECT "+returnColumns+" FROM returns ORDER BY id")
+	}
+	return queryReturns(s.db, "SELECT "+returnColumns+" FROM returns WHERE status = ? ORDER BY id", status)
+}
+
+// UpdateReturn is a method on the Store struct that saves a return and the grading and refund of its lines in a
+// single transaction. The return is locked while its status is compared, so of two requests that read the same
+// status only the first one saves; the other gets ErrReturnChanged. The tracking is left to SetReturnTracking.
+func (s *Store) UpdateReturn(ret types.Return, status string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockReturn(tx, ret.ID, status); err != nil {
+		return err
+	}
+	if err := saveReturn(tx, ret); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReceiveReturn is a method on the Store struct that saves a return whose items arrived, if it is still approved.
+// The order is locked first, as CreateReturn does, so refund sees the returns of the order received before and
+// no other return of the order is received until this one is saved.
+func (s *Store) ReceiveReturn(ret *types.Return, refund func(others []*types.Return) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var orderID int
+	err = tx.QueryRow("SELECT id FROM orders WHERE id = ? FOR UPDATE", ret.OrderID).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("order not found")
+	}
+	if err != nil {
+		return err
+	}
+	if err := lockReturn(tx, ret.ID, types.ReturnApproved); err != nil {
+		return err
+	}
+
+	returns, err := queryReturns(tx, "SELECT "+returnColumns+" FROM returns WHERE orderId = ? AND id != ? ORDER BY id", ret.OrderID, ret.ID)
+	if err != nil {
+		return err
+	}
+	if err := refund(returns); err != nil {
+		return err
+	}
+	if err := saveReturn(tx, *ret); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// saveReturn writes a return and the grading and refund of its lines, within a transaction.
+func saveReturn(tx *sql.Tx, ret types.Return) error {
+	_, err := tx.Exec(`
+		UPDATE returns SET status = ?, supportNote = ?, warehouseId = ?,
+			refund = ?, shippingRefund = ?, providerRefund = ?, storeCreditRefund = ?, paymentRefundId = ?, storeCreditEntryId = ?,
+			decidedAt = ?, receivedAt = ?, refundedAt = ?
+		WHERE id = ?`,
+		ret.Status, ret.SupportNote, ret.WarehouseID,
+		ret.Refund.Decimal(), ret.ShippingRefund.Decimal(), ret.ProviderRefund.Decimal(), ret.StoreCreditRefund.Decimal(),
+		ret.PaymentRefundID, ret.StoreCreditEntry, ret.DecidedAt, ret.ReceivedAt, ret.RefundedAt, ret.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range ret.Lines {
+		_, err := tx.Exec(
+			"UPDATE return_lines SET itemCondition = ?, restocked = ?, refund = ? WHERE returnId = ? AND orderItemId = ?",
+			line.Condition, line.Restocked, line.Refund.Decimal(), ret.ID, line.OrderItemID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetReturnTracking is a method on the Store struct that saves how an approved return is sent back. Only the
+// tracking columns are written, so it cannot undo a change made to the rest of the return meanwhile.
+func (s *Store) SetReturnTracking(id int, carrier, trackingNumber, trackingURL string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockReturn(tx, id, types.ReturnApproved); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE returns SET carrier = ?, trackingNumber = ?, trackingUrl = ? WHERE id = ?",
+		carrier, trackingNumber, trackingURL, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockReturn locks a return for the rest of the transaction, and returns ErrReturnChanged unless it has the given
+// status.
+func lockReturn(tx *sql.Tx, id int, status string) error {
+	var current string
+	err := tx.QueryRow("SELECT status FROM returns WHERE id = ? FOR UPDATE", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("return not found")
+	}
+	if err != nil {
+		return err
+	}
+	if current != status {
+		return fmt.Errorf("%w: return %d is %s, not %s", types.ErrReturnChanged, id, current, status)
+	}
+
+	return nil
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx, so returns can be read inside or outside a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// queryReturns runs a query selecting returnColumns and returns the returns, with their lines.
+func queryReturns(q querier, query string, args ...any) ([]*types.Return, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	returns := []*types.Return{}
+	byID := map[int]*types.Return{}
+	for rows.Next() {
+		ret, err := scanRowIntoReturn(rows)
+		if err != nil {
+			return nil, err
+		}
+		returns = append(returns, ret)
+		byID[ret.ID] = ret
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return returns, nil
+	}
+
+	// Load the lines of all the returns at once.
+	ids := make([]any, 0, len(returns))
+	for _, ret := range returns {
+		ids = append(ids, ret.ID)
+	}
+	lines, err := q.Query(`
+		SELECT rl.returnId, rl.orderItemId, oi.sku, rl.quantity, rl.reason, rl.comment, rl.itemCondition, rl.restocked, rl.refund
+		FROM return_lines rl JOIN order_items oi ON oi.id = rl.orderItemId
+		WHERE rl.returnId IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+		ORDER BY rl.orderItemId`, ids...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var returnID int
+		var line types.ReturnLine
+		var refund string
+		err := lines.Scan(&returnID, &line.OrderItemID, &line.SKU, &line.Quantity, &line.Reason, &line.Comment,
+			&line.Condition, &line.Restocked, &refund)
+		if err != nil {
+			return nil, err
+		}
+		ret := byID[returnID]
+		if line.Refund, err = types.ParseMoney(refund, ret.Refund.Currency); err != nil {
+			return nil, err
+		}
+		ret.Lines = append(ret.Lines, line)
+	}
+
+	return returns, lines.Err()
+}
+
+// scanRowIntoReturn scans a row selected with returnColumns into a Return, without its lines.
+func scanRowIntoReturn(rows *sql.Rows) (*types.Return, error) {
+	ret := &types.Return{Lines: []types.ReturnLine{}}
+	var currency, refund, shippingRefund, providerRefund, storeCreditRefund string
+	var warehouseID, storeCreditEntry sql.NullInt64
+	var decidedAt, receivedAt, refundedAt sql.NullTime
+
+	err := rows.Scan(&ret.ID, &ret.OrderID, &ret.UserID, &ret.Status, &ret.Note, &ret.SupportNote,
+		&ret.Carrier, &ret.TrackingNumber, &ret.TrackingURL, &warehouseID,
+		&currency, &refund, &shippingRefund, &providerRefund, &storeCreditRefund, &ret.PaymentRefundID, &storeCreditEntry,
+		&ret.CreatedAt, &decidedAt, &receivedAt, &refundedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, amount := range []struct {
+		dest  *types.Money
+		value string
+	}{
+		{&ret.Refund, refund},
+		{&ret.ShippingRefund, shippingRefund},
+		{&ret.ProviderRefund, providerRefund},
+		{&ret.StoreCreditRefund, storeCreditRefund},
+	} {
+		if *amount.dest, err = types.ParseMoney(amount.value, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	ret.WarehouseID = nullInt(warehouseID)
+	ret.StoreCreditEntry = nullInt(storeCreditEntry)
+	if decidedAt.Valid {
+		ret.DecidedAt = &decidedAt.Time
+	}
+	if receivedAt.Valid {
+		ret.ReceivedAt = &receivedAt.Time
+	}
+	if refundedAt.Valid {
+		ret.RefundedAt = &refundedAt.Time
+	}
+
+	return ret, nil
+}
+
+// nullInt returns a nullable integer column as a pointer, nil for NULL.
+func nullInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+
+	i := int(n.Int64)
+	return &i
+}
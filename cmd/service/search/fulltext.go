@@ -0,0 +1,74 @@
+package search
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"strings"
+
+	// Import the types package for the product and search hit types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// FulltextIndex struct searches products with the FULLTEXT index of the products table in MySQL.
+// MySQL keeps the index in sync itself, so Index and Remove do nothing. It does not stem or tolerate typos,
+// but needs no memory and is shared by all instances of the API.
+type FulltextIndex struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewFulltextIndex is a constructor function that returns a FulltextIndex using the given database.
+func NewFulltextIndex(db *sql.DB) *FulltextIndex {
+	return &FulltextIndex{db: db}
+}
+
+// Index is a method on the FulltextIndex struct that does nothing, since MySQL indexes products as they are written.
+func (f *FulltextIndex) Index(types.Product) error {
+	return nil
+}
+
+// Remove is a method on the FulltextIndex struct that does nothing, since MySQL indexes products as they are written.
+func (f *FulltextIndex) Remove(int) error {
+	return nil
+}
+
+// Search is a method on the FulltextIndex struct that returns up to limit products matching any of the query
+// terms, ordered by the relevance MySQL computes. The last term is matched as a prefix.
+func (f *FulltextIndex) Search(query string, limit int) ([]types.SearchHit, error) {
+	expr := booleanQuery(query)
+	if expr == "" {
+		return []types.SearchHit{}, nil
+	}
+
+	rows, err := f.db.Query(
+		"SELECT id, MATCH(name, description) AGAINST (? IN BOOLEAN MODE) AS score FROM products "+
+			"WHERE MATCH(name, description) AGAINST (? IN BOOLEAN MODE) ORDER BY score DESC, id LIMIT ?",
+		expr, expr, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []types.SearchHit{}
+	for rows.Next() {
+		var hit types.SearchHit
+		if err := rows.Scan(&hit.ProductID, &hit.Score); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// booleanQuery turns a user's query into a MySQL boolean mode expression. Tokenizing drops the operators of
+// boolean mode, so users cannot inject them, and the last term gets a * to match it as a prefix.
+func booleanQuery(query string) string {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	tokens[len(tokens)-1] += "*"
+	return strings.Join(tokens, " ")
+}
@@ -0,0 +1,261 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	// Import the types package for the product and search hit types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// BM25 parameters: k1 controls how quickly repeated terms stop adding to the score,
+// b how much long documents are penalized.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// nameWeight is how many times a term in the product name counts compared to one in the description.
+const nameWeight = 2
+
+// Score factors for query terms that did not match exactly, so exact matches rank first.
+const (
+	prefixDiscount = 0.8
+	fuzzyDiscount  = 0.6
+)
+
+// InvertedIndex struct is an in-memory full-text index of products, ranking matches with BM25.
+// It stems terms, expands the last query term as a prefix so results appear while typing,
+// and tolerates typos. It is safe for concurrent use.
+type InvertedIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[int]int // Term frequency per document, per term.
+	docs     map[int][]string       // Terms of each document, to remove it again.
+	lengths  map[int]int            // Number of terms in each document.
+	total    int                    // Sum of the document lengths.
+	terms    []string               // All terms, sorted, for prefix matching. Nil when it must be rebuilt.
+}
+
+// NewInvertedIndex is a constructor function that returns an empty InvertedIndex.
+func NewInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{
+		postings: map[string]map[int]int{},
+		docs:     map[int][]string{},
+		lengths:  map[int]int{},
+	}
+}
+
+// Index is a method on the InvertedIndex struct that adds a product to the index, replacing any earlier version.
+func (idx *InvertedIndex) Index(p types.Product) error {
+	// Name terms are repeated so they weigh more than description terms.
+	var terms []string
+	name := Analyze(p.Name)
+	for i := 0; i < nameWeight; i++ {
+		terms = append(terms, name...)
+	}
+	terms = append(terms, Analyze(p.Description)...)
+	terms = append(terms, Analyze(p.SKU)...)
+	for _, value := range p.Attributes {
+		terms = append(terms, Analyze(value)...)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(p.ID)
+	for _, t := range terms {
+		if idx.postings[t] == nil {
+			idx.postings[t] = map[int]int{}
+			idx.terms = nil
+		}
+		idx.postings[t][p.ID]++
+	}
+	idx.docs[p.ID] = terms
+	idx.lengths[p.ID] = len(terms)
+	idx.total += len(terms)
+
+	return nil
+}
+
+// Remove is a method on the InvertedIndex struct that removes a product from the index.
+func (idx *InvertedIndex) Remove(id int) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+	return nil
+}
+
+// remove removes a document. The caller must hold the write lock.
+func (idx *InvertedIndex) remove(id int) {
+	terms, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, t := range terms {
+		delete(idx.postings[t], id)
+		if len(idx.postings[t]) == 0 {
+			delete(idx.postings, t)
+			idx.terms = nil
+		}
+	}
+	idx.total -= idx.lengths[id]
+	delete(idx.docs, id)
+	delete(idx.lengths, id)
+}
+
+// Search is a method on the InvertedIndex struct that returns up to limit products matching any of the
+// query terms, best match first. Equal scores are ordered by product ID.
+func (idx *InvertedIndex) Search(query string, limit int) ([]types.SearchHit, error) {
+	words := Tokenize(query)
+	if len(words) == 0 {
+		return []types.SearchHit{}, nil
+	}
+
+	// Sorting the term list needs the write lock, so do it before taking the read lock.
+	idx.mu.Lock()
+	if idx.terms == nil {
+		idx.terms = make([]string, 0, len(idx.postings))
+		for t := range idx.postings {
+			idx.terms = append(idx.terms, t)
+		}
+		sort.Strings(idx.terms)
+	}
+	idx.mu.Unlock()
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.docs))
+	if n == 0 {
+		return []types.SearchHit{}, nil
+	}
+	avgLength := float64(idx.total) / n
+
+	scores := map[int]float64{}
+	for i, word := range words {
+		for term, factor := range idx.expand(word, i == len(words)-1) {
+			docs := idx.postings[term]
+			df := float64(len(docs))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+			for id, tf := range docs {
+				f := float64(tf)
+				norm := 1 - bm25B + bm25B*float64(idx.lengths[id])/avgLength
+				scores[id] += factor * idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+			}
+		}
+	}
+
+	hits := make([]types.SearchHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, types.SearchHit{ProductID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ProductID < hits[j].ProductID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
+
+// expand returns the index terms a query word matches, with the factor to score each with.
+// An exact match of the stem scores fully. The last word of the query is also matched as a prefix,
+// since the user may still be typing it. Other terms within a small edit distance match as typos.
+// A term matched several ways keeps its best factor. The caller must hold the read lock.
+func (idx *InvertedIndex) expand(word string, last bool) map[string]float64 {
+	matches := map[string]float64{}
+	match := func(term string, factor float64) {
+		if factor > matches[term] {
+			matches[term] = factor
+		}
+	}
+
+	stem := Stem(word)
+	if _, ok := idx.postings[stem]; ok {
+		match(stem, 1)
+	}
+
+	if last {
+		// The word itself is the prefix; its stem may be shorter than what was typed.
+		start := sort.SearchStrings(idx.terms, word)
+		for _, term := range idx.terms[start:] {
+			if !strings.HasPrefix(term, word) {
+				break
+			}
+			match(term, prefixDiscount)
+		}
+	}
+
+	if maxEdits := allowedEdits(word); maxEdits > 0 {
+		for _, term := range idx.terms {
+			if abs(len(term)-len(stem)) > maxEdits && abs(len(term)-len(word)) > maxEdits {
+				continue
+			}
+			if editDistance(term, stem) <= maxEdits || editDistance(term, word) <= maxEdits {
+				match(term, fuzzyDiscount)
+			}
+		}
+	}
+
+	return matches
+}
+
+// allowedEdits returns how many typos a query word may contain: none for short words,
+// where a single edit often gives another real word, one for medium words and two for long ones.
+func allowedEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// editDistance returns the optimal string alignment distance between a and b: the number of insertions,
+// deletions, substitutions and transpositions of adjacent characters needed to turn one into the other.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	// Three rows suffice: a transposition looks back two rows.
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(t)]
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"fmt"
+	"net/http"
+	"strconv"
+
+	// Import the types package for the suggester interface.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Default and maximum number of suggestions of each kind.
+const (
+	defaultSuggestLimit = 5
+	maxSuggestLimit     = maxCompletions
+)
+
+// Handler struct groups the methods that handle search requests.
+type Handler struct {
+	suggester types.Suggester // Interface for autocomplete.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(suggester types.Suggester) *Handler {
+	return &Handler{suggester: suggester}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the search routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Suggest completions while the user types.
+	router.HandleFunc("/search/suggest", h.handleSuggest).Methods(http.MethodGet)
+}
+
+// handleSuggest handles GET /search/suggest.
+// It supports the query parameters q, what the user has typed so far, and limit, the number of suggestions of each kind.
+func (h *Handler) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if NormalizeQuery(q) == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing search query"))
+		return
+	}
+
+	limit := defaultSuggestLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSuggestLimit {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxSuggestLimit))
+			return
+		}
+		limit = n
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"query":       q,
+		"suggestions": h.suggester.Suggest(q, limit),
+	})
+}
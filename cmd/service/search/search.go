@@ -0,0 +1,28 @@
+package search
+
+import (
+	// Import the sql package for the database used by the MySQL backend.
+	"database/sql"
+	"fmt"
+
+	// Import the types package for the search index interface.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Names of the search backends.
+const (
+	BackendMemory = "memory" // The in-process inverted index, which must be filled at startup.
+	BackendMySQL  = "mysql"  // MySQL full-text search on the products table.
+)
+
+// NewIndex returns the search index of the backend with the given name.
+func NewIndex(backend string, db *sql.DB) (types.SearchIndex, error) {
+	switch backend {
+	case BackendMemory:
+		return NewInvertedIndex(), nil
+	case BackendMySQL:
+		return NewFulltextIndex(db), nil
+	}
+
+	return nil, fmt.Errorf("unknown search backend %q", backend)
+}
@@ -0,0 +1,152 @@
+package search
+
+import (
+	"testing" // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for products
+)
+
+// TestStem tests the Porter stemmer against examples from the reference vocabulary.
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"running":        "run",
+		"hopping":        "hop",
+		"relational":     "relat",
+		"hopefulness":    "hope",
+		"generalization": "gener",
+		"shirts":         "shirt",
+		"shirt":          "shirt",
+		"xl":             "xl",
+		"4k":             "4k",
+	}
+
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+// TestEditDistance tests that transpositions count as one edit.
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"shirt", "shirt", 0},
+		{"shirt", "shrit", 1},
+		{"shirt", "shirts", 1},
+		{"jacket", "jakcet", 1},
+		{"sweater", "swaeter", 1},
+		{"boot", "coat", 2},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// fixtureIndex returns an index of a small clothing catalog.
+func fixtureIndex(t *testing.T) *InvertedIndex {
+	idx := NewInvertedIndex()
+	products := []types.Product{
+		{ID: 1, SKU: "TS-RED", Name: "Red cotton shirt", Description: "A soft shirt for running errands."},
+		{ID: 2, SKU: "JK-BLU", Name: "Blue denim jacket", Description: "Goes well with a red shirt."},
+		{ID: 3, SKU: "SW-GRY", Name: "Grey wool sweater", Description: "Warm and comfortable."},
+		{ID: 4, SKU: "SH-RUN", Name: "Running shoes", Description: "Lightweight shoes for runners."},
+	}
+	for _, p := range products {
+		if err := idx.Index(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return idx
+}
+
+// ids returns the product IDs of the hits, in order.
+func ids(hits []types.SearchHit) []int {
+	out := []int{}
+	for _, hit := range hits {
+		out = append(out, hit.ProductID)
+	}
+	return out
+}
+
+// TestSearch tests ranking, stemming, prefix matching and typo tolerance.
+func TestSearch(t *testing.T) {
+	idx := fixtureIndex(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		// A match in the name outranks one in the description.
+		{"ranking", "shirt", []int{1, 2}},
+		// Plurals and other forms match their stem.
+		{"stemming", "shirts", []int{1, 2}},
+		// The last word matches as a prefix while it is being typed.
+		{"prefix", "swea", []int{3}},
+		// Small typos are tolerated.
+		{"typo", "jakcet", []int{2}},
+		{"two words", "red jacket", []int{2, 1}},
+		{"stop words only", "the and", []int{}},
+		{"no match", "trousers", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := idx.Search(tt.query, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ids(hits)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestSearchAfterUpdate tests that reindexing and removing products is reflected in the results.
+func TestSearchAfterUpdate(t *testing.T) {
+	idx := fixtureIndex(t)
+
+	if err := idx.Index(types.Product{ID: 3, Name: "Grey wool cardigan"}); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := idx.Search("sweater", 10); len(hits) != 0 {
+		t.Errorf("expected the old name to be gone, got %v", ids(hits))
+	}
+	if hits, _ := idx.Search("cardigan", 10); len(hits) != 1 || hits[0].ProductID != 3 {
+		t.Errorf("expected product 3, got %v", ids(hits))
+	}
+
+	if err := idx.Remove(3); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := idx.Search("cardigan", 10); len(hits) != 0 {
+		t.Errorf("expected no results after removal, got %v", ids(hits))
+	}
+}
+
+// TestBooleanQuery tests that boolean mode operators are stripped and the last term matches as a prefix.
+func TestBooleanQuery(t *testing.T) {
+	if got := booleanQuery(`red +shirt -"jack`); got != "red shirt jack*" {
+		t.Errorf("booleanQuery = %q", got)
+	}
+	if got := booleanQuery("the"); got != "" {
+		t.Errorf("booleanQuery = %q, want empty", got)
+	}
+}
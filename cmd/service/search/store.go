@@ -0,0 +1,51 @@
+package search
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"time"
+
+	// Import the types package for the query log types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Store struct represents the data store of the search query log.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// LogQuery is a method on the Store struct that records a search query and the number of results it had.
+func (s *Store) LogQuery(query string, results int) error {
+	_, err := s.db.Exec("INSERT INTO search_queries (query, results) VALUES (?, ?)", query, results)
+	return err
+}
+
+// GetPopularQueries is a method on the Store struct that retrieves the queries with results searched most often
+// since the given time, most searched first.
+func (s *Store) GetPopularQueries(since time.Time, limit int) ([]types.PopularQuery, error) {
+	rows, err := s.db.Query(
+		"SELECT query, COUNT(*) AS searches FROM search_queries WHERE createdAt >= ? AND results > 0 GROUP BY query ORDER BY searches DESC, query LIMIT ?",
+		since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queries := []types.PopularQuery{}
+	for rows.Next() {
+		var q types.PopularQuery
+		if err := rows.Scan(&q.Query, &q.Count); err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+
+	return queries, rows.Err()
+}
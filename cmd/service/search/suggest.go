@@ -0,0 +1,197 @@
+package search
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	// Import the types package for the catalog and suggestion types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// maxPopularQueries is the number of popular past queries kept for autocomplete.
+const maxPopularQueries = 10000
+
+// Suggester struct completes partial search queries with product names, category names and popular past queries,
+// and corrects misspelled queries. It answers from memory, and is rebuilt from the catalog and the query log
+// as a whole. It is safe for concurrent use.
+type Suggester struct {
+	mu         sync.RWMutex
+	products   *Trie          // Product names, with the product IDs.
+	categories *Trie          // Category names, with the category IDs.
+	slugs      map[int]string // Category slugs by ID.
+	queries    *Trie          // Popular past queries.
+	vocabulary map[string]int // How often each word occurs in the catalog, for spelling corrections.
+}
+
+// NewSuggester is a constructor function that returns a Suggester without suggestions, until it is rebuilt.
+func NewSuggester() *Suggester {
+	return &Suggester{
+		products:   NewTrie(),
+		categories: NewTrie(),
+		slugs:      map[int]string{},
+		queries:    NewPrefixTrie(),
+		vocabulary: map[string]int{},
+	}
+}
+
+// Rebuild is a method on the Suggester struct that replaces all suggestions. Products are weighted by their
+// number of reviews, categories by their number of products and queries by how often they were searched.
+func (s *Suggester) Rebuild(products []*types.Product, categories []*types.Category, queries []types.PopularQuery) {
+	productTrie, categoryTrie, queryTrie := NewTrie(), NewTrie(), NewPrefixTrie()
+	vocabulary := map[string]int{}
+	learn := func(text string) {
+		for _, word := range Tokenize(text) {
+			vocabulary[word]++
+		}
+	}
+
+	categorySize := map[int]int{}
+	for _, p := range products {
+		productTrie.Insert(Entry{ID: p.ID, Text: p.Name, Weight: p.ReviewCount})
+		learn(p.Name)
+		learn(p.Description)
+		for _, value := range p.Attributes {
+			learn(value)
+		}
+		if p.CategoryID != nil {
+			categorySize[*p.CategoryID]++
+		}
+	}
+
+	slugs := map[int]string{}
+	for _, c := range categories {
+		categoryTrie.Insert(Entry{ID: c.ID, Text: c.Name, Weight: categorySize[c.ID]})
+		slugs[c.ID] = c.Slug
+		learn(c.Name)
+	}
+
+	for _, q := range queries {
+		queryTrie.Insert(Entry{Text: q.Query, Weight: q.Count})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.products, s.categories, s.slugs, s.queries, s.vocabulary = productTrie, categoryTrie, slugs, queryTrie, vocabulary
+}
+
+// Suggest is a method on the Suggester struct that returns up to limit products, categories and past queries
+// completing the prefix. Products and categories match on any word of their name, queries only on their start,
+// or they would suggest queries about something else.
+func (s *Suggester) Suggest(prefix string, limit int) types.Suggestions {
+	prefix = NormalizeQuery(prefix)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	suggestions := types.Suggestions{
+		Products:   []types.ProductSuggestion{},
+		Categories: []types.CategorySuggestion{},
+		Queries:    []string{},
+	}
+	if prefix == "" {
+		return suggestions
+	}
+
+	for _, e := range s.products.Complete(prefix, limit) {
+		suggestions.Products = append(suggestions.Products, types.ProductSuggestion{ID: e.ID, Name: e.Text})
+	}
+	for _, e := range s.categories.Complete(prefix, limit) {
+		suggestions.Categories = append(suggestions.Categories, types.CategorySuggestion{Slug: s.slugs[e.ID], Name: e.Text})
+	}
+	for _, e := range s.queries.Complete(prefix, limit) {
+		suggestions.Queries = append(suggestions.Queries, e.Text)
+	}
+
+	return suggestions
+}
+
+// DidYouMean is a method on the Suggester struct that replaces every word of the query that does not occur
+// in the catalog with the most common catalog word within a few typos of it. It returns "" when no word
+// was replaced.
+func (s *Suggester) DidYouMean(query string) string {
+	words := Tokenize(query)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changed := false
+	for i, word := range words {
+		if s.vocabulary[word] > 0 {
+			continue
+		}
+		if best := s.correct(word); best != "" {
+			words[i] = best
+			changed = true
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+	return strings.Join(words, " ")
+}
+
+// correct returns the catalog word closest to the misspelled word, preferring fewer typos and then more common
+// words, or "" if none is close enough. The caller must hold the read lock.
+func (s *Suggester) correct(word string) string {
+	maxEdits := allowedEdits(word)
+	if maxEdits == 0 {
+		return ""
+	}
+
+	best, bestEdits, bestCount := "", maxEdits+1, 0
+	for candidate, count := range s.vocabulary {
+		if abs(len(candidate)-len(word)) > maxEdits {
+			continue
+		}
+		edits := editDistance(word, candidate)
+		if edits < bestEdits || edits == bestEdits && (count > bestCount || count == bestCount && candidate < best) {
+			best, bestEdits, bestCount = candidate, edits, count
+		}
+	}
+
+	return best
+}
+
+// NormalizeQuery lowercases a query and collapses its whitespace, so the same query is logged and completed
+// the same way however it was typed.
+func NormalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// RebuildSuggester rebuilds the suggester from the catalog and the popular queries of the last windowDays days.
+func RebuildSuggester(s *Suggester, products types.ProductStore, categories types.CategoryStore, queries types.QueryLogStore, windowDays int) error {
+	allProducts, err := products.GetProducts()
+	if err != nil {
+		return err
+	}
+	allCategories, err := categories.GetCategories()
+	if err != nil {
+		return err
+	}
+	popular, err := queries.GetPopularQueries(time.Now().UTC().AddDate(0, 0, -windowDays), maxPopularQueries)
+	if err != nil {
+		return err
+	}
+
+	s.Rebuild(allProducts, allCategories, popular)
+	return nil
+}
+
+// StartSuggesterRefresh starts a goroutine that rebuilds the suggester at the given interval,
+// so it picks up catalog changes and new popular queries.
+func StartSuggesterRefresh(s *Suggester, products types.ProductStore, categories types.CategoryStore, queries types.QueryLogStore, windowDays int, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := RebuildSuggester(s, products, categories, queries, windowDays); err != nil {
+				log.Printf("failed to rebuild search suggestions: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,133 @@
+package search
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package to compare suggestions
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"testing"           // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for catalog types
+	"github.com/gorilla/mux"                 // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// texts returns the texts of the entries, in order.
+func texts(entries []Entry) []string {
+	out := []string{}
+	for _, e := range entries {
+		out = append(out, e.Text)
+	}
+	return out
+}
+
+// TestTrie tests that completions match word starts and come best first.
+func TestTrie(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(Entry{ID: 1, Text: "Red cotton shirt", Weight: 5})
+	trie.Insert(Entry{ID: 2, Text: "Shirt dress", Weight: 1})
+	trie.Insert(Entry{ID: 3, Text: "Short-sleeve shirt", Weight: 5})
+
+	tests := []struct {
+		prefix string
+		limit  int
+		want   string
+	}{
+		// Heavier entries first, then shorter ones; "Short-sleeve shirt" is only added once.
+		{"sh", 10, "[Red cotton shirt Short-sleeve shirt Shirt dress]"},
+		{"SHIR", 10, "[Red cotton shirt Short-sleeve shirt Shirt dress]"},
+		{"sleeve", 10, "[Short-sleeve shirt]"},
+		{"sh", 1, "[Red cotton shirt]"},
+		{"otton", 10, "[]"},
+		{"jacket", 10, "[]"},
+	}
+
+	for _, tt := range tests {
+		if got := fmt.Sprint(texts(trie.Complete(tt.prefix, tt.limit))); got != tt.want {
+			t.Errorf("Complete(%q, %d) = %s, want %s", tt.prefix, tt.limit, got, tt.want)
+		}
+	}
+
+	// A prefix trie only completes from the start.
+	prefixTrie := NewPrefixTrie()
+	prefixTrie.Insert(Entry{Text: "red shirt"})
+	if got := texts(prefixTrie.Complete("shi", 10)); len(got) != 0 {
+		t.Errorf("expected no completions from the second word, got %v", got)
+	}
+}
+
+// fixtureSuggester returns a suggester of a small clothing catalog with some past queries.
+func fixtureSuggester() *Suggester {
+	shirts := 1
+	s := NewSuggester()
+	s.Rebuild(
+		[]*types.Product{
+			{ID: 1, Name: "Red cotton shirt", Description: "Soft cotton.", CategoryID: &shirts, ReviewCount: 3},
+			{ID: 2, Name: "Blue denim jacket", Description: "Classic denim.", Attributes: map[string]string{"brand": "Levis"}},
+		},
+		[]*types.Category{{ID: 1, Slug: "shirts", Name: "Shirts"}, {ID: 2, Slug: "shoes", Name: "Shoes"}},
+		[]types.PopularQuery{{Query: "shirt", Count: 10}, {Query: "short shirt", Count: 2}, {Query: "red shirt", Count: 5}},
+	)
+	return s
+}
+
+// TestSuggest tests completions of products, categories and past queries.
+func TestSuggest(t *testing.T) {
+	got := fixtureSuggester().Suggest(" SH ", 5)
+
+	want := types.Suggestions{
+		Products:   []types.ProductSuggestion{{ID: 1, Name: "Red cotton shirt"}},
+		Categories: []types.CategorySuggestion{{Slug: "shirts", Name: "Shirts"}, {Slug: "shoes", Name: "Shoes"}},
+		Queries:    []string{"shirt", "short shirt"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Suggest = %+v, want %+v", got, want)
+	}
+}
+
+// TestDidYouMean tests spelling corrections against the catalog vocabulary.
+func TestDidYouMean(t *testing.T) {
+	s := fixtureSuggester()
+
+	tests := map[string]string{
+		"cottn shirt":  "cotton shirt",
+		"denin jakcet": "denim jacket",
+		"levsi":        "levis",
+		"red shirt":    "",
+		"xyz":          "",
+	}
+
+	for query, want := range tests {
+		if got := s.DidYouMean(query); got != want {
+			t.Errorf("DidYouMean(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+// TestSuggestHandler tests the suggest endpoint.
+func TestSuggestHandler(t *testing.T) {
+	router := mux.NewRouter()
+	NewHandler(fixtureSuggester()).RegisterRoutes(router)
+
+	for target, code := range map[string]int{
+		"/search/suggest?q=den":            http.StatusOK,
+		"/search/suggest?q=%20":            http.StatusBadRequest,
+		"/search/suggest?q=den&limit=1000": http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != code {
+			t.Errorf("expected status code %d for %s, got %d", code, target, rr.Code)
+		}
+
+		if code == http.StatusOK {
+			var res struct {
+				Suggestions types.Suggestions `json:"suggestions"`
+			}
+			json.NewDecoder(rr.Body).Decode(&res)
+			if len(res.Suggestions.Products) != 1 || res.Suggestions.Products[0].ID != 2 {
+				t.Errorf("expected the denim jacket, got %+v", res.Suggestions)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxCompletions is the most completions a Trie returns for a prefix.
+const maxCompletions = 20
+
+// maxKeyLength is the number of characters of a key that are indexed. Nobody types further before picking
+// a completion, and it bounds the size of the trie.
+const maxKeyLength = 40
+
+// Entry struct is an item that can be completed, such as a product name.
+type Entry struct {
+	ID     int    // ID of the item, such as a product ID. Entries with the same ID and text are the same entry.
+	Text   string // The text shown to the user.
+	Weight int    // How popular the entry is. Higher weights are completed first.
+}
+
+// Trie struct is a prefix tree of entries. Every node keeps the best completions below it,
+// so completing a prefix only takes walking down the prefix. It is built once and then only read,
+// which makes it safe for concurrent use after building.
+type Trie struct {
+	root       *trieNode
+	wholeTexts bool // Whether entries only complete from the start of their text, not from every word.
+}
+
+// trieNode is a node of a Trie.
+type trieNode struct {
+	children map[rune]*trieNode
+	best     []*Entry // The best entries whose keys pass through this node, best first.
+}
+
+// NewTrie is a constructor function that returns an empty Trie completing every word of its entries,
+// so "Red cotton shirt" completes "red", "cot" and "shi".
+func NewTrie() *Trie {
+	return &Trie{root: &trieNode{}}
+}
+
+// NewPrefixTrie is a constructor function that returns an empty Trie completing its entries only from the start,
+// so "red shirt" completes "red s" but not "shi".
+func NewPrefixTrie() *Trie {
+	return &Trie{root: &trieNode{}, wholeTexts: true}
+}
+
+// Insert adds an entry under its text, and unless the trie completes whole texts only, under every later word
+// of its text. Matching ignores case.
+func (t *Trie) Insert(e Entry) {
+	key := []rune(strings.ToLower(e.Text))
+	entry := &e
+
+	for start := 0; start < len(key); start++ {
+		// Keys start at the beginning of the text, or of each word.
+		if start > 0 && (t.wholeTexts || isWordChar(key[start-1]) || !isWordChar(key[start])) {
+			continue
+		}
+
+		node := t.root
+		for _, r := range key[start:min(len(key), start+maxKeyLength)] {
+			child := node.children[r]
+			if child == nil {
+				if node.children == nil {
+					node.children = map[rune]*trieNode{}
+				}
+				child = &trieNode{}
+				node.children[r] = child
+			}
+			child.add(entry)
+			node = child
+		}
+	}
+}
+
+// Complete returns up to limit entries with a word starting with the prefix, best first.
+func (t *Trie) Complete(prefix string, limit int) []Entry {
+	node := t.root
+	for _, r := range strings.ToLower(prefix) {
+		if node = node.children[r]; node == nil {
+			return []Entry{}
+		}
+	}
+
+	entries := []Entry{}
+	for _, e := range node.best[:min(limit, len(node.best))] {
+		entries = append(entries, *e)
+	}
+	return entries
+}
+
+// add adds an entry to the best entries of the node, if it is one of them.
+func (n *trieNode) add(e *Entry) {
+	for _, b := range n.best {
+		if b.ID == e.ID && b.Text == e.Text {
+			return
+		}
+	}
+
+	i := sort.Search(len(n.best), func(i int) bool { return better(e, n.best[i]) })
+	if i >= maxCompletions {
+		return
+	}
+
+	n.best = append(n.best, nil)
+	copy(n.best[i+1:], n.best[i:])
+	n.best[i] = e
+	if len(n.best) > maxCompletions {
+		n.best = n.best[:maxCompletions]
+	}
+}
+
+// better reports whether a should be completed before b: higher weights first, then shorter texts, since
+// they are closer to what was typed, then alphabetically.
+func better(a, b *Entry) bool {
+	if a.Weight != b.Weight {
+		return a.Weight > b.Weight
+	}
+	if len(a.Text) != len(b.Text) {
+		return len(a.Text) < len(b.Text)
+	}
+	if a.Text != b.Text {
+		return a.Text < b.Text
+	}
+	return a.ID < b.ID
+}
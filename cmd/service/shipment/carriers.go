@@ -0,0 +1,25 @@
+package shipment
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingURLs maps carriers, in lower case, to the page customers follow a package on, with %s for the
+// tracking number.
+var trackingURLs = map[string]string{
+	"dhl":   "https://www.dhl.com/global-en/home/tracking/tracking-parcel.html?tracking-id=%s",
+	"fedex": "https://www.fedex.com/fedextrack/?trknbr=%s",
+	"ups":   "https://www.ups.com/track?tracknum=%s",
+	"usps":  "https://tools.usps.com/go/TrackConfirmAction?tLabels=%s",
+}
+
+// TrackingURL returns the page customers follow a package of a known carrier on, or an empty string if the carrier
+// is unknown or there is no tracking number.
+func TrackingURL(carrier, trackingNumber string) string {
+	pattern, ok := trackingURLs[strings.ToLower(strings.TrimSpace(carrier))]
+	if !ok || trackingNumber == "" {
+		return ""
+	}
+	return strings.Replace(pattern, "%s", url.QueryEscape(trackingNumber), 1)
+}
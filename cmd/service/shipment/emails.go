@@ -0,0 +1,57 @@
+package shipment
+
+import (
+	"fmt"
+	"strings"
+
+	// Import the types package for the Email type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// shippedEmail builds the email telling a customer a package of their order is on its way, with what is in it and
+// how to follow it. partial tells whether more of the order is still to ship.
+func shippedEmail(u *types.User, o *types.Order, sh *types.Shipment, partial bool) types.Email {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\n", u.FirstName)
+	if partial {
+		fmt.Fprintf(&b, "Part of your order #%d is on its way with %s:\n\n", o.ID, sh.Carrier)
+	} else {
+		fmt.Fprintf(&b, "Your order #%d is on its way with %s:\n\n", o.ID, sh.Carrier)
+	}
+	for _, line := range sh.Lines {
+		fmt.Fprintf(&b, "- %d x %s\n", line.Quantity, line.SKU)
+	}
+	b.WriteString("\n")
+
+	switch {
+	case sh.TrackingURL != "":
+		fmt.Fprintf(&b, "Follow your package here:\n\n%s\n\n", sh.TrackingURL)
+	case sh.TrackingNumber != "":
+		fmt.Fprintf(&b, "Your tracking number is %s.\n\n", sh.TrackingNumber)
+	}
+	if partial {
+		b.WriteString("The rest of your order will follow in another package.\n")
+	}
+
+	return types.Email{
+		To:      u.Email,
+		Subject: fmt.Sprintf("Your order #%d has shipped", o.ID),
+		Body:    b.String(),
+	}
+}
+
+// deliveredEmail builds the email telling a customer a package of their order was delivered.
+func deliveredEmail(u *types.User, o *types.Order, sh *types.Shipment) types.Email {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\n%s delivered a package of your order #%d:\n\n", u.FirstName, sh.Carrier, o.ID)
+	for _, line := range sh.Lines {
+		fmt.Fprintf(&b, "- %d x %s\n", line.Quantity, line.SKU)
+	}
+	b.WriteString("\nIf you did not receive it, please contact us.\n")
+
+	return types.Email{
+		To:      u.Email,
+		Subject: fmt.Sprintf("Your order #%d was delivered", o.ID),
+		Body:    b.String(),
+	}
+}
@@ -0,0 +1,399 @@
+package shipment
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the auth package for the authentication middlewares.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle shipment requests.
+type Handler struct {
+	store     types.ShipmentStore  // Interface for shipments.
+	orders    types.OrderStore     // Interface for the orders shipments belong to.
+	inventory types.InventoryStore // Interface for the stock held for the items shipped.
+	mailer    types.Mailer         // Interface for emailing customers about their packages.
+	userStore types.UserStore      // Interface for user-related data operations.
+	now       func() time.Time     // Returns the current time.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.ShipmentStore, orders types.OrderStore, inventory types.InventoryStore, mailer types.Mailer, userStore types.UserStore) *Handler {
+	return &Handler{store: store, orders: orders, inventory: inventory, mailer: mailer, userStore: userStore, now: time.Now}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the shipment routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Follow the packages of an order of the user.
+	router.HandleFunc("/orders/{id:[0-9]+}/shipments", auth.WithAuth(h.handleGetMyShipments, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/orders/{id:[0-9]+}/shipments/{shipmentId:[0-9]+}", auth.WithAuth(h.handleGetMyShipment, h.userStore)).Methods(http.MethodGet)
+
+	// Ship orders, in one package or several, and record how the packages get on.
+	router.HandleFunc("/admin/orders/{id:[0-9]+}/shipments", auth.WithAdminAuth(h.handleGetShipments, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/orders/{id:[0-9]+}/shipments", auth.WithAdminAuth(h.handleCreateShipment, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/shipments/{id:[0-9]+}", auth.WithAdminAuth(h.handleUpdateShipment, h.userStore)).Methods(http.MethodPatch)
+}
+
+// handleGetMyShipments handles GET /orders/{id}/shipments.
+// Orders of other users are reported as not found, so their IDs cannot be probed.
+func (h *Handler) handleGetMyShipments(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.myOrder(w, r)
+	if !ok {
+		return
+	}
+
+	shipments, err := h.store.GetShipmentsByOrder(o.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, shipments)
+}
+
+// handleGetMyShipment handles GET /orders/{id}/shipments/{shipmentId}.
+func (h *Handler) handleGetMyShipment(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.myOrder(w, r)
+	if !ok {
+		return
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["shipmentId"])
+	sh, err := h.store.GetShipmentByID(id)
+	if err != nil || sh.OrderID != o.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("shipment not found"))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, sh)
+}
+
+// myOrder returns the order in the URL if it belongs to the authenticated user, and writes a not found error
+// otherwise.
+func (h *Handler) myOrder(w http.ResponseWriter, r *http.Request) (*types.Order, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	o, err := h.orders.GetOrderByID(id)
+	if err != nil || o.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order not found"))
+		return nil, false
+	}
+
+	return o, true
+}
+
+// handleGetShipments handles GET /admin/orders/{id}/shipments.
+func (h *Handler) handleGetShipments(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.orders.GetOrderByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	shipments, err := h.store.GetShipmentsByOrder(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, shipments)
+}
+
+// handleCreateShipment handles POST /admin/orders/{id}/shipments.
+// A shipment holds some or all of what is left to ship of the items of an order. Items waiting for stock cannot
+// ship yet, and neither can items whose hold on stock expired. The stock of the items shipped leaves the warehouse
+// they were routed to, if it has not already. The order moves to partially shipped or shipped, and the customer is
+// emailed about the package.
+func (h *Handler) handleCreateShipment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ShipmentPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	o, err := h.orders.GetOrderByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if o.Status == types.OrderCancelled {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order %d is cancelled", o.ID))
+		return
+	}
+
+	items := map[int]types.OrderItem{}
+	for _, item := range o.Items {
+		items[item.ID] = item
+	}
+
+	sh := types.Shipment{
+		OrderID:        o.ID,
+		Carrier:        strings.TrimSpace(payload.Carrier),
+		TrackingNumber: strings.TrimSpace(payload.TrackingNumber),
+		TrackingURL:    payload.TrackingURL,
+		Status:         types.ShipmentLabelCreated,
+	}
+	for _, line := range payload.Lines {
+		item, ok := items[line.OrderItemID]
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order item %d is not in order %d", line.OrderItemID, o.ID))
+			return
+		}
+		if item.ReservationID == nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order item %d is waiting for stock", item.ID))
+			return
+		}
+		if slices.ContainsFunc(sh.Lines, func(l types.ShipmentLine) bool { return l.OrderItemID == item.ID }) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order item %d is listed twice", item.ID))
+			return
+		}
+		sh.Lines = append(sh.Lines, types.ShipmentLine{OrderItemID: item.ID, SKU: item.SKU, Quantity: line.Quantity})
+	}
+	for _, line := range sh.Lines {
+		if status, err := h.takeStock(items[line.OrderItemID]); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+	}
+	if sh.TrackingURL == "" {
+		sh.TrackingURL = TrackingURL(sh.Carrier, sh.TrackingNumber)
+	}
+	if payload.Status != "" {
+		setStatus(&sh, payload.Status, h.now().UTC())
+	}
+
+	shipmentID, err := h.store.CreateShipment(sh)
+	if errors.Is(err, types.ErrOverShipped) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetShipmentByID(shipmentID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.updateOrder(o, created, true)
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// takeStock makes sure the stock held for an item has left the warehouse it ships from, committing its reservation
+// there if that did not happen when the order was placed. On failure it also returns the HTTP status code to
+// respond with.
+func (h *Handler) takeStock(item types.OrderItem) (int, error) {
+	r, err := h.inventory.GetReservation(*item.ReservationID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	switch {
+	case r.Status == types.ReservationCommitted:
+		return 0, nil
+	case r.Status == types.ReservationExpired || (r.Status == types.ReservationActive && !r.ExpiresAt.After(h.now())):
+		return h.requeue(item)
+	case r.Status != types.ReservationActive:
+		return http.StatusConflict, fmt.Errorf("the stock held for order item %d was %s", item.ID, r.Status)
+	case item.WarehouseID == nil:
+		return http.StatusConflict, fmt.Errorf("order item %d has no warehouse to ship from", item.ID)
+	}
+
+	err = h.inventory.CommitReservation(r.ID, *item.WarehouseID)
+	if errors.Is(err, types.ErrReservationExpired) {
+		return h.requeue(item)
+	}
+	if errors.Is(err, types.ErrInsufficientStock) {
+		return http.StatusConflict, fmt.Errorf("warehouse %d does not have the stock of order item %d", *item.WarehouseID, item.ID)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
+
+// requeue puts an item whose hold on stock expired back to wait for stock, so the next allocation of its SKU holds
+// stock for it again, and returns the HTTP status code and error to respond with. An order that was waiting for
+// nothing else is backordered.
+func (h *Handler) requeue(item types.OrderItem) (int, error) {
+	if err := h.orders.RequeueItem(*item.ReservationID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	o, err := h.orders.GetOrderByID(item.OrderID)
+	if err == nil && o.Status == types.OrderPending {
+		err = h.orders.UpdateOrderStatus(o.ID, types.OrderBackordered)
+	}
+	if err != nil {
+		log.Printf("failed to mark order %d as backordered: %v", item.OrderID, err)
+	}
+
+	return http.StatusConflict, fmt.Errorf("the stock held for order item %d has expired, so it waits for stock again", item.ID)
+}
+
+// handleUpdateShipment handles PATCH /admin/shipments/{id}.
+// Only the fields present in the payload change. A new carrier or tracking number gives the shipment the
+// tracking URL of the carrier, unless the payload sets one. Statuses only move forward; once every shipment of an
+// order is delivered, so is the order, and the customer is emailed when their package arrives.
+func (h *Handler) handleUpdateShipment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.UpdateShipmentPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	sh, err := h.store.GetShipmentByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	delivered := sh.Status == types.ShipmentDelivered
+
+	if payload.Carrier != nil {
+		if sh.Carrier = strings.TrimSpace(*payload.Carrier); sh.Carrier == "" {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("carrier cannot be empty"))
+			return
+		}
+	}
+	if payload.TrackingNumber != nil {
+		sh.TrackingNumber = strings.TrimSpace(*payload.TrackingNumber)
+	}
+	switch {
+	case payload.TrackingURL != nil:
+		if *payload.TrackingURL != "" && utils.Validate.Var(*payload.TrackingURL, "url") != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid tracking URL %q", *payload.TrackingURL))
+			return
+		}
+		sh.TrackingURL = *payload.TrackingURL
+	case payload.Carrier != nil || payload.TrackingNumber != nil:
+		sh.TrackingURL = TrackingURL(sh.Carrier, sh.TrackingNumber)
+	}
+	if payload.Status != nil {
+		if slices.Index(types.ShipmentStatuses, *payload.Status) < slices.Index(types.ShipmentStatuses, sh.Status) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("shipment %d is already %s", sh.ID, sh.Status))
+			return
+		}
+		setStatus(sh, *payload.Status, h.now().UTC())
+	}
+
+	if err := h.store.UpdateShipment(*sh); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !delivered && sh.Status == types.ShipmentDelivered {
+		o, err := h.orders.GetOrderByID(sh.OrderID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		h.updateOrder(o, sh, false)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, sh)
+}
+
+// updateOrder brings the status of an order up to date with its shipments after one was created or delivered, and
+// emails the customer about it. Failures are logged rather than returned: the shipment itself is already saved.
+func (h *Handler) updateOrder(o *types.Order, sh *types.Shipment, created bool) {
+	shipments, err := h.store.GetShipmentsByOrder(o.ID)
+	if err != nil {
+		log.Printf("failed to update status of order %d: %v", o.ID, err)
+		return
+	}
+
+	status := orderStatus(o, shipments)
+	if status != o.Status {
+		if err := h.orders.UpdateOrderStatus(o.ID, status); err != nil {
+			log.Printf("failed to update status of order %d: %v", o.ID, err)
+		}
+	}
+
+	u, err := h.userStore.GetUserByID(o.UserID)
+	if err != nil {
+		log.Printf("failed to email shipment %d of order %d: %v", sh.ID, o.ID, err)
+		return
+	}
+
+	email := deliveredEmail(u, o, sh)
+	if created {
+		email = shippedEmail(u, o, sh, status == types.OrderPartiallyShipped)
+	}
+	if err := h.mailer.Send(email); err != nil {
+		log.Printf("failed to email shipment %d of order %d: %v", sh.ID, o.ID, err)
+	}
+}
+
+// setStatus moves a shipment to a status, recording when it left and arrived. A package delivered without having
+// been marked in transit is taken to have left when it arrived.
+func setStatus(sh *types.Shipment, status string, now time.Time) {
+	sh.Status = status
+	if status != types.ShipmentLabelCreated && sh.ShippedAt == nil {
+		sh.ShippedAt = &now
+	}
+	if status == types.ShipmentDelivered && sh.DeliveredAt == nil {
+		sh.DeliveredAt = &now
+	}
+}
+
+// orderStatus returns the status an order has given its shipments. An item counts as shipped once it is in a
+// shipment. Until something ships, the order keeps its status; then it is partially shipped, shipped once every
+// item is, and delivered once every shipment is too.
+func orderStatus(o *types.Order, shipments []*types.Shipment) string {
+	shipped := map[int]int{}
+	delivered := true
+	for _, sh := range shipments {
+		for _, line := range sh.Lines {
+			shipped[line.OrderItemID] += line.Quantity
+		}
+		delivered = delivered && sh.Status == types.ShipmentDelivered
+	}
+	if len(shipped) == 0 {
+		return o.Status
+	}
+
+	for _, item := range o.Items {
+		if shipped[item.ID] < item.Quantity {
+			return types.OrderPartiallyShipped
+		}
+	}
+	if delivered {
+		return types.OrderDelivered
+	}
+	return types.OrderShipped
+}
@@ -0,0 +1,460 @@
+package shipment
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for shipment timestamps
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for shipment types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestShipmentHandlers tests shipping orders and following their packages.
+func TestShipmentHandlers(t *testing.T) {
+	id := func(n int) *int { return &n }
+	later, earlier := time.Now().Add(time.Hour), time.Now().Add(-time.Minute)
+
+	// Create a user store with an admin (ID 1), Jane (ID 2) and another customer (ID 3).
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleAdmin},
+		2: {ID: 2, Role: types.RoleCustomer, FirstName: "Jane", Email: "jane@example.com"},
+		3: {ID: 3, Role: types.RoleCustomer},
+	}}
+
+	t.Run("should ship an order in several packages and follow them to delivery", func(t *testing.T) {
+		// Jane ordered 2 shirts and a mug. The shirts were taken from warehouse 1 when the order was placed; the mug
+		// is still held for it.
+		orders := &mockOrderStore{orders: map[int]*types.Order{
+			1: {ID: 1, UserID: 2, Status: types.OrderPending, Items: []types.OrderItem{
+				{ID: 11, OrderID: 1, SKU: "SHIRT", Quantity: 2, ReservationID: id(1), WarehouseID: id(1)},
+				{ID: 12, OrderID: 1, SKU: "MUG", Quantity: 1, ReservationID: id(2), WarehouseID: id(1)},
+			}},
+		}}
+		inventory := &mockInventoryStore{reservations: map[int]*types.Reservation{
+			1: {ID: 1, SKU: "SHIRT", Quantity: 2, Status: types.ReservationCommitted, ExpiresAt: earlier},
+			2: {ID: 2, SKU: "MUG", Quantity: 1, Status: types.ReservationActive, ExpiresAt: later},
+		}}
+		mailer := &mockMailer{}
+
+		router := mux.NewRouter()
+		NewHandler(&mockShipmentStore{orders: orders}, orders, inventory, mailer, userStore).RegisterRoutes(router)
+
+		rr := send(t, router, http.MethodPost, "/admin/orders/1/shipments", `{"carrier": "DHL", "trackingNumber": "JD0001", "lines": [{"orderItemId": 11, "quantity": 1}]}`, 1)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		var sh types.Shipment
+		json.NewDecoder(rr.Body).Decode(&sh)
+		if sh.ID != 1 || sh.Status != types.ShipmentLabelCreated || sh.ShippedAt != nil || !strings.Contains(sh.TrackingURL, "dhl.com") || !strings.HasSuffix(sh.TrackingURL, "=JD0001") {
+			t.Errorf("expected a labelled shipment tracked at DHL, got %+v", sh)
+		}
+		if status := orders.orders[1].Status; status != types.OrderPartiallyShipped {
+			t.Errorf("expected the order to be %s, got %s", types.OrderPartiallyShipped, status)
+		}
+		if len(mailer.sent) != 1 || mailer.sent[0].To != "jane@example.com" || !strings.Contains(mailer.sent[0].Body, "Part of your order #1") || !strings.Contains(mailer.sent[0].Body, sh.TrackingURL) {
+			t.Errorf("expected Jane to be emailed about the first package, got %+v", mailer.sent)
+		}
+
+		rr = send(t, router, http.MethodPost, "/admin/orders/1/shipments", `{"carrier": "PostNL", "status": "in_transit", "lines": [{"orderItemId": 11, "quantity": 1}, {"orderItemId": 12, "quantity": 1}]}`, 1)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		json.NewDecoder(rr.Body).Decode(&sh)
+		if sh.TrackingURL != "" || sh.ShippedAt == nil || len(sh.Lines) != 2 || sh.Lines[1].SKU != "MUG" {
+			t.Errorf("expected an untracked shipment in transit with both items, got %+v", sh)
+		}
+		if status := orders.orders[1].Status; status != types.OrderShipped {
+			t.Errorf("expected the order to be %s, got %s", types.OrderShipped, status)
+		}
+		if r := inventory.reservations[2]; r.Status != types.ReservationCommitted || inventory.sales[2] != 1 {
+			t.Errorf("expected the mug to be taken from warehouse 1, got %+v", r)
+		}
+		if len(mailer.sent) != 2 || !strings.Contains(mailer.sent[1].Body, "Your order #1 is on its way with PostNL") {
+			t.Errorf("expected Jane to be emailed about the last package, got %+v", mailer.sent)
+		}
+
+		if rr := send(t, router, http.MethodPatch, "/admin/shipments/1", `{"status": "delivered"}`, 1); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if status := orders.orders[1].Status; status != types.OrderShipped {
+			t.Errorf("expected the order to stay %s while a package is underway, got %s", types.OrderShipped, status)
+		}
+		rr = send(t, router, http.MethodPatch, "/admin/shipments/2", `{"status": "delivered"}`, 1)
+		json.NewDecoder(rr.Body).Decode(&sh)
+		if sh.DeliveredAt == nil || sh.ShippedAt == nil {
+			t.Errorf("expected the shipment to have left and arrived, got %+v", sh)
+		}
+		if status := orders.orders[1].Status; status != types.OrderDelivered {
+			t.Errorf("expected the order to be %s, got %s", types.OrderDelivered, status)
+		}
+		if len(mailer.sent) != 4 || mailer.sent[3].Subject != "Your order #1 was delivered" {
+			t.Errorf("expected Jane to be emailed about each delivery, got %+v", mailer.sent)
+		}
+
+		if rr := send(t, router, http.MethodPatch, "/admin/shipments/2", `{"status": "in_transit"}`, 1); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d moving a shipment back, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(t, router, http.MethodPatch, "/admin/shipments/2", `{"status": "delivered"}`, 1); rr.Code != http.StatusOK || len(mailer.sent) != 4 {
+			t.Errorf("expected no second delivery email, got %d and %d emails", rr.Code, len(mailer.sent))
+		}
+	})
+
+	t.Run("should show customers the packages of their own orders only", func(t *testing.T) {
+		// Jane has an order of a mug, and another one waiting for stock.
+		orders := &mockOrderStore{orders: map[int]*types.Order{
+			1: {ID: 1, UserID: 2, Status: types.OrderPending, Items: []types.OrderItem{
+				{ID: 12, OrderID: 1, SKU: "MUG", Quantity: 1, ReservationID: id(2), WarehouseID: id(1)},
+			}},
+			2: {ID: 2, UserID: 2, Status: types.OrderBackordered, Items: []types.OrderItem{{ID: 21, OrderID: 2, SKU: "BACK", Quantity: 1}}},
+		}}
+		inventory := &mockInventoryStore{reservations: map[int]*types.Reservation{
+			2: {ID: 2, SKU: "MUG", Quantity: 1, Status: types.ReservationActive, ExpiresAt: later},
+		}}
+
+		router := mux.NewRouter()
+		NewHandler(&mockShipmentStore{orders: orders}, orders, inventory, &mockMailer{}, userStore).RegisterRoutes(router)
+		send(t, router, http.MethodPost, "/admin/orders/1/shipments", `{"carrier": "UPS", "trackingNumber": "1Z999", "lines": [{"orderItemId": 12, "quantity": 1}]}`, 1)
+
+		rr := send(t, router, http.MethodGet, "/orders/1/shipments", "", 2)
+		var shipments []types.Shipment
+		json.NewDecoder(rr.Body).Decode(&shipments)
+		if rr.Code != http.StatusOK || len(shipments) != 1 || shipments[0].TrackingURL != "https://www.ups.com/track?tracknum=1Z999" {
+			t.Errorf("expected the package tracked at UPS, got %d: %+v", rr.Code, shipments)
+		}
+		if rr := send(t, router, http.MethodGet, "/orders/1/shipments/1", "", 2); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		for _, c := range []struct {
+			target string
+			userID int
+		}{
+			{"/orders/1/shipments", 3},
+			{"/orders/1/shipments/1", 3},
+			{"/orders/2/shipments/1", 2},
+			{"/orders/9/shipments", 2},
+		} {
+			if rr := send(t, router, http.MethodGet, c.target, "", c.userID); rr.Code != http.StatusNotFound {
+				t.Errorf("%s as user %d: expected status code %d, got %d", c.target, c.userID, http.StatusNotFound, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should refuse shipments that cannot ship", func(t *testing.T) {
+		// Jane has an order of 2 shirts and a mug in stock (order 1), an order waiting for stock (order 2), a
+		// cancelled order (order 3) and an order whose hold on stock expired (order 4).
+		orders := &mockOrderStore{orders: map[int]*types.Order{
+			1: {ID: 1, UserID: 2, Status: types.OrderPending, Items: []types.OrderItem{
+				{ID: 11, OrderID: 1, SKU: "SHIRT", Quantity: 2, ReservationID: id(1), WarehouseID: id(1)},
+				{ID: 12, OrderID: 1, SKU: "MUG", Quantity: 1, ReservationID: id(2), WarehouseID: id(1)},
+			}},
+			2: {ID: 2, UserID: 2, Status: types.OrderBackordered, Items: []types.OrderItem{{ID: 21, OrderID: 2, SKU: "BACK", Quantity: 1}}},
+			3: {ID: 3, UserID: 2, Status: types.OrderCancelled, Items: []types.OrderItem{
+				{ID: 31, OrderID: 3, SKU: "SHIRT", Quantity: 1, ReservationID: id(3), WarehouseID: id(1)},
+			}},
+			4: {ID: 4, UserID: 2, Status: types.OrderPending, Items: []types.OrderItem{
+				{ID: 41, OrderID: 4, SKU: "SHIRT", Quantity: 1, ReservationID: id(4), WarehouseID: id(1)},
+			}},
+		}}
+		inventory := &mockInventoryStore{reservations: map[int]*types.Reservation{
+			1: {ID: 1, SKU: "SHIRT", Quantity: 2, Status: types.ReservationCommitted, ExpiresAt: earlier},
+			2: {ID: 2, SKU: "MUG", Quantity: 1, Status: types.ReservationActive, ExpiresAt: later},
+			3: {ID: 3, SKU: "SHIRT", Quantity: 1, Status: types.ReservationActive, ExpiresAt: later},
+			4: {ID: 4, SKU: "SHIRT", Quantity: 1, Status: types.ReservationActive, ExpiresAt: earlier},
+		}}
+		store := &mockShipmentStore{orders: orders}
+		mailer := &mockMailer{}
+
+		router := mux.NewRouter()
+		NewHandler(store, orders, inventory, mailer, userStore).RegisterRoutes(router)
+
+		for _, c := range []struct {
+			target string
+			body   string
+			code   int
+		}{
+			{"/admin/orders/1/shipments", `{"carrier": "DHL", "lines": [{"orderItemId": 11, "quantity": 3}]}`, http.StatusBadRequest},
+			{"/admin/orders/1/shipments", `{"carrier": "DHL", "lines": [{"orderItemId": 21, "quantity": 1}]}`, http.StatusBadRequest},
+			{"/admin/orders/1/shipments", `{"carrier": "DHL", "lines": [{"orderItemId": 11, "quantity": 1}, {"orderItemId": 11, "quantity": 1}]}`, http.StatusBadRequest},
+			{"/admin/orders/1/shipments", `{"lines": [{"orderItemId": 11, "quantity": 1}]}`, http.StatusBadRequest},
+			{"/admin/orders/1/shipments", `{"carrier": "DHL", "lines": []}`, http.StatusBadRequest},
+			{"/admin/orders/2/shipments", `{"carrier": "DHL", "lines": [{"orderItemId": 21, "quantity": 1}]}`, http.StatusBadRequest},
+			{"/admin/orders/3/shipments", `{"carrier": "DHL", "lines": [{"orderItemId": 31, "quantity": 1}]}`, http.StatusBadRequest},
+			{"/admin/orders/4/shipments", `{"carrier": "DHL", "lines": [{"orderItemId": 41, "quantity": 1}]}`, http.StatusConflict},
+			{"/admin/orders/9/shipments", `{"carrier": "DHL", "lines": [{"orderItemId": 11, "quantity": 1}]}`, http.StatusNotFound},
+		} {
+			if rr := send(t, router, http.MethodPost, c.target, c.body, 1); rr.Code != c.code {
+				t.Errorf("%s %s: expected status code %d, got %d", c.target, c.body, c.code, rr.Code)
+			}
+		}
+
+		if rr := send(t, router, http.MethodPost, "/admin/orders/1/shipments", `{"carrier": "DHL", "lines": [{"orderItemId": 11, "quantity": 1}]}`, 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a customer, got %d", http.StatusForbidden, rr.Code)
+		}
+
+		if len(store.shipments) != 0 || len(mailer.sent) != 0 || orders.orders[1].Status != types.OrderPending {
+			t.Errorf("expected nothing to ship, got %d shipments and %d emails", len(store.shipments), len(mailer.sent))
+		}
+
+		// The item whose hold expired waits for stock again, rather than being stuck.
+		if o := orders.orders[4]; o.Status != types.OrderBackordered || o.Items[0].ReservationID != nil || o.Items[0].WarehouseID != nil {
+			t.Errorf("expected order 4 to be backordered with its item waiting, got %+v", o)
+		}
+	})
+
+	t.Run("should update the tracking of a shipment", func(t *testing.T) {
+		// Jane's shirts left with DHL, without a tracking number.
+		orders := &mockOrderStore{orders: map[int]*types.Order{
+			1: {ID: 1, UserID: 2, Status: types.OrderShipped, Items: []types.OrderItem{{ID: 11, OrderID: 1, SKU: "SHIRT", Quantity: 2}}},
+		}}
+		store := &mockShipmentStore{orders: orders, shipments: []*types.Shipment{
+			{ID: 1, OrderID: 1, Carrier: "DHL", Status: types.ShipmentLabelCreated, Lines: []types.ShipmentLine{{OrderItemID: 11, SKU: "SHIRT", Quantity: 2}}},
+		}}
+
+		router := mux.NewRouter()
+		NewHandler(store, orders, &mockInventoryStore{}, &mockMailer{}, userStore).RegisterRoutes(router)
+
+		if rr := send(t, router, http.MethodPatch, "/admin/shipments/1", `{"carrier": "UPS", "trackingNumber": "1Z999"}`, 1); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if sh := store.shipments[0]; sh.Carrier != "UPS" || sh.TrackingURL != "https://www.ups.com/track?tracknum=1Z999" {
+			t.Errorf("expected the shipment to be tracked at UPS, got %+v", sh)
+		}
+
+		if rr := send(t, router, http.MethodPatch, "/admin/shipments/1", `{"trackingUrl": ""}`, 1); rr.Code != http.StatusOK || store.shipments[0].TrackingURL != "" {
+			t.Errorf("expected the tracking URL to be cleared, got %d: %+v", rr.Code, store.shipments[0])
+		}
+
+		for _, body := range []string{`{"trackingUrl": "not a url"}`, `{"carrier": " "}`, `{"status": "lost"}`} {
+			if rr := send(t, router, http.MethodPatch, "/admin/shipments/1", body, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", body, http.StatusBadRequest, rr.Code)
+			}
+		}
+		if rr := send(t, router, http.MethodPatch, "/admin/shipments/9", `{"status": "delivered"}`, 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+// TestOrderStatus tests the status orders get from their shipments.
+func TestOrderStatus(t *testing.T) {
+	o := &types.Order{Status: types.OrderBackordered, Items: []types.OrderItem{{ID: 1, Quantity: 2}, {ID: 2, Quantity: 1}}}
+	shipment := func(status string, lines ...types.ShipmentLine) *types.Shipment {
+		return &types.Shipment{Status: status, Lines: lines}
+	}
+
+	for _, c := range []struct {
+		name      string
+		shipments []*types.Shipment
+		expected  string
+	}{
+		{"nothing shipped", nil, types.OrderBackordered},
+		{"some units shipped", []*types.Shipment{shipment(types.ShipmentDelivered, types.ShipmentLine{OrderItemID: 1, Quantity: 2})}, types.OrderPartiallyShipped},
+		{"everything shipped", []*types.Shipment{
+			shipment(types.ShipmentDelivered, types.ShipmentLine{OrderItemID: 1, Quantity: 1}),
+			shipment(types.ShipmentLabelCreated, types.ShipmentLine{OrderItemID: 1, Quantity: 1}, types.ShipmentLine{OrderItemID: 2, Quantity: 1}),
+		}, types.OrderShipped},
+		{"everything delivered", []*types.Shipment{
+			shipment(types.ShipmentDelivered, types.ShipmentLine{OrderItemID: 1, Quantity: 2}, types.ShipmentLine{OrderItemID: 2, Quantity: 1}),
+		}, types.OrderDelivered},
+	} {
+		if status := orderStatus(o, c.shipments); status != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, status)
+		}
+	}
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockShipmentStore is an in-memory implementation of the ShipmentStore interface.
+type mockShipmentStore struct {
+	orders    *mockOrderStore   // The orders, to check what is left to ship.
+	shipments []*types.Shipment // Shipments, where the ID is the index plus one.
+}
+
+// CreateShipment is a mock method that appends the shipment, failing if it ships more than is left of an item.
+func (m *mockShipmentStore) CreateShipment(sh types.Shipment) (int, error) {
+	o, ok := m.orders.orders[sh.OrderID]
+	if !ok {
+		return 0, fmt.Errorf("order not found")
+	}
+
+	for _, line := range sh.Lines {
+		left := 0
+		for _, item := range o.Items {
+			if item.ID == line.OrderItemID {
+				left = item.Quantity
+			}
+		}
+		for _, s := range m.shipments {
+			for _, l := range s.Lines {
+				if l.OrderItemID == line.OrderItemID {
+					left -= l.Quantity
+				}
+			}
+		}
+		if line.Quantity > left {
+			return 0, types.ErrOverShipped
+		}
+	}
+
+	sh.ID = len(m.shipments) + 1
+	sh.CreatedAt = time.Now()
+	m.shipments = append(m.shipments, &sh)
+	return sh.ID, nil
+}
+
+// GetShipmentByID is a mock method that returns a copy of the shipment with the given ID.
+func (m *mockShipmentStore) GetShipmentByID(id int) (*types.Shipment, error) {
+	if id < 1 || id > len(m.shipments) {
+		return nil, fmt.Errorf("shipment not found")
+	}
+
+	c := *m.shipments[id-1]
+	return &c, nil
+}
+
+// GetShipmentsByOrder is a mock method that returns copies of the shipments of an order.
+func (m *mockShipmentStore) GetShipmentsByOrder(orderID int) ([]*types.Shipment, error) {
+	shipments := []*types.Shipment{}
+	for _, sh := range m.shipments {
+		if sh.OrderID == orderID {
+			c := *sh
+			shipments = append(shipments, &c)
+		}
+	}
+	return shipments, nil
+}
+
+// UpdateShipment is a mock method that replaces the stored shipment.
+func (m *mockShipmentStore) UpdateShipment(sh types.Shipment) error {
+	if sh.ID < 1 || sh.ID > len(m.shipments) {
+		return fmt.Errorf("shipment not found")
+	}
+
+	m.shipments[sh.ID-1] = &sh
+	return nil
+}
+
+// mockOrderStore is an in-memory implementation of the parts of the OrderStore interface used by the handler.
+type mockOrderStore struct {
+	types.OrderStore
+	orders map[int]*types.Order // Orders by ID.
+}
+
+// GetOrderByID is a mock method that returns a copy of the order with the given ID.
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	c := *o
+	return &c, nil
+}
+
+// RequeueItem is a mock method that clears the reservation and warehouse of the item holding a reservation.
+func (m *mockOrderStore) RequeueItem(reservationID int) error {
+	for _, o := range m.orders {
+		for i := range o.Items {
+			if id := o.Items[i].ReservationID; id != nil && *id == reservationID {
+				o.Items[i].ReservationID, o.Items[i].WarehouseID = nil, nil
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no order item holds reservation %d", reservationID)
+}
+
+// UpdateOrderStatus is a mock method that changes the status of the stored order.
+func (m *mockOrderStore) UpdateOrderStatus(id int, status string) error {
+	m.orders[id].Status = status
+	return nil
+}
+
+// mockInventoryStore is an in-memory implementation of the parts of the InventoryStore interface used by the handler.
+type mockInventoryStore struct {
+	types.InventoryStore
+	reservations map[int]*types.Reservation // Reservations by ID.
+	sales        map[int]int                // Warehouse committed reservations were sold from, by reservation ID.
+}
+
+// GetReservation is a mock method that returns a copy of the reservation with the given ID.
+func (m *mockInventoryStore) GetReservation(id int) (*types.Reservation, error) {
+	r, ok := m.reservations[id]
+	if !ok {
+		return nil, fmt.Errorf("reservation %d not found", id)
+	}
+
+	c := *r
+	return &c, nil
+}
+
+// CommitReservation is a mock method that records the sale of an unexpired reservation from a warehouse.
+func (m *mockInventoryStore) CommitReservation(id, warehouseID int) error {
+	r := m.reservations[id]
+	if r.Status != types.ReservationActive || !r.ExpiresAt.After(time.Now()) {
+		return types.ErrReservationExpired
+	}
+
+	r.Status = types.ReservationCommitted
+	if m.sales == nil {
+		m.sales = map[int]int{}
+	}
+	m.sales[id] = warehouseID
+	return nil
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
+
+// mockMailer is an implementation of the Mailer interface that records the emails instead of sending them.
+type mockMailer struct {
+	sent []types.Email
+}
+
+// Send is a mock method that records the email.
+func (m *mockMailer) Send(email types.Email) error {
+	m.sent = append(m.sent, email)
+	return nil
+}
@@ -0,0 +1,204 @@
+package shipment
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+	"strings"
+
+	// Import the types package for the shipment types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// shipmentColumns lists the columns of the shipments table, in the order scanRowIntoShipment reads them.
+const shipmentColumns = "id, orderId, carrier, trackingNumber, trackingUrl, status, shippedAt, deliveredAt, createdAt"
+
+// Store struct represents the data store of shipments.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateShipment is a method on the Store struct that stores a shipment and its lines in a single transaction,
+// and returns its ID. The order is locked while the quantities already shipped are read.
+func (s *Store) CreateShipment(sh types.Shipment) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var orderID int
+	err = tx.QueryRow("SELECT id FROM orders WHERE id = ? FOR UPDATE", sh.OrderID).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("order not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range sh.Lines {
+		var ordered, shipped int
+		err := tx.QueryRow(`
+			SELECT oi.quantity, COALESCE((SELECT SUM(sl.quantity) FROM shipment_lines sl WHERE sl.orderItemId = oi.id), 0)
+			FROM order_items oi WHERE oi.id = ? AND oi.orderId = ?`,
+			line.OrderItemID, sh.OrderID,
+		).Scan(&ordered, &shipped)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("order item %d not found", line.OrderItemID)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if shipped+line.Quantity > ordered {
+			return 0, fmt.Errorf("%w: order item %d has %d left", types.ErrOverShipped, line.OrderItemID, ordered-shipped)
+		}
+	}
+
+	res, err := tx.Exec(
+		`INSERT INTO shipments (orderId, carrier, trackingNumber, trackingUrl, status, shippedAt, deliveredAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sh.OrderID, sh.Carrier, sh.TrackingNumber, sh.TrackingURL, sh.Status, sh.ShippedAt, sh.DeliveredAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range sh.Lines {
+		_, err := tx.Exec(
+			"INSERT INTO shipment_lines (shipmentId, orderItemId, quantity) VALUES (?, ?, ?)",
+			id, line.OrderItemID, line.Quantity,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int(id), tx.Commit()
+}
+
+// GetShipmentByID is a method on the Store struct that retrieves a shipment, with its lines, by ID.
+func (s *Store) GetShipmentByID(id int) (*types.Shipment, error) {
+	shipments, err := s.queryShipments("SELECT "+shipmentColumns+" FROM shipments WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(shipments) == 0 {
+		return nil, fmt.Errorf("shipment not found")
+	}
+
+	return shipments[0], nil
+}
+
+// GetShipmentsByOrder is a method on the Store struct that retrieves the shipments of an order, with their lines,
+// oldest first.
+func (s *Store) GetShipmentsByOrder(orderID int) ([]*types.Shipment, error) {
+	return s.queryShipments("SELECT "+shipmentColumns+" FROM shipments WHERE orderId = ? ORDER BY id", orderID)
+}
+
+// UpdateShipment is a method on the Store struct that changes the carrier, tracking and status of a shipment.
+func (s *Store) UpdateShipment(sh types.Shipment) error {
+	res, err := s.db.Exec(
+		`UPDATE shipments SET carrier = ?, trackingNumber = ?, trackingUrl = ?, status = ?, shippedAt = ?, deliveredAt = ?
+		WHERE id = ?`,
+		sh.Carrier, sh.TrackingNumber, sh.TrackingURL, sh.Status, sh.ShippedAt, sh.DeliveredAt, sh.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := s.GetShipmentByID(sh.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryShipments runs a query selecting shipmentColumns and returns the shipments, with their lines.
+func (s *Store) queryShipments(query string, args ...any) ([]*types.Shipment, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shipments := []*types.Shipment{}
+	byID := map[int]*types.Shipment{}
+	for rows.Next() {
+		sh, err := scanRowIntoShipment(rows)
+		if err != nil {
+			return nil, err
+		}
+		shipments = append(shipments, sh)
+		byID[sh.ID] = sh
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(shipments) == 0 {
+		return shipments, nil
+	}
+
+	// Load the lines of all the shipments at once.
+	ids := make([]any, 0, len(shipments))
+	for _, sh := range shipments {
+		ids = append(ids, sh.ID)
+	}
+	lines, err := s.db.Query(`
+		SELECT sl.shipmentId, sl.orderItemId, oi.sku, sl.quantity
+		FROM shipment_lines sl JOIN order_items oi ON oi.id = sl.orderItemId
+		WHERE sl.shipmentId IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+		ORDER BY sl.orderItemId`, ids...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var shipmentID int
+		var line types.ShipmentLine
+		if err := lines.Scan(&shipmentID, &line.OrderItemID, &line.SKU, &line.Quantity); err != nil {
+			return nil, err
+		}
+		byID[shipmentID].Lines = append(byID[shipmentID].Lines, line)
+	}
+
+	return shipments, lines.Err()
+}
+
+// scanRowIntoShipment scans a row selected with shipmentColumns into a Shipment, without its lines.
+func scanRowIntoShipment(rows *sql.Rows) (*types.Shipment, error) {
+	sh := &types.Shipment{Lines: []types.ShipmentLine{}}
+	var shippedAt, deliveredAt sql.NullTime
+
+	err := rows.Scan(&sh.ID, &sh.OrderID, &sh.Carrier, &sh.TrackingNumber, &sh.TrackingURL, &sh.Status,
+		&shippedAt, &deliveredAt, &sh.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if shippedAt.Valid {
+		sh.ShippedAt = &shippedAt.Time
+	}
+	if deliveredAt.Valid {
+		sh.DeliveredAt = &deliveredAt.Time
+	}
+
+	return sh, nil
+}
@@ -0,0 +1,148 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	// Import the types package for the shipping types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Quoter struct lists the shipping options of carts: the active methods of the zone of the address, priced
+// by their providers.
+type Quoter struct {
+	store     types.ShippingStore                   // Interface for shipping zones and methods.
+	pricer    types.Pricer                          // Converts between the base currency and the currency of carts.
+	providers map[string]types.ShippingRateProvider // Rate providers by name.
+}
+
+// NewQuoter is a constructor function that returns a new Quoter instance. Methods can be priced by their rate
+// table and by the given providers, such as carrier APIs; a provider named like the rate table replaces it.
+func NewQuoter(store types.ShippingStore, pricer types.Pricer, providers ...types.ShippingRateProvider) *Quoter {
+	q := &Quoter{store: store, pricer: pricer, providers: map[string]types.ShippingRateProvider{}}
+	for _, p := range append([]types.ShippingRateProvider{NewTableRates()}, providers...) {
+		q.providers[p.Name()] = p
+	}
+
+	return q
+}
+
+// HasProvider is a method on the Quoter struct that returns whether methods can be priced by the named provider.
+func (q *Quoter) HasProvider(name string) bool {
+	_, ok := q.providers[name]
+	return ok
+}
+
+// ShippingOptions is a method on the Quoter struct that returns the methods that can ship a priced cart to an
+// address, cheapest first.
+//
+// Shipping is free when a promotion makes it free, or when the cart, after discounts, reaches the free shipping
+// threshold of the method. Methods whose provider fails are left out, so one carrier being down does not stop
+// customers from checking out with another.
+func (q *Quoter) ShippingOptions(address types.Address, pricing *types.CartPricing) ([]types.ShippingOption, error) {
+	options := []types.ShippingOption{}
+
+	zones, err := q.store.GetShippingZones()
+	if err != nil {
+		return nil, err
+	}
+	zone := MatchZone(zones, address)
+	if zone == nil {
+		return options, nil
+	}
+
+	methods, err := q.store.GetShippingMethods(zone.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	parcel, err := q.parcel(address, pricing)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range methods {
+		if !m.Active {
+			continue
+		}
+
+		option, err := q.quote(*m, parcel, pricing)
+		if errors.Is(err, types.ErrNoShippingRate) {
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to quote shipping method %d: %v", m.ID, err)
+			continue
+		}
+		options = append(options, *option)
+	}
+
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i].Price.Amount < options[j].Price.Amount
+	})
+
+	return options, nil
+}
+
+// parcel returns the parcel of a priced cart, valued in the base currency.
+func (q *Quoter) parcel(address types.Address, pricing *types.CartPricing) (types.Parcel, error) {
+	parcel := types.Parcel{Address: address}
+	for _, line := range pricing.Lines {
+		parcel.Weight += line.Weight
+		parcel.Items += line.Quantity
+	}
+
+	value, err := pricing.Subtotal.Sub(pricing.Discount)
+	if err != nil {
+		return types.Parcel{}, err
+	}
+	if value.Currency != q.pricer.Base() {
+		if value, err = q.pricer.Convert(value, q.pricer.Base()); err != nil {
+			return types.Parcel{}, err
+		}
+	}
+	parcel.Value = value
+
+	return parcel, nil
+}
+
+// quote prices a method for a parcel, in the currency of the cart.
+func (q *Quoter) quote(m types.ShippingMethod, parcel types.Parcel, pricing *types.CartPricing) (*types.ShippingOption, error) {
+	provider, ok := q.providers[m.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", m.Provider)
+	}
+
+	price, err := provider.Quote(m, parcel)
+	if err != nil {
+		return nil, err
+	}
+
+	free := pricing.FreeShipping
+	if m.FreeAbove != nil {
+		c, err := parcel.Value.Cmp(*m.FreeAbove)
+		if err != nil {
+			return nil, err
+		}
+		free = free || c >= 0
+	}
+
+	if free {
+		price = types.NewMoney(0, pricing.Currency)
+	} else if price.Currency != pricing.Currency {
+		if price, err = q.pricer.Convert(price, pricing.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.ShippingOption{
+		MethodID: m.ID,
+		Name:     m.Name,
+		Price:    price,
+		Free:     free,
+		MinDays:  m.MinDays,
+		MaxDays:  m.MaxDays,
+	}, nil
+}
@@ -0,0 +1,332 @@
+package shipping
+
+import (
+	"errors"  // Import the errors package to check returned errors
+	"fmt"     // Import the fmt package for formatted I/O operations
+	"testing" // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for shipping types
+)
+
+// TestMatchZone tests finding the zone of an address.
+func TestMatchZone(t *testing.T) {
+	zones := []*types.ShippingZone{
+		{ID: 1, Name: "Netherlands", Regions: []types.ShippingRegion{{Country: "NL"}}},
+		{ID: 2, Name: "Amsterdam", Regions: []types.ShippingRegion{{Country: "NL", PostalCodes: []string{"10*", "1100-1109"}}}},
+		{ID: 3, Name: "California", Regions: []types.ShippingRegion{{Country: "US", State: "CA"}}},
+		{ID: 4, Name: "Rest of world"},
+		{ID: 5, Name: "Also the Netherlands", Regions: []types.ShippingRegion{{Country: "NL"}}},
+		{ID: 6, Name: "Beverly Hills", Regions: []types.ShippingRegion{{Country: "US", State: "CA", PostalCodes: []string{"90210"}}}},
+	}
+
+	for _, tc := range []struct {
+		address types.Address
+		zone    int
+	}{
+		{types.Address{Country: "nl", PostalCode: "1011 ab"}, 2},
+		{types.Address{Country: "NL", PostalCode: "1105AZ"}, 2},
+		{types.Address{Country: "NL", PostalCode: "1110AA"}, 1},
+		{types.Address{Country: "NL"}, 1},
+		{types.Address{Country: "US", State: "ca", PostalCode: "90210-1234"}, 6},
+		{types.Address{Country: "US", State: "CA", PostalCode: "94000"}, 3},
+		{types.Address{Country: "US", State: "NY"}, 4},
+		{types.Address{Country: "JP"}, 4},
+	} {
+		if z := MatchZone(zones, tc.address); z == nil || z.ID != tc.zone {
+			t.Errorf("expected %+v to be in zone %d, got %+v", tc.address, tc.zone, z)
+		}
+	}
+
+	if z := MatchZone(zones[:3], types.Address{Country: "JP"}); z != nil {
+		t.Errorf("expected no zone without a rest of the world, got %+v", z)
+	}
+}
+
+// TestTableRates tests pricing parcels by rate tables.
+func TestTableRates(t *testing.T) {
+	euros := func(cents int64) *types.Money {
+		m := types.NewMoney(cents, "EUR")
+		return &m
+	}
+
+	byWeight := types.ShippingMethod{Basis: types.RateByWeight, Rates: []types.ShippingRate{
+		{MinWeight: 2000, Price: *euros(695)},
+		{MinWeight: 0, Price: *euros(495)},
+		{MinWeight: 10000, Price: *euros(1295)},
+	}}
+	byPrice := types.ShippingMethod{Basis: types.RateByPrice, Rates: []types.ShippingRate{
+		{MinPrice: euros(2500), Price: *euros(295)},
+		{MinPrice: euros(1000), Price: *euros(495)},
+	}}
+	byItems := types.ShippingMethod{Basis: types.RateByItems, Rates: []types.ShippingRate{
+		{MinItems: 1, Price: *euros(395)},
+		{MinItems: 5, Price: *euros(795)},
+	}}
+
+	for _, tc := range []struct {
+		method types.ShippingMethod
+		parcel types.Parcel
+		price  int64
+	}{
+		{byWeight, types.Parcel{Weight: 1999}, 495},
+		{byWeight, types.Parcel{Weight: 2000}, 695},
+		{byWeight, types.Parcel{Weight: 25000}, 1295},
+		{byPrice, types.Parcel{Value: *euros(1000)}, 495},
+		{byPrice, types.Parcel{Value: *euros(3000)}, 295},
+		{byItems, types.Parcel{Items: 4}, 395},
+		{byItems, types.Parcel{Items: 5}, 795},
+	} {
+		price, err := NewTableRates().Quote(tc.method, tc.parcel)
+		if err != nil || price != *euros(tc.price) {
+			t.Errorf("expected %d cents by %s for %+v, got %v (%v)", tc.price, tc.method.Basis, tc.parcel, price, err)
+		}
+	}
+
+	// Below the lowest bound, the method cannot ship the parcel.
+	if _, err := NewTableRates().Quote(byPrice, types.Parcel{Value: *euros(999)}); !errors.Is(err, types.ErrNoShippingRate) {
+		t.Errorf("expected no rate below the lowest bound, got %v", err)
+	}
+	if _, err := NewTableRates().Quote(byItems, types.Parcel{}); !errors.Is(err, types.ErrNoShippingRate) {
+		t.Errorf("expected no rate for an empty parcel, got %v", err)
+	}
+}
+
+// TestQuoter tests listing the shipping options of carts.
+func TestQuoter(t *testing.T) {
+	euros := func(cents int64) *types.Money {
+		m := types.NewMoney(cents, "EUR")
+		return &m
+	}
+
+	// newQuoter returns a quoter for two zones: the Netherlands with standard shipping by weight (free from
+	// 50.00 euros), express shipping by items, an inactive method, one by a failing carrier and one by a
+	// carrier quoting 3.00 euros; and the rest of the world with international shipping of 19.95 euros.
+	newQuoter := func() *Quoter {
+		store := newMockShippingStore()
+		store.CreateShippingZone(types.ShippingZone{Name: "Netherlands", Regions: []types.ShippingRegion{{Country: "NL"}}})
+		store.CreateShippingZone(types.ShippingZone{Name: "Rest of world"})
+		for _, m := range []types.ShippingMethod{
+			{ZoneID: 1, Name: "Standard", Provider: TableProvider, Basis: types.RateByWeight, Active: true, FreeAbove: euros(5000), MinDays: 1, MaxDays: 2,
+				Rates: []types.ShippingRate{{Price: *euros(495)}, {MinWeight: 2000, Price: *euros(695)}}},
+			{ZoneID: 1, Name: "Express", Provider: TableProvider, Basis: types.RateByItems, Active: true,
+				Rates: []types.ShippingRate{{MinItems: 1, Price: *euros(995)}}},
+			{ZoneID: 1, Name: "Retired", Provider: TableProvider, Basis: types.RateByItems,
+				Rates: []types.ShippingRate{{MinItems: 1, Price: *euros(100)}}},
+			{ZoneID: 1, Name: "Down", Provider: "down", Basis: types.RateByItems, Active: true},
+			{ZoneID: 1, Name: "Carrier", Provider: "carrier", Basis: types.RateByItems, Active: true},
+			{ZoneID: 2, Name: "International", Provider: TableProvider, Basis: types.RateByItems, Active: true,
+				Rates: []types.ShippingRate{{MinItems: 1, Price: *euros(1995)}}},
+		} {
+			store.CreateShippingMethod(m)
+		}
+
+		return NewQuoter(store, &mockPricer{}, &mockProvider{name: "down", err: fmt.Errorf("carrier unavailable")},
+			&mockProvider{name: "carrier", price: *euros(300)})
+	}
+
+	// pricing returns a priced cart of 1.5 kilos and 2 items, totalling the given amount after a discount of 1.00.
+	pricing := func(currency string, total int64) *types.CartPricing {
+		return &types.CartPricing{
+			Currency: currency,
+			Lines:    []types.PricedLine{{SKU: "SHIRT", Weight: 1500, Quantity: 2}},
+			Subtotal: types.NewMoney(total+100, currency),
+			Discount: types.NewMoney(100, currency),
+			Total:    types.NewMoney(total, currency),
+		}
+	}
+
+	names := func(options []types.ShippingOption) []string {
+		names := []string{}
+		for _, o := range options {
+			names = append(names, fmt.Sprintf("%s %s", o.Name, o.Price))
+		}
+		return names
+	}
+
+	t.Run("should list the active methods of the zone, cheapest first", func(t *testing.T) {
+		options, err := newQuoter().ShippingOptions(types.Address{Country: "NL"}, pricing("EUR", 2000))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "[Carrier 3.00 EUR Standard 4.95 EUR Express 9.95 EUR]"
+		if fmt.Sprint(names(options)) != expected {
+			t.Errorf("expected %s, got %v", expected, names(options))
+		}
+		if options[1].MethodID != 3 || options[1].MinDays != 1 || options[1].MaxDays != 2 || options[1].Free {
+			t.Errorf("expected the details of standard shipping, got %+v", options[1])
+		}
+
+		options, _ = newQuoter().ShippingOptions(types.Address{Country: "BE"}, pricing("EUR", 2000))
+		if fmt.Sprint(names(options)) != "[International 19.95 EUR]" {
+			t.Errorf("expected international shipping, got %v", names(options))
+		}
+	})
+
+	t.Run("should make shipping free from the threshold, or by promotion", func(t *testing.T) {
+		options, _ := newQuoter().ShippingOptions(types.Address{Country: "NL"}, pricing("EUR", 5000))
+		if options[0].Name != "Standard" || !options[0].Free || !options[0].Price.IsZero() {
+			t.Errorf("expected free standard shipping from 50.00, got %+v", options)
+		}
+
+		p := pricing("EUR", 2000)
+		p.FreeShipping = true
+		options, _ = newQuoter().ShippingOptions(types.Address{Country: "NL"}, p)
+		for _, o := range options {
+			if !o.Free || !o.Price.IsZero() {
+				t.Errorf("expected every method to be free, got %+v", o)
+			}
+		}
+	})
+
+	t.Run("should price in the currency of the cart", func(t *testing.T) {
+		// 98.00 dollars is 49.00 euros, just below the threshold.
+		options, err := newQuoter().ShippingOptions(types.Address{Country: "NL"}, pricing("USD", 9800))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "[Carrier 6.00 USD Standard 9.90 USD Express 19.90 USD]"
+		if fmt.Sprint(names(options)) != expected {
+			t.Errorf("expected %s, got %v", expected, names(options))
+		}
+	})
+}
+
+// mockShippingStore is an in-memory implementation of the ShippingStore interface.
+type mockShippingStore struct {
+	zones   map[int]*types.ShippingZone   // Zones by ID.
+	methods map[int]*types.ShippingMethod // Methods by ID.
+	nextID  int                           // Last ID given to a zone or method.
+}
+
+// newMockShippingStore returns an empty mockShippingStore.
+func newMockShippingStore() *mockShippingStore {
+	return &mockShippingStore{zones: map[int]*types.ShippingZone{}, methods: map[int]*types.ShippingMethod{}}
+}
+
+// GetShippingZones is a mock method that returns the zones, ordered by ID.
+func (m *mockShippingStore) GetShippingZones() ([]*types.ShippingZone, error) {
+	zones := []*types.ShippingZone{}
+	for id := 1; id <= m.nextID; id++ {
+		if z, ok := m.zones[id]; ok {
+			zones = append(zones, z)
+		}
+	}
+	return zones, nil
+}
+
+// GetShippingZoneByID is a mock method that returns the zone with the given ID.
+func (m *mockShippingStore) GetShippingZoneByID(id int) (*types.ShippingZone, error) {
+	z, ok := m.zones[id]
+	if !ok {
+		return nil, fmt.Errorf("shipping zone not found")
+	}
+	return z, nil
+}
+
+// CreateShippingZone is a mock method that stores a zone under the next ID.
+func (m *mockShippingStore) CreateShippingZone(z types.ShippingZone) (int, error) {
+	m.nextID++
+	z.ID = m.nextID
+	m.zones[z.ID] = &z
+	return z.ID, nil
+}
+
+// UpdateShippingZone is a mock method that replaces a zone.
+func (m *mockShippingStore) UpdateShippingZone(z types.ShippingZone) error {
+	m.zones[z.ID] = &z
+	return nil
+}
+
+// DeleteShippingZone is a mock method that removes a zone and its methods.
+func (m *mockShippingStore) DeleteShippingZone(id int) error {
+	delete(m.zones, id)
+	for methodID, method := range m.methods {
+		if method.ZoneID == id {
+			delete(m.methods, methodID)
+		}
+	}
+	return nil
+}
+
+// GetShippingMethods is a mock method that returns the methods of a zone, ordered by ID.
+func (m *mockShippingStore) GetShippingMethods(zoneID int) ([]*types.ShippingMethod, error) {
+	methods := []*types.ShippingMethod{}
+	for id := 1; id <= m.nextID; id++ {
+		if method, ok := m.methods[id]; ok && method.ZoneID == zoneID {
+			methods = append(methods, method)
+		}
+	}
+	return methods, nil
+}
+
+// GetShippingMethodByID is a mock method that returns the method with the given ID.
+func (m *mockShippingStore) GetShippingMethodByID(id int) (*types.ShippingMethod, error) {
+	method, ok := m.methods[id]
+	if !ok {
+		return nil, fmt.Errorf("shipping method not found")
+	}
+	return method, nil
+}
+
+// CreateShippingMethod is a mock method that stores a method under the next ID.
+func (m *mockShippingStore) CreateShippingMethod(method types.ShippingMethod) (int, error) {
+	m.nextID++
+	method.ID = m.nextID
+	m.methods[method.ID] = &method
+	return method.ID, nil
+}
+
+// UpdateShippingMethod is a mock method that replaces a method.
+func (m *mockShippingStore) UpdateShippingMethod(method types.ShippingMethod) error {
+	m.methods[method.ID] = &method
+	return nil
+}
+
+// DeleteShippingMethod is a mock method that removes a method.
+func (m *mockShippingStore) DeleteShippingMethod(id int) error {
+	delete(m.methods, id)
+	return nil
+}
+
+// mockProvider is an implementation of the ShippingRateProvider interface quoting the same price, or failing.
+type mockProvider struct {
+	name  string      // Name of the provider.
+	price types.Money // The price quoted.
+	err   error       // The error returned instead, if set.
+}
+
+// Name is a mock method that returns the name of the provider.
+func (m *mockProvider) Name() string {
+	return m.name
+}
+
+// Quote is a mock method that returns the price, or the error.
+func (m *mockProvider) Quote(method types.ShippingMethod, parcel types.Parcel) (types.Money, error) {
+	return m.price, m.err
+}
+
+// mockPricer is an implementation of the parts of the Pricer interface used for shipping. The base currency
+// is the euro, which buys 2 dollars.
+type mockPricer struct {
+	types.Pricer
+}
+
+// Base is a mock method that returns the euro.
+func (m *mockPricer) Base() string {
+	return "EUR"
+}
+
+// Convert is a mock method that converts between euros and dollars at 2 dollars to the euro.
+func (m *mockPricer) Convert(money types.Money, currency string) (types.Money, error) {
+	switch {
+	case money.Currency == currency:
+		return money, nil
+	case money.Currency == "EUR" && currency == "USD":
+		return types.NewMoney(money.Amount*2, "USD"), nil
+	case money.Currency == "USD" && currency == "EUR":
+		return types.NewMoney(money.Amount/2, "EUR"), nil
+	}
+	return types.Money{}, types.ErrUnsupportedCurrency
+}
@@ -0,0 +1,111 @@
+package shipping
+
+import (
+	"fmt"
+
+	// Import the types package for the shipping types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// TableProvider is the name of the provider pricing methods by their own rate table, which methods use unless
+// they name another.
+const TableProvider = "table"
+
+// TableRates struct is the ShippingRateProvider pricing methods by their rate table.
+type TableRates struct{}
+
+// NewTableRates is a constructor function that returns a new TableRates instance.
+func NewTableRates() *TableRates {
+	return &TableRates{}
+}
+
+// Name is a method on the TableRates struct that returns the name methods refer to it by.
+func (t *TableRates) Name() string {
+	return TableProvider
+}
+
+// Quote is a method on the TableRates struct that returns the price of the row of the rate table with the
+// highest bound the parcel reaches. Rows may be in any order. It returns ErrNoShippingRate if the parcel reaches
+// no row, such as parcels lighter than the first weight of the table.
+func (t *TableRates) Quote(method types.ShippingMethod, parcel types.Parcel) (types.Money, error) {
+	var best *types.ShippingRate
+	for i := range method.Rates {
+		rate := &method.Rates[i]
+
+		reached, err := reaches(method.Basis, rate, parcel)
+		if err != nil {
+			return types.Money{}, err
+		}
+		if !reached {
+			continue
+		}
+
+		if best == nil || above(method.Basis, rate, best) {
+			best = rate
+		}
+	}
+
+	if best == nil {
+		return types.Money{}, types.ErrNoShippingRate
+	}
+	return best.Price, nil
+}
+
+// reaches returns whether a parcel reaches the bound of a row of a rate table.
+func reaches(basis string, rate *types.ShippingRate, parcel types.Parcel) (bool, error) {
+	switch basis {
+	case types.RateByWeight:
+		return parcel.Weight >= rate.MinWeight, nil
+	case types.RateByItems:
+		return parcel.Items >= rate.MinItems, nil
+	case types.RateByPrice:
+		c, err := parcel.Value.Cmp(minPrice(rate, parcel.Value.Currency))
+		return c >= 0, err
+	}
+	return false, fmt.Errorf("unknown rate basis %q", basis)
+}
+
+// above returns whether the bound of a row of a rate table is above that of another row.
+func above(basis string, rate, other *types.ShippingRate) bool {
+	switch basis {
+	case types.RateByWeight:
+		return rate.MinWeight > other.MinWeight
+	case types.RateByItems:
+		return rate.MinItems > other.MinItems
+	}
+	return minPrice(rate, "").Amount > minPrice(other, "").Amount
+}
+
+// minPrice returns the price bound of a row of a rate table, which is zero if it has none.
+func minPrice(rate *types.ShippingRate, currency string) types.Money {
+	if rate.MinPrice == nil {
+		return types.NewMoney(0, currency)
+	}
+	return *rate.MinPrice
+}
+
+// checkRates returns why the rate table of a method cannot be saved, or nil if it can: rate tables need rows,
+// amounts must be in the base currency, and no bound may be negative.
+func checkRates(provider, basis string, rates []types.ShippingRate, base string) error {
+	if provider == TableProvider && len(rates) == 0 {
+		return fmt.Errorf("rates are required for methods priced by their rate table")
+	}
+
+	for _, rate := range rates {
+		if rate.Price.Currency != base || rate.Price.IsNegative() {
+			return fmt.Errorf("price must be an amount in %s that is not negative", base)
+		}
+		if rate.MinWeight < 0 || rate.MinItems < 0 {
+			return fmt.Errorf("minWeight and minItems must not be negative")
+		}
+		if rate.MinPrice != nil && (rate.MinPrice.Currency != base || rate.MinPrice.IsNegative()) {
+			return fmt.Errorf("minPrice must be an amount in %s that is not negative", base)
+		}
+		if rate.MinWeight != 0 && basis != types.RateByWeight || rate.MinItems != 0 && basis != types.RateByItems ||
+			rate.MinPrice != nil && basis != types.RateByPrice {
+			return fmt.Errorf("rates by %s can only have a bound by %s", basis, basis)
+		}
+	}
+
+	return nil
+}
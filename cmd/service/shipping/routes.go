@@ -0,0 +1,321 @@
+package shipping
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	// Import the auth package for the authentication middlewares.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle shipping zone and method requests.
+type Handler struct {
+	store     types.ShippingStore // Interface for shipping zones and methods.
+	quoter    *Quoter             // Tells which rate providers methods can name.
+	pricer    types.Pricer        // Tells the base currency amounts of methods are set in.
+	userStore types.UserStore     // Interface for user-related data operations.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.ShippingStore, quoter *Quoter, pricer types.Pricer, userStore types.UserStore) *Handler {
+	return &Handler{store: store, quoter: quoter, pricer: pricer, userStore: userStore}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the shipping routes. All of them are for admins;
+// customers see the shipping options of their cart at checkout.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Manage the zones shipped to.
+	router.HandleFunc("/admin/shipping-zones", auth.WithAdminAuth(h.handleGetZones, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/shipping-zones", auth.WithAdminAuth(h.handleCreateZone, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/shipping-zones/{id:[0-9]+}", auth.WithAdminAuth(h.handleGetZone, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/shipping-zones/{id:[0-9]+}", auth.WithAdminAuth(h.handleUpdateZone, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/shipping-zones/{id:[0-9]+}", auth.WithAdminAuth(h.handleDeleteZone, h.userStore)).Methods(http.MethodDelete)
+
+	// Manage the methods of each zone and their rate tables.
+	router.HandleFunc("/admin/shipping-zones/{id:[0-9]+}/methods", auth.WithAdminAuth(h.handleGetMethods, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/shipping-zones/{id:[0-9]+}/methods", auth.WithAdminAuth(h.handleCreateMethod, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/shipping-methods/{id:[0-9]+}", auth.WithAdminAuth(h.handleGetMethod, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/shipping-methods/{id:[0-9]+}", auth.WithAdminAuth(h.handleUpdateMethod, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/admin/shipping-methods/{id:[0-9]+}", auth.WithAdminAuth(h.handleDeleteMethod, h.userStore)).Methods(http.MethodDelete)
+}
+
+// handleGetZones handles GET /admin/shipping-zones.
+func (h *Handler) handleGetZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.store.GetShippingZones()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, zones)
+}
+
+// handleGetZone handles GET /admin/shipping-zones/{id}.
+func (h *Handler) handleGetZone(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	z, err := h.store.GetShippingZoneByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, z)
+}
+
+// handleCreateZone handles POST /admin/shipping-zones.
+func (h *Handler) handleCreateZone(w http.ResponseWriter, r *http.Request) {
+	z, ok := parseZone(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := h.store.CreateShippingZone(*z)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetShippingZoneByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdateZone handles PUT /admin/shipping-zones/{id}. The body replaces the zone; its methods are kept.
+func (h *Handler) handleUpdateZone(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	existing, err := h.store.GetShippingZoneByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	z, ok := parseZone(w, r)
+	if !ok {
+		return
+	}
+
+	z.ID = id
+	z.CreatedAt = existing.CreatedAt
+	if err := h.store.UpdateShippingZone(*z); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, z)
+}
+
+// handleDeleteZone handles DELETE /admin/shipping-zones/{id}. The methods of the zone are deleted with it.
+func (h *Handler) handleDeleteZone(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.store.GetShippingZoneByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeleteShippingZone(id); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetMethods handles GET /admin/shipping-zones/{id}/methods.
+func (h *Handler) handleGetMethods(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.store.GetShippingZoneByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	methods, err := h.store.GetShippingMethods(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, methods)
+}
+
+// handleGetMethod handles GET /admin/shipping-methods/{id}.
+func (h *Handler) handleGetMethod(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	m, err := h.store.GetShippingMethodByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, m)
+}
+
+// handleCreateMethod handles POST /admin/shipping-zones/{id}/methods.
+func (h *Handler) handleCreateMethod(w http.ResponseWriter, r *http.Request) {
+	zoneID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.store.GetShippingZoneByID(zoneID); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	m, ok := h.parseMethod(w, r)
+	if !ok {
+		return
+	}
+
+	m.ZoneID = zoneID
+	id, err := h.store.CreateShippingMethod(*m)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetShippingMethodByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdateMethod handles PUT /admin/shipping-methods/{id}. The body replaces the method; its zone is kept.
+func (h *Handler) handleUpdateMethod(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	existing, err := h.store.GetShippingMethodByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	m, ok := h.parseMethod(w, r)
+	if !ok {
+		return
+	}
+
+	m.ID = id
+	m.ZoneID = existing.ZoneID
+	m.CreatedAt = existing.CreatedAt
+	if err := h.store.UpdateShippingMethod(*m); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, m)
+}
+
+// handleDeleteMethod handles DELETE /admin/shipping-methods/{id}.
+func (h *Handler) handleDeleteMethod(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.store.GetShippingMethodByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeleteShippingMethod(id); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseZone parses and validates the shipping zone in the body of a request, with its country and state codes in
+// upper case. On failure it writes the error response and returns false.
+func parseZone(w http.ResponseWriter, r *http.Request) (*types.ShippingZone, bool) {
+	var payload types.ShippingZonePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return nil, false
+	}
+	if err := checkRegions(payload.Regions); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	regions := []types.ShippingRegion{}
+	for _, region := range payload.Regions {
+		region.Country, region.State = strings.ToUpper(region.Country), strings.ToUpper(region.State)
+		if region.PostalCodes == nil {
+			region.PostalCodes = []string{}
+		}
+		regions = append(regions, region)
+	}
+
+	return &types.ShippingZone{Name: payload.Name, Regions: regions}, true
+}
+
+// parseMethod parses and validates the shipping method in the body of a request. Beyond the payload tags, it
+// checks the provider exists and the rate table fits the basis, with amounts in the base currency.
+// On failure it writes the error response and returns false.
+func (h *Handler) parseMethod(w http.ResponseWriter, r *http.Request) (*types.ShippingMethod, bool) {
+	var payload types.ShippingMethodPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return nil, false
+	}
+
+	if payload.Provider == "" {
+		payload.Provider = TableProvider
+	}
+	if !h.quoter.HasProvider(payload.Provider) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown provider %s", payload.Provider))
+		return nil, false
+	}
+	if err := checkRates(payload.Provider, payload.Basis, payload.Rates, h.pricer.Base()); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	if m := payload.FreeAbove; m != nil && (m.Currency != h.pricer.Base() || m.IsNegative()) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("freeAbove must be an amount in %s that is not negative", h.pricer.Base()))
+		return nil, false
+	}
+
+	rates := payload.Rates
+	if rates == nil {
+		rates = []types.ShippingRate{}
+	}
+	active := payload.Active == nil || *payload.Active
+
+	return &types.ShippingMethod{
+		Name:      payload.Name,
+		Provider:  payload.Provider,
+		Basis:     payload.Basis,
+		Rates:     rates,
+		FreeAbove: payload.FreeAbove,
+		MinDays:   payload.MinDays,
+		MaxDays:   payload.MaxDays,
+		Active:    active,
+	}, true
+}
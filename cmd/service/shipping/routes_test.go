@@ -0,0 +1,147 @@
+package shipping
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for shipping types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestShippingHandlers tests the admin shipping handlers.
+func TestShippingHandlers(t *testing.T) {
+	// Create a user store with one admin (ID 1) and one customer (ID 2).
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleAdmin},
+		2: {ID: 2, Role: types.RoleCustomer},
+	}}
+
+	t.Run("should create, update and delete zones and their methods", func(t *testing.T) {
+		store := newMockShippingStore()
+		router := mux.NewRouter()
+		NewHandler(store, NewQuoter(store, &mockPricer{}), &mockPricer{}, userStore).RegisterRoutes(router)
+
+		rr := send(t, router, http.MethodPost, "/admin/shipping-zones", `{"name": "Benelux", "regions": [{"country": "nl"}, {"country": "be", "postalCodes": ["1000-1299"]}]}`, 1)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		var z types.ShippingZone
+		json.NewDecoder(rr.Body).Decode(&z)
+		if z.ID != 1 || len(z.Regions) != 2 || z.Regions[0].Country != "NL" || z.Regions[1].PostalCodes[0] != "1000-1299" {
+			t.Errorf("expected the zone with upper case country codes, got %+v", z)
+		}
+
+		body := `{"name": "Standard", "basis": "weight", "freeAbove": {"amount": "50.00", "currency": "EUR"}, "minDays": 1, "maxDays": 2,
+			"rates": [{"price": {"amount": "4.95", "currency": "EUR"}}, {"minWeight": 2000, "price": {"amount": "6.95", "currency": "EUR"}}]}`
+		rr = send(t, router, http.MethodPost, "/admin/shipping-zones/1/methods", body, 1)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		var m types.ShippingMethod
+		json.NewDecoder(rr.Body).Decode(&m)
+		if m.ID != 2 || m.ZoneID != 1 || m.Provider != TableProvider || !m.Active || len(m.Rates) != 2 || *m.FreeAbove != types.NewMoney(5000, "EUR") {
+			t.Errorf("expected an active method priced by its rate table, got %+v", m)
+		}
+
+		rr = send(t, router, http.MethodPut, "/admin/shipping-methods/2", `{"name": "Flat", "basis": "items", "active": false, "rates": [{"price": {"amount": "5.00", "currency": "EUR"}}]}`, 1)
+		if rr.Code != http.StatusOK || store.methods[2].Name != "Flat" || store.methods[2].Active || store.methods[2].ZoneID != 1 {
+			t.Errorf("expected the method to be replaced, got %d: %s", rr.Code, rr.Body)
+		}
+
+		if rr := send(t, router, http.MethodGet, "/admin/shipping-zones/1/methods", "", 1); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"name":"Flat"`) {
+			t.Errorf("expected the methods of the zone, got %d: %s", rr.Code, rr.Body)
+		}
+
+		if rr := send(t, router, http.MethodDelete, "/admin/shipping-zones/1", "", 1); rr.Code != http.StatusNoContent || len(store.zones) != 0 || len(store.methods) != 0 {
+			t.Errorf("expected the zone and its methods to be deleted, got %d", rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/admin/shipping-methods/2", "", 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should reject invalid zones and methods", func(t *testing.T) {
+		store := newMockShippingStore()
+		router := mux.NewRouter()
+		NewHandler(store, NewQuoter(store, &mockPricer{}), &mockPricer{}, userStore).RegisterRoutes(router)
+		store.CreateShippingZone(types.ShippingZone{Name: "Netherlands", Regions: []types.ShippingRegion{{Country: "NL"}}})
+
+		for _, body := range []string{
+			`{"regions": [{"country": "NL"}]}`,
+			`{"name": "Holland", "regions": [{"country": "NLD"}]}`,
+			`{"name": "Holland", "regions": [{"country": "NL", "postalCodes": ["*"]}]}`,
+			`{"name": "Holland", "regions": [{"country": "NL", "postalCodes": ["1*2*"]}]}`,
+		} {
+			if rr := send(t, router, http.MethodPost, "/admin/shipping-zones", body, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", body, http.StatusBadRequest, rr.Code)
+			}
+		}
+
+		for _, body := range []string{
+			`{"name": "Standard", "basis": "volume", "rates": [{"price": {"amount": "4.95", "currency": "EUR"}}]}`,
+			`{"name": "Standard", "basis": "weight"}`,
+			`{"name": "Standard", "basis": "weight", "rates": [{"price": {"amount": "4.95", "currency": "USD"}}]}`,
+			`{"name": "Standard", "basis": "weight", "rates": [{"minItems": 2, "price": {"amount": "4.95", "currency": "EUR"}}]}`,
+			`{"name": "Standard", "basis": "weight", "rates": [{"minWeight": -1, "price": {"amount": "4.95", "currency": "EUR"}}]}`,
+			`{"name": "Standard", "basis": "weight", "freeAbove": {"amount": "50.00", "currency": "USD"}, "rates": [{"price": {"amount": "4.95", "currency": "EUR"}}]}`,
+			`{"name": "Standard", "basis": "weight", "minDays": 3, "maxDays": 2, "rates": [{"price": {"amount": "4.95", "currency": "EUR"}}]}`,
+			`{"name": "Courier", "basis": "weight", "provider": "courier"}`,
+		} {
+			if rr := send(t, router, http.MethodPost, "/admin/shipping-zones/1/methods", body, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", body, http.StatusBadRequest, rr.Code)
+			}
+		}
+
+		if rr := send(t, router, http.MethodPost, "/admin/shipping-zones/9/methods", `{"name": "Standard", "basis": "items"}`, 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for an unknown zone, got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := send(t, router, http.MethodGet, "/admin/shipping-zones", "", 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d for a customer, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the handler.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
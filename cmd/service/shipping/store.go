@@ -0,0 +1,227 @@
+package shipping
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	// Import the json package for storing regions and rate tables as JSON.
+	"encoding/json"
+	"fmt"
+
+	// Import the types package for the shipping types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// zoneColumns lists the columns of the shipping_zones table, in the order scanRowIntoZone reads them.
+const zoneColumns = "id, name, regions, createdAt"
+
+// methodColumns lists the columns of the shipping_methods table, in the order scanRowIntoMethod reads them.
+const methodColumns = "id, zoneId, name, provider, basis, rates, freeAbove, minDays, maxDays, active, createdAt"
+
+// Store struct represents the data store of shipping zones and methods.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db       *sql.DB // SQL database connection.
+	currency string  // The base currency, which the free shipping thresholds are stored in.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+// Amounts of shipping methods are in the given base currency.
+func NewStore(db *sql.DB, currency string) *Store {
+	return &Store{db: db, currency: currency}
+}
+
+// GetShippingZones is a method on the Store struct that retrieves all shipping zones, ordered by ID.
+func (s *Store) GetShippingZones() ([]*types.ShippingZone, error) {
+	return s.queryZones("SELECT " + zoneColumns + " FROM shipping_zones ORDER BY id")
+}
+
+// GetShippingZoneByID is a method on the Store struct that retrieves a shipping zone by ID.
+func (s *Store) GetShippingZoneByID(id int) (*types.ShippingZone, error) {
+	zones, err := s.queryZones("SELECT "+zoneColumns+" FROM shipping_zones WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("shipping zone not found")
+	}
+
+	return zones[0], nil
+}
+
+// CreateShippingZone is a method on the Store struct that stores a new shipping zone and returns its ID.
+func (s *Store) CreateShippingZone(z types.ShippingZone) (int, error) {
+	regions, err := json.Marshal(z.Regions)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.db.Exec("INSERT INTO shipping_zones (name, regions) VALUES (?, ?)", z.Name, regions)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// UpdateShippingZone is a method on the Store struct that replaces a shipping zone.
+func (s *Store) UpdateShippingZone(z types.ShippingZone) error {
+	regions, err := json.Marshal(z.Regions)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE shipping_zones SET name = ?, regions = ? WHERE id = ?", z.Name, regions, z.ID)
+	return err
+}
+
+// DeleteShippingZone is a method on the Store struct that removes a shipping zone. Its methods go with it.
+func (s *Store) DeleteShippingZone(id int) error {
+	_, err := s.db.Exec("DELETE FROM shipping_zones WHERE id = ?", id)
+	return err
+}
+
+// GetShippingMethods is a method on the Store struct that retrieves the methods of a shipping zone, ordered by ID.
+func (s *Store) GetShippingMethods(zoneID int) ([]*types.ShippingMethod, error) {
+	return s.queryMethods("SELECT "+methodColumns+" FROM shipping_methods WHERE zoneId = ? ORDER BY id", zoneID)
+}
+
+// GetShippingMethodByID is a method on the Store struct that retrieves a shipping method by ID.
+func (s *Store) GetShippingMethodByID(id int) (*types.ShippingMethod, error) {
+	methods, err := s.queryMethods("SELECT "+methodColumns+" FROM shipping_methods WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("shipping method not found")
+	}
+
+	return methods[0], nil
+}
+
+// CreateShippingMethod is a method on the Store struct that stores a new shipping method and returns its ID.
+func (s *Store) CreateShippingMethod(m types.ShippingMethod) (int, error) {
+	rates, freeAbove, err := s.encode(m)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.db.Exec(
+		"INSERT INTO shipping_methods (zoneId, name, provider, basis, rates, freeAbove, minDays, maxDays, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		m.ZoneID, m.Name, m.Provider, m.Basis, rates, freeAbove, m.MinDays, m.MaxDays, m.Active,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// UpdateShippingMethod is a method on the Store struct that replaces a shipping method. Its zone cannot change.
+func (s *Store) UpdateShippingMethod(m types.ShippingMethod) error {
+	rates, freeAbove, err := s.encode(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"UPDATE shipping_methods SET name = ?, provider = ?, basis = ?, rates = ?, freeAbove = ?, minDays = ?, maxDays = ?, active = ? WHERE id = ?",
+		m.Name, m.Provider, m.Basis, rates, freeAbove, m.MinDays, m.MaxDays, m.Active, m.ID,
+	)
+	return err
+}
+
+// DeleteShippingMethod is a method on the Store struct that removes a shipping method. Orders shipped with it
+// keep its name.
+func (s *Store) DeleteShippingMethod(id int) error {
+	_, err := s.db.Exec("DELETE FROM shipping_methods WHERE id = ?", id)
+	return err
+}
+
+// queryZones runs a query selecting zoneColumns and returns the zones.
+func (s *Store) queryZones(query string, args ...any) ([]*types.ShippingZone, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := []*types.ShippingZone{}
+	for rows.Next() {
+		z := new(types.ShippingZone)
+		var regions []byte
+		if err := rows.Scan(&z.ID, &z.Name, &regions, &z.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(regions, &z.Regions); err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+
+	return zones, rows.Err()
+}
+
+// queryMethods runs a query selecting methodColumns and returns the methods.
+func (s *Store) queryMethods(query string, args ...any) ([]*types.ShippingMethod, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	methods := []*types.ShippingMethod{}
+	for rows.Next() {
+		m, err := s.scanRowIntoMethod(rows)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+
+	return methods, rows.Err()
+}
+
+// scanRowIntoMethod scans a row selected with methodColumns into a ShippingMethod.
+func (s *Store) scanRowIntoMethod(rows *sql.Rows) (*types.ShippingMethod, error) {
+	m := new(types.ShippingMethod)
+	var rates []byte
+	var freeAbove sql.NullString
+
+	err := rows.Scan(&m.ID, &m.ZoneID, &m.Name, &m.Provider, &m.Basis, &rates, &freeAbove,
+		&m.MinDays, &m.MaxDays, &m.Active, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(rates, &m.Rates); err != nil {
+		return nil, err
+	}
+	if freeAbove.Valid {
+		amount, err := types.ParseMoney(freeAbove.String, s.currency)
+		if err != nil {
+			return nil, err
+		}
+		m.FreeAbove = &amount
+	}
+
+	return m, nil
+}
+
+// encode returns the rate table of a method as JSON, and its free shipping threshold as a decimal or NULL.
+func (s *Store) encode(m types.ShippingMethod) ([]byte, sql.NullString, error) {
+	if m.FreeAbove != nil && m.FreeAbove.Currency != s.currency {
+		return nil, sql.NullString{}, fmt.Errorf("freeAbove must be in %s", s.currency)
+	}
+
+	rates, err := json.Marshal(m.Rates)
+	if err != nil {
+		return nil, sql.NullString{}, err
+	}
+	if m.FreeAbove == nil {
+		return rates, sql.NullString{}, nil
+	}
+
+	return rates, sql.NullString{String: m.FreeAbove.Decimal(), Valid: true}, nil
+}
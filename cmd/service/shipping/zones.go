@@ -0,0 +1,143 @@
+package shipping
+
+import (
+	"fmt"
+	"strings"
+
+	// Import the types package for the shipping types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// How precisely a zone matches an address. A higher level wins.
+const (
+	noMatch          = -1 // The zone does not ship to the address.
+	matchRestOfWorld = 0  // The zone has no regions, so it ships anywhere.
+	matchCountry     = 1  // A region of the zone is the country of the address.
+	matchState       = 2  // A region of the zone is the state of the address.
+	matchPostalCode  = 3  // A region of the zone lists the postal code of the address.
+)
+
+// MatchZone returns the zone an address is in: the one matching it most precisely, and of those the one with
+// the lowest ID. It returns nil if no zone ships to the address.
+func MatchZone(zones []*types.ShippingZone, address types.Address) *types.ShippingZone {
+	var best *types.ShippingZone
+	bestLevel := noMatch
+	for _, z := range zones {
+		level := matchLevel(z, address)
+		if level > bestLevel || level == bestLevel && level > noMatch && z.ID < best.ID {
+			best, bestLevel = z, level
+		}
+	}
+
+	return best
+}
+
+// matchLevel returns how precisely a zone matches an address, one of the match constants.
+func matchLevel(z *types.ShippingZone, address types.Address) int {
+	if len(z.Regions) == 0 {
+		return matchRestOfWorld
+	}
+
+	country, state := strings.ToUpper(address.Country), strings.ToUpper(address.State)
+	postalCodes := postalCodeForms(address.PostalCode)
+
+	level := noMatch
+	for _, r := range z.Regions {
+		if strings.ToUpper(r.Country) != country || r.State != "" && strings.ToUpper(r.State) != state {
+			continue
+		}
+
+		l := matchCountry
+		if r.State != "" {
+			l = matchState
+		}
+		if len(r.PostalCodes) > 0 {
+			if !matchPostalCodes(r.PostalCodes, postalCodes) {
+				continue
+			}
+			l = matchPostalCode
+		}
+		level = max(level, l)
+	}
+
+	return level
+}
+
+// postalCodeForms returns the normalized forms of the postal code of an address to match patterns against: the
+// whole code and, for codes such as the ZIP+4 code "90210-1234", the part before the dash.
+func postalCodeForms(postalCode string) []string {
+	forms := []string{}
+	if code := NormalizePostalCode(postalCode); code != "" {
+		forms = append(forms, code)
+	}
+	if first, _, ok := strings.Cut(postalCode, "-"); ok && NormalizePostalCode(first) != "" {
+		forms = append(forms, NormalizePostalCode(first))
+	}
+	return forms
+}
+
+// matchPostalCodes returns whether any form of a postal code matches any of the patterns.
+func matchPostalCodes(patterns []string, postalCodes []string) bool {
+	for _, postalCode := range postalCodes {
+		if matchPattern(patterns, postalCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern returns whether a normalized postal code matches any of the patterns.
+func matchPattern(patterns []string, postalCode string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(postalCode, NormalizePostalCode(prefix)) {
+				return true
+			}
+			continue
+		}
+
+		if low, high, ok := postalCodeRange(pattern); ok {
+			if len(postalCode) >= len(low) && low <= postalCode[:len(low)] && postalCode[:len(low)] <= high {
+				return true
+			}
+			continue
+		}
+
+		if NormalizePostalCode(pattern) == postalCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// postalCodeRange splits a pattern such as "1000-1099" into its bounds. Only two codes of the same length make a
+// range, so patterns such as the ZIP+4 code "90001-1234" are exact codes.
+func postalCodeRange(pattern string) (low, high string, ok bool) {
+	low, high, ok = strings.Cut(pattern, "-")
+	low, high = NormalizePostalCode(low), NormalizePostalCode(high)
+	if !ok || low == "" || len(low) != len(high) || low > high {
+		return "", "", false
+	}
+	return low, high, true
+}
+
+// NormalizePostalCode returns a postal code in upper case, without the spaces and dashes it is often written
+// with, such as "1011AB" for "1011 ab".
+func NormalizePostalCode(postalCode string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.ToUpper(strings.TrimSpace(postalCode)))
+}
+
+// checkRegions returns why the regions of a zone cannot be saved, or nil if they can: postal code patterns must
+// have something before a wildcard, and only at the end.
+func checkRegions(regions []types.ShippingRegion) error {
+	for _, r := range regions {
+		for _, pattern := range r.PostalCodes {
+			prefix, _ := strings.CutSuffix(pattern, "*")
+			if NormalizePostalCode(prefix) == "" || strings.Contains(prefix, "*") {
+				return fmt.Errorf("invalid postal code pattern %q for %s", pattern, r.Country)
+			}
+		}
+	}
+	return nil
+}
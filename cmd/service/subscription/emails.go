@@ -0,0 +1,41 @@
+package subscription
+
+import (
+	"fmt"
+	"strings"
+
+	// Import the types package for the Email type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// renewalFailedEmail builds the email telling a customer a renewal of their subscription could not be placed or
+// paid, why, and when it is tried again.
+func renewalFailedEmail(u *types.User, sub *types.Subscription, plan *types.SubscriptionPlan) types.Email {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\n", u.FirstName)
+	fmt.Fprintf(&b, "We could not renew your subscription to %s (%d x %s): %s.\n\n", plan.Name, sub.Quantity, sub.SKU, sub.LastError)
+	fmt.Fprintf(&b, "We will try again on %s. ", sub.NextRunAt.Format("2 January 2006"))
+	b.WriteString("If your card has expired, please add a new payment method to your subscription before then.\n")
+
+	return types.Email{
+		To:      u.Email,
+		Subject: fmt.Sprintf("We could not renew your subscription to %s", plan.Name),
+		Body:    b.String(),
+	}
+}
+
+// cancelledEmail builds the email telling a customer their subscription was cancelled because its renewal kept
+// failing.
+func cancelledEmail(u *types.User, sub *types.Subscription, plan *types.SubscriptionPlan) types.Email {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\n", u.FirstName)
+	fmt.Fprintf(&b, "We tried to renew your subscription to %s (%d x %s) %d times, but could not: %s.\n\n",
+		plan.Name, sub.Quantity, sub.SKU, sub.FailedAttempts, sub.LastError)
+	b.WriteString("Your subscription has been cancelled. You are welcome to subscribe again at any time.\n")
+
+	return types.Email{
+		To:      u.Email,
+		Subject: fmt.Sprintf("Your subscription to %s was cancelled", plan.Name),
+		Body:    b.String(),
+	}
+}
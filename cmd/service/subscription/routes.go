@@ -0,0 +1,524 @@
+package subscription
+
+import (
+	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the auth package for the authentication middleware.
+	"github.com/FreekAlberti/Ecom/cmd/service/auth"
+	// Import the config package for the base currency plans are checked against.
+	"github.com/FreekAlberti/Ecom/cmd/config"
+	// Import the currency package to select the currency of a request.
+	"github.com/FreekAlberti/Ecom/cmd/service/currency"
+	// Import the types package for the store interfaces and payloads.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+	// Import the utils package for helper functions such as JSON parsing and error handling.
+	"github.com/FreekAlberti/Ecom/cmd/utils"
+	"github.com/go-playground/validator/v10"
+	// Import the Gorilla Mux package for routing HTTP requests to appropriate handlers.
+	"github.com/gorilla/mux"
+)
+
+// Handler struct groups the methods that handle subscription plans and the subscriptions of customers.
+type Handler struct {
+	store     types.SubscriptionStore  // Interface for the plans, subscriptions and renewals.
+	methods   types.PaymentMethodStore // Interface for the saved payment methods renewals are charged to.
+	carts     types.CartPricer         // Prices carts, used to check the SKUs of plans are sold.
+	pricer    types.Pricer             // Selects the currency of the customer.
+	userStore types.UserStore          // Interface for user-related data operations, used to authenticate users.
+	now       func() time.Time         // Returns the current time.
+}
+
+// NewHandler is a constructor function that returns a new Handler instance.
+func NewHandler(store types.SubscriptionStore, methods types.PaymentMethodStore, carts types.CartPricer, pricer types.Pricer, userStore types.UserStore) *Handler {
+	return &Handler{store: store, methods: methods, carts: carts, pricer: pricer, userStore: userStore, now: time.Now}
+}
+
+// RegisterRoutes is a method on the Handler struct that registers the subscription routes.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// List the plans customers can subscribe to.
+	router.HandleFunc("/subscription-plans", h.handleGetPlans).Methods(http.MethodGet)
+
+	// Subscribe, and manage the subscriptions of the user.
+	router.HandleFunc("/me/subscriptions", auth.WithAuth(h.handleGetMySubscriptions, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/me/subscriptions", auth.WithAuth(auth.WithoutImpersonation(h.handleSubscribe), h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/me/subscriptions/{id:[0-9]+}", auth.WithAuth(h.handleGetMySubscription, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/me/subscriptions/{id:[0-9]+}", auth.WithAuth(auth.WithoutImpersonation(h.handleUpdateSubscription), h.userStore)).Methods(http.MethodPatch)
+	router.HandleFunc("/me/subscriptions/{id:[0-9]+}/pause", auth.WithAuth(h.handlePause, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/me/subscriptions/{id:[0-9]+}/resume", auth.WithAuth(h.handleResume, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/me/subscriptions/{id:[0-9]+}/skip", auth.WithAuth(h.handleSkip, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/me/subscriptions/{id:[0-9]+}/cancel", auth.WithAuth(h.handleCancel, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/me/subscriptions/{id:[0-9]+}/renewals", auth.WithAuth(h.handleGetRenewals, h.userStore)).Methods(http.MethodGet)
+
+	// Manage the plans, and list the subscriptions of all customers.
+	router.HandleFunc("/admin/subscription-plans", auth.WithAdminAuth(h.handleGetAllPlans, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/subscription-plans", auth.WithAdminAuth(h.handleCreatePlan, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/subscription-plans/{id:[0-9]+}", auth.WithAdminAuth(h.handleUpdatePlan, h.userStore)).Methods(http.MethodPatch)
+	router.HandleFunc("/admin/subscriptions", auth.WithAdminAuth(h.handleGetSubscriptions, h.userStore)).Methods(http.MethodGet)
+}
+
+// handleGetPlans handles GET /subscription-plans.
+// It lists the plans customers can subscribe to.
+func (h *Handler) handleGetPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.store.GetPlans(true)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, plans)
+}
+
+// handleGetMySubscriptions handles GET /me/subscriptions.
+func (h *Handler) handleGetMySubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.store.GetSubscriptionsByUser(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, subs)
+}
+
+// handleSubscribe handles POST /me/subscriptions.
+// The user subscribes to an active plan at one of its intervals, paying with a saved payment method, in the
+// currency of the request. The first renewal is placed at startAt, or by the next run of the scheduler without it.
+// Admins impersonating a customer cannot subscribe for them.
+func (h *Handler) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	var payload types.SubscribePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	plan, err := h.store.GetPlanByID(payload.PlanID)
+	if err != nil || !plan.Active {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("subscription plan %d not found", payload.PlanID))
+		return
+	}
+	if !slices.Contains(plan.Intervals, payload.IntervalDays) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("plan %d is not delivered every %d days", plan.ID, payload.IntervalDays))
+		return
+	}
+	if err := h.checkPaymentMethod(userID, payload.PaymentMethodID); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cur, err := currency.FromRequest(r, h.pricer, h.userStore)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	now := h.now().UTC()
+	nextRunAt := now
+	if payload.StartAt != nil && payload.StartAt.After(now) {
+		nextRunAt = payload.StartAt.UTC()
+	}
+
+	id, err := h.store.CreateSubscription(types.Subscription{
+		UserID:           userID,
+		PlanID:           plan.ID,
+		SKU:              plan.SKU,
+		Quantity:         payload.Quantity,
+		IntervalDays:     payload.IntervalDays,
+		Currency:         cur,
+		PaymentMethodID:  payload.PaymentMethodID,
+		Address:          payload.Address,
+		ShippingMethodID: payload.ShippingMethodID,
+		Status:           types.SubscriptionActive,
+		NextRunAt:        nextRunAt,
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sub, err := h.store.GetSubscriptionByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, sub)
+}
+
+// handleGetMySubscription handles GET /me/subscriptions/{id}.
+func (h *Handler) handleGetMySubscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.mySubscription(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, sub)
+}
+
+// handleUpdateSubscription handles PATCH /me/subscriptions/{id}.
+// Only the fields present in the payload change. A new interval must be one of the plan's; the next renewal moves
+// to that many days after the last one, but never into the past. A past due subscription given another payment
+// method is retried by the next run of the scheduler. Admins impersonating a customer cannot change their
+// subscriptions, since that could swap the payment method charged.
+func (h *Handler) handleUpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	var payload types.UpdateSubscriptionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	sub, ok := h.mySubscription(w, r)
+	if !ok {
+		return
+	}
+	if sub.Status == types.SubscriptionCancelled {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("subscription %d is cancelled", sub.ID))
+		return
+	}
+	now := h.now().UTC()
+
+	if payload.Quantity != nil {
+		sub.Quantity = *payload.Quantity
+	}
+	if payload.IntervalDays != nil && *payload.IntervalDays != sub.IntervalDays {
+		plan, err := h.store.GetPlanByID(sub.PlanID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !slices.Contains(plan.Intervals, *payload.IntervalDays) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("plan %d is not delivered every %d days", plan.ID, *payload.IntervalDays))
+			return
+		}
+
+		sub.IntervalDays = *payload.IntervalDays
+		if sub.Status == types.SubscriptionActive && sub.LastRenewedAt != nil {
+			sub.NextRunAt = latest(sub.LastRenewedAt.AddDate(0, 0, sub.IntervalDays), now)
+		}
+	}
+	if payload.PaymentMethodID != nil {
+		if err := h.checkPaymentMethod(sub.UserID, *payload.PaymentMethodID); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		sub.PaymentMethodID = *payload.PaymentMethodID
+		if sub.Status == types.SubscriptionPastDue {
+			sub.NextRunAt = now
+		}
+	}
+	if payload.Address != nil {
+		if err := utils.Validate.Struct(payload.Address); err != nil {
+			errors := err.(validator.ValidationErrors)
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+			return
+		}
+		sub.Address = payload.Address
+	}
+	if payload.ShippingMethodID != nil {
+		sub.ShippingMethodID = payload.ShippingMethodID
+	}
+
+	h.save(w, sub)
+}
+
+// handlePause handles POST /me/subscriptions/{id}/pause.
+// A paused subscription is not renewed until it is resumed. Pausing a past due subscription stops its retries.
+func (h *Handler) handlePause(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.mySubscription(w, r)
+	if !ok {
+		return
+	}
+	if sub.Status != types.SubscriptionActive && sub.Status != types.SubscriptionPastDue {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("subscription %d is %s", sub.ID, sub.Status))
+		return
+	}
+
+	sub.Status = types.SubscriptionPaused
+	h.save(w, sub)
+}
+
+// handleResume handles POST /me/subscriptions/{id}/resume.
+// The subscription is renewed again from when it would have been. If that has passed, the next renewal is one
+// interval from now, so resuming does not place an order right away.
+func (h *Handler) handleResume(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.mySubscription(w, r)
+	if !ok {
+		return
+	}
+	if sub.Status != types.SubscriptionPaused {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("subscription %d is %s", sub.ID, sub.Status))
+		return
+	}
+
+	now := h.now().UTC()
+	sub.Status = types.SubscriptionActive
+	sub.FailedAttempts, sub.LastError = 0, ""
+	if sub.NextRunAt.Before(now) {
+		sub.NextRunAt = now.AddDate(0, 0, sub.IntervalDays)
+	}
+	h.save(w, sub)
+}
+
+// handleSkip handles POST /me/subscriptions/{id}/skip.
+// The next renewal is skipped: it moves one interval later.
+func (h *Handler) handleSkip(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.mySubscription(w, r)
+	if !ok {
+		return
+	}
+	if sub.Status != types.SubscriptionActive {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("subscription %d is %s", sub.ID, sub.Status))
+		return
+	}
+
+	sub.NextRunAt = sub.NextRunAt.AddDate(0, 0, sub.IntervalDays)
+	h.save(w, sub)
+}
+
+// handleCancel handles POST /me/subscriptions/{id}/cancel.
+// A cancelled subscription is never renewed again. Orders already placed are not affected.
+func (h *Handler) handleCancel(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.mySubscription(w, r)
+	if !ok {
+		return
+	}
+	if sub.Status == types.SubscriptionCancelled {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("subscription %d is already cancelled", sub.ID))
+		return
+	}
+
+	now := h.now().UTC()
+	sub.Status = types.SubscriptionCancelled
+	sub.CancelledAt = &now
+	h.save(w, sub)
+}
+
+// handleGetRenewals handles GET /me/subscriptions/{id}/renewals.
+// It lists the renewal attempts of a subscription of the user, newest first.
+func (h *Handler) handleGetRenewals(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.mySubscription(w, r)
+	if !ok {
+		return
+	}
+
+	renewals, err := h.store.GetRenewals(sub.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, renewals)
+}
+
+// handleGetAllPlans handles GET /admin/subscription-plans.
+// It lists every plan, including those closed to new subscribers.
+func (h *Handler) handleGetAllPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.store.GetPlans(false)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, plans)
+}
+
+// handleCreatePlan handles POST /admin/subscription-plans.
+// The SKU must be sold. Intervals are stored ascending, without duplicates.
+func (h *Handler) handleCreatePlan(w http.ResponseWriter, r *http.Request) {
+	var payload types.SubscriptionPlanPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	plan := types.SubscriptionPlan{
+		SKU:       strings.TrimSpace(payload.SKU),
+		Name:      strings.TrimSpace(payload.Name),
+		Intervals: sortedIntervals(payload.Intervals),
+		Discount:  "0",
+		Active:    payload.Active == nil || *payload.Active,
+	}
+	if payload.Discount != "" {
+		discount, err := parseDiscount(payload.Discount)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		plan.Discount = discount
+	}
+
+	_, err := h.carts.PriceCart(0, config.Envs.BaseCurrency, []types.OrderLine{{SKU: plan.SKU, Quantity: 1}}, nil)
+	if errors.Is(err, types.ErrUnknownSKU) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	id, err := h.store.CreatePlan(plan)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	created, err := h.store.GetPlanByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdatePlan handles PATCH /admin/subscription-plans/{id}.
+// Only the fields present in the payload change. Existing subscriptions keep their interval, even if the plan no
+// longer offers it; a new discount applies from their next renewal.
+func (h *Handler) handleUpdatePlan(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.UpdateSubscriptionPlanPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	plan, err := h.store.GetPlanByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if payload.Name != nil {
+		if plan.Name = strings.TrimSpace(*payload.Name); plan.Name == "" {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("name cannot be empty"))
+			return
+		}
+	}
+	if payload.Intervals != nil {
+		if len(payload.Intervals) == 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("a plan needs at least one interval"))
+			return
+		}
+		plan.Intervals = sortedIntervals(payload.Intervals)
+	}
+	if payload.Discount != nil {
+		if plan.Discount, err = parseDiscount(*payload.Discount); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if payload.Active != nil {
+		plan.Active = *payload.Active
+	}
+
+	if err := h.store.UpdatePlan(*plan); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, plan)
+}
+
+// handleGetSubscriptions handles GET /admin/subscriptions.
+// It lists the subscriptions of all customers, only those with the status in the query parameter status if given.
+func (h *Handler) handleGetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	statuses := []string{types.SubscriptionActive, types.SubscriptionPaused, types.SubscriptionPastDue, types.SubscriptionCancelled}
+	if status != "" && !slices.Contains(statuses, status) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid status %q", status))
+		return
+	}
+
+	subs, err := h.store.GetSubscriptions(status)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, subs)
+}
+
+// mySubscription returns the subscription in the URL if it belongs to the authenticated user, and writes a not
+// found error otherwise.
+func (h *Handler) mySubscription(w http.ResponseWriter, r *http.Request) (*types.Subscription, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	sub, err := h.store.GetSubscriptionByID(id)
+	if err != nil || sub.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("subscription not found"))
+		return nil, false
+	}
+
+	return sub, true
+}
+
+// save stores a changed subscription and writes it as the response.
+func (h *Handler) save(w http.ResponseWriter, sub *types.Subscription) {
+	if err := h.store.UpdateSubscription(*sub); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, sub)
+}
+
+// checkPaymentMethod returns an error unless a payment method is saved by a user and not removed.
+func (h *Handler) checkPaymentMethod(userID, id int) error {
+	m, err := h.methods.GetPaymentMethodByID(id)
+	if err != nil || m.UserID != userID || m.RemovedAt != nil {
+		return fmt.Errorf("payment method %d not found", id)
+	}
+	return nil
+}
+
+// parseDiscount checks a discount is a percentage from 0 to 100, and returns it without trailing zeros.
+func parseDiscount(value string) (string, error) {
+	discount, ok := new(big.Rat).SetString(value)
+	if !ok || discount.Sign() < 0 || discount.Cmp(big.NewRat(100, 1)) > 0 {
+		return "", fmt.Errorf("invalid discount %q, expected a percentage from 0 to 100", value)
+	}
+	return trimDecimal(discount.FloatString(4)), nil
+}
+
+// sortedIntervals returns intervals ascending, without duplicates.
+func sortedIntervals(intervals []int) []int {
+	sorted := slices.Clone(intervals)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+// latest returns the later of two times.
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,268 @@
+package subscription
+
+import (
+	"encoding/json"     // Import the encoding/json package for decoding responses
+	"fmt"               // Import the fmt package for formatted I/O operations
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package for HTTP testing utilities
+	"slices"            // Import the slices package to look up the SKUs sold
+	"strings"           // Import the strings package to build request bodies
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for the clock of the handler
+
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for subscription types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
+)
+
+// TestSubscriptionHandlers tests subscribing to plans, and pausing, resuming, skipping, changing and cancelling
+// subscriptions.
+func TestSubscriptionHandlers(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	renewed := now.AddDate(0, 0, -10)
+
+	// Create the stores. Coffee (plan 1) is delivered every 14 or 30 days; tea (plan 2) is no longer open to
+	// subscribers. Jane (ID 2) saved card 1 and removed card 2; her coffee subscription (subscription 1) was renewed
+	// 10 days ago and renews every 30 days. John (ID 3) saved card 3. Admin (ID 1) manages the plans.
+	coffee := types.Subscription{
+		ID: 1, UserID: 2, PlanID: 1, SKU: "COFFEE", Quantity: 1, IntervalDays: 30, Currency: "EUR", PaymentMethodID: 1,
+		Address: &types.Address{Country: "NL"}, Status: types.SubscriptionActive, Renewals: 1, LastRenewedAt: &renewed,
+		NextRunAt: renewed.AddDate(0, 0, 30),
+	}
+	store := &mockSubscriptionStore{
+		plans: map[int]*types.SubscriptionPlan{
+			1: {ID: 1, SKU: "COFFEE", Name: "Coffee", Intervals: []int{14, 30}, Discount: "10", Active: true},
+			2: {ID: 2, SKU: "TEA", Name: "Tea", Intervals: []int{30}, Discount: "0", Active: false},
+		},
+		subscriptions: map[int]*types.Subscription{1: &coffee},
+	}
+	removed := now.AddDate(0, -1, 0)
+	methods := &mockPaymentMethodStore{methods: map[int]*types.PaymentMethod{
+		1: {ID: 1, UserID: 2},
+		2: {ID: 2, UserID: 2, RemovedAt: &removed},
+		3: {ID: 3, UserID: 3},
+	}}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleAdmin},
+		2: {ID: 2, Role: types.RoleCustomer},
+		3: {ID: 3, Role: types.RoleCustomer},
+	}}
+
+	// Initialize a handler using the mock stores at a fixed time, and register its routes. The tests that change
+	// Jane's subscription start from a copy of it, in the state they need.
+	h := NewHandler(store, methods, &mockCartPricer{skus: []string{"COFFEE", "TEA", "BEANS"}}, &mockPricer{}, userStore)
+	h.now = func() time.Time { return now }
+
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	// decode decodes a subscription from a response with the expected status code.
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder, code int) types.Subscription {
+		t.Helper()
+
+		if rr.Code != code {
+			t.Fatalf("expected status code %d, got %d: %s", code, rr.Code, rr.Body)
+		}
+		var sub types.Subscription
+		json.NewDecoder(rr.Body).Decode(&sub)
+		return sub
+	}
+
+	t.Run("subscribes to an active plan", func(t *testing.T) {
+		body := `{"planId": 1, "quantity": 2, "intervalDays": 14, "paymentMethodId": 1, "address": {"country": "NL"}}`
+		sub := decode(t, send(t, router, http.MethodPost, "/me/subscriptions", body, 2), http.StatusCreated)
+		if sub.UserID != 2 || sub.SKU != "COFFEE" || sub.Currency != "EUR" || sub.Status != types.SubscriptionActive || !sub.NextRunAt.Equal(now) {
+			t.Errorf("expected an active subscription to coffee renewed right away, got %+v", sub)
+		}
+	})
+
+	t.Run("rejects subscriptions the plan or payment method do not allow", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"closed plan":          `{"planId": 2, "quantity": 1, "intervalDays": 30, "paymentMethodId": 1, "address": {"country": "NL"}}`,
+			"interval not offered": `{"planId": 1, "quantity": 1, "intervalDays": 7, "paymentMethodId": 1, "address": {"country": "NL"}}`,
+			"removed card":         `{"planId": 1, "quantity": 1, "intervalDays": 14, "paymentMethodId": 2, "address": {"country": "NL"}}`,
+			"card of another user": `{"planId": 1, "quantity": 1, "intervalDays": 14, "paymentMethodId": 3, "address": {"country": "NL"}}`,
+			"no address":           `{"planId": 1, "quantity": 1, "intervalDays": 14, "paymentMethodId": 1}`,
+		} {
+			if rr := send(t, router, http.MethodPost, "/me/subscriptions", body, 2); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d: %s", name, http.StatusBadRequest, rr.Code, rr.Body)
+			}
+		}
+	})
+
+	t.Run("changes the frequency from the last renewal", func(t *testing.T) {
+		jane := coffee
+		store.subscriptions[1] = &jane
+
+		sub := decode(t, send(t, router, http.MethodPatch, "/me/subscriptions/1", `{"intervalDays": 14}`, 2), http.StatusOK)
+		if sub.IntervalDays != 14 || !sub.NextRunAt.Equal(renewed.AddDate(0, 0, 14)) {
+			t.Errorf("expected the next renewal 14 days after the last, got %+v", sub)
+		}
+
+		// 14 days after a renewal 20 days ago has passed, so the next is now.
+		last := now.AddDate(0, 0, -20)
+		jane = coffee
+		jane.LastRenewedAt = &last
+		store.subscriptions[1] = &jane
+		if sub = decode(t, send(t, router, http.MethodPatch, "/me/subscriptions/1", `{"intervalDays": 14}`, 2), http.StatusOK); !sub.NextRunAt.Equal(now) {
+			t.Errorf("expected the next renewal now, got %v", sub.NextRunAt)
+		}
+
+		if rr := send(t, router, http.MethodPatch, "/me/subscriptions/1", `{"intervalDays": 7}`, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected an interval the plan does not offer to be rejected, got %d", rr.Code)
+		}
+	})
+
+	t.Run("pauses, resumes, skips and cancels", func(t *testing.T) {
+		jane := coffee
+		store.subscriptions[1] = &jane
+		next := jane.NextRunAt
+
+		sub := decode(t, send(t, router, http.MethodPost, "/me/subscriptions/1/skip", "", 2), http.StatusOK)
+		if !sub.NextRunAt.Equal(next.AddDate(0, 0, 30)) {
+			t.Errorf("expected the next renewal to move 30 days, got %v", sub.NextRunAt)
+		}
+
+		if sub = decode(t, send(t, router, http.MethodPost, "/me/subscriptions/1/pause", "", 2), http.StatusOK); sub.Status != types.SubscriptionPaused {
+			t.Errorf("expected a paused subscription, got %s", sub.Status)
+		}
+		if rr := send(t, router, http.MethodPost, "/me/subscriptions/1/skip", "", 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected a paused subscription not to be skipped, got %d", rr.Code)
+		}
+
+		// The renewal it would have had is still ahead, so it is kept.
+		if sub = decode(t, send(t, router, http.MethodPost, "/me/subscriptions/1/resume", "", 2), http.StatusOK); sub.Status != types.SubscriptionActive || !sub.NextRunAt.Equal(next.AddDate(0, 0, 30)) {
+			t.Errorf("expected an active subscription keeping its next renewal, got %+v", sub)
+		}
+
+		if sub = decode(t, send(t, router, http.MethodPost, "/me/subscriptions/1/cancel", "", 2), http.StatusOK); sub.Status != types.SubscriptionCancelled || sub.CancelledAt == nil {
+			t.Errorf("expected a cancelled subscription, got %+v", sub)
+		}
+		if rr := send(t, router, http.MethodPost, "/me/subscriptions/1/resume", "", 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected a cancelled subscription not to be resumed, got %d", rr.Code)
+		}
+	})
+
+	t.Run("resuming after a missed renewal waits an interval", func(t *testing.T) {
+		jane := coffee
+		jane.Status, jane.NextRunAt = types.SubscriptionPaused, now.AddDate(0, 0, -1)
+		store.subscriptions[1] = &jane
+
+		sub := decode(t, send(t, router, http.MethodPost, "/me/subscriptions/1/resume", "", 2), http.StatusOK)
+		if !sub.NextRunAt.Equal(now.AddDate(0, 0, 30)) {
+			t.Errorf("expected the next renewal in 30 days, got %v", sub.NextRunAt)
+		}
+	})
+
+	t.Run("a new card retries a past due subscription", func(t *testing.T) {
+		jane := coffee
+		jane.Status, jane.NextRunAt = types.SubscriptionPastDue, now.AddDate(0, 0, 3)
+		store.subscriptions[1] = &jane
+
+		sub := decode(t, send(t, router, http.MethodPatch, "/me/subscriptions/1", `{"paymentMethodId": 1}`, 2), http.StatusOK)
+		if !sub.NextRunAt.Equal(now) {
+			t.Errorf("expected a retry right away, got %v", sub.NextRunAt)
+		}
+		if rr := send(t, router, http.MethodPatch, "/me/subscriptions/1", `{"paymentMethodId": 2}`, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected a removed card to be rejected, got %d", rr.Code)
+		}
+	})
+
+	t.Run("subscriptions of other users are not found", func(t *testing.T) {
+		for _, target := range []string{"/me/subscriptions/1", "/me/subscriptions/1/renewals"} {
+			if rr := send(t, router, http.MethodGet, target, "", 3); rr.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status code %d, got %d", target, http.StatusNotFound, rr.Code)
+			}
+		}
+		if rr := send(t, router, http.MethodPost, "/me/subscriptions/1/cancel", "", 3); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("admins manage plans", func(t *testing.T) {
+		body := `{"sku": "BEANS", "name": "Beans", "intervals": [30, 7, 30], "discount": "12.50"}`
+		rr := send(t, router, http.MethodPost, "/admin/subscription-plans", body, 1)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		var plan types.SubscriptionPlan
+		json.NewDecoder(rr.Body).Decode(&plan)
+		if fmt.Sprint(plan.Intervals) != "[7 30]" || plan.Discount != "12.5" || !plan.Active {
+			t.Errorf("expected an active plan every 7 or 30 days at 12.5%% off, got %+v", plan)
+		}
+
+		for name, body := range map[string]string{
+			"unknown SKU":       `{"sku": "NOPE", "name": "Nope", "intervals": [30]}`,
+			"discount over 100": `{"sku": "BEANS", "name": "Beans", "intervals": [30], "discount": "101"}`,
+			"no intervals":      `{"sku": "BEANS", "name": "Beans", "intervals": []}`,
+		} {
+			if rr := send(t, router, http.MethodPost, "/admin/subscription-plans", body, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d: %s", name, http.StatusBadRequest, rr.Code, rr.Body)
+			}
+		}
+
+		rr = send(t, router, http.MethodPatch, "/admin/subscription-plans/2", `{"active": true, "discount": "5"}`, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		json.NewDecoder(rr.Body).Decode(&plan)
+		if !plan.Active || plan.Discount != "5" || plan.SKU != "TEA" {
+			t.Errorf("expected tea open at 5%% off, got %+v", plan)
+		}
+
+		if rr := send(t, router, http.MethodPost, "/admin/subscription-plans", body, 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected customers to be forbidden, got %d", rr.Code)
+		}
+	})
+}
+
+// send sends a request with the body through the router, authenticated as the user with the given ID,
+// and returns the recorded response.
+func send(t *testing.T, router *mux.Router, method, target, body string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockCartPricer is an implementation of the CartPricer interface that only knows which SKUs are sold.
+type mockCartPricer struct {
+	skus []string // SKUs sold.
+}
+
+// PriceCart is a mock method that prices the items, or returns ErrUnknownSKU for a SKU not sold.
+func (m *mockCartPricer) PriceCart(userID int, currency string, items []types.OrderLine, codes []string) (*types.CartPricing, error) {
+	for _, item := range items {
+		if !slices.Contains(m.skus, item.SKU) {
+			return nil, fmt.Errorf("%w %s", types.ErrUnknownSKU, item.SKU)
+		}
+	}
+	return &types.CartPricing{Currency: currency}, nil
+}
+
+// mockPricer is an implementation of the parts of the Pricer interface used by the handler.
+type mockPricer struct {
+	types.Pricer
+}
+
+// SelectCurrency is a mock method that selects euros, the only currency.
+func (m *mockPricer) SelectCurrency(requested string, user *types.User) (string, error) {
+	if requested != "" && requested != "EUR" {
+		return "", types.ErrUnsupportedCurrency
+	}
+	return "EUR", nil
+}
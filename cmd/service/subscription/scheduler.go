@@ -0,0 +1,220 @@
+package subscription
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	// Import the types package for the store, order, payment and mailer interfaces.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// claimLease is how long a subscription being renewed is kept from being picked up again. It only matters if the
+// renewal never finishes, such as when the server stops halfway; the renewal is then retried after it.
+const claimLease = time.Hour
+
+// maxErrorLength is the longest reason for a failed renewal that is stored.
+const maxErrorLength = 255
+
+// Scheduler struct renews the subscriptions that are due: it places their renewal orders, charges their saved
+// payment methods, and retries failed renewals on the dunning schedule.
+type Scheduler struct {
+	store     types.SubscriptionStore  // Interface for the subscriptions and their renewals.
+	methods   types.PaymentMethodStore // Interface for the saved payment methods renewals are charged to.
+	orders    types.OrderPlacer        // Places renewal orders as checkout does.
+	payments  types.PaymentProvider    // Charges the saved payment methods.
+	mailer    types.Mailer             // Interface for emailing customers about failed renewals.
+	userStore types.UserStore          // Interface for the customers emailed.
+	retryDays []int64                  // Days between the retries of a failed renewal.
+}
+
+// NewScheduler is a constructor function that returns a new Scheduler. retryDays holds the days to wait before each
+// retry of a failed renewal; the subscription is cancelled when the last retry fails.
+func NewScheduler(store types.SubscriptionStore, methods types.PaymentMethodStore, orders types.OrderPlacer, payments types.PaymentProvider, mailer types.Mailer, userStore types.UserStore, retryDays []int64) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		methods:   methods,
+		orders:    orders,
+		payments:  payments,
+		mailer:    mailer,
+		userStore: userStore,
+		retryDays: retryDays,
+	}
+}
+
+// Start is a method on the Scheduler struct that starts a goroutine renewing the subscriptions that are due every
+// interval.
+func (s *Scheduler) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			if _, err := s.Scan(now); err != nil {
+				log.Printf("failed to renew subscriptions: %v", err)
+			}
+		}
+	}()
+}
+
+// Scan is a method on the Scheduler struct that renews every subscription that is due, and returns the renewal
+// attempts it made. Subscriptions another instance claimed first are left to it.
+func (s *Scheduler) Scan(now time.Time) ([]*types.SubscriptionRenewal, error) {
+	subs, err := s.store.GetDueSubscriptions(now)
+	if err != nil {
+		return nil, err
+	}
+
+	renewals := []*types.SubscriptionRenewal{}
+	for _, sub := range subs {
+		claimed, err := s.store.ClaimSubscription(sub.ID, sub.NextRunAt, now.Add(claimLease))
+		if err != nil {
+			return renewals, err
+		}
+		if !claimed {
+			continue
+		}
+
+		r, err := s.renew(sub, now)
+		if err != nil {
+			return renewals, err
+		}
+		renewals = append(renewals, r)
+	}
+
+	return renewals, nil
+}
+
+// renew places and charges the renewal order of a claimed subscription, and schedules the next renewal. A renewal
+// that fails, for whatever reason, is retried on the dunning schedule; the subscription is cancelled when it runs
+// out of retries. Only failures to read or save the subscription itself are returned.
+func (s *Scheduler) renew(sub *types.Subscription, now time.Time) (*types.SubscriptionRenewal, error) {
+	plan, err := s.store.GetPlanByID(sub.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := types.SubscriptionRenewal{
+		SubscriptionID: sub.ID,
+		Renewal:        sub.Renewals + 1,
+		Attempt:        sub.FailedAttempts + 1,
+		CreatedAt:      now,
+	}
+
+	o, err := s.placeOrder(sub, plan, &r)
+	if err == nil {
+		r.Status, r.OrderID = types.RenewalPaid, &o.ID
+		sub.Status = types.SubscriptionActive
+		sub.Renewals++
+		sub.FailedAttempts, sub.LastError = 0, ""
+		sub.LastRenewedAt = &now
+		sub.NextRunAt = now.AddDate(0, 0, sub.IntervalDays)
+	} else {
+		r.Status, r.Error = types.RenewalFailed, truncate(err.Error(), maxErrorLength)
+		s.fail(sub, plan, r.Error, now)
+	}
+
+	if err := s.store.UpdateSubscription(*sub); err != nil {
+		return nil, err
+	}
+	if r.ID, err = s.store.CreateRenewal(r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// placeOrder places the renewal order of a subscription at the discount of its plan, charging what is left to pay
+// to the saved payment method. The ID of the charge is recorded on the renewal. Each attempt at a renewal charges
+// under a reference of its own, since the provider answers a reference it has seen with its first result: a retry
+// after a decline would be declined again, and one after a refund would pass without taking anything. The order is
+// placed under the same reference, so an attempt that is run again, because its outcome was never recorded, gets
+// back the order it placed instead of placing and charging another one. A charge whose order could not be stored
+// is refunded.
+func (s *Scheduler) placeOrder(sub *types.Subscription, plan *types.SubscriptionPlan, r *types.SubscriptionRenewal) (*types.Order, error) {
+	method, err := s.methods.GetPaymentMethodByID(sub.PaymentMethodID)
+	if err != nil || method.UserID != sub.UserID || method.RemovedAt != nil {
+		return nil, fmt.Errorf("payment method %d is no longer available", sub.PaymentMethodID)
+	}
+
+	payload := types.CheckoutPayload{
+		Items:                []types.OrderLine{{SKU: sub.SKU, Quantity: sub.Quantity}},
+		Codes:                []string{},
+		GiftCards:            []string{},
+		Address:              sub.Address,
+		ShippingMethodID:     sub.ShippingMethodID,
+		SubscriptionDiscount: plan.Discount,
+		Reference:            fmt.Sprintf("subscription:%d:renewal:%d:attempt:%d", sub.ID, r.Renewal, r.Attempt),
+	}
+
+	reference := payload.Reference
+	var charged types.Money
+	o, err := s.orders.PlaceOrder(sub.UserID, sub.Currency, payload, func(o types.Order) error {
+		if o.AmountDue.Amount <= 0 {
+			return nil
+		}
+
+		chargeID, err := s.payments.Charge(types.PaymentCharge{
+			UserID:    sub.UserID,
+			Token:     method.Token,
+			Amount:    o.AmountDue,
+			Reference: reference,
+		})
+		if err == nil {
+			charged = o.AmountDue
+		}
+		r.ChargeID = chargeID
+		return err
+	})
+
+	// The customer was charged, but the order was not stored: give the money back before the renewal is retried.
+	if err != nil && charged.Amount > 0 {
+		if _, refundErr := s.payments.Refund(types.PaymentRefund{Amount: charged, Reference: reference + ":refund"}); refundErr != nil {
+			log.Printf("failed to refund charge %s of subscription %d: %v", r.ChargeID, sub.ID, refundErr)
+		}
+	}
+
+	return o, err
+}
+
+// fail records a failed renewal attempt on a subscription. It is retried after the next delay of the dunning
+// schedule, or cancelled if there is none left. The customer is emailed either way.
+func (s *Scheduler) fail(sub *types.Subscription, plan *types.SubscriptionPlan, reason string, now time.Time) {
+	sub.FailedAttempts++
+	sub.LastError = reason
+
+	var email types.Email
+	u, err := s.userStore.GetUserByID(sub.UserID)
+	if sub.FailedAttempts > len(s.retryDays) {
+		sub.Status = types.SubscriptionCancelled
+		sub.CancelledAt = &now
+		if err == nil {
+			email = cancelledEmail(u, sub, plan)
+		}
+	} else {
+		sub.Status = types.SubscriptionPastDue
+		sub.NextRunAt = now.AddDate(0, 0, int(s.retryDays[sub.FailedAttempts-1]))
+		if err == nil {
+			email = renewalFailedEmail(u, sub, plan)
+		}
+	}
+
+	if err == nil {
+		err = s.mailer.Send(email)
+	}
+	if err != nil {
+		log.Printf("failed to email the failed renewal of subscription %d: %v", sub.ID, err)
+	}
+}
+
+// truncate cuts a string to at most n bytes, without splitting a multi-byte character.
+func truncate(value string, n int) string {
+	if len(value) <= n {
+		return value
+	}
+	for n > 0 && !utf8.RuneStart(value[n]) {
+		n--
+	}
+	return value[:n]
+}
@@ -0,0 +1,460 @@
+package subscription
+
+import (
+	"fmt"     // Import the fmt package for formatted I/O operations
+	"strings" // Import the strings package to check the email body
+	"testing" // Import the testing package to write test cases
+	"time"    // Import the time package for scan times
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for subscription types
+)
+
+// TestSchedulerScan tests that due subscriptions are renewed at the discount of their plan, and that failed
+// renewals are retried on the dunning schedule until the subscription is cancelled.
+func TestSchedulerScan(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// Create the stores the scheduler reads. Jane (ID 2) subscribes to 2 bags of coffee every 30 days, due now, and
+	// pays with card 1. Each test renews a copy of her subscription in a store of its own, and retries failed
+	// renewals after 1 and 3 days.
+	plans := map[int]*types.SubscriptionPlan{
+		1: {ID: 1, SKU: "COFFEE", Name: "Coffee", Intervals: []int{14, 30}, Discount: "10", Active: true},
+		2: {ID: 2, SKU: "TEA", Name: "Tea", Intervals: []int{30}, Discount: "0", Active: true},
+	}
+	coffee := types.Subscription{
+		ID: 1, UserID: 2, PlanID: 1, SKU: "COFFEE", Quantity: 2, IntervalDays: 30, Currency: "EUR", PaymentMethodID: 1,
+		Address: &types.Address{Country: "NL"}, Status: types.SubscriptionActive, NextRunAt: now,
+	}
+	methods := &mockPaymentMethodStore{methods: map[int]*types.PaymentMethod{1: {ID: 1, UserID: 2, Token: "tok_visa"}}}
+	userStore := &mockUserStore{users: map[int]*types.User{2: {ID: 2, FirstName: "Jane", Email: "jane@example.com"}}}
+
+	t.Run("renews due subscriptions at the discount of their plan", func(t *testing.T) {
+		// Her subscription to tea is due in 20 days.
+		tea := types.Subscription{
+			ID: 2, UserID: 2, PlanID: 2, SKU: "TEA", Quantity: 1, IntervalDays: 30, Currency: "EUR", PaymentMethodID: 1,
+			Address: &types.Address{Country: "NL"}, Status: types.SubscriptionActive, NextRunAt: now.Add(20 * day),
+		}
+		jane := coffee
+		store := &mockSubscriptionStore{plans: plans, subscriptions: map[int]*types.Subscription{1: &jane, 2: &tea}}
+		orders, payments := &mockOrderPlacer{}, &mockPaymentProvider{}
+		scheduler := NewScheduler(store, methods, orders, payments, &mockMailer{}, userStore, []int64{1, 3})
+
+		renewals, err := scheduler.Scan(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(renewals) != 1 || renewals[0].Status != types.RenewalPaid || renewals[0].OrderID == nil || renewals[0].ChargeID == "" {
+			t.Fatalf("expected one paid renewal, got %+v", renewals)
+		}
+		if len(orders.placed) != 1 || orders.placed[0].SubscriptionDiscount != "10" || orders.placed[0].Items[0].Quantity != 2 {
+			t.Errorf("expected an order of 2 x COFFEE at 10%% off, got %+v", orders.placed)
+		}
+		if len(payments.charges) != 1 || payments.charges[0].Token != "tok_visa" || payments.charges[0].Reference != "subscription:1:renewal:1:attempt:1" {
+			t.Errorf("expected a charge of the saved card, got %+v", payments.charges)
+		}
+
+		sub := store.subscriptions[1]
+		if sub.Renewals != 1 || sub.Status != types.SubscriptionActive || !sub.NextRunAt.Equal(now.AddDate(0, 0, 30)) {
+			t.Errorf("expected the next renewal in 30 days, got %+v", sub)
+		}
+
+		// Nothing is due any more.
+		if renewals, _ := scheduler.Scan(now.Add(time.Minute)); len(renewals) != 0 {
+			t.Errorf("expected no renewals, got %+v", renewals)
+		}
+	})
+
+	t.Run("retries declined renewals and cancels after the last retry", func(t *testing.T) {
+		jane := coffee
+		store := &mockSubscriptionStore{plans: plans, subscriptions: map[int]*types.Subscription{1: &jane}}
+		orders, payments, mailer := &mockOrderPlacer{}, &mockPaymentProvider{}, &mockMailer{}
+		scheduler := NewScheduler(store, methods, orders, payments, mailer, userStore, []int64{1, 3})
+		payments.decline = true
+
+		scheduler.Scan(now)
+		sub := store.subscriptions[1]
+		if sub.Status != types.SubscriptionPastDue || sub.FailedAttempts != 1 || !sub.NextRunAt.Equal(now.Add(day)) {
+			t.Fatalf("expected a retry tomorrow, got %+v", sub)
+		}
+		if len(orders.undone) != 1 {
+			t.Errorf("expected the declined order to be undone, got %d", len(orders.undone))
+		}
+		if len(mailer.sent) != 1 || !strings.Contains(mailer.sent[0].Body, "payment declined") {
+			t.Errorf("expected an email about the declined payment, got %+v", mailer.sent)
+		}
+
+		scheduler.Scan(now.Add(day))
+		if sub = store.subscriptions[1]; sub.FailedAttempts != 2 || !sub.NextRunAt.Equal(now.Add(4*day)) {
+			t.Fatalf("expected a retry 3 days later, got %+v", sub)
+		}
+
+		scheduler.Scan(now.Add(4 * day))
+		if sub = store.subscriptions[1]; sub.Status != types.SubscriptionCancelled || sub.CancelledAt == nil {
+			t.Fatalf("expected the subscription to be cancelled, got %+v", sub)
+		}
+		if last := mailer.sent[len(mailer.sent)-1]; !strings.Contains(last.Subject, "cancelled") {
+			t.Errorf("expected an email about the cancellation, got %+v", last)
+		}
+
+		// Every attempt is recorded, and charged under a reference of its own, so the provider does not answer it
+		// with the decline of the one before.
+		if renewals, _ := store.GetRenewals(1); len(renewals) != 3 || renewals[0].Attempt != 3 || renewals[0].Status != types.RenewalFailed {
+			t.Errorf("expected 3 failed attempts, got %+v", renewals)
+		}
+		for i, charge := range payments.charges {
+			if expected := fmt.Sprintf("subscription:1:renewal:1:attempt:%d", i+1); charge.Reference != expected {
+				t.Errorf("expected reference %q, got %q", expected, charge.Reference)
+			}
+		}
+	})
+
+	t.Run("a paid retry resets the dunning state", func(t *testing.T) {
+		jane := coffee
+		store := &mockSubscriptionStore{plans: plans, subscriptions: map[int]*types.Subscription{1: &jane}}
+		payments := &mockPaymentProvider{}
+		scheduler := NewScheduler(store, methods, &mockOrderPlacer{}, payments, &mockMailer{}, userStore, []int64{1, 3})
+		payments.decline = true
+		scheduler.Scan(now)
+
+		payments.decline = false
+		scheduler.Scan(now.Add(day))
+		sub := store.subscriptions[1]
+		if sub.Status != types.SubscriptionActive || sub.FailedAttempts != 0 || sub.LastError != "" || sub.Renewals != 1 {
+			t.Errorf("expected an active subscription, got %+v", sub)
+		}
+	})
+
+	t.Run("a charge whose order is not stored is refunded", func(t *testing.T) {
+		jane := coffee
+		store := &mockSubscriptionStore{plans: plans, subscriptions: map[int]*types.Subscription{1: &jane}}
+		orders, payments := &mockOrderPlacer{}, &mockPaymentProvider{}
+		scheduler := NewScheduler(store, methods, orders, payments, &mockMailer{}, userStore, []int64{1, 3})
+		orders.failStore = true
+
+		scheduler.Scan(now)
+		if len(payments.refunds) != 1 || payments.refunds[0].Amount.Amount != 1800 || payments.refunds[0].Reference != "subscription:1:renewal:1:attempt:1:refund" {
+			t.Errorf("expected the charge of 18.00 to be refunded, got %+v", payments.refunds)
+		}
+		if sub := store.subscriptions[1]; sub.Status != types.SubscriptionPastDue || sub.Renewals != 0 {
+			t.Errorf("expected a past due subscription, got %+v", sub)
+		}
+
+		// The retry charges again rather than getting the refunded charge back.
+		orders.failStore = false
+		scheduler.Scan(now.Add(day))
+		if len(payments.charges) != 2 || payments.charges[1].Reference != "subscription:1:renewal:1:attempt:2" || store.subscriptions[1].Renewals != 1 {
+			t.Errorf("expected the retry to be charged under a new reference, got %+v", payments.charges)
+		}
+
+		// Declined charges are not refunded.
+		jane = coffee
+		store = &mockSubscriptionStore{plans: plans, subscriptions: map[int]*types.Subscription{1: &jane}}
+		payments = &mockPaymentProvider{}
+		scheduler = NewScheduler(store, methods, &mockOrderPlacer{}, payments, &mockMailer{}, userStore, []int64{1, 3})
+		payments.decline = true
+		scheduler.Scan(now)
+		if len(payments.refunds) != 0 {
+			t.Errorf("expected no refund of a declined charge, got %+v", payments.refunds)
+		}
+	})
+
+	t.Run("an attempt run again after its outcome was lost does not order twice", func(t *testing.T) {
+		jane := coffee
+		store := &mockSubscriptionStore{plans: plans, subscriptions: map[int]*types.Subscription{1: &jane}}
+		orders, payments := &mockOrderPlacer{}, &mockPaymentProvider{}
+		scheduler := NewScheduler(store, methods, orders, payments, &mockMailer{}, userStore, []int64{1, 3})
+
+		// The order is placed and paid, but the renewal is never recorded.
+		store.failUpdate = true
+		if _, err := scheduler.Scan(now); err == nil {
+			t.Fatal("expected saving the subscription to fail")
+		}
+
+		// Once the claim runs out, the attempt is run again.
+		store.failUpdate = false
+		renewals, err := scheduler.Scan(now.Add(claimLease))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(renewals) != 1 || renewals[0].Status != types.RenewalPaid || renewals[0].OrderID == nil || *renewals[0].OrderID != 1 {
+			t.Fatalf("expected the renewal to be paid with the first order, got %+v", renewals)
+		}
+		if len(orders.placed) != 1 || len(payments.charges) != 1 || len(payments.refunds) != 0 {
+			t.Errorf("expected a single order and charge, got %+v and %+v", orders.placed, payments.charges)
+		}
+	})
+
+	t.Run("a removed payment method fails the renewal", func(t *testing.T) {
+		removed := now.Add(-day)
+		methods := &mockPaymentMethodStore{methods: map[int]*types.PaymentMethod{1: {ID: 1, UserID: 2, Token: "tok_visa", RemovedAt: &removed}}}
+		jane := coffee
+		store := &mockSubscriptionStore{plans: plans, subscriptions: map[int]*types.Subscription{1: &jane}}
+		orders := &mockOrderPlacer{}
+		scheduler := NewScheduler(store, methods, orders, &mockPaymentProvider{}, &mockMailer{}, userStore, []int64{1, 3})
+
+		scheduler.Scan(now)
+		if len(orders.placed) != 0 || store.subscriptions[1].Status != types.SubscriptionPastDue {
+			t.Errorf("expected no order and a past due subscription, got %+v", store.subscriptions[1])
+		}
+	})
+
+	t.Run("subscriptions claimed elsewhere are skipped", func(t *testing.T) {
+		jane := coffee
+		store := &mockSubscriptionStore{plans: plans, subscriptions: map[int]*types.Subscription{1: &jane}}
+		orders := &mockOrderPlacer{}
+		scheduler := NewScheduler(store, methods, orders, &mockPaymentProvider{}, &mockMailer{}, userStore, []int64{1, 3})
+		store.claimed = map[int]bool{1: true}
+
+		if renewals, _ := scheduler.Scan(now); len(renewals) != 0 || len(orders.placed) != 0 {
+			t.Errorf("expected nothing renewed, got %+v", renewals)
+		}
+	})
+}
+
+// TestTruncate tests that reasons for failed renewals are cut on a character boundary.
+func TestTruncate(t *testing.T) {
+	if got := truncate("declined", 20); got != "declined" {
+		t.Errorf("expected a short reason to be kept, got %q", got)
+	}
+	if got := truncate("café", 4); got != "caf" {
+		t.Errorf("expected the split character to be dropped, got %q", got)
+	}
+	if got := truncate("€€", 4); got != "€" {
+		t.Errorf("expected whole characters only, got %q", got)
+	}
+}
+
+// mockSubscriptionStore is an in-memory implementation of the SubscriptionStore interface.
+type mockSubscriptionStore struct {
+	plans         map[int]*types.SubscriptionPlan // Plans by ID.
+	subscriptions map[int]*types.Subscription     // Subscriptions by ID.
+	renewals      []*types.SubscriptionRenewal    // Renewal attempts, oldest first.
+	claimed       map[int]bool                    // Subscriptions claimed by another instance.
+	failUpdate    bool                            // Whether saving subscriptions fails.
+}
+
+// CreatePlan is a mock method that stores a plan.
+func (m *mockSubscriptionStore) CreatePlan(p types.SubscriptionPlan) (int, error) {
+	p.ID = len(m.plans) + 1
+	m.plans[p.ID] = &p
+	return p.ID, nil
+}
+
+// GetPlanByID is a mock method that returns a copy of the plan with the given ID.
+func (m *mockSubscriptionStore) GetPlanByID(id int) (*types.SubscriptionPlan, error) {
+	p, ok := m.plans[id]
+	if !ok {
+		return nil, fmt.Errorf("subscription plan not found")
+	}
+
+	c := *p
+	return &c, nil
+}
+
+// GetPlans is a mock method that returns the plans, only the active ones if asked, by ID.
+func (m *mockSubscriptionStore) GetPlans(activeOnly bool) ([]*types.SubscriptionPlan, error) {
+	plans := []*types.SubscriptionPlan{}
+	for id := 1; id <= len(m.plans); id++ {
+		if p, ok := m.plans[id]; ok && (p.Active || !activeOnly) {
+			plans = append(plans, p)
+		}
+	}
+	return plans, nil
+}
+
+// UpdatePlan is a mock method that saves a plan.
+func (m *mockSubscriptionStore) UpdatePlan(p types.SubscriptionPlan) error {
+	m.plans[p.ID] = &p
+	return nil
+}
+
+// CreateSubscription is a mock method that stores a subscription.
+func (m *mockSubscriptionStore) CreateSubscription(sub types.Subscription) (int, error) {
+	sub.ID = len(m.subscriptions) + 1
+	m.subscriptions[sub.ID] = &sub
+	return sub.ID, nil
+}
+
+// GetSubscriptionByID is a mock method that returns a copy of the subscription with the given ID.
+func (m *mockSubscriptionStore) GetSubscriptionByID(id int) (*types.Subscription, error) {
+	sub, ok := m.subscriptions[id]
+	if !ok {
+		return nil, fmt.Errorf("subscription not found")
+	}
+
+	c := *sub
+	return &c, nil
+}
+
+// GetSubscriptionsByUser is a mock method that returns copies of the subscriptions of a user, by ID.
+func (m *mockSubscriptionStore) GetSubscriptionsByUser(userID int) ([]*types.Subscription, error) {
+	return m.filter(func(sub *types.Subscription) bool { return sub.UserID == userID }), nil
+}
+
+// GetSubscriptions is a mock method that returns copies of the subscriptions with a status, by ID.
+func (m *mockSubscriptionStore) GetSubscriptions(status string) ([]*types.Subscription, error) {
+	return m.filter(func(sub *types.Subscription) bool { return status == "" || sub.Status == status }), nil
+}
+
+// GetDueSubscriptions is a mock method that returns copies of the active and past due subscriptions that are due.
+func (m *mockSubscriptionStore) GetDueSubscriptions(now time.Time) ([]*types.Subscription, error) {
+	return m.filter(func(sub *types.Subscription) bool {
+		return (sub.Status == types.SubscriptionActive || sub.Status == types.SubscriptionPastDue) && !sub.NextRunAt.After(now)
+	}), nil
+}
+
+// ClaimSubscription is a mock method that moves the next run of a subscription unless another instance claimed it.
+func (m *mockSubscriptionStore) ClaimSubscription(id int, nextRunAt, until time.Time) (bool, error) {
+	sub := m.subscriptions[id]
+	if m.claimed[id] || !sub.NextRunAt.Equal(nextRunAt) {
+		return false, nil
+	}
+
+	sub.NextRunAt = until
+	return true, nil
+}
+
+// UpdateSubscription is a mock method that saves a subscription.
+func (m *mockSubscriptionStore) UpdateSubscription(sub types.Subscription) error {
+	if m.failUpdate {
+		return fmt.Errorf("subscriptions cannot be saved")
+	}
+	m.subscriptions[sub.ID] = &sub
+	return nil
+}
+
+// CreateRenewal is a mock method that records a renewal attempt.
+func (m *mockSubscriptionStore) CreateRenewal(r types.SubscriptionRenewal) (int, error) {
+	r.ID = len(m.renewals) + 1
+	m.renewals = append(m.renewals, &r)
+	return r.ID, nil
+}
+
+// GetRenewals is a mock method that returns the renewal attempts of a subscription, newest first.
+func (m *mockSubscriptionStore) GetRenewals(subscriptionID int) ([]*types.SubscriptionRenewal, error) {
+	renewals := []*types.SubscriptionRenewal{}
+	for i := len(m.renewals) - 1; i >= 0; i-- {
+		if m.renewals[i].SubscriptionID == subscriptionID {
+			renewals = append(renewals, m.renewals[i])
+		}
+	}
+	return renewals, nil
+}
+
+// filter returns copies of the subscriptions that match, by ID.
+func (m *mockSubscriptionStore) filter(match func(*types.Subscription) bool) []*types.Subscription {
+	subs := []*types.Subscription{}
+	for id := 1; id <= len(m.subscriptions); id++ {
+		if sub, ok := m.subscriptions[id]; ok && match(sub) {
+			c := *sub
+			subs = append(subs, &c)
+		}
+	}
+	return subs
+}
+
+// mockPaymentMethodStore is an in-memory implementation of the parts of the PaymentMethodStore interface used by
+// the package.
+type mockPaymentMethodStore struct {
+	types.PaymentMethodStore
+	methods map[int]*types.PaymentMethod // Payment methods by ID.
+}
+
+// GetPaymentMethodByID is a mock method that returns a copy of the payment method with the given ID.
+func (m *mockPaymentMethodStore) GetPaymentMethodByID(id int) (*types.PaymentMethod, error) {
+	pm, ok := m.methods[id]
+	if !ok {
+		return nil, fmt.Errorf("payment method not found")
+	}
+
+	c := *pm
+	return &c, nil
+}
+
+// mockOrderPlacer is an implementation of the OrderPlacer interface that prices every unit at 10.00 EUR, less the
+// subscription discount, and undoes orders whose payment or storing fails. An order is placed under a reference once.
+type mockOrderPlacer struct {
+	placed    []types.CheckoutPayload // Payloads of the orders placed, where the order ID is the index plus one.
+	undone    []types.CheckoutPayload // Payloads of the orders undone because their payment or storing failed.
+	failStore bool                    // Whether storing orders fails, after they are paid.
+}
+
+// PlaceOrder is a mock method that places an order, calling pay with it first, or returns the order placed under
+// its reference before.
+func (m *mockOrderPlacer) PlaceOrder(userID int, currency string, payload types.CheckoutPayload, pay func(types.Order) error) (*types.Order, error) {
+	for i, placed := range m.placed {
+		if placed.Reference == payload.Reference {
+			return &types.Order{ID: i + 1, UserID: userID}, nil
+		}
+	}
+
+	amount := int64(1000 * payload.Items[0].Quantity)
+	if payload.SubscriptionDiscount == "10" {
+		amount = amount * 9 / 10
+	}
+	o := types.Order{ID: len(m.placed) + 1, UserID: userID, Total: types.NewMoney(amount, currency), AmountDue: types.NewMoney(amount, currency)}
+
+	if err := pay(o); err != nil {
+		m.undone = append(m.undone, payload)
+		return nil, err
+	}
+	if m.failStore {
+		m.undone = append(m.undone, payload)
+		return nil, fmt.Errorf("failed to store order")
+	}
+
+	m.placed = append(m.placed, payload)
+	return &o, nil
+}
+
+// mockPaymentProvider is an implementation of the parts of the PaymentProvider interface used by the scheduler.
+type mockPaymentProvider struct {
+	types.PaymentProvider
+	charges []types.PaymentCharge // Charges asked for, including declined ones.
+	refunds []types.PaymentRefund // Refunds made.
+	decline bool                  // Whether to decline charges.
+}
+
+// Charge is a mock method that records a charge, and declines it if asked.
+func (m *mockPaymentProvider) Charge(c types.PaymentCharge) (string, error) {
+	m.charges = append(m.charges, c)
+	if m.decline {
+		return "", types.ErrPaymentDeclined
+	}
+	return fmt.Sprintf("ch_%d", len(m.charges)), nil
+}
+
+// Refund is a mock method that records a refund.
+func (m *mockPaymentProvider) Refund(r types.PaymentRefund) (string, error) {
+	m.refunds = append(m.refunds, r)
+	return fmt.Sprintf("re_%d", len(m.refunds)), nil
+}
+
+// mockMailer is an implementation of the Mailer interface that records the emails sent.
+type mockMailer struct {
+	sent []types.Email // Emails sent.
+}
+
+// Send is a mock method that records an email.
+func (m *mockMailer) Send(e types.Email) error {
+	m.sent = append(m.sent, e)
+	return nil
+}
+
+// mockUserStore is an in-memory implementation of the parts of the UserStore interface used by the package.
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User // Users by ID.
+}
+
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
+}
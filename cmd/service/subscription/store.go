@@ -0,0 +1,335 @@
+package subscription
+
+import (
+	// Import the sql package for interacting with the SQL database.
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Import the types package for the subscription types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Columns of the tables, in the order the scan functions read them.
+const (
+	planColumns         = "id, sku, name, intervals, discount, active, createdAt"
+	subscriptionColumns = `id, userId, planId, sku, quantity, intervalDays, currency, paymentMethodId, country, state, postalCode,
+	shippingMethodId, status, nextRunAt, renewals, failedAttempts, lastError, lastRenewedAt, createdAt, cancelledAt`
+	renewalColumns = "id, subscriptionId, renewal, attempt, status, orderId, chargeId, error, createdAt"
+)
+
+// Store struct represents the data store of subscription plans, subscriptions and their renewals.
+// It holds a reference to the SQL database connection.
+type Store struct {
+	db *sql.DB // SQL database connection.
+}
+
+// NewStore is a constructor function that initializes and returns a new instance of Store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreatePlan is a method on the Store struct that stores a subscription plan and returns its ID.
+func (s *Store) CreatePlan(p types.SubscriptionPlan) (int, error) {
+	res, err := s.db.Exec(
+		"INSERT INTO subscription_plans (sku, name, intervals, discount, active) VALUES (?, ?, ?, ?, ?)",
+		p.SKU, p.Name, joinInts(p.Intervals), p.Discount, p.Active,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetPlanByID is a method on the Store struct that retrieves a subscription plan by ID.
+func (s *Store) GetPlanByID(id int) (*types.SubscriptionPlan, error) {
+	rows, err := s.db.Query("SELECT "+planColumns+" FROM subscription_plans WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	plans, err := scanPlans(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("subscription plan not found")
+	}
+
+	return plans[0], nil
+}
+
+// GetPlans is a method on the Store struct that retrieves the subscription plans, only the active ones if asked,
+// oldest first.
+func (s *Store) GetPlans(activeOnly bool) ([]*types.SubscriptionPlan, error) {
+	query := "SELECT " + planColumns + " FROM subscription_plans"
+	if activeOnly {
+		query += " WHERE active = TRUE"
+	}
+
+	rows, err := s.db.Query(query + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPlans(rows)
+}
+
+// UpdatePlan is a method on the Store struct that saves the name, intervals, discount and active flag of a plan.
+func (s *Store) UpdatePlan(p types.SubscriptionPlan) error {
+	_, err := s.db.Exec(
+		"UPDATE subscription_plans SET name = ?, intervals = ?, discount = ?, active = ? WHERE id = ?",
+		p.Name, joinInts(p.Intervals), p.Discount, p.Active, p.ID,
+	)
+	return err
+}
+
+// CreateSubscription is a method on the Store struct that stores a subscription and returns its ID.
+func (s *Store) CreateSubscription(sub types.Subscription) (int, error) {
+	address := addressOf(sub)
+	res, err := s.db.Exec(
+		`INSERT INTO subscriptions (userId, planId, sku, quantity, intervalDays, currency, paymentMethodId, country, state,
+		postalCode, shippingMethodId, status, nextRunAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sub.UserID, sub.PlanID, sub.SKU, sub.Quantity, sub.IntervalDays, sub.Currency, sub.PaymentMethodID,
+		address.Country, address.State, address.PostalCode, sub.ShippingMethodID, sub.Status, sub.NextRunAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetSubscriptionByID is a method on the Store struct that retrieves a subscription by ID.
+func (s *Store) GetSubscriptionByID(id int) (*types.Subscription, error) {
+	rows, err := s.db.Query("SELECT "+subscriptionColumns+" FROM subscriptions WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := scanSubscriptions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) == 0 {
+		return nil, fmt.Errorf("subscription not found")
+	}
+
+	return subs[0], nil
+}
+
+// GetSubscriptionsByUser is a method on the Store struct that retrieves the subscriptions of a user, oldest first.
+func (s *Store) GetSubscriptionsByUser(userID int) ([]*types.Subscription, error) {
+	rows, err := s.db.Query("SELECT "+subscriptionColumns+" FROM subscriptions WHERE userId = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubscriptions(rows)
+}
+
+// GetSubscriptions is a method on the Store struct that retrieves the subscriptions with a status, or all
+// subscriptions for an empty status, oldest first.
+func (s *Store) GetSubscriptions(status string) ([]*types.Subscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions"
+	args := []any{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+
+	rows, err := s.db.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubscriptions(rows)
+}
+
+// GetDueSubscriptions is a method on the Store struct that retrieves the active and past due subscriptions whose
+// next run is at or before a time, the longest overdue first.
+func (s *Store) GetDueSubscriptions(now time.Time) ([]*types.Subscription, error) {
+	rows, err := s.db.Query(
+		"SELECT "+subscriptionColumns+" FROM subscriptions WHERE status IN (?, ?) AND nextRunAt <= ? ORDER BY nextRunAt, id",
+		types.SubscriptionActive, types.SubscriptionPastDue, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubscriptions(rows)
+}
+
+// ClaimSubscription is a method on the Store struct that moves the next run of a due subscription to a later time,
+// if it is still at the time it was read at. It reports whether the subscription was claimed.
+func (s *Store) ClaimSubscription(id int, nextRunAt, until time.Time) (bool, error) {
+	res, err := s.db.Exec(
+		"UPDATE subscriptions SET nextRunAt = ? WHERE id = ? AND nextRunAt = ? AND status IN (?, ?)",
+		until, id, nextRunAt, types.SubscriptionActive, types.SubscriptionPastDue,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// UpdateSubscription is a method on the Store struct that saves the quantity, interval, payment method, address,
+// status, schedule and dunning state of a subscription.
+func (s *Store) UpdateSubscription(sub types.Subscription) error {
+	address := addressOf(sub)
+	_, err := s.db.Exec(
+		`UPDATE subscriptions SET quantity = ?, intervalDays = ?, paymentMethodId = ?, country = ?, state = ?, postalCode = ?,
+		shippingMethodId = ?, status = ?, nextRunAt = ?, renewals = ?, failedAttempts = ?, lastError = ?, lastRenewedAt = ?,
+		cancelledAt = ? WHERE id = ?`,
+		sub.Quantity, sub.IntervalDays, sub.PaymentMethodID, address.Country, address.State, address.PostalCode,
+		sub.ShippingMethodID, sub.Status, sub.NextRunAt, sub.Renewals, sub.FailedAttempts, sub.LastError, sub.LastRenewedAt,
+		sub.CancelledAt, sub.ID,
+	)
+	return err
+}
+
+// CreateRenewal is a method on the Store struct that records an attempt to renew a subscription and returns its ID.
+func (s *Store) CreateRenewal(r types.SubscriptionRenewal) (int, error) {
+	res, err := s.db.Exec(
+		"INSERT INTO subscription_renewals (subscriptionId, renewal, attempt, status, orderId, chargeId, error) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		r.SubscriptionID, r.Renewal, r.Attempt, r.Status, r.OrderID, r.ChargeID, r.Error,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetRenewals is a method on the Store struct that retrieves the renewal attempts of a subscription, newest first.
+func (s *Store) GetRenewals(subscriptionID int) ([]*types.SubscriptionRenewal, error) {
+	rows, err := s.db.Query("SELECT "+renewalColumns+" FROM subscription_renewals WHERE subscriptionId = ? ORDER BY id DESC", subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	renewals := []*types.SubscriptionRenewal{}
+	for rows.Next() {
+		r := new(types.SubscriptionRenewal)
+		var orderID sql.NullInt64
+		err := rows.Scan(&r.ID, &r.SubscriptionID, &r.Renewal, &r.Attempt, &r.Status, &orderID, &r.ChargeID, &r.Error, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if orderID.Valid {
+			id := int(orderID.Int64)
+			r.OrderID = &id
+		}
+		renewals = append(renewals, r)
+	}
+
+	return renewals, rows.Err()
+}
+
+// addressOf returns the address of a subscription, or an empty one if it has none.
+func addressOf(sub types.Subscription) types.Address {
+	if sub.Address == nil {
+		return types.Address{}
+	}
+	return *sub.Address
+}
+
+// joinInts stores a list of days as a comma-separated string, such as "7,14,30".
+func joinInts(ints []int) string {
+	fields := make([]string, len(ints))
+	for i, n := range ints {
+		fields[i] = strconv.Itoa(n)
+	}
+	return strings.Join(fields, ",")
+}
+
+// splitInts reads a list of days stored by joinInts.
+func splitInts(value string) ([]int, error) {
+	ints := []int{}
+	for _, field := range strings.Split(value, ",") {
+		if field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+// scanPlans scans and closes rows selected with planColumns.
+func scanPlans(rows *sql.Rows) ([]*types.SubscriptionPlan, error) {
+	defer rows.Close()
+
+	plans := []*types.SubscriptionPlan{}
+	for rows.Next() {
+		p := new(types.SubscriptionPlan)
+		var intervals string
+		if err := rows.Scan(&p.ID, &p.SKU, &p.Name, &intervals, &p.Discount, &p.Active, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if p.Intervals, err = splitInts(intervals); err != nil {
+			return nil, err
+		}
+		p.Discount = trimDecimal(p.Discount)
+		plans = append(plans, p)
+	}
+
+	return plans, rows.Err()
+}
+
+// trimDecimal drops the trailing zeros the database adds to a percentage, so "10.0000" reads "10".
+func trimDecimal(value string) string {
+	if !strings.Contains(value, ".") {
+		return value
+	}
+	return strings.TrimSuffix(strings.TrimRight(value, "0"), ".")
+}
+
+// scanSubscriptions scans and closes rows selected with subscriptionColumns.
+func scanSubscriptions(rows *sql.Rows) ([]*types.Subscription, error) {
+	defer rows.Close()
+
+	subs := []*types.Subscription{}
+	for rows.Next() {
+		sub := new(types.Subscription)
+		address := new(types.Address)
+		var shippingMethodID sql.NullInt64
+		var lastRenewedAt, cancelledAt sql.NullTime
+
+		err := rows.Scan(
+			&sub.ID, &sub.UserID, &sub.PlanID, &sub.SKU, &sub.Quantity, &sub.IntervalDays, &sub.Currency, &sub.PaymentMethodID,
+			&address.Country, &address.State, &address.PostalCode, &shippingMethodID, &sub.Status, &sub.NextRunAt,
+			&sub.Renewals, &sub.FailedAttempts, &sub.LastError, &lastRenewedAt, &sub.CreatedAt, &cancelledAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sub.Address = address
+		if shippingMethodID.Valid {
+			id := int(shippingMethodID.Int64)
+			sub.ShippingMethodID = &id
+		}
+		if lastRenewedAt.Valid {
+			sub.LastRenewedAt = &lastRenewedAt.Time
+		}
+		if cancelledAt.Valid {
+			sub.CancelledAt = &cancelledAt.Time
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
@@ -0,0 +1,207 @@
+package tax
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	// Import the types package for the tax types.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// taxClassStandard is the tax class of products without another one, and of classes a country has no rate for.
+const taxClassStandard = types.TaxClassStandard
+
+// taxClasses holds the known tax classes.
+var taxClasses = map[string]bool{
+	types.TaxClassStandard:     true,
+	types.TaxClassReduced:      true,
+	types.TaxClassSuperReduced: true,
+	types.TaxClassZero:         true,
+}
+
+// vatIDPattern matches VAT IDs without spaces, dots and dashes: a two-letter country prefix and 2 to 12 digits,
+// letters or the + and * some countries use.
+var vatIDPattern = regexp.MustCompile(`^[A-Z]{2}[0-9A-Z+*]{2,12}$`)
+
+// Calculator struct works out taxes from rules: VAT rates per country and tax class, sales tax rates per US
+// state and ZIP code, and the reverse charge for business customers in other countries.
+type Calculator struct {
+	rules    Rules                          // The tax rules.
+	rates    map[string]map[string]*big.Rat // The rates of the rules as fractions, by country and tax class.
+	salesTax *SalesTaxTable                 // Sales tax rates by state and ZIP code.
+}
+
+// NewCalculator is a constructor function that checks the rules and returns a new Calculator instance.
+func NewCalculator(rules Rules, salesTax *SalesTaxTable) (*Calculator, error) {
+	rates, err := parseRates(rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tax rules: %v", err)
+	}
+	if salesTax == nil {
+		salesTax = &SalesTaxTable{states: map[string]*big.Rat{}, zips: map[string]zipRate{}}
+	}
+
+	return &Calculator{rules: rules, rates: rates, salesTax: salesTax}, nil
+}
+
+// CalculateTax is a method on the Calculator struct that works out the tax on each line of an order.
+//
+// Lines are taxed at the rate of their tax class in the country shipped to, or at its standard rate if it has
+// no rate for the class. Countries without rules are not taxed, and neither are orders of business customers
+// with a VAT ID of another country that has the reverse charge. The VAT ID is only checked for its form.
+//
+// When prices include tax, the tax due is taken out of the price, so customers pay the price they were shown.
+// When no tax is due, the tax of the origin country is taken off the price instead. Tax is rounded per line.
+func (c *Calculator) CalculateTax(req types.TaxRequest) (*types.TaxResult, error) {
+	country := strings.ToUpper(req.Address.Country)
+	if country == "" {
+		country = c.rules.Origin
+	}
+
+	reverseCharge, err := c.reverseCharge(country, req.VATID)
+	if err != nil {
+		return nil, err
+	}
+
+	zero := types.NewMoney(0, req.Currency)
+	result := &types.TaxResult{
+		PricesIncludeTax: c.rules.PricesIncludeTax,
+		ReverseCharge:    reverseCharge,
+		Lines:            []types.LineTax{},
+		Net:              zero,
+		Tax:              zero,
+		Gross:            zero,
+	}
+
+	for _, line := range req.Lines {
+		class := line.TaxClass
+		if class == "" {
+			class = taxClassStandard
+		}
+
+		jurisdiction, rate, taxed := c.rate(country, class, req.Address)
+		if reverseCharge {
+			rate, taxed = new(big.Rat), false
+		}
+
+		lt, err := c.taxLine(line.Amount, rate, taxed, class)
+		if err != nil {
+			return nil, err
+		}
+		lt.SKU, lt.TaxClass, lt.Jurisdiction = line.SKU, class, jurisdiction
+
+		result.Lines = append(result.Lines, *lt)
+		result.Net.Amount += lt.Net.Amount
+		result.Tax.Amount += lt.Tax.Amount
+		result.Gross.Amount += lt.Gross.Amount
+	}
+
+	return result, nil
+}
+
+// rate returns the rate of a tax class in a country, as a fraction, with the jurisdiction it applies in, and
+// whether the country taxes orders at all.
+func (c *Calculator) rate(country, class string, address types.Address) (string, *big.Rat, bool) {
+	cr, ok := c.rules.Countries[country]
+	if !ok {
+		return country, new(big.Rat), false
+	}
+
+	if cr.SalesTax {
+		jurisdiction, rate := c.salesTax.lookup(country, address.State, strings.TrimSpace(address.PostalCode))
+		return jurisdiction, rate, true
+	}
+
+	return country, c.classRate(country, class), true
+}
+
+// classRate returns the rate of a tax class in a country with rules, or its standard rate if it has none for
+// the class.
+func (c *Calculator) classRate(country, class string) *big.Rat {
+	if rate, ok := c.rates[country][class]; ok {
+		return rate
+	}
+	if rate, ok := c.rates[country][taxClassStandard]; ok {
+		return rate
+	}
+	return new(big.Rat)
+}
+
+// taxLine works out the net amount, tax and gross amount of a line at a rate.
+func (c *Calculator) taxLine(amount types.Money, rate *big.Rat, taxed bool, class string) (*types.LineTax, error) {
+	lt := &types.LineTax{Rate: formatPercent(rate)}
+	one := big.NewRat(1, 1)
+
+	var err error
+	switch {
+	case !c.rules.PricesIncludeTax:
+		// The tax comes on top of the price.
+		lt.Net = amount
+		if lt.Tax, err = amount.MulRat(rate, types.RoundHalfUp); err != nil {
+			return nil, err
+		}
+	case taxed:
+		// The price includes the tax: gross × rate / (1 + rate) of it is tax.
+		lt.Tax, err = amount.MulRat(new(big.Rat).Quo(rate, new(big.Rat).Add(one, rate)), types.RoundHalfUp)
+		if err != nil {
+			return nil, err
+		}
+		lt.Net = types.NewMoney(amount.Amount-lt.Tax.Amount, amount.Currency)
+	default:
+		// The price includes the tax of the origin country, which is not due.
+		origin := new(big.Rat)
+		if c.rules.Origin != "" && !c.rules.Countries[c.rules.Origin].SalesTax {
+			origin = c.classRate(c.rules.Origin, class)
+		}
+		lt.Net, err = amount.MulRat(new(big.Rat).Quo(one, new(big.Rat).Add(one, origin)), types.RoundHalfUp)
+		if err != nil {
+			return nil, err
+		}
+		lt.Tax = types.NewMoney(0, amount.Currency)
+	}
+	lt.Gross = types.NewMoney(lt.Net.Amount+lt.Tax.Amount, amount.Currency)
+
+	return lt, nil
+}
+
+// reverseCharge returns whether the VAT of an order to a country shifts to the business customer with the VAT
+// ID: the country must have the reverse charge and not be the origin, and the VAT ID must be of the country.
+// VAT IDs that are not well-formed are rejected even when the reverse charge does not apply.
+func (c *Calculator) reverseCharge(country, vatID string) (bool, error) {
+	if vatID == "" {
+		return false, nil
+	}
+
+	id := NormalizeVATID(vatID)
+	if !vatIDPattern.MatchString(id) {
+		return false, fmt.Errorf("%w %q", types.ErrInvalidVATID, vatID)
+	}
+	if !c.rules.Countries[country].ReverseCharge || country == c.rules.Origin {
+		return false, nil
+	}
+
+	// Greece uses the prefix EL rather than its country code.
+	prefix := country
+	if prefix == "GR" {
+		prefix = "EL"
+	}
+	if id[:2] != prefix {
+		return false, fmt.Errorf("%w: %s is not a VAT ID of %s", types.ErrInvalidVATID, vatID, country)
+	}
+
+	return true, nil
+}
+
+// NormalizeVATID returns a VAT ID in upper case, without the spaces, dots and dashes it is often written with.
+func NormalizeVATID(vatID string) string {
+	return strings.NewReplacer(" ", "", ".", "", "-", "").Replace(strings.ToUpper(strings.TrimSpace(vatID)))
+}
+
+// formatPercent formats a rate given as a fraction as a percentage without trailing zeros, such as "21" or "9.5".
+func formatPercent(rate *big.Rat) string {
+	s := new(big.Rat).Mul(rate, big.NewRat(100, 1)).FloatString(4)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
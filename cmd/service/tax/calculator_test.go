@@ -0,0 +1,161 @@
+package tax
+
+import (
+	"errors"  // Import the errors package to check returned errors
+	"strings" // Import the strings package to read sales tax tables from strings
+	"testing" // Import the testing package to write test cases
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for tax types
+)
+
+// TestCalculator tests working out taxes from the rules.
+func TestCalculator(t *testing.T) {
+	// vat is a shop in the Netherlands with prices including VAT, selling in the EU.
+	vat := Rules{
+		Origin:           "NL",
+		PricesIncludeTax: true,
+		Countries: map[string]CountryRules{
+			"NL": {Rates: map[string]string{"standard": "21", "reduced": "9"}, ReverseCharge: true},
+			"DE": {Rates: map[string]string{"standard": "19", "reduced": "7"}, ReverseCharge: true},
+		},
+	}
+
+	// calculate works out the tax on a standard line and a reduced line of 12.10 euros each.
+	calculate := func(t *testing.T, rules Rules, address types.Address, vatID string) (*types.TaxResult, error) {
+		t.Helper()
+
+		c, err := NewCalculator(rules, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c.CalculateTax(types.TaxRequest{Address: address, VATID: vatID, Currency: "EUR", Lines: []types.TaxableLine{
+			{SKU: "SHIRT", TaxClass: types.TaxClassStandard, Amount: types.NewMoney(1210, "EUR")},
+			{SKU: "BOOK", TaxClass: types.TaxClassReduced, Amount: types.NewMoney(1210, "EUR")},
+		}})
+	}
+
+	t.Run("should take the tax out of prices that include it", func(t *testing.T) {
+		result, err := calculate(t, vat, types.Address{Country: "nl"}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shirt, book := result.Lines[0], result.Lines[1]
+		if shirt.Rate != "21" || shirt.Tax != types.NewMoney(210, "EUR") || shirt.Net != types.NewMoney(1000, "EUR") || shirt.Gross != types.NewMoney(1210, "EUR") {
+			t.Errorf("expected 2.10 of 21%% VAT in 12.10, got %+v", shirt)
+		}
+		if book.Rate != "9" || book.Tax != types.NewMoney(100, "EUR") || book.Jurisdiction != "NL" {
+			t.Errorf("expected 1.00 of 9%% VAT in 12.10, got %+v", book)
+		}
+		if result.Tax != types.NewMoney(310, "EUR") || result.Gross != types.NewMoney(2420, "EUR") || !result.PricesIncludeTax {
+			t.Errorf("expected 3.10 of VAT in 24.20, got %+v", result)
+		}
+	})
+
+	t.Run("should tax orders without a country as sold in the origin country", func(t *testing.T) {
+		result, _ := calculate(t, vat, types.Address{}, "")
+		if result.Lines[0].Jurisdiction != "NL" || result.Tax != types.NewMoney(310, "EUR") {
+			t.Errorf("expected Dutch VAT, got %+v", result)
+		}
+	})
+
+	t.Run("should tax at the rates of the country shipped to", func(t *testing.T) {
+		result, _ := calculate(t, vat, types.Address{Country: "DE"}, "")
+
+		// 12.10 × 19 / 119 = 1.932, and 12.10 × 7 / 107 = 0.792.
+		if result.Lines[0].Tax != types.NewMoney(193, "EUR") || result.Lines[1].Tax != types.NewMoney(79, "EUR") || result.Gross != types.NewMoney(2420, "EUR") {
+			t.Errorf("expected German VAT in the same prices, got %+v", result)
+		}
+	})
+
+	t.Run("should reverse charge business customers in other countries", func(t *testing.T) {
+		result, err := calculate(t, vat, types.Address{Country: "DE"}, "de 123.456.789")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The Dutch VAT is taken off: 12.10 / 1.21 and 12.10 / 1.09.
+		if !result.ReverseCharge || result.Tax != types.NewMoney(0, "EUR") || result.Lines[0].Gross != types.NewMoney(1000, "EUR") || result.Lines[1].Gross != types.NewMoney(1110, "EUR") {
+			t.Errorf("expected no VAT on the net prices, got %+v", result)
+		}
+
+		// Business customers in the origin country pay VAT.
+		if result, _ := calculate(t, vat, types.Address{Country: "NL"}, "NL123456789B01"); result.ReverseCharge || result.Tax != types.NewMoney(310, "EUR") {
+			t.Errorf("expected VAT in the origin country, got %+v", result)
+		}
+	})
+
+	t.Run("should reject VAT IDs that cannot be used", func(t *testing.T) {
+		for _, vatID := range []string{"123456789", "DE 1", "NL123456789B01"} {
+			if _, err := calculate(t, vat, types.Address{Country: "DE"}, vatID); !errors.Is(err, types.ErrInvalidVATID) {
+				t.Errorf("expected %q to be rejected, got %v", vatID, err)
+			}
+		}
+	})
+
+	t.Run("should not tax exports, taking the tax of the origin country off", func(t *testing.T) {
+		result, _ := calculate(t, vat, types.Address{Country: "CH"}, "")
+		if result.Lines[0].Jurisdiction != "CH" || result.Tax != types.NewMoney(0, "EUR") || result.Net != types.NewMoney(2110, "EUR") {
+			t.Errorf("expected no tax on the net prices, got %+v", result)
+		}
+	})
+
+	t.Run("should add sales tax by state and ZIP code to prices", func(t *testing.T) {
+		table, err := ReadSalesTaxTable(strings.NewReader(
+			"State,ZipCode,TaxRegionName,EstimatedCombinedRate,StateRate\n" +
+				"CA,90001,LOS ANGELES,0.0950,0.0725\n" +
+				"NY,10001,NEW YORK CITY,0.08875,0.04\n",
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewCalculator(Rules{Origin: "US", Countries: map[string]CountryRules{"US": {SalesTax: true}}}, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tc := range []struct {
+			address      types.Address
+			jurisdiction string
+			rate         string
+			tax          int64
+		}{
+			{types.Address{Country: "US", State: "CA", PostalCode: "90001-1234"}, "US-CA-90001", "9.5", 950},
+			{types.Address{Country: "US", State: "ca", PostalCode: "94000"}, "US-CA", "7.25", 725},
+			{types.Address{Country: "US", State: "NY", PostalCode: "10001"}, "US-NY-10001", "8.875", 888},
+			{types.Address{Country: "US", State: "OR", PostalCode: "97201"}, "US", "0", 0},
+		} {
+			result, err := c.CalculateTax(types.TaxRequest{Address: tc.address, Currency: "USD", Lines: []types.TaxableLine{
+				{SKU: "SHIRT", Amount: types.NewMoney(10000, "USD")},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			line := result.Lines[0]
+			if line.Jurisdiction != tc.jurisdiction || line.Rate != tc.rate || line.Tax.Amount != tc.tax || line.Gross.Amount != 10000+tc.tax {
+				t.Errorf("expected %s%% in %s on 100.00, got %+v", tc.rate, tc.jurisdiction, line)
+			}
+		}
+	})
+
+	t.Run("should reject invalid rules", func(t *testing.T) {
+		for _, rules := range []Rules{
+			{Origin: "NL"},
+			{Countries: map[string]CountryRules{"NL": {Rates: map[string]string{"reduced": "9"}}}},
+			{Countries: map[string]CountryRules{"NL": {Rates: map[string]string{"standard": "21%"}}}},
+			{Countries: map[string]CountryRules{"NL": {Rates: map[string]string{"standard": "121"}}}},
+			{Countries: map[string]CountryRules{"NL": {Rates: map[string]string{"standard": "21", "luxury": "25"}}}},
+			{Countries: map[string]CountryRules{"nl": {Rates: map[string]string{"standard": "21"}}}},
+		} {
+			if _, err := NewCalculator(rules, nil); err == nil {
+				t.Errorf("expected %+v to be rejected", rules)
+			}
+		}
+
+		if _, err := ReadSalesTaxTable(strings.NewReader("state,zip,rate\nCA,900,0.095\n")); err == nil {
+			t.Errorf("expected an invalid ZIP code to be rejected")
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package tax
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rules struct holds the tax rules of the shop, as kept in the JSON rules file, such as:
+//
+//	{
+//	  "origin": "NL",
+//	  "pricesIncludeTax": true,
+//	  "countries": {
+//	    "NL": {"rates": {"standard": "21", "reduced": "9"}, "reverseCharge": true},
+//	    "DE": {"rates": {"standard": "19", "reduced": "7"}, "reverseCharge": true},
+//	    "US": {"salesTax": true}
+//	  }
+//	}
+type Rules struct {
+	Origin           string                  `json:"origin"`           // Country the shop sells from, such as "NL".
+	PricesIncludeTax bool                    `json:"pricesIncludeTax"` // Whether product prices include the tax of the origin country.
+	Countries        map[string]CountryRules `json:"countries"`        // Rules by country code. Orders shipped to other countries are not taxed.
+}
+
+// CountryRules struct holds the tax rules of a country.
+type CountryRules struct {
+	Rates         map[string]string `json:"rates"`         // Rates as percentages by tax class, such as {"standard": "21"}. The standard rate is required unless SalesTax is set.
+	ReverseCharge bool              `json:"reverseCharge"` // Whether business customers with a VAT ID of this country account for the VAT themselves when buying from another country, as in the EU.
+	SalesTax      bool              `json:"salesTax"`      // Whether the rate comes from the sales tax table, by state and ZIP code, for all tax classes.
+}
+
+// countryPattern matches ISO 3166-1 alpha-2 country codes.
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// percentPattern matches rates as percentages, such as "21" or "5.5".
+var percentPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// LoadRules loads the tax rules from the JSON file at path. A missing file is not an error: nothing is taxed
+// then, and prices are taken to exclude tax.
+func LoadRules(path string) (Rules, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Rules{}, nil
+	}
+	if err != nil {
+		return Rules{}, err
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return Rules{}, fmt.Errorf("invalid tax rules file %s: %v", path, err)
+	}
+
+	return rules, nil
+}
+
+// parseRates checks the rules and returns the rates of each country as fractions, by country and tax class.
+func parseRates(rules Rules) (map[string]map[string]*big.Rat, error) {
+	if rules.Origin != "" {
+		if _, ok := rules.Countries[rules.Origin]; !ok {
+			return nil, fmt.Errorf("the origin %q has no rules", rules.Origin)
+		}
+	}
+
+	rates := map[string]map[string]*big.Rat{}
+	for country, cr := range rules.Countries {
+		if !countryPattern.MatchString(country) {
+			return nil, fmt.Errorf("invalid country code %q", country)
+		}
+		if _, ok := cr.Rates[taxClassStandard]; !ok && !cr.SalesTax {
+			return nil, fmt.Errorf("country %s has no standard rate", country)
+		}
+
+		rates[country] = map[string]*big.Rat{}
+		for class, percent := range cr.Rates {
+			if !taxClasses[class] {
+				return nil, fmt.Errorf("country %s has a rate for the unknown tax class %q", country, class)
+			}
+			rate, err := parsePercent(percent)
+			if err != nil {
+				return nil, fmt.Errorf("country %s: %v", country, err)
+			}
+			rates[country][class] = rate
+		}
+	}
+
+	return rates, nil
+}
+
+// parsePercent parses a rate as a percentage, from 0 to 100, and returns it as a fraction.
+func parsePercent(percent string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(percent)
+	if !percentPattern.MatchString(percent) || !ok || r.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, fmt.Errorf("invalid rate %q, expected a percentage such as \"21\"", percent)
+	}
+
+	return r.Quo(r, big.NewRat(100, 1)), nil
+}
+
+// SalesTaxTable struct holds US-style sales tax rates, which differ by state and, within states, by ZIP code.
+type SalesTaxTable struct {
+	states map[string]*big.Rat // State-wide rates by state code.
+	zips   map[string]zipRate  // Combined rates of state, county and city by five-digit ZIP code.
+}
+
+// zipRate struct holds the sales tax rate of a ZIP code.
+type zipRate struct {
+	state string   // Code of the state the ZIP code is in.
+	rate  *big.Rat // The rate, as a fraction.
+}
+
+// LoadSalesTaxTable loads a sales tax table from the CSV file at path. A missing file is not an error: the
+// table is empty then, so countries with sales tax are not taxed.
+func LoadSalesTaxTable(path string) (*SalesTaxTable, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &SalesTaxTable{states: map[string]*big.Rat{}, zips: map[string]zipRate{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	table, err := ReadSalesTaxTable(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sales tax file %s: %v", path, err)
+	}
+
+	return table, nil
+}
+
+// ReadSalesTaxTable reads a sales tax table from CSV with a header row. Columns are found by name, ignoring
+// case, so the files rate providers publish can be used as they are:
+//
+//   - "state": the state code, such as "CA".
+//   - "zip" or "zipCode": the five-digit ZIP code; rows without one set the state-wide rate.
+//   - "rate" or "estimatedCombinedRate": the combined rate of the ZIP code, as a fraction such as "0.0950".
+//   - "stateRate", optionally: the state-wide rate, used for ZIP codes that are not in the table.
+//
+// Other columns are ignored.
+func ReadSalesTaxTable(r io.Reader) (*SalesTaxTable, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header row: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	column := func(names ...string) int {
+		for _, name := range names {
+			if i, ok := columns[strings.ToLower(name)]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	stateCol, zipCol, rateCol, stateRateCol := column("state"), column("zip", "zipCode"), column("rate", "estimatedCombinedRate"), column("stateRate")
+	if stateCol < 0 || rateCol < 0 {
+		return nil, fmt.Errorf("the header needs a state and a rate column")
+	}
+
+	table := &SalesTaxTable{states: map[string]*big.Rat{}, zips: map[string]zipRate{}}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		state := strings.ToUpper(field(stateCol))
+		if state == "" {
+			return nil, fmt.Errorf("line %d: missing state", line)
+		}
+		rate, err := parseFraction(field(rateCol))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		zip := field(zipCol)
+		if zip == "" {
+			table.states[state] = rate
+			continue
+		}
+		if len(zip) != 5 || strings.Trim(zip, "0123456789") != "" {
+			return nil, fmt.Errorf("line %d: invalid ZIP code %q", line, zip)
+		}
+		table.zips[zip] = zipRate{state: state, rate: rate}
+
+		if v := field(stateRateCol); v != "" {
+			if table.states[state], err = parseFraction(v); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+	}
+
+	return table, nil
+}
+
+// parseFraction parses a rate as a fraction, from 0 to 1.
+func parseFraction(fraction string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(fraction)
+	if !percentPattern.MatchString(fraction) || !ok || r.Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, fmt.Errorf("invalid rate %q, expected a fraction such as \"0.0950\"", fraction)
+	}
+
+	return r, nil
+}
+
+// lookup returns the sales tax rate of an address, with the jurisdiction it applies in: the rate of the ZIP
+// code if the table has it, else the rate of the state, else none.
+func (t *SalesTaxTable) lookup(country, state, postalCode string) (string, *big.Rat) {
+	state = strings.ToUpper(state)
+
+	// ZIP+4 codes such as "90001-1234" are looked up by their first five digits.
+	zip := postalCode
+	if len(zip) > 5 {
+		zip = zip[:5]
+	}
+	if z, ok := t.zips[zip]; ok && (state == "" || state == z.state) {
+		return country + "-" + z.state + "-" + zip, z.rate
+	}
+	if rate, ok := t.states[state]; ok {
+		return country + "-" + state, rate
+	}
+
+	return country, new(big.Rat)
+}
@@ -4,6 +4,9 @@ import (
 	// Import necessary packages for handling HTTP requests and responses, routing, and utilities.
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
 	// Import the auth package for password hashing and authentication-related utilities.
 	"github.com/FreekAlberti/Ecom/cmd/service/auth"
@@ -38,12 +41,67 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 
 	// Register the /register route with the handleRegister method, also listening for POST requests.
 	router.HandleFunc("/register", h.handleRegister).Methods("POST")
+
+	// Register the /me route, returning the authenticated user.
+	// Like /me/password, it can be used by users who have to change their password.
+	router.HandleFunc("/me", auth.WithPasswordResetAuth(h.handleGetMe, h.store)).Methods("GET")
+
+	// Register the /me/password route for changing the password. It cannot be used while impersonating.
+	router.HandleFunc("/me/password", auth.WithPasswordResetAuth(auth.WithoutImpersonation(h.handleChangePassword), h.store)).Methods("POST")
+
+	// Register the /me/api-keys routes for managing personal API keys.
+	// Keys can only be managed after an interactive login, never with another API key or while impersonating.
+	router.HandleFunc("/me/api-keys", auth.WithAuth(auth.WithoutAPIKey(h.handleListAPIKeys), h.store)).Methods("GET")
+	router.HandleFunc("/me/api-keys", auth.WithAuth(auth.WithoutAPIKey(auth.WithoutImpersonation(h.handleCreateAPIKey)), h.store)).Methods("POST")
+	router.HandleFunc("/me/api-keys/{id}", auth.WithAuth(auth.WithoutAPIKey(auth.WithoutImpersonation(h.handleRevokeAPIKey)), h.store)).Methods("DELETE")
 }
 
 // handleLogin is a method on the Handler struct that handles requests to the /login route.
-// It will be called when a POST request is made to /login. (Implementation not provided here)
+// It checks the user's credentials and responds with a signed JWT when they are correct.
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
-	// The actual login handling code would be implemented here.
+	var payload types.LoginUserPayload // Struct to hold the login credentials.
+
+	// Parse the incoming JSON request body into the LoginUserPayload struct.
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the parsed payload using the validator package.
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// Look up the user by email. The same error is returned for an unknown email and a wrong password,
+	// so the endpoint cannot be used to find out which emails are registered.
+	u, err := h.store.GetUserByEmail(payload.Email)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid email or password"))
+		return
+	}
+
+	// Check the provided password against the stored hash.
+	if !auth.ComparePasswords(u.Password, []byte(payload.Password)) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid email or password"))
+		return
+	}
+
+	// Disabled accounts cannot log in.
+	if u.Disabled {
+		utils.WriteError(w, http.StatusForbidden, fmt.Errorf("account is disabled"))
+		return
+	}
+
+	// Create a signed token for the user, and tell the client whether the user has to change their password.
+	res, err := auth.CreateLoginResponse(u)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, res)
 }
 
 // handleRegister is a method on the Handler struct that handles requests to the /register route.
@@ -99,3 +157,148 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	// If the user is successfully created, return a 201 Created status with no content.
 	utils.WriteJSON(w, http.StatusCreated, nil)
 }
+
+// handleGetMe is a method on the Handler struct that handles GET requests to the /me route.
+// It returns the authenticated user, flagging the response when an admin is impersonating them.
+func (h *Handler) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	impersonation := auth.GetImpersonationFromContext(r.Context())
+
+	utils.WriteJSON(w, http.StatusOK, types.MeResponse{
+		User:          auth.GetUserFromContext(r.Context()),
+		Impersonated:  impersonation != nil,
+		Impersonation: impersonation,
+	})
+}
+
+// handleChangePassword is a method on the Handler struct that handles POST requests to the /me/password route.
+// It checks the current password before replacing it, which also satisfies a password reset forced by an admin.
+// Users without a password, created by a social login, set their first one here.
+func (h *Handler) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	u := auth.GetUserFromContext(r.Context())
+
+	// Parse and validate the request body.
+	var payload types.ChangePasswordPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// The current password must be correct. Users created by a social login have none until they set one.
+	if u.Password != "" && !auth.ComparePasswords(u.Password, []byte(payload.CurrentPassword)) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("current password is incorrect"))
+		return
+	}
+
+	// Hash and store the new password.
+	hashedPassword, err := auth.HashPassword(payload.NewPassword)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.store.UpdatePassword(u.ID, hashedPassword); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListAPIKeys is a method on the Handler struct that handles GET requests to the /me/api-keys route.
+// It returns the authenticated user's API keys, without their secrets.
+func (h *Handler) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.store.GetAPIKeysByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, keys)
+}
+
+// handleCreateAPIKey is a method on the Handler struct that handles POST requests to the /me/api-keys route.
+// It creates a new API key and returns the full key. This is the only time the key is shown.
+func (h *Handler) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	u := auth.GetUserFromContext(r.Context())
+
+	// Parse and validate the request body.
+	var payload types.CreateAPIKeyPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("expiresAt must be in the future"))
+		return
+	}
+
+	// Only admins can create keys with the admin scope. Every request also needs the read or write scope, so a key
+	// with only the admin scope could not be used at all.
+	if slices.Contains(payload.Scopes, types.ScopeAdmin) {
+		if u.Role != types.RoleAdmin {
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("only admins can create keys with the admin scope"))
+			return
+		}
+		if !slices.Contains(payload.Scopes, types.ScopeRead) && !slices.Contains(payload.Scopes, types.ScopeWrite) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("the admin scope needs the read or write scope"))
+			return
+		}
+	}
+
+	// Generate the key.
+	key, prefix, secretHash, err := auth.GenerateAPIKey()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Store the key, keeping only the hash of its secret.
+	apiKey := types.APIKey{
+		UserID:     u.ID,
+		Name:       payload.Name,
+		Prefix:     prefix,
+		SecretHash: secretHash,
+		Scopes:     payload.Scopes,
+		ExpiresAt:  payload.ExpiresAt,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if apiKey.Scopes == nil {
+		apiKey.Scopes = []string{}
+	}
+	apiKey.ID, err = h.store.CreateAPIKey(apiKey)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"key":    key,
+		"apiKey": apiKey,
+	})
+}
+
+// handleRevokeAPIKey is a method on the Handler struct that handles DELETE requests to the /me/api-keys/{id} route.
+// Revoked keys stop working immediately but are kept, so their last use can still be looked up.
+func (h *Handler) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid API key ID"))
+		return
+	}
+
+	if err := h.store.RevokeAPIKey(auth.GetUserIDFromContext(r.Context()), keyID); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
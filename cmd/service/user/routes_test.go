@@ -7,9 +7,12 @@ import (
 	"net/http"          // Import the net/http package for HTTP client and server implementations
 	"net/http/httptest" // Import the httptest package for HTTP testing utilities
 	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for API key timestamps
 
-	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for user-related types
-	"github.com/gorilla/mux"                 // Import the Gorilla Mux package for routing HTTP requests
+	"github.com/FreekAlberti/Ecom/cmd/config"       // Import the config package for the JWT secret
+	"github.com/FreekAlberti/Ecom/cmd/service/auth" // Import the auth package to create tokens
+	"github.com/FreekAlberti/Ecom/cmd/types"        // Import the custom types package for user-related types
+	"github.com/gorilla/mux"                        // Import the Gorilla Mux package for routing HTTP requests
 )
 
 // TestUserServiceHandlers tests the user service HTTP handlers.
@@ -58,8 +61,212 @@ func TestUserServiceHandlers(t *testing.T) {
 	})
 }
 
-// mockUserStore is a mock implementation of the UserStore interface, used for testing purposes.
-type mockUserStore struct{}
+// TestAPIKeyHandlers tests the handlers that manage personal API keys.
+func TestAPIKeyHandlers(t *testing.T) {
+	// Create a store with two customers (IDs 1 and 2) and an admin (ID 3), and a router serving the handler.
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "jane@example.com", Role: types.RoleCustomer},
+		2: {ID: 2, Email: "john@example.com", Role: types.RoleCustomer},
+		3: {ID: 3, Email: "admin@example.com", Role: types.RoleAdmin},
+	}}
+
+	router := mux.NewRouter()
+	NewHandler(userStore).RegisterRoutes(router)
+
+	// create creates a key for the user and returns the full key and the stored key.
+	create := func(t *testing.T, router *mux.Router, userID int) (string, types.APIKey) {
+		t.Helper()
+
+		rr := serve(t, router, http.MethodPost, "/me/api-keys", []byte(`{"name": "ci", "scopes": ["read"]}`), userID)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		var created struct {
+			Key    string       `json:"key"`
+			APIKey types.APIKey `json:"apiKey"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+			t.Fatal(err)
+		}
+
+		return created.Key, created.APIKey
+	}
+
+	t.Run("should return the full key only when it is created", func(t *testing.T) {
+		key, apiKey := create(t, router, 1)
+		prefix, secret, err := auth.ParseAPIKey(key)
+		if err != nil || prefix != apiKey.Prefix {
+			t.Fatalf("expected a full key starting with prefix %q, got %q (%v)", apiKey.Prefix, key, err)
+		}
+
+		rr := serve(t, router, http.MethodGet, "/me/api-keys", nil, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if bytes.Contains(rr.Body.Bytes(), []byte(secret)) {
+			t.Errorf("expected the listed keys to leave out the secret, got %s", rr.Body)
+		}
+
+		var keys []types.APIKey
+		if err := json.NewDecoder(rr.Body).Decode(&keys); err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 1 || keys[0].ID != apiKey.ID {
+			t.Errorf("expected the created key to be listed, got %+v", keys)
+		}
+	})
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		stored := len(userStore.keys)
+		rr := serve(t, router, http.MethodPost, "/me/api-keys", []byte(`{"name": "ci", "scopes": ["read", "root"]}`), 1)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if len(userStore.keys) != stored {
+			t.Errorf("expected no key to be stored, got %d", len(userStore.keys)-stored)
+		}
+	})
+
+	t.Run("should reject the admin scope for customers", func(t *testing.T) {
+		rr := serve(t, router, http.MethodPost, "/me/api-keys", []byte(`{"name": "ci", "scopes": ["admin"]}`), 1)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should only give the admin scope with the read or write scope", func(t *testing.T) {
+		if rr := serve(t, router, http.MethodPost, "/me/api-keys", []byte(`{"name": "ci", "scopes": ["admin"]}`), 3); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for the admin scope alone, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := serve(t, router, http.MethodPost, "/me/api-keys", []byte(`{"name": "ci", "scopes": ["write", "admin"]}`), 3); rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d for the write and admin scopes, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+	})
+
+	t.Run("should not let a user list or revoke another user's keys", func(t *testing.T) {
+		_, apiKey := create(t, router, 1)
+
+		rr := serve(t, router, http.MethodGet, "/me/api-keys", nil, 2)
+		var keys []types.APIKey
+		if err := json.NewDecoder(rr.Body).Decode(&keys); err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 0 {
+			t.Errorf("expected no keys for the other user, got %+v", keys)
+		}
+
+		rr = serve(t, router, http.MethodDelete, fmt.Sprintf("/me/api-keys/%d", apiKey.ID), nil, 2)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+		if userStore.keys[apiKey.ID-1].RevokedAt != nil {
+			t.Error("expected the key to stay active")
+		}
+	})
+
+	t.Run("should refuse a key once it is revoked", func(t *testing.T) {
+		key, apiKey := create(t, router, 1)
+
+		// getMe fetches the user with the key and returns the status code.
+		getMe := func() int {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("X-API-Key", key)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		if code := getMe(); code != http.StatusOK {
+			t.Fatalf("expected status code %d before revoking, got %d", http.StatusOK, code)
+		}
+
+		rr := serve(t, router, http.MethodDelete, fmt.Sprintf("/me/api-keys/%d", apiKey.ID), nil, 1)
+		if rr.Code != http.StatusNoContent || rr.Body.Len() != 0 {
+			t.Fatalf("expected an empty response with status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+
+		if code := getMe(); code != http.StatusForbidden {
+			t.Errorf("expected status code %d after revoking, got %d", http.StatusForbidden, code)
+		}
+	})
+}
+
+// TestChangePassword tests that the current password is checked, unless the user has none yet.
+func TestChangePassword(t *testing.T) {
+	hashedPassword, err := auth.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "jane@example.com", Role: types.RoleCustomer, Password: hashedPassword},
+		2: {ID: 2, Email: "john@example.com", Role: types.RoleCustomer},
+	}}
+
+	router := mux.NewRouter()
+	NewHandler(userStore).RegisterRoutes(router)
+
+	if rr := serve(t, router, http.MethodPost, "/me/password", []byte(`{"newPassword": "new secret"}`), 1); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d without the current password, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := serve(t, router, http.MethodPost, "/me/password", []byte(`{"currentPassword": "secret", "newPassword": "new secret"}`), 1); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status code %d with the current password, got %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+	}
+	if rr := serve(t, router, http.MethodPost, "/me/password", []byte(`{"newPassword": "new secret"}`), 2); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status code %d setting a first password, got %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+	}
+}
+
+// TestPasswordResetRequired tests that a user who has to change their password can only see themselves and
+// change it.
+func TestPasswordResetRequired(t *testing.T) {
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Email: "jane@example.com", Role: types.RoleCustomer, PasswordResetRequired: true},
+	}}
+
+	router := mux.NewRouter()
+	NewHandler(userStore).RegisterRoutes(router)
+
+	if rr := serve(t, router, http.MethodGet, "/me", nil, 1); rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d for /me, got %d", http.StatusOK, rr.Code)
+	}
+	if rr := serve(t, router, http.MethodPost, "/me/password", []byte(`{}`), 1); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d for an invalid password change, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := serve(t, router, http.MethodGet, "/me/api-keys", nil, 1); rr.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d for /me/api-keys, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+// serve sends a request authenticated as the given user through the router and returns the recorded response.
+func serve(t *testing.T, router *mux.Router, method, target string, body []byte, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign a token for the user, the same way the login handler does.
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// mockUserStore is an in-memory implementation of the UserStore interface, used for testing purposes.
+type mockUserStore struct {
+	users map[int]*types.User // Users by ID.
+	keys  []*types.APIKey     // API keys, where the ID is the index plus one.
+}
 
 // GetUserByEmail is a mock method that simulates retrieving a user by their email.
 // In this mock implementation, it always returns an error indicating the user was not found.
@@ -67,10 +274,15 @@ func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
 	return nil, fmt.Errorf("user not found")
 }
 
-// GetUserByID is a mock method that simulates retrieving a user by their ID.
-// In this mock implementation, it returns nil and no error, simulating a user not being found.
+// GetUserByID is a mock method that returns a copy of the user with the given ID.
 func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
-	return nil, nil
+	u, ok := m.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	c := *u
+	return &c, nil
 }
 
 // CreateUser is a mock method that simulates creating a new user.
@@ -78,3 +290,72 @@ func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
 func (m *mockUserStore) CreateUser(types.User) error {
 	return nil
 }
+
+// UpdateUser is a mock method that simulates updating a user.
+// In this mock implementation, it simply returns nil, indicating no error.
+func (m *mockUserStore) UpdateUser(types.User) error {
+	return nil
+}
+
+// ListUsers is a mock method that simulates listing users.
+// In this mock implementation, it returns no users and no next cursor.
+func (m *mockUserStore) ListUsers(types.UserFilter) ([]*types.User, string, error) {
+	return nil, "", nil
+}
+
+// UpdatePassword is a mock method that simulates changing a user's password.
+// In this mock implementation, it simply returns nil, indicating no error.
+func (m *mockUserStore) UpdatePassword(int, string) error {
+	return nil
+}
+
+// UpdateCurrency is a mock method that simulates setting a user's preferred currency.
+// In this mock implementation, it simply returns nil, indicating no error.
+func (m *mockUserStore) UpdateCurrency(int, string) error {
+	return nil
+}
+
+// CreateAPIKey is a mock method that appends the key.
+func (m *mockUserStore) CreateAPIKey(key types.APIKey) (int, error) {
+	key.ID = len(m.keys) + 1
+	m.keys = append(m.keys, &key)
+	return key.ID, nil
+}
+
+// GetAPIKeyByPrefix is a mock method that looks up a key by its prefix.
+func (m *mockUserStore) GetAPIKeyByPrefix(prefix string) (*types.APIKey, error) {
+	for _, k := range m.keys {
+		if k.Prefix == prefix {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("API key not found")
+}
+
+// GetAPIKeysByUserID is a mock method that returns the keys of a user.
+func (m *mockUserStore) GetAPIKeysByUserID(userID int) ([]*types.APIKey, error) {
+	keys := []*types.APIKey{}
+	for _, k := range m.keys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey is a mock method that marks a key of the user as revoked.
+func (m *mockUserStore) RevokeAPIKey(userID, keyID int) error {
+	if keyID < 1 || keyID > len(m.keys) || m.keys[keyID-1].UserID != userID {
+		return fmt.Errorf("API key not found")
+	}
+	now := time.Now()
+	m.keys[keyID-1].RevokedAt = &now
+	return nil
+}
+
+// TouchAPIKey is a mock method that sets the last used time of a key.
+func (m *mockUserStore) TouchAPIKey(keyID int) error {
+	now := time.Now()
+	m.keys[keyID-1].LastUsedAt = &now
+	return nil
+}
@@ -3,12 +3,36 @@ package user
 import (
 	// Import the sql package for interacting with the SQL database.
 	"database/sql"
+	// Import the base64 and json packages for encoding pagination cursors.
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	// Import the strings package for building dynamic SQL queries.
+	"strings"
+	"time"
 
 	// Import the types package, which likely contains data types used across the application, such as User.
 	"github.com/FreekAlberti/Ecom/cmd/types"
 )
 
+// userColumns lists the columns selected for a user, in the order scanRowIntoUser expects them.
+const userColumns = "id, firstName, lastName, email, password, createdAt, role, verified, disabled, passwordResetRequired, currency"
+
+// Default and maximum number of users returned by ListUsers.
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// sortColumns maps the sort fields accepted in a UserFilter to the columns they sort on.
+// Only columns listed here can ever end up in an ORDER BY clause.
+var sortColumns = map[string]string{
+	types.UserSortByID:        "id",
+	types.UserSortByCreatedAt: "createdAt",
+	types.UserSortByEmail:     "email",
+	types.UserSortByLastName:  "lastName",
+}
+
 // Store struct represents the data store that interacts with the user data in the database.
 // It holds a reference to the SQL database connection.
 type Store struct {
@@ -26,11 +50,12 @@ func NewStore(db *sql.DB) *Store {
 // It returns a pointer to a User object and an error if something goes wrong.
 func (s *Store) GetUserByEmail(email string) (*types.User, error) {
 	// Execute an SQL query to find the user by email.
-	rows, err := s.db.Query("SELECT * FROM users WHERE email = ?", email)
+	rows, err := s.db.Query("SELECT "+userColumns+" FROM users WHERE email = ?", email)
 	if err != nil {
 		// Return nil and the error if the query execution fails.
 		return nil, err
 	}
+	defer rows.Close()
 
 	// Initialize a new User object to store the retrieved data.
 	u := new(types.User)
@@ -67,6 +92,11 @@ func scanRowIntoUser(rows *sql.Rows) (*types.User, error) {
 		&user.Email,
 		&user.Password,
 		&user.CreatedAt,
+		&user.Role,
+		&user.Verified,
+		&user.Disabled,
+		&user.PasswordResetRequired,
+		&user.Currency,
 	)
 
 	// If scanning fails, return nil and the error.
@@ -79,15 +109,408 @@ func scanRowIntoUser(rows *sql.Rows) (*types.User, error) {
 }
 
 // GetUserByID is a method on the Store struct that retrieves a user from the database by their ID.
-// The method is not yet implemented and currently returns nil and no error.
+// It returns a pointer to a User object and an error if the user does not exist or something goes wrong.
 func (s *Store) GetUserByID(id int) (*types.User, error) {
-	// Implementation is needed to return a user by ID.
-	return nil, nil
+	// Execute an SQL query to find the user by ID.
+	rows, err := s.db.Query("SELECT "+userColumns+" FROM users WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Iterate over the result set to populate the User object.
+	u := new(types.User)
+	for rows.Next() {
+		u, err = scanRowIntoUser(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A zero ID means no row was found.
+	if u.ID == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return u, nil
 }
 
 // CreateUser is a method on the Store struct that adds a new user to the database.
-// The method is not yet implemented and currently returns no error.
+// New users always start as unverified customers unless a role is provided.
 func (s *Store) CreateUser(user types.User) error {
-	// Implementation is needed to create a new user in the database.
+	// Default to the customer role, so registration can never create an admin by accident.
+	if user.Role == "" {
+		user.Role = types.RoleCustomer
+	}
+
+	// Insert the new user into the users table.
+	_, err := s.db.Exec(
+		"INSERT INTO users (firstName, lastName, email, password, role, verified) VALUES (?, ?, ?, ?, ?, ?)",
+		user.FirstName, user.LastName, user.Email, user.Password, user.Role, user.Verified,
+	)
+
+	return err
+}
+
+// UpdateUser is a method on the Store struct that saves the role and account flags of an existing user.
+// It returns an error if the user does not exist or the update fails.
+func (s *Store) UpdateUser(user types.User) error {
+	// Update the mutable columns of the user row.
+	res, err := s.db.Exec(
+		"UPDATE users SET firstName = ?, lastName = ?, role = ?, verified = ?, disabled = ?, passwordResetRequired = ? WHERE id = ?",
+		user.FirstName, user.LastName, user.Role, user.Verified, user.Disabled, user.PasswordResetRequired, user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// If no row matched the ID, the user does not exist.
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// MySQL reports 0 affected rows when nothing changed, so double-check the user exists.
+		if _, err := s.GetUserByID(user.ID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// UpdatePassword is a method on the Store struct that replaces the password hash of a user.
+// Setting a new password also clears the passwordResetRequired flag an admin may have set.
+func (s *Store) UpdatePassword(userID int, hashedPassword string) error {
+	_, err := s.db.Exec(
+		"UPDATE users SET password = ?, passwordResetRequired = FALSE WHERE id = ?",
+		hashedPassword, userID,
+	)
+
+	return err
+}
+
+// UpdateCurrency is a method on the Store struct that sets the currency a user prefers prices in.
+func (s *Store) UpdateCurrency(userID int, currency string) error {
+	_, err := s.db.Exec("UPDATE users SET currency = ? WHERE id = ?", currency, userID)
+
+	return err
+}
+
+// userCursor is the decoded form of the opaque pagination cursor returned by ListUsers.
+// It holds the sort value and ID of the last user on the previous page.
+type userCursor struct {
+	SortBy string `json:"s,omitempty"`
+	Value  string `json:"v"`
+	ID     int    `json:"id"`
+}
+
+// ListUsers is a method on the Store struct that searches users using the given filter.
+// It uses keyset pagination on (sort column, id), so pages stay stable while users are added.
+// It returns the users on the page and the cursor for the next page, which is empty on the last page.
+func (s *Store) ListUsers(filter types.UserFilter) ([]*types.User, string, error) {
+	// Resolve the sort column, defaulting to the primary key.
+	if filter.SortBy == "" {
+		filter.SortBy = types.UserSortByID
+	}
+	column, ok := sortColumns[filter.SortBy]
+	if !ok {
+		return nil, "", fmt.Errorf("invalid sort field %q", filter.SortBy)
+	}
+
+	// Clamp the page size.
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	// Build the WHERE clause from the filter, collecting the query arguments along the way.
+	where := []string{"1 = 1"}
+	args := []any{}
+
+	if filter.EmailPrefix != "" {
+		where = append(where, "email LIKE ?")
+		args = append(args, escapeLike(filter.EmailPrefix)+"%")
+	}
+	if filter.Name != "" {
+		where = append(where, "(firstName LIKE ? OR lastName LIKE ?)")
+		pattern := "%" + escapeLike(filter.Name) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if filter.CreatedFrom != nil {
+		where = append(where, "createdAt >= ?")
+		args = append(args, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		where = append(where, "createdAt < ?")
+		args = append(args, *filter.CreatedTo)
+	}
+	if filter.Role != "" {
+		where = append(where, "role = ?")
+		args = append(args, filter.Role)
+	}
+	if filter.Verified != nil {
+		where = append(where, "verified = ?")
+		args = append(args, *filter.Verified)
+	}
+
+	// Pick the comparison and direction used for keyset pagination.
+	cmp, dir := ">", "ASC"
+	if filter.SortDesc {
+		cmp, dir = "<", "DESC"
+	}
+
+	// Continue after the last row of the previous page, if a cursor was given.
+	if filter.Cursor != "" {
+		c, err := decodeUserCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		// A cursor from a listing sorted on another column would compare the wrong values.
+		if c.SortBy != filter.SortBy {
+			return nil, "", types.ErrInvalidCursor
+		}
+
+		if column == "id" {
+			where = append(where, "id "+cmp+" ?")
+			args = append(args, c.ID)
+		} else {
+			value, err := cursorValueArg(filter.SortBy, c.Value)
+			if err != nil {
+				return nil, "", err
+			}
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, cmp))
+			args = append(args, value, value, c.ID)
+		}
+	}
+
+	// Fetch one extra row to find out whether there is a next page.
+	query := fmt.Sprintf(
+		"SELECT %s FROM users WHERE %s ORDER BY %s %s, id %s LIMIT ?",
+		userColumns, strings.Join(where, " AND "), column, dir, dir,
+	)
+	args = append(args, limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	users := make([]*types.User, 0, limit)
+	for rows.Next() {
+		u, err := scanRowIntoUser(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// If there are no more rows than requested, this is the last page.
+	if len(users) <= limit {
+		return users, "", nil
+	}
+
+	// Otherwise drop the extra row and build the cursor from the last user on the page.
+	users = users[:limit]
+	next, err := encodeUserCursor(filter.SortBy, users[len(users)-1])
+	if err != nil {
+		return nil, "", err
+	}
+
+	return users, next, nil
+}
+
+// encodeUserCursor builds the opaque cursor pointing just after the given user.
+func encodeUserCursor(sortBy string, u *types.User) (string, error) {
+	c := userCursor{SortBy: sortBy, ID: u.ID}
+
+	// Store the value of the sort column so the next page can continue after it.
+	switch sortBy {
+	case types.UserSortByCreatedAt:
+		c.Value = u.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case types.UserSortByEmail:
+		c.Value = u.Email
+	case types.UserSortByLastName:
+		c.Value = u.LastName
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeUserCursor parses a cursor produced by encodeUserCursor.
+func decodeUserCursor(cursor string) (*userCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, types.ErrInvalidCursor
+	}
+
+	c := new(userCursor)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, types.ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// cursorValueArg converts the sort value stored in a cursor back into a query argument.
+func cursorValueArg(sortBy, value string) (any, error) {
+	if sortBy == types.UserSortByCreatedAt {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, types.ErrInvalidCursor
+		}
+		return t, nil
+	}
+
+	return value, nil
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern, so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// apiKeyColumns lists the columns selected for an API key, in the order scanRowIntoAPIKey expects them.
+const apiKeyColumns = "id, userId, name, prefix, secretHash, scopes, expiresAt, lastUsedAt, revokedAt, createdAt"
+
+// CreateAPIKey is a method on the Store struct that adds a new API key to the database and returns its ID.
+// Scopes are stored as a comma-separated list.
+func (s *Store) CreateAPIKey(key types.APIKey) (int, error) {
+	res, err := s.db.Exec(
+		"INSERT INTO api_keys (userId, name, prefix, secretHash, scopes, expiresAt) VALUES (?, ?, ?, ?, ?, ?)",
+		key.UserID, key.Name, key.Prefix, key.SecretHash, strings.Join(key.Scopes, ","), key.ExpiresAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetAPIKeyByPrefix is a method on the Store struct that retrieves an API key by its visible prefix.
+// It returns an error if no key has that prefix.
+func (s *Store) GetAPIKeyByPrefix(prefix string) (*types.APIKey, error) {
+	rows, err := s.db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = ?", prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// The prefix is unique, so there is at most one row.
+	if !rows.Next() {
+		return nil, fmt.Errorf("API key not found")
+	}
+
+	return scanRowIntoAPIKey(rows)
+}
+
+// GetAPIKeysByUserID is a method on the Store struct that retrieves all API keys of a user, newest first.
+func (s *Store) GetAPIKeysByUserID(userID int) ([]*types.APIKey, error) {
+	rows, err := s.db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE userId = ? ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*types.APIKey{}
+	for rows.Next() {
+		k, err := scanRowIntoAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey is a method on the Store struct that revokes an API key belonging to the given user.
+// It returns an error if the user has no active key with that ID.
+func (s *Store) RevokeAPIKey(userID, keyID int) error {
+	res, err := s.db.Exec(
+		"UPDATE api_keys SET revokedAt = NOW() WHERE id = ? AND userId = ? AND revokedAt IS NULL",
+		keyID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("API key not found")
+	}
+
+	return nil
+}
+
+// TouchAPIKey is a method on the Store struct that sets the last used time of an API key to now.
+func (s *Store) TouchAPIKey(keyID int) error {
+	_, err := s.db.Exec("UPDATE api_keys SET lastUsedAt = NOW() WHERE id = ?", keyID)
+	return err
+}
+
+// scanRowIntoAPIKey is a helper function that scans a row from the result set into an APIKey object.
+func scanRowIntoAPIKey(rows *sql.Rows) (*types.APIKey, error) {
+	key := new(types.APIKey)
+
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := rows.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.SecretHash,
+		&scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Split the comma-separated scopes, leaving an empty list for unrestricted keys.
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+
+	// Convert the nullable timestamps to pointers.
+	key.ExpiresAt = nullTimePtr(expiresAt)
+	key.LastUsedAt = nullTimePtr(lastUsedAt)
+	key.RevokedAt = nullTimePtr(revokedAt)
+
+	return key, nil
+}
+
+// nullTimePtr converts a nullable timestamp to a pointer, which is nil for NULL.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	// Import the bytes, crypto and encoding packages for building and signing the request body.
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	// Import the types package for the Notifier interface and Event type.
+	"github.com/FreekAlberti/Ecom/cmd/types"
+)
+
+// Headers sent with every webhook request.
+const (
+	EventHeader     = "X-Webhook-Event"     // The type of the event.
+	SignatureHeader = "X-Webhook-Signature" // "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">".
+)
+
+// Sender struct delivers events to outgoing webhooks, as signed JSON POST requests.
+type Sender struct {
+	urls     []string      // The endpoints every event is sent to.
+	secret   []byte        // The secret the requests are signed with.
+	client   *http.Client  // The HTTP client used to send the requests.
+	attempts int           // How many times delivery to an endpoint is tried.
+	backoff  time.Duration // How long to wait after the first failed attempt; doubled after each one.
+}
+
+// NewSender is a constructor function that returns a new Sender for the given endpoints.
+// A Sender without endpoints accepts every event and does nothing.
+func NewSender(urls []string, secret string) *Sender {
+	return &Sender{
+		urls:     urls,
+		secret:   []byte(secret),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		attempts: 3,
+		backoff:  time.Second,
+	}
+}
+
+// Notify is a method on the Sender struct that sends the event to every endpoint, retrying failed deliveries.
+// It returns the errors of the endpoints that could not be reached after all attempts.
+func (s *Sender) Notify(event types.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, url := range s.urls {
+		if err := s.deliver(url, event.Type, body); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", url, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deliver sends the body to an endpoint until it answers with a 2xx status code or the attempts run out.
+func (s *Sender) deliver(url, eventType string, body []byte) error {
+	var err error
+	wait := s.backoff
+
+	for attempt := 1; attempt <= s.attempts; attempt++ {
+		if err = s.post(url, eventType, body); err == nil {
+			return nil
+		}
+		if attempt < s.attempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+
+	return err
+}
+
+// post sends a single signed request.
+func (s *Sender) post(url, eventType string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(SignatureHeader, Sign(s.secret, time.Now(), body))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the value of the signature header for a body sent at the given time.
+// The timestamp is signed along with the body, so receivers can reject replayed requests.
+func Sign(secret []byte, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"io"                // Import the io package to read request bodies
+	"net/http"          // Import the net/http package for HTTP client and server implementations
+	"net/http/httptest" // Import the httptest package to run a receiving endpoint
+	"testing"           // Import the testing package to write test cases
+	"time"              // Import the time package for event timestamps
+
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import the custom types package for the Event type
+)
+
+// TestSender tests that events are delivered signed, and retried when the endpoint fails.
+func TestSender(t *testing.T) {
+	calls := 0
+	var signature, eventType string
+	var body []byte
+
+	// The endpoint fails the first request and accepts the second.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		signature, eventType = r.Header.Get(SignatureHeader), r.Header.Get(EventHeader)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	sender := NewSender([]string{server.URL}, "secret")
+	sender.backoff = time.Millisecond
+
+	at := time.Now()
+	if err := sender.Notify(types.Event{ID: "1", Type: types.EventLowStock, CreatedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+	if eventType != types.EventLowStock {
+		t.Errorf("expected event header %q, got %q", types.EventLowStock, eventType)
+	}
+	if signature != Sign([]byte("secret"), at, body) {
+		t.Errorf("expected the body to be signed with the secret, got %q", signature)
+	}
+}
+
+// TestSenderGivesUp tests that a failing endpoint is reported after the last attempt.
+func TestSenderGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sender := NewSender([]string{server.URL}, "secret")
+	sender.backoff = time.Millisecond
+
+	if err := sender.Notify(types.Event{ID: "1", Type: types.EventLowStock}); err == nil {
+		t.Error("expected an error for an endpoint that keeps failing")
+	}
+}
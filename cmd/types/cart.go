@@ -0,0 +1,60 @@
+package types
+
+import "errors"
+
+// ErrUnknownSKU is returned when pricing a SKU that is neither a product nor a variant.
+var ErrUnknownSKU = errors.New("unknown SKU")
+
+// CartPricer is an interface that defines the contract for pricing the items of a cart, with its promotions.
+type CartPricer interface {
+	// PriceCart prices the items for a user, 0 for guests, in a currency, applying the automatic promotions
+	// and those of the coupon codes. It returns ErrUnknownSKU for items that are not in the catalog.
+	PriceCart(userID int, currency string, items []OrderLine, codes []string) (*CartPricing, error)
+}
+
+// Cart struct represents the items a customer is about to order, with their prices, as promotions see them.
+type Cart struct {
+	UserID   int        // ID of the customer, 0 for guests.
+	Currency string     // Currency of the prices.
+	Lines    []CartLine // The items.
+	Codes    []string   // Coupon codes entered by the customer, in upper case.
+}
+
+// CartLine struct represents a quantity of a SKU in a cart.
+type CartLine struct {
+	SKU        string // Stock keeping unit of the product or variant.
+	ProductID  int    // ID of the product, or of the product of the variant.
+	CategoryID *int   // ID of the category of the product, nil if it has none.
+	TaxClass   string // Tax class of the product.
+	Weight     int    // Weight of one unit in grams.
+	Quantity   int    // Quantity ordered.
+	UnitPrice  Money  // Price of one unit, in the currency of the cart.
+}
+
+// CartPricing struct holds the prices of a cart and the promotions applied to it.
+// Discounts are spread over the lines, so every line knows what it was sold for.
+type CartPricing struct {
+	Currency      string             `json:"currency"`      // Currency of all amounts.
+	Lines         []PricedLine       `json:"lines"`         // The lines, in the order of the cart.
+	Promotions    []AppliedPromotion `json:"promotions"`    // The promotions applied, in the order they were applied.
+	RejectedCodes []RejectedCode     `json:"rejectedCodes"` // Coupon codes that could not be applied, and why.
+	Subtotal      Money              `json:"subtotal"`      // Sum of the lines before discounts.
+	Discount      Money              `json:"discount"`      // Sum of the discounts.
+	Total         Money              `json:"total"`         // Subtotal minus discount, until shipping and tax are worked out; then what the customer pays with them.
+	FreeShipping  bool               `json:"freeShipping"`  // Whether a promotion makes shipping free.
+	Shipping      *ShippingOption    `json:"shipping"`      // The shipping method chosen, with its price; nil if none was.
+	Tax           *TaxResult         `json:"tax"`           // The tax on the lines, nil until it is worked out.
+}
+
+// PricedLine struct represents a line of a priced cart.
+type PricedLine struct {
+	SKU       string `json:"sku"`       // Stock keeping unit.
+	ProductID int    `json:"productId"` // ID of the product.
+	TaxClass  string `json:"taxClass"`  // Tax class of the product.
+	Weight    int    `json:"weight"`    // Weight of the line in grams: the weight of one unit times quantity.
+	Quantity  int    `json:"quantity"`  // Quantity ordered.
+	UnitPrice Money  `json:"unitPrice"` // Price of one unit.
+	Subtotal  Money  `json:"subtotal"`  // Unit price times quantity.
+	Discount  Money  `json:"discount"`  // The part of the discounts given on this line.
+	Total     Money  `json:"total"`     // Subtotal minus discount.
+}
@@ -0,0 +1,20 @@
+package types
+
+// ImportReport struct is the outcome of a bulk catalog import: how many rows were applied, and what was wrong
+// with the rows that were not. In a dry run nothing is written, and the counts say what the import would do.
+type ImportReport struct {
+	DryRun    bool          `json:"dryRun"`    // Whether the import only checked the file.
+	Rows      int           `json:"rows"`      // Number of rows read.
+	Created   int           `json:"created"`   // Number of products and variants created.
+	Updated   int           `json:"updated"`   // Number of products and variants updated.
+	Failed    int           `json:"failed"`    // Number of rows rejected.
+	Errors    []ImportError `json:"errors"`    // What was wrong with the rejected rows, for the first of them.
+	Truncated bool          `json:"truncated"` // Whether there were more rejected rows than Errors lists.
+}
+
+// ImportError struct describes a row rejected by a bulk catalog import.
+type ImportError struct {
+	Line    int    `json:"line"`    // Line of the row in the file.
+	SKU     string `json:"sku"`     // SKU of the row, if it has one.
+	Message string `json:"message"` // What is wrong with the row.
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// CurrencyHeader is the request header selecting the currency prices are shown in, such as "X-Currency: USD".
+const CurrencyHeader = "X-Currency"
+
+// Errors returned by exchange rates.
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")   // The currency has no exchange rate.
+	ErrInvalidRates        = errors.New("invalid exchange rates") // A rate table that cannot replace the rates.
+)
+
+// ExchangeRates is an interface that defines the contract for the exchange rates between the base currency,
+// which product prices are stored in, and the other currencies prices can be shown in.
+type ExchangeRates interface {
+	// Base returns the base currency.
+	Base() string
+
+	// Rates returns the current exchange rates.
+	Rates() RateTable
+
+	// UpdateRates replaces the exchange rates, and keeps them for the next start.
+	// It returns ErrInvalidRates for tables of another base currency, or with unknown currencies or invalid rates.
+	UpdateRates(RateTable) error
+
+	// Convert converts an amount to another currency, rounding half up to its minor unit.
+	// It returns ErrUnsupportedCurrency if either currency has no rate.
+	Convert(m Money, currency string) (Money, error)
+}
+
+// RateTable struct holds the exchange rates of the base currency. Rates are decimal strings, so they are exact.
+type RateTable struct {
+	Base      string            `json:"base"`      // The base currency.
+	Rates     map[string]string `json:"rates"`     // Units of each currency one unit of the base currency buys, such as {"USD": "1.0842"}.
+	UpdatedAt time.Time         `json:"updatedAt"` // Timestamp when the rates were last updated.
+}
+
+// PriceListStore is an interface that defines the contract for the prices of products set in currencies other
+// than the base currency. Products without a price in a currency sell at the converted base price.
+type PriceListStore interface {
+	// GetPriceList retrieves the prices set for a product, ordered by currency.
+	GetPriceList(productID int) ([]Money, error)
+
+	// GetPrices retrieves the prices set in a currency for the products with the given IDs, by product ID.
+	// Products without a price in the currency are left out.
+	GetPrices(productIDs []int, currency string) (map[int]Money, error)
+
+	// SetPrice sets the price of a product in the currency of the price, replacing any price set before.
+	SetPrice(productID int, price Money) error
+
+	// DeletePrice removes the price of a product in a currency.
+	DeletePrice(productID int, currency string) error
+}
+
+// Pricer is an interface that defines the contract for showing prices in the currency a customer selected.
+type Pricer interface {
+	// Base returns the base currency, which product prices are stored and set in.
+	Base() string
+
+	// Currencies returns the currencies prices can be shown in, the base currency first.
+	Currencies() []string
+
+	// SelectCurrency returns the currency to show prices in: the requested one if there is one, else the
+	// preferred currency of the user if there is one and it is supported, else the base currency.
+	// The user is nil for guests. It returns ErrUnsupportedCurrency for requested currencies without a rate.
+	SelectCurrency(requested string, user *User) (string, error)
+
+	// PriceProducts sets the prices of the products in a currency: the price set for it, or else the
+	// converted base price.
+	PriceProducts(products []*Product, currency string) error
+
+	// PriceVariants converts the prices of the variants of a product to a currency.
+	PriceVariants(variants []*ProductVariant, currency string) error
+
+	// Convert converts an amount to another currency, such as a price filter to the base currency.
+	Convert(m Money, currency string) (Money, error)
+}
+
+// CurrencyPayload struct is used to capture and validate the preferred currency of a user.
+type CurrencyPayload struct {
+	Currency string `json:"currency" validate:"omitempty,len=3"` // Currency is an ISO 4217 code, or empty for no preference.
+}
+
+// PricePayload struct is used to capture and validate the price of a product in a currency.
+type PricePayload struct {
+	Amount string `json:"amount" validate:"required,max=32"` // Amount is required, as a decimal string such as "19.99".
+}
@@ -0,0 +1,23 @@
+package types
+
+import "time"
+
+// Notifier is an interface that defines the contract for telling other systems about events,
+// for example through outgoing webhooks.
+type Notifier interface {
+	// Notify delivers an event, returning an error if it could not be delivered.
+	Notify(Event) error
+}
+
+// Types of event.
+const (
+	EventLowStock = "inventory.low_stock" // The quantity available to sell of a SKU dropped to its reorder threshold.
+)
+
+// Event struct represents something that happened, as sent to other systems.
+type Event struct {
+	ID        string    `json:"id"`        // Unique identifier for the event, so receivers can ignore duplicates.
+	Type      string    `json:"type"`      // One of the Event constants.
+	CreatedAt time.Time `json:"createdAt"` // Timestamp when the event happened.
+	Data      any       `json:"data"`      // Details of the event, depending on its type.
+}
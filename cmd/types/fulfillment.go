@@ -0,0 +1,68 @@
+package types
+
+// FulfillmentStrategy is an interface that defines the contract for choosing the warehouses an order is
+// fulfilled from. Implementations must be deterministic: the same request always gives the same plan.
+type FulfillmentStrategy interface {
+	// Name returns the name the strategy is configured by.
+	Name() string
+
+	// Plan assigns the lines of the request to warehouses. Quantities that no warehouse can supply are
+	// reported in the plan as unfulfilled rather than as an error.
+	Plan(FulfillmentRequest) (*FulfillmentPlan, error)
+}
+
+// OrderRouter is an interface that defines the contract for choosing the warehouse each line of an order ships from.
+type OrderRouter interface {
+	// Route returns the ID of the warehouse each line ships from, in the order of the lines. A line ships whole
+	// from one warehouse, since its stock is held by a single reservation; its ID is 0 if no warehouse has it in
+	// stock. The destination is nil if it is unknown.
+	Route(destination *Location, lines []OrderLine) ([]int, error)
+}
+
+// Location struct represents a point on earth, in decimal degrees.
+type Location struct {
+	Latitude  float64 `json:"latitude" validate:"min=-90,max=90"`    // Latitude, from -90 to 90.
+	Longitude float64 `json:"longitude" validate:"min=-180,max=180"` // Longitude, from -180 to 180.
+}
+
+// OrderLine struct represents a quantity of a SKU to fulfill.
+type OrderLine struct {
+	SKU      string `json:"sku" validate:"required,max=64"`              // Stock keeping unit.
+	Quantity int    `json:"quantity" validate:"required,gt=0,max=10000"` // Quantity ordered, bounded so a cart cannot cost unbounded work.
+}
+
+// FulfillmentCandidate struct represents a warehouse that can fulfill an order, with its stock of the ordered SKUs.
+type FulfillmentCandidate struct {
+	Warehouse Warehouse      // The warehouse.
+	Stock     map[string]int // On-hand quantity by SKU.
+}
+
+// FulfillmentRequest struct holds everything a strategy needs to plan the fulfillment of an order.
+type FulfillmentRequest struct {
+	Destination Location               // Where the order is shipped to.
+	Lines       []OrderLine            // The ordered lines.
+	Candidates  []FulfillmentCandidate // The active warehouses, with their stock.
+}
+
+// Allocation struct represents a quantity of an order line shipped from a warehouse.
+type Allocation struct {
+	Line        int    `json:"line"`        // Index of the order line.
+	SKU         string `json:"sku"`         // Stock keeping unit.
+	WarehouseID int    `json:"warehouseId"` // ID of the warehouse the quantity ships from.
+	Quantity    int    `json:"quantity"`    // Quantity shipped from the warehouse.
+}
+
+// FulfillmentPlan struct represents the result of routing an order.
+type FulfillmentPlan struct {
+	Strategy    string       `json:"strategy"`    // Name of the strategy that made the plan.
+	Allocations []Allocation `json:"allocations"` // Allocations, ordered by line and then by warehouse choice.
+	Shipments   int          `json:"shipments"`   // Number of distinct warehouses the order ships from.
+	Unfulfilled []OrderLine  `json:"unfulfilled"` // Quantities no warehouse has in stock.
+}
+
+// FulfillmentPlanPayload struct is used to capture and validate a request to preview the routing of an order.
+type FulfillmentPlanPayload struct {
+	Destination Location    `json:"destination" validate:"required"`      // Destination is required.
+	Lines       []OrderLine `json:"lines" validate:"required,min=1,dive"` // At least one line is required.
+	Strategy    string      `json:"strategy"`                             // Strategy to use instead of the configured one.
+}
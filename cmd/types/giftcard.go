@@ -0,0 +1,121 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors returned by the BalanceStore.
+var (
+	ErrInvalidGiftCard     = errors.New("gift card cannot be used") // The code is unknown, expired or in another currency than the order.
+	ErrInsufficientBalance = errors.New("insufficient balance")     // Taking the amount would make a balance negative.
+)
+
+// BalanceStore is an interface that defines the contract for gift cards and the store credit of users.
+// Balances are the sum of an append-only ledger: entries are never removed and their amounts never change,
+// mistakes are corrected by new entries.
+type BalanceStore interface {
+	// CreateGiftCard issues a gift card, with an entry for its initial value, and returns its ID. The code is
+	// only stored as a hash.
+	CreateGiftCard(card GiftCard, code string) (int, error)
+
+	// GetGiftCards retrieves all gift cards with their balances, newest first.
+	GetGiftCards() ([]*GiftCard, error)
+
+	// GetGiftCardByID retrieves a gift card with its balance by ID.
+	GetGiftCardByID(id int) (*GiftCard, error)
+
+	// GetGiftCardByCode retrieves a gift card with its balance by its code, ignoring case and the dashes between groups.
+	GetGiftCardByCode(code string) (*GiftCard, error)
+
+	// GetGiftCardLedger retrieves the entries of a gift card, oldest first.
+	GetGiftCardLedger(giftCardID int) ([]BalanceEntry, error)
+
+	// GetStoreCredit retrieves the store credit balances of a user, one per currency with entries, sorted by
+	// currency.
+	GetStoreCredit(userID int) ([]Money, error)
+
+	// GetStoreCreditLedger retrieves the store credit entries of a user, oldest first.
+	GetStoreCreditLedger(userID int) ([]BalanceEntry, error)
+
+	// AddStoreCredit appends an entry to the store credit of a user and returns its ID. A negative amount takes
+	// credit away, but never below zero: ErrInsufficientBalance is returned instead.
+	AddStoreCredit(entry BalanceEntry) (int, error)
+
+	// ChargeBalances pays as much of the amount due as the gift cards of the codes, in the order given, and then
+	// the store credit of the user cover. The gift cards and the user are locked while their balances are read,
+	// so concurrent checkouts cannot spend a balance twice. It returns the charges made and the IDs of their
+	// entries, or ErrInvalidGiftCard with nothing charged.
+	ChargeBalances(userID int, due Money, codes []string, storeCredit bool) ([]BalanceCharge, []int, error)
+
+	// SetEntriesOrder links entries to the order they were made for.
+	SetEntriesOrder(entryIDs []int, orderID int) error
+
+	// ReverseEntries appends entries that cancel out the given ones, for a checkout that failed.
+	ReverseEntries(entryIDs []int) error
+}
+
+// Reasons of balance entries.
+const (
+	EntryIssue      = "issue"      // A gift card was issued.
+	EntryRedemption = "redemption" // Part of an order was paid.
+	EntryReversal   = "reversal"   // An entry was cancelled out.
+	EntryRefund     = "refund"     // An order was refunded to store credit.
+	EntryGoodwill   = "goodwill"   // Credit given to a customer as a gesture.
+	EntryAdjustment = "adjustment" // A correction by an admin.
+)
+
+// GiftCard struct represents a gift card. Its code is only known when it is issued.
+type GiftCard struct {
+	ID        int        `json:"id"`        // Unique identifier for the gift card.
+	Last4     string     `json:"last4"`     // Last four characters of the code, to recognize the card.
+	Value     Money      `json:"value"`     // Value the gift card was issued with.
+	Balance   Money      `json:"balance"`   // What is left to spend.
+	Note      string     `json:"note"`      // Note for admins, such as who it was sold to.
+	ExpiresAt *time.Time `json:"expiresAt"` // When the gift card expires, nil if it never does.
+	CreatedAt time.Time  `json:"createdAt"` // Timestamp when the gift card was issued.
+}
+
+// Expired returns whether the gift card has expired at the given time.
+func (g GiftCard) Expired(at time.Time) bool {
+	return g.ExpiresAt != nil && !at.Before(*g.ExpiresAt)
+}
+
+// BalanceEntry struct represents an entry in the ledger of a gift card or of the store credit of a user.
+type BalanceEntry struct {
+	ID         int       `json:"id"`         // Unique identifier for the entry.
+	GiftCardID *int      `json:"giftCardId"` // ID of the gift card, nil for store credit.
+	UserID     *int      `json:"userId"`     // ID of the user whose store credit it is, or who spent the gift card.
+	OrderID    *int      `json:"orderId"`    // ID of the order it was made for, if any.
+	Amount     Money     `json:"amount"`     // Amount added, negative when spent.
+	Reason     string    `json:"reason"`     // One of the Entry constants.
+	Note       string    `json:"note"`       // Note for admins.
+	CreatedAt  time.Time `json:"createdAt"`  // Timestamp when the entry was made.
+}
+
+// BalanceCharge struct represents a part of an order paid with a gift card or store credit.
+type BalanceCharge struct {
+	GiftCardID int    `json:"giftCardId"` // ID of the gift card, 0 for store credit.
+	Last4      string `json:"last4"`      // Last four characters of the gift card code.
+	Amount     Money  `json:"amount"`     // Amount paid.
+}
+
+// GiftCardPayload struct is used to capture and validate a gift card to issue.
+type GiftCardPayload struct {
+	Value     Money      `json:"value" validate:"required"` // Value is required.
+	Note      string     `json:"note" validate:"max=255"`   // Note is optional.
+	ExpiresAt *time.Time `json:"expiresAt"`                 // ExpiresAt is optional, and in the future.
+}
+
+// GiftCardCodePayload struct is used to capture the code of a gift card whose balance is looked up.
+type GiftCardCodePayload struct {
+	Code string `json:"code" validate:"required,max=32"` // Code is required.
+}
+
+// StoreCreditPayload struct is used to capture and validate an entry to the store credit of a user.
+type StoreCreditPayload struct {
+	Amount  Money  `json:"amount" validate:"required"`                                  // Amount is required, and only negative for adjustments.
+	Reason  string `json:"reason" validate:"required,oneof=refund goodwill adjustment"` // Reason is required.
+	OrderID *int   `json:"orderId"`                                                     // OrderID is required for refunds.
+	Note    string `json:"note" validate:"max=255"`                                     // Note is optional.
+}
@@ -0,0 +1,208 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInsufficientStock is returned when a reservation or movement needs more stock than is available.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrReservationExpired is returned when committing a reservation whose hold has run out, since its stock may
+// already have been sold again.
+var ErrReservationExpired = errors.New("reservation expired")
+
+// InventoryStore is an interface that defines the contract for the inventory subsystem.
+// Stock is tracked per SKU and warehouse. Every change goes through the append-only stock movement ledger,
+// and reservations hold stock for checkouts in progress.
+type InventoryStore interface {
+	// CreateWarehouse adds a new warehouse and returns its ID.
+	CreateWarehouse(Warehouse) (int, error)
+
+	// GetWarehouses retrieves all warehouses.
+	GetWarehouses() ([]*Warehouse, error)
+
+	// GetStockLevels retrieves the on-hand quantity of a SKU in every warehouse that stocks it.
+	GetStockLevels(sku string) ([]*StockLevel, error)
+
+	// GetMovements retrieves the most recent ledger entries of a SKU, newest first.
+	GetMovements(sku string, limit int) ([]*StockMovement, error)
+
+	// RecordMovement appends a movement to the ledger and updates the stock level of its warehouse.
+	// It returns ErrInsufficientStock if the movement would make the on-hand quantity negative.
+	RecordMovement(StockMovement) error
+
+	// Transfer moves stock of a SKU between two warehouses, recording a movement in each.
+	Transfer(StockTransfer) error
+
+	// AvailableToSell returns the quantity of a SKU that can still be sold:
+	// the stock on hand in all active warehouses minus the quantity held by active reservations.
+	AvailableToSell(sku string) (int, error)
+
+	// CreateReservation holds stock for a checkout until it expires, and returns its ID.
+	// It returns ErrInsufficientStock if there is not enough available to sell.
+	CreateReservation(Reservation) (int, error)
+
+	// GetReservation retrieves a reservation by ID.
+	GetReservation(id int) (*Reservation, error)
+
+	// ReleaseReservation gives the stock held by an active reservation back, for example when payment fails.
+	ReleaseReservation(id int) error
+
+	// CommitReservation turns an active reservation into a sale from the given warehouse, once payment succeeds.
+	// It returns ErrReservationExpired if the reservation expired, even if the sweeper has not marked it yet.
+	CommitReservation(id, warehouseID int) error
+
+	// ExpireReservations marks the active reservations that expired before the given time as expired,
+	// and returns how many there were.
+	ExpireReservations(before time.Time) (int, error)
+}
+
+// Types of stock movement.
+const (
+	MovementReceipt    = "receipt"    // Stock received from a supplier.
+	MovementSale       = "sale"       // Stock sold to a customer.
+	MovementReturn     = "return"     // Stock returned by a customer.
+	MovementAdjustment = "adjustment" // Manual correction, e.g. after a stock count.
+	MovementTransfer   = "transfer"   // Stock moved between warehouses.
+)
+
+// Statuses of a reservation.
+const (
+	ReservationActive    = "active"    // Holding stock.
+	ReservationReleased  = "released"  // Given back, e.g. after a payment failure.
+	ReservationCommitted = "committed" // Turned into a sale.
+	ReservationExpired   = "expired"   // Given back because the checkout was not completed in time.
+)
+
+// Warehouse struct represents a stock location.
+type Warehouse struct {
+	ID        int       `json:"id"`        // Unique identifier for the warehouse.
+	Code      string    `json:"code"`      // Short unique code, e.g. "AMS1".
+	Name      string    `json:"name"`      // Human readable name.
+	Active    bool      `json:"active"`    // Whether stock in this warehouse can be sold.
+	Priority  int       `json:"priority"`  // Fulfillment priority, lower is preferred.
+	Location  Location  `json:"location"`  // Where the warehouse is, used to ship from the nearest one.
+	CreatedAt time.Time `json:"createdAt"` // Timestamp when the warehouse was created.
+}
+
+// StockLevel struct represents the on-hand quantity of a SKU in a warehouse.
+type StockLevel struct {
+	WarehouseID int       `json:"warehouseId"` // ID of the warehouse.
+	SKU         string    `json:"sku"`         // Stock keeping unit.
+	OnHand      int       `json:"onHand"`      // Quantity physically in the warehouse.
+	UpdatedAt   time.Time `json:"updatedAt"`   // Timestamp of the last movement.
+}
+
+// StockMovement struct represents an entry in the append-only stock ledger.
+// The quantity is signed: positive movements add stock to the warehouse, negative ones remove it.
+type StockMovement struct {
+	ID          int       `json:"id"`          // Unique identifier for the entry.
+	WarehouseID int       `json:"warehouseId"` // ID of the warehouse whose stock changed.
+	SKU         string    `json:"sku"`         // Stock keeping unit.
+	Type        string    `json:"type"`        // One of the Movement constants.
+	Quantity    int       `json:"quantity"`    // Signed change in on-hand quantity.
+	Reference   string    `json:"reference"`   // Free-form reference, e.g. a purchase order or reservation.
+	ActorID     int       `json:"actorId"`     // ID of the user who recorded the movement, 0 for the system.
+	CreatedAt   time.Time `json:"createdAt"`   // Timestamp when the movement was recorded.
+}
+
+// StockTransfer struct describes stock moved between two warehouses.
+type StockTransfer struct {
+	FromWarehouseID int    // ID of the warehouse the stock leaves.
+	ToWarehouseID   int    // ID of the warehouse the stock arrives at.
+	SKU             string // Stock keeping unit.
+	Quantity        int    // Quantity moved, always positive.
+	Reference       string // Free-form reference.
+	ActorID         int    // ID of the user who recorded the transfer.
+}
+
+// Reservation struct represents stock held for a checkout in progress.
+type Reservation struct {
+	ID        int       `json:"id"`        // Unique identifier for the reservation.
+	SKU       string    `json:"sku"`       // Stock keeping unit.
+	Quantity  int       `json:"quantity"`  // Quantity held.
+	Reference string    `json:"reference"` // What the stock is held for, e.g. a checkout ID.
+	Status    string    `json:"status"`    // One of the Reservation constants.
+	ExpiresAt time.Time `json:"expiresAt"` // Time at which the stock is given back if not committed.
+	CreatedAt time.Time `json:"createdAt"` // Timestamp when the reservation was created.
+}
+
+// CreateWarehousePayload struct is used to capture and validate a request to create a warehouse.
+type CreateWarehousePayload struct {
+	Code     string   `json:"code" validate:"required,max=32"`  // Code is required.
+	Name     string   `json:"name" validate:"required,max=255"` // Name is required.
+	Priority int      `json:"priority" validate:"min=0"`        // Priority is optional, lower is preferred.
+	Location Location `json:"location"`                         // Location is optional.
+}
+
+// RecordMovementPayload struct is used to capture and validate a stock movement recorded by an admin.
+// Transfers have their own payload, since they always involve two warehouses.
+type RecordMovementPayload struct {
+	WarehouseID int    `json:"warehouseId" validate:"required"`                               // Warehouse is required.
+	SKU         string `json:"sku" validate:"required,max=64"`                                // SKU is required.
+	Type        string `json:"type" validate:"required,oneof=receipt sale return adjustment"` // Type is required.
+	Quantity    int    `json:"quantity" validate:"required"`                                  // Signed quantity, never zero.
+	Reference   string `json:"reference" validate:"max=255"`                                  // Reference is optional.
+}
+
+// TransferPayload struct is used to capture and validate a stock transfer between warehouses.
+type TransferPayload struct {
+	FromWarehouseID int    `json:"fromWarehouseId" validate:"required"`                       // Source is required.
+	ToWarehouseID   int    `json:"toWarehouseId" validate:"required,nefield=FromWarehouseID"` // Destination must differ.
+	SKU             string `json:"sku" validate:"required,max=64"`                            // SKU is required.
+	Quantity        int    `json:"quantity" validate:"required,gt=0"`                         // Quantity must be positive.
+	Reference       string `json:"reference" validate:"max=255"`                              // Reference is optional.
+}
+
+// ErrBackorderLimit is returned when accepting a backorder or preorder would exceed the limit of its policy.
+var ErrBackorderLimit = errors.New("backorder limit reached")
+
+// BackorderPolicyStore is an interface that defines the contract for the per-SKU backorder and preorder policies.
+type BackorderPolicyStore interface {
+	// GetBackorderPolicy retrieves the policy of a SKU, or nil if it has none.
+	GetBackorderPolicy(sku string) (*BackorderPolicy, error)
+
+	// SaveBackorderPolicy creates or replaces the policy of a SKU, keeping its waiting quantity.
+	SaveBackorderPolicy(BackorderPolicy) error
+
+	// DeleteBackorderPolicy removes the policy of a SKU. Orders already accepted are not affected.
+	DeleteBackorderPolicy(sku string) error
+
+	// AcceptBackorder adds to the quantity waiting for stock under the policy of a SKU.
+	// It returns ErrBackorderLimit if the SKU has no policy or the limit would be exceeded.
+	AcceptBackorder(sku string, quantity int) error
+
+	// ReleaseBackorder subtracts from the quantity waiting for stock, once it has been allocated or cancelled.
+	ReleaseBackorder(sku string, quantity int) error
+}
+
+// StockAllocator is an interface that defines the contract for giving newly received stock to the orders
+// waiting for it.
+type StockAllocator interface {
+	// AllocateStock allocates the stock available to sell of a SKU to the orders waiting for it,
+	// and returns how many order lines were allocated.
+	AllocateStock(sku string) (int, error)
+}
+
+// Backorder policy modes.
+const (
+	PolicyBackorder = "backorder" // The SKU can be ordered when out of stock.
+	PolicyPreorder  = "preorder"  // The SKU is not released yet; every order waits for the release.
+)
+
+// BackorderPolicy struct represents whether and how much of a SKU can be ordered without stock.
+type BackorderPolicy struct {
+	SKU        string     `json:"sku"`        // Stock keeping unit.
+	Mode       string     `json:"mode"`       // One of the Policy constants.
+	Limit      int        `json:"limit"`      // Maximum quantity waiting for stock, 0 for no limit.
+	Waiting    int        `json:"waiting"`    // Quantity ordered and waiting for stock.
+	ExpectedAt *time.Time `json:"expectedAt"` // When stock or the release is expected, nil if unknown.
+}
+
+// BackorderPolicyPayload struct is used to capture and validate the backorder policy of a SKU.
+type BackorderPolicyPayload struct {
+	Mode       string     `json:"mode" validate:"required,oneof=backorder preorder"` // Mode is required.
+	Limit      int        `json:"limit" validate:"min=0"`                            // Limit is optional, 0 for no limit.
+	ExpectedAt *time.Time `json:"expectedAt"`                                        // Expected date is optional.
+}
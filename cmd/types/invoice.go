@@ -0,0 +1,46 @@
+package types
+
+import "time"
+
+// InvoiceStore is an interface that defines the contract for the invoices and credit notes issued for orders.
+// Issued documents never change: there is no way to update or delete them.
+type InvoiceStore interface {
+	// IssueInvoice gives a document the next number of its kind in its fiscal year, renders it and stores it with
+	// its PDF, in a single transaction, so numbers have no gaps. A source is only issued once: when it already has
+	// a document, that one is returned instead and render is not called.
+	IssueInvoice(inv Invoice, render func(Invoice) ([]byte, error)) (*Invoice, error)
+
+	// GetInvoiceBySource retrieves the document issued for a source, such as "order:12", without its PDF.
+	GetInvoiceBySource(source string) (*Invoice, error)
+
+	// GetInvoices retrieves the documents of a fiscal year, without their PDFs, by kind and number.
+	GetInvoices(fiscalYear int) ([]*Invoice, error)
+
+	// GetInvoicePDF retrieves the PDF of a document.
+	GetInvoicePDF(id int) ([]byte, error)
+}
+
+// Kinds of documents.
+const (
+	InvoiceKindInvoice    = "invoice"     // What the customer owes for an order.
+	InvoiceKindCreditNote = "credit_note" // What is paid back of an invoice, such as for a return.
+)
+
+// Invoice struct represents an invoice or credit note issued for an order. The PDF is the legal document; the
+// other fields summarise it.
+type Invoice struct {
+	ID         int       `json:"id"`         // Unique identifier for the document.
+	Kind       string    `json:"kind"`       // One of the InvoiceKind constants.
+	Number     string    `json:"number"`     // Number printed on the document, such as "INV-2024-000001".
+	FiscalYear int       `json:"fiscalYear"` // Fiscal year the document is numbered in.
+	Sequence   int       `json:"sequence"`   // Position of the document in its kind and fiscal year, from 1 without gaps.
+	Source     string    `json:"source"`     // What the document was issued for, such as "order:12" or "return:3".
+	OrderID    int       `json:"orderId"`    // ID of the order.
+	ReturnID   *int      `json:"returnId"`   // ID of the return a credit note pays back, nil for invoices.
+	CorrectsID *int      `json:"correctsId"` // ID of the invoice a credit note corrects, nil for invoices.
+	UserID     int       `json:"userId"`     // ID of the customer.
+	Net        Money     `json:"net"`        // Sum of the lines without tax; negative on credit notes.
+	Tax        Money     `json:"tax"`        // Sum of the tax; negative on credit notes.
+	Total      Money     `json:"total"`      // Net plus tax; negative on credit notes.
+	IssuedAt   time.Time `json:"issuedAt"`   // When the document was issued, which decides its fiscal year.
+}
@@ -0,0 +1,67 @@
+package types
+
+import "time"
+
+// BlobStore is an interface that defines the contract for storing files, such as product images.
+type BlobStore interface {
+	// Put stores the content under the key, replacing any file already stored there.
+	Put(key string, content []byte, contentType string) error
+
+	// Get retrieves the content stored under the key.
+	Get(key string) ([]byte, error)
+
+	// Delete removes the file stored under the key. Deleting a file that does not exist is not an error.
+	Delete(key string) error
+
+	// URL returns the public URL of the file stored under the key.
+	URL(key string) string
+}
+
+// ImageStore is an interface that defines the contract for the image galleries of products.
+// Every product has a gallery, and every variant of a product, identified by its SKU, can have its own.
+type ImageStore interface {
+	// GetImages retrieves the gallery of a product or, when variant is not empty, of one of its variants,
+	// ordered by position.
+	GetImages(productID int, variant string) ([]*ProductImage, error)
+
+	// GetImageByID retrieves an image by ID.
+	GetImageByID(id int) (*ProductImage, error)
+
+	// CreateImage adds an image at the end of its gallery and returns its ID.
+	CreateImage(ProductImage) (int, error)
+
+	// DeleteImage removes an image from its gallery.
+	DeleteImage(id int) error
+
+	// ReorderImages sets the order of a gallery. The IDs must be those of all images in the gallery.
+	ReorderImages(productID int, variant string, ids []int) error
+}
+
+// ProductImage struct represents an image in the gallery of a product or product variant.
+// The uploaded file is kept as is, next to resized WebP versions of it.
+type ProductImage struct {
+	ID          int               `json:"id"`          // Unique identifier for the image.
+	ProductID   int               `json:"productId"`   // ID of the product the image shows.
+	Variant     string            `json:"variant"`     // SKU of the variant the image shows, or empty for the product gallery.
+	Position    int               `json:"position"`    // Position of the image in its gallery, from 0.
+	Alt         string            `json:"alt"`         // Text describing the image, for screen readers.
+	Width       int               `json:"width"`       // Width of the uploaded image, in pixels.
+	Height      int               `json:"height"`      // Height of the uploaded image, in pixels.
+	ContentType string            `json:"contentType"` // MIME type of the uploaded image.
+	Key         string            `json:"-"`           // Prefix of the blob keys of the files of the image.
+	Extension   string            `json:"-"`           // File extension of the uploaded image, such as ".jpg".
+	URLs        map[string]string `json:"urls"`        // Public URLs of the uploaded image and its resized versions, by name.
+	CreatedAt   time.Time         `json:"createdAt"`   // Timestamp when the image was uploaded.
+}
+
+// ImageOrderPayload struct defines the payload for reordering a gallery.
+type ImageOrderPayload struct {
+	Variant  string `json:"variant" validate:"max=64"`    // SKU of the variant whose gallery is reordered, or empty for the product gallery.
+	ImageIDs []int  `json:"imageIds" validate:"required"` // IDs of all images in the gallery, in their new order.
+}
+
+// ImageUploadPayload struct defines the form fields of an image upload, next to the image file itself.
+type ImageUploadPayload struct {
+	Variant string `validate:"max=64"`  // SKU of the variant the image shows, or empty for the product gallery.
+	Alt     string `validate:"max=255"` // Text describing the image.
+}
@@ -0,0 +1,341 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Errors returned by Money operations.
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrMoneyOverflow    = errors.New("amount out of range")
+)
+
+// minorDigits maps the ISO 4217 codes of the supported currencies to the number of digits of their minor unit,
+// such as 2 for the cents of the euro and 0 for the yen.
+var minorDigits = map[string]int{
+	"AED": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CLP": 0, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "ISK": 0, "JOD": 3,
+	"JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PHP": 2, "PLN": 2,
+	"RON": 2, "SAR": 2, "SEK": 2, "SGD": 2, "THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "USD": 2, "VND": 0,
+	"ZAR": 2,
+}
+
+// MinorDigits returns the number of digits of the minor unit of a currency, and whether the currency is supported.
+func MinorDigits(currency string) (int, bool) {
+	digits, ok := minorDigits[currency]
+	return digits, ok
+}
+
+// RoundingMode is how an amount that falls between two minor units is rounded.
+type RoundingMode int
+
+// Rounding modes.
+const (
+	RoundHalfUp   RoundingMode = iota // To the nearest unit, halves away from zero: the usual commercial rounding.
+	RoundHalfEven                     // To the nearest unit, halves to the even one, so many roundings do not add up to a bias.
+	RoundDown                         // Toward zero, dropping the fraction.
+	RoundUp                           // Away from zero.
+)
+
+// Money struct is an amount of money in a currency. The amount is counted in the minor unit of the currency,
+// such as cents, so sums are exact; never use floats for money.
+type Money struct {
+	Amount   int64  // Amount in the minor unit of the currency.
+	Currency string // ISO 4217 code of the currency, such as "EUR".
+}
+
+// NewMoney returns the amount in minor units of the currency, so NewMoney(1999, "EUR") is 19.99 euros.
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount in the major unit of a currency, such as "19.99", exactly.
+// Trailing zeros beyond the minor unit are accepted, as databases return them, but other extra digits are not.
+func ParseMoney(amount, currency string) (Money, error) {
+	digits, ok := MinorDigits(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	if len(fraction) > digits {
+		if strings.Trim(fraction[digits:], "0") != "" {
+			return Money{}, fmt.Errorf("invalid amount %q: %s has %d decimals", amount, currency, digits)
+		}
+		fraction = fraction[:digits]
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+
+	n, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	if negative {
+		n.Neg(n)
+	}
+	if !n.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Amount: n.Int64(), Currency: currency}, nil
+}
+
+// isDigits returns whether s only contains ASCII digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// RoundMoney returns an amount in the major unit of a currency, such as 19.995 euros, rounded to its minor unit.
+func RoundMoney(amount *big.Rat, currency string, mode RoundingMode) (Money, error) {
+	digits, ok := MinorDigits(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+
+	minor := new(big.Rat).Mul(amount, new(big.Rat).SetInt(pow10(digits)))
+	n := roundRat(minor, mode)
+	if !n.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Amount: n.Int64(), Currency: currency}, nil
+}
+
+// roundRat rounds r to an integer.
+func roundRat(r *big.Rat, mode RoundingMode) *big.Int {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return q
+	}
+
+	// The quotient is truncated toward zero; away is the neighbour on the side of the sign.
+	away := new(big.Int).Add(q, big.NewInt(int64(r.Sign())))
+	switch mode {
+	case RoundDown:
+		return q
+	case RoundUp:
+		return away
+	}
+
+	// Compare the remainder with half the denominator.
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	switch twice.Cmp(r.Denom()) {
+	case 1:
+		return away
+	case -1:
+		return q
+	}
+	if mode == RoundHalfEven && q.Bit(0) == 0 {
+		return q
+	}
+	return away
+}
+
+// pow10 returns 10 to the power n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Rat returns the amount in the major unit of its currency, such as 19.99 for 1999 cents.
+func (m Money) Rat() *big.Rat {
+	digits := minorDigits[m.Currency]
+	return new(big.Rat).SetFrac(big.NewInt(m.Amount), pow10(digits))
+}
+
+// IsZero returns whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive returns whether the amount is above zero.
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative returns whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns the sum of two amounts in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	if o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount || o.Amount < 0 && m.Amount < math.MinInt64-o.Amount {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns the difference of two amounts in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(o.Neg())
+}
+
+// Neg returns the amount with the opposite sign.
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul returns the amount multiplied by a whole number, such as the quantity of an order line.
+func (m Money) Mul(n int64) (Money, error) {
+	p := m.Amount * n
+	if m.Amount != 0 && (p/m.Amount != n || m.Amount == -1 && n == math.MinInt64 || n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Amount: p, Currency: m.Currency}, nil
+}
+
+// MulRat returns the amount multiplied by a fraction, such as a tax rate or discount, rounded to the minor unit.
+func (m Money) MulRat(f *big.Rat, mode RoundingMode) (Money, error) {
+	n := roundRat(new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), f), mode)
+	if !n.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Amount: n.Int64(), Currency: m.Currency}, nil
+}
+
+// Allocate splits the amount in parts proportional to the ratios, such as a discount over order lines, without
+// losing or creating a single minor unit: the parts always add up to the amount. Units left over by rounding down
+// go to the first parts, one each.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	total := new(big.Int)
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, fmt.Errorf("negative ratio %d", r)
+		}
+		total.Add(total, big.NewInt(r))
+	}
+	if total.Sign() == 0 {
+		return nil, fmt.Errorf("ratios must not all be zero")
+	}
+
+	parts := make([]Money, len(ratios))
+	left := m.Amount
+	for i, r := range ratios {
+		share := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(r))
+		share.Quo(share, total)
+		parts[i] = Money{Amount: share.Int64(), Currency: m.Currency}
+		left -= parts[i].Amount
+	}
+
+	// The shares are truncated toward zero, so what is left has the sign of the amount and is less than a unit
+	// per part.
+	step := int64(1)
+	if left < 0 {
+		step = -1
+	}
+	for i := 0; left != 0; i++ {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].Amount += step
+		left -= step
+	}
+
+	return parts, nil
+}
+
+// Cmp compares two amounts in the same currency, returning -1, 0 or 1 as m is less than, equal to or more than o.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Decimal returns the amount in the major unit of its currency, such as "19.99" or "-0.50".
+func (m Money) Decimal() string {
+	digits := minorDigits[m.Currency]
+
+	sign := ""
+	abs := new(big.Int).Abs(big.NewInt(m.Amount)).String()
+	if m.Amount < 0 {
+		sign = "-"
+	}
+	if digits == 0 {
+		return sign + abs
+	}
+
+	if len(abs) <= digits {
+		abs = strings.Repeat("0", digits-len(abs)+1) + abs
+	}
+	return sign + abs[:len(abs)-digits] + "." + abs[len(abs)-digits:]
+}
+
+// String returns the amount with its currency, such as "19.99 EUR".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// moneyJSON is the JSON form of Money. The amount is a decimal string, so clients never parse it into a float.
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON is a method on the Money struct that encodes it as {"amount": "19.99", "currency": "EUR"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount, _ := json.Marshal(m.Decimal())
+	return json.Marshal(moneyJSON{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON is a method on the Money struct that decodes it from its JSON form. The amount may also be
+// a JSON number, which is read from its text, so it is exact too.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.Amount) == 0 {
+		return fmt.Errorf("missing amount")
+	}
+
+	amount := string(v.Amount)
+	if strings.HasPrefix(amount, `"`) {
+		if err := json.Unmarshal(v.Amount, &amount); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseMoney(amount, v.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
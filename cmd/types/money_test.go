@@ -0,0 +1,144 @@
+package types
+
+import (
+	"encoding/json" // Import the encoding/json package to encode and decode amounts
+	"errors"        // Import the errors package to match errors
+	"fmt"           // Import the fmt package to compare parts
+	"math"          // Import the math package for the largest amounts
+	"math/big"      // Import the math/big package for exact fractions
+	"testing"       // Import the testing package to write test cases
+)
+
+// TestParseMoney tests parsing decimal amounts into minor units.
+func TestParseMoney(t *testing.T) {
+	for _, tc := range []struct {
+		amount, currency string
+		expected         int64
+	}{
+		{"19.99", "EUR", 1999},
+		{"19.9", "EUR", 1990},
+		{"19", "EUR", 1900},
+		{".5", "EUR", 50},
+		{"-0.50", "EUR", -50},
+		{"19.9900", "EUR", 1999},
+		{"1500", "JPY", 1500},
+		{"1.250", "KWD", 1250},
+	} {
+		m, err := ParseMoney(tc.amount, tc.currency)
+		if err != nil || m.Amount != tc.expected || m.Currency != tc.currency {
+			t.Errorf("%s %s: expected %d, got %v (%v)", tc.amount, tc.currency, tc.expected, m, err)
+		}
+	}
+
+	for _, amount := range []string{"", ".", "19.999", "1e3", "19,99", "NaN", "99999999999999999999"} {
+		if _, err := ParseMoney(amount, "EUR"); err == nil {
+			t.Errorf("expected %q to be rejected", amount)
+		}
+	}
+	if _, err := ParseMoney("1", "XYZ"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("expected an unknown currency, got %v", err)
+	}
+}
+
+// TestMoneyArithmetic tests that arithmetic checks currencies and overflows.
+func TestMoneyArithmetic(t *testing.T) {
+	a, b := NewMoney(1999, "EUR"), NewMoney(1, "EUR")
+
+	if sum, err := a.Add(b); err != nil || sum != NewMoney(2000, "EUR") {
+		t.Errorf("expected 20.00 EUR, got %v (%v)", sum, err)
+	}
+	if diff, err := b.Sub(a); err != nil || diff != NewMoney(-1998, "EUR") {
+		t.Errorf("expected -19.98 EUR, got %v (%v)", diff, err)
+	}
+	if _, err := a.Add(NewMoney(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected a currency mismatch, got %v", err)
+	}
+	if _, err := NewMoney(math.MaxInt64, "EUR").Add(b); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("expected an overflow, got %v", err)
+	}
+	if _, err := NewMoney(math.MaxInt64/2+1, "EUR").Mul(2); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("expected an overflow, got %v", err)
+	}
+	if total, err := a.Mul(3); err != nil || total != NewMoney(5997, "EUR") {
+		t.Errorf("expected 59.97 EUR, got %v (%v)", total, err)
+	}
+}
+
+// TestMoneyRounding tests the rounding modes on halves and other fractions.
+func TestMoneyRounding(t *testing.T) {
+	for _, tc := range []struct {
+		amount   int64
+		mode     RoundingMode
+		expected int64
+	}{
+		{25, RoundHalfUp, 3}, {25, RoundHalfEven, 2}, {35, RoundHalfEven, 4}, {25, RoundDown, 2}, {21, RoundUp, 3},
+		{-25, RoundHalfUp, -3}, {-25, RoundHalfEven, -2}, {-25, RoundDown, -2}, {-21, RoundUp, -3},
+		{24, RoundHalfUp, 2}, {26, RoundHalfEven, 3},
+	} {
+		// A tenth of the amount falls between two cents.
+		m, err := NewMoney(tc.amount, "EUR").MulRat(big.NewRat(1, 10), tc.mode)
+		if err != nil || m.Amount != tc.expected {
+			t.Errorf("%d/10 with mode %d: expected %d, got %d (%v)", tc.amount, tc.mode, tc.expected, m.Amount, err)
+		}
+	}
+
+	if m, err := RoundMoney(big.NewRat(19995, 1000), "EUR", RoundHalfUp); err != nil || m != NewMoney(2000, "EUR") {
+		t.Errorf("expected 20.00 EUR, got %v (%v)", m, err)
+	}
+	if m, err := RoundMoney(big.NewRat(1, 2), "JPY", RoundHalfEven); err != nil || m != NewMoney(0, "JPY") {
+		t.Errorf("expected 0 JPY, got %v (%v)", m, err)
+	}
+}
+
+// TestMoneyAllocate tests that allocated parts always add up to the amount.
+func TestMoneyAllocate(t *testing.T) {
+	for _, tc := range []struct {
+		amount   int64
+		ratios   []int64
+		expected string
+	}{
+		{100, []int64{1, 1, 1}, "[34 33 33]"},
+		{-100, []int64{1, 1, 1}, "[-34 -33 -33]"},
+		{5, []int64{0, 1, 1}, "[0 3 2]"},
+		{1000, []int64{70, 30}, "[700 300]"},
+	} {
+		parts, err := NewMoney(tc.amount, "EUR").Allocate(tc.ratios...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		amounts := []int64{}
+		for _, p := range parts {
+			amounts = append(amounts, p.Amount)
+		}
+		if fmt.Sprint(amounts) != tc.expected {
+			t.Errorf("%d by %v: expected %s, got %v", tc.amount, tc.ratios, tc.expected, amounts)
+		}
+	}
+
+	if _, err := NewMoney(100, "EUR").Allocate(0, 0); err == nil {
+		t.Error("expected ratios that are all zero to be rejected")
+	}
+}
+
+// TestMoneyJSON tests encoding amounts as decimal strings, and decoding strings and numbers exactly.
+func TestMoneyJSON(t *testing.T) {
+	b, _ := json.Marshal(NewMoney(-5, "EUR"))
+	if string(b) != `{"amount":"-0.05","currency":"EUR"}` {
+		t.Errorf("unexpected encoding %s", b)
+	}
+
+	for _, data := range []string{`{"amount":"0.1","currency":"EUR"}`, `{"amount":0.10,"currency":"EUR"}`} {
+		var m Money
+		if err := json.Unmarshal([]byte(data), &m); err != nil || m != NewMoney(10, "EUR") {
+			t.Errorf("%s: expected 0.10 EUR, got %v (%v)", data, m, err)
+		}
+	}
+
+	for _, data := range []string{`{"currency":"EUR"}`, `{"amount":"0.001","currency":"EUR"}`, `{"amount":"1","currency":"EURO"}`, `19.99`} {
+		var m Money
+		if err := json.Unmarshal([]byte(data), &m); err == nil {
+			t.Errorf("expected %s to be rejected", data)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrOrderNotFound is returned when an order does not exist.
+var ErrOrderNotFound = errors.New("order not found")
+
+// OrderStore is an interface that defines the contract for storing orders.
+type OrderStore interface {
+	// CreateOrder stores an order and its items, and returns its ID.
+	CreateOrder(Order) (int, error)
+
+	// GetOrderByID retrieves an order, with its items, by ID, or returns ErrOrderNotFound.
+	GetOrderByID(id int) (*Order, error)
+
+	// GetOrderByReference retrieves the order placed under a reference, with its items, or nil if there is none.
+	GetOrderByReference(reference string) (*Order, error)
+
+	// GetWaitingItems retrieves the items of a SKU waiting for stock, oldest first.
+	GetWaitingItems(sku string) ([]*OrderItem, error)
+
+	// AllocateItem records the reservation holding the stock of a waiting item, and the warehouse it ships from,
+	// or 0 if none has it in stock.
+	AllocateItem(itemID, reservationID, warehouseID int) error
+
+	// RequeueItem puts the item holding a reservation back to wait for stock, without a reservation or warehouse.
+	RequeueItem(reservationID int) error
+
+	// UpdateOrderStatus changes the status of an order.
+	UpdateOrderStatus(id int, status string) error
+
+	// CountOrders counts the orders a user placed that were not cancelled.
+	CountOrders(userID int) (int, error)
+
+	// GetSalesSince returns the units of each SKU ordered since the given time, in orders that were not cancelled.
+	GetSalesSince(since time.Time) (map[string]int, error)
+
+	// ListOrders retrieves a page of the orders matching the filter, newest first, with their items.
+	// It also returns the cursor of the next page, which is empty when there are no more results. A cursor it
+	// did not hand out is refused with ErrInvalidCursor.
+	ListOrders(OrderFilter) ([]*Order, string, error)
+}
+
+// OrderPlacer is an interface that defines the contract for placing orders without a request, as checkout does.
+type OrderPlacer interface {
+	// PlaceOrder places an order for a user in a currency, and returns it as stored. pay, if not nil, is called
+	// with the order just before it is stored, once stock, promotions and balances are held; if it fails, or
+	// anything else does, nothing is held. An error after pay succeeded means the order was not stored, so the
+	// caller must give back whatever pay charged. An order placed under the reference of the payload before is
+	// returned as stored, without placing or paying it again.
+	PlaceOrder(userID int, currency string, payload CheckoutPayload, pay func(Order) error) (*Order, error)
+}
+
+// Statuses of an order.
+const (
+	OrderPending          = "pending"           // All items have stock held for them.
+	OrderBackordered      = "backordered"       // Some items wait for stock to be received.
+	OrderPreordered       = "preordered"        // Some items wait for the release of the product.
+	OrderPartiallyShipped = "partially_shipped" // Some items have shipped, others are still to ship.
+	OrderShipped          = "shipped"           // Every item has shipped.
+	OrderDelivered        = "delivered"         // Every item has shipped and every shipment was delivered.
+	OrderCancelled        = "cancelled"         // The order was cancelled.
+)
+
+// OrderStatuses lists the statuses of an order.
+var OrderStatuses = []string{OrderPending, OrderBackordered, OrderPreordered, OrderPartiallyShipped, OrderShipped, OrderDelivered, OrderCancelled}
+
+// Availability of an order item at checkout.
+const (
+	ItemInStock   = "in_stock"  // Stock was reserved at checkout.
+	ItemBackorder = "backorder" // Ordered while out of stock.
+	ItemPreorder  = "preorder"  // Ordered before release.
+)
+
+// Order struct represents an order placed by a customer.
+type Order struct {
+	ID        int         `json:"id"`        // Unique identifier for the order.
+	UserID    int         `json:"userId"`    // ID of the customer who placed the order.
+	Reference string      `json:"-"`         // Reference the order was placed under without a request, empty for checkouts.
+	Status    string      `json:"status"`    // One of the Order constants.
+	Items     []OrderItem `json:"items"`     // The ordered items.
+	CreatedAt time.Time   `json:"createdAt"` // Timestamp when the order was placed.
+
+	Subtotal     Money `json:"subtotal"`     // Sum of the items before discounts, in the currency of the order.
+	Discount     Money `json:"discount"`     // Sum of the discounts of promotions.
+	Total        Money `json:"total"`        // What the customer pays: subtotal minus discount plus shipping, with tax added or taken off as it is due.
+	FreeShipping bool  `json:"freeShipping"` // Whether a promotion made shipping free.
+
+	Address          *Address  `json:"address"`          // Where the order ships to, nil if the customer gave no address.
+	Destination      *Location `json:"destination"`      // Coordinates of the address, nil if unknown; used to ship from the nearest warehouses.
+	VATID            string    `json:"vatId"`            // VAT ID of a business customer, empty for consumers.
+	Tax              Money     `json:"tax"`              // Sum of the tax on the items and shipping.
+	PricesIncludeTax bool      `json:"pricesIncludeTax"` // Whether the prices of the items include tax.
+	ReverseCharge    bool      `json:"reverseCharge"`    // Whether the business customer accounts for the VAT instead of the shop.
+
+	ShippingMethodID *int   `json:"shippingMethodId"` // ID of the shipping method chosen, nil if none was or it was deleted since.
+	ShippingMethod   string `json:"shippingMethod"`   // Name of the shipping method when the order was placed.
+	Shipping         Money  `json:"shipping"`         // Price of shipping, as shown to the customer.
+	ShippingTax      Money  `json:"shippingTax"`      // Tax on shipping, included in Tax.
+	ShippingTaxRate  string `json:"shippingTaxRate"`  // Rate shipping was taxed at, as a percentage such as "21".
+
+	GiftCardAmount    Money `json:"giftCardAmount"`    // Part of the total paid with gift cards.
+	StoreCreditAmount Money `json:"storeCreditAmount"` // Part of the total paid with store credit.
+	AmountDue         Money `json:"amountDue"`         // Rest of the total, charged by the payment provider.
+}
+
+// OrderItem struct represents a quantity of a SKU in an order.
+type OrderItem struct {
+	ID            int        `json:"id"`            // Unique identifier for the item.
+	OrderID       int        `json:"orderId"`       // ID of the order.
+	SKU           string     `json:"sku"`           // Stock keeping unit.
+	Quantity      int        `json:"quantity"`      // Quantity ordered.
+	Availability  string     `json:"availability"`  // One of the Item constants.
+	ReservationID *int       `json:"reservationId"` // Reservation holding the stock, nil while waiting for stock.
+	WarehouseID   *int       `json:"warehouseId"`   // Warehouse the item ships from, nil while waiting for stock or if none had it.
+	ExpectedAt    *time.Time `json:"expectedAt"`    // When a waiting item is expected to be available, nil if unknown.
+	UnitPrice     Money      `json:"unitPrice"`     // Price of one unit when the order was placed.
+	Discount      Money      `json:"discount"`      // The part of the discounts of the order given on this item.
+	TaxClass      string     `json:"taxClass"`      // Tax class of the item.
+	TaxRate       string     `json:"taxRate"`       // Rate the item was taxed at, as a percentage such as "21".
+	Tax           Money      `json:"tax"`           // Tax on the item, after discounts.
+	Total         Money      `json:"total"`         // What the customer pays for the item: after discounts, with tax as it is due.
+}
+
+// OrderFilter struct holds the filters and pagination options used when listing the orders of a customer.
+type OrderFilter struct {
+	UserID   int      // ID of the customer who placed the orders.
+	Statuses []string // Only include orders with one of these statuses; all orders if empty.
+	Cursor   string   // Opaque cursor returned by a previous page.
+	Limit    int      // Maximum number of orders to return.
+}
+
+// OrderDetail struct represents an order as its customer sees it, with its packages and returns.
+type OrderDetail struct {
+	*Order
+	Shipments []*Shipment `json:"shipments"` // The packages of the order, oldest first.
+	Returns   []*Return   `json:"returns"`   // The returns of the order, oldest first.
+}
+
+// What became of the items of a past order when it is ordered again.
+const (
+	ReorderAdded        = "added"        // The full quantity is in the cart.
+	ReorderReduced      = "reduced"      // Only the quantity in stock is in the cart.
+	ReorderBackorder    = "backorder"    // The full quantity is in the cart, but will wait for stock.
+	ReorderPreorder     = "preorder"     // The full quantity is in the cart, but will wait for the release.
+	ReorderOutOfStock   = "out_of_stock" // Left out: there is no stock, and no more backorders are accepted.
+	ReorderDiscontinued = "discontinued" // Left out: the SKU is no longer sold.
+)
+
+// Reorder struct represents a cart rebuilt from a past order, priced at the current prices.
+type Reorder struct {
+	OrderID int             `json:"orderId"` // ID of the past order.
+	Lines   []ReorderLine   `json:"lines"`   // What became of each SKU of the order, in the order it was ordered.
+	Cart    CheckoutPayload `json:"cart"`    // The cart, shipping to the address of the order, ready to price or check out.
+	Pricing *CartPricing    `json:"pricing"` // The cart at the current prices and promotions, nil if nothing could be added.
+}
+
+// ReorderLine struct represents a SKU of a past order, and what became of it in the rebuilt cart.
+type ReorderLine struct {
+	SKU               string     `json:"sku"`               // Stock keeping unit.
+	Ordered           int        `json:"ordered"`           // Quantity in the past order.
+	Quantity          int        `json:"quantity"`          // Quantity in the cart, 0 if left out.
+	Status            string     `json:"status"`            // One of the Reorder constants.
+	PreviousUnitPrice Money      `json:"previousUnitPrice"` // Price of one unit in the past order.
+	UnitPrice         *Money     `json:"unitPrice"`         // Price of one unit now, nil if left out.
+	ExpectedAt        *time.Time `json:"expectedAt"`        // When a backorder or preorder is expected to be available, nil if unknown.
+}
+
+// CheckoutPayload struct is used to capture and validate the items of a checkout.
+type CheckoutPayload struct {
+	Items []OrderLine `json:"items" validate:"required,min=1,dive"`        // At least one item is required.
+	Codes []string    `json:"codes" validate:"max=5,dive,required,max=32"` // Up to 5 coupon codes are optional.
+
+	GiftCards       []string `json:"giftCards" validate:"max=5,dive,required,max=32"` // Up to 5 gift card codes are optional.
+	UseStoreCredit  bool     `json:"useStoreCredit"`                                  // Whether to pay with store credit.
+	PaymentMethodID *int     `json:"paymentMethodId"`                                 // Saved payment method charged what is left due; required if anything is.
+
+	Address  *Address  `json:"address"`                 // Address is optional; without it, the order is taxed as sold in the country of the shop.
+	Location *Location `json:"location"`                // Coordinates of the address are optional; with them, the order ships from the nearest warehouses.
+	VATID    string    `json:"vatId" validate:"max=20"` // VAT ID of a business customer is optional.
+
+	ShippingMethodID *int `json:"shippingMethodId"` // Shipping method is optional; it needs an address.
+
+	SubscriptionDiscount string `json:"-"` // Percentage taken off the items of a subscription renewal, such as "10"; never read from requests.
+	Reference            string `json:"-"` // Reference an order is placed under only once, such as a renewal attempt; never read from requests.
+}
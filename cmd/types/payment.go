@@ -0,0 +1,76 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPaymentDeclined is returned when the payment provider refuses to charge a payment method.
+var ErrPaymentDeclined = errors.New("payment declined")
+
+// PaymentProvider is an interface that defines the contract for the service customers pay orders through.
+type PaymentProvider interface {
+	// Charge takes an amount from a saved payment method, and returns the provider's ID of the charge. It returns
+	// ErrPaymentDeclined if the provider refuses. A charge is only made once per reference, so a call that failed
+	// can be retried with the same one; a new attempt after a decline needs a new reference.
+	Charge(PaymentCharge) (string, error)
+
+	// Refund pays part of an order back to what it was paid with, and returns the provider's ID of the refund.
+	// A refund is only made once per reference, so a call that failed can be retried with the same one.
+	Refund(PaymentRefund) (string, error)
+}
+
+// PaymentCharge struct represents an amount to take from a saved payment method.
+type PaymentCharge struct {
+	UserID    int    // ID of the customer.
+	Token     string // The provider's token of the payment method.
+	Amount    Money  // Amount to charge.
+	Reference string // What the charge is for, such as "subscription:3:renewal:2:attempt:1"; the same reference never charges twice.
+}
+
+// PaymentRefund struct represents an amount of an order to pay back to the customer.
+type PaymentRefund struct {
+	OrderID   int    // ID of the order, 0 for a charge whose order could not be placed.
+	Amount    Money  // Amount to pay back, in the currency of the order.
+	Reference string // What the refund is for, such as "return:12"; the same reference never refunds twice.
+}
+
+// PaymentMethodStore is an interface that defines the contract for the payment methods customers save, to be
+// charged later without them, such as for the renewals of subscriptions.
+type PaymentMethodStore interface {
+	// CreatePaymentMethod stores a payment method and returns its ID.
+	CreatePaymentMethod(PaymentMethod) (int, error)
+
+	// GetPaymentMethodByID retrieves a payment method by ID, including removed ones.
+	GetPaymentMethodByID(id int) (*PaymentMethod, error)
+
+	// GetPaymentMethods retrieves the payment methods a user has not removed, oldest first.
+	GetPaymentMethods(userID int) ([]*PaymentMethod, error)
+
+	// RemovePaymentMethod marks a payment method as removed, so it is no longer charged.
+	RemovePaymentMethod(id int, at time.Time) error
+}
+
+// PaymentMethod struct represents a card or account a customer saved with the payment provider. The shop only
+// keeps the provider's token and what the customer needs to recognise it.
+type PaymentMethod struct {
+	ID        int        `json:"id"`        // Unique identifier for the payment method.
+	UserID    int        `json:"userId"`    // ID of the customer.
+	Token     string     `json:"-"`         // The provider's token, which charges are made with; never shown.
+	Brand     string     `json:"brand"`     // Brand of the card or kind of account, such as "visa" or "sepa_debit".
+	Last4     string     `json:"last4"`     // Last 4 digits of the card or account number.
+	ExpMonth  int        `json:"expMonth"`  // Month the card expires, 0 for accounts that do not.
+	ExpYear   int        `json:"expYear"`   // Year the card expires, 0 for accounts that do not.
+	CreatedAt time.Time  `json:"createdAt"` // Timestamp when the payment method was saved.
+	RemovedAt *time.Time `json:"removedAt"` // Timestamp when the customer removed it, nil while it can be charged.
+}
+
+// PaymentMethodPayload struct is used to capture and validate a payment method saved by a customer. The token
+// is created by the payment provider's form, so card numbers never reach the shop.
+type PaymentMethodPayload struct {
+	Token    string `json:"token" validate:"required,max=255"`              // Token is required.
+	Brand    string `json:"brand" validate:"required,max=32"`               // Brand is required.
+	Last4    string `json:"last4" validate:"required,len=4,numeric"`        // Last 4 digits are required.
+	ExpMonth int    `json:"expMonth" validate:"omitempty,min=1,max=12"`     // Expiry month is optional.
+	ExpYear  int    `json:"expYear" validate:"omitempty,min=2000,max=2100"` // Expiry year is optional.
+}
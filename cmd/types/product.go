@@ -0,0 +1,276 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors returned when a product or a variant does not exist.
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrVariantNotFound = errors.New("variant not found")
+)
+
+// ProductStore is an interface that defines the contract for the product catalog.
+type ProductStore interface {
+	// GetProducts retrieves all products, ordered by ID.
+	GetProducts() ([]*Product, error)
+
+	// ListProducts retrieves a page of the products matching the filters of the query, in the order it asks for,
+	// with the number of matches for every facet value.
+	ListProducts(ProductQuery) (*ProductListing, error)
+
+	// GetProductByID retrieves a product by ID, or returns ErrProductNotFound.
+	GetProductByID(id int) (*Product, error)
+
+	// GetProductsByIDs retrieves the products with the given IDs, in the order of the IDs.
+	// IDs of products that do not exist are skipped.
+	GetProductsByIDs(ids []int) ([]*Product, error)
+
+	// GetProductBySKU retrieves a product by SKU, or returns ErrProductNotFound.
+	GetProductBySKU(sku string) (*Product, error)
+
+	// GetProductsAfter retrieves up to limit products with an ID above afterID, ordered by ID,
+	// to go through the whole catalog in batches.
+	GetProductsAfter(afterID, limit int) ([]*Product, error)
+
+	// GetAttributeNames retrieves the names of all attributes set on products or their variants, sorted.
+	GetAttributeNames() ([]string, error)
+
+	// CreateProduct adds a new product and returns its ID.
+	CreateProduct(Product) (int, error)
+
+	// UpdateProduct replaces the details of an existing product.
+	UpdateProduct(Product) error
+
+	// DeleteProduct removes a product.
+	DeleteProduct(id int) error
+}
+
+// CategoryStore is an interface that defines the contract for the product categories.
+type CategoryStore interface {
+	// GetCategories retrieves all categories, ordered by name.
+	GetCategories() ([]*Category, error)
+
+	// GetCategoryByID retrieves a category by ID.
+	GetCategoryByID(id int) (*Category, error)
+
+	// CreateCategory adds a new category and returns its ID.
+	CreateCategory(Category) (int, error)
+
+	// GetAttributeDefinitions retrieves the attributes defined for products in a category, ordered by ID.
+	GetAttributeDefinitions(categoryID int) ([]*AttributeDefinition, error)
+
+	// GetFilterableAttributes retrieves the filterable attributes of all categories, ordered by ID.
+	// A name defined in several categories is returned once, as first defined.
+	GetFilterableAttributes() ([]*AttributeDefinition, error)
+
+	// CreateAttributeDefinition adds an attribute to a category and returns its ID.
+	CreateAttributeDefinition(AttributeDefinition) (int, error)
+
+	// DeleteAttributeDefinition removes an attribute from a category. Values already set on products are kept.
+	DeleteAttributeDefinition(categoryID int, name string) error
+}
+
+// VariantStore is an interface that defines the contract for the variants of products, such as the sizes
+// of a shirt. Every variant has its own SKU, so its own stock.
+type VariantStore interface {
+	// GetVariants retrieves the variants of a product, ordered by ID.
+	GetVariants(productID int) ([]*ProductVariant, error)
+
+	// GetVariantBySKU retrieves a variant by SKU, or returns ErrVariantNotFound.
+	GetVariantBySKU(sku string) (*ProductVariant, error)
+
+	// CreateVariant adds a new variant to a product and returns its ID.
+	CreateVariant(ProductVariant) (int, error)
+
+	// UpdateVariant replaces the details of an existing variant. Its product cannot change.
+	UpdateVariant(ProductVariant) error
+}
+
+// SearchIndex is an interface that defines the contract for full-text product search.
+type SearchIndex interface {
+	// Index adds a product to the index, or replaces it if it is already indexed.
+	Index(Product) error
+
+	// Remove removes a product from the index.
+	Remove(id int) error
+
+	// Search returns the products matching the query, best match first, up to limit results.
+	Search(query string, limit int) ([]SearchHit, error)
+}
+
+// Product struct represents a product in the catalog.
+type Product struct {
+	ID          int       `json:"id"`          // Unique identifier for the product.
+	SKU         string    `json:"sku"`         // Stock keeping unit, linking the product to its inventory.
+	Name        string    `json:"name"`        // Name of the product.
+	Description string    `json:"description"` // Description of the product.
+	Image       string    `json:"image"`       // URL of the product image.
+	Price       Money     `json:"price"`       // Price of the product, in the base currency unless priced for a customer.
+	CategoryID  *int      `json:"categoryId"`  // ID of the category of the product, if it has one.
+	TaxClass    string    `json:"taxClass"`    // Tax class of the product and its variants, one of the TaxClass constants.
+	Weight      int       `json:"weight"`      // Shipping weight of the product and its variants in grams, 0 if unknown.
+	Rating      float64   `json:"rating"`      // Average rating of the product, from 0 to 5.
+	ReviewCount int       `json:"reviewCount"` // Number of ratings the average is based on.
+	CreatedAt   time.Time `json:"createdAt"`   // Timestamp when the product was created.
+
+	Attributes map[string]string `json:"attributes"` // Attributes of the product by name, such as its brand, size and color.
+}
+
+// ProductVariant struct represents a variant of a product, such as a size or color, sold under its own SKU.
+type ProductVariant struct {
+	ID         int               `json:"id"`         // Unique identifier for the variant.
+	ProductID  int               `json:"productId"`  // ID of the product the variant belongs to.
+	SKU        string            `json:"sku"`        // Stock keeping unit of the variant.
+	Name       string            `json:"name"`       // Name of the variant, such as "Red, XL".
+	Price      *Money            `json:"price"`      // Price of the variant, or nil if it sells at the price of its product.
+	Attributes map[string]string `json:"attributes"` // Attributes setting the variant apart, such as its size and color.
+	CreatedAt  time.Time         `json:"createdAt"`  // Timestamp when the variant was created.
+}
+
+// Category struct represents a category of products.
+type Category struct {
+	ID        int       `json:"id"`        // Unique identifier for the category.
+	Slug      string    `json:"slug"`      // Short name of the category, used in URLs and filters.
+	Name      string    `json:"name"`      // Display name of the category.
+	CreatedAt time.Time `json:"createdAt"` // Timestamp when the category was created.
+}
+
+// Types of product attributes.
+const (
+	AttributeString  = "string"  // Free text.
+	AttributeNumber  = "number"  // A number, in the unit of the definition.
+	AttributeEnum    = "enum"    // One of the options of the definition.
+	AttributeBoolean = "boolean" // "true" or "false".
+)
+
+// AttributeDefinition struct represents an attribute products of a category have, such as the screen size of TVs.
+type AttributeDefinition struct {
+	ID         int       `json:"id"`         // Unique identifier for the definition.
+	CategoryID int       `json:"categoryId"` // ID of the category whose products have the attribute.
+	Name       string    `json:"name"`       // Name of the attribute, used as key of product attributes and as listing filter.
+	Label      string    `json:"label"`      // Display name of the attribute.
+	Type       string    `json:"type"`       // One of the Attribute constants.
+	Unit       string    `json:"unit"`       // Unit of number attributes, such as "in" or "kg".
+	Options    []string  `json:"options"`    // Allowed values of enum attributes.
+	Required   bool      `json:"required"`   // Whether every product of the category must have a value.
+	Filterable bool      `json:"filterable"` // Whether product listings can be filtered on the attribute.
+	CreatedAt  time.Time `json:"createdAt"`  // Timestamp when the definition was created.
+}
+
+// Sort orders of product listings.
+const (
+	SortNewest     = "newest"     // Most recently added first.
+	SortPriceAsc   = "price"      // Cheapest first.
+	SortPriceDesc  = "-price"     // Most expensive first.
+	SortPopularity = "popularity" // Most sold recently first.
+	SortRating     = "rating"     // Highest rated first.
+)
+
+// Facets of product listings.
+const (
+	FacetCategory = "category" // Values are category slugs.
+	FacetPrice    = "price"    // Values are price ranges, such as "25-50", or "200-" for the highest range.
+	FacetRating   = "rating"   // Values are minimum ratings, so "4" counts the products rated 4 or higher.
+	FacetInStock  = "inStock"  // The only value, "true", counts the products in stock.
+)
+
+// ProductQuery struct describes a page of a product listing. Filters left empty do not apply.
+// Within a filter any of the values may match, and a product must match all filters.
+type ProductQuery struct {
+	Categories []string            // Slugs of the categories.
+	MinPrice   *Money              // Lowest price in the base currency, inclusive.
+	MaxPrice   *Money              // Highest price in the base currency, inclusive.
+	Attributes map[string][]string // Values of attributes by name, such as {"size": {"M", "L"}}.
+	Ranges     map[string]Range    // Ranges of number attributes by name, such as {"screenSize": {Min: 40}}.
+	Facets     []string            // Names of the attributes to count values of, besides the ones filtered on.
+	InStock    bool                // Whether only products available to sell are listed.
+	MinRating  float64             // Lowest average rating.
+	Sort       string              // One of the Sort constants.
+	After      *ListingCursor      // Where the previous page ended, or nil for the first page.
+	Limit      int                 // Maximum number of products on the page.
+}
+
+// Range struct represents an inclusive range of numbers. Bounds left nil are open.
+type Range struct {
+	Min *float64 // Lowest value.
+	Max *float64 // Highest value.
+}
+
+// ListingCursor struct marks the last product of a page, so the next page starts right after it
+// even when products are added or removed in between.
+type ListingCursor struct {
+	Sort  string `json:"s"` // The sort order of the listing.
+	Value string `json:"v"` // The value the last product was sorted by.
+	ID    int    `json:"i"` // ID of the last product, which breaks ties.
+}
+
+// ProductListing struct represents a page of a product listing.
+type ProductListing struct {
+	Products []*Product              // The products on the page.
+	Total    int                     // Number of products matching all filters.
+	Facets   map[string][]FacetValue // Counts of the values of each facet. See FacetCounts.
+	Next     *ListingCursor          // Where the next page starts, or nil if this is the last page.
+}
+
+// FacetValue struct represents a value of a facet and the number of products that would match if it were selected.
+// Counts apply all other filters but not the facet's own, so they stay useful after selecting a value.
+type FacetValue struct {
+	Value string `json:"value"` // The value, such as a category slug or a color.
+	Count int    `json:"count"` // Number of matching products.
+}
+
+// SearchHit struct represents a product matching a search query.
+type SearchHit struct {
+	ProductID int     `json:"productId"` // ID of the matching product.
+	Score     float64 `json:"score"`     // Relevance of the product, higher is better.
+}
+
+// SearchResult struct represents a product in the search results.
+type SearchResult struct {
+	Product *Product `json:"product"` // The matching product.
+	Score   float64  `json:"score"`   // Relevance of the product, higher is better.
+}
+
+// ProductPayload struct is used to capture and validate the details of a product created or updated by an admin.
+type ProductPayload struct {
+	SKU         string  `json:"sku" validate:"required,max=64"`                                          // SKU is required.
+	Name        string  `json:"name" validate:"required,max=255"`                                        // Name is required.
+	Description string  `json:"description" validate:"max=10000"`                                        // Description is optional.
+	Image       string  `json:"image" validate:"max=255"`                                                // Image is optional.
+	Price       Money   `json:"price" validate:"required,gt=0"`                                          // Price is required and must be positive, in the base currency.
+	CategoryID  *int    `json:"categoryId"`                                                              // Category is optional.
+	TaxClass    string  `json:"taxClass" validate:"omitempty,oneof=standard reduced super_reduced zero"` // TaxClass is optional, standard by default.
+	Weight      int     `json:"weight" validate:"gte=0"`                                                 // Weight is optional, in grams.
+	Rating      float64 `json:"rating" validate:"gte=0,lte=5"`                                           // Rating is optional, from 0 to 5.
+	ReviewCount int     `json:"reviewCount" validate:"gte=0"`                                            // ReviewCount is optional.
+
+	Attributes map[string]string `json:"attributes" validate:"max=50,dive,keys,required,max=64,endkeys,max=255"` // Attributes are optional.
+}
+
+// VariantPayload struct is used to capture and validate the details of a product variant.
+type VariantPayload struct {
+	SKU   string `json:"sku" validate:"required,max=64"`  // SKU is required.
+	Name  string `json:"name" validate:"max=255"`         // Name is optional.
+	Price *Money `json:"price" validate:"omitempty,gt=0"` // Price is optional, in the base currency; without it the variant sells at the product price.
+
+	Attributes map[string]string `json:"attributes" validate:"max=50,dive,keys,required,max=64,endkeys,max=255"` // Attributes are optional.
+}
+
+// CategoryPayload struct is used to capture and validate a category created by an admin.
+type CategoryPayload struct {
+	Slug string `json:"slug" validate:"required,max=64"`  // Slug is required, and may only contain lowercase letters, digits and hyphens.
+	Name string `json:"name" validate:"required,max=255"` // Name is required.
+}
+
+// AttributeDefinitionPayload struct is used to capture and validate an attribute an admin defines for a category.
+type AttributeDefinitionPayload struct {
+	Name       string   `json:"name" validate:"required,max=64"`                                        // Name is required, and must be a camelCase identifier.
+	Label      string   `json:"label" validate:"required,max=255"`                                      // Label is required.
+	Type       string   `json:"type" validate:"required,oneof=string number enum boolean"`              // Type is required.
+	Unit       string   `json:"unit" validate:"max=16"`                                                 // Unit is optional, for number attributes.
+	Options    []string `json:"options" validate:"required_if=Type enum,max=100,dive,required,max=255"` // Options are required for enum attributes.
+	Required   bool     `json:"required"`                                                               // Required is optional.
+	Filterable bool     `json:"filterable"`                                                             // Filterable is optional.
+}
@@ -0,0 +1,136 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPromotionLimit is returned when redeeming a promotion that has reached a usage limit.
+var ErrPromotionLimit = errors.New("promotion usage limit reached")
+
+// PromotionStore is an interface that defines the contract for storing promotions, their redemptions and the
+// customer segments they can target.
+type PromotionStore interface {
+	// GetPromotions retrieves all promotions, newest first.
+	GetPromotions() ([]*Promotion, error)
+
+	// GetPromotionByID retrieves a promotion by ID.
+	GetPromotionByID(id int) (*Promotion, error)
+
+	// GetPromotionByCode retrieves the promotion of a coupon code, which must be in upper case.
+	GetPromotionByCode(code string) (*Promotion, error)
+
+	// GetAutomaticPromotions retrieves the promotions without a code that run at the given time.
+	GetAutomaticPromotions(at time.Time) ([]*Promotion, error)
+
+	// CreatePromotion stores a new promotion and returns its ID.
+	CreatePromotion(Promotion) (int, error)
+
+	// UpdatePromotion replaces a promotion.
+	UpdatePromotion(Promotion) error
+
+	// DeletePromotion removes a promotion. Its redemptions are kept.
+	DeletePromotion(id int) error
+
+	// CountRedemptions returns how often a promotion was redeemed in total, and by the user.
+	CountRedemptions(promotionID, userID int) (total, byUser int, err error)
+
+	// RedeemPromotions records that a user redeems the applied promotions, and returns the IDs of the
+	// redemptions. The usage limits are checked while the promotions are locked, so concurrent checkouts
+	// cannot exceed them; if any promotion is at a limit, nothing is recorded and ErrPromotionLimit is returned.
+	RedeemPromotions(userID int, applied []AppliedPromotion) ([]int, error)
+
+	// SetRedemptionOrder links redemptions to the order they were made for.
+	SetRedemptionOrder(redemptionIDs []int, orderID int) error
+
+	// ReleaseRedemptions removes redemptions of a checkout that failed, so they do not count.
+	ReleaseRedemptions(redemptionIDs []int) error
+
+	// GetSegments retrieves the segments of a customer, sorted.
+	GetSegments(userID int) ([]string, error)
+
+	// AddSegment adds a customer to a segment.
+	AddSegment(userID int, segment string) error
+
+	// RemoveSegment removes a customer from a segment.
+	RemoveSegment(userID int, segment string) error
+}
+
+// Types of promotion action.
+const (
+	ActionPercentage   = "percentage"    // A percentage off the qualifying items.
+	ActionFixedAmount  = "fixed_amount"  // An amount off the qualifying items.
+	ActionFreeShipping = "free_shipping" // No shipping costs.
+	ActionBuyXGetY     = "buy_x_get_y"   // For every X qualifying units bought, Y more of the cheapest are discounted.
+)
+
+// Promotion struct represents a discount, either applied automatically to every cart meeting its conditions,
+// or only to carts with its coupon code.
+type Promotion struct {
+	ID               int                 `json:"id"`               // Unique identifier for the promotion.
+	Name             string              `json:"name"`             // Name shown to customers, such as "Summer sale".
+	Code             string              `json:"code"`             // Coupon code in upper case, empty for automatic promotions.
+	Conditions       PromotionConditions `json:"conditions"`       // What a cart needs to qualify.
+	Action           PromotionAction     `json:"action"`           // The discount given.
+	Priority         int                 `json:"priority"`         // Promotions with a higher priority are applied first.
+	Exclusive        bool                `json:"exclusive"`        // Whether the promotion is never combined with others.
+	UsageLimit       int                 `json:"usageLimit"`       // How often the promotion can be redeemed in total, 0 for no limit.
+	PerCustomerLimit int                 `json:"perCustomerLimit"` // How often a customer can redeem it, 0 for no limit.
+	StartsAt         *time.Time          `json:"startsAt"`         // When the promotion starts, nil to start right away.
+	EndsAt           *time.Time          `json:"endsAt"`           // When the promotion ends, nil to run until deleted.
+	CreatedAt        time.Time           `json:"createdAt"`        // Timestamp when the promotion was created.
+}
+
+// PromotionConditions struct holds the conditions a cart must meet for a promotion. Zero values mean no condition.
+type PromotionConditions struct {
+	MinSubtotal *Money   `json:"minSubtotal"` // Least subtotal of the qualifying items, in the base currency.
+	ProductIDs  []int    `json:"productIds"`  // Only these products qualify, along with the categories.
+	CategoryIDs []int    `json:"categoryIds"` // Only products in these categories qualify, along with the products.
+	FirstOrder  bool     `json:"firstOrder"`  // Only for the first order of a customer.
+	Segments    []string `json:"segments"`    // Only for customers in one of these segments.
+}
+
+// PromotionAction struct describes the discount a promotion gives.
+type PromotionAction struct {
+	Type        string `json:"type"`        // One of the Action constants.
+	Percent     int    `json:"percent"`     // Percentage off, for percentage and buy X get Y actions, where 100 makes the units free.
+	Amount      *Money `json:"amount"`      // Amount off, in the base currency, for fixed amount actions.
+	BuyQuantity int    `json:"buyQuantity"` // Units to buy, for buy X get Y actions.
+	GetQuantity int    `json:"getQuantity"` // Units discounted, for buy X get Y actions.
+}
+
+// AppliedPromotion struct represents a promotion applied to a cart.
+type AppliedPromotion struct {
+	PromotionID  int    `json:"promotionId"`  // ID of the promotion.
+	Name         string `json:"name"`         // Name of the promotion.
+	Code         string `json:"code"`         // Coupon code it was applied with, empty for automatic promotions.
+	Discount     Money  `json:"discount"`     // Discount it gave.
+	FreeShipping bool   `json:"freeShipping"` // Whether it makes shipping free.
+}
+
+// RejectedCode struct represents a coupon code that could not be applied to a cart.
+type RejectedCode struct {
+	Code   string `json:"code"`   // The coupon code.
+	Reason string `json:"reason"` // Why it was not applied.
+}
+
+// PromotionEvaluator is an interface that defines the contract for applying promotions to carts.
+type PromotionEvaluator interface {
+	// Evaluate applies the automatic promotions and those of the codes of the cart. The same cart, promotions
+	// and redemptions always give the same result.
+	Evaluate(Cart) (*CartPricing, error)
+}
+
+// PromotionPayload struct is used to capture and validate a promotion.
+type PromotionPayload struct {
+	Name             string              `json:"name" validate:"required,max=255"`          // Name is required.
+	Code             string              `json:"code" validate:"omitempty,alphanum,max=32"` // Code is optional, letters and digits only.
+	Conditions       PromotionConditions `json:"conditions"`                                // Conditions are optional.
+	Action           PromotionAction     `json:"action"`                                    // Action is checked by its type.
+	Priority         int                 `json:"priority"`                                  // Priority is optional.
+	Exclusive        bool                `json:"exclusive"`                                 // Exclusive is optional.
+	UsageLimit       int                 `json:"usageLimit" validate:"gte=0"`               // UsageLimit must not be negative.
+	PerCustomerLimit int                 `json:"perCustomerLimit" validate:"gte=0"`         // PerCustomerLimit must not be negative.
+	StartsAt         *time.Time          `json:"startsAt"`                                  // StartsAt is optional.
+	EndsAt           *time.Time          `json:"endsAt"`                                    // EndsAt is optional, and after StartsAt.
+}
@@ -0,0 +1,52 @@
+package types
+
+import "time"
+
+// ReorderRuleStore is an interface that defines the contract for the per-SKU reorder rules.
+type ReorderRuleStore interface {
+	// GetReorderRules retrieves all reorder rules, ordered by SKU.
+	GetReorderRules() ([]*ReorderRule, error)
+
+	// SaveReorderRule creates or replaces the reorder rule of a SKU.
+	SaveReorderRule(ReorderRule) error
+
+	// DeleteReorderRule removes the reorder rule of a SKU.
+	DeleteReorderRule(sku string) error
+
+	// SetAlertedAt records when a low-stock alert was last sent for a SKU, or clears it when nil.
+	SetAlertedAt(sku string, at *time.Time) error
+}
+
+// ReorderRule struct represents when and how much of a SKU to reorder.
+type ReorderRule struct {
+	SKU          string     `json:"sku"`          // Stock keeping unit.
+	Threshold    int        `json:"threshold"`    // Alert when the quantity available to sell drops to this level.
+	LeadTimeDays int        `json:"leadTimeDays"` // Days it takes for a reorder to arrive.
+	CoverDays    int        `json:"coverDays"`    // Days of sales a reorder should cover once it has arrived.
+	AlertedAt    *time.Time `json:"alertedAt"`    // When the current low-stock alert was sent, nil if stock is not low.
+	UpdatedAt    time.Time  `json:"updatedAt"`    // Timestamp when the rule was last changed.
+}
+
+// LowStockEvent struct holds the details of an EventLowStock event.
+type LowStockEvent struct {
+	SKU       string `json:"sku"`       // Stock keeping unit.
+	Available int    `json:"available"` // Quantity available to sell.
+	Threshold int    `json:"threshold"` // Reorder threshold of the SKU.
+}
+
+// ReorderSuggestion struct represents how much of a SKU should be reordered, based on recent sales.
+type ReorderSuggestion struct {
+	SKU               string  `json:"sku"`               // Stock keeping unit.
+	Available         int     `json:"available"`         // Quantity available to sell.
+	Threshold         int     `json:"threshold"`         // Reorder threshold, 0 for SKUs without a rule.
+	DailyVelocity     float64 `json:"dailyVelocity"`     // Average units sold per day in the report window.
+	DaysOfStock       float64 `json:"daysOfStock"`       // Days until the available stock runs out, -1 if nothing sells.
+	SuggestedQuantity int     `json:"suggestedQuantity"` // Quantity to order now, 0 if none is needed.
+}
+
+// ReorderRulePayload struct is used to capture and validate the reorder rule of a SKU.
+type ReorderRulePayload struct {
+	Threshold    int `json:"threshold" validate:"min=0"`    // Threshold is required, 0 alerts when sold out.
+	LeadTimeDays int `json:"leadTimeDays" validate:"min=0"` // Lead time is optional, defaults to the configured one.
+	CoverDays    int `json:"coverDays" validate:"min=0"`    // Cover is optional, defaults to the configured one.
+}
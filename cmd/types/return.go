@@ -0,0 +1,150 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotReturnable is returned when a return holds more of an order item than has shipped and is not being
+// returned already.
+var ErrNotReturnable = errors.New("quantity exceeds what can be returned")
+
+// ErrReturnChanged is returned when a return is saved after its status was changed by another request.
+var ErrReturnChanged = errors.New("the return was changed meanwhile")
+
+// ReturnStore is an interface that defines the contract for the returns of orders.
+type ReturnStore interface {
+	// CreateReturn stores a return and its lines, and returns its ID. The order is locked while the quantities
+	// shipped and already being returned are read, so an item cannot be returned twice: ErrNotReturnable is
+	// returned instead.
+	CreateReturn(Return) (int, error)
+
+	// GetReturnByID retrieves a return, with its lines, by ID.
+	GetReturnByID(id int) (*Return, error)
+
+	// GetReturnsByOrder retrieves the returns of an order, with their lines, oldest first.
+	GetReturnsByOrder(orderID int) ([]*Return, error)
+
+	// GetReturns retrieves the returns with a status, or all returns for an empty status, oldest first.
+	GetReturns(status string) ([]*Return, error)
+
+	// UpdateReturn saves the status, notes and refund of a return, and the condition, restocking and refund of its
+	// lines, if the stored return still has the given status; ErrReturnChanged is returned otherwise. The items
+	// and quantities returned never change, and the tracking only through SetReturnTracking.
+	UpdateReturn(ret Return, status string) error
+
+	// ReceiveReturn saves a return whose items arrived, if the stored return is still approved; ErrReturnChanged is
+	// returned otherwise. The order of the return is locked while refund is called with the other returns of the
+	// order, to work out the refund of ret against them, so two returns of an order received at the same time never
+	// refund the same shipping or discount.
+	ReceiveReturn(ret *Return, refund func(others []*Return) error) error
+
+	// SetReturnTracking saves the carrier, tracking number and tracking URL of an approved return, leaving the
+	// rest of it alone. ErrReturnChanged is returned if the return is no longer approved.
+	SetReturnTracking(id int, carrier, trackingNumber, trackingURL string) error
+}
+
+// Statuses of a return.
+const (
+	ReturnRequested  = "requested"  // The customer asked to return items; support has yet to decide.
+	ReturnApproved   = "approved"   // Support agreed; the customer can send the items back.
+	ReturnRejected   = "rejected"   // Support refused the return.
+	ReturnReceived   = "received"   // The items arrived and were graded; restocking or the refund failed and can be retried.
+	ReturnRestocking = "restocking" // A request is putting the items back in stock.
+	ReturnRefunding  = "refunding"  // A request is paying the refund back.
+	ReturnRefunded   = "refunded"   // The refund was made.
+)
+
+// Reasons for returning an item.
+const (
+	ReturnReasonDamaged        = "damaged"          // The item arrived damaged.
+	ReturnReasonDefective      = "defective"        // The item does not work.
+	ReturnReasonWrongItem      = "wrong_item"       // Another item was sent than was ordered.
+	ReturnReasonNotAsDescribed = "not_as_described" // The item differs from its description.
+	ReturnReasonNoLongerNeeded = "no_longer_needed" // The customer changed their mind.
+	ReturnReasonOther          = "other"            // Any other reason, explained in the comment.
+)
+
+// Conditions returned items are graded in when they arrive.
+const (
+	ConditionNew     = "new"     // Unopened, sold again as new.
+	ConditionOpened  = "opened"  // Opened but as new, sold again.
+	ConditionDamaged = "damaged" // Cannot be sold again.
+)
+
+// Return struct represents a request of a customer to send items of an order back for a refund.
+type Return struct {
+	ID          int          `json:"id"`          // Unique identifier for the return.
+	OrderID     int          `json:"orderId"`     // ID of the order.
+	UserID      int          `json:"userId"`      // ID of the customer.
+	Status      string       `json:"status"`      // One of the Return constants.
+	Lines       []ReturnLine `json:"lines"`       // The items returned.
+	Note        string       `json:"note"`        // Note of the customer.
+	SupportNote string       `json:"supportNote"` // Note of support, such as why the return was rejected.
+
+	Carrier        string `json:"carrier"`        // Carrier the customer sent the items back with, empty until they do.
+	TrackingNumber string `json:"trackingNumber"` // Tracking number of the package sent back.
+	TrackingURL    string `json:"trackingUrl"`    // Page where the package sent back can be followed, empty if unknown.
+
+	WarehouseID       *int   `json:"warehouseId"`       // ID of the warehouse the items were restocked in, nil until received.
+	Refund            Money  `json:"refund"`            // Sum of the refund of the lines and of shipping, zero until received.
+	ShippingRefund    Money  `json:"shippingRefund"`    // Part of the refund paying shipping back.
+	ProviderRefund    Money  `json:"providerRefund"`    // Part of the refund paid back through the payment provider.
+	StoreCreditRefund Money  `json:"storeCreditRefund"` // Part of the refund given as store credit, for what gift cards and store credit paid.
+	PaymentRefundID   string `json:"paymentRefundId"`   // ID of the refund at the payment provider, empty until made.
+	StoreCreditEntry  *int   `json:"storeCreditEntry"`  // ID of the store credit entry of the refund, nil until made.
+
+	CreatedAt  time.Time  `json:"createdAt"`  // Timestamp when the return was requested.
+	DecidedAt  *time.Time `json:"decidedAt"`  // When support approved or rejected the return, nil before.
+	ReceivedAt *time.Time `json:"receivedAt"` // When the items arrived, nil before.
+	RefundedAt *time.Time `json:"refundedAt"` // When the refund was made, nil before.
+}
+
+// ReturnLine struct represents a quantity of an order item in a return.
+type ReturnLine struct {
+	OrderItemID int    `json:"orderItemId"` // ID of the order item.
+	SKU         string `json:"sku"`         // Stock keeping unit of the item.
+	Quantity    int    `json:"quantity"`    // Quantity returned.
+	Reason      string `json:"reason"`      // One of the ReturnReason constants.
+	Comment     string `json:"comment"`     // Comment of the customer on the item.
+	Condition   string `json:"condition"`   // One of the Condition constants, empty until received.
+	Restocked   bool   `json:"restocked"`   // Whether the items were put back in stock.
+	Refund      Money  `json:"refund"`      // What is paid back for the items, zero until received.
+}
+
+// ReturnPayload struct is used to capture and validate a return requested by a customer.
+type ReturnPayload struct {
+	Lines []ReturnLinePayload `json:"lines" validate:"required,min=1,max=100,dive"` // At least one line is required.
+	Note  string              `json:"note" validate:"max=1000"`                     // Note is optional.
+}
+
+// ReturnLinePayload struct is used to capture and validate a quantity of an order item to return.
+type ReturnLinePayload struct {
+	OrderItemID int    `json:"orderItemId" validate:"required"`                                                                       // Order item is required.
+	Quantity    int    `json:"quantity" validate:"required,gt=0"`                                                                     // Quantity must be positive.
+	Reason      string `json:"reason" validate:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"` // Reason is required.
+	Comment     string `json:"comment" validate:"max=1000"`                                                                           // Comment is optional.
+}
+
+// ReturnTrackingPayload struct is used to capture and validate how a customer sends the items of a return back.
+type ReturnTrackingPayload struct {
+	Carrier        string `json:"carrier" validate:"required,max=32"`        // Carrier is required.
+	TrackingNumber string `json:"trackingNumber" validate:"required,max=64"` // Tracking number is required.
+}
+
+// ReturnDecisionPayload struct is used to capture and validate support approving or rejecting a return.
+type ReturnDecisionPayload struct {
+	Note string `json:"note" validate:"max=1000"` // Note is optional when approving, and required when rejecting.
+}
+
+// ReceiveReturnPayload struct is used to capture and validate the items of a return arriving.
+type ReceiveReturnPayload struct {
+	WarehouseID int                   `json:"warehouseId"`                                  // Warehouse is required when items can be sold again.
+	Lines       []ReceivedLinePayload `json:"lines" validate:"required,min=1,max=100,dive"` // Every line of the return must be graded.
+}
+
+// ReceivedLinePayload struct is used to capture and validate the condition of a returned item.
+type ReceivedLinePayload struct {
+	OrderItemID int    `json:"orderItemId" validate:"required"`                        // Order item is required.
+	Condition   string `json:"condition" validate:"required,oneof=new opened damaged"` // Condition is required.
+}
@@ -0,0 +1,47 @@
+package types
+
+import "time"
+
+// QueryLogStore is an interface that defines the contract for the log of search queries.
+type QueryLogStore interface {
+	// LogQuery records a search query and the number of results it had.
+	LogQuery(query string, results int) error
+
+	// GetPopularQueries retrieves the queries searched most often since the given time that had results,
+	// most searched first, up to limit queries.
+	GetPopularQueries(since time.Time, limit int) ([]PopularQuery, error)
+}
+
+// Suggester is an interface that defines the contract for search autocomplete.
+type Suggester interface {
+	// Suggest returns completions of what the user has typed so far, up to limit of each kind.
+	Suggest(prefix string, limit int) Suggestions
+
+	// DidYouMean returns the query with misspelled words corrected, or "" if it knows no better query.
+	DidYouMean(query string) string
+}
+
+// PopularQuery struct represents a search query and how often it was searched.
+type PopularQuery struct {
+	Query string `json:"query"` // The normalized query.
+	Count int    `json:"count"` // Number of times it was searched.
+}
+
+// Suggestions struct represents the completions of a partial search query.
+type Suggestions struct {
+	Products   []ProductSuggestion  `json:"products"`   // Products whose name completes the query.
+	Categories []CategorySuggestion `json:"categories"` // Categories whose name completes the query.
+	Queries    []string             `json:"queries"`    // Popular past queries completing the query.
+}
+
+// ProductSuggestion struct represents a product suggested while typing.
+type ProductSuggestion struct {
+	ID   int    `json:"id"`   // ID of the product.
+	Name string `json:"name"` // Name of the product.
+}
+
+// CategorySuggestion struct represents a category suggested while typing.
+type CategorySuggestion struct {
+	Slug string `json:"slug"` // Slug of the category, to filter listings on.
+	Name string `json:"name"` // Name of the category.
+}
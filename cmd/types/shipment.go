@@ -0,0 +1,80 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrOverShipped is returned when a shipment holds more of an order item than is left to ship.
+var ErrOverShipped = errors.New("quantity exceeds what is left to ship")
+
+// ShipmentStore is an interface that defines the contract for the packages orders ship in.
+type ShipmentStore interface {
+	// CreateShipment stores a shipment and its lines, and returns its ID. The order is locked while the quantities
+	// already shipped are read, so concurrent shipments cannot ship an item twice: ErrOverShipped is returned instead.
+	CreateShipment(Shipment) (int, error)
+
+	// GetShipmentByID retrieves a shipment, with its lines, by ID.
+	GetShipmentByID(id int) (*Shipment, error)
+
+	// GetShipmentsByOrder retrieves the shipments of an order, with their lines, oldest first.
+	GetShipmentsByOrder(orderID int) ([]*Shipment, error)
+
+	// UpdateShipment changes the carrier, tracking and status of a shipment. Its lines never change.
+	UpdateShipment(Shipment) error
+}
+
+// Statuses of a shipment, in the order they follow each other.
+const (
+	ShipmentLabelCreated = "label_created" // The label was printed, the carrier has not picked the package up yet.
+	ShipmentInTransit    = "in_transit"    // The carrier has the package.
+	ShipmentDelivered    = "delivered"     // The package was delivered.
+)
+
+// ShipmentStatuses lists the statuses of a shipment in the order they follow each other.
+var ShipmentStatuses = []string{ShipmentLabelCreated, ShipmentInTransit, ShipmentDelivered}
+
+// Shipment struct represents a package shipping some of the items of an order.
+type Shipment struct {
+	ID             int            `json:"id"`             // Unique identifier for the shipment.
+	OrderID        int            `json:"orderId"`        // ID of the order.
+	Carrier        string         `json:"carrier"`        // Carrier delivering the package, such as "dhl".
+	TrackingNumber string         `json:"trackingNumber"` // Tracking number given by the carrier, empty if unknown.
+	TrackingURL    string         `json:"trackingUrl"`    // Page where customers follow the package, empty if unknown.
+	Status         string         `json:"status"`         // One of the Shipment constants.
+	Lines          []ShipmentLine `json:"lines"`          // The items in the package.
+	ShippedAt      *time.Time     `json:"shippedAt"`      // When the carrier took the package, nil before.
+	DeliveredAt    *time.Time     `json:"deliveredAt"`    // When the package was delivered, nil before.
+	CreatedAt      time.Time      `json:"createdAt"`      // Timestamp when the shipment was created.
+}
+
+// ShipmentLine struct represents a quantity of an order item in a shipment.
+type ShipmentLine struct {
+	OrderItemID int    `json:"orderItemId"` // ID of the order item.
+	SKU         string `json:"sku"`         // Stock keeping unit of the item.
+	Quantity    int    `json:"quantity"`    // Quantity in the package.
+}
+
+// ShipmentPayload struct is used to capture and validate a shipment created by an admin.
+type ShipmentPayload struct {
+	Carrier        string                `json:"carrier" validate:"required,max=32"`                                   // Carrier is required.
+	TrackingNumber string                `json:"trackingNumber" validate:"max=64"`                                     // Tracking number is optional.
+	TrackingURL    string                `json:"trackingUrl" validate:"omitempty,url,max=255"`                         // Tracking URL is optional; known carriers get one from the tracking number.
+	Status         string                `json:"status" validate:"omitempty,oneof=label_created in_transit delivered"` // Status is optional, label_created by default.
+	Lines          []ShipmentLinePayload `json:"lines" validate:"required,min=1,max=100,dive"`                         // At least one line is required.
+}
+
+// ShipmentLinePayload struct is used to capture and validate a quantity of an order item to ship.
+type ShipmentLinePayload struct {
+	OrderItemID int `json:"orderItemId" validate:"required"`   // Order item is required.
+	Quantity    int `json:"quantity" validate:"required,gt=0"` // Quantity must be positive.
+}
+
+// UpdateShipmentPayload struct is used to capture and validate the changes an admin makes to a shipment.
+// Every field is optional; only the fields that are present in the request are applied.
+type UpdateShipmentPayload struct {
+	Carrier        *string `json:"carrier" validate:"omitempty,max=32"`                                  // Change the carrier.
+	TrackingNumber *string `json:"trackingNumber" validate:"omitempty,max=64"`                           // Change the tracking number.
+	TrackingURL    *string `json:"trackingUrl" validate:"omitempty,max=255"`                             // Change the tracking URL, or clear it with an empty one.
+	Status         *string `json:"status" validate:"omitempty,oneof=label_created in_transit delivered"` // Move the shipment on; statuses never go back.
+}
@@ -0,0 +1,144 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoShippingRate is returned by a ShippingRateProvider for parcels a shipping method cannot ship.
+var ErrNoShippingRate = errors.New("no shipping rate")
+
+// ShippingStore is an interface that defines the contract for storing shipping zones and their methods.
+type ShippingStore interface {
+	// GetShippingZones retrieves all shipping zones, ordered by ID.
+	GetShippingZones() ([]*ShippingZone, error)
+
+	// GetShippingZoneByID retrieves a shipping zone by ID.
+	GetShippingZoneByID(id int) (*ShippingZone, error)
+
+	// CreateShippingZone stores a new shipping zone and returns its ID.
+	CreateShippingZone(ShippingZone) (int, error)
+
+	// UpdateShippingZone replaces a shipping zone.
+	UpdateShippingZone(ShippingZone) error
+
+	// DeleteShippingZone removes a shipping zone and its methods.
+	DeleteShippingZone(id int) error
+
+	// GetShippingMethods retrieves the methods of a shipping zone, ordered by ID.
+	GetShippingMethods(zoneID int) ([]*ShippingMethod, error)
+
+	// GetShippingMethodByID retrieves a shipping method by ID.
+	GetShippingMethodByID(id int) (*ShippingMethod, error)
+
+	// CreateShippingMethod stores a new shipping method and returns its ID.
+	CreateShippingMethod(ShippingMethod) (int, error)
+
+	// UpdateShippingMethod replaces a shipping method.
+	UpdateShippingMethod(ShippingMethod) error
+
+	// DeleteShippingMethod removes a shipping method.
+	DeleteShippingMethod(id int) error
+}
+
+// ShippingRateProvider is an interface that defines the contract for pricing the shipment of a parcel with a
+// method, such as from its rate table or by asking the API of a carrier.
+type ShippingRateProvider interface {
+	// Name returns the name methods refer to the provider by, such as "table".
+	Name() string
+
+	// Quote returns the price of shipping the parcel with the method, in the base currency. It returns
+	// ErrNoShippingRate if the method cannot ship the parcel.
+	Quote(method ShippingMethod, parcel Parcel) (Money, error)
+}
+
+// ShippingQuoter is an interface that defines the contract for listing the shipping options of a cart.
+type ShippingQuoter interface {
+	// ShippingOptions returns the methods that can ship the priced cart to the address, with their prices in the
+	// currency of the cart, cheapest first.
+	ShippingOptions(address Address, pricing *CartPricing) ([]ShippingOption, error)
+}
+
+// Bases of the rate tables of shipping methods.
+const (
+	RateByWeight = "weight" // Rates by the total weight of the items.
+	RateByPrice  = "price"  // Rates by the total price of the items.
+	RateByItems  = "items"  // Rates by the number of items.
+)
+
+// ShippingZone struct represents the part of the world a set of shipping methods ships to.
+// An address is in the zone that matches it most precisely: by postal code, then by state, then by country.
+// A zone without regions matches any address no other zone does, such as "Rest of world".
+type ShippingZone struct {
+	ID        int              `json:"id"`        // Unique identifier for the zone.
+	Name      string           `json:"name"`      // Name of the zone, such as "Benelux".
+	Regions   []ShippingRegion `json:"regions"`   // The regions in the zone.
+	CreatedAt time.Time        `json:"createdAt"` // Timestamp when the zone was created.
+}
+
+// ShippingRegion struct represents a country, or a part of one, in a shipping zone.
+type ShippingRegion struct {
+	Country     string   `json:"country" validate:"required,len=2"`                   // ISO 3166-1 alpha-2 code of the country.
+	State       string   `json:"state" validate:"max=3"`                              // Code of the state or province, empty for the whole country.
+	PostalCodes []string `json:"postalCodes" validate:"max=100,dive,required,max=33"` // Postal codes, prefixes such as "10*" or ranges such as "1000-1099", empty for all.
+}
+
+// ShippingMethod struct represents a way of shipping to a zone, such as standard or express delivery.
+type ShippingMethod struct {
+	ID        int            `json:"id"`        // Unique identifier for the method.
+	ZoneID    int            `json:"zoneId"`    // ID of the zone the method ships to.
+	Name      string         `json:"name"`      // Name shown to customers, such as "Express".
+	Provider  string         `json:"provider"`  // Name of the ShippingRateProvider pricing the method.
+	Basis     string         `json:"basis"`     // What the rate table goes by, one of the RateBy constants.
+	Rates     []ShippingRate `json:"rates"`     // The rate table.
+	FreeAbove *Money         `json:"freeAbove"` // Total price of the items, in the base currency, from which shipping is free; nil if it never is.
+	MinDays   int            `json:"minDays"`   // Least number of days delivery takes.
+	MaxDays   int            `json:"maxDays"`   // Most number of days delivery takes.
+	Active    bool           `json:"active"`    // Whether customers can choose the method.
+	CreatedAt time.Time      `json:"createdAt"` // Timestamp when the method was created.
+}
+
+// ShippingRate struct represents a row of a rate table: the price of shipping parcels from a weight, price or
+// number of items on, up to the next row. Only the bound of the basis of the method is set.
+type ShippingRate struct {
+	MinWeight int    `json:"minWeight"` // Least total weight in grams, for rates by weight.
+	MinPrice  *Money `json:"minPrice"`  // Least total price in the base currency, for rates by price.
+	MinItems  int    `json:"minItems"`  // Least number of items, for rates by items.
+	Price     Money  `json:"price"`     // Price of shipping, in the base currency.
+}
+
+// Parcel struct describes what is shipped, and where to, for quoting shipping rates.
+type Parcel struct {
+	Address Address // Where the parcel ships to.
+	Weight  int     // Total weight of the items in grams.
+	Items   int     // Number of items.
+	Value   Money   // Total price of the items after discounts, in the base currency.
+}
+
+// ShippingOption struct represents a shipping method available for a cart, with its price.
+type ShippingOption struct {
+	MethodID int    `json:"methodId"` // ID of the method.
+	Name     string `json:"name"`     // Name of the method.
+	Price    Money  `json:"price"`    // Price of shipping, in the currency of the cart.
+	Free     bool   `json:"free"`     // Whether shipping is free, by the threshold of the method or a promotion.
+	MinDays  int    `json:"minDays"`  // Least number of days delivery takes.
+	MaxDays  int    `json:"maxDays"`  // Most number of days delivery takes.
+}
+
+// ShippingZonePayload struct is used to capture and validate a shipping zone.
+type ShippingZonePayload struct {
+	Name    string           `json:"name" validate:"required,max=255"` // Name is required.
+	Regions []ShippingRegion `json:"regions" validate:"max=500,dive"`  // Regions are optional; without them the zone is the rest of the world.
+}
+
+// ShippingMethodPayload struct is used to capture and validate a shipping method.
+type ShippingMethodPayload struct {
+	Name      string         `json:"name" validate:"required,max=255"`                   // Name is required.
+	Provider  string         `json:"provider" validate:"max=32"`                         // Provider is optional, the rate table by default.
+	Basis     string         `json:"basis" validate:"required,oneof=weight price items"` // Basis is required.
+	Rates     []ShippingRate `json:"rates" validate:"max=100"`                           // Rates are required for rate tables.
+	FreeAbove *Money         `json:"freeAbove"`                                          // FreeAbove is optional, in the base currency.
+	MinDays   int            `json:"minDays" validate:"gte=0"`                           // MinDays is optional.
+	MaxDays   int            `json:"maxDays" validate:"gte=0,gtefield=MinDays"`          // MaxDays is optional, and not below MinDays.
+	Active    *bool          `json:"active"`                                             // Active is optional, true by default.
+}
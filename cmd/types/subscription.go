@@ -0,0 +1,149 @@
+package types
+
+import "time"
+
+// SubscriptionStore is an interface that defines the contract for subscription plans, the subscriptions customers
+// take out on them, and their renewals.
+type SubscriptionStore interface {
+	// CreatePlan stores a subscription plan and returns its ID.
+	CreatePlan(SubscriptionPlan) (int, error)
+
+	// GetPlanByID retrieves a subscription plan by ID.
+	GetPlanByID(id int) (*SubscriptionPlan, error)
+
+	// GetPlans retrieves the subscription plans, only the active ones if asked, oldest first.
+	GetPlans(activeOnly bool) ([]*SubscriptionPlan, error)
+
+	// UpdatePlan saves the name, intervals, discount and active flag of a plan. Its SKU never changes.
+	UpdatePlan(SubscriptionPlan) error
+
+	// CreateSubscription stores a subscription and returns its ID.
+	CreateSubscription(Subscription) (int, error)
+
+	// GetSubscriptionByID retrieves a subscription by ID.
+	GetSubscriptionByID(id int) (*Subscription, error)
+
+	// GetSubscriptionsByUser retrieves the subscriptions of a user, oldest first.
+	GetSubscriptionsByUser(userID int) ([]*Subscription, error)
+
+	// GetSubscriptions retrieves the subscriptions with a status, or all subscriptions for an empty status, oldest first.
+	GetSubscriptions(status string) ([]*Subscription, error)
+
+	// GetDueSubscriptions retrieves the active and past due subscriptions whose next run is at or before a time,
+	// the longest overdue first.
+	GetDueSubscriptions(now time.Time) ([]*Subscription, error)
+
+	// ClaimSubscription moves the next run of a due subscription to a later time, if it is still at the time it was
+	// read at, so no other instance renews it meanwhile. It reports whether the subscription was claimed.
+	ClaimSubscription(id int, nextRunAt, until time.Time) (bool, error)
+
+	// UpdateSubscription saves the quantity, interval, payment method, address, status, schedule and dunning state
+	// of a subscription.
+	UpdateSubscription(Subscription) error
+
+	// CreateRenewal records an attempt to renew a subscription and returns its ID.
+	CreateRenewal(SubscriptionRenewal) (int, error)
+
+	// GetRenewals retrieves the renewal attempts of a subscription, newest first.
+	GetRenewals(subscriptionID int) ([]*SubscriptionRenewal, error)
+}
+
+// Statuses of a subscription.
+const (
+	SubscriptionActive    = "active"    // Renewed every interval.
+	SubscriptionPaused    = "paused"    // Not renewed until the customer resumes it.
+	SubscriptionPastDue   = "past_due"  // The last renewal failed; it is retried on the dunning schedule.
+	SubscriptionCancelled = "cancelled" // Ended by the customer, or after the last retry failed.
+)
+
+// Statuses of a renewal attempt.
+const (
+	RenewalPaid   = "paid"   // The renewal order was placed and paid.
+	RenewalFailed = "failed" // The order could not be placed or paid.
+)
+
+// SubscriptionPlan struct represents a product customers can subscribe to, with the intervals it can be delivered
+// at and the discount subscribers get.
+type SubscriptionPlan struct {
+	ID        int       `json:"id"`        // Unique identifier for the plan.
+	SKU       string    `json:"sku"`       // Stock keeping unit of the product or variant delivered.
+	Name      string    `json:"name"`      // Name of the plan, such as "Coffee beans, subscribe and save".
+	Intervals []int     `json:"intervals"` // Days between renewals customers can choose from, ascending.
+	Discount  string    `json:"discount"`  // Percentage taken off renewals, such as "10"; "0" for none.
+	Active    bool      `json:"active"`    // Whether customers can subscribe; existing subscriptions go on renewing.
+	CreatedAt time.Time `json:"createdAt"` // Timestamp when the plan was created.
+}
+
+// Subscription struct represents a customer's standing order of a plan, renewed every interval.
+type Subscription struct {
+	ID               int        `json:"id"`               // Unique identifier for the subscription.
+	UserID           int        `json:"userId"`           // ID of the customer.
+	PlanID           int        `json:"planId"`           // ID of the plan.
+	SKU              string     `json:"sku"`              // Stock keeping unit delivered, that of the plan.
+	Quantity         int        `json:"quantity"`         // Quantity delivered every renewal.
+	IntervalDays     int        `json:"intervalDays"`     // Days between renewals, one of the intervals of the plan.
+	Currency         string     `json:"currency"`         // Currency renewals are priced and charged in.
+	PaymentMethodID  int        `json:"paymentMethodId"`  // ID of the saved payment method renewals are charged to.
+	Address          *Address   `json:"address"`          // Where renewals ship to.
+	ShippingMethodID *int       `json:"shippingMethodId"` // ID of the shipping method renewals ship with, nil for none.
+	Status           string     `json:"status"`           // One of the Subscription constants.
+	NextRunAt        time.Time  `json:"nextRunAt"`        // When the next renewal, or retry of a failed one, is made.
+	Renewals         int        `json:"renewals"`         // Number of renewals paid.
+	FailedAttempts   int        `json:"failedAttempts"`   // Failed attempts at the current renewal, 0 after a paid one.
+	LastError        string     `json:"lastError"`        // Why the last attempt failed, empty after a paid one.
+	LastRenewedAt    *time.Time `json:"lastRenewedAt"`    // When the last renewal was paid, nil before the first.
+	CreatedAt        time.Time  `json:"createdAt"`        // Timestamp when the customer subscribed.
+	CancelledAt      *time.Time `json:"cancelledAt"`      // Timestamp when the subscription ended, nil while it runs.
+}
+
+// SubscriptionRenewal struct represents an attempt to renew a subscription.
+type SubscriptionRenewal struct {
+	ID             int       `json:"id"`             // Unique identifier for the attempt.
+	SubscriptionID int       `json:"subscriptionId"` // ID of the subscription.
+	Renewal        int       `json:"renewal"`        // Which renewal was attempted, from 1.
+	Attempt        int       `json:"attempt"`        // Which attempt at the renewal it was, from 1.
+	Status         string    `json:"status"`         // One of the Renewal constants.
+	OrderID        *int      `json:"orderId"`        // ID of the order placed, nil if it failed.
+	ChargeID       string    `json:"chargeId"`       // The payment provider's ID of the charge, empty if nothing was charged.
+	Error          string    `json:"error"`          // Why it failed, empty if it was paid.
+	CreatedAt      time.Time `json:"createdAt"`      // Timestamp when the attempt was made.
+}
+
+// SubscriptionPlanPayload struct is used to capture and validate a subscription plan created or changed by an admin.
+type SubscriptionPlanPayload struct {
+	SKU       string `json:"sku" validate:"required,max=64"`                                // SKU is required; it cannot be changed later.
+	Name      string `json:"name" validate:"required,max=255"`                              // Name is required.
+	Intervals []int  `json:"intervals" validate:"required,min=1,max=12,dive,min=1,max=365"` // At least one interval of 1 to 365 days is required.
+	Discount  string `json:"discount" validate:"omitempty,numeric"`                         // Discount is optional, a percentage from 0 to 100.
+	Active    *bool  `json:"active"`                                                        // Active is optional, true by default.
+}
+
+// UpdateSubscriptionPlanPayload struct is used to capture and validate the changes an admin makes to a subscription
+// plan. Every field is optional; only the fields that are present in the request are applied.
+type UpdateSubscriptionPlanPayload struct {
+	Name      *string `json:"name" validate:"omitempty,max=255"`                        // Rename the plan.
+	Intervals []int   `json:"intervals" validate:"omitempty,max=12,dive,min=1,max=365"` // Replace the intervals customers can choose from.
+	Discount  *string `json:"discount" validate:"omitempty,numeric"`                    // Change the discount of future renewals.
+	Active    *bool   `json:"active"`                                                   // Open or close the plan to new subscribers.
+}
+
+// SubscribePayload struct is used to capture and validate a subscription a customer takes out.
+type SubscribePayload struct {
+	PlanID           int        `json:"planId" validate:"required"`                 // Plan is required.
+	Quantity         int        `json:"quantity" validate:"required,min=1,max=100"` // Quantity is required.
+	IntervalDays     int        `json:"intervalDays" validate:"required"`           // Interval is required, one of the plan's.
+	PaymentMethodID  int        `json:"paymentMethodId" validate:"required"`        // Payment method is required.
+	Address          *Address   `json:"address" validate:"required"`                // Address is required.
+	ShippingMethodID *int       `json:"shippingMethodId"`                           // Shipping method is optional.
+	StartAt          *time.Time `json:"startAt"`                                    // First renewal is optional, right away by default.
+}
+
+// UpdateSubscriptionPayload struct is used to capture and validate the changes a customer makes to a subscription.
+// Every field is optional; only the fields that are present in the request are applied.
+type UpdateSubscriptionPayload struct {
+	Quantity         *int     `json:"quantity" validate:"omitempty,min=1,max=100"` // Change the quantity delivered.
+	IntervalDays     *int     `json:"intervalDays"`                                // Change how often it is delivered, to another interval of the plan.
+	PaymentMethodID  *int     `json:"paymentMethodId"`                             // Charge another saved payment method.
+	Address          *Address `json:"address"`                                     // Ship to another address.
+	ShippingMethodID *int     `json:"shippingMethodId"`                            // Ship with another shipping method.
+}
@@ -0,0 +1,65 @@
+package types
+
+import "errors"
+
+// ErrInvalidVATID is returned for VAT IDs that are not well-formed, or do not belong to the country shipped to.
+var ErrInvalidVATID = errors.New("invalid VAT ID")
+
+// Tax classes of products. Countries set a rate per class; a class without a rate in a country is taxed at its
+// standard rate there.
+const (
+	TaxClassStandard     = "standard"      // Most goods.
+	TaxClassReduced      = "reduced"       // Goods with a reduced rate, such as food and books in many countries.
+	TaxClassSuperReduced = "super_reduced" // Goods with a second, lower reduced rate.
+	TaxClassZero         = "zero"          // Goods that are zero-rated.
+)
+
+// TaxCalculator is an interface that defines the contract for working out the tax on orders.
+type TaxCalculator interface {
+	// CalculateTax works out the tax on each line of an order shipped to an address. It returns
+	// ErrInvalidVATID for VAT IDs that cannot be used for a reverse charge.
+	CalculateTax(TaxRequest) (*TaxResult, error)
+}
+
+// Address struct represents where an order ships to, as far as taxes are concerned.
+type Address struct {
+	Country    string `json:"country" validate:"required,len=2"` // ISO 3166-1 alpha-2 code of the country, such as "NL".
+	State      string `json:"state" validate:"max=3"`            // Code of the state or province, such as "CA"; needed for US sales tax.
+	PostalCode string `json:"postalCode" validate:"max=16"`      // Postal or ZIP code.
+}
+
+// TaxRequest struct holds the lines of an order to work out the tax on, and who they are sold to.
+type TaxRequest struct {
+	Address  Address       // Where the order ships to; without a country, the order is taxed as sold in the country of the shop.
+	VATID    string        // VAT ID of a business customer, empty for consumers.
+	Currency string        // Currency of the amounts.
+	Lines    []TaxableLine // The lines.
+}
+
+// TaxableLine struct represents a line of an order to work out the tax on.
+type TaxableLine struct {
+	SKU      string // Stock keeping unit.
+	TaxClass string // One of the TaxClass constants.
+	Amount   Money  // What the line sells for after discounts, with tax if prices include it.
+}
+
+// TaxResult struct holds the tax on the lines of an order.
+type TaxResult struct {
+	PricesIncludeTax bool      `json:"pricesIncludeTax"` // Whether the prices the tax was worked out from include it.
+	ReverseCharge    bool      `json:"reverseCharge"`    // Whether the business customer accounts for the VAT instead of the shop.
+	Lines            []LineTax `json:"lines"`            // The tax on each line, in the order of the request.
+	Net              Money     `json:"net"`              // Sum of the lines without tax.
+	Tax              Money     `json:"tax"`              // Sum of the tax.
+	Gross            Money     `json:"gross"`            // Net plus tax: what the customer pays for the lines.
+}
+
+// LineTax struct represents the tax on a line of an order.
+type LineTax struct {
+	SKU          string `json:"sku"`          // Stock keeping unit.
+	TaxClass     string `json:"taxClass"`     // Tax class of the line.
+	Jurisdiction string `json:"jurisdiction"` // Where the rate applies, such as "NL", "US-CA" or "US-CA-90001".
+	Rate         string `json:"rate"`         // Rate as a percentage, such as "21" or "9.5".
+	Net          Money  `json:"net"`          // The line without tax.
+	Tax          Money  `json:"tax"`          // The tax on the line.
+	Gross        Money  `json:"gross"`        // Net plus tax.
+}
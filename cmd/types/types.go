@@ -1,6 +1,12 @@
 package types
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a listing is continued with a cursor it did not hand out.
+var ErrInvalidCursor = errors.New("invalid cursor")
 
 // UserStore is an interface that defines the contract for any data store that handles user-related operations.
 // Any struct that implements these methods can be used as a UserStore in the application.
@@ -16,8 +22,101 @@ type UserStore interface {
 	// CreateUser adds a new user to the data store.
 	// It accepts a User object and returns an error if the operation fails.
 	CreateUser(User) error
+
+	// UpdateUser persists the role and account flags of an existing user.
+	// It accepts a User object identified by its ID and returns an error if the operation fails.
+	UpdateUser(User) error
+
+	// UpdatePassword replaces the password hash of a user and clears the PasswordResetRequired flag.
+	// It returns an error if the user does not exist or the update fails.
+	UpdatePassword(userID int, hashedPassword string) error
+
+	// UpdateCurrency sets the currency a user prefers prices in, or clears it with an empty currency.
+	UpdateCurrency(userID int, currency string) error
+
+	// ListUsers returns a page of users matching the given filter, ordered as the filter requests.
+	// It also returns the cursor of the next page, which is empty when there are no more results. A cursor that
+	// does not continue a listing in the same order is refused with ErrInvalidCursor.
+	ListUsers(UserFilter) ([]*User, string, error)
+
+	// CreateAPIKey stores a new API key for a user and returns its ID.
+	CreateAPIKey(APIKey) (int, error)
+
+	// GetAPIKeyByPrefix retrieves an API key by its visible prefix, including revoked and expired keys.
+	GetAPIKeyByPrefix(prefix string) (*APIKey, error)
+
+	// GetAPIKeysByUserID retrieves all API keys belonging to a user.
+	GetAPIKeysByUserID(userID int) ([]*APIKey, error)
+
+	// RevokeAPIKey revokes the API key with the given ID, provided it belongs to the given user.
+	RevokeAPIKey(userID, keyID int) error
+
+	// TouchAPIKey records that the API key with the given ID was just used.
+	TouchAPIKey(keyID int) error
+}
+
+// AuditStore is an interface that defines the contract for recording audit log entries.
+// Every privileged action (such as an admin changing a user account) should be written through it.
+type AuditStore interface {
+	// CreateAuditEntry appends a new entry to the audit log.
+	// It returns an error if the entry could not be recorded.
+	CreateAuditEntry(AuditEntry) error
+}
+
+// IdentityStore is an interface that defines the contract for storing the external identities linked to users.
+// An external identity is an account at an OpenID Connect provider, identified by the provider name and subject.
+type IdentityStore interface {
+	// GetIdentity retrieves the identity with the given provider and subject.
+	// It returns an error if the identity has not been linked to a user.
+	GetIdentity(provider, subject string) (*Identity, error)
+
+	// CreateIdentity links an external identity to a user.
+	CreateIdentity(Identity) error
 }
 
+// Mailer is an interface that defines the contract for sending emails.
+type Mailer interface {
+	// Send delivers an email, returning an error if it could not be handed over for delivery.
+	Send(Email) error
+}
+
+// MagicLinkStore is an interface that defines the contract for storing passwordless login links.
+type MagicLinkStore interface {
+	// CreateMagicLink stores a new login link.
+	CreateMagicLink(MagicLink) error
+
+	// CountMagicLinksSince counts the login links requested for an email since the given time.
+	CountMagicLinksSince(email string, since time.Time) (int, error)
+
+	// GetMagicLinkByTokenHash retrieves a login link by the hash of its token.
+	GetMagicLinkByTokenHash(tokenHash string) (*MagicLink, error)
+
+	// MarkMagicLinkUsed marks a login link as used. It returns an error if the link was already used,
+	// so a link can only ever be exchanged once, even by concurrent requests.
+	MarkMagicLinkUsed(id int) error
+}
+
+// Roles a user can have. Customers are regular shoppers, admins can use the /admin endpoints.
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
+// Scopes an API key can be limited to. A key without scopes can do everything its user can.
+const (
+	ScopeRead  = "read"  // Safe (GET, HEAD, OPTIONS) requests.
+	ScopeWrite = "write" // Requests that change data.
+	ScopeAdmin = "admin" // Admin-only endpoints, for keys belonging to admins.
+)
+
+// User sort fields accepted by UserFilter.SortBy.
+const (
+	UserSortByID        = "id"
+	UserSortByCreatedAt = "createdAt"
+	UserSortByEmail     = "email"
+	UserSortByLastName  = "lastName"
+)
+
 // User struct represents a user in the application.
 // It contains various fields such as ID, first name, last name, email, password, and the time the user was created.
 type User struct {
@@ -27,6 +126,38 @@ type User struct {
 	Email     string    `json:"email"`     // User's email address.
 	Password  string    `json:"-"`         // User's hashed password. This field is omitted from JSON responses.
 	CreatedAt time.Time `json:"createdAt"` // Timestamp when the user was created.
+
+	Role                  string `json:"role"`                  // User's role, either RoleCustomer or RoleAdmin.
+	Verified              bool   `json:"verified"`              // Whether the user has verified their email address.
+	Disabled              bool   `json:"disabled"`              // Whether the account has been disabled by an admin.
+	PasswordResetRequired bool   `json:"passwordResetRequired"` // Whether the user must change their password on next login.
+	Currency              string `json:"currency"`              // ISO 4217 code of the currency the user prefers prices in, empty for none.
+}
+
+// UserFilter struct holds the search, sorting and pagination options used when listing users.
+// Zero values mean "no filter" for every field.
+type UserFilter struct {
+	EmailPrefix string     // Only include users whose email starts with this prefix.
+	Name        string     // Only include users whose first or last name contains this text.
+	CreatedFrom *time.Time // Only include users created at or after this time.
+	CreatedTo   *time.Time // Only include users created before this time.
+	Role        string     // Only include users with this role.
+	Verified    *bool      // Only include users with this verified status.
+	SortBy      string     // Field to sort by, one of the UserSortBy constants. Defaults to UserSortByID.
+	SortDesc    bool       // Whether to sort in descending order.
+	Cursor      string     // Opaque cursor returned by a previous page.
+	Limit       int        // Maximum number of users to return.
+}
+
+// AuditEntry struct represents a single entry in the audit log.
+type AuditEntry struct {
+	ID         int            `json:"id"`         // Unique identifier for the entry.
+	ActorID    int            `json:"actorId"`    // ID of the user who performed the action.
+	Action     string         `json:"action"`     // Name of the action, e.g. "user.update".
+	TargetType string         `json:"targetType"` // Type of the object the action was performed on, e.g. "user".
+	TargetID   int            `json:"targetId"`   // ID of the object the action was performed on.
+	Details    map[string]any `json:"details"`    // Free-form details about the action, stored as JSON.
+	CreatedAt  time.Time      `json:"createdAt"`  // Timestamp when the action was performed.
 }
 
 // RegisterUserPayload struct is used to capture and validate the data sent when a new user is registering.
@@ -37,3 +168,116 @@ type RegisterUserPayload struct {
 	Email     string `json:"email" validate:"required,email"`            // Email is required and must be a valid email format.
 	Password  string `json:"password" validate:"required,min=3,max=130"` // Password is required, with a minimum of 3 and a maximum of 130 characters.
 }
+
+// LoginUserPayload struct is used to capture and validate the credentials sent when a user logs in.
+type LoginUserPayload struct {
+	Email    string `json:"email" validate:"required,email"` // Email is required and must be a valid email format.
+	Password string `json:"password" validate:"required"`    // Password is required.
+}
+
+// AdminUpdateUserPayload struct is used to capture and validate the changes an admin makes to a user account.
+// Every field is optional; only the fields that are present in the request are applied.
+type AdminUpdateUserPayload struct {
+	Disabled           *bool   `json:"disabled"`                                       // Disable or re-enable the account.
+	ForcePasswordReset *bool   `json:"forcePasswordReset"`                             // Require the user to change their password.
+	Role               *string `json:"role" validate:"omitempty,oneof=customer admin"` // Change the user's role.
+}
+
+// Impersonation struct describes an admin acting as another user.
+// It is attached to requests made with an impersonation token and shown in GET /me responses.
+type Impersonation struct {
+	AdminID   int       `json:"adminId"`   // ID of the admin doing the impersonation.
+	SessionID string    `json:"sessionId"` // Identifier of the impersonation session, as recorded in the audit log.
+	ExpiresAt time.Time `json:"expiresAt"` // Time at which the impersonation token stops working.
+}
+
+// MeResponse struct is the response body of GET /me.
+// Impersonated is true when an admin is acting as the user, so clients can show a banner.
+type MeResponse struct {
+	User          *User          `json:"user"`                    // The authenticated user.
+	Impersonated  bool           `json:"impersonated"`            // Whether the request was made by an admin impersonating the user.
+	Impersonation *Impersonation `json:"impersonation,omitempty"` // Details about the impersonation, if any.
+}
+
+// ChangePasswordPayload struct is used to capture and validate a password change request.
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"currentPassword"`                               // The user's current password, if they have one.
+	NewPassword     string `json:"newPassword" validate:"required,min=3,max=130"` // The new password, with the same rules as registration.
+}
+
+// APIKey struct represents a personal API key used for machine-to-machine access.
+// Only the visible prefix and a hash of the secret are stored; the full key is shown once, when it is created.
+type APIKey struct {
+	ID         int        `json:"id"`         // Unique identifier for the key.
+	UserID     int        `json:"userId"`     // ID of the user the key belongs to.
+	Name       string     `json:"name"`       // Name given to the key by its owner.
+	Prefix     string     `json:"prefix"`     // Visible, unique prefix of the key, used to look it up.
+	SecretHash string     `json:"-"`          // SHA-256 hash of the secret part of the key. Omitted from JSON responses.
+	Scopes     []string   `json:"scopes"`     // Scopes the key is limited to. Empty means unrestricted.
+	ExpiresAt  *time.Time `json:"expiresAt"`  // Time after which the key stops working, if any.
+	LastUsedAt *time.Time `json:"lastUsedAt"` // Time the key was last used to authenticate, if ever.
+	RevokedAt  *time.Time `json:"revokedAt"`  // Time the key was revoked, if it was.
+	CreatedAt  time.Time  `json:"createdAt"`  // Timestamp when the key was created.
+}
+
+// HasScope reports whether the key may be used for the given scope.
+// Keys without scopes are unrestricted.
+func (k *APIKey) HasScope(scope string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateAPIKeyPayload struct is used to capture and validate a request to create an API key.
+type CreateAPIKeyPayload struct {
+	Name      string     `json:"name" validate:"required,max=100"`                        // Name is required.
+	Scopes    []string   `json:"scopes" validate:"omitempty,dive,oneof=read write admin"` // Scopes are optional and must be known.
+	ExpiresAt *time.Time `json:"expiresAt"`                                               // Expiry is optional.
+}
+
+// Identity struct represents an external identity linked to a user.
+type Identity struct {
+	ID        int       `json:"id"`        // Unique identifier for the link.
+	UserID    int       `json:"userId"`    // ID of the linked user.
+	Provider  string    `json:"provider"`  // Name of the identity provider, as configured.
+	Subject   string    `json:"subject"`   // The provider's unique identifier for the account ("sub" claim).
+	Email     string    `json:"email"`     // Email address the provider reported when the identity was linked.
+	CreatedAt time.Time `json:"createdAt"` // Timestamp when the identity was linked.
+}
+
+// Email struct represents an email to send.
+type Email struct {
+	To      string // Address of the recipient.
+	Subject string // Subject line.
+	Body    string // Plain text body.
+}
+
+// MagicLink struct represents a single-use passwordless login link.
+// Only hashes of the token and of the device cookie are stored.
+type MagicLink struct {
+	ID         int        // Unique identifier for the link.
+	Email      string     // Email address the link was requested for.
+	TokenHash  string     // SHA-256 hash of the token in the link.
+	DeviceHash string     // SHA-256 hash of the device cookie of the browser that requested the link.
+	ExpiresAt  time.Time  // Time after which the link stops working.
+	UsedAt     *time.Time // Time the link was exchanged, if it was.
+	CreatedAt  time.Time  // Timestamp when the link was requested.
+}
+
+// MagicLinkRequestPayload struct is used to capture and validate a request for a login link.
+type MagicLinkRequestPayload struct {
+	Email string `json:"email" validate:"required,email"` // Email is required and must be a valid email format.
+}
+
+// MagicLinkExchangePayload struct is used to capture and validate the exchange of a login link for a token.
+type MagicLinkExchangePayload struct {
+	Token string `json:"token" validate:"required"` // The token from the emailed link.
+}
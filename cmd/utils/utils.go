@@ -4,13 +4,26 @@ import (
 	"encoding/json" // Import for encoding and decoding JSON data
 	"fmt"           // Import for formatting error messages
 	"net/http"      // Import for HTTP client and server implementations
+	"reflect"       // Import for reading the values of custom field types
 
+	"github.com/FreekAlberti/Ecom/cmd/types" // Import for the Money type
 	"github.com/go-playground/validator/v10" // Import for data validation
 )
 
 // Validate is an instance of the validator from the go-playground/validator package.
 // This is used to validate structs and fields in various parts of the application.
-var Validate = validator.New()
+var Validate = newValidator()
+
+// newValidator returns a validator that validates Money fields by their amount in minor units,
+// so tags such as required and gt=0 work on prices.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(types.Money).Amount
+	}, types.Money{})
+
+	return v
+}
 
 // ParseJSON is a utility function that decodes the JSON body of an HTTP request into the provided payload.
 // The payload parameter is a generic type (any), meaning it can accept any data structure.